@@ -0,0 +1,88 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/juju/errors"
+)
+
+// acquireRetryDelay is how long AcquireSpecificMachine waits before trying
+// again to allocate a machine that nobody owns.
+var acquireRetryDelay = time.Second
+
+// AcquireSpecificMachine implements Controller.
+//
+// Returns
+//  - NotValid error if args.SystemId is set to another machine
+//  - AlreadyAllocatedError if the machine is allocated to another user
+//  - NoMatchError if there is no such machine, or it can't be allocated,
+//    including when it is already allocated to the authenticated user
+//  - CannotCompleteError if MAAS allocated a different machine, which is
+//    released again
+//  - the context's error if it is done before the machine is allocated
+func (c *controller) AcquireSpecificMachine(ctx context.Context, systemID string, args AllocateMachineArgs) (Machine, ConstraintMatches, error) {
+	if systemID == "" {
+		return nil, ConstraintMatches{}, errors.NotValidf("missing systemID")
+	}
+	if args.SystemId != "" && args.SystemId != systemID {
+		return nil, ConstraintMatches{}, errors.NotValidf("SystemId %q for machine %q", args.SystemId, systemID)
+	}
+	args.SystemId = systemID
+
+	for attempt := 0; ; attempt++ {
+		machine, matches, err := c.AllocateMachine(args)
+		if err == nil {
+			if err := c.checkAcquired(systemID, machine); err != nil {
+				return nil, ConstraintMatches{}, errors.Trace(err)
+			}
+			return machine, matches, nil
+		}
+		if !IsNoMatchError(err) {
+			return nil, matches, errors.Trace(err)
+		}
+		// MAAS refuses to allocate the machine, so find out whether
+		// somebody else has it, or it is only busy for now.
+		machines, readErr := c.Machines(MachinesArgs{SystemIDs: []string{systemID}})
+		if readErr != nil {
+			return nil, matches, errors.Trace(readErr)
+		}
+		if len(machines) == 0 {
+			return nil, matches, NewNoMatchError(fmt.Sprintf("machine %q not found", systemID))
+		}
+		switch owner := machines[0].Owner(); owner {
+		case "":
+		case c.username:
+			return nil, matches, errors.Annotatef(err, "machine %q already allocated to %q", systemID, owner)
+		default:
+			return nil, matches, errors.Wrap(err, NewAlreadyAllocatedError(systemID, owner))
+		}
+		if attempt >= NumberOfRetries {
+			return nil, matches, errors.Trace(err)
+		}
+		c.logger.Debugf("machine %q not allocated, retrying: %v", systemID, err)
+		timer := time.NewTimer(acquireRetryDelay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, matches, errors.Trace(ctx.Err())
+		}
+	}
+}
+
+// checkAcquired returns an error if MAAS allocated another machine than
+// the one asked for, after trying to release the machine again.
+func (c *controller) checkAcquired(systemID string, machine Machine) error {
+	if machine.SystemID() == systemID {
+		return nil
+	}
+	if err := c.ReleaseMachines(ReleaseMachinesArgs{SystemIDs: []string{machine.SystemID()}}); err != nil {
+		c.logger.Warnf("could not release machine %q: %v", machine.SystemID(), err)
+	}
+	return NewCannotCompleteError(fmt.Sprintf("allocated machine %q rather than %q", machine.SystemID(), systemID))
+}
@@ -0,0 +1,121 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+const machine4y3ha3Path = "/api/2.0/machines/?id=4y3ha3"
+
+func (s *controllerSuite) TestAcquireSpecificMachine(c *gc.C) {
+	s.addAllocateResponse(c, http.StatusOK, nil, nil)
+	controller := s.getController(c)
+
+	machine, _, err := controller.AcquireSpecificMachine(context.Background(), "4y3ha3", AllocateMachineArgs{Zone: "default"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.SystemID(), gc.Equals, "4y3ha3")
+	form := s.server.LastRequest().PostForm
+	c.Check(form.Get("system_id"), gc.Equals, "4y3ha3")
+	c.Check(form.Get("zone"), gc.Equals, "default")
+}
+
+func (s *controllerSuite) TestAcquireSpecificMachineConflictingArgs(c *gc.C) {
+	controller := s.getController(c)
+
+	_, _, err := controller.AcquireSpecificMachine(context.Background(), "4y3ha3", AllocateMachineArgs{SystemId: "4y3ha4"})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(err.Error(), gc.Equals, `SystemId "4y3ha4" for machine "4y3ha3" not valid`)
+}
+
+func (s *controllerSuite) TestAcquireSpecificMachineWrongMachine(c *gc.C) {
+	s.addAllocateResponse(c, http.StatusOK, nil, nil)
+	s.server.AddPostResponse("/api/2.0/machines/?op=release", http.StatusOK, "[]")
+	controller := s.getController(c)
+
+	_, _, err := controller.AcquireSpecificMachine(context.Background(), "4y3ha4", AllocateMachineArgs{})
+	c.Assert(err, jc.Satisfies, IsCannotCompleteError)
+	c.Assert(err.Error(), gc.Equals, `allocated machine "4y3ha3" rather than "4y3ha4"`)
+	request := s.server.LastRequest()
+	c.Check(request.URL.String(), gc.Equals, "/api/2.0/machines/?op=release")
+	c.Check(request.PostForm["machines"], jc.DeepEquals, []string{"4y3ha3"})
+}
+
+func (s *controllerSuite) TestAcquireSpecificMachineAlreadyAllocated(c *gc.C) {
+	s.addAllocateResponse(c, http.StatusConflict, nil, nil)
+	s.server.AddGetResponse(machine4y3ha3Path, http.StatusOK, "["+machineResponse+"]")
+	controller := s.getController(c)
+
+	_, _, err := controller.AcquireSpecificMachine(context.Background(), "4y3ha3", AllocateMachineArgs{})
+	c.Assert(err, jc.Satisfies, IsAlreadyAllocatedError)
+	allocated := errors.Cause(err).(*AlreadyAllocatedError)
+	c.Check(allocated.SystemID, gc.Equals, "4y3ha3")
+	c.Check(allocated.Owner, gc.Equals, "thumper")
+}
+
+func (s *controllerSuite) TestAcquireSpecificMachineAllocatedToSelf(c *gc.C) {
+	mine := updateJSONMap(c, machineResponse, map[string]interface{}{"owner": "captain awesome"})
+	s.addAllocateResponse(c, http.StatusConflict, nil, nil)
+	s.server.AddGetResponse(machine4y3ha3Path, http.StatusOK, "["+mine+"]")
+	controller := s.getController(c)
+
+	_, _, err := controller.AcquireSpecificMachine(context.Background(), "4y3ha3", AllocateMachineArgs{})
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+	c.Assert(err, gc.Not(jc.Satisfies), IsAlreadyAllocatedError)
+	c.Assert(err, gc.ErrorMatches, `machine "4y3ha3" already allocated to "captain awesome": .*`)
+}
+
+func (s *controllerSuite) TestAcquireSpecificMachineRetriesFreeMachine(c *gc.C) {
+	s.PatchValue(&acquireRetryDelay, time.Duration(0))
+	free := updateJSONMap(c, machineResponse, map[string]interface{}{"owner": nil})
+	s.addAllocateResponse(c, http.StatusConflict, nil, nil)
+	s.server.AddGetResponse(machine4y3ha3Path, http.StatusOK, "["+free+"]")
+	s.addAllocateResponse(c, http.StatusOK, nil, nil)
+	controller := s.getController(c)
+
+	machine, _, err := controller.AcquireSpecificMachine(context.Background(), "4y3ha3", AllocateMachineArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.SystemID(), gc.Equals, "4y3ha3")
+}
+
+func (s *controllerSuite) TestAcquireSpecificMachineGivesUp(c *gc.C) {
+	s.PatchValue(&acquireRetryDelay, time.Duration(0))
+	free := updateJSONMap(c, machineResponse, map[string]interface{}{"owner": nil})
+	for i := 0; i <= NumberOfRetries; i++ {
+		s.addAllocateResponse(c, http.StatusConflict, nil, nil)
+		s.server.AddGetResponse(machine4y3ha3Path, http.StatusOK, "["+free+"]")
+	}
+	controller := s.getController(c)
+
+	_, _, err := controller.AcquireSpecificMachine(context.Background(), "4y3ha3", AllocateMachineArgs{})
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+}
+
+func (s *controllerSuite) TestAcquireSpecificMachineNotFound(c *gc.C) {
+	s.addAllocateResponse(c, http.StatusConflict, nil, nil)
+	s.server.AddGetResponse(machine4y3ha3Path, http.StatusOK, "[]")
+	controller := s.getController(c)
+
+	_, _, err := controller.AcquireSpecificMachine(context.Background(), "4y3ha3", AllocateMachineArgs{})
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+	c.Assert(err.Error(), gc.Equals, `machine "4y3ha3" not found`)
+}
+
+func (s *controllerSuite) TestAcquireSpecificMachineContextDone(c *gc.C) {
+	free := updateJSONMap(c, machineResponse, map[string]interface{}{"owner": nil})
+	s.addAllocateResponse(c, http.StatusConflict, nil, nil)
+	s.server.AddGetResponse(machine4y3ha3Path, http.StatusOK, "["+free+"]")
+	controller := s.getController(c)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, _, err := controller.AcquireSpecificMachine(ctx, "4y3ha3", AllocateMachineArgs{})
+	c.Assert(errors.Cause(err), gc.Equals, context.Canceled)
+}
@@ -0,0 +1,78 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/netip"
+	"reflect"
+
+	"github.com/juju/errors"
+)
+
+// The typed addresses of the entities are parsed once, when the entities are
+// read. Values that can't be parsed are left as the zero netip.Addr or
+// netip.Prefix, and the parse error is kept so that controllers created with
+// ValidateAddresses can return it.
+
+// parseAddrs parses each of the addresses, returning the first error.
+func parseAddrs(values []string) ([]netip.Addr, error) {
+	var firstErr error
+	result := make([]netip.Addr, len(values))
+	for i, value := range values {
+		addr, err := netip.ParseAddr(value)
+		if err != nil && firstErr == nil {
+			firstErr = errors.Annotatef(err, "IP address %q", value)
+		}
+		result[i] = addr
+	}
+	return result, firstErr
+}
+
+// parseOptionalAddr parses the address, which is the zero netip.Addr if the
+// value is empty.
+func parseOptionalAddr(value string) (netip.Addr, error) {
+	if value == "" {
+		return netip.Addr{}, nil
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Addr{}, errors.Annotatef(err, "IP address %q", value)
+	}
+	return addr, nil
+}
+
+// parsePrefix parses the CIDR.
+func parsePrefix(value string) (netip.Prefix, error) {
+	prefix, err := netip.ParsePrefix(value)
+	if err != nil {
+		return netip.Prefix{}, errors.Annotatef(err, "CIDR %q", value)
+	}
+	return prefix, nil
+}
+
+// addressChecker is implemented by the entities that hold typed addresses,
+// directly or in the entities they contain.
+type addressChecker interface {
+	// addressErr returns the first error from parsing the addresses.
+	addressErr() error
+}
+
+// checkAddresses returns a DeserializationError for the first address of
+// the entity, or slice of entities, that couldn't be parsed.
+func checkAddresses(entity string, value interface{}) error {
+	var err error
+	if checker, ok := value.(addressChecker); ok {
+		err = checker.addressErr()
+	} else if v := reflect.ValueOf(value); v.Kind() == reflect.Slice {
+		for i := 0; i < v.Len() && err == nil; i++ {
+			if checker, ok := v.Index(i).Interface().(addressChecker); ok {
+				err = checker.addressErr()
+			}
+		}
+	}
+	if err != nil {
+		return WrapWithDeserializationError(err, "%s address validation failed", entity)
+	}
+	return nil
+}
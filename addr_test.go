@@ -0,0 +1,83 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+	"net/netip"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type addrSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&addrSuite{})
+
+func (*addrSuite) TestParseAddrs(c *gc.C) {
+	addrs, err := parseAddrs([]string{"10.0.0.1", "bad", "2001:db8::1"})
+	c.Assert(err, gc.ErrorMatches, `IP address "bad": .*`)
+	c.Assert(addrs, jc.DeepEquals, []netip.Addr{
+		netip.MustParseAddr("10.0.0.1"),
+		{},
+		netip.MustParseAddr("2001:db8::1"),
+	})
+}
+
+func (*addrSuite) TestParseOptionalAddr(c *gc.C) {
+	addr, err := parseOptionalAddr("")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(addr.IsValid(), jc.IsFalse)
+
+	_, err = parseOptionalAddr("10.0.0")
+	c.Assert(err, gc.ErrorMatches, `IP address "10.0.0": .*`)
+}
+
+func (*addrSuite) TestCheckAddresses(c *gc.C) {
+	source := parseJSON(c, "["+updateJSONMap(c, machineResponse, map[string]interface{}{
+		"ip_addresses": []string{"192.168.100.300"},
+	})+"]")
+	machines, err := readMachines(twoDotOh, source)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines[0].IPAddrs(), jc.DeepEquals, []netip.Addr{{}})
+
+	c.Assert(checkAddresses("machine", machines[0]), jc.Satisfies, IsDeserializationError)
+	err = checkAddresses("machine", machines)
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err, gc.ErrorMatches, `machine address validation failed: IP address "192.168.100.300": .*`)
+}
+
+func (*addrSuite) TestCheckAddressesNested(c *gc.C) {
+	source := parseJSON(c, subnetResponse)
+	source.([]interface{})[0].(map[string]interface{})["cidr"] = "192.168.100.0/33"
+	subnets, err := readSubnets(twoDotOh, source)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(subnets[0].CIDRPrefix().IsValid(), jc.IsFalse)
+
+	space := &space{subnets: subnets}
+	c.Assert(checkAddresses("space", space), gc.ErrorMatches, `space address validation failed: CIDR "192.168.100.0/33": .*`)
+}
+
+func (s *addrSuite) TestValidateAddresses(c *gc.C) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, "["+updateJSONMap(c, machineResponse, map[string]interface{}{
+		"ip_addresses": []string{"wat"},
+	})+"]")
+	server.Start()
+	s.AddCleanup(func(*gc.C) { server.Close() })
+
+	controller, err := NewController(ControllerArgs{
+		BaseURL:           server.URL,
+		APIKey:            "fake:as:key",
+		ValidateAddresses: true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = controller.Machines(MachinesArgs{})
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+}
@@ -0,0 +1,391 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/utils/set"
+	"gopkg.in/yaml.v2"
+)
+
+// MachineSpec is a declarative description of a machine. A machine is
+// converged to a spec by applying the MachinePlan returned by PlanMachine.
+type MachineSpec struct {
+	// Constraints are used to allocate a machine when the spec is planned
+	// without one.
+	Constraints MachineSpecConstraints `yaml:"constraints"`
+
+	// Interfaces describes the subnet links of the machine's interfaces.
+	// Interfaces that aren't mentioned are left alone.
+	Interfaces []MachineSpecInterface `yaml:"interfaces"`
+
+	// StorageLayout is the name of a MAAS storage layout, e.g. "flat" or
+	// "lvm". MAAS doesn't report the current layout, so it is set whenever
+	// the machine is Ready or Allocated, and ignored otherwise.
+	StorageLayout string `yaml:"storage-layout"`
+
+	// Tags the machine must have. Other tags are left alone.
+	Tags []string `yaml:"tags"`
+
+	// OwnerData values the machine must have. Other keys are left alone.
+	OwnerData map[string]string `yaml:"owner-data"`
+}
+
+// MachineSpecConstraints are the constraints used to allocate a machine,
+// see AllocateMachineArgs.
+type MachineSpecConstraints struct {
+	Hostname     string   `yaml:"hostname"`
+	SystemID     string   `yaml:"system-id"`
+	Architecture string   `yaml:"architecture"`
+	MinCPUCount  int      `yaml:"min-cpu-count"`
+	MinMemory    int      `yaml:"min-memory"`
+	Tags         []string `yaml:"tags"`
+	NotTags      []string `yaml:"not-tags"`
+	Zone         string   `yaml:"zone"`
+	NotInZone    []string `yaml:"not-in-zone"`
+	// Spaces are required spaces, keyed by interface label.
+	Spaces   map[string]string `yaml:"spaces"`
+	NotSpace []string          `yaml:"not-space"`
+}
+
+// MachineSpecInterface describes the subnet links of one interface. The
+// interface is found by MAC address if one is given, and by name otherwise.
+// Links to subnets that aren't listed are removed.
+type MachineSpecInterface struct {
+	Name       string            `yaml:"name"`
+	MACAddress string            `yaml:"mac-address"`
+	Links      []MachineSpecLink `yaml:"links"`
+}
+
+// MachineSpecLink describes a link between an interface and a subnet.
+type MachineSpecLink struct {
+	// Subnet is the CIDR of the subnet.
+	Subnet string `yaml:"subnet"`
+	// Mode is one of "dhcp", "static" or "link_up".
+	Mode string `yaml:"mode"`
+	// IPAddress is only valid for static links.
+	IPAddress      string `yaml:"ip-address"`
+	DefaultGateway bool   `yaml:"default-gateway"`
+}
+
+// ParseMachineSpec reads a MachineSpec from YAML or JSON, and validates it.
+func ParseMachineSpec(data []byte) (MachineSpec, error) {
+	var spec MachineSpec
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return MachineSpec{}, errors.Annotate(err, "parsing machine spec")
+	}
+	if err := spec.Validate(); err != nil {
+		return MachineSpec{}, errors.Trace(err)
+	}
+	return spec, nil
+}
+
+// Validate checks that each of the interfaces and links is complete and
+// consistent.
+func (s *MachineSpec) Validate() error {
+	for _, iface := range s.Interfaces {
+		if iface.Name == "" && iface.MACAddress == "" {
+			return errors.NotValidf("interface without name or MAC address")
+		}
+		for _, link := range iface.Links {
+			if link.Subnet == "" {
+				return errors.NotValidf("interface %q link missing subnet", iface.key())
+			}
+			mode := link.linkMode()
+			switch mode {
+			case LinkModeDHCP, LinkModeLinkUp, LinkModeStatic:
+			default:
+				return errors.NotValidf("interface %q link mode %q", iface.key(), link.Mode)
+			}
+			if mode != LinkModeStatic && (link.IPAddress != "" || link.DefaultGateway) {
+				return errors.NotValidf("interface %q link with IP address or default gateway for mode %q", iface.key(), link.Mode)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *MachineSpec) allocateArgs() AllocateMachineArgs {
+	c := s.Constraints
+	args := AllocateMachineArgs{
+		Hostname:     c.Hostname,
+		SystemId:     c.SystemID,
+		Architecture: c.Architecture,
+		MinCPUCount:  c.MinCPUCount,
+		MinMemory:    c.MinMemory,
+		Tags:         c.Tags,
+		NotTags:      c.NotTags,
+		Zone:         c.Zone,
+		NotInZone:    c.NotInZone,
+		NotSpace:     c.NotSpace,
+	}
+	for _, label := range sortedKeys(c.Spaces) {
+		args.Interfaces = append(args.Interfaces, InterfaceSpec{Label: label, Space: c.Spaces[label]})
+	}
+	return args
+}
+
+func (i *MachineSpecInterface) key() string {
+	if i.MACAddress != "" {
+		return i.MACAddress
+	}
+	return i.Name
+}
+
+func (i *MachineSpecInterface) matches(iface Interface) bool {
+	if i.MACAddress != "" {
+		return strings.EqualFold(i.MACAddress, iface.MACAddress())
+	}
+	return i.Name == iface.Name()
+}
+
+func (l *MachineSpecLink) linkMode() InterfaceLinkMode {
+	return normalizeLinkMode(l.Mode)
+}
+
+func (l *MachineSpecLink) matches(link Link) bool {
+	return l.linkMode() == link.LinkMode() &&
+		(l.IPAddress == "" || l.IPAddress == link.IPAddress())
+}
+
+// PlanAction is one of the changes made when a MachinePlan is applied.
+type PlanAction struct {
+	// Description is a human readable summary of the change.
+	Description string
+
+	apply func() error
+}
+
+// MachinePlan is the set of changes needed to converge a machine to a
+// MachineSpec.
+type MachinePlan struct {
+	// Machine is the machine being converged, or nil if one is to be
+	// allocated.
+	Machine Machine
+
+	// Actions are the changes to be made, in order. If a machine is to be
+	// allocated, the changes to it are planned once it has been allocated.
+	Actions []PlanAction
+
+	controller Controller
+	spec       MachineSpec
+}
+
+// String returns the descriptions of the actions, one per line.
+func (p *MachinePlan) String() string {
+	lines := make([]string, len(p.Actions))
+	for i, action := range p.Actions {
+		lines[i] = action.Description
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Empty returns true if the machine already matches the spec.
+func (p *MachinePlan) Empty() bool {
+	return len(p.Actions) == 0
+}
+
+// PlanMachine compares the machine to the spec and returns the changes
+// needed to converge it, without making any of them. If machine is nil,
+// the plan is to allocate one matching the spec's constraints.
+func PlanMachine(controller Controller, machine Machine, spec MachineSpec) (*MachinePlan, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	plan := &MachinePlan{Machine: machine, controller: controller, spec: spec}
+	if machine == nil {
+		args := spec.allocateArgs()
+		if err := args.Validate(); err != nil {
+			return nil, errors.Trace(err)
+		}
+		plan.Actions = append(plan.Actions, PlanAction{
+			Description: "allocate machine",
+			apply: func() error {
+				allocated, _, err := controller.AllocateMachine(args)
+				if err != nil {
+					return errors.Trace(err)
+				}
+				plan.Machine = allocated
+				return nil
+			},
+		})
+		return plan, nil
+	}
+
+	if err := plan.planInterfaces(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	plan.planStorageLayout()
+	plan.planTags()
+	plan.planOwnerData()
+	return plan, nil
+}
+
+// Apply makes each of the changes in the plan, stopping at the first
+// failure. The machine is returned, including when it was allocated by the
+// plan.
+func (p *MachinePlan) Apply() (Machine, error) {
+	allocating := p.Machine == nil
+	for _, action := range p.Actions {
+		if err := action.apply(); err != nil {
+			return p.Machine, errors.Annotate(err, action.Description)
+		}
+	}
+	if !allocating {
+		return p.Machine, nil
+	}
+	plan, err := PlanMachine(p.controller, p.Machine, p.spec)
+	if err != nil {
+		return p.Machine, errors.Trace(err)
+	}
+	return plan.Apply()
+}
+
+func (p *MachinePlan) add(description string, apply func() error) {
+	p.Actions = append(p.Actions, PlanAction{Description: description, apply: apply})
+}
+
+func (p *MachinePlan) planInterfaces() error {
+	for _, ifaceSpec := range p.spec.Interfaces {
+		var iface Interface
+		for _, candidate := range p.Machine.InterfaceSet() {
+			if ifaceSpec.matches(candidate) {
+				iface = candidate
+				break
+			}
+		}
+		if iface == nil {
+			return errors.NotFoundf("interface %q on machine %q", ifaceSpec.key(), p.Machine.SystemID())
+		}
+
+		wanted := make(map[string]MachineSpecLink)
+		for _, link := range ifaceSpec.Links {
+			wanted[link.Subnet] = link
+		}
+		current := set.NewStrings()
+		for _, link := range iface.Links() {
+			subnet := link.Subnet()
+			if subnet == nil {
+				continue
+			}
+			linkSpec, ok := wanted[subnet.CIDR()]
+			if ok && linkSpec.matches(link) {
+				current.Add(subnet.CIDR())
+				continue
+			}
+			p.add(
+				fmt.Sprintf("unlink %s from %s (%s)", iface.Name(), subnet.CIDR(), link.Mode()),
+				func() error { return iface.UnlinkSubnet(subnet) },
+			)
+		}
+		for _, linkSpec := range ifaceSpec.Links {
+			if current.Contains(linkSpec.Subnet) {
+				continue
+			}
+			subnet, err := p.controller.SubnetByCIDR(linkSpec.Subnet)
+			if IsNoMatchError(err) {
+				return errors.NotFoundf("subnet %q", linkSpec.Subnet)
+			} else if err != nil {
+				return errors.Trace(err)
+			}
+			args := LinkSubnetArgs{
+				Mode:           linkSpec.linkMode(),
+				Subnet:         subnet,
+				IPAddress:      linkSpec.IPAddress,
+				DefaultGateway: linkSpec.DefaultGateway,
+			}
+			description := fmt.Sprintf("link %s to %s (%s)", iface.Name(), linkSpec.Subnet, strings.ToLower(linkSpec.Mode))
+			if linkSpec.IPAddress != "" {
+				description += " with address " + linkSpec.IPAddress
+			}
+			p.add(description, func() error { return iface.LinkSubnet(args) })
+		}
+	}
+	return nil
+}
+
+func (p *MachinePlan) planStorageLayout() {
+	layout := p.spec.StorageLayout
+	if layout == "" {
+		return
+	}
+	switch p.Machine.StatusName() {
+	case "Ready", "Allocated":
+	default:
+		return
+	}
+	systemID := p.Machine.SystemID()
+	p.add(fmt.Sprintf("set storage layout to %q", layout), func() error {
+		params := url.Values{"storage_layout": {layout}}
+		_, _, err := p.controller.Raw("POST", "machines/"+systemID, "set_storage_layout", params, nil)
+		return errors.Trace(err)
+	})
+}
+
+func (p *MachinePlan) planTags() {
+	existing := set.NewStrings(p.Machine.Tags()...)
+	systemID := p.Machine.SystemID()
+	for _, tag := range p.spec.Tags {
+		if existing.Contains(tag) {
+			continue
+		}
+		tag := tag
+		p.add(fmt.Sprintf("add tag %q", tag), func() error {
+			return addMachineTag(p.controller, tag, systemID)
+		})
+	}
+}
+
+func (p *MachinePlan) planOwnerData() {
+	existing := p.Machine.OwnerData()
+	changes := make(map[string]string)
+	for key, value := range p.spec.OwnerData {
+		if current, ok := existing[key]; !ok || current != value {
+			changes[key] = value
+		}
+	}
+	if len(changes) == 0 {
+		return
+	}
+	var descriptions []string
+	for _, key := range sortedKeys(changes) {
+		descriptions = append(descriptions, fmt.Sprintf("%s=%q", key, changes[key]))
+	}
+	p.add("set owner data "+strings.Join(descriptions, ", "), func() error {
+		return p.Machine.SetOwnerData(changes)
+	})
+}
+
+// addMachineTag adds the tag to the machine, creating the tag first if
+// the controller doesn't have it.
+func addMachineTag(controller Controller, tag, systemID string) error {
+	params := url.Values{"add": {systemID}}
+	_, status, err := controller.Raw("POST", "tags/"+tag, "update_nodes", params, nil)
+	if err == nil {
+		return nil
+	}
+	if status != http.StatusNotFound {
+		return errors.Trace(err)
+	}
+	if _, _, err := controller.Raw("POST", "tags", "", url.Values{"name": {tag}}, nil); err != nil {
+		return errors.Annotatef(err, "creating tag %q", tag)
+	}
+	_, _, err = controller.Raw("POST", "tags/"+tag, "update_nodes", params, nil)
+	return errors.Trace(err)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
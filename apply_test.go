@@ -0,0 +1,251 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type applySuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&applySuite{})
+
+const machineSpecYAML = `
+constraints:
+  hostname: untasted-markita
+  min-memory: 1024
+  spaces:
+    eth0: storage
+interfaces:
+- mac-address: 52:54:00:55:b6:80
+  links:
+  - subnet: 192.168.122.0/24
+    mode: static
+    ip-address: 192.168.122.10
+storage-layout: flat
+tags: [virtual, gpu]
+owner-data:
+  fez: phil fish
+  owner: bob
+`
+
+func (s *applySuite) getServerAndController(c *gc.C, response string) (*SimpleTestServer, Controller, Machine) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, "["+response+"]")
+	server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, subnetResponse)
+	machines, err := controller.Machines(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines, gc.HasLen, 1)
+	server.ResetRequests()
+	return server, controller, machines[0]
+}
+
+func (s *applySuite) TestParseMachineSpec(c *gc.C) {
+	spec, err := ParseMachineSpec([]byte(machineSpecYAML))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(spec, jc.DeepEquals, MachineSpec{
+		Constraints: MachineSpecConstraints{
+			Hostname:  "untasted-markita",
+			MinMemory: 1024,
+			Spaces:    map[string]string{"eth0": "storage"},
+		},
+		Interfaces: []MachineSpecInterface{{
+			MACAddress: "52:54:00:55:b6:80",
+			Links: []MachineSpecLink{{
+				Subnet:    "192.168.122.0/24",
+				Mode:      "static",
+				IPAddress: "192.168.122.10",
+			}},
+		}},
+		StorageLayout: "flat",
+		Tags:          []string{"virtual", "gpu"},
+		OwnerData:     map[string]string{"fez": "phil fish", "owner": "bob"},
+	})
+}
+
+func (s *applySuite) TestParseMachineSpecJSON(c *gc.C) {
+	spec, err := ParseMachineSpec([]byte(`{"tags": ["gpu"], "owner-data": {"owner": "bob"}}`))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(spec.Tags, jc.DeepEquals, []string{"gpu"})
+	c.Assert(spec.OwnerData, jc.DeepEquals, map[string]string{"owner": "bob"})
+}
+
+func (s *applySuite) TestParseMachineSpecInvalid(c *gc.C) {
+	_, err := ParseMachineSpec([]byte("tags: {"))
+	c.Assert(err, gc.ErrorMatches, "parsing machine spec: .*")
+}
+
+func (s *applySuite) TestValidate(c *gc.C) {
+	for i, test := range []struct {
+		spec    MachineSpec
+		message string
+	}{{
+		spec:    MachineSpec{Interfaces: []MachineSpecInterface{{}}},
+		message: "interface without name or MAC address not valid",
+	}, {
+		spec: MachineSpec{Interfaces: []MachineSpecInterface{{
+			Name:  "eth0",
+			Links: []MachineSpecLink{{Mode: "dhcp"}},
+		}}},
+		message: `interface "eth0" link missing subnet not valid`,
+	}, {
+		spec: MachineSpec{Interfaces: []MachineSpecInterface{{
+			Name:  "eth0",
+			Links: []MachineSpecLink{{Subnet: "10.0.0.0/24", Mode: "auto"}},
+		}}},
+		message: `interface "eth0" link mode "auto" not valid`,
+	}, {
+		spec: MachineSpec{Interfaces: []MachineSpecInterface{{
+			Name:  "eth0",
+			Links: []MachineSpecLink{{Subnet: "10.0.0.0/24", Mode: "dhcp", IPAddress: "10.0.0.2"}},
+		}}},
+		message: `interface "eth0" link with IP address or default gateway for mode "dhcp" not valid`,
+	}} {
+		c.Logf("test %d", i)
+		err := test.spec.Validate()
+		c.Check(err, jc.Satisfies, errors.IsNotValid)
+		c.Check(err, gc.ErrorMatches, test.message)
+	}
+}
+
+func (s *applySuite) TestPlanMachine(c *gc.C) {
+	_, controller, machine := s.getServerAndController(c, machineResponse)
+	spec, err := ParseMachineSpec([]byte(machineSpecYAML))
+	c.Assert(err, jc.ErrorIsNil)
+
+	plan, err := PlanMachine(controller, machine, spec)
+	c.Assert(err, jc.ErrorIsNil)
+	// The storage layout is skipped as the machine is deployed.
+	c.Assert(plan.String(), gc.Equals, ""+
+		"unlink eth0 from 192.168.100.0/24 (auto)\n"+
+		"link eth0 to 192.168.122.0/24 (static) with address 192.168.122.10\n"+
+		`add tag "gpu"`+"\n"+
+		`set owner data owner="bob"`)
+}
+
+func (s *applySuite) TestPlanMachineStorageLayout(c *gc.C) {
+	response := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"status_name": "Allocated",
+	})
+	_, controller, machine := s.getServerAndController(c, response)
+
+	plan, err := PlanMachine(controller, machine, MachineSpec{StorageLayout: "lvm"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(plan.String(), gc.Equals, `set storage layout to "lvm"`)
+}
+
+func (s *applySuite) TestPlanMachineNoChanges(c *gc.C) {
+	_, controller, machine := s.getServerAndController(c, machineResponse)
+	spec := MachineSpec{
+		Interfaces: []MachineSpecInterface{{
+			MACAddress: "52:54:00:55:b6:80",
+			Links:      []MachineSpecLink{{Subnet: "192.168.100.0/24", Mode: "auto"}},
+		}},
+		Tags:      []string{"magic"},
+		OwnerData: map[string]string{"fez": "phil fish"},
+	}
+	// Validation requires a mode that can be used to link a subnet, so
+	// don't go through it here.
+	plan := &MachinePlan{Machine: machine, controller: controller, spec: spec}
+	c.Assert(plan.planInterfaces(), jc.ErrorIsNil)
+	plan.planTags()
+	plan.planOwnerData()
+	c.Assert(plan.Empty(), jc.IsTrue)
+}
+
+func (s *applySuite) TestPlanMachineMissingInterface(c *gc.C) {
+	_, controller, machine := s.getServerAndController(c, machineResponse)
+	spec := MachineSpec{Interfaces: []MachineSpecInterface{{Name: "eth9"}}}
+
+	_, err := PlanMachine(controller, machine, spec)
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+	c.Assert(err.Error(), gc.Equals, `interface "eth9" on machine "4y3ha3" not found`)
+}
+
+func (s *applySuite) TestPlanMachineMissingSubnet(c *gc.C) {
+	server, controller, machine := s.getServerAndController(c, machineResponse)
+	// The subnets are read again when the CIDR isn't found.
+	server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, subnetResponse)
+	spec := MachineSpec{Interfaces: []MachineSpecInterface{{
+		Name:  "eth0",
+		Links: []MachineSpecLink{{Subnet: "10.0.0.0/8", Mode: "dhcp"}},
+	}}}
+
+	_, err := PlanMachine(controller, machine, spec)
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+}
+
+func (s *applySuite) TestApply(c *gc.C) {
+	server, controller, machine := s.getServerAndController(c, machineResponse)
+	server.AddPostResponse("/MAAS/api/2.0/nodes/4y3ha3/interfaces/35/?op=unlink_subnet", http.StatusOK, interfaceResponse)
+	// The interface is updated from the unlink response, so the link is
+	// made using the resource URI in interfaceResponse.
+	server.AddPostResponse("/MAAS/api/2.0/nodes/4y3ha6/interfaces/40/?op=link_subnet", http.StatusOK, interfaceResponse)
+	server.AddPostResponse("/api/2.0/tags/gpu/?op=update_nodes", http.StatusNotFound, "no tag")
+	server.AddPostResponse("/api/2.0/tags/", http.StatusOK, `{"name": "gpu"}`)
+	server.AddPostResponse("/api/2.0/tags/gpu/?op=update_nodes", http.StatusOK, `{"added": 1, "removed": 0}`)
+	server.AddPostResponse("/MAAS/api/2.0/machines/4y3ha3/?op=set_owner_data", http.StatusOK, machineResponse)
+	spec, err := ParseMachineSpec([]byte(machineSpecYAML))
+	c.Assert(err, jc.ErrorIsNil)
+	plan, err := PlanMachine(controller, machine, spec)
+	c.Assert(err, jc.ErrorIsNil)
+
+	applied, err := plan.Apply()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(applied, gc.Equals, machine)
+
+	requests := server.LastNRequests(6)
+	c.Assert(requests, gc.HasLen, 6)
+	c.Check(requests[1].PostForm.Get("mode"), gc.Equals, "STATIC")
+	c.Check(requests[1].PostForm.Get("ip_address"), gc.Equals, "192.168.122.10")
+	c.Check(requests[3].PostForm.Get("name"), gc.Equals, "gpu")
+	c.Check(requests[4].PostForm.Get("add"), gc.Equals, "4y3ha3")
+	c.Check(requests[5].PostForm.Get("owner"), gc.Equals, "bob")
+	_, found := requests[5].PostForm["fez"]
+	c.Check(found, jc.IsFalse)
+}
+
+func (s *applySuite) TestApplyStopsOnError(c *gc.C) {
+	server, controller, machine := s.getServerAndController(c, machineResponse)
+	server.AddPostResponse("/api/2.0/tags/gpu/?op=update_nodes", http.StatusForbidden, "not admin")
+	plan, err := PlanMachine(controller, machine, MachineSpec{
+		Tags:      []string{"gpu"},
+		OwnerData: map[string]string{"owner": "bob"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, err = plan.Apply()
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+	c.Assert(err.Error(), gc.Equals, `add tag "gpu": not admin`)
+	c.Assert(server.RequestCount(), gc.Equals, 1)
+}
+
+func (s *applySuite) TestApplyAllocates(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusOK, machineResponse)
+	server.AddPostResponse("/MAAS/api/2.0/machines/4y3ha3/?op=set_owner_data", http.StatusOK, machineResponse)
+	spec := MachineSpec{
+		Constraints: MachineSpecConstraints{Hostname: "untasted-markita"},
+		OwnerData:   map[string]string{"owner": "bob"},
+	}
+
+	plan, err := PlanMachine(controller, nil, spec)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(plan.String(), gc.Equals, "allocate machine")
+
+	machine, err := plan.Apply()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machine.SystemID(), gc.Equals, "4y3ha3")
+
+	requests := server.LastNRequests(2)
+	c.Check(requests[0].PostForm.Get("name"), gc.Equals, "untasted-markita")
+	c.Check(requests[1].PostForm.Get("owner"), gc.Equals, "bob")
+}
@@ -0,0 +1,73 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// machinesListing returns a machines listing with n machines, as a large
+// environment would return.
+func machinesListing(n int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("[")
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		systemID := fmt.Sprintf(`"system_id": "m%05d"`, i)
+		buf.WriteString(strings.Replace(machineResponse, `"system_id": "4y3ha3"`, systemID, 1))
+	}
+	buf.WriteString("]")
+	return buf.Bytes()
+}
+
+func benchmarkMachinesDecode(b *testing.B, projection MachineProjection, n int) {
+	body := machinesListing(n)
+	b.SetBytes(int64(len(body)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		source, err := decodeMachines(projection, body)
+		if err != nil {
+			b.Fatal(err)
+		}
+		machines, err := readMachines(twoDotOh, source)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(machines) != n {
+			b.Fatalf("read %d machines, expected %d", len(machines), n)
+		}
+	}
+}
+
+func BenchmarkMachinesDecode5000(b *testing.B) {
+	benchmarkMachinesDecode(b, ProjectFull, 5000)
+}
+
+func BenchmarkMachinesDecodeSummary5000(b *testing.B) {
+	benchmarkMachinesDecode(b, ProjectSummary, 5000)
+}
+
+func BenchmarkReadResponseBody(b *testing.B) {
+	body := machinesListing(500)
+	b.SetBytes(int64(len(body)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		response := &http.Response{
+			Header: make(http.Header),
+			Body:   ioutil.NopCloser(bytes.NewReader(body)),
+		}
+		if _, err := readResponseBody(response); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -4,12 +4,16 @@
 package gomaasapi
 
 import (
+	"strconv"
+
 	"github.com/juju/errors"
 	"github.com/juju/schema"
 	"github.com/juju/version"
 )
 
 type blockdevice struct {
+	controller *controller
+
 	resourceURI string
 
 	id      int
@@ -24,9 +28,21 @@ type blockdevice struct {
 	usedSize  uint64
 	size      uint64
 
+	firmwareVersion string
+	storagePool     string
+	numaNode        int
+
 	partitions []*partition
 }
 
+// bind associates the block device and its partitions with the controller.
+func (b *blockdevice) bind(c *controller) {
+	b.controller = c
+	for _, p := range b.partitions {
+		p.controller = c
+	}
+}
+
 // ID implements BlockDevice.
 func (b *blockdevice) ID() int {
 	return b.id
@@ -77,6 +93,21 @@ func (b *blockdevice) Size() uint64 {
 	return b.size
 }
 
+// FirmwareVersion implements BlockDevice.
+func (b *blockdevice) FirmwareVersion() string {
+	return b.firmwareVersion
+}
+
+// StoragePool implements BlockDevice.
+func (b *blockdevice) StoragePool() string {
+	return b.storagePool
+}
+
+// NUMANode implements BlockDevice.
+func (b *blockdevice) NUMANode() int {
+	return b.numaNode
+}
+
 // Partitions implements BlockDevice.
 func (b *blockdevice) Partitions() []Partition {
 	result := make([]Partition, len(b.partitions))
@@ -86,6 +117,82 @@ func (b *blockdevice) Partitions() []Partition {
 	return result
 }
 
+// partitionsURI is the URI of the block device's partitions collection.
+func (b *blockdevice) partitionsURI() string {
+	return EnsureTrailingSlash(b.resourceURI) + "partitions/"
+}
+
+// RefreshPartitions implements BlockDevice.
+//
+// Returns
+//  - NotSupported error if the block device wasn't read from a controller
+//  - NoMatchError if the block device cannot be found
+//  - PermissionError if the user does not have permission to read the machine
+func (b *blockdevice) RefreshPartitions() error {
+	if b.controller == nil {
+		return errors.NotSupportedf("refreshing partitions of block device %d not read from a controller", b.id)
+	}
+	source, err := b.controller.get(b.partitionsURI())
+	if err != nil {
+		return translateError(opEntity, err)
+	}
+	partitions, err := readPartitions(b.controller.schemaVersion(), source)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err = b.controller.checkDecoded("partition", source, partitions); err != nil {
+		return errors.Trace(err)
+	}
+	for _, p := range partitions {
+		p.controller = b.controller
+	}
+	b.partitions = partitions
+	return nil
+}
+
+// CreatePartitionArgs is an argument struct for calling
+// BlockDevice.CreatePartition.
+type CreatePartitionArgs struct {
+	// Size is the size of the partition in bytes. If zero, the partition
+	// uses the rest of the free space on the block device.
+	Size     uint64
+	Bootable bool
+}
+
+// CreatePartition implements BlockDevice.
+//
+// Returns
+//  - NotSupported error if the block device wasn't read from a controller
+//  - BadRequestError if the server rejects the size, for example if there
+//    isn't enough free space
+//  - CannotCompleteError if the machine isn't Ready or Allocated
+//  - PermissionError if the user does not have permission to change the machine
+//  - NoMatchError if the block device cannot be found
+func (b *blockdevice) CreatePartition(args CreatePartitionArgs) (Partition, error) {
+	if b.controller == nil {
+		return nil, errors.NotSupportedf("creating a partition on block device %d not read from a controller", b.id)
+	}
+	params := NewURLParams()
+	if args.Size > 0 {
+		params.Values.Add("size", strconv.FormatUint(args.Size, 10))
+	}
+	params.MaybeAddBool("bootable", args.Bootable)
+	result, err := b.controller.post(b.partitionsURI(), "", params.Values)
+	if err != nil {
+		return nil, translateError(opEntity, err)
+	}
+	partition, err := readPartition(b.controller.schemaVersion(), result)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err = b.controller.checkDecoded("partition", result, partition); err != nil {
+		return nil, errors.Trace(err)
+	}
+	partition.controller = b.controller
+	b.partitions = append(b.partitions, partition)
+	return partition, nil
+}
+
 func readBlockDevices(controllerVersion version.Number, source interface{}) ([]*blockdevice, error) {
 	checker := schema.List(schema.StringMap(schema.Any()))
 	coerced, err := checker.Coerce(source, nil)
@@ -117,7 +224,7 @@ func readBlockDeviceList(sourceList []interface{}, readFunc blockdeviceDeseriali
 		}
 		blockdevice, err := readFunc(source)
 		if err != nil {
-			return nil, errors.Annotatef(err, "blockdevice %d", i)
+			return nil, annotateListItem(err, "blockdevice", i, source)
 		}
 		result = append(result, blockdevice)
 	}
@@ -130,26 +237,35 @@ var blockdeviceDeserializationFuncs = map[version.Number]blockdeviceDeserializat
 	twoDotOh: blockdevice_2_0,
 }
 
-func blockdevice_2_0(source map[string]interface{}) (*blockdevice, error) {
-	fields := schema.Fields{
-		"resource_uri": schema.String(),
+var blockdevice_2_0Checker = fieldMap("blockdevice", schema.Fields{
+	"resource_uri": schema.String(),
 
-		"id":       schema.ForceInt(),
-		"name":     schema.String(),
-		"model":    schema.OneOf(schema.Nil(""), schema.String()),
-		"id_path":  schema.OneOf(schema.Nil(""), schema.String()),
-		"path":     schema.String(),
-		"used_for": schema.String(),
-		"tags":     schema.List(schema.String()),
+	"id":       schema.ForceInt(),
+	"name":     schema.String(),
+	"model":    schema.OneOf(schema.Nil(""), schema.String()),
+	"id_path":  schema.OneOf(schema.Nil(""), schema.String()),
+	"path":     schema.String(),
+	"used_for": schema.String(),
+	"tags":     schema.OneOf(schema.Nil(""), schema.List(schema.String())),
 
-		"block_size": schema.ForceUint(),
-		"used_size":  schema.ForceUint(),
-		"size":       schema.ForceUint(),
+	"block_size": schema.ForceUint(),
+	"used_size":  schema.ForceUint(),
+	"size":       schema.ForceUint(),
 
-		"partitions": schema.List(schema.StringMap(schema.Any())),
-	}
-	checker := schema.FieldMap(fields, nil)
-	coerced, err := checker.Coerce(source, nil)
+	"firmware_version": schema.OneOf(schema.Nil(""), schema.String()),
+	"storage_pool":     schema.OneOf(schema.Nil(""), schema.String()),
+	"numa_node":        schema.ForceInt(),
+
+	"partitions": schema.List(schema.StringMap(schema.Any())),
+}, schema.Defaults{
+	// Not reported by older controllers.
+	"firmware_version": "",
+	"storage_pool":     "",
+	"numa_node":        0,
+})
+
+func blockdevice_2_0(source map[string]interface{}) (*blockdevice, error) {
+	coerced, err := blockdevice_2_0Checker.Coerce(source, nil)
 	if err != nil {
 		return nil, WrapWithDeserializationError(err, "blockdevice 2.0 schema check failed")
 	}
@@ -164,6 +280,8 @@ func blockdevice_2_0(source map[string]interface{}) (*blockdevice, error) {
 
 	model, _ := valid["model"].(string)
 	idPath, _ := valid["id_path"].(string)
+	firmwareVersion, _ := valid["firmware_version"].(string)
+	storagePool, _ := valid["storage_pool"].(string)
 	result := &blockdevice{
 		resourceURI: valid["resource_uri"].(string),
 
@@ -179,6 +297,10 @@ func blockdevice_2_0(source map[string]interface{}) (*blockdevice, error) {
 		usedSize:  valid["used_size"].(uint64),
 		size:      valid["size"].(uint64),
 
+		firmwareVersion: firmwareVersion,
+		storagePool:     storagePool,
+		numaNode:        valid["numa_node"].(int),
+
 		partitions: partitions,
 	}
 	return result, nil
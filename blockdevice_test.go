@@ -4,12 +4,19 @@
 package gomaasapi
 
 import (
+	"net/http"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
 	jc "github.com/juju/testing/checkers"
 	"github.com/juju/version"
 	gc "gopkg.in/check.v1"
 )
 
-type blockdeviceSuite struct{}
+type blockdeviceSuite struct {
+	testing.CleanupSuite
+}
 
 var _ = gc.Suite(&blockdeviceSuite{})
 
@@ -43,6 +50,106 @@ func (*blockdeviceSuite) TestReadBlockDevices(c *gc.C) {
 	c.Check(partition.UsedFor(), gc.Equals, "ext4 formatted filesystem mounted at /")
 }
 
+func (*blockdeviceSuite) TestReadBlockDevicesLargeSizes(c *gc.C) {
+	// Sizes above 2^53 can't be held exactly by a float64.
+	response := strings.Replace(blockdevicesResponse, `"size": 8589934592`, `"size": 18014398509481985`, 1)
+	response = strings.Replace(response, `"used_size": 8586788864`, `"used_size": 18014398509481983`, 1)
+	source, err := decodeJSON([]byte(response))
+	c.Assert(err, jc.ErrorIsNil)
+	blockdevices, err := readBlockDevices(twoDotOh, source)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(blockdevices, gc.HasLen, 1)
+	c.Check(blockdevices[0].Size(), gc.Equals, uint64(18014398509481985))
+	c.Check(blockdevices[0].UsedSize(), gc.Equals, uint64(18014398509481983))
+}
+
+func (*blockdeviceSuite) TestReadBlockDevicesStorageFields(c *gc.C) {
+	json := parseJSON(c, blockdevicesResponse)
+	source := json.([]interface{})[0].(map[string]interface{})
+	source["firmware_version"] = "2.5+"
+	source["storage_pool"] = "pool-1"
+	source["numa_node"] = 1
+	blockdevices, err := readBlockDevices(twoDotOh, json)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(blockdevices, gc.HasLen, 1)
+	blockdevice := blockdevices[0]
+
+	c.Check(blockdevice.FirmwareVersion(), gc.Equals, "2.5+")
+	c.Check(blockdevice.StoragePool(), gc.Equals, "pool-1")
+	c.Check(blockdevice.NUMANode(), gc.Equals, 1)
+}
+
+func (s *blockdeviceSuite) getServerAndBlockDevice(c *gc.C) (*SimpleTestServer, *blockdevice) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, "["+machineResponse+"]")
+	machines, err := controller.Machines(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	device := machines[0].PhysicalBlockDevice(34)
+	c.Assert(device, gc.NotNil)
+	server.ResetRequests()
+	return server, device.(*blockdevice)
+}
+
+const blockdevicePartitionsPath = "/MAAS/api/2.0/nodes/4y3ha3/blockdevices/34/partitions/"
+
+func (s *blockdeviceSuite) TestRefreshPartitions(c *gc.C) {
+	server, device := s.getServerAndBlockDevice(c)
+	server.AddGetResponse(blockdevicePartitionsPath, http.StatusOK, "[]")
+
+	err := device.RefreshPartitions()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(device.Partitions(), gc.HasLen, 0)
+}
+
+func (s *blockdeviceSuite) TestRefreshPartitionsNotFound(c *gc.C) {
+	_, device := s.getServerAndBlockDevice(c)
+	err := device.RefreshPartitions()
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+}
+
+func (s *blockdeviceSuite) TestCreatePartition(c *gc.C) {
+	server, device := s.getServerAndBlockDevice(c)
+	response := updateJSONMap(c, partitionResponse, map[string]interface{}{
+		"id":         2,
+		"bootable":   true,
+		"filesystem": nil,
+	})
+	server.AddPostResponse(blockdevicePartitionsPath+"?op=", http.StatusOK, response)
+
+	partition, err := device.CreatePartition(CreatePartitionArgs{Size: 1 << 33, Bootable: true})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(partition.ID(), gc.Equals, 2)
+	c.Check(partition.Bootable(), jc.IsTrue)
+	c.Check(device.Partitions(), gc.HasLen, 2)
+	form := server.LastRequest().PostForm
+	c.Check(form.Get("size"), gc.Equals, "8589934592")
+	c.Check(form.Get("bootable"), gc.Equals, "true")
+}
+
+func (s *blockdeviceSuite) TestCreatePartitionRestOfDevice(c *gc.C) {
+	server, device := s.getServerAndBlockDevice(c)
+	server.AddPostResponse(blockdevicePartitionsPath+"?op=", http.StatusOK, partitionResponse)
+
+	_, err := device.CreatePartition(CreatePartitionArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	form := server.LastRequest().PostForm
+	_, ok := form["size"]
+	c.Check(ok, jc.IsFalse)
+}
+
+func (s *blockdeviceSuite) TestCreatePartitionBadRequest(c *gc.C) {
+	server, device := s.getServerAndBlockDevice(c)
+	server.AddPostResponse(blockdevicePartitionsPath+"?op=", http.StatusBadRequest, "not enough space")
+	_, err := device.CreatePartition(CreatePartitionArgs{Size: 1 << 40})
+	c.Assert(err, jc.Satisfies, IsBadRequestError)
+}
+
+func (*blockdeviceSuite) TestCreatePartitionUnbound(c *gc.C) {
+	var empty blockdevice
+	_, err := empty.CreatePartition(CreatePartitionArgs{})
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+}
+
 func (*blockdeviceSuite) TestReadBlockDevicesWithNulls(c *gc.C) {
 	blockdevices, err := readBlockDevices(twoDotOh, parseJSON(c, blockdevicesWithNullsResponse))
 	c.Assert(err, jc.ErrorIsNil)
@@ -53,6 +160,16 @@ func (*blockdeviceSuite) TestReadBlockDevicesWithNulls(c *gc.C) {
 	c.Check(blockdevice.IDPath(), gc.Equals, "")
 }
 
+func (*blockdeviceSuite) TestReadBlockDevicesNullTags(c *gc.C) {
+	json := parseJSON(c, blockdevicesWithNullsResponse)
+	json.([]interface{})[0].(map[string]interface{})["tags"] = nil
+	blockdevices, err := readBlockDevices(twoDotOh, json)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(blockdevices, gc.HasLen, 1)
+	c.Check(blockdevices[0].Tags(), gc.NotNil)
+	c.Check(blockdevices[0].Tags(), gc.HasLen, 0)
+}
+
 func (*blockdeviceSuite) TestLowVersion(c *gc.C) {
 	_, err := readBlockDevices(version.MustParse("1.9.0"), parseJSON(c, blockdevicesResponse))
 	c.Assert(err, jc.Satisfies, IsUnsupportedVersionError)
@@ -6,7 +6,6 @@ package gomaasapi
 import (
 	"strings"
 
-	"github.com/juju/errors"
 	"github.com/juju/schema"
 	"github.com/juju/utils/set"
 	"github.com/juju/version"
@@ -87,7 +86,7 @@ func readBootResourceList(sourceList []interface{}, readFunc bootResourceDeseria
 		}
 		bootResource, err := readFunc(source)
 		if err != nil {
-			return nil, errors.Annotatef(err, "boot resource %d", i)
+			return nil, annotateListItem(err, "boot resource", i, source)
 		}
 		result = append(result, bootResource)
 	}
@@ -100,22 +99,21 @@ var bootResourceDeserializationFuncs = map[version.Number]bootResourceDeserializ
 	twoDotOh: bootResource_2_0,
 }
 
+var bootResource_2_0Checker = fieldMap("boot resource", schema.Fields{
+	"resource_uri": schema.String(),
+	"id":           schema.ForceInt(),
+	"name":         schema.String(),
+	"type":         schema.String(),
+	"architecture": schema.String(),
+	"subarches":    schema.String(),
+	"kflavor":      schema.String(),
+}, schema.Defaults{
+	"subarches": "",
+	"kflavor":   "",
+})
+
 func bootResource_2_0(source map[string]interface{}) (*bootResource, error) {
-	fields := schema.Fields{
-		"resource_uri": schema.String(),
-		"id":           schema.ForceInt(),
-		"name":         schema.String(),
-		"type":         schema.String(),
-		"architecture": schema.String(),
-		"subarches":    schema.String(),
-		"kflavor":      schema.String(),
-	}
-	defaults := schema.Defaults{
-		"subarches": "",
-		"kflavor":   "",
-	}
-	checker := schema.FieldMap(fields, defaults)
-	coerced, err := checker.Coerce(source, nil)
+	coerced, err := bootResource_2_0Checker.Coerce(source, nil)
 	if err != nil {
 		return nil, WrapWithDeserializationError(err, "boot resource 2.0 schema check failed")
 	}
@@ -0,0 +1,118 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"sync"
+
+	"github.com/juju/errors"
+)
+
+// DefaultBulkWorkers is the number of concurrent requests made by bulk
+// operations when BulkArgs.Workers is not set.
+const DefaultBulkWorkers = 10
+
+// BulkArgs is an argument struct for passing parameters to the
+// Controller.Bulk method.
+type BulkArgs struct {
+	// Workers is the maximum number of requests made to the controller at
+	// the same time. If zero, DefaultBulkWorkers is used.
+	Workers int
+}
+
+type bulkOperations struct {
+	controller *controller
+	workers    int
+}
+
+// Bulk implements Controller.
+func (c *controller) Bulk(args BulkArgs) BulkOperations {
+	workers := args.Workers
+	if workers <= 0 {
+		workers = DefaultBulkWorkers
+	}
+	return &bulkOperations{controller: c, workers: workers}
+}
+
+// Start implements BulkOperations.
+func (b *bulkOperations) Start(ctx context.Context, machines []Machine, args StartArgs) error {
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	return b.run(ctx, machines, func(m Machine) error {
+		return m.Start(args)
+	})
+}
+
+// Release implements BulkOperations.
+func (b *bulkOperations) Release(ctx context.Context, machines []Machine, comment string) error {
+	return b.run(ctx, machines, func(m Machine) error {
+		return b.controller.ReleaseMachines(ReleaseMachinesArgs{
+			SystemIDs: []string{m.SystemID()},
+			Comment:   comment,
+		})
+	})
+}
+
+// SetOwnerData implements BulkOperations.
+func (b *bulkOperations) SetOwnerData(ctx context.Context, machines []Machine, ownerData map[string]string) error {
+	return b.run(ctx, machines, func(m Machine) error {
+		return m.SetOwnerData(ownerData)
+	})
+}
+
+// run calls op for each of the machines using at most b.workers
+// goroutines. Once ctx is done no more calls are started, and each machine
+// that was skipped is recorded in the result with the context's error.
+func (b *bulkOperations) run(ctx context.Context, machines []Machine, op func(Machine) error) error {
+	var (
+		mu       sync.Mutex
+		failures = make(map[string]error)
+		wg       sync.WaitGroup
+	)
+	fail := func(m Machine, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		failures[m.SystemID()] = err
+	}
+
+	work := make(chan Machine)
+	workers := b.workers
+	if workers > len(machines) {
+		workers = len(machines)
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for m := range work {
+				if err := op(m); err != nil {
+					fail(m, errors.Trace(err))
+				}
+			}
+		}()
+	}
+
+	for i, m := range machines {
+		if ctx.Err() == nil {
+			select {
+			case work <- m:
+				continue
+			case <-ctx.Done():
+			}
+		}
+		for _, skipped := range machines[i:] {
+			fail(skipped, ctx.Err())
+		}
+		break
+	}
+	close(work)
+	wg.Wait()
+
+	if len(failures) == 0 {
+		return nil
+	}
+	return NewMultiError(failures)
+}
@@ -0,0 +1,215 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type bulkSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&bulkSuite{})
+
+// fakeMachine overrides the Machine methods used by the bulk operations.
+type fakeMachine struct {
+	Machine
+	systemID string
+	call     func(string) error
+}
+
+func (m *fakeMachine) SystemID() string {
+	return m.systemID
+}
+
+func (m *fakeMachine) Start(StartArgs) error {
+	return m.call("Start")
+}
+
+func (m *fakeMachine) SetOwnerData(map[string]string) error {
+	return m.call("SetOwnerData")
+}
+
+func makeFakeMachines(count int, call func(id, method string) error) []Machine {
+	machines := make([]Machine, count)
+	for i := range machines {
+		id := fmt.Sprintf("machine-%d", i)
+		machines[i] = &fakeMachine{
+			systemID: id,
+			call: func(method string) error {
+				return call(id, method)
+			},
+		}
+	}
+	return machines
+}
+
+func (s *bulkSuite) getController(c *gc.C) Controller {
+	_, controller := createTestServerController(c, s)
+	return controller
+}
+
+func (s *bulkSuite) TestStart(c *gc.C) {
+	var (
+		mu    sync.Mutex
+		calls []string
+	)
+	machines := makeFakeMachines(20, func(id, method string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, id+" "+method)
+		return nil
+	})
+
+	err := s.getController(c).Bulk(BulkArgs{}).Start(context.Background(), machines, StartArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	expected := make([]string, len(machines))
+	for i, m := range machines {
+		expected[i] = m.SystemID() + " Start"
+	}
+	c.Assert(calls, jc.SameContents, expected)
+}
+
+func (s *bulkSuite) TestSetOwnerData(c *gc.C) {
+	var (
+		mu    sync.Mutex
+		calls []string
+	)
+	machines := makeFakeMachines(3, func(id, method string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, id+" "+method)
+		return nil
+	})
+
+	err := s.getController(c).Bulk(BulkArgs{}).SetOwnerData(context.Background(), machines, map[string]string{"a": "b"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(calls, jc.SameContents, []string{
+		"machine-0 SetOwnerData",
+		"machine-1 SetOwnerData",
+		"machine-2 SetOwnerData",
+	})
+}
+
+func (s *bulkSuite) TestStartNotValid(c *gc.C) {
+	var calls []string
+	machines := makeFakeMachines(3, func(id, method string) error {
+		calls = append(calls, id+" "+method)
+		return nil
+	})
+	err := s.getController(c).Bulk(BulkArgs{}).Start(context.Background(), machines, StartArgs{Kernel: "kernel"})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(calls, gc.HasLen, 0)
+}
+
+func (s *bulkSuite) TestWorkersBoundConcurrency(c *gc.C) {
+	var (
+		mu                  sync.Mutex
+		running, maxRunning int
+	)
+	machines := makeFakeMachines(30, func(id, method string) error {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	})
+
+	err := s.getController(c).Bulk(BulkArgs{Workers: 3}).Start(context.Background(), machines, StartArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(maxRunning <= 3, jc.IsTrue)
+}
+
+func (s *bulkSuite) TestErrorsCollected(c *gc.C) {
+	machines := makeFakeMachines(4, func(id, method string) error {
+		if id == "machine-1" || id == "machine-3" {
+			return errors.Errorf("%s failed", id)
+		}
+		return nil
+	})
+
+	err := s.getController(c).Bulk(BulkArgs{}).Start(context.Background(), machines, StartArgs{})
+	c.Assert(err, jc.Satisfies, IsMultiError)
+	c.Assert(err.Error(), gc.Equals, "2 machines failed: machine-1: machine-1 failed; machine-3: machine-3 failed")
+	failures := errors.Cause(err).(*MultiError).Errors
+	c.Assert(failures, gc.HasLen, 2)
+	c.Assert(failures["machine-1"], gc.ErrorMatches, "machine-1 failed")
+}
+
+func (s *bulkSuite) TestCancelledContext(c *gc.C) {
+	called := false
+	machines := makeFakeMachines(3, func(id, method string) error {
+		called = true
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := s.getController(c).Bulk(BulkArgs{}).Start(ctx, machines, StartArgs{})
+	c.Assert(err, jc.Satisfies, IsMultiError)
+	failures := errors.Cause(err).(*MultiError).Errors
+	c.Assert(failures, gc.HasLen, 3)
+	c.Assert(failures["machine-0"], gc.Equals, context.Canceled)
+	c.Assert(called, jc.IsFalse)
+}
+
+func (s *bulkSuite) TestCancelStopsDispatch(c *gc.C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var (
+		mu    sync.Mutex
+		calls int
+	)
+	machines := makeFakeMachines(10, func(id, method string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if id == "machine-1" {
+			cancel()
+		}
+		return nil
+	})
+
+	err := s.getController(c).Bulk(BulkArgs{Workers: 1}).Start(ctx, machines, StartArgs{})
+	c.Assert(err, jc.Satisfies, IsMultiError)
+	failures := errors.Cause(err).(*MultiError).Errors
+	c.Assert(calls+len(failures), gc.Equals, 10)
+	c.Assert(calls < 10, jc.IsTrue)
+	c.Assert(failures["machine-9"], gc.Equals, context.Canceled)
+}
+
+func (s *bulkSuite) TestRelease(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse("/api/2.0/machines/?op=release", http.StatusOK, "[]")
+	server.AddPostResponse("/api/2.0/machines/?op=release", http.StatusConflict, "busy")
+	machines := makeFakeMachines(2, nil)
+
+	// A single worker, as the test server isn't safe for concurrent use.
+	err := controller.Bulk(BulkArgs{Workers: 1}).Release(context.Background(), machines, "done")
+	c.Assert(err, jc.Satisfies, IsMultiError)
+	failures := errors.Cause(err).(*MultiError).Errors
+	c.Assert(failures, gc.HasLen, 1)
+	c.Assert(failures["machine-1"], jc.Satisfies, IsCannotCompleteError)
+
+	requests := server.LastNRequests(2)
+	c.Assert(requests[0].PostForm["machines"], jc.DeepEquals, []string{"machine-0"})
+	c.Assert(requests[1].PostForm["machines"], jc.DeepEquals, []string{"machine-1"})
+	c.Assert(requests[1].PostForm.Get("comment"), gc.Equals, "done")
+}
@@ -0,0 +1,121 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"sort"
+
+	"github.com/juju/errors"
+	"github.com/juju/utils/set"
+)
+
+// MachineResources are the compute resources of a machine, or the total of
+// those of several machines.
+type MachineResources struct {
+	// MAAS only counts logical CPUs, so Machine.Resources returns the
+	// CPU count for both Cores and Threads.
+	Cores    int
+	Threads  int
+	MemoryMB int
+	// StorageGB is the total size of the physical block devices, in units
+	// of 10^9 bytes.
+	StorageGB int
+	// GPUs aren't reported with the machine, so Machine.Resources always
+	// returns zero. Callers that know better can fill it in.
+	GPUs int
+}
+
+// Add returns the sum of the resources.
+func (r MachineResources) Add(other MachineResources) MachineResources {
+	return MachineResources{
+		Cores:     r.Cores + other.Cores,
+		Threads:   r.Threads + other.Threads,
+		MemoryMB:  r.MemoryMB + other.MemoryMB,
+		StorageGB: r.StorageGB + other.StorageGB,
+		GPUs:      r.GPUs + other.GPUs,
+	}
+}
+
+// Resources implements Machine.
+func (m *machine) Resources() MachineResources {
+	var storage uint64
+	for _, device := range m.physicalBlockDevices {
+		storage += device.size
+	}
+	return MachineResources{
+		Cores:     m.cpuCount,
+		Threads:   m.cpuCount,
+		MemoryMB:  m.memory,
+		StorageGB: int(storage / 1e9),
+	}
+}
+
+// freeStatuses and allocatedStatuses are the machine status names counted
+// as free and allocated capacity by Controller.CapacitySummary.
+var (
+	freeStatuses      = set.NewStrings("Ready")
+	allocatedStatuses = set.NewStrings("Allocated", "Deploying", "Deployed")
+)
+
+// CapacityGroup is the capacity of the machines in a zone and resource pool,
+// as returned by Controller.CapacitySummary.
+type CapacityGroup struct {
+	Zone string
+	// Pool is empty for controllers older than MAAS 2.3, which don't
+	// have resource pools.
+	Pool string
+
+	FreeMachines      int
+	AllocatedMachines int
+	Free              MachineResources
+	Allocated         MachineResources
+}
+
+// CapacitySummary implements Controller.
+func (c *controller) CapacitySummary(args MachinesArgs) ([]CapacityGroup, error) {
+	machines, err := c.Machines(args)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return summarizeCapacity(machines), nil
+}
+
+func summarizeCapacity(machines []Machine) []CapacityGroup {
+	type key struct{ zone, pool string }
+	groups := make(map[key]*CapacityGroup)
+	for _, m := range machines {
+		status := m.StatusName()
+		free := freeStatuses.Contains(status)
+		if !free && !allocatedStatuses.Contains(status) {
+			continue
+		}
+		k := key{zone: zoneName(m.Zone())}
+		if pool := m.Pool(); pool != nil {
+			k.pool = pool.Name()
+		}
+		group, ok := groups[k]
+		if !ok {
+			group = &CapacityGroup{Zone: k.zone, Pool: k.pool}
+			groups[k] = group
+		}
+		if free {
+			group.FreeMachines++
+			group.Free = group.Free.Add(m.Resources())
+		} else {
+			group.AllocatedMachines++
+			group.Allocated = group.Allocated.Add(m.Resources())
+		}
+	}
+	result := make([]CapacityGroup, 0, len(groups))
+	for _, group := range groups {
+		result = append(result, *group)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Zone != result[j].Zone {
+			return result[i].Zone < result[j].Zone
+		}
+		return result[i].Pool < result[j].Pool
+	})
+	return result
+}
@@ -0,0 +1,92 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type capacitySuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&capacitySuite{})
+
+func (*capacitySuite) TestResources(c *gc.C) {
+	machines, err := readMachines(twoDotOh, parseJSON(c, machinesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machines[0].Resources(), jc.DeepEquals, MachineResources{
+		Cores:     1,
+		Threads:   1,
+		MemoryMB:  1024,
+		StorageGB: 17,
+	})
+}
+
+func (*capacitySuite) TestHardwareInfo(c *gc.C) {
+	source := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"hardware_info": map[string]interface{}{
+			"cpu_model":     "Intel(R) Xeon(R) CPU E5-2680 v4",
+			"system_vendor": "Unknown",
+		},
+	})
+	machines, err := readMachines(twoDotOh, parseJSON(c, "["+source+"]"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machines[0].HardwareInfo(), jc.DeepEquals, map[string]string{
+		"cpu_model":     "Intel(R) Xeon(R) CPU E5-2680 v4",
+		"system_vendor": "Unknown",
+	})
+
+	machines, err = readMachines(twoDotOh, parseJSON(c, "["+machineResponse+"]"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machines[0].HardwareInfo(), gc.HasLen, 0)
+}
+
+func (*capacitySuite) TestResourcesAdd(c *gc.C) {
+	a := MachineResources{Cores: 1, Threads: 2, MemoryMB: 3, StorageGB: 4, GPUs: 5}
+	c.Check(a.Add(a), jc.DeepEquals, MachineResources{Cores: 2, Threads: 4, MemoryMB: 6, StorageGB: 8, GPUs: 10})
+}
+
+func (s *capacitySuite) TestCapacitySummary(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, machinesResponse)
+
+	groups, err := controller.CapacitySummary(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(groups, jc.DeepEquals, []CapacityGroup{{
+		Zone:              "default",
+		FreeMachines:      2,
+		AllocatedMachines: 1,
+		Free:              MachineResources{Cores: 2, Threads: 2, MemoryMB: 2048, StorageGB: 16},
+		Allocated:         MachineResources{Cores: 1, Threads: 1, MemoryMB: 1024, StorageGB: 17},
+	}})
+}
+
+func (*capacitySuite) TestSummarizeCapacityGroups(c *gc.C) {
+	machines := []Machine{
+		&machine{statusName: "Ready", zone: &zone{name: "b"}, memory: 1},
+		&machine{statusName: "Deployed", zone: &zone{name: "a"}, pool: &pool{name: "gpu"}, memory: 2},
+		&machine{statusName: "Broken", zone: &zone{name: "a"}, memory: 4},
+		&machine{statusName: "Ready", zone: &zone{name: "a"}, memory: 8},
+	}
+	groups := summarizeCapacity(machines)
+	c.Check(groups, jc.DeepEquals, []CapacityGroup{{
+		Zone:         "a",
+		FreeMachines: 1,
+		Free:         MachineResources{MemoryMB: 8},
+	}, {
+		Zone:              "a",
+		Pool:              "gpu",
+		AllocatedMachines: 1,
+		Allocated:         MachineResources{MemoryMB: 2},
+	}, {
+		Zone:         "b",
+		FreeMachines: 1,
+		Free:         MachineResources{MemoryMB: 1},
+	}})
+}
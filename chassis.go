@@ -0,0 +1,104 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"github.com/juju/errors"
+	"github.com/juju/utils/set"
+)
+
+const (
+	// ChassisType* values are the kinds of chassis or virtualization host
+	// that machines can be enlisted from with Controller.AddChassis.
+	ChassisTypeHMCZ     = "hmcz"
+	ChassisTypeLXD      = "lxd"
+	ChassisTypeMSCM     = "mscm"
+	ChassisTypeMSFTOCS  = "msftocs"
+	ChassisTypePowerKVM = "powerkvm"
+	ChassisTypeProxmox  = "proxmox"
+	ChassisTypeRECSBox  = "recs_box"
+	ChassisTypeSM15K    = "sm15k"
+	ChassisTypeUCSM     = "ucsm"
+	ChassisTypeVirsh    = "virsh"
+	ChassisTypeVMware   = "vmware"
+)
+
+var chassisTypes = set.NewStrings(
+	ChassisTypeHMCZ,
+	ChassisTypeLXD,
+	ChassisTypeMSCM,
+	ChassisTypeMSFTOCS,
+	ChassisTypePowerKVM,
+	ChassisTypeProxmox,
+	ChassisTypeRECSBox,
+	ChassisTypeSM15K,
+	ChassisTypeUCSM,
+	ChassisTypeVirsh,
+	ChassisTypeVMware,
+)
+
+// AddChassisArgs is an argument struct for passing parameters to the
+// Controller.AddChassis method.
+type AddChassisArgs struct {
+	// ChassisType is required, and is one of the ChassisType constants.
+	ChassisType string
+	// Hostname is required. It is the address of the chassis, or for
+	// virsh the URL of the libvirt host, such as
+	// "qemu+ssh://ubuntu@10.0.0.2/system".
+	Hostname string
+	// Username and Password are the credentials of the chassis, which
+	// most of the chassis types require.
+	Username string
+	Password string
+	// Domain is optional, and is the DNS domain of the machines that are
+	// enlisted.
+	Domain string
+	// PrefixFilter is optional. If set, only the machines whose names
+	// start with it are enlisted.
+	PrefixFilter string
+	// AcceptAll commissions the machines as they are enlisted, rather than
+	// leaving them New.
+	AcceptAll bool
+}
+
+// Validate checks that the chassis type is known and the hostname is set.
+func (a *AddChassisArgs) Validate() error {
+	if a.ChassisType == "" {
+		return errors.NotValidf("missing ChassisType")
+	}
+	if !chassisTypes.Contains(a.ChassisType) {
+		return errors.NotValidf("ChassisType %q", a.ChassisType)
+	}
+	if a.Hostname == "" {
+		return errors.NotValidf("missing Hostname")
+	}
+	return nil
+}
+
+// AddChassis implements Controller.
+//
+// Returns
+//  - NotValid error if the args aren't valid
+//  - BadRequestError if MAAS refuses the args
+//  - PermissionError if the user isn't an admin
+//  - NoMatchError if the domain doesn't exist
+func (c *controller) AddChassis(args AddChassisArgs) (string, error) {
+	if err := args.Validate(); err != nil {
+		return "", errors.Trace(err)
+	}
+	params := NewURLParams()
+	params.Values.Add("chassis_type", args.ChassisType)
+	params.Values.Add("hostname", args.Hostname)
+	params.MaybeAdd("username", args.Username)
+	params.MaybeAdd("password", args.Password)
+	params.MaybeAdd("domain", args.Domain)
+	params.MaybeAdd("prefix_filter", args.PrefixFilter)
+	params.MaybeAddBool("accept_all", args.AcceptAll)
+	result, err := c.post("machines", "add_chassis", params.Values)
+	if err != nil {
+		return "", translateError(opEntity, err)
+	}
+	message, _ := result.(string)
+	return message, nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+const addChassisPath = "/api/2.0/machines/?op=add_chassis"
+
+func (s *controllerSuite) TestAddChassisArgsValidate(c *gc.C) {
+	for i, test := range []struct {
+		args    AddChassisArgs
+		message string
+	}{{
+		args:    AddChassisArgs{Hostname: "10.0.0.2"},
+		message: "missing ChassisType not valid",
+	}, {
+		args:    AddChassisArgs{ChassisType: "blade", Hostname: "10.0.0.2"},
+		message: `ChassisType "blade" not valid`,
+	}, {
+		args:    AddChassisArgs{ChassisType: ChassisTypeVirsh},
+		message: "missing Hostname not valid",
+	}} {
+		c.Logf("test %d", i)
+		err := test.args.Validate()
+		c.Check(err, jc.Satisfies, errors.IsNotValid)
+		c.Check(err, gc.ErrorMatches, test.message)
+	}
+}
+
+func (s *controllerSuite) TestAddChassis(c *gc.C) {
+	s.server.AddPostResponse(addChassisPath, http.StatusOK, `"Asking maas-rack to add machines from chassis qemu+ssh://ubuntu@10.0.0.2/system"`)
+	controller := s.getController(c)
+
+	message, err := controller.AddChassis(AddChassisArgs{
+		ChassisType:  ChassisTypeVirsh,
+		Hostname:     "qemu+ssh://ubuntu@10.0.0.2/system",
+		Password:     "sekrit",
+		Domain:       "lab",
+		PrefixFilter: "juju-",
+		AcceptAll:    true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(message, gc.Equals, "Asking maas-rack to add machines from chassis qemu+ssh://ubuntu@10.0.0.2/system")
+
+	form := s.server.LastRequest().PostForm
+	c.Check(form.Get("chassis_type"), gc.Equals, "virsh")
+	c.Check(form.Get("hostname"), gc.Equals, "qemu+ssh://ubuntu@10.0.0.2/system")
+	c.Check(form.Get("password"), gc.Equals, "sekrit")
+	c.Check(form.Get("domain"), gc.Equals, "lab")
+	c.Check(form.Get("prefix_filter"), gc.Equals, "juju-")
+	c.Check(form.Get("accept_all"), gc.Equals, "true")
+	_, found := form["username"]
+	c.Check(found, jc.IsFalse)
+}
+
+func (s *controllerSuite) TestAddChassisErrors(c *gc.C) {
+	controller := s.getController(c)
+	args := AddChassisArgs{ChassisType: ChassisTypeVMware, Hostname: "vcenter", Username: "admin"}
+	for i, test := range []struct {
+		status    int
+		satisfies func(error) bool
+	}{
+		{http.StatusBadRequest, IsBadRequestError},
+		{http.StatusForbidden, IsPermissionError},
+		{http.StatusNotFound, IsNoMatchError},
+		{http.StatusInternalServerError, IsUnexpectedError},
+	} {
+		c.Logf("test %d: %d", i, test.status)
+		s.server.AddPostResponse(addChassisPath, test.status, "no")
+		_, err := controller.AddChassis(args)
+		c.Check(err, jc.Satisfies, test.satisfies)
+	}
+}
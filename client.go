@@ -4,7 +4,14 @@
 package gomaasapi
 
 import (
+	"bufio"
 	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -33,6 +40,64 @@ const (
 type Client struct {
 	APIURL *url.URL
 	Signer OAuthSigner
+
+	// RequestIDHeader and RequestID are optional. If both are set, each
+	// request is sent with the request ID in the header.
+	RequestIDHeader string
+	RequestID       string
+
+	// UserAgent is optional. If set, each request is sent with it in the
+	// User-Agent header, unless the request already has one, so that the
+	// MAAS controller's logs and any proxies can attribute the traffic.
+	// Otherwise Go's default User-Agent is sent.
+	UserAgent string
+
+	// DisableCompression is optional. By default each request asks for a
+	// gzip or deflate compressed response, which is transparently
+	// decompressed. If set, responses are requested uncompressed.
+	DisableCompression bool
+
+	// RetryUnsafeRequests is optional. Requests are retried when the server
+	// responds 503 with a Retry-After header, but by default only if they
+	// are idempotent, as the server may have acted on a POST request before
+	// failing, for example by allocating a machine. If set, POST requests
+	// are retried too.
+	RetryUnsafeRequests bool
+
+	// IdempotencyKeyHeader is optional. If set, each POST request is sent
+	// with a random key in this header, the same for every attempt, and is
+	// retried like an idempotent request. MAAS itself ignores the key, so
+	// this is only useful behind a proxy that deduplicates requests by it.
+	IdempotencyKeyHeader string
+
+	// Context is optional. If set, requests are sent with it, so that they
+	// are abandoned when it is done.
+	Context context.Context
+
+	// HTTPClient is optional. If set, requests are sent with it, for
+	// example to trust the CA that signed the certificate of a MAAS
+	// controller. It is shared by the copies of the client, so that they
+	// reuse its transport. Otherwise a default http.Client is used.
+	HTTPClient *http.Client
+
+	// endpoints is nil unless the controller has more than one endpoint,
+	// see ControllerArgs.FailoverURLs. It is shared by the copies of the
+	// client, so that they all know which endpoints are down.
+	endpoints *endpoints
+}
+
+// WithUserAgent returns a copy of the client that sends the user agent,
+// for overriding it for some requests.
+func (client Client) WithUserAgent(userAgent string) Client {
+	client.UserAgent = userAgent
+	return client
+}
+
+// WithRetryUnsafeRequests returns a copy of the client that does or doesn't
+// retry POST requests, for overriding RetryUnsafeRequests for some requests.
+func (client Client) WithRetryUnsafeRequests(retry bool) Client {
+	client.RetryUnsafeRequests = retry
+	return client
 }
 
 // ServerError is an http error (or at least, a non-2xx result) received from
@@ -64,24 +129,132 @@ func readAndClose(stream io.ReadCloser) ([]byte, error) {
 	return ioutil.ReadAll(stream)
 }
 
+// maxPreallocatedResponseBody is the most that is allocated up front for
+// a response body from its Content-Length, so that a bogus length can't
+// make the client allocate more than the body it is actually sent.
+const maxPreallocatedResponseBody = 32 << 20
+
+// readResponseBody reads and closes the body of the response, decompressing
+// it according to its Content-Encoding.
+func readResponseBody(response *http.Response) ([]byte, error) {
+	reader, err := decodedResponseBody(response)
+	if err != nil {
+		response.Body.Close()
+		return nil, err
+	}
+	defer reader.Close()
+	var buffer bytes.Buffer
+	if response.ContentLength > 0 && reader == response.Body {
+		size := response.ContentLength
+		if size > maxPreallocatedResponseBody {
+			size = maxPreallocatedResponseBody
+		}
+		buffer.Grow(int(size))
+	}
+	if _, err := buffer.ReadFrom(reader); err != nil {
+		return nil, errors.Annotate(err, "reading response")
+	}
+	return buffer.Bytes(), nil
+}
+
+// decodedResponseBody returns a reader for the body of the response that
+// decompresses it according to its Content-Encoding. Closing the reader
+// closes the body.
+func decodedResponseBody(response *http.Response) (io.ReadCloser, error) {
+	var decoder io.ReadCloser
+	switch strings.ToLower(response.Header.Get("Content-Encoding")) {
+	case "", "identity":
+		return response.Body, nil
+	case "gzip":
+		gzipReader, err := gzip.NewReader(response.Body)
+		if err != nil {
+			return nil, errors.Annotate(err, "decompressing gzip response")
+		}
+		decoder = gzipReader
+	case "deflate":
+		// Deflate responses should be zlib wrapped, but some servers send
+		// raw deflate data, so both are accepted.
+		buffered := bufio.NewReader(response.Body)
+		zlibReader, err := newZlibOrFlateReader(buffered)
+		if err != nil {
+			return nil, errors.Annotate(err, "decompressing deflate response")
+		}
+		decoder = zlibReader
+	default:
+		return nil, errors.Errorf("unsupported response Content-Encoding %q", response.Header.Get("Content-Encoding"))
+	}
+	return decodingReader{decoder, response.Body}, nil
+}
+
+// decodingReader reads from the decoder, and closes both the decoder and
+// the body it decodes.
+type decodingReader struct {
+	io.ReadCloser
+	body io.Closer
+}
+
+func (r decodingReader) Close() error {
+	r.ReadCloser.Close()
+	return r.body.Close()
+}
+
+// newZlibOrFlateReader returns a reader for zlib wrapped deflate data, or for
+// raw deflate data if the zlib header is missing.
+func newZlibOrFlateReader(reader *bufio.Reader) (io.ReadCloser, error) {
+	header, err := reader.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	// A zlib header uses the deflate method, and is a multiple of 31
+	// when read as a big endian number.
+	if len(header) == 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(reader)
+	}
+	return flate.NewReader(reader), nil
+}
+
 // dispatchRequest sends a request to the server, and interprets the response.
 // Client-side errors will return an empty response and a non-nil error.  For
 // server-side errors however (i.e. responses with a non 2XX status code), the
 // returned error will be ServerError and the returned body will reflect the
 // server's response.  If the server returns a 503 response with a 'Retry-after'
-// header, the request will be transparenty retried.
+// header, the request will be transparenty retried, if it is idempotent or the
+// client allows it, see Client.RetryUnsafeRequests.
 func (client Client) dispatchRequest(request *http.Request) ([]byte, error) {
+	body, _, err := client.dispatchRequestWithStatus(request)
+	return body, err
+}
+
+// dispatchRequestWithStatus is like dispatchRequest, but also returns the
+// status code of the response. The status code is zero if no response was
+// received.
+func (client Client) dispatchRequestWithStatus(request *http.Request) ([]byte, int, error) {
+	body, response, err := client.dispatchRequestWithResponse(request)
+	if response == nil {
+		return body, 0, err
+	}
+	return body, response.StatusCode, err
+}
+
+// dispatchRequestWithResponse is like dispatchRequest, but also returns the
+// response, whose body has already been read, for its status code and
+// headers. The response is nil if none was received.
+func (client Client) dispatchRequestWithResponse(request *http.Request) ([]byte, *http.Response, error) {
 	// First, store the request's body into a byte[] to be able to restore it
 	// after each request.
 	bodyContent, err := readAndClose(request.Body)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	if !client.retryable(request) {
+		request.Body = ioutil.NopCloser(bytes.NewReader(bodyContent))
+		return client.dispatchAnsweringChallenge(request, bodyContent)
 	}
 	for retry := 0; retry < NumberOfRetries; retry++ {
 		// Restore body before issuing request.
 		newBody := ioutil.NopCloser(bytes.NewReader(bodyContent))
 		request.Body = newBody
-		body, err := client.dispatchSingleRequest(request)
+		body, response, err := client.dispatchAnsweringChallenge(request, bodyContent)
 		// If this is a 503 response with a non-void "Retry-After" header: wait
 		// as instructed and retry the request.
 		if err != nil {
@@ -96,34 +269,179 @@ func (client Client) dispatchRequest(request *http.Request) ([]byte, error) {
 				}
 			}
 		}
-		return body, err
+		return body, response, err
 	}
 	// Restore body before issuing request.
 	newBody := ioutil.NopCloser(bytes.NewReader(bodyContent))
 	request.Body = newBody
+	return client.dispatchAnsweringChallenge(request, bodyContent)
+}
+
+// retryable reports whether the request may be sent again when the server
+// asks for it to be retried, adding an idempotency key to it if the client
+// is configured to send them.
+func (client Client) retryable(request *http.Request) bool {
+	switch request.Method {
+	case "GET", "HEAD", "OPTIONS", "PUT", "DELETE":
+		return true
+	}
+	if client.IdempotencyKeyHeader != "" {
+		if request.Header.Get(client.IdempotencyKeyHeader) == "" {
+			key, err := newIdempotencyKey()
+			if err != nil {
+				return client.RetryUnsafeRequests
+			}
+			request.Header.Set(client.IdempotencyKeyHeader, key)
+		}
+		return true
+	}
+	return client.RetryUnsafeRequests
+}
+
+// newIdempotencyKey returns a random key for IdempotencyKeyHeader.
+func newIdempotencyKey() (string, error) {
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return "", errors.Trace(err)
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// dispatchAnsweringChallenge sends the request, and if the server challenges
+// the credentials and the Signer can answer the challenge, sends the request
+// once more with the new credentials.
+func (client Client) dispatchAnsweringChallenge(request *http.Request, bodyContent []byte) ([]byte, *http.Response, error) {
+	body, response, err := client.dispatchSingleRequest(request)
+	answerer, ok := client.Signer.(challengeAnswerer)
+	if !ok || err == nil {
+		return body, response, err
+	}
+	svrErr, ok := errors.Cause(err).(ServerError)
+	if !ok {
+		return body, response, err
+	}
+	answered, answerErr := answerer.answerChallenge(request.Context(), svrErr)
+	if !answered {
+		return body, response, err
+	}
+	if answerErr != nil {
+		return body, response, errors.Trace(answerErr)
+	}
+	request.Body = ioutil.NopCloser(bytes.NewReader(bodyContent))
 	return client.dispatchSingleRequest(request)
 }
 
-func (client Client) dispatchSingleRequest(request *http.Request) ([]byte, error) {
+func (client Client) dispatchSingleRequest(request *http.Request) ([]byte, *http.Response, error) {
+	response, err := client.sendRequest(request)
+	if err != nil {
+		return nil, nil, err
+	}
+	body, err := readResponseBody(response)
+	if err != nil {
+		return nil, response, err
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return body, response, newServerError(response, body)
+	}
+	return body, response, nil
+}
+
+// newServerError returns the ServerError for the non-2xx response, with the
+// body that was read from it.
+func newServerError(response *http.Response, body []byte) error {
+	err := errors.Errorf("ServerError: %v (%s)", response.Status, body)
+	return errors.Trace(ServerError{error: err, StatusCode: response.StatusCode, Header: response.Header, BodyMessage: string(body)})
+}
+
+// sendRequest adds the client's headers to the request, signs it and sends
+// it. The body of the response is left for the caller to read and close.
+func (client Client) sendRequest(request *http.Request) (*http.Response, error) {
+	if client.Context != nil {
+		request = request.WithContext(client.Context)
+	}
+	if client.RequestIDHeader != "" && client.RequestID != "" {
+		request.Header.Set(client.RequestIDHeader, client.RequestID)
+	}
+	if client.UserAgent != "" && request.Header.Get("User-Agent") == "" {
+		request.Header.Set("User-Agent", client.UserAgent)
+	}
+	if client.DisableCompression {
+		request.Header.Set("Accept-Encoding", "identity")
+	} else {
+		request.Header.Set("Accept-Encoding", "gzip, deflate")
+	}
+	if client.endpoints != nil {
+		return client.endpoints.send(request, client.signAndDo)
+	}
+	return client.signAndDo(request)
+}
+
+// signAndDo signs the request and sends it.
+func (client Client) signAndDo(request *http.Request) (*http.Response, error) {
 	client.Signer.OAuthSign(request)
-	httpClient := http.Client{}
+	httpClient := client.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
 	// See https://code.google.com/p/go/issues/detail?id=4677
 	// We need to force the connection to close each time so that we don't
 	// hit the above Go bug.
 	request.Close = true
-	response, err := httpClient.Do(request)
-	if err != nil {
-		return nil, err
-	}
-	body, err := readAndClose(response.Body)
-	if err != nil {
+	return httpClient.Do(request)
+}
+
+// dispatchStreamingRequest sends a GET request like dispatchRequest, but
+// rather than reading the body of a successful response into memory, it
+// copies the decompressed body to the writer, and returns the headers of the
+// response. Requests are retried and challenges answered as they are by
+// dispatchRequest, which is possible as nothing is written until the
+// response is successful. Each attempt sends a fresh copy of the request,
+// so that the headers set while sending one don't carry over to the next.
+func (client Client) dispatchStreamingRequest(request *http.Request, w io.Writer) (http.Header, error) {
+	answerer, canAnswer := client.Signer.(challengeAnswerer)
+	for retry := 0; ; retry++ {
+		response, err := client.sendRequest(request.Clone(request.Context()))
+		if err != nil {
+			return nil, err
+		}
+		if response.StatusCode >= 200 && response.StatusCode <= 299 {
+			reader, err := decodedResponseBody(response)
+			if err != nil {
+				response.Body.Close()
+				return nil, err
+			}
+			defer reader.Close()
+			if _, err := io.Copy(w, reader); err != nil {
+				return nil, errors.Annotate(err, "copying response")
+			}
+			return response.Header, nil
+		}
+		body, err := readResponseBody(response)
+		if err != nil {
+			return nil, err
+		}
+		err = newServerError(response, body)
+		svrErr := errors.Cause(err).(ServerError)
+		if svrErr.StatusCode == http.StatusServiceUnavailable && retry < NumberOfRetries {
+			retryAfter, errConv := strconv.Atoi(svrErr.Header.Get(RetryAfterHeaderName))
+			if errConv == nil {
+				time.Sleep(time.Duration(retryAfter) * time.Second)
+				continue
+			}
+		}
+		if canAnswer {
+			// Only the first challenge is answered.
+			canAnswer = false
+			answered, answerErr := answerer.answerChallenge(request.Context(), svrErr)
+			if answered && answerErr != nil {
+				return nil, errors.Trace(answerErr)
+			}
+			if answered {
+				continue
+			}
+		}
 		return nil, err
 	}
-	if response.StatusCode < 200 || response.StatusCode > 299 {
-		err := errors.Errorf("ServerError: %v (%s)", response.Status, body)
-		return body, errors.Trace(ServerError{error: err, StatusCode: response.StatusCode, Header: response.Header, BodyMessage: string(body)})
-	}
-	return body, nil
 }
 
 // GetURL returns the URL to a given resource on the API, based on its URI.
@@ -137,6 +455,15 @@ func (client Client) GetURL(uri *url.URL) *url.URL {
 // invocation (if you pass its name in "operation") or plain resource
 // retrieval (if you leave "operation" blank).
 func (client Client) Get(uri *url.URL, operation string, parameters url.Values) ([]byte, error) {
+	request, err := client.newGetRequest(uri, operation, parameters)
+	if err != nil {
+		return nil, err
+	}
+	return client.dispatchRequest(request)
+}
+
+// newGetRequest makes the request for Get.
+func (client Client) newGetRequest(uri *url.URL, operation string, parameters url.Values) (*http.Request, error) {
 	if parameters == nil {
 		parameters = make(url.Values)
 	}
@@ -150,11 +477,21 @@ func (client Client) Get(uri *url.URL, operation string, parameters url.Values)
 	}
 	queryUrl := client.GetURL(uri)
 	queryUrl.RawQuery = parameters.Encode()
-	request, err := http.NewRequest("GET", queryUrl.String(), nil)
+	return http.NewRequest("GET", queryUrl.String(), nil)
+}
+
+// GetStream performs an HTTP "GET" to the API like Get, but copies the body
+// of the response to the writer as it is received, rather than reading it
+// into memory, and returns the headers of the response. It is intended for
+// downloading large files. If MAAS returns an error response, nothing is
+// written, but if the response fails part way through, the writer is left
+// with the part of the body that was received before the error.
+func (client Client) GetStream(uri *url.URL, operation string, parameters url.Values, w io.Writer) (http.Header, error) {
+	request, err := client.newGetRequest(uri, operation, parameters)
 	if err != nil {
 		return nil, err
 	}
-	return client.dispatchRequest(request)
+	return client.dispatchStreamingRequest(request, w)
 }
 
 // writeMultiPartFiles writes the given files as parts of a multipart message
@@ -256,6 +593,46 @@ func (client Client) Delete(uri *url.URL) error {
 	return nil
 }
 
+// Raw performs an HTTP request using the given method to the API, and returns
+// the body and status code of the response. It is intended for calling API
+// methods that have no other support in this package.
+//
+// The operation, if given, is added to the query. The parameters are sent
+// in the query for GET and DELETE requests, and when a body is given.
+// Otherwise the parameters are sent as a form encoded body.
+func (client Client) Raw(method string, uri *url.URL, operation string, parameters url.Values, body io.Reader) ([]byte, int, error) {
+	if parameters.Get("op") != "" {
+		return nil, 0, errors.Errorf("reserved parameter 'op' passed (with value '%s')", parameters.Get("op"))
+	}
+	query := make(url.Values)
+	if operation != "" {
+		query.Set("op", operation)
+	}
+	var contentType string
+	switch {
+	case body != nil:
+		contentType = "application/octet-stream"
+		fallthrough
+	case method == "GET" || method == "DELETE":
+		for key, values := range parameters {
+			query[key] = values
+		}
+	default:
+		contentType = "application/x-www-form-urlencoded"
+		body = strings.NewReader(parameters.Encode())
+	}
+	requestURL := client.GetURL(uri)
+	requestURL.RawQuery = query.Encode()
+	request, err := http.NewRequest(method, requestURL.String(), body)
+	if err != nil {
+		return nil, 0, err
+	}
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	return client.dispatchRequestWithStatus(request)
+}
+
 // Anonymous "signature method" implementation.
 type anonSigner struct{}
 
@@ -310,6 +687,12 @@ func NewAnonymousClient(BaseURL string, apiVersion string) (*Client, error) {
 // the MAAS server, e.g.:
 // http://my.maas.server.example.com/MAAS/api/2.0/
 func NewAuthenticatedClient(versionedURL, apiKey string) (*Client, error) {
+	return NewAuthenticatedClientWithSignatureMethod(versionedURL, apiKey, PlainTextSignatureMethod)
+}
+
+// NewAuthenticatedClientWithSignatureMethod is like NewAuthenticatedClient,
+// but the Client it creates signs requests using the specified method.
+func NewAuthenticatedClientWithSignatureMethod(versionedURL, apiKey string, method OAuthSignatureMethod) (*Client, error) {
 	elements := strings.Split(apiKey, ":")
 	if len(elements) != 3 {
 		errString := fmt.Sprintf("invalid API key %q; expected \"<consumer secret>:<token key>:<token secret>\"", apiKey)
@@ -322,7 +705,7 @@ func NewAuthenticatedClient(versionedURL, apiKey string) (*Client, error) {
 		TokenKey:       elements[1],
 		TokenSecret:    elements[2],
 	}
-	signer, err := NewPlainTestOAuthSigner(token, "MAAS API")
+	signer, err := NewOAuthSigner(method, token, "MAAS API")
 	if err != nil {
 		return nil, err
 	}
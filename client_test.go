@@ -5,12 +5,18 @@ package gomaasapi
 
 import (
 	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"strings"
 
+	"github.com/juju/errors"
 	jc "github.com/juju/testing/checkers"
 	gc "gopkg.in/check.v1"
 )
@@ -35,6 +41,19 @@ func (*ClientSuite) TestReadAndCloseReturnsContents(c *gc.C) {
 	c.Check(string(data), gc.Equals, content)
 }
 
+func (*ClientSuite) TestReadResponseBodyIgnoresHugeContentLength(c *gc.C) {
+	response := &http.Response{
+		Header:        make(http.Header),
+		ContentLength: 1 << 50,
+		Body:          ioutil.NopCloser(strings.NewReader("short")),
+	}
+
+	body, err := readResponseBody(response)
+
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(body), gc.Equals, "short")
+}
+
 func (suite *ClientSuite) TestClientdispatchRequestReturnsServerError(c *gc.C) {
 	URI := "/some/url/?param1=test"
 	expectedResult := "expected:result"
@@ -75,6 +94,24 @@ func (suite *ClientSuite) TestClientdispatchRequestRetries503(c *gc.C) {
 	c.Check(*server.requests, jc.DeepEquals, expectedRequestsContent)
 }
 
+func (suite *ClientSuite) TestClientdispatchRequestRetrySignsOnce(c *gc.C) {
+	URI := "/some/url/?param1=test"
+	server := newFlakyServer(URI, 503, 1)
+	defer server.Close()
+	client, err := NewAuthenticatedClientWithSignatureMethod(server.URL+"/api/1.0/", "a:b:c", HMACSHA1SignatureMethod)
+	c.Assert(err, jc.ErrorIsNil)
+	request, err := http.NewRequest("GET", server.URL+URI, nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, err = client.dispatchRequest(request)
+
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(*server.nbRequests, gc.Equals, 2)
+	for _, header := range *server.headers {
+		c.Check(header["Authorization"], gc.HasLen, 1)
+	}
+}
+
 func (suite *ClientSuite) TestClientdispatchRequestDoesntRetry200(c *gc.C) {
 	URI := "/some/url/?param1=test"
 	server := newFlakyServer(URI, 200, 10)
@@ -107,6 +144,74 @@ func (suite *ClientSuite) TestClientdispatchRequestRetriesIsLimited(c *gc.C) {
 	c.Assert(svrError.StatusCode, gc.Equals, 503)
 }
 
+func (suite *ClientSuite) TestClientdispatchRequestDoesntRetryPOST(c *gc.C) {
+	URI := "/some/url/?op=allocate"
+	server := newFlakyServer(URI, 503, 1)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	request, err := http.NewRequest("POST", server.URL+URI, strings.NewReader("zone=a"))
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, err = client.dispatchRequest(request)
+
+	c.Check(*server.nbRequests, gc.Equals, 1)
+	svrError, ok := GetServerError(err)
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(svrError.StatusCode, gc.Equals, 503)
+}
+
+func (suite *ClientSuite) TestClientdispatchRequestRetriesUnsafeRequests(c *gc.C) {
+	URI := "/some/url/?op=allocate"
+	server := newFlakyServer(URI, 503, 1)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	request, err := http.NewRequest("POST", server.URL+URI, strings.NewReader("zone=a"))
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, err = client.WithRetryUnsafeRequests(true).dispatchRequest(request)
+
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(*server.nbRequests, gc.Equals, 2)
+	c.Check(*server.requests, jc.DeepEquals, [][]byte{[]byte("zone=a"), []byte("zone=a")})
+}
+
+func (suite *ClientSuite) TestClientdispatchRequestRetriesWithIdempotencyKey(c *gc.C) {
+	URI := "/some/url/?op=allocate"
+	server := newFlakyServer(URI, 503, 1)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	client.IdempotencyKeyHeader = "Idempotency-Key"
+	request, err := http.NewRequest("POST", server.URL+URI, strings.NewReader("zone=a"))
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, err = client.dispatchRequest(request)
+
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(*server.nbRequests, gc.Equals, 2)
+	headers := *server.headers
+	key := headers[0].Get("Idempotency-Key")
+	c.Check(key, gc.Matches, "[0-9a-f]{32}")
+	c.Check(headers[1].Get("Idempotency-Key"), gc.Equals, key)
+}
+
+func (suite *ClientSuite) TestClientdispatchRequestRetriesPUT(c *gc.C) {
+	URI := "/some/url/"
+	server := newFlakyServer(URI, 503, 1)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	request, err := http.NewRequest("PUT", server.URL+URI, strings.NewReader("hostname=a"))
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, err = client.dispatchRequest(request)
+
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(*server.nbRequests, gc.Equals, 2)
+}
+
 func (suite *ClientSuite) TestClientDispatchRequestReturnsNonServerError(c *gc.C) {
 	client, err := NewAnonymousClient("/foo", "1.0")
 	c.Assert(err, jc.ErrorIsNil)
@@ -140,6 +245,215 @@ func (suite *ClientSuite) TestClientdispatchRequestSignsRequest(c *gc.C) {
 	c.Check((*server.requestHeader)["Authorization"][0], gc.Matches, "^OAuth .*")
 }
 
+func (suite *ClientSuite) TestClientdispatchRequestSendsRequestID(c *gc.C) {
+	URI := "/some/url/"
+	server := newSingleServingServer(URI, "expected:result", http.StatusOK)
+	defer server.Close()
+	client, err := NewAuthenticatedClient(server.URL, "the:api:key")
+	c.Assert(err, jc.ErrorIsNil)
+	client.RequestIDHeader = "X-Request-Id"
+	client.RequestID = "abc-1"
+	request, err := http.NewRequest("GET", server.URL+URI, nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, err = client.dispatchRequest(request)
+
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(server.requestHeader.Get("X-Request-Id"), gc.Equals, "abc-1")
+}
+
+func (suite *ClientSuite) TestClientdispatchRequestSendsUserAgent(c *gc.C) {
+	URI := "/some/url/"
+	server := newSingleServingServer(URI, "expected:result", http.StatusOK)
+	defer server.Close()
+	client, err := NewAuthenticatedClient(server.URL, "the:api:key")
+	c.Assert(err, jc.ErrorIsNil)
+	client.UserAgent = "my-tool/1.2"
+	request, err := http.NewRequest("GET", server.URL+URI, nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, err = client.dispatchRequest(request)
+
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(server.requestHeader.Get("User-Agent"), gc.Equals, "my-tool/1.2")
+}
+
+func (suite *ClientSuite) TestClientdispatchRequestKeepsRequestUserAgent(c *gc.C) {
+	URI := "/some/url/"
+	server := newSingleServingServer(URI, "expected:result", http.StatusOK)
+	defer server.Close()
+	client, err := NewAuthenticatedClient(server.URL, "the:api:key")
+	c.Assert(err, jc.ErrorIsNil)
+	client.UserAgent = "my-tool/1.2"
+	request, err := http.NewRequest("GET", server.URL+URI, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	request.Header.Set("User-Agent", "other-tool/3.4")
+
+	_, err = client.dispatchRequest(request)
+
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(server.requestHeader.Get("User-Agent"), gc.Equals, "other-tool/3.4")
+}
+
+func (suite *ClientSuite) TestClientWithUserAgent(c *gc.C) {
+	URI := "/some/url/"
+	server := newSingleServingServer(URI, "expected:result", http.StatusOK)
+	defer server.Close()
+	client, err := NewAuthenticatedClient(server.URL, "the:api:key")
+	c.Assert(err, jc.ErrorIsNil)
+	client.UserAgent = "my-tool/1.2"
+	uri, err := url.Parse(URI)
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, err = client.WithUserAgent("my-tool/1.2 (sync)").Get(uri, "", nil)
+
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(server.requestHeader.Get("User-Agent"), gc.Equals, "my-tool/1.2 (sync)")
+	c.Check(client.UserAgent, gc.Equals, "my-tool/1.2")
+}
+
+// newCompressingServer creates a test http server which returns the content
+// compressed by the writer made by compress, with the encoding in the
+// Content-Encoding header. The Accept-Encoding header of the last request is
+// stored in acceptEncoding.
+func newCompressingServer(content, encoding string, compress func(io.Writer) io.WriteCloser, acceptEncoding *string) *httptest.Server {
+	handler := func(writer http.ResponseWriter, request *http.Request) {
+		*acceptEncoding = request.Header.Get("Accept-Encoding")
+		writer.Header().Set("Content-Encoding", encoding)
+		compressor := compress(writer)
+		fmt.Fprint(compressor, content)
+		compressor.Close()
+	}
+	return httptest.NewServer(http.HandlerFunc(handler))
+}
+
+func (suite *ClientSuite) checkDecompresses(c *gc.C, encoding string, compress func(io.Writer) io.WriteCloser) {
+	var acceptEncoding string
+	server := newCompressingServer(`{"some": "json"}`, encoding, compress, &acceptEncoding)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	request, err := http.NewRequest("GET", server.URL+"/some/url/", nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	result, err := client.dispatchRequest(request)
+
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(result), gc.Equals, `{"some": "json"}`)
+	c.Check(acceptEncoding, gc.Equals, "gzip, deflate")
+}
+
+func (suite *ClientSuite) TestClientdispatchRequestDecompressesGzip(c *gc.C) {
+	suite.checkDecompresses(c, "gzip", func(w io.Writer) io.WriteCloser {
+		return gzip.NewWriter(w)
+	})
+}
+
+func (suite *ClientSuite) TestClientdispatchRequestDecompressesDeflate(c *gc.C) {
+	suite.checkDecompresses(c, "deflate", func(w io.Writer) io.WriteCloser {
+		return zlib.NewWriter(w)
+	})
+}
+
+func (suite *ClientSuite) TestClientdispatchRequestDecompressesRawDeflate(c *gc.C) {
+	suite.checkDecompresses(c, "deflate", func(w io.Writer) io.WriteCloser {
+		writer, _ := flate.NewWriter(w, flate.DefaultCompression)
+		return writer
+	})
+}
+
+func (suite *ClientSuite) TestClientGetStreamDecompresses(c *gc.C) {
+	var acceptEncoding string
+	content := strings.Repeat("stress test output\n", 1000)
+	server := newCompressingServer(content, "gzip", func(w io.Writer) io.WriteCloser {
+		return gzip.NewWriter(w)
+	}, &acceptEncoding)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	var buf bytes.Buffer
+
+	header, err := client.GetStream(&url.URL{Path: "/some/url/"}, "", nil, &buf)
+
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(buf.String(), gc.Equals, content)
+	c.Check(header.Get("Content-Encoding"), gc.Equals, "gzip")
+	c.Check(acceptEncoding, gc.Equals, "gzip, deflate")
+}
+
+func (suite *ClientSuite) TestClientGetStreamErrorWritesNothing(c *gc.C) {
+	URI := "/some/url/"
+	server := newSingleServingServer(URI, "no such thing", http.StatusNotFound)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	var buf bytes.Buffer
+
+	_, err = client.GetStream(&url.URL{Path: URI}, "", nil, &buf)
+
+	svrErr, ok := GetServerError(err)
+	c.Assert(ok, jc.IsTrue)
+	c.Check(svrErr.StatusCode, gc.Equals, http.StatusNotFound)
+	c.Check(buf.Len(), gc.Equals, 0)
+}
+
+func (suite *ClientSuite) TestClientGetStreamRetries503(c *gc.C) {
+	URI := "/some/url/"
+	server := newFlakyServer(URI, 503, 1)
+	defer server.Close()
+	client, err := NewAuthenticatedClientWithSignatureMethod(server.URL+"/api/1.0/", "a:b:c", HMACSHA1SignatureMethod)
+	c.Assert(err, jc.ErrorIsNil)
+	var buf bytes.Buffer
+
+	_, err = client.GetStream(&url.URL{Path: URI}, "", nil, &buf)
+
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(buf.String(), gc.Equals, "ok")
+	c.Assert(*server.nbRequests, gc.Equals, 2)
+	for _, header := range *server.headers {
+		c.Check(header["Authorization"], gc.HasLen, 1)
+	}
+}
+
+func (suite *ClientSuite) TestClientdispatchRequestDisableCompression(c *gc.C) {
+	URI := "/some/url/"
+	server := newSingleServingServer(URI, "expected:result", http.StatusOK)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	client.DisableCompression = true
+	request, err := http.NewRequest("GET", server.URL+URI, nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	result, err := client.dispatchRequest(request)
+
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(result), gc.Equals, "expected:result")
+	c.Check(server.requestHeader.Get("Accept-Encoding"), gc.Equals, "identity")
+}
+
+func (suite *ClientSuite) TestClientdispatchRequestUnsupportedEncoding(c *gc.C) {
+	var acceptEncoding string
+	server := newCompressingServer("data", "br", func(w io.Writer) io.WriteCloser {
+		return nopWriteCloser{w}
+	}, &acceptEncoding)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	request, err := http.NewRequest("GET", server.URL+"/some/url/", nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, err = client.dispatchRequest(request)
+
+	c.Check(err, gc.ErrorMatches, `unsupported response Content-Encoding "br"`)
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
 func (suite *ClientSuite) TestClientGetFormatsGetParameters(c *gc.C) {
 	URI, err := url.Parse("/some/url")
 	c.Assert(err, jc.ErrorIsNil)
@@ -268,6 +582,69 @@ func (suite *ClientSuite) TestClientDeleteSendsRequest(c *gc.C) {
 	c.Assert(err, jc.ErrorIsNil)
 }
 
+func (suite *ClientSuite) TestClientRawSendsFormParameters(c *gc.C) {
+	URI, err := url.Parse("/some/url")
+	c.Assert(err, jc.ErrorIsNil)
+	expectedResult := "expected:result"
+	params := url.Values{"test": {"123"}}
+	server := newSingleServingServer(URI.String()+"?op=raw_op", expectedResult, http.StatusOK)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	result, status, err := client.Raw("POST", URI, "raw_op", params, nil)
+
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(status, gc.Equals, http.StatusOK)
+	c.Check(string(result), gc.Equals, expectedResult)
+	c.Check(*server.requestContent, gc.Equals, "test=123")
+	c.Check((*server.requestHeader).Get("Content-Type"), gc.Equals, "application/x-www-form-urlencoded")
+}
+
+func (suite *ClientSuite) TestClientRawSendsBody(c *gc.C) {
+	URI, err := url.Parse("/some/url")
+	c.Assert(err, jc.ErrorIsNil)
+	params := url.Values{"test": {"123"}}
+	server := newSingleServingServer(URI.String()+"?test=123", "", http.StatusOK)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, _, err = client.Raw("PUT", URI, "", params, strings.NewReader("content"))
+
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(*server.requestContent, gc.Equals, "content")
+	c.Check((*server.requestHeader).Get("Content-Type"), gc.Equals, "application/octet-stream")
+}
+
+func (suite *ClientSuite) TestClientRawReturnsStatusOnError(c *gc.C) {
+	URI, err := url.Parse("/some/url")
+	c.Assert(err, jc.ErrorIsNil)
+	server := newSingleServingServer(URI.String(), "gone", http.StatusConflict)
+	defer server.Close()
+	client, err := NewAnonymousClient(server.URL, "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, status, err := client.Raw("GET", URI, "", nil, nil)
+
+	c.Assert(err, gc.NotNil)
+	c.Check(status, gc.Equals, http.StatusConflict)
+	svrErr, ok := errors.Cause(err).(ServerError)
+	c.Assert(ok, jc.IsTrue)
+	c.Check(svrErr.BodyMessage, gc.Equals, "gone")
+}
+
+func (suite *ClientSuite) TestClientRawRejectsOpParameter(c *gc.C) {
+	client, err := NewAnonymousClient("http://example.com/", "1.0")
+	c.Assert(err, jc.ErrorIsNil)
+	URI, err := url.Parse("/some/url")
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, _, err = client.Raw("POST", URI, "", url.Values{"op": {"list"}}, nil)
+
+	c.Check(err, gc.ErrorMatches, "reserved parameter 'op' passed.*")
+}
+
 func (suite *ClientSuite) TestNewAnonymousClientEnsuresTrailingSlash(c *gc.C) {
 	client, err := NewAnonymousClient("http://example.com/", "1.0")
 	c.Assert(err, jc.ErrorIsNil)
@@ -302,6 +679,16 @@ func (suite *ClientSuite) TestNewAuthenticatedClientParsesApiKey(c *gc.C) {
 	c.Check(signer.token.TokenSecret, gc.Equals, tokenSecret)
 }
 
+func (suite *ClientSuite) TestNewAuthenticatedClientWithSignatureMethod(c *gc.C) {
+	client, err := NewAuthenticatedClientWithSignatureMethod("http://example.com/api/1.0/", "a:b:c", HMACSHA1SignatureMethod)
+
+	c.Assert(err, jc.ErrorIsNil)
+	signer := client.Signer.(*hmacSHA1OAuthSigner)
+	c.Check(signer.token.ConsumerKey, gc.Equals, "a")
+	c.Check(signer.token.TokenKey, gc.Equals, "b")
+	c.Check(signer.token.TokenSecret, gc.Equals, "c")
+}
+
 func (suite *ClientSuite) TestNewAuthenticatedClientFailsIfInvalidKey(c *gc.C) {
 	client, err := NewAuthenticatedClient("", "invalid-key")
 
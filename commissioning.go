@@ -0,0 +1,174 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/juju/schema"
+)
+
+// machineResourcesScript is the commissioning script whose output is the
+// JSON written by machine-resources. MAAS runs it from 2.9.
+const machineResourcesScript = "50-maas-01-commissioning"
+
+// CommissioningData is the hardware of a machine, as found by
+// machine-resources when the machine was commissioned.
+type CommissioningData struct {
+	System       SystemResources
+	NetworkCards []NetworkCardResources
+	PCIDevices   []PCIDeviceResources
+	USBDevices   []USBDeviceResources
+
+	// Raw is the JSON output of machine-resources that the data is read
+	// from, which describes more of the hardware.
+	Raw []byte
+}
+
+// SystemResources describes the machine itself, as reported by its
+// firmware.
+type SystemResources struct {
+	UUID    string `json:"uuid"`
+	Vendor  string `json:"vendor"`
+	Product string `json:"product"`
+	Family  string `json:"family"`
+	Version string `json:"version"`
+	SKU     string `json:"sku"`
+	Serial  string `json:"serial"`
+	// Type is "physical", or the kind of virtual machine, such as "kvm".
+	Type     string            `json:"type"`
+	Firmware FirmwareResources `json:"firmware"`
+}
+
+// FirmwareResources describes the firmware of a machine.
+type FirmwareResources struct {
+	Vendor  string `json:"vendor"`
+	Date    string `json:"date"`
+	Version string `json:"version"`
+}
+
+// NetworkCardResources describes a network card.
+type NetworkCardResources struct {
+	Driver        string `json:"driver"`
+	DriverVersion string `json:"driver_version"`
+	PCIAddress    string `json:"pci_address"`
+	Vendor        string `json:"vendor"`
+	VendorID      string `json:"vendor_id"`
+	Product       string `json:"product"`
+	ProductID     string `json:"product_id"`
+	// Ports are the network interfaces of the card.
+	Ports []NetworkPortResources `json:"ports"`
+}
+
+// NetworkPortResources describes a network interface of a network card.
+type NetworkPortResources struct {
+	// ID is the name of the interface in the commissioning environment,
+	// such as "eth0".
+	ID           string `json:"id"`
+	Address      string `json:"address"`
+	Protocol     string `json:"protocol"`
+	LinkDetected bool   `json:"link_detected"`
+	// LinkSpeed is in Mbit/s, and is zero if there is no link.
+	LinkSpeed uint64 `json:"link_speed"`
+}
+
+// PCIDeviceResources describes a PCI device.
+type PCIDeviceResources struct {
+	Driver        string `json:"driver"`
+	DriverVersion string `json:"driver_version"`
+	PCIAddress    string `json:"pci_address"`
+	Vendor        string `json:"vendor"`
+	VendorID      string `json:"vendor_id"`
+	Product       string `json:"product"`
+	ProductID     string `json:"product_id"`
+}
+
+// USBDeviceResources describes a USB device.
+type USBDeviceResources struct {
+	BusAddress    uint64 `json:"bus_address"`
+	DeviceAddress uint64 `json:"device_address"`
+	Driver        string `json:"driver"`
+	Vendor        string `json:"vendor"`
+	VendorID      string `json:"vendor_id"`
+	Product       string `json:"product"`
+	ProductID     string `json:"product_id"`
+	// Speed is in Mbit/s.
+	Speed float64 `json:"speed"`
+}
+
+// machineResources is the part of the machine-resources output that is
+// read into CommissioningData.
+type machineResources struct {
+	Resources struct {
+		System  SystemResources `json:"system"`
+		Network struct {
+			Cards []NetworkCardResources `json:"cards"`
+		} `json:"network"`
+		PCI struct {
+			Devices []PCIDeviceResources `json:"devices"`
+		} `json:"pci"`
+		USB struct {
+			Devices []USBDeviceResources `json:"devices"`
+		} `json:"usb"`
+	} `json:"resources"`
+}
+
+// CommissioningData implements Machine.
+//
+// Returns
+//  - NoMatchError if the machine hasn't been commissioned by MAAS 2.9 or
+//    later, which is when machine-resources was introduced
+//  - PermissionError if the user does not have permission to read the results
+//  - DeserializationError if the output can't be read
+func (m *machine) CommissioningData() (CommissioningData, error) {
+	var empty CommissioningData
+	params := NewURLParams()
+	params.Values.Add("system_id", m.systemID)
+	params.Values.Add("name", machineResourcesScript)
+	source, err := m.controller.getQuery("commissioning-results", params.Values)
+	if err != nil {
+		return empty, translateError(opEntity, err)
+	}
+
+	fields := schema.Fields{
+		"name": schema.String(),
+		"data": schema.String(),
+	}
+	checker := schema.List(schema.FieldMap(fields, nil))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return empty, WrapWithDeserializationError(err, "commissioning results schema check failed")
+	}
+	// If the machine has been commissioned more than once, the last
+	// output is the most recent.
+	var data string
+	found := false
+	for _, result := range coerced.([]interface{}) {
+		valid := result.(map[string]interface{})
+		if valid["name"].(string) == machineResourcesScript {
+			data = valid["data"].(string)
+			found = true
+		}
+	}
+	if !found {
+		return empty, NewNoMatchError(fmt.Sprintf("no commissioning data for machine %q", m.systemID))
+	}
+	content, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return empty, WrapWithDeserializationError(err, "commissioning data")
+	}
+	var resources machineResources
+	if err := json.Unmarshal(content, &resources); err != nil {
+		return empty, WrapWithDeserializationError(err, "commissioning data")
+	}
+	return CommissioningData{
+		System:       resources.Resources.System,
+		NetworkCards: resources.Resources.Network.Cards,
+		PCIDevices:   resources.Resources.PCI.Devices,
+		USBDevices:   resources.Resources.USB.Devices,
+		Raw:          content,
+	}, nil
+}
@@ -0,0 +1,185 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+const commissioningResultsPath = "/api/2.0/commissioning-results/?name=50-maas-01-commissioning&system_id=4y3ha3"
+
+func commissioningResultsResponse(data string) string {
+	return fmt.Sprintf(`[{"name": %q, "data": %q, "result_type": 0, "script_result": 0}]`,
+		machineResourcesScript, base64.StdEncoding.EncodeToString([]byte(data)))
+}
+
+func (s *machineSuite) TestCommissioningData(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse(commissioningResultsPath, http.StatusOK, commissioningResultsResponse(machineResourcesResponse))
+
+	data, err := machine.CommissioningData()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(data.System, jc.DeepEquals, SystemResources{
+		UUID:    "4c4c4544-0042-3010-8056-b2c04f4e4d32",
+		Vendor:  "Dell Inc.",
+		Product: "PowerEdge R630",
+		Family:  "PowerEdge",
+		SKU:     "SKU=NotProvided",
+		Serial:  "2B0VNM2",
+		Type:    "physical",
+		Firmware: FirmwareResources{
+			Vendor:  "Dell Inc.",
+			Date:    "08/21/2019",
+			Version: "2.11.0",
+		},
+	})
+	c.Check(data.NetworkCards, jc.DeepEquals, []NetworkCardResources{{
+		Driver:        "ixgbe",
+		DriverVersion: "5.1.0-k",
+		PCIAddress:    "0000:01:00.0",
+		Vendor:        "Intel Corporation",
+		VendorID:      "8086",
+		Product:       "82599ES 10-Gigabit SFI/SFP+ Network Connection",
+		ProductID:     "10fb",
+		Ports: []NetworkPortResources{{
+			ID:           "eno1",
+			Address:      "52:54:00:55:b6:80",
+			Protocol:     "ethernet",
+			LinkDetected: true,
+			LinkSpeed:    10000,
+		}},
+	}})
+	c.Check(data.PCIDevices, jc.DeepEquals, []PCIDeviceResources{{
+		Driver:     "ixgbe",
+		PCIAddress: "0000:01:00.0",
+		Vendor:     "Intel Corporation",
+		VendorID:   "8086",
+		Product:    "82599ES 10-Gigabit SFI/SFP+ Network Connection",
+		ProductID:  "10fb",
+	}})
+	c.Check(data.USBDevices, jc.DeepEquals, []USBDeviceResources{{
+		BusAddress:    1,
+		DeviceAddress: 2,
+		Driver:        "usbhid",
+		Vendor:        "Avocent",
+		VendorID:      "0624",
+		Product:       "USB Composite Device-0",
+		ProductID:     "0248",
+		Speed:         12,
+	}})
+	c.Check(string(data.Raw), gc.Equals, machineResourcesResponse)
+}
+
+func (s *machineSuite) TestCommissioningDataMissing(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse(commissioningResultsPath, http.StatusOK, "[]")
+
+	_, err := machine.CommissioningData()
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+	c.Assert(err.Error(), gc.Equals, `no commissioning data for machine "4y3ha3"`)
+}
+
+func (s *machineSuite) TestCommissioningDataBadData(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse(commissioningResultsPath, http.StatusOK, commissioningResultsResponse("not json"))
+
+	_, err := machine.CommissioningData()
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+}
+
+func (s *machineSuite) TestCommissioningDataForbidden(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse(commissioningResultsPath, http.StatusForbidden, "no")
+
+	_, err := machine.CommissioningData()
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+}
+
+const machineResourcesResponse = `
+{
+    "api_extensions": ["resources"],
+    "api_version": "1.0",
+    "resources": {
+        "cpu": {"architecture": "x86_64"},
+        "network": {
+            "cards": [
+                {
+                    "driver": "ixgbe",
+                    "driver_version": "5.1.0-k",
+                    "ports": [
+                        {
+                            "id": "eno1",
+                            "address": "52:54:00:55:b6:80",
+                            "port": 0,
+                            "protocol": "ethernet",
+                            "auto_negotiation": true,
+                            "link_detected": true,
+                            "link_speed": 10000,
+                            "link_duplex": "full"
+                        }
+                    ],
+                    "numa_node": 0,
+                    "pci_address": "0000:01:00.0",
+                    "vendor": "Intel Corporation",
+                    "vendor_id": "8086",
+                    "product": "82599ES 10-Gigabit SFI/SFP+ Network Connection",
+                    "product_id": "10fb"
+                }
+            ],
+            "total": 1
+        },
+        "pci": {
+            "devices": [
+                {
+                    "driver": "ixgbe",
+                    "driver_version": "",
+                    "numa_node": 0,
+                    "pci_address": "0000:01:00.0",
+                    "vendor": "Intel Corporation",
+                    "vendor_id": "8086",
+                    "product": "82599ES 10-Gigabit SFI/SFP+ Network Connection",
+                    "product_id": "10fb"
+                }
+            ],
+            "total": 1
+        },
+        "usb": {
+            "devices": [
+                {
+                    "bus_address": 1,
+                    "device_address": 2,
+                    "driver": "usbhid",
+                    "driver_version": "",
+                    "vendor": "Avocent",
+                    "vendor_id": "0624",
+                    "product": "USB Composite Device-0",
+                    "product_id": "0248",
+                    "speed": 12
+                }
+            ],
+            "total": 1
+        },
+        "system": {
+            "uuid": "4c4c4544-0042-3010-8056-b2c04f4e4d32",
+            "vendor": "Dell Inc.",
+            "product": "PowerEdge R630",
+            "family": "PowerEdge",
+            "version": "",
+            "sku": "SKU=NotProvided",
+            "serial": "2B0VNM2",
+            "type": "physical",
+            "firmware": {
+                "vendor": "Dell Inc.",
+                "date": "08/21/2019",
+                "version": "2.11.0"
+            }
+        }
+    }
+}
+`
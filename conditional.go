@@ -0,0 +1,153 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+
+	"github.com/juju/errors"
+)
+
+// changeToken records what is known about a previous response, so that a
+// repeated request can tell whether the resource has changed. It is passed to
+// callers as an opaque string.
+type changeToken struct {
+	etag         string
+	lastModified string
+	digest       string
+}
+
+func parseChangeToken(token string) changeToken {
+	// A malformed token is treated like no token at all, so that the
+	// resource is read in full.
+	values, _ := url.ParseQuery(token)
+	return changeToken{
+		etag:         values.Get("etag"),
+		lastModified: values.Get("modified"),
+		digest:       values.Get("sha256"),
+	}
+}
+
+func (t changeToken) String() string {
+	values := make(url.Values)
+	if t.etag != "" {
+		values.Set("etag", t.etag)
+	}
+	if t.lastModified != "" {
+		values.Set("modified", t.lastModified)
+	}
+	values.Set("sha256", t.digest)
+	return values.Encode()
+}
+
+// GetIfChanged performs an HTTP "GET" to the API like Get, unless the
+// resource is unchanged since the response that the previous token was
+// returned for. The validators MAAS returned with that response, if any, are
+// sent so that the MAAS controller can answer with a "304 Not Modified"
+// rather than the resource. Otherwise the resource is read in full, and it is
+// compared with the previous response.
+//
+// It returns the body of the response and a token to pass to the next call.
+// If the resource is unchanged, the body is nil and changed is false. An
+// empty previous token always counts as changed.
+func (client Client) GetIfChanged(uri *url.URL, operation string, parameters url.Values, previousToken string) (body []byte, token string, changed bool, err error) {
+	request, err := client.newGetRequest(uri, operation, parameters)
+	if err != nil {
+		return nil, "", false, err
+	}
+	previous := parseChangeToken(previousToken)
+	if previous.etag != "" {
+		request.Header.Set("If-None-Match", previous.etag)
+	}
+	if previous.lastModified != "" {
+		request.Header.Set("If-Modified-Since", previous.lastModified)
+	}
+	body, response, err := client.dispatchRequestWithResponse(request)
+	if svrErr, ok := GetServerError(err); ok && svrErr.StatusCode == http.StatusNotModified {
+		return nil, previousToken, false, nil
+	}
+	if err != nil {
+		return nil, "", false, err
+	}
+	digest := sha256.Sum256(body)
+	next := changeToken{
+		etag:         response.Header.Get("ETag"),
+		lastModified: response.Header.Get("Last-Modified"),
+		digest:       hex.EncodeToString(digest[:]),
+	}
+	if previousToken != "" && next.digest == previous.digest {
+		return nil, next.String(), false, nil
+	}
+	return body, next.String(), true, nil
+}
+
+// MachinesIfChanged implements Controller.
+func (c *controller) MachinesIfChanged(args MachinesArgs, previousToken string) ([]Machine, string, bool, error) {
+	if err := args.Validate(); err != nil {
+		return nil, "", false, errors.Trace(err)
+	}
+	bytes, token, changed, err := c._getRawIfChanged("machines", machinesParams(args, c.schemaVersion()), previousToken)
+	if err != nil {
+		return nil, "", false, translateError(opEntity, err)
+	}
+	if !changed {
+		return nil, token, false, nil
+	}
+	source, err := decodeMachines(args.Projection, bytes)
+	if err != nil {
+		return nil, "", false, translateError(opEntity, err)
+	}
+	machines, err := c.machinesFromSource(args, source)
+	if err != nil {
+		return nil, "", false, errors.Trace(err)
+	}
+	return machines, token, true, nil
+}
+
+// _getRawIfChanged is like _getRaw, but uses Client.GetIfChanged.
+func (c *controller) _getRawIfChanged(path string, params url.Values, previousToken string) ([]byte, string, bool, error) {
+	path = EnsureTrailingSlash(path)
+	requestID := nextRequestID()
+	client := c.requestClient(requestID)
+	if c.logger.IsTraceEnabled() {
+		c.logger.Tracef("request %s: GET %s%s?%s, if changed", requestID, c.client.APIURL, path, params.Encode())
+	}
+	var (
+		token   string
+		changed bool
+	)
+	bytes, err := c.withCredentialRefresh(context.Background(), func() ([]byte, error) {
+		if err := c.rateLimiter.wait(context.Background(), path); err != nil {
+			return nil, errors.Trace(err)
+		}
+		// The client adds the op to the params it is passed, so give
+		// each attempt its own copy.
+		query := make(url.Values)
+		for key, values := range params {
+			query[key] = values
+		}
+		var (
+			bytes []byte
+			err   error
+		)
+		bytes, token, changed, err = client.GetIfChanged(&url.URL{Path: path}, "", query, previousToken)
+		return bytes, err
+	})
+	if err != nil {
+		c.logger.Tracef("response %s: error: %q", requestID, err.Error())
+		c.logger.Tracef("error detail: %#v", err)
+		err = newRequestError(requestID, err)
+		return nil, "", false, errors.Trace(err)
+	}
+	if !changed {
+		c.logger.Tracef("response %s: unchanged", requestID)
+		return nil, token, false, nil
+	}
+	c.logger.Tracef("response %s: %s", requestID, string(bytes))
+	return bytes, token, true, nil
+}
@@ -0,0 +1,95 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type conditionalSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&conditionalSuite{})
+
+const machinesPath = "/api/2.0/machines/"
+
+func (s *conditionalSuite) TestChangeTokenRoundTrip(c *gc.C) {
+	token := changeToken{etag: `"abc"`, lastModified: "Wed, 21 Oct 2015 07:28:00 GMT", digest: "1234"}
+	c.Assert(parseChangeToken(token.String()), gc.Equals, token)
+	c.Assert(parseChangeToken("%%bad"), gc.Equals, changeToken{})
+}
+
+func (s *conditionalSuite) TestMachinesIfChangedFirstCall(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse(machinesPath, http.StatusOK, machinesResponse)
+
+	machines, token, changed, err := controller.MachinesIfChanged(MachinesArgs{}, "")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(changed, jc.IsTrue)
+	c.Check(machines, gc.HasLen, 3)
+	c.Check(token, gc.Not(gc.Equals), "")
+	request := server.LastRequest()
+	c.Check(request.Header.Get("If-None-Match"), gc.Equals, "")
+	c.Check(request.Header.Get("If-Modified-Since"), gc.Equals, "")
+}
+
+func (s *conditionalSuite) TestMachinesIfChangedSendsValidators(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponseWithHeader(machinesPath, http.StatusOK, machinesResponse, http.Header{
+		"Etag":          {`"v1"`},
+		"Last-Modified": {"Wed, 21 Oct 2015 07:28:00 GMT"},
+	})
+	server.AddGetResponse(machinesPath, http.StatusNotModified, "")
+
+	_, token, _, err := controller.MachinesIfChanged(MachinesArgs{}, "")
+	c.Assert(err, jc.ErrorIsNil)
+	machines, nextToken, changed, err := controller.MachinesIfChanged(MachinesArgs{}, token)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(changed, jc.IsFalse)
+	c.Check(machines, gc.HasLen, 0)
+	c.Check(nextToken, gc.Equals, token)
+	request := server.LastRequest()
+	c.Check(request.Header.Get("If-None-Match"), gc.Equals, `"v1"`)
+	c.Check(request.Header.Get("If-Modified-Since"), gc.Equals, "Wed, 21 Oct 2015 07:28:00 GMT")
+}
+
+func (s *conditionalSuite) TestMachinesIfChangedSameContent(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse(machinesPath, http.StatusOK, machinesResponse)
+	server.AddGetResponse(machinesPath, http.StatusOK, machinesResponse)
+
+	_, token, _, err := controller.MachinesIfChanged(MachinesArgs{}, "")
+	c.Assert(err, jc.ErrorIsNil)
+	machines, _, changed, err := controller.MachinesIfChanged(MachinesArgs{}, token)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(changed, jc.IsFalse)
+	c.Check(machines, gc.HasLen, 0)
+}
+
+func (s *conditionalSuite) TestMachinesIfChangedNewContent(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse(machinesPath, http.StatusOK, machinesResponse)
+	server.AddGetResponse(machinesPath, http.StatusOK, "["+machineResponse+"]")
+
+	_, token, _, err := controller.MachinesIfChanged(MachinesArgs{}, "")
+	c.Assert(err, jc.ErrorIsNil)
+	machines, nextToken, changed, err := controller.MachinesIfChanged(MachinesArgs{}, token)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(changed, jc.IsTrue)
+	c.Check(machines, gc.HasLen, 1)
+	c.Check(nextToken, gc.Not(gc.Equals), token)
+}
+
+func (s *conditionalSuite) TestMachinesIfChangedError(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse(machinesPath, http.StatusInternalServerError, "boom")
+
+	_, _, _, err := controller.MachinesIfChanged(MachinesArgs{}, "")
+	c.Assert(err, jc.Satisfies, IsUnexpectedError)
+}
@@ -0,0 +1,398 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/utils/set"
+)
+
+// The names of the MAAS configuration settings used by the typed helpers.
+const (
+	configEnableHTTPProxy = "enable_http_proxy"
+	configHTTPProxy       = "http_proxy"
+	configUsePeerProxy    = "use_peer_proxy"
+	configNTPServers      = "ntp_servers"
+	configNTPExternalOnly = "ntp_external_only"
+
+	configUpstreamDNS      = "upstream_dns"
+	configDNSSECValidation = "dnssec_validation"
+
+	configDefaultStorageLayout = "default_storage_layout"
+	configDefaultMinHWEKernel  = "default_min_hwe_kernel"
+)
+
+const (
+	// StorageLayoutFlat - A single partition on the boot disk, holding the
+	// root filesystem.
+	StorageLayoutFlat = "flat"
+
+	// StorageLayoutLVM - A single volume group on the boot disk, with a
+	// logical volume holding the root filesystem.
+	StorageLayoutLVM = "lvm"
+
+	// StorageLayoutBcache - The boot disk is cached by the smallest SSD,
+	// using bcache.
+	StorageLayoutBcache = "bcache"
+
+	// StorageLayoutBlank - The disks are left without partitions or
+	// filesystems, to be set up with the storage APIs.
+	StorageLayoutBlank = "blank"
+)
+
+const (
+	// DNSSECValidationAuto - The DNSSEC root key built into the DNS
+	// server is used.
+	DNSSECValidationAuto = "auto"
+
+	// DNSSECValidationYes - DNSSEC is validated with the trust anchors
+	// configured in the DNS server.
+	DNSSECValidationYes = "yes"
+
+	// DNSSECValidationNo - DNSSEC isn't validated.
+	DNSSECValidationNo = "no"
+)
+
+var dnssecValidations = set.NewStrings(
+	DNSSECValidationAuto,
+	DNSSECValidationYes,
+	DNSSECValidationNo,
+)
+
+var storageLayouts = set.NewStrings(
+	StorageLayoutFlat,
+	StorageLayoutLVM,
+	StorageLayoutBcache,
+	StorageLayoutBlank,
+)
+
+// StorageLayouts returns the names of the storage layouts that MAAS can
+// apply to a machine's disks.
+func StorageLayouts() []string {
+	return storageLayouts.SortedValues()
+}
+
+// validateStorageLayout checks the layout is one of StorageLayouts.
+func validateStorageLayout(layout string) error {
+	if !storageLayouts.Contains(layout) {
+		return errors.NotValidf("storage layout %q", layout)
+	}
+	return nil
+}
+
+// hweKernelRE matches the names of the kernels that MAAS deploys, such as
+// "ga-18.04", "hwe-16.04" or "hwe-16.04-edge".
+var hweKernelRE = regexp.MustCompile(`^(ga|hwe)-[0-9a-z.]+(-[0-9a-z]+)*$`)
+
+// ProxyConfig holds the MAAS settings for the HTTP proxy used by the
+// machines that MAAS deploys.
+type ProxyConfig struct {
+	// Enabled is whether the machines use a proxy (enable_http_proxy).
+	Enabled bool
+	// URL is the external proxy that is used instead of the proxy built
+	// into MAAS (http_proxy). It is optional.
+	URL string
+	// UsePeerProxy is whether the proxy built into MAAS is used, with URL
+	// as its peer (use_peer_proxy). It requires URL.
+	UsePeerProxy bool
+}
+
+// Validate checks that the URL, if set, is an absolute http or https URL,
+// and that it is set if UsePeerProxy is.
+func (c *ProxyConfig) Validate() error {
+	if c.URL == "" {
+		if c.UsePeerProxy {
+			return errors.NotValidf("UsePeerProxy without URL")
+		}
+		return nil
+	}
+	proxyURL, err := url.Parse(c.URL)
+	if err != nil {
+		return errors.NewNotValid(err, "URL")
+	}
+	if (proxyURL.Scheme != "http" && proxyURL.Scheme != "https") || proxyURL.Host == "" {
+		return errors.NotValidf("URL %q", c.URL)
+	}
+	return nil
+}
+
+// NTPConfig holds the MAAS settings for the NTP servers used by MAAS and
+// the machines it deploys.
+type NTPConfig struct {
+	// Servers are the addresses or hostnames of the NTP servers
+	// (ntp_servers).
+	Servers []string
+	// ExternalOnly is whether the machines use the Servers directly,
+	// rather than the MAAS region and rack controllers (ntp_external_only).
+	// It requires Servers.
+	ExternalOnly bool
+}
+
+// Validate checks the servers aren't empty and don't contain whitespace,
+// and that there are servers if ExternalOnly is set.
+func (c *NTPConfig) Validate() error {
+	for _, server := range c.Servers {
+		if server == "" || strings.ContainsAny(server, " \t\n,") {
+			return errors.NotValidf("NTP server %q", server)
+		}
+	}
+	if c.ExternalOnly && len(c.Servers) == 0 {
+		return errors.NotValidf("ExternalOnly without Servers")
+	}
+	return nil
+}
+
+// DNSConfig holds the MAAS settings for the DNS servers that MAAS forwards
+// the queries it can't answer to.
+type DNSConfig struct {
+	// UpstreamServers are the IP addresses of the upstream DNS servers
+	// (upstream_dns).
+	UpstreamServers []string
+	// DNSSECValidation is one of the DNSSECValidation constants
+	// (dnssec_validation).
+	DNSSECValidation string
+}
+
+// Validate checks the upstream servers are IP addresses, and that
+// DNSSECValidation is one of the DNSSECValidation constants.
+func (c *DNSConfig) Validate() error {
+	for _, server := range c.UpstreamServers {
+		if _, err := netip.ParseAddr(server); err != nil {
+			return errors.NotValidf("upstream DNS server %q", server)
+		}
+	}
+	if !dnssecValidations.Contains(c.DNSSECValidation) {
+		return errors.NotValidf("DNSSEC validation %q", c.DNSSECValidation)
+	}
+	return nil
+}
+
+// ConfigureDNSArgs is an argument struct for passing parameters to the
+// Controller.ConfigureDNS method.
+type ConfigureDNSArgs struct {
+	// DefaultDomain is the name of the domain given to new machines. The
+	// default domain isn't changed if it is empty.
+	DefaultDomain string
+	DNS           DNSConfig
+}
+
+// ConfigureNetworkServicesArgs is an argument struct for passing
+// parameters to the Controller.ConfigureNetworkServices method.
+type ConfigureNetworkServicesArgs struct {
+	Proxy ProxyConfig
+	NTP   NTPConfig
+}
+
+// Validate checks the proxy and NTP settings.
+func (a *ConfigureNetworkServicesArgs) Validate() error {
+	if err := a.Proxy.Validate(); err != nil {
+		return errors.Annotate(err, "proxy")
+	}
+	if err := a.NTP.Validate(); err != nil {
+		return errors.Annotate(err, "NTP")
+	}
+	return nil
+}
+
+// ProxyConfig implements Controller.
+func (c *controller) ProxyConfig() (ProxyConfig, error) {
+	var (
+		config ProxyConfig
+		err    error
+	)
+	if config.Enabled, err = c.configBool(configEnableHTTPProxy); err != nil {
+		return ProxyConfig{}, errors.Trace(err)
+	}
+	if config.URL, err = c.configString(configHTTPProxy); err != nil {
+		return ProxyConfig{}, errors.Trace(err)
+	}
+	if config.UsePeerProxy, err = c.configBool(configUsePeerProxy); err != nil {
+		return ProxyConfig{}, errors.Trace(err)
+	}
+	return config, nil
+}
+
+// SetProxyConfig implements Controller.
+func (c *controller) SetProxyConfig(config ProxyConfig) error {
+	if err := config.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	return c.setProxyConfig(config)
+}
+
+func (c *controller) setProxyConfig(config ProxyConfig) error {
+	// The proxy URL is set first so that the proxy is never enabled with
+	// the old URL.
+	if err := c.setConfig(configHTTPProxy, config.URL); err != nil {
+		return errors.Trace(err)
+	}
+	if err := c.setConfig(configUsePeerProxy, strconv.FormatBool(config.UsePeerProxy)); err != nil {
+		return errors.Trace(err)
+	}
+	return c.setConfig(configEnableHTTPProxy, strconv.FormatBool(config.Enabled))
+}
+
+// NTPConfig implements Controller.
+func (c *controller) NTPConfig() (NTPConfig, error) {
+	var config NTPConfig
+	servers, err := c.configString(configNTPServers)
+	if err != nil {
+		return NTPConfig{}, errors.Trace(err)
+	}
+	config.Servers = strings.Fields(servers)
+	if config.ExternalOnly, err = c.configBool(configNTPExternalOnly); err != nil {
+		return NTPConfig{}, errors.Trace(err)
+	}
+	return config, nil
+}
+
+// SetNTPConfig implements Controller.
+func (c *controller) SetNTPConfig(config NTPConfig) error {
+	if err := config.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	return c.setNTPConfig(config)
+}
+
+func (c *controller) setNTPConfig(config NTPConfig) error {
+	if err := c.setConfig(configNTPServers, strings.Join(config.Servers, " ")); err != nil {
+		return errors.Trace(err)
+	}
+	return c.setConfig(configNTPExternalOnly, strconv.FormatBool(config.ExternalOnly))
+}
+
+// ConfigureNetworkServices implements Controller.
+func (c *controller) ConfigureNetworkServices(args ConfigureNetworkServicesArgs) error {
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	if err := c.setProxyConfig(args.Proxy); err != nil {
+		return errors.Trace(err)
+	}
+	return c.setNTPConfig(args.NTP)
+}
+
+// DNSConfig implements Controller.
+func (c *controller) DNSConfig() (DNSConfig, error) {
+	var config DNSConfig
+	servers, err := c.configString(configUpstreamDNS)
+	if err != nil {
+		return DNSConfig{}, errors.Trace(err)
+	}
+	config.UpstreamServers = strings.Fields(servers)
+	if config.DNSSECValidation, err = c.configString(configDNSSECValidation); err != nil {
+		return DNSConfig{}, errors.Trace(err)
+	}
+	return config, nil
+}
+
+// SetDNSConfig implements Controller.
+func (c *controller) SetDNSConfig(config DNSConfig) error {
+	if err := config.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	return c.setDNSConfig(config)
+}
+
+func (c *controller) setDNSConfig(config DNSConfig) error {
+	if err := c.setConfig(configUpstreamDNS, strings.Join(config.UpstreamServers, " ")); err != nil {
+		return errors.Trace(err)
+	}
+	return c.setConfig(configDNSSECValidation, config.DNSSECValidation)
+}
+
+// ConfigureDNS implements Controller.
+func (c *controller) ConfigureDNS(args ConfigureDNSArgs) error {
+	if err := args.DNS.Validate(); err != nil {
+		return errors.Annotate(err, "DNS")
+	}
+	if err := c.setDNSConfig(args.DNS); err != nil {
+		return errors.Trace(err)
+	}
+	if args.DefaultDomain == "" {
+		return nil
+	}
+	return errors.Trace(c.SetDefaultDomain(args.DefaultDomain))
+}
+
+// SetDefaultStorageLayout implements Controller.
+func (c *controller) SetDefaultStorageLayout(layout string) error {
+	if err := validateStorageLayout(layout); err != nil {
+		return errors.Trace(err)
+	}
+	return c.setConfig(configDefaultStorageLayout, layout)
+}
+
+// SetDefaultMinHWEKernel implements Controller.
+func (c *controller) SetDefaultMinHWEKernel(kernel string) error {
+	if kernel != "" && !hweKernelRE.MatchString(kernel) {
+		return errors.NotValidf("kernel %q", kernel)
+	}
+	return c.setConfig(configDefaultMinHWEKernel, kernel)
+}
+
+// getConfig returns the value of the MAAS configuration setting.
+func (c *controller) getConfig(name string) (interface{}, error) {
+	params := NewURLParams()
+	params.Values.Add("name", name)
+	result, err := c._get("maas", "get_config", params.Values)
+	if err != nil {
+		return nil, translateError(opEntity, err)
+	}
+	return result, nil
+}
+
+// setConfig sets the MAAS configuration setting to the value.
+func (c *controller) setConfig(name, value string) error {
+	params := NewURLParams()
+	params.Values.Add("name", name)
+	params.Values.Add("value", value)
+	if _, err := c._postRaw("maas", "set_config", params.Values, nil); err != nil {
+		return errors.Annotatef(translateError(opEntity, err), "setting %s", name)
+	}
+	return nil
+}
+
+// configBool returns the value of a boolean MAAS configuration setting.
+func (c *controller) configBool(name string) (bool, error) {
+	value, err := c.getConfig(name)
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	switch value := value.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return value, nil
+	case string:
+		result, err := strconv.ParseBool(value)
+		if err != nil {
+			return false, NewDeserializationError("config %s: unexpected value %q", name, value)
+		}
+		return result, nil
+	}
+	return false, NewDeserializationError("config %s: unexpected value %v", name, value)
+}
+
+// configString returns the value of a string MAAS configuration setting,
+// which is empty if the setting isn't set.
+func (c *controller) configString(name string) (string, error) {
+	value, err := c.getConfig(name)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	switch value := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	}
+	return "", NewDeserializationError("config %s: unexpected value %v", name, value)
+}
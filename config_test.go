@@ -0,0 +1,371 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type configSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&configSuite{})
+
+func getConfigPath(name string) string {
+	return "/api/2.0/maas/?name=" + name + "&op=get_config"
+}
+
+const setConfigPath = "/api/2.0/maas/?op=set_config"
+
+func (*configSuite) TestProxyConfigValidate(c *gc.C) {
+	for i, test := range []struct {
+		config ProxyConfig
+		errMsg string
+	}{{
+		config: ProxyConfig{},
+	}, {
+		config: ProxyConfig{Enabled: true, URL: "http://proxy.example.com:3128/"},
+	}, {
+		config: ProxyConfig{Enabled: true, URL: "https://proxy.example.com", UsePeerProxy: true},
+	}, {
+		config: ProxyConfig{Enabled: true, UsePeerProxy: true},
+		errMsg: "UsePeerProxy without URL not valid",
+	}, {
+		config: ProxyConfig{URL: "proxy.example.com:3128"},
+		errMsg: `URL "proxy.example.com:3128" not valid`,
+	}, {
+		config: ProxyConfig{URL: "ftp://proxy.example.com"},
+		errMsg: `URL "ftp://proxy.example.com" not valid`,
+	}} {
+		c.Logf("test %d", i)
+		err := test.config.Validate()
+		if test.errMsg == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, jc.Satisfies, errors.IsNotValid)
+			c.Check(err, gc.ErrorMatches, test.errMsg)
+		}
+	}
+}
+
+func (*configSuite) TestNTPConfigValidate(c *gc.C) {
+	for i, test := range []struct {
+		config NTPConfig
+		errMsg string
+	}{{
+		config: NTPConfig{},
+	}, {
+		config: NTPConfig{Servers: []string{"ntp.ubuntu.com", "10.0.0.1"}, ExternalOnly: true},
+	}, {
+		config: NTPConfig{ExternalOnly: true},
+		errMsg: "ExternalOnly without Servers not valid",
+	}, {
+		config: NTPConfig{Servers: []string{""}},
+		errMsg: `NTP server "" not valid`,
+	}, {
+		config: NTPConfig{Servers: []string{"a b"}},
+		errMsg: `NTP server "a b" not valid`,
+	}} {
+		c.Logf("test %d", i)
+		err := test.config.Validate()
+		if test.errMsg == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, jc.Satisfies, errors.IsNotValid)
+			c.Check(err, gc.ErrorMatches, test.errMsg)
+		}
+	}
+}
+
+func (s *configSuite) TestProxyConfig(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse(getConfigPath("enable_http_proxy"), http.StatusOK, "true")
+	server.AddGetResponse(getConfigPath("http_proxy"), http.StatusOK, `"http://proxy.example.com:3128/"`)
+	server.AddGetResponse(getConfigPath("use_peer_proxy"), http.StatusOK, "false")
+
+	config, err := controller.ProxyConfig()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(config, jc.DeepEquals, ProxyConfig{
+		Enabled: true,
+		URL:     "http://proxy.example.com:3128/",
+	})
+}
+
+func (s *configSuite) TestProxyConfigUnset(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse(getConfigPath("enable_http_proxy"), http.StatusOK, "null")
+	server.AddGetResponse(getConfigPath("http_proxy"), http.StatusOK, "null")
+	server.AddGetResponse(getConfigPath("use_peer_proxy"), http.StatusOK, "null")
+
+	config, err := controller.ProxyConfig()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(config, jc.DeepEquals, ProxyConfig{})
+}
+
+func (s *configSuite) TestProxyConfigBadValue(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse(getConfigPath("enable_http_proxy"), http.StatusOK, "42")
+
+	_, err := controller.ProxyConfig()
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err, gc.ErrorMatches, "config enable_http_proxy: unexpected value 42")
+}
+
+func (s *configSuite) TestProxyConfigForbidden(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse(getConfigPath("enable_http_proxy"), http.StatusForbidden, "admins only")
+
+	_, err := controller.ProxyConfig()
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+	c.Assert(err, gc.ErrorMatches, "admins only")
+}
+
+func (s *configSuite) TestSetProxyConfig(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	for i := 0; i < 3; i++ {
+		server.AddPostResponse(setConfigPath, http.StatusOK, "")
+	}
+
+	err := controller.SetProxyConfig(ProxyConfig{
+		Enabled:      true,
+		URL:          "http://proxy.example.com:3128/",
+		UsePeerProxy: true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	checkSetConfig(c, server, map[string]string{
+		"http_proxy":        "http://proxy.example.com:3128/",
+		"use_peer_proxy":    "true",
+		"enable_http_proxy": "true",
+	})
+}
+
+func (s *configSuite) TestSetProxyConfigValidates(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.ResetRequests()
+	err := controller.SetProxyConfig(ProxyConfig{UsePeerProxy: true})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *configSuite) TestSetProxyConfigBadRequest(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse(setConfigPath, http.StatusBadRequest, "bad proxy")
+
+	err := controller.SetProxyConfig(ProxyConfig{URL: "http://proxy.example.com/"})
+	c.Assert(err, jc.Satisfies, IsBadRequestError)
+	c.Assert(err, gc.ErrorMatches, "setting http_proxy: bad proxy")
+}
+
+func (s *configSuite) TestNTPConfig(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse(getConfigPath("ntp_servers"), http.StatusOK, `"ntp.ubuntu.com 10.0.0.1"`)
+	server.AddGetResponse(getConfigPath("ntp_external_only"), http.StatusOK, "true")
+
+	config, err := controller.NTPConfig()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(config, jc.DeepEquals, NTPConfig{
+		Servers:      []string{"ntp.ubuntu.com", "10.0.0.1"},
+		ExternalOnly: true,
+	})
+}
+
+func (s *configSuite) TestSetNTPConfig(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	for i := 0; i < 2; i++ {
+		server.AddPostResponse(setConfigPath, http.StatusOK, "")
+	}
+
+	err := controller.SetNTPConfig(NTPConfig{Servers: []string{"ntp.ubuntu.com", "10.0.0.1"}})
+	c.Assert(err, jc.ErrorIsNil)
+	checkSetConfig(c, server, map[string]string{
+		"ntp_servers":       "ntp.ubuntu.com 10.0.0.1",
+		"ntp_external_only": "false",
+	})
+}
+
+func (s *configSuite) TestConfigureNetworkServices(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	for i := 0; i < 5; i++ {
+		server.AddPostResponse(setConfigPath, http.StatusOK, "")
+	}
+
+	err := controller.ConfigureNetworkServices(ConfigureNetworkServicesArgs{
+		Proxy: ProxyConfig{Enabled: true},
+		NTP:   NTPConfig{Servers: []string{"ntp.ubuntu.com"}, ExternalOnly: true},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	checkSetConfig(c, server, map[string]string{
+		"http_proxy":        "",
+		"use_peer_proxy":    "false",
+		"enable_http_proxy": "true",
+		"ntp_servers":       "ntp.ubuntu.com",
+		"ntp_external_only": "true",
+	})
+}
+
+func (s *configSuite) TestConfigureNetworkServicesValidates(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.ResetRequests()
+	err := controller.ConfigureNetworkServices(ConfigureNetworkServicesArgs{
+		Proxy: ProxyConfig{Enabled: true},
+		NTP:   NTPConfig{ExternalOnly: true},
+	})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(err, gc.ErrorMatches, "NTP: ExternalOnly without Servers not valid")
+	c.Assert(server.RequestCount(), gc.Equals, 0)
+}
+
+func (*configSuite) TestDNSConfigValidate(c *gc.C) {
+	for i, test := range []struct {
+		config DNSConfig
+		errMsg string
+	}{{
+		config: DNSConfig{DNSSECValidation: DNSSECValidationAuto},
+	}, {
+		config: DNSConfig{UpstreamServers: []string{"8.8.8.8", "2001:4860:4860::8888"}, DNSSECValidation: DNSSECValidationNo},
+	}, {
+		config: DNSConfig{},
+		errMsg: `DNSSEC validation "" not valid`,
+	}, {
+		config: DNSConfig{UpstreamServers: []string{"dns.example.com"}, DNSSECValidation: DNSSECValidationYes},
+		errMsg: `upstream DNS server "dns.example.com" not valid`,
+	}} {
+		c.Logf("test %d", i)
+		err := test.config.Validate()
+		if test.errMsg == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, jc.Satisfies, errors.IsNotValid)
+			c.Check(err, gc.ErrorMatches, test.errMsg)
+		}
+	}
+}
+
+func (s *configSuite) TestDNSConfig(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse(getConfigPath("upstream_dns"), http.StatusOK, `"8.8.8.8 8.8.4.4"`)
+	server.AddGetResponse(getConfigPath("dnssec_validation"), http.StatusOK, `"auto"`)
+
+	config, err := controller.DNSConfig()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(config, jc.DeepEquals, DNSConfig{
+		UpstreamServers:  []string{"8.8.8.8", "8.8.4.4"},
+		DNSSECValidation: DNSSECValidationAuto,
+	})
+}
+
+func (s *configSuite) TestSetDNSConfig(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	for i := 0; i < 2; i++ {
+		server.AddPostResponse(setConfigPath, http.StatusOK, "")
+	}
+
+	err := controller.SetDNSConfig(DNSConfig{
+		UpstreamServers:  []string{"8.8.8.8", "8.8.4.4"},
+		DNSSECValidation: DNSSECValidationNo,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	checkSetConfig(c, server, map[string]string{
+		"upstream_dns":      "8.8.8.8 8.8.4.4",
+		"dnssec_validation": "no",
+	})
+}
+
+func (s *configSuite) TestConfigureDNS(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	for i := 0; i < 2; i++ {
+		server.AddPostResponse(setConfigPath, http.StatusOK, "")
+	}
+	server.AddGetResponse(domainsPath, http.StatusOK, domainsResponse)
+	server.AddPostResponse("/api/2.0/domains/0/?op=set_default", http.StatusOK, "{}")
+
+	err := controller.ConfigureDNS(ConfigureDNSArgs{
+		DefaultDomain: "maas",
+		DNS:           DNSConfig{UpstreamServers: []string{"8.8.8.8"}, DNSSECValidation: DNSSECValidationAuto},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	requests := server.LastNRequests(4)
+	c.Assert(requests[2].URL.String(), gc.Equals, domainsPath)
+	c.Assert(requests[3].URL.String(), gc.Equals, "/api/2.0/domains/0/?op=set_default")
+}
+
+func (s *configSuite) TestConfigureDNSValidates(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.ResetRequests()
+	err := controller.ConfigureDNS(ConfigureDNSArgs{
+		DefaultDomain: "maas",
+		DNS:           DNSConfig{DNSSECValidation: "maybe"},
+	})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(err, gc.ErrorMatches, `DNS: DNSSEC validation "maybe" not valid`)
+	c.Assert(server.RequestCount(), gc.Equals, 0)
+}
+
+func (*configSuite) TestStorageLayouts(c *gc.C) {
+	c.Assert(StorageLayouts(), jc.DeepEquals, []string{"bcache", "blank", "flat", "lvm"})
+}
+
+func (s *configSuite) TestSetDefaultStorageLayout(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse(setConfigPath, http.StatusOK, "")
+
+	err := controller.SetDefaultStorageLayout(StorageLayoutLVM)
+	c.Assert(err, jc.ErrorIsNil)
+	checkSetConfig(c, server, map[string]string{"default_storage_layout": "lvm"})
+}
+
+func (s *configSuite) TestSetDefaultStorageLayoutValidates(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.ResetRequests()
+	err := controller.SetDefaultStorageLayout("zfs")
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(err, gc.ErrorMatches, `storage layout "zfs" not valid`)
+	c.Assert(server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *configSuite) TestSetDefaultStorageLayoutForbidden(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse(setConfigPath, http.StatusForbidden, "admins only")
+
+	err := controller.SetDefaultStorageLayout(StorageLayoutFlat)
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+	c.Assert(err, gc.ErrorMatches, "setting default_storage_layout: admins only")
+}
+
+func (s *configSuite) TestSetDefaultMinHWEKernel(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	for _, kernel := range []string{"hwe-16.04", "ga-18.04", "hwe-16.04-edge", "hwe-x", ""} {
+		server.AddPostResponse(setConfigPath, http.StatusOK, "")
+		err := controller.SetDefaultMinHWEKernel(kernel)
+		c.Assert(err, jc.ErrorIsNil)
+		checkSetConfig(c, server, map[string]string{"default_min_hwe_kernel": kernel})
+	}
+}
+
+func (s *configSuite) TestSetDefaultMinHWEKernelValidates(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.ResetRequests()
+	for _, kernel := range []string{"16.04", "hwe 16.04", "hwe-", "linux-generic"} {
+		err := controller.SetDefaultMinHWEKernel(kernel)
+		c.Check(err, jc.Satisfies, errors.IsNotValid)
+	}
+	c.Assert(server.RequestCount(), gc.Equals, 0)
+}
+
+// checkSetConfig checks the last requests made to the server set the
+// configuration settings to the values.
+func checkSetConfig(c *gc.C, server *SimpleTestServer, expected map[string]string) {
+	values := make(map[string]string)
+	for _, request := range server.LastNRequests(len(expected)) {
+		c.Assert(request.URL.String(), gc.Equals, setConfigPath)
+		values[request.PostForm.Get("name")] = request.PostForm.Get("value")
+	}
+	c.Assert(values, jc.DeepEquals, expected)
+}
@@ -0,0 +1,165 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/juju/errors"
+)
+
+// ConnectionProblem is the kind of failure diagnosed by
+// NewControllerFromEnv.
+type ConnectionProblem string
+
+const (
+	// ProblemConfig is a missing or malformed setting in the environment.
+	ProblemConfig ConnectionProblem = "config"
+	// ProblemDNS is a failure to resolve the host of the MAAS controller.
+	ProblemDNS ConnectionProblem = "dns"
+	// ProblemNetwork is a failure to connect to the MAAS controller.
+	ProblemNetwork ConnectionProblem = "network"
+	// ProblemTLS is a failure to set up or verify TLS with the MAAS
+	// controller.
+	ProblemTLS ConnectionProblem = "tls"
+	// ProblemAuth is the MAAS controller rejecting the API key.
+	ProblemAuth ConnectionProblem = "auth"
+	// ProblemVersion is the MAAS controller not serving a supported API
+	// version.
+	ProblemVersion ConnectionProblem = "version"
+	// ProblemUnknown is any other failure.
+	ProblemUnknown ConnectionProblem = "unknown"
+)
+
+// connectionHints are the suggestions given with each problem.
+var connectionHints = map[ConnectionProblem]string{
+	ProblemConfig:  fmt.Sprintf("check %s and %s", URLEnvVar, APIKeyEnvVar),
+	ProblemDNS:     fmt.Sprintf("check the host in %s and the DNS configuration", URLEnvVar),
+	ProblemNetwork: fmt.Sprintf("check %s and that the MAAS region controller is running", URLEnvVar),
+	ProblemTLS:     fmt.Sprintf("check %s names the CA certificate of the MAAS controller", CACertEnvVar),
+	ProblemAuth:    fmt.Sprintf("check %s is the API key of a MAAS user", APIKeyEnvVar),
+	ProblemVersion: fmt.Sprintf("check %s is the URL of the MAAS API, such as http://maas:5240/MAAS/", URLEnvVar),
+}
+
+// ConnectionError is returned by NewControllerFromEnv when it can't connect
+// to the MAAS controller. The message says what failed and what to check,
+// and the error it wraps is available with errors.Underlying.
+type ConnectionError struct {
+	errors.Err
+
+	// Problem is the kind of failure.
+	Problem ConnectionProblem
+	// URL is the URL of the MAAS controller connected to.
+	URL string
+}
+
+func newConnectionError(problem ConnectionProblem, baseURL string, err error) error {
+	message := fmt.Sprintf("cannot connect to MAAS at %q (%s problem): %v", baseURL, problem, err)
+	if hint := connectionHints[problem]; hint != "" {
+		message += "; " + hint
+	}
+	cerr := &ConnectionError{Err: errors.NewErr("%s", message), Problem: problem, URL: baseURL}
+	cerr.SetLocation(1)
+	return errors.Wrap(err, cerr)
+}
+
+// IsConnectionError returns true if err is a ConnectionError.
+func IsConnectionError(err error) bool {
+	_, ok := errors.Cause(err).(*ConnectionError)
+	return ok
+}
+
+// NewControllerFromEnv creates a Controller with the ControllerArgs from
+// LoadControllerArgs, that is for MAAS_URL and MAAS_API_KEY or the single
+// profile of the MAAS command line client, and pings it. If MAAS_CA_CERT
+// is set, the CA certificates in the PEM file it names are trusted rather
+// than the system's. Returns
+//  - ConnectionError, whose Problem tells apart the settings, DNS, network,
+//    TLS, authentication and API version failures
+func NewControllerFromEnv() (Controller, error) {
+	args, err := LoadControllerArgs("")
+	if err != nil {
+		return nil, newConnectionError(ProblemConfig, args.BaseURL, err)
+	}
+	if caPath := os.Getenv(CACertEnvVar); caPath != "" {
+		httpClient, err := caHTTPClient(caPath)
+		if err != nil {
+			return nil, newConnectionError(ProblemTLS, args.BaseURL, err)
+		}
+		args.HTTPClient = httpClient
+	}
+	controller, err := NewController(args)
+	if err != nil {
+		return nil, diagnoseConnection(args.BaseURL, err)
+	}
+	if _, err := controller.Ping(context.Background()); err != nil {
+		return nil, diagnoseConnection(args.BaseURL, err)
+	}
+	return controller, nil
+}
+
+// caHTTPClient returns an HTTP client that trusts only the CA certificates
+// in the PEM file.
+func caHTTPClient(path string) (*http.Client, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Annotatef(err, "reading %s", CACertEnvVar)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, errors.NotValidf("%s %q without PEM certificates", CACertEnvVar, path)
+	}
+	return &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{RootCAs: pool},
+	}}, nil
+}
+
+// diagnoseConnection returns a ConnectionError for the failure to connect
+// to the MAAS controller.
+func diagnoseConnection(baseURL string, err error) error {
+	switch {
+	case IsPermissionError(err):
+		return newConnectionError(ProblemAuth, baseURL, err)
+	case errors.IsNotValid(err):
+		// The API key couldn't be parsed.
+		return newConnectionError(ProblemConfig, baseURL, err)
+	case IsUnsupportedVersionError(err):
+		return newConnectionError(ProblemVersion, baseURL, err)
+	}
+	// The network errors are wrapped by this package with Underlying, and
+	// by the standard library, such as in *url.Error, with Unwrap. A
+	// failure to dial is only a network problem if it didn't come from
+	// resolving the host.
+	problem := ProblemUnknown
+	for cause := err; cause != nil; {
+		switch cause := cause.(type) {
+		case *net.DNSError:
+			return newConnectionError(ProblemDNS, baseURL, err)
+		case x509.UnknownAuthorityError, x509.HostnameError, x509.CertificateInvalidError,
+			tls.RecordHeaderError:
+			return newConnectionError(ProblemTLS, baseURL, err)
+		case *net.OpError:
+			if cause.Op == "dial" {
+				problem = ProblemNetwork
+			}
+		}
+		switch wrapper := cause.(type) {
+		case interface{ Underlying() error }:
+			cause = wrapper.Underlying()
+		case interface{ Unwrap() error }:
+			cause = wrapper.Unwrap()
+		default:
+			cause = nil
+		}
+	}
+	return newConnectionError(problem, baseURL, err)
+}
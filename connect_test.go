@@ -0,0 +1,184 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+func (s *controllerSuite) patchControllerEnv(baseURL, apiKey, caPath string) {
+	s.PatchEnvironment(URLEnvVar, baseURL)
+	s.PatchEnvironment(APIKeyEnvVar, apiKey)
+	s.PatchEnvironment(CACertEnvVar, caPath)
+}
+
+func checkConnectionProblem(c *gc.C, err error, problem ConnectionProblem) {
+	c.Assert(err, jc.Satisfies, IsConnectionError)
+	c.Check(errors.Cause(err).(*ConnectionError).Problem, gc.Equals, problem)
+}
+
+func (s *controllerSuite) TestNewControllerFromEnv(c *gc.C) {
+	// The version and credentials are checked again by the ping.
+	s.server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	s.server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	s.patchControllerEnv(s.server.URL, "fake:as:key", "")
+
+	controller, err := NewControllerFromEnv()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(controller, gc.NotNil)
+	c.Assert(s.server.LastRequest().URL.String(), gc.Equals, "/api/2.0/users/?op=whoami")
+}
+
+func (s *controllerSuite) TestNewControllerFromEnvPingFails(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	s.server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusForbidden, "revoked")
+	s.patchControllerEnv(s.server.URL, "fake:as:key", "")
+
+	_, err := NewControllerFromEnv()
+	checkConnectionProblem(c, err, ProblemAuth)
+}
+
+func (s *controllerSuite) TestNewControllerFromEnvMissing(c *gc.C) {
+	s.patchControllerEnv(s.server.URL, "", "")
+	_, err := NewControllerFromEnv()
+	checkConnectionProblem(c, err, ProblemConfig)
+	c.Assert(err, gc.ErrorMatches, `cannot connect to MAAS at ".*" \(config problem\): MAAS_URL set without MAAS_API_KEY not valid; check MAAS_URL and MAAS_API_KEY`)
+}
+
+func (s *controllerSuite) TestNewControllerFromEnvCLIProfile(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	s.server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	s.patchControllerEnv("", "", "")
+	s.PatchValue(&listCLIProfiles, func() ([]byte, error) {
+		return []byte("admin " + s.server.URL + "/api/2.0/ fake:as:key\n"), nil
+	})
+
+	controller, err := NewControllerFromEnv()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(controller, gc.NotNil)
+}
+
+func (s *controllerSuite) TestNewControllerFromEnvNoCLIProfile(c *gc.C) {
+	s.patchControllerEnv("", "", "")
+	s.PatchValue(&listCLIProfiles, func() ([]byte, error) {
+		return nil, errors.New("maas: command not found")
+	})
+
+	_, err := NewControllerFromEnv()
+	checkConnectionProblem(c, err, ProblemConfig)
+}
+
+func (s *controllerSuite) TestNewControllerFromEnvInvalidKey(c *gc.C) {
+	s.patchControllerEnv(s.server.URL, "invalid", "")
+	_, err := NewControllerFromEnv()
+	checkConnectionProblem(c, err, ProblemConfig)
+}
+
+func (s *controllerSuite) TestNewControllerFromEnvBadCreds(c *gc.C) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusUnauthorized, "naughty")
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.Start()
+	defer server.Close()
+	s.patchControllerEnv(server.URL, "fake:as:key", "")
+
+	_, err := NewControllerFromEnv()
+	checkConnectionProblem(c, err, ProblemAuth)
+	c.Assert(err, gc.ErrorMatches, `.*; check MAAS_API_KEY is the API key of a MAAS user`)
+}
+
+func (s *controllerSuite) TestNewControllerFromEnvNoSupport(c *gc.C) {
+	server := NewSimpleServer()
+	server.Start()
+	defer server.Close()
+	s.patchControllerEnv(server.URL, "fake:as:key", "")
+
+	_, err := NewControllerFromEnv()
+	checkConnectionProblem(c, err, ProblemVersion)
+}
+
+func (s *controllerSuite) TestNewControllerFromEnvRefused(c *gc.C) {
+	server := NewSimpleServer()
+	server.Start()
+	server.Close()
+	s.patchControllerEnv(server.URL, "fake:as:key", "")
+
+	_, err := NewControllerFromEnv()
+	checkConnectionProblem(c, err, ProblemNetwork)
+}
+
+func (s *controllerSuite) startTLSServer(c *gc.C) (*SimpleTestServer, string) {
+	server := NewSimpleServer()
+	for i := 0; i < 2; i++ {
+		server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+		server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	}
+	server.StartTLS()
+	s.AddCleanup(func(*gc.C) { server.Close() })
+
+	caPath := filepath.Join(c.MkDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	err := ioutil.WriteFile(caPath, caPEM, 0644)
+	c.Assert(err, jc.ErrorIsNil)
+	return server, caPath
+}
+
+func (s *controllerSuite) TestNewControllerFromEnvCACert(c *gc.C) {
+	server, caPath := s.startTLSServer(c)
+	s.patchControllerEnv(server.URL, "fake:as:key", caPath)
+
+	envController, err := NewControllerFromEnv()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(envController, gc.NotNil)
+	// The HTTP client trusting the CA is made once, and used for all the
+	// requests.
+	c.Assert(envController.(*controller).client.HTTPClient, gc.NotNil)
+}
+
+func (s *controllerSuite) TestNewControllerFromEnvUntrusted(c *gc.C) {
+	server, _ := s.startTLSServer(c)
+	s.patchControllerEnv(server.URL, "fake:as:key", "")
+
+	_, err := NewControllerFromEnv()
+	checkConnectionProblem(c, err, ProblemTLS)
+}
+
+func (s *controllerSuite) TestNewControllerFromEnvBadCACert(c *gc.C) {
+	caPath := filepath.Join(c.MkDir(), "ca.pem")
+	err := ioutil.WriteFile(caPath, []byte("not a certificate"), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+	s.patchControllerEnv(s.server.URL, "fake:as:key", caPath)
+
+	_, err = NewControllerFromEnv()
+	checkConnectionProblem(c, err, ProblemTLS)
+
+	s.patchControllerEnv(s.server.URL, "fake:as:key", caPath+".missing")
+	_, err = NewControllerFromEnv()
+	checkConnectionProblem(c, err, ProblemTLS)
+}
+
+func (*errorTypesSuite) TestDiagnoseConnectionDNS(c *gc.C) {
+	lookup := &url.Error{Op: "Get", URL: "http://maas/", Err: &net.OpError{
+		Op:  "dial",
+		Err: &net.DNSError{Err: "no such host", Name: "maas"},
+	}}
+	err := diagnoseConnection("http://maas/MAAS/", NewUnexpectedError(errors.Trace(lookup)))
+	checkConnectionProblem(c, err, ProblemDNS)
+	c.Assert(errors.Cause(err).(*ConnectionError).URL, gc.Equals, "http://maas/MAAS/")
+}
+
+func (*errorTypesSuite) TestDiagnoseConnectionUnknown(c *gc.C) {
+	err := diagnoseConnection("http://maas/MAAS/", errors.New("wat"))
+	checkConnectionProblem(c, err, ProblemUnknown)
+	c.Assert(err, gc.ErrorMatches, `cannot connect to MAAS at "http://maas/MAAS/" \(unknown problem\): wat`)
+}
@@ -4,19 +4,29 @@
 package gomaasapi
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"path"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/juju/errors"
 	"github.com/juju/loggo"
 	"github.com/juju/schema"
+	"github.com/juju/utils/clock"
 	"github.com/juju/utils/set"
 	"github.com/juju/version"
 )
@@ -33,15 +43,142 @@ var (
 	// the deserialization functions.
 	twoDotOh = version.Number{Major: 2, Minor: 0}
 
-	// Current request number. Informational only for logging.
+	// MAAS continues to serve the 2.0 API while adding fields to the
+	// responses with each server release. These versions refer to the
+	// server release that introduced the change.
+	twoDotThree = version.Number{Major: 2, Minor: 3}
+	twoDotFour  = version.Number{Major: 2, Minor: 4}
+	twoDotFive  = version.Number{Major: 2, Minor: 5}
+	twoDotNine  = version.Number{Major: 2, Minor: 9}
+
+	// Current request number, which is part of the request ID.
 	requestNumber int64
+
+	// requestIDPrefix distinguishes the request IDs of this process from
+	// those of the other clients of a MAAS controller.
+	requestIDPrefix = newRequestIDPrefix()
 )
 
+// DefaultRequestIDHeader is the header that the request ID is sent in if
+// ControllerArgs.RequestIDHeader isn't set.
+const DefaultRequestIDHeader = "X-Request-Id"
+
 // ControllerArgs is an argument struct for passing the required parameters
 // to the NewController method.
 type ControllerArgs struct {
 	BaseURL string
 	APIKey  string
+
+	// CredentialProvider is optional. If specified, it is asked for a new
+	// API key whenever the MAAS controller rejects the current one, and the
+	// rejected request is retried once with the new key. If APIKey is empty
+	// the initial key is also obtained from the CredentialProvider.
+	CredentialProvider CredentialProvider
+
+	// MacaroonDischarger is optional. If specified, requests are
+	// authenticated with the macaroons that MAAS issues, discharged by the
+	// MacaroonDischarger, rather than with an API key. This is needed for
+	// MAAS controllers that delegate authentication to Candid. APIKey and
+	// CredentialProvider must not be set with it.
+	MacaroonDischarger MacaroonDischarger
+
+	// SignatureMethod is optional, and defaults to PlainTextSignatureMethod.
+	SignatureMethod OAuthSignatureMethod
+
+	// StrictDecoding is optional. If set, reading an entity returns a
+	// DeserializationError when the MAAS controller's response has fields
+	// that this package doesn't read, or is missing fields that would
+	// otherwise be given default values. It is intended for detecting drift
+	// between a MAAS version and this package, so expect failures against
+	// real controllers, which return many fields that aren't read.
+	StrictDecoding bool
+
+	// ValidateAddresses is optional. If set, reading an entity returns a
+	// DeserializationError when any of its IP addresses or CIDRs can't be
+	// parsed. Otherwise those values are left as the zero netip.Addr or
+	// netip.Prefix by the typed accessors, such as Machine.IPAddrs.
+	ValidateAddresses bool
+
+	// RateLimits is optional. It limits the rate of requests to each family
+	// of endpoints, named by the first element of the API path such as
+	// "machines", "nodes" or "subnets". The limit for OtherEndpoints applies
+	// to the families without a limit of their own. Requests over the limit
+	// are delayed rather than failed.
+	RateLimits map[string]RateLimit
+
+	// Logger is optional, and defaults to the package's loggo logger. OAuth
+	// credentials are redacted from the messages before they are logged.
+	Logger Logger
+
+	// RequestIDHeader is optional, and defaults to DefaultRequestIDHeader.
+	// Each request is sent with a new ID in this header, so that it can be
+	// found in the MAAS controller's logs. The ID is also logged, and is
+	// recorded in the errors returned for failed requests, see RequestID.
+	RequestIDHeader string
+
+	// UserAgent is optional. If set, each request is sent with it in the
+	// User-Agent header, such as "my-tool/1.2", so that the MAAS
+	// controller's logs and any proxies can attribute the traffic.
+	// Otherwise Go's default User-Agent is sent.
+	UserAgent string
+
+	// DisableCompression is optional. By default responses are requested
+	// gzip or deflate compressed, which greatly reduces the size of large
+	// listings such as Machines. If set, responses are requested
+	// uncompressed.
+	DisableCompression bool
+
+	// RetryUnsafeRequests is optional. By default only idempotent requests
+	// are retried when the MAAS controller asks for them to be, so that an
+	// operation such as allocating a machine isn't repeated if the first
+	// attempt succeeded. If set, POST requests are retried too.
+	RetryUnsafeRequests bool
+
+	// IdempotencyKeyHeader is optional, see Client.IdempotencyKeyHeader.
+	IdempotencyKeyHeader string
+
+	// HTTPClient is optional, see Client.HTTPClient. NewControllerFromEnv
+	// sets it to trust the CA certificates named by CACertEnvVar.
+	HTTPClient *http.Client
+
+	// FailoverURLs is optional. It lists the base URLs of the other region
+	// controllers of the MAAS at BaseURL, such as
+	// "http://region-2:5240/MAAS/", which must have the same path as
+	// BaseURL. Requests are sent to BaseURL, and fail over to each of the
+	// others in turn when the previous can't be reached. A region that
+	// can't be reached is passed over for the next 30 seconds. Requests
+	// that aren't idempotent only fail over if they couldn't connect.
+	FailoverURLs []string
+
+	// ReadURLs is optional. It lists the base URLs of region controllers,
+	// with the same path as BaseURL, that GET requests are sent to in
+	// preference to BaseURL and FailoverURLs, such as those in front of
+	// read-only database replicas. The other requests are never sent to
+	// them.
+	ReadURLs []string
+
+	// DefaultAgentName is optional. If set, machines are allocated with it
+	// as their agent name when AllocateMachineArgs.AgentName is empty, and
+	// it is the agent of MachinesOwnedByAgent when none is given. This lets
+	// several automation systems that share a MAAS identify their own
+	// machines.
+	DefaultAgentName string
+
+	// Plan is optional. If set, the requests that would change the MAAS,
+	// the POST, PUT and DELETE requests, are recorded in it rather than
+	// sent, and the operations making them return an error satisfying
+	// IsPlannedError. Reads are still made.
+	Plan *ActionPlan
+}
+
+// CredentialProvider supplies the API key used to authenticate with the MAAS
+// controller. It allows long running controllers to pick up rotated keys,
+// from a file or a secret store for example, without being recreated.
+type CredentialProvider interface {
+	// GetAPIKey returns the current API key, which has the same
+	// "<consumer key>:<token key>:<token secret>" form as
+	// ControllerArgs.APIKey.
+	GetAPIKey(ctx context.Context) (string, error)
 }
 
 // NewController creates an authenticated client to the MAAS API, and
@@ -53,12 +190,28 @@ type ControllerArgs struct {
 // If the APIKey is not valid, a NotValid error is returned.
 // If the credentials are incorrect, a PermissionError is returned.
 func NewController(args ControllerArgs) (Controller, error) {
+	for family, limit := range args.RateLimits {
+		if err := limit.Validate(); err != nil {
+			return nil, errors.Annotatef(err, "rate limit for %q", family)
+		}
+	}
+	if args.MacaroonDischarger != nil && (args.APIKey != "" || args.CredentialProvider != nil) {
+		return nil, errors.NotValidf("MacaroonDischarger with APIKey or CredentialProvider")
+	}
+	if args.APIKey == "" && args.CredentialProvider != nil {
+		apiKey, err := args.CredentialProvider.GetAPIKey(context.Background())
+		if err != nil {
+			return nil, errors.Annotate(err, "getting API key")
+		}
+		args.APIKey = apiKey
+	}
 	base, apiVersion, includesVersion := SplitVersionedURL(args.BaseURL)
 	if includesVersion {
 		if !supportedVersion(apiVersion) {
 			return nil, NewUnsupportedVersionError("version %s", apiVersion)
 		}
-		return newControllerWithVersion(base, apiVersion, args.APIKey)
+		args.BaseURL = base
+		return newControllerWithVersion(apiVersion, args)
 	}
 	return newControllerUnknownVersion(args)
 }
@@ -72,13 +225,19 @@ func supportedVersion(value string) bool {
 	return false
 }
 
-func newControllerWithVersion(baseURL, apiVersion, apiKey string) (Controller, error) {
+func newControllerWithVersion(apiVersion string, args ControllerArgs) (Controller, error) {
 	major, minor, err := version.ParseMajorMinor(apiVersion)
 	// We should not get an error here. See the test.
 	if err != nil {
 		return nil, errors.Errorf("bad version defined in supported versions: %q", apiVersion)
 	}
-	client, err := NewAuthenticatedClient(AddAPIVersionToURL(baseURL, apiVersion), apiKey)
+	var client *Client
+	if args.MacaroonDischarger != nil {
+		client, err = NewMacaroonClient(AddAPIVersionToURL(args.BaseURL, apiVersion), args.MacaroonDischarger)
+	} else {
+		client, err = NewAuthenticatedClientWithSignatureMethod(
+			AddAPIVersionToURL(args.BaseURL, apiVersion), args.APIKey, args.SignatureMethod)
+	}
 	if err != nil {
 		// If the credentials aren't valid, return now.
 		if errors.IsNotValid(err) {
@@ -88,31 +247,109 @@ func newControllerWithVersion(baseURL, apiVersion, apiKey string) (Controller, e
 		// is an unexpected error and return now.
 		return nil, NewUnexpectedError(err)
 	}
+	client.UserAgent = args.UserAgent
+	client.DisableCompression = args.DisableCompression
+	client.RetryUnsafeRequests = args.RetryUnsafeRequests
+	client.IdempotencyKeyHeader = args.IdempotencyKeyHeader
+	client.HTTPClient = args.HTTPClient
+	client.endpoints, err = newEndpoints(clock.WallClock, args.BaseURL, args.FailoverURLs, args.ReadURLs)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
 	controllerVersion := version.Number{
 		Major: major,
 		Minor: minor,
 	}
-	controller := &controller{client: client, apiVersion: controllerVersion}
-	controller.capabilities, err = controller.readAPIVersionInfo()
+	requestIDHeader := args.RequestIDHeader
+	if requestIDHeader == "" {
+		requestIDHeader = DefaultRequestIDHeader
+	}
+	controller := &controller{
+		client:          client,
+		apiVersion:      controllerVersion,
+		apiVersionName:  apiVersion,
+		signatureMethod: args.SignatureMethod,
+		strictDecoding:  args.StrictDecoding,
+
+		validateAddresses: args.ValidateAddresses,
+		rateLimiter:       newRateLimiter(clock.WallClock, args.RateLimits),
+		logger:            newRedactingLogger(args.Logger),
+		requestIDHeader:   requestIDHeader,
+		defaultAgentName:  args.DefaultAgentName,
+		actionPlan:        args.Plan,
+	}
+	if args.CredentialProvider != nil {
+		client.Signer = &refreshableSigner{signer: client.Signer}
+		controller.credentials = args.CredentialProvider
+	}
+	serverVersion, err := controller.readAPIVersionInfo(context.Background())
 	if err != nil {
-		logger.Debugf("read version failed: %#v", err)
+		controller.logger.Debugf("read version failed: %#v", err)
 		return nil, errors.Trace(err)
 	}
+	controller.capabilities = serverVersion.Capabilities
+	controller.serverVersion = serverVersion
+	controller.release = version.Number{Major: serverVersion.Version.Major, Minor: serverVersion.Version.Minor}
 
-	if err := controller.checkCreds(); err != nil {
+	username, err := controller.whoami(context.Background())
+	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	controller.username = username
 	return controller, nil
 }
 
+// negotiatedVersions caches the API version negotiated with each MAAS
+// controller, keyed by base URL, so that controllers created later for the
+// same URL don't probe the versions it doesn't support.
+var negotiatedVersions = struct {
+	sync.Mutex
+	versions map[string]string
+}{versions: make(map[string]string)}
+
+func cachedAPIVersion(baseURL string) (string, bool) {
+	negotiatedVersions.Lock()
+	defer negotiatedVersions.Unlock()
+	apiVersion, ok := negotiatedVersions.versions[baseURL]
+	return apiVersion, ok
+}
+
+func cacheAPIVersion(baseURL, apiVersion string) {
+	negotiatedVersions.Lock()
+	defer negotiatedVersions.Unlock()
+	negotiatedVersions.versions[baseURL] = apiVersion
+}
+
+func forgetAPIVersion(baseURL string) {
+	negotiatedVersions.Lock()
+	defer negotiatedVersions.Unlock()
+	delete(negotiatedVersions.versions, baseURL)
+}
+
 func newControllerUnknownVersion(args ControllerArgs) (Controller, error) {
-	// For now we don't need to test multiple versions. It is expected that at
-	// some time in the future, we will try the most up to date version and then
-	// work our way backwards.
+	// The version negotiated before is tried first, and forgotten if the
+	// controller no longer serves it, as after a MAAS upgrade.
+	cached, ok := cachedAPIVersion(args.BaseURL)
+	if ok {
+		controller, err := newControllerWithVersion(cached, args)
+		switch {
+		case err == nil:
+			return controller, nil
+		case IsUnsupportedVersionError(err):
+			forgetAPIVersion(args.BaseURL)
+		default:
+			return nil, errors.Trace(err)
+		}
+	}
+	// The versions are tried from the most desirable, the highest, down.
 	for _, apiVersion := range supportedAPIVersions {
-		controller, err := newControllerWithVersion(args.BaseURL, apiVersion, args.APIKey)
+		if ok && apiVersion == cached {
+			continue
+		}
+		controller, err := newControllerWithVersion(apiVersion, args)
 		switch {
 		case err == nil:
+			cacheAPIVersion(args.BaseURL, apiVersion)
 			return controller, nil
 		case IsUnsupportedVersionError(err):
 			// This will only come back from readAPIVersionInfo for 410/404.
@@ -125,10 +362,65 @@ func newControllerUnknownVersion(args ControllerArgs) (Controller, error) {
 	return nil, NewUnsupportedVersionError("controller at %s does not support any of %s", args.BaseURL, supportedAPIVersions)
 }
 
+// controller implements Controller.
+var _ Controller = (*controller)(nil)
+
 type controller struct {
-	client       *Client
-	apiVersion   version.Number
-	capabilities set.Strings
+	client     *Client
+	apiVersion version.Number
+	// apiVersionName is the API version in the URLs, such as "2.0".
+	apiVersionName string
+	// release is the major and minor version of the MAAS server, which is
+	// zero if the server doesn't report it.
+	release version.Number
+
+	capabilities  set.Strings
+	serverVersion ServerVersion
+	// username is the user the controller is authenticated as, see whoami.
+	username string
+
+	// credentials is only set if a CredentialProvider was specified,
+	// in which case the client's Signer is a *refreshableSigner.
+	credentials     CredentialProvider
+	signatureMethod OAuthSignatureMethod
+	strictDecoding  bool
+
+	// validateAddresses is set from ControllerArgs.ValidateAddresses.
+	validateAddresses bool
+	// rateLimiter is nil unless ControllerArgs.RateLimits was specified.
+	rateLimiter *rateLimiter
+	// logger writes to ControllerArgs.Logger.
+	logger redactingLogger
+	// requestIDHeader is the header that request IDs are sent in.
+	requestIDHeader string
+	// defaultAgentName is set from ControllerArgs.DefaultAgentName.
+	defaultAgentName string
+	// actionPlan is nil unless ControllerArgs.Plan was specified.
+	actionPlan *ActionPlan
+
+	// subnets caches the controller's subnets for the subnet lookup
+	// helpers, see cachedSubnets. They were read at subnetsRead.
+	subnetsMutex sync.Mutex
+	subnets      []*subnet
+	subnetsRead  time.Time
+}
+
+// checkDecoded is called with the source and the result of reading an
+// entity, or a list of them. It checks the source with checkStrict if
+// strict decoding was asked for, and the addresses of the result with
+// checkAddresses if address validation was asked for.
+func (c *controller) checkDecoded(entity string, source, result interface{}) error {
+	if c.strictDecoding {
+		if err := checkStrict(entity, source); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if c.validateAddresses {
+		if err := checkAddresses(entity, result); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
 }
 
 // Capabilities implements Controller.
@@ -136,16 +428,87 @@ func (c *controller) Capabilities() set.Strings {
 	return c.capabilities
 }
 
+// ServerVersion describes the release of MAAS that a controller is running,
+// as reported by its version endpoint.
+type ServerVersion struct {
+	// Version is the MAAS release, such as 2.9.2. It is version.Zero if the
+	// release can't be parsed, as for development servers, which report
+	// "unknown".
+	Version version.Number
+
+	// Subversion is the revision of the release, which is often empty.
+	Subversion string
+
+	Capabilities set.Strings
+}
+
+// HasCapability implements Controller.
+func (c *controller) HasCapability(capability Capability) bool {
+	return c.capabilities.Contains(string(capability))
+}
+
+// requireCapability returns a NotSupported error if the MAAS controller
+// doesn't have the capability.
+func (c *controller) requireCapability(capability Capability) error {
+	if !c.HasCapability(capability) {
+		return errors.NotSupportedf("MAAS controller without the %q capability", capability)
+	}
+	return nil
+}
+
+// requireRelease returns a NotSupported error if the MAAS controller is
+// older than the release that introduced the feature. Development servers,
+// whose release is unknown, are assumed to have it.
+func (c *controller) requireRelease(major, minor int, feature string) error {
+	if !c.hasRelease(major, minor) {
+		return errors.NotSupportedf("%s before MAAS %d.%d", feature, major, minor)
+	}
+	return nil
+}
+
+// hasRelease reports whether the MAAS controller is at least the release,
+// which development servers, that don't report their release, are taken
+// to be.
+func (c *controller) hasRelease(major, minor int) bool {
+	if c.release == version.Zero {
+		return true
+	}
+	return c.release.Compare(version.Number{Major: major, Minor: minor}) >= 0
+}
+
+// schemaVersion returns the version that responses are deserialized with,
+// which is the server release when it is newer than the API version, as
+// the release is what determines the fields returned.
+func (c *controller) schemaVersion() version.Number {
+	if c.release.Compare(c.apiVersion) > 0 {
+		return c.release
+	}
+	return c.apiVersion
+}
+
+// ServerVersion implements Controller.
+func (c *controller) ServerVersion() ServerVersion {
+	return c.serverVersion
+}
+
+// APIVersion implements Controller.
+func (c *controller) APIVersion() string {
+	return c.apiVersionName
+}
+
 // BootResources implements Controller.
 func (c *controller) BootResources() ([]BootResource, error) {
 	source, err := c.get("boot-resources")
 	if err != nil {
-		return nil, NewUnexpectedError(err)
+		return nil, translateError(opEntity, err)
 	}
-	resources, err := readBootResources(c.apiVersion, source)
+	resources, err := readBootResources(c.schemaVersion(), source)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	if err = c.checkDecoded("boot resource", source, resources); err != nil {
+		return nil, errors.Trace(err)
+	}
 	var result []BootResource
 	for _, r := range resources {
 		result = append(result, r)
@@ -157,14 +520,18 @@ func (c *controller) BootResources() ([]BootResource, error) {
 func (c *controller) Fabrics() ([]Fabric, error) {
 	source, err := c.get("fabrics")
 	if err != nil {
-		return nil, NewUnexpectedError(err)
+		return nil, translateError(opEntity, err)
 	}
-	fabrics, err := readFabrics(c.apiVersion, source)
+	fabrics, err := readFabrics(c.schemaVersion(), source)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	if err = c.checkDecoded("fabric", source, fabrics); err != nil {
+		return nil, errors.Trace(err)
+	}
 	var result []Fabric
 	for _, f := range fabrics {
+		f.bind(c)
 		result = append(result, f)
 	}
 	return result, nil
@@ -174,29 +541,208 @@ func (c *controller) Fabrics() ([]Fabric, error) {
 func (c *controller) Spaces() ([]Space, error) {
 	source, err := c.get("spaces")
 	if err != nil {
-		return nil, NewUnexpectedError(err)
+		return nil, translateError(opEntity, err)
 	}
-	spaces, err := readSpaces(c.apiVersion, source)
+	spaces, err := readSpaces(c.schemaVersion(), source)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	if err = c.checkDecoded("space", source, spaces); err != nil {
+		return nil, errors.Trace(err)
+	}
 	var result []Space
 	for _, space := range spaces {
+		space.bind(c)
 		result = append(result, space)
 	}
 	return result, nil
 }
 
+// SpaceByName implements Controller.
+//
+// Returns a NoMatchError if there is no space with the name.
+func (c *controller) SpaceByName(name string) (Space, error) {
+	spaces, err := c.Spaces()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for _, s := range spaces {
+		if s.Name() == name {
+			return s, nil
+		}
+	}
+	return nil, NewNoMatchError(fmt.Sprintf("space %q not found", name))
+}
+
+// SubnetsInSpace implements Controller.
+func (c *controller) SubnetsInSpace(space string) ([]Subnet, error) {
+	result, err := c.findSubnets(func(s *subnet) bool {
+		return s.space == space
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return result, nil
+}
+
+// SubnetByCIDR implements Controller.
+//
+// Returns a NoMatchError if there is no subnet with the CIDR.
+func (c *controller) SubnetByCIDR(cidr string) (Subnet, error) {
+	result, err := c.findSubnets(func(s *subnet) bool {
+		return s.cidr == cidr
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if len(result) == 0 {
+		return nil, NewNoMatchError(fmt.Sprintf("subnet %q not found", cidr))
+	}
+	return result[0], nil
+}
+
+// CreateSubnetArgs is an argument struct for passing information into
+// CreateSubnet.
+type CreateSubnetArgs struct {
+	// CIDR is required, for example "2001:db8::/64".
+	CIDR string
+	Name string
+	// VLAN is optional, and MAAS uses the default VLAN of the default
+	// fabric if it is nil.
+	VLAN       VLAN
+	Space      string
+	Gateway    string
+	DNSServers []string
+	// RDNSMode is optional, and MAAS uses RDNSModeRFC2317 if it is nil.
+	RDNSMode *RDNSMode
+}
+
+func (a *CreateSubnetArgs) vlanID() int {
+	if a.VLAN == nil {
+		return 0
+	}
+	return a.VLAN.ID()
+}
+
+// Validate checks the CIDR, that the gateway is in the same address family
+// as the CIDR, and the reverse DNS mode.
+func (a *CreateSubnetArgs) Validate() error {
+	prefix, err := parsePrefix(a.CIDR)
+	if err != nil {
+		return errors.NotValidf("CIDR %q", a.CIDR)
+	}
+	gateway, err := parseOptionalAddr(a.Gateway)
+	if err != nil || gateway.IsValid() && gateway.Is6() != prefix.Addr().Is6() {
+		return errors.NotValidf("Gateway %q for CIDR %q", a.Gateway, a.CIDR)
+	}
+	return validateRDNSMode(a.RDNSMode)
+}
+
+// CreateSubnet implements Controller.
+//
+// Returns
+//  - NotValid error if the args are not valid
+//  - BadRequestError if the server rejects the subnet
+//  - PermissionError if the user does not have permission to create subnets
+func (c *controller) CreateSubnet(args CreateSubnetArgs) (Subnet, error) {
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	params := NewURLParams()
+	params.Values.Add("cidr", args.CIDR)
+	params.MaybeAddInt("vlan", args.vlanID())
+	params.MaybeAdd("space", args.Space)
+	addSubnetParams(params, args.Name, args.Gateway, args.DNSServers, args.RDNSMode)
+	source, err := c.post("subnets", "", params.Values)
+	if err != nil {
+		return nil, translateError(opEntity, err)
+	}
+	subnet, err := readSubnet(c.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err = c.checkDecoded("subnet", source, subnet); err != nil {
+		return nil, errors.Trace(err)
+	}
+	subnet.bind(c)
+	c.invalidateSubnets()
+	return subnet, nil
+}
+
+// subnetCacheTTL is how long the subnets cached for the lookup helpers are
+// used for, so that changes made by other clients are seen.
+const subnetCacheTTL = time.Minute
+
+// findSubnets returns the cached subnets that match. If none match, the
+// cache is refreshed in case the subnets have changed since they were read.
+func (c *controller) findSubnets(match func(*subnet) bool) ([]Subnet, error) {
+	for _, refresh := range []bool{false, true} {
+		subnets, err := c.cachedSubnets(refresh)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		var result []Subnet
+		for _, s := range subnets {
+			if match(s) {
+				result = append(result, s)
+			}
+		}
+		if len(result) > 0 {
+			return result, nil
+		}
+	}
+	return nil, nil
+}
+
+// cachedSubnets returns all of the controller's subnets, only reading them
+// from the controller if they haven't been read in the last
+// subnetCacheTTL, or if refresh is true.
+func (c *controller) cachedSubnets(refresh bool) ([]*subnet, error) {
+	c.subnetsMutex.Lock()
+	defer c.subnetsMutex.Unlock()
+	if c.subnets != nil && !refresh && time.Since(c.subnetsRead) < subnetCacheTTL {
+		return c.subnets, nil
+	}
+	source, err := c.get("subnets")
+	if err != nil {
+		return nil, translateError(opEntity, err)
+	}
+	subnets, err := readSubnets(c.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err = c.checkDecoded("subnet", source, subnets); err != nil {
+		return nil, errors.Trace(err)
+	}
+	for _, s := range subnets {
+		s.bind(c)
+	}
+	c.subnets = subnets
+	c.subnetsRead = time.Now()
+	return subnets, nil
+}
+
+// invalidateSubnets drops the cached subnets, so that they are read again
+// the next time they are needed.
+func (c *controller) invalidateSubnets() {
+	c.subnetsMutex.Lock()
+	defer c.subnetsMutex.Unlock()
+	c.subnets = nil
+}
+
 // StaticRoutes implements Controller.
 func (c *controller) StaticRoutes() ([]StaticRoute, error) {
 	source, err := c.get("static-routes")
 	if err != nil {
-		return nil, NewUnexpectedError(err)
+		return nil, translateError(opEntity, err)
 	}
-	staticRoutes, err := readStaticRoutes(c.apiVersion, source)
+	staticRoutes, err := readStaticRoutes(c.schemaVersion(), source)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	if err = c.checkDecoded("static route", source, staticRoutes); err != nil {
+		return nil, errors.Trace(err)
+	}
 	var result []StaticRoute
 	for _, staticRoute := range staticRoutes {
 		result = append(result, staticRoute)
@@ -208,12 +754,16 @@ func (c *controller) StaticRoutes() ([]StaticRoute, error) {
 func (c *controller) Zones() ([]Zone, error) {
 	source, err := c.get("zones")
 	if err != nil {
-		return nil, NewUnexpectedError(err)
+		return nil, translateError(opEntity, err)
 	}
-	zones, err := readZones(c.apiVersion, source)
+	zones, err := readZones(c.schemaVersion(), source)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	if err = c.checkDecoded("zone", source, zones); err != nil {
+		return nil, errors.Trace(err)
+	}
+	sortZones(zones)
 	var result []Zone
 	for _, z := range zones {
 		result = append(result, z)
@@ -221,6 +771,39 @@ func (c *controller) Zones() ([]Zone, error) {
 	return result, nil
 }
 
+// Pools implements Controller.
+//
+// Returns
+//  - NotSupported error if MAAS is older than 2.3
+func (c *controller) Pools() ([]Pool, error) {
+	if err := c.requireRelease(2, 3, "resource pools"); err != nil {
+		return nil, errors.Trace(err)
+	}
+	source, err := c.get("resourcepools")
+	if err != nil {
+		return nil, translateError(opEntity, err)
+	}
+	// Development servers don't report their release, but have pools if
+	// they got this far.
+	readVersion := c.schemaVersion()
+	if readVersion.Compare(twoDotThree) < 0 {
+		readVersion = twoDotThree
+	}
+	pools, err := readPools(readVersion, source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err = c.checkDecoded("pool", source, pools); err != nil {
+		return nil, errors.Trace(err)
+	}
+	sortPools(pools)
+	var result []Pool
+	for _, p := range pools {
+		result = append(result, p)
+	}
+	return result, nil
+}
+
 // DevicesArgs is a argument struct for selecting Devices.
 // Only devices that match the specified criteria are returned.
 type DevicesArgs struct {
@@ -230,28 +813,45 @@ type DevicesArgs struct {
 	Domain       string
 	Zone         string
 	AgentName    string
+	// Owner is the username of the user that created the devices, and
+	// Parent is the system ID of the devices' parent. MAAS filters by them
+	// from 2.5; the devices are also filtered by the client, for older
+	// controllers.
+	Owner  string
+	Parent string
+	// SortBy is optional, and defaults to SortBySystemID.
+	SortBy SortBy
 }
 
 // Devices implements Controller.
 func (c *controller) Devices(args DevicesArgs) ([]Device, error) {
-	params := NewURLParams()
-	params.MaybeAddMany("hostname", args.Hostname)
-	params.MaybeAddMany("mac_address", args.MACAddresses)
-	params.MaybeAddMany("id", args.SystemIDs)
-	params.MaybeAdd("domain", args.Domain)
-	params.MaybeAdd("zone", args.Zone)
-	params.MaybeAdd("agent_name", args.AgentName)
-	source, err := c.getQuery("devices", params.Values)
+	if err := c.requireCapability(CapDevicesManagement); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := args.SortBy.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	source, err := c.getQuery("devices", devicesParams(args, c.schemaVersion()))
 	if err != nil {
-		return nil, NewUnexpectedError(err)
+		return nil, translateError(opEntity, err)
 	}
-	devices, err := readDevices(c.apiVersion, source)
+	devices, err := readDevices(c.schemaVersion(), source)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	if err = c.checkDecoded("device", source, devices); err != nil {
+		return nil, errors.Trace(err)
+	}
+	sortDevices(devices, args.SortBy)
 	var result []Device
 	for _, d := range devices {
-		d.controller = c
+		if args.Owner != "" && d.owner != args.Owner {
+			continue
+		}
+		if args.Parent != "" && d.parent != args.Parent {
+			continue
+		}
+		d.bind(c)
 		result = append(result, d)
 	}
 	return result, nil
@@ -259,10 +859,61 @@ func (c *controller) Devices(args DevicesArgs) ([]Device, error) {
 
 // CreateDeviceArgs is a argument struct for passing information into CreateDevice.
 type CreateDeviceArgs struct {
+	// Hostname is optional, and MAAS generates one if it is empty. It
+	// may be domain-qualified, e.g. "web.example.com", in which case the
+	// part after the first dot is the domain.
 	Hostname     string
 	MACAddresses []string
 	Domain       string
 	Parent       string
+	Zone         string
+	Description  string
+}
+
+// hostnameAndDomain returns the unqualified hostname and the domain.
+func (a *CreateDeviceArgs) hostnameAndDomain() (string, string) {
+	hostname, domain, qualified := strings.Cut(a.Hostname, ".")
+	if !qualified {
+		return a.Hostname, a.Domain
+	}
+	return hostname, domain
+}
+
+// Validate checks the hostname, and that a domain-qualified hostname
+// agrees with the Domain. The MAC addresses are checked by CreateDevice,
+// and the Domain is checked by MAAS.
+func (a *CreateDeviceArgs) Validate() error {
+	if a.Hostname == "" {
+		return nil
+	}
+	for _, label := range strings.Split(a.Hostname, ".") {
+		if !isDNSLabel(label) {
+			return errors.NotValidf("Hostname %q", a.Hostname)
+		}
+	}
+	if _, domain := a.hostnameAndDomain(); a.Domain != "" && domain != a.Domain {
+		return errors.NotValidf("Hostname %q with Domain %q", a.Hostname, a.Domain)
+	}
+	return nil
+}
+
+// isDNSLabel returns whether the label is a valid DNS host label, see
+// RFC 1123.
+func isDNSLabel(label string) bool {
+	if label == "" || len(label) > 63 {
+		return false
+	}
+	if label[0] == '-' || label[len(label)-1] == '-' {
+		return false
+	}
+	for _, r := range label {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-':
+		default:
+			return false
+		}
+	}
+	return true
 }
 
 // Devices implements Controller.
@@ -271,27 +922,33 @@ func (c *controller) CreateDevice(args CreateDeviceArgs) (Device, error) {
 	if len(args.MACAddresses) == 0 {
 		return nil, NewBadRequestError("at least one MAC address must be specified")
 	}
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := c.requireCapability(CapDevicesManagement); err != nil {
+		return nil, errors.Trace(err)
+	}
+	hostname, domain := args.hostnameAndDomain()
 	params := NewURLParams()
-	params.MaybeAdd("hostname", args.Hostname)
-	params.MaybeAdd("domain", args.Domain)
+	params.MaybeAdd("hostname", hostname)
+	params.MaybeAdd("domain", domain)
 	params.MaybeAddMany("mac_addresses", args.MACAddresses)
 	params.MaybeAdd("parent", args.Parent)
+	params.MaybeAdd("zone", args.Zone)
+	params.MaybeAdd("description", args.Description)
 	result, err := c.post("devices", "", params.Values)
 	if err != nil {
-		if svrErr, ok := errors.Cause(err).(ServerError); ok {
-			if svrErr.StatusCode == http.StatusBadRequest {
-				return nil, errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
-			}
-		}
-		// Translate http errors.
-		return nil, NewUnexpectedError(err)
+		return nil, translateError(opEntity, err)
 	}
 
-	device, err := readDevice(c.apiVersion, result)
+	device, err := readDevice(c.schemaVersion(), result)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
-	device.controller = c
+	if err = c.checkDecoded("device", result, device); err != nil {
+		return nil, errors.Trace(err)
+	}
+	device.bind(c)
 	return device, nil
 }
 
@@ -305,10 +962,159 @@ type MachinesArgs struct {
 	Zone         string
 	AgentName    string
 	OwnerData    map[string]string
+	// Owner is the username of the user the machines are allocated to.
+	// MAAS filters by it from 2.5; the machines are also filtered by the
+	// client, for older controllers.
+	Owner string
+	// SortBy is optional, and defaults to SortBySystemID.
+	SortBy SortBy
+	// Projection is optional, and defaults to ProjectFull.
+	Projection MachineProjection
+}
+
+// Validate checks the sort order and projection are known.
+func (a *MachinesArgs) Validate() error {
+	if err := a.SortBy.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(a.Projection.Validate())
 }
 
 // Machines implements Controller.
 func (c *controller) Machines(args MachinesArgs) ([]Machine, error) {
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	bytes, err := c._getRaw("machines", "", machinesParams(args, c.schemaVersion()))
+	if err != nil {
+		return nil, translateError(opEntity, err)
+	}
+	source, err := decodeMachines(args.Projection, bytes)
+	if err != nil {
+		return nil, translateError(opEntity, err)
+	}
+	return c.machinesFromSource(args, source)
+}
+
+// EachMachine implements Controller.
+//
+// Returns
+//  - NotValid error if the args aren't valid, or select an order
+//  - the error returned by f, if it returns one
+func (c *controller) EachMachine(args MachinesArgs, f func(Machine) error) error {
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	if args.SortBy != "" {
+		return errors.NotValidf("sorting streamed machines")
+	}
+	reader, writer := io.Pipe()
+	requestDone := make(chan error, 1)
+	go func() {
+		_, err := c._getStream(context.Background(), "machines", "", machinesParams(args, c.schemaVersion()), writer)
+		// The reader sees the error, or the end of the listing.
+		writer.CloseWithError(err)
+		requestDone <- err
+	}()
+	stopped, err := c.readMachineStream(args, reader, f)
+	// Closing the reader stops the request if the listing wasn't read to
+	// the end.
+	reader.Close()
+	requestErr := <-requestDone
+	if stopped {
+		return errors.Trace(err)
+	}
+	if requestErr != nil {
+		return translateError(opEntity, requestErr)
+	}
+	return errors.Trace(err)
+}
+
+// readMachineStream decodes the machines listing from the reader one machine
+// at a time, and calls f with each machine that the args select. It returns
+// true with the error of f if f fails.
+func (c *controller) readMachineStream(args MachinesArgs, reader io.Reader, f func(Machine) error) (bool, error) {
+	decoder := json.NewDecoder(reader)
+	if err := readDelim(decoder, '['); err != nil {
+		return false, translateError(opEntity, err)
+	}
+	for i := 0; decoder.More(); i++ {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return false, translateError(opEntity, err)
+		}
+		source, err := decodeMachine(args.Projection, raw)
+		if err != nil {
+			return false, translateError(opEntity, err)
+		}
+		m, err := readMachine(c.schemaVersion(), source)
+		if err != nil {
+			item, _ := source.(map[string]interface{})
+			return false, annotateListItem(err, "machine", i, item)
+		}
+		if err = c.checkDecoded("machine", source, m); err != nil {
+			return false, errors.Trace(err)
+		}
+		m.bind(c)
+		if !machineMatches(m, args) {
+			continue
+		}
+		if err := f(m); err != nil {
+			return true, err
+		}
+	}
+	if err := readDelim(decoder, ']'); err != nil {
+		return false, translateError(opEntity, err)
+	}
+	return false, nil
+}
+
+// readDelim reads the next token of the decoder, which must be the
+// delimiter.
+func readDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if token != delim {
+		return errors.Errorf("expected %v, got %v", delim, token)
+	}
+	return nil
+}
+
+// MachinesOwnedByAgent implements Controller.
+//
+// Returns a NotValid error if neither the agent nor
+// ControllerArgs.DefaultAgentName is set.
+func (c *controller) MachinesOwnedByAgent(agent string) ([]Machine, error) {
+	if agent == "" {
+		agent = c.defaultAgentName
+	}
+	if agent == "" {
+		return nil, errors.NotValidf("missing agent name")
+	}
+	machines, err := c.Machines(MachinesArgs{AgentName: agent})
+	return machines, errors.Trace(err)
+}
+
+// devicesParams and machinesParams return the query parameters MAAS
+// expects for filtering the devices and machines listings.
+func devicesParams(args DevicesArgs, controllerVersion version.Number) url.Values {
+	params := NewURLParams()
+	params.MaybeAddMany("hostname", args.Hostname)
+	params.MaybeAddMany("mac_address", args.MACAddresses)
+	params.MaybeAddMany("id", args.SystemIDs)
+	params.MaybeAdd("domain", args.Domain)
+	params.MaybeAdd("zone", args.Zone)
+	params.MaybeAdd("agent_name", args.AgentName)
+	if controllerVersion.Compare(twoDotFive) >= 0 {
+		params.MaybeAdd("owner", args.Owner)
+		params.MaybeAdd("parent", args.Parent)
+	}
+	return params.Values
+}
+
+func machinesParams(args MachinesArgs, controllerVersion version.Number) url.Values {
 	params := NewURLParams()
 	params.MaybeAddMany("hostname", args.Hostnames)
 	params.MaybeAddMany("mac_address", args.MACAddresses)
@@ -316,26 +1122,43 @@ func (c *controller) Machines(args MachinesArgs) ([]Machine, error) {
 	params.MaybeAdd("domain", args.Domain)
 	params.MaybeAdd("zone", args.Zone)
 	params.MaybeAdd("agent_name", args.AgentName)
-	// At the moment the MAAS API doesn't support filtering by owner
-	// data so we do that ourselves below.
-	source, err := c.getQuery("machines", params.Values)
-	if err != nil {
-		return nil, NewUnexpectedError(err)
+	if controllerVersion.Compare(twoDotFive) >= 0 {
+		params.MaybeAdd("owner", args.Owner)
 	}
-	machines, err := readMachines(c.apiVersion, source)
+	return params.Values
+}
+
+// machinesFromSource reads the machines listing, and sorts and filters it
+// as specified by the args.
+func (c *controller) machinesFromSource(args MachinesArgs, source interface{}) ([]Machine, error) {
+	machines, err := readMachines(c.schemaVersion(), source)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	if err = c.checkDecoded("machine", source, machines); err != nil {
+		return nil, errors.Trace(err)
+	}
+	sortMachines(machines, args.SortBy)
 	var result []Machine
 	for _, m := range machines {
-		m.controller = c
-		if ownerDataMatches(m.ownerData, args.OwnerData) {
+		m.bind(c)
+		if machineMatches(m, args) {
 			result = append(result, m)
 		}
 	}
 	return result, nil
 }
 
+// machineMatches reports whether the machine has the owner and owner data
+// selected by the args. At the moment the MAAS API doesn't support
+// filtering by owner data so we do that ourselves.
+func machineMatches(m *machine, args MachinesArgs) bool {
+	if args.Owner != "" && m.owner != args.Owner {
+		return false
+	}
+	return ownerDataMatches(m.ownerData, args.OwnerData)
+}
+
 func ownerDataMatches(ownerData, filter map[string]string) bool {
 	for key, value := range filter {
 		if ownerData[key] != value {
@@ -385,6 +1208,8 @@ func (s *StorageSpec) String() string {
 }
 
 // InterfaceSpec represents one elemenet of network related constraints.
+// Each of the list values matches any of the values in the list, and the
+// Not lists exclude interfaces matching any of their values.
 type InterfaceSpec struct {
 	// Label is required and an arbitrary string. Labels need to be unique
 	// across the InterfaceSpec elements specified in the AllocateMachineArgs.
@@ -393,33 +1218,87 @@ type InterfaceSpec struct {
 	Label string
 	Space string
 
-	// NOTE: there are other interface spec values that we are not exposing at
-	// this stage that can be added on an as needed basis. Other possible values are:
-	//     'fabric_class', 'not_fabric_class',
-	//     'subnet_cidr', 'not_subnet_cidr',
-	//     'vid', 'not_vid',
-	//     'fabric', 'not_fabric',
-	//     'subnet', 'not_subnet',
-	//     'mode'
+	Fabrics          []string
+	NotFabrics       []string
+	FabricClasses    []string
+	NotFabricClasses []string
+	SubnetCIDRs      []string
+	NotSubnetCIDRs   []string
+	VIDs             []int
+	NotVIDs          []int
+
+	// Mode is optional, and the only value MAAS supports is
+	// InterfaceSpecModeUnconfigured.
+	Mode string
 }
 
-// Validate ensures that a Label is specified and that there is at least one
-// Space or NotSpace value set.
+// InterfaceSpecModeUnconfigured matches interfaces that have no links
+// configured.
+const InterfaceSpecModeUnconfigured = "unconfigured"
+
+// Validate ensures that a Label is specified, that there is at least one
+// constraint, and that the CIDRs, VIDs and Mode are valid.
 func (a *InterfaceSpec) Validate() error {
 	if a.Label == "" {
 		return errors.NotValidf("missing Label")
 	}
-	// Perhaps at some stage in the future there will be other possible specs
-	// supported (like vid, subnet, etc), but until then, just space to check.
-	if a.Space == "" {
-		return errors.NotValidf("empty Space constraint")
+	if len(a.constraints()) == 0 {
+		return errors.NotValidf("empty constraints")
+	}
+	for _, cidrs := range [][]string{a.SubnetCIDRs, a.NotSubnetCIDRs} {
+		for _, cidr := range cidrs {
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				return errors.NotValidf("subnet CIDR %q", cidr)
+			}
+		}
+	}
+	for _, vids := range [][]int{a.VIDs, a.NotVIDs} {
+		for _, vid := range vids {
+			if vid < 0 || vid > 4094 {
+				return errors.NotValidf("VID %d", vid)
+			}
+		}
+	}
+	if a.Mode != "" && a.Mode != InterfaceSpecModeUnconfigured {
+		return errors.NotValidf("Mode %q", a.Mode)
 	}
 	return nil
 }
 
+// constraints returns the key=value pairs of the spec in the order MAAS
+// documents them.
+func (a *InterfaceSpec) constraints() []string {
+	var result []string
+	add := func(key string, values ...string) {
+		for _, value := range values {
+			if value != "" {
+				result = append(result, key+"="+value)
+			}
+		}
+	}
+	vids := func(values []int) []string {
+		result := make([]string, len(values))
+		for i, v := range values {
+			result[i] = strconv.Itoa(v)
+		}
+		return result
+	}
+	add("space", a.Space)
+	add("fabric", a.Fabrics...)
+	add("not_fabric", a.NotFabrics...)
+	add("fabric_class", a.FabricClasses...)
+	add("not_fabric_class", a.NotFabricClasses...)
+	add("subnet_cidr", a.SubnetCIDRs...)
+	add("not_subnet_cidr", a.NotSubnetCIDRs...)
+	add("vid", vids(a.VIDs)...)
+	add("not_vid", vids(a.NotVIDs)...)
+	add("mode", a.Mode)
+	return result
+}
+
 // String returns the interface spec as MaaS requires it.
 func (a *InterfaceSpec) String() string {
-	return fmt.Sprintf("%s:space=%s", a.Label, a.Space)
+	return fmt.Sprintf("%s:%s", a.Label, strings.Join(a.constraints(), ","))
 }
 
 // AllocateMachineArgs is an argument struct for passing args into Machine.Allocate.
@@ -434,6 +1313,10 @@ type AllocateMachineArgs struct {
 	NotTags   []string
 	Zone      string
 	NotInZone []string
+	// Pool and NotPools constrain the resource pools that the machine may
+	// be allocated from.
+	Pool     string
+	NotPools []string
 	// Storage represents the required disks on the Machine. If any are specified
 	// the first value is used for the root disk.
 	Storage []StorageSpec
@@ -446,6 +1329,9 @@ type AllocateMachineArgs struct {
 	AgentName string
 	Comment   string
 	DryRun    bool
+	// Verbose is only valid with DryRun, and requests an AllocationReport
+	// in the returned ConstraintMatches.
+	Verbose bool
 }
 
 // Validate makes sure that any labels specifed in Storage or Interfaces
@@ -478,6 +1364,9 @@ func (a *AllocateMachineArgs) Validate() error {
 			return errors.NotValidf("empty NotSpace constraint")
 		}
 	}
+	if a.Verbose && !a.DryRun {
+		return errors.NotValidf("Verbose without DryRun")
+	}
 	return nil
 }
 
@@ -516,6 +1405,27 @@ type ConstraintMatches struct {
 	// Storage is a mapping of the constraint label specified to the BlockDevices
 	// that match that constraint.
 	Storage map[string][]BlockDevice
+
+	// InterfaceSpecs maps each label in Interfaces to the InterfaceSpec
+	// with that label that the interfaces satisfied.
+	InterfaceSpecs map[string]InterfaceSpec
+
+	// Report is only set for a Verbose DryRun allocation.
+	Report *AllocationReport
+}
+
+// AllocationReport describes how each of the machines that MAAS considered
+// for an allocation matched the labelled Storage and Interfaces constraints.
+// Machines are identified by MAAS's internal node ID, as that is all MAAS
+// reports.
+type AllocationReport struct {
+	// Storage maps each storage constraint label to the IDs of the matching
+	// block devices of each machine that satisfied it.
+	Storage map[string]map[int][]int
+
+	// Interfaces maps each interface constraint label to the IDs of the
+	// matching interfaces of each machine that satisfied it.
+	Interfaces map[string]map[int][]int
 }
 
 // AllocateMachine implements Controller.
@@ -537,32 +1447,47 @@ func (c *controller) AllocateMachine(args AllocateMachineArgs) (Machine, Constra
 	params.MaybeAddMany("not_subnets", args.notSubnets())
 	params.MaybeAdd("zone", args.Zone)
 	params.MaybeAddMany("not_in_zone", args.NotInZone)
-	params.MaybeAdd("agent_name", args.AgentName)
+	params.MaybeAdd("pool", args.Pool)
+	params.MaybeAddMany("not_in_pool", args.NotPools)
+	agentName := args.AgentName
+	if agentName == "" {
+		agentName = c.defaultAgentName
+	}
+	params.MaybeAdd("agent_name", agentName)
 	params.MaybeAdd("comment", args.Comment)
 	params.MaybeAddBool("dry_run", args.DryRun)
+	params.MaybeAddBool("verbose", args.Verbose)
 	result, err := c.post("machines", "allocate", params.Values)
 	if err != nil {
 		// A 409 Status code is "No Matching Machines"
-		if svrErr, ok := errors.Cause(err).(ServerError); ok {
-			if svrErr.StatusCode == http.StatusConflict {
-				return nil, matches, errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
-			}
-		}
-		// Translate http errors.
-		return nil, matches, NewUnexpectedError(err)
+		return nil, matches, translateError(opAllocate, err)
 	}
 
-	machine, err := readMachine(c.apiVersion, result)
+	machine, err := readMachine(c.schemaVersion(), result)
 	if err != nil {
 		return nil, matches, errors.Trace(err)
 	}
-	machine.controller = c
+	if err = c.checkDecoded("machine", result, machine); err != nil {
+		return nil, matches, errors.Trace(err)
+	}
+	machine.bind(c)
 
 	// Parse the constraint matches.
 	matches, err = parseAllocateConstraintsResponse(result, machine)
 	if err != nil {
 		return nil, matches, errors.Trace(err)
 	}
+	for _, spec := range args.Interfaces {
+		if _, found := matches.Interfaces[spec.Label]; found {
+			matches.InterfaceSpecs[spec.Label] = spec
+		}
+	}
+	if args.DryRun && args.Verbose {
+		matches.Report, err = parseAllocationReport(result)
+		if err != nil {
+			return nil, matches, errors.Trace(err)
+		}
+	}
 
 	return machine, matches, nil
 }
@@ -572,6 +1497,28 @@ func (c *controller) AllocateMachine(args AllocateMachineArgs) (Machine, Constra
 type ReleaseMachinesArgs struct {
 	SystemIDs []string
 	Comment   string
+
+	// Erase is whether the disks of the machines are erased before they
+	// are released. MAAS only erases them on a bulk release if disk
+	// erasing is enabled for all releases, so the machines are released
+	// one by one when Erase is set.
+	Erase bool
+	// SecureErase uses the secure erase feature of the disks, falling back
+	// to QuickErase or a full erase if the disks don't have it. It
+	// requires Erase.
+	SecureErase bool
+	// QuickErase only wipes the start and end of the disks. It requires
+	// Erase.
+	QuickErase bool
+}
+
+// Validate checks that SecureErase and QuickErase aren't set without
+// Erase.
+func (a *ReleaseMachinesArgs) Validate() error {
+	if !a.Erase && (a.SecureErase || a.QuickErase) {
+		return errors.NotValidf("SecureErase or QuickErase without Erase")
+	}
+	return nil
 }
 
 // ReleaseMachines implements Controller.
@@ -580,40 +1527,64 @@ type ReleaseMachinesArgs struct {
 //  - BadRequestError if any of the machines cannot be found
 //  - PermissionError if the user does not have permission to release any of the machines
 //  - CannotCompleteError if any of the machines could not be released due to their current state
+//  - NoMatchError if a machine whose disks are erased doesn't exist
+//  - MultiError with the errors above for each machine that failed, if
+//    the disks are erased
 func (c *controller) ReleaseMachines(args ReleaseMachinesArgs) error {
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	if args.Erase {
+		return c.releaseAndErase(args)
+	}
 	params := NewURLParams()
 	params.MaybeAddMany("machines", args.SystemIDs)
 	params.MaybeAdd("comment", args.Comment)
 	_, err := c.post("machines", "release", params.Values)
 	if err != nil {
-		if svrErr, ok := errors.Cause(err).(ServerError); ok {
-			switch svrErr.StatusCode {
-			case http.StatusBadRequest:
-				return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
-			case http.StatusForbidden:
-				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
-			case http.StatusConflict:
-				return errors.Wrap(err, NewCannotCompleteError(svrErr.BodyMessage))
-			}
-		}
-		return NewUnexpectedError(err)
+		return translateError(opEntity, err)
 	}
 
 	return nil
 }
 
+// releaseAndErase releases each of the machines with the erase options,
+// which are only honoured when releasing a single machine.
+func (c *controller) releaseAndErase(args ReleaseMachinesArgs) error {
+	params := NewURLParams()
+	params.MaybeAdd("comment", args.Comment)
+	params.MaybeAddBool("erase", args.Erase)
+	params.MaybeAddBool("secure_erase", args.SecureErase)
+	params.MaybeAddBool("quick_erase", args.QuickErase)
+	failures := make(map[string]error)
+	for _, id := range args.SystemIDs {
+		_, err := c.post("machines/"+id, "release", params.Values)
+		if err == nil {
+			continue
+		}
+		failures[id] = translateError(opEntity, err)
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+	return NewMultiError(failures)
+}
+
 // Files implements Controller.
 func (c *controller) Files(prefix string) ([]File, error) {
 	params := NewURLParams()
 	params.MaybeAdd("prefix", prefix)
 	source, err := c.getQuery("files", params.Values)
 	if err != nil {
-		return nil, NewUnexpectedError(err)
+		return nil, translateError(opEntity, err)
 	}
-	files, err := readFiles(c.apiVersion, source)
+	files, err := readFiles(c.schemaVersion(), source)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	if err = c.checkDecoded("file", source, files); err != nil {
+		return nil, errors.Trace(err)
+	}
 	var result []File
 	for _, f := range files {
 		f.controller = c
@@ -622,6 +1593,21 @@ func (c *controller) Files(prefix string) ([]File, error) {
 	return result, nil
 }
 
+// FileInfos implements Controller.
+func (c *controller) FileInfos(prefix string) ([]FileInfo, error) {
+	files, err := c.Files(prefix)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	result := make([]FileInfo, len(files))
+	for i, f := range files {
+		if result[i], err = f.Stat(); err != nil {
+			return nil, errors.Annotatef(err, "file %q", f.Filename())
+		}
+	}
+	return result, nil
+}
+
 // GetFile implements Controller.
 func (c *controller) GetFile(filename string) (File, error) {
 	if filename == "" {
@@ -629,17 +1615,15 @@ func (c *controller) GetFile(filename string) (File, error) {
 	}
 	source, err := c.get("files/" + filename)
 	if err != nil {
-		if svrErr, ok := errors.Cause(err).(ServerError); ok {
-			if svrErr.StatusCode == http.StatusNotFound {
-				return nil, errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
-			}
-		}
-		return nil, NewUnexpectedError(err)
+		return nil, translateError(opEntity, err)
 	}
-	file, err := readFile(c.apiVersion, source)
+	file, err := readFile(c.schemaVersion(), source)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	if err = c.checkDecoded("file", source, file); err != nil {
+		return nil, errors.Trace(err)
+	}
 	file.controller = c
 	return file, nil
 }
@@ -697,42 +1681,106 @@ func (c *controller) AddFile(args AddFileArgs) error {
 	params := url.Values{"filename": {args.Filename}}
 	_, err := c.postFile("files", "", params, fileContent)
 	if err != nil {
-		if svrErr, ok := errors.Cause(err).(ServerError); ok {
-			if svrErr.StatusCode == http.StatusBadRequest {
-				return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
-			}
-		}
-		return NewUnexpectedError(err)
+		return translateError(opEntity, err)
 	}
 	return nil
 }
 
-func (c *controller) checkCreds() error {
-	if _, err := c.getOp("users", "whoami"); err != nil {
-		if svrErr, ok := errors.Cause(err).(ServerError); ok {
-			if svrErr.StatusCode == http.StatusUnauthorized {
-				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
-			}
+// Raw implements Controller.
+//
+// Error responses are translated, and the status code is returned whether or
+// not there was an error. Returns
+//  - BadRequestError for a 400 response
+//  - PermissionError for a 401 or 403 response
+//  - NoMatchError for a 404 response
+//  - CannotCompleteError for a 409 or 503 response
+func (c *controller) Raw(method, path, op string, params url.Values, body io.Reader) ([]byte, int, error) {
+	// The body is read up front so that it can be sent again if the
+	// request is retried.
+	var content []byte
+	if body != nil {
+		var err error
+		content, err = ioutil.ReadAll(body)
+		if err != nil {
+			return nil, 0, errors.Annotatef(err, "cannot read body")
 		}
-		return NewUnexpectedError(err)
 	}
-	return nil
+	path = EnsureTrailingSlash(path)
+	if method != "GET" && method != "HEAD" {
+		request := PlannedRequest{Method: method, Path: path, Op: op, Params: params, Body: content}
+		if err := c.plan(request); err != nil {
+			return nil, 0, errors.Trace(err)
+		}
+	}
+	requestID := nextRequestID()
+	client := c.requestClient(requestID)
+	c.logger.Tracef("request %s: %s %s%s, op=%q, params=%s", requestID, method, c.client.APIURL, path, op, params.Encode())
+	var status int
+	result, err := c.withCredentialRefresh(context.Background(), func() ([]byte, error) {
+		if err := c.rateLimiter.wait(context.Background(), path); err != nil {
+			return nil, errors.Trace(err)
+		}
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(content)
+		}
+		var (
+			result []byte
+			err    error
+		)
+		result, status, err = client.Raw(method, &url.URL{Path: path}, op, params, reader)
+		return result, err
+	})
+	if err != nil {
+		c.logger.Tracef("response %s: error: %q", requestID, err.Error())
+		c.logger.Tracef("error detail: %#v", err)
+		err = newRequestError(requestID, err)
+		return result, status, translateError(opEntity, err)
+	}
+	c.logger.Tracef("response %s: %d %s", requestID, status, string(result))
+	return result, status, nil
+}
+
+// whoami returns the name of the user the controller is authenticated as,
+// which is empty if MAAS doesn't report it.
+func (c *controller) whoami(ctx context.Context) (string, error) {
+	source, err := c._getContext(ctx, "users", "whoami", nil)
+	if err != nil {
+		return "", translateError(opEntity, err)
+	}
+	switch source := source.(type) {
+	case string:
+		return source, nil
+	case map[string]interface{}:
+		username, _ := source["username"].(string)
+		return username, nil
+	}
+	return "", nil
 }
 
 func (c *controller) put(path string, params url.Values) (interface{}, error) {
 	path = EnsureTrailingSlash(path)
+	if err := c.plan(PlannedRequest{Method: "PUT", Path: path, Params: params}); err != nil {
+		return nil, errors.Trace(err)
+	}
 	requestID := nextRequestID()
-	logger.Tracef("request %x: PUT %s%s, params: %s", requestID, c.client.APIURL, path, params.Encode())
-	bytes, err := c.client.Put(&url.URL{Path: path}, params)
+	client := c.requestClient(requestID)
+	c.logger.Tracef("request %s: PUT %s%s, params: %s", requestID, c.client.APIURL, path, params.Encode())
+	bytes, err := c.withCredentialRefresh(context.Background(), func() ([]byte, error) {
+		if err := c.rateLimiter.wait(context.Background(), path); err != nil {
+			return nil, errors.Trace(err)
+		}
+		return client.Put(&url.URL{Path: path}, params)
+	})
 	if err != nil {
-		logger.Tracef("response %x: error: %q", requestID, err.Error())
-		logger.Tracef("error detail: %#v", err)
+		c.logger.Tracef("response %s: error: %q", requestID, err.Error())
+		c.logger.Tracef("error detail: %#v", err)
+		err = newRequestError(requestID, err)
 		return nil, errors.Trace(err)
 	}
-	logger.Tracef("response %x: %s", requestID, string(bytes))
+	c.logger.Tracef("response %s: %s", requestID, string(bytes))
 
-	var parsed interface{}
-	err = json.Unmarshal(bytes, &parsed)
+	parsed, err := decodeJSON(bytes)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -745,8 +1793,7 @@ func (c *controller) post(path, op string, params url.Values) (interface{}, erro
 		return nil, errors.Trace(err)
 	}
 
-	var parsed interface{}
-	err = json.Unmarshal(bytes, &parsed)
+	parsed, err := decodeJSON(bytes)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -761,35 +1808,55 @@ func (c *controller) postFile(path, op string, params url.Values, fileContent []
 
 func (c *controller) _postRaw(path, op string, params url.Values, files map[string][]byte) ([]byte, error) {
 	path = EnsureTrailingSlash(path)
+	if err := c.plan(PlannedRequest{Method: "POST", Path: path, Op: op, Params: params, Files: files}); err != nil {
+		return nil, errors.Trace(err)
+	}
 	requestID := nextRequestID()
-	if logger.IsTraceEnabled() {
+	client := c.requestClient(requestID)
+	if c.logger.IsTraceEnabled() {
 		opArg := ""
 		if op != "" {
 			opArg = "?op=" + op
 		}
-		logger.Tracef("request %x: POST %s%s%s, params=%s", requestID, c.client.APIURL, path, opArg, params.Encode())
+		c.logger.Tracef("request %s: POST %s%s%s, params=%s", requestID, c.client.APIURL, path, opArg, params.Encode())
 	}
-	bytes, err := c.client.Post(&url.URL{Path: path}, op, params, files)
+	bytes, err := c.withCredentialRefresh(context.Background(), func() ([]byte, error) {
+		if err := c.rateLimiter.wait(context.Background(), path); err != nil {
+			return nil, errors.Trace(err)
+		}
+		return client.Post(&url.URL{Path: path}, op, params, files)
+	})
 	if err != nil {
-		logger.Tracef("response %x: error: %q", requestID, err.Error())
-		logger.Tracef("error detail: %#v", err)
+		c.logger.Tracef("response %s: error: %q", requestID, err.Error())
+		c.logger.Tracef("error detail: %#v", err)
+		err = newRequestError(requestID, err)
 		return nil, errors.Trace(err)
 	}
-	logger.Tracef("response %x: %s", requestID, string(bytes))
+	c.logger.Tracef("response %s: %s", requestID, string(bytes))
 	return bytes, nil
 }
 
 func (c *controller) delete(path string) error {
 	path = EnsureTrailingSlash(path)
+	if err := c.plan(PlannedRequest{Method: "DELETE", Path: path}); err != nil {
+		return errors.Trace(err)
+	}
 	requestID := nextRequestID()
-	logger.Tracef("request %x: DELETE %s%s", requestID, c.client.APIURL, path)
-	err := c.client.Delete(&url.URL{Path: path})
+	client := c.requestClient(requestID)
+	c.logger.Tracef("request %s: DELETE %s%s", requestID, c.client.APIURL, path)
+	_, err := c.withCredentialRefresh(context.Background(), func() ([]byte, error) {
+		if err := c.rateLimiter.wait(context.Background(), path); err != nil {
+			return nil, errors.Trace(err)
+		}
+		return nil, client.Delete(&url.URL{Path: path})
+	})
 	if err != nil {
-		logger.Tracef("response %x: error: %q", requestID, err.Error())
-		logger.Tracef("error detail: %#v", err)
+		c.logger.Tracef("response %s: error: %q", requestID, err.Error())
+		c.logger.Tracef("error detail: %#v", err)
+		err = newRequestError(requestID, err)
 		return errors.Trace(err)
 	}
-	logger.Tracef("response %x: complete", requestID)
+	c.logger.Tracef("response %s: complete", requestID)
 	return nil
 }
 
@@ -806,12 +1873,17 @@ func (c *controller) getOp(path, op string) (interface{}, error) {
 }
 
 func (c *controller) _get(path, op string, params url.Values) (interface{}, error) {
-	bytes, err := c._getRaw(path, op, params)
+	return c._getContext(context.Background(), path, op, params)
+}
+
+// _getContext is like _get, but the request is abandoned when the context
+// is done.
+func (c *controller) _getContext(ctx context.Context, path, op string, params url.Values) (interface{}, error) {
+	bytes, err := c._getRawContext(ctx, path, op, params)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
-	var parsed interface{}
-	err = json.Unmarshal(bytes, &parsed)
+	parsed, err := decodeJSON(bytes)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -819,27 +1891,139 @@ func (c *controller) _get(path, op string, params url.Values) (interface{}, erro
 }
 
 func (c *controller) _getRaw(path, op string, params url.Values) ([]byte, error) {
+	return c._getRawContext(context.Background(), path, op, params)
+}
+
+func (c *controller) _getRawContext(ctx context.Context, path, op string, params url.Values) ([]byte, error) {
 	path = EnsureTrailingSlash(path)
 	requestID := nextRequestID()
-	if logger.IsTraceEnabled() {
+	client := c.requestClient(requestID)
+	client.Context = ctx
+	if c.logger.IsTraceEnabled() {
 		var query string
 		if params != nil {
 			query = "?" + params.Encode()
 		}
-		logger.Tracef("request %x: GET %s%s%s", requestID, c.client.APIURL, path, query)
+		c.logger.Tracef("request %s: GET %s%s%s", requestID, c.client.APIURL, path, query)
 	}
-	bytes, err := c.client.Get(&url.URL{Path: path}, op, params)
+	bytes, err := c.withCredentialRefresh(ctx, func() ([]byte, error) {
+		if err := c.rateLimiter.wait(ctx, path); err != nil {
+			return nil, errors.Trace(err)
+		}
+		// The client adds the op to the params it is passed, so give
+		// each attempt its own copy.
+		query := make(url.Values)
+		for key, values := range params {
+			query[key] = values
+		}
+		return client.Get(&url.URL{Path: path}, op, query)
+	})
 	if err != nil {
-		logger.Tracef("response %x: error: %q", requestID, err.Error())
-		logger.Tracef("error detail: %#v", err)
+		c.logger.Tracef("response %s: error: %q", requestID, err.Error())
+		c.logger.Tracef("error detail: %#v", err)
+		err = newRequestError(requestID, err)
 		return nil, errors.Trace(err)
 	}
-	logger.Tracef("response %x: %s", requestID, string(bytes))
+	c.logger.Tracef("response %s: %s", requestID, string(bytes))
 	return bytes, nil
 }
 
-func nextRequestID() int64 {
-	return atomic.AddInt64(&requestNumber, 1)
+// _getStream is like _getRawContext, but copies the body of the response to
+// the writer rather than returning it, and returns the headers of the
+// response.
+func (c *controller) _getStream(ctx context.Context, path, op string, params url.Values, w io.Writer) (http.Header, error) {
+	path = EnsureTrailingSlash(path)
+	requestID := nextRequestID()
+	client := c.requestClient(requestID)
+	client.Context = ctx
+	if c.logger.IsTraceEnabled() {
+		var query string
+		if params != nil {
+			query = "?" + params.Encode()
+		}
+		c.logger.Tracef("request %s: GET %s%s%s, streamed", requestID, c.client.APIURL, path, query)
+	}
+	var header http.Header
+	_, err := c.withCredentialRefresh(ctx, func() ([]byte, error) {
+		if err := c.rateLimiter.wait(ctx, path); err != nil {
+			return nil, errors.Trace(err)
+		}
+		// The client adds the op to the params it is passed, so give
+		// each attempt its own copy.
+		query := make(url.Values)
+		for key, values := range params {
+			query[key] = values
+		}
+		var err error
+		header, err = client.GetStream(&url.URL{Path: path}, op, query, w)
+		return nil, err
+	})
+	if err != nil {
+		c.logger.Tracef("response %s: error: %q", requestID, err.Error())
+		c.logger.Tracef("error detail: %#v", err)
+		err = newRequestError(requestID, err)
+		return nil, errors.Trace(err)
+	}
+	c.logger.Tracef("response %s: streamed, Content-Type %q", requestID, header.Get("Content-Type"))
+	return header, nil
+}
+
+// withCredentialRefresh calls the request func, and if the MAAS controller
+// rejects the credentials used and a CredentialProvider was specified, gets a
+// new API key from the provider, passing it the context of the request, and
+// calls the request func once more.
+func (c *controller) withCredentialRefresh(ctx context.Context, request func() ([]byte, error)) ([]byte, error) {
+	bytes, err := request()
+	if c.credentials == nil {
+		return bytes, err
+	}
+	if svrErr, ok := GetServerError(err); !ok || svrErr.StatusCode != http.StatusUnauthorized {
+		return bytes, err
+	}
+	if refreshErr := c.refreshCredentials(ctx); refreshErr != nil {
+		c.logger.Warnf("cannot refresh credentials: %v", refreshErr)
+		return bytes, err
+	}
+	return request()
+}
+
+// refreshCredentials gets the current API key from the CredentialProvider
+// and uses it to sign all subsequent requests.
+func (c *controller) refreshCredentials(ctx context.Context) error {
+	apiKey, err := c.credentials.GetAPIKey(ctx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	// Parse the key the same way the original client was created.
+	client, err := NewAuthenticatedClientWithSignatureMethod(c.client.APIURL.String(), apiKey, c.signatureMethod)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	c.client.Signer.(*refreshableSigner).setSigner(client.Signer)
+	return nil
+}
+
+// newRequestIDPrefix returns a random prefix for the request IDs.
+func newRequestIDPrefix() string {
+	prefix := make([]byte, 4)
+	if _, err := rand.Read(prefix); err != nil {
+		// The IDs are still unique within the process.
+		return "0"
+	}
+	return hex.EncodeToString(prefix)
+}
+
+func nextRequestID() string {
+	return fmt.Sprintf("%s-%x", requestIDPrefix, atomic.AddInt64(&requestNumber, 1))
+}
+
+// requestClient returns a copy of the controller's client that sends the
+// request ID with each request.
+func (c *controller) requestClient(requestID string) *Client {
+	client := *c.client
+	client.RequestIDHeader = c.requestIDHeader
+	client.RequestID = requestID
+	return &client
 }
 
 func indicatesUnsupportedVersion(err error) bool {
@@ -860,25 +2044,30 @@ func indicatesUnsupportedVersion(err error) bool {
 	return false
 }
 
-func (c *controller) readAPIVersionInfo() (set.Strings, error) {
-	parsed, err := c.get("version")
+func (c *controller) readAPIVersionInfo(ctx context.Context) (ServerVersion, error) {
+	var empty ServerVersion
+	parsed, err := c._getContext(ctx, "version", "", nil)
 	if indicatesUnsupportedVersion(err) {
-		return nil, WrapWithUnsupportedVersionError(err)
+		return empty, WrapWithUnsupportedVersionError(err)
 	} else if err != nil {
-		return nil, errors.Trace(err)
+		return empty, errors.Trace(err)
 	}
 
 	// As we care about other fields, add them.
 	fields := schema.Fields{
 		"capabilities": schema.List(schema.String()),
+		"version":      schema.String(),
+		"subversion":   schema.String(),
 	}
-	checker := schema.FieldMap(fields, nil) // no defaults
+	defaults := schema.Defaults{
+		"version":    "",
+		"subversion": "",
+	}
+	checker := schema.FieldMap(fields, defaults)
 	coerced, err := checker.Coerce(parsed, nil)
 	if err != nil {
-		return nil, WrapWithDeserializationError(err, "version response")
+		return empty, WrapWithDeserializationError(err, "version response")
 	}
-	// For now, we don't append any subversion, but as it becomes used, we
-	// should parse and check.
 
 	valid := coerced.(map[string]interface{})
 	// From here we know that the map returned from the schema coercion
@@ -889,7 +2078,35 @@ func (c *controller) readAPIVersionInfo() (set.Strings, error) {
 		capabilities.Add(value.(string))
 	}
 
-	return capabilities, nil
+	return ServerVersion{
+		Version:      parseServerRelease(valid["version"].(string)),
+		Subversion:   valid["subversion"].(string),
+		Capabilities: capabilities,
+	}, nil
+}
+
+var serverVersionPattern = regexp.MustCompile(`^(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// parseServerRelease returns the MAAS release reported by the version
+// endpoint, e.g. 2.9.2 for "2.9.2~rc1". Development servers report
+// "unknown", in which case version.Zero is returned.
+func parseServerRelease(value string) version.Number {
+	match := serverVersionPattern.FindStringSubmatch(value)
+	if match == nil {
+		return version.Zero
+	}
+	var numbers [3]int
+	for i, part := range match[1:] {
+		if part == "" {
+			continue
+		}
+		number, err := strconv.Atoi(part)
+		if err != nil {
+			return version.Zero
+		}
+		numbers[i] = number
+	}
+	return version.Number{Major: numbers[0], Minor: numbers[1], Patch: numbers[2]}
 }
 
 func parseAllocateConstraintsResponse(source interface{}, machine *machine) (ConstraintMatches, error) {
@@ -913,8 +2130,9 @@ func parseAllocateConstraintsResponse(source interface{}, machine *machine) (Con
 	valid := coerced.(map[string]interface{})
 	constraintsMap := valid["constraints_by_type"].(map[string]interface{})
 	result := ConstraintMatches{
-		Interfaces: make(map[string][]Interface),
-		Storage:    make(map[string][]BlockDevice),
+		Interfaces:     make(map[string][]Interface),
+		InterfaceSpecs: make(map[string]InterfaceSpec),
+		Storage:        make(map[string][]BlockDevice),
 	}
 
 	if interfaceMatches, found := constraintsMap["interfaces"]; found {
@@ -949,6 +2167,64 @@ func parseAllocateConstraintsResponse(source interface{}, machine *machine) (Con
 	return result, nil
 }
 
+// parseAllocationReport reads the verbose constraint matches for all the
+// machines considered. MAAS keys verbose_storage by machine then label, and
+// verbose_interfaces by label then machine; both are returned keyed by label.
+func parseAllocationReport(source interface{}) (*AllocationReport, error) {
+	verboseMatches := schema.StringMap(schema.StringMap(schema.List(schema.ForceInt())))
+	matchFields := schema.Fields{
+		"verbose_storage":    verboseMatches,
+		"verbose_interfaces": verboseMatches,
+	}
+	matchDefaults := schema.Defaults{
+		"verbose_storage":    schema.Omit,
+		"verbose_interfaces": schema.Omit,
+	}
+	fields := schema.Fields{
+		"constraints_by_type": schema.FieldMap(matchFields, matchDefaults),
+	}
+	checker := schema.FieldMap(fields, nil) // no defaults
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "allocation report schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	constraintsMap := valid["constraints_by_type"].(map[string]interface{})
+	report := &AllocationReport{
+		Storage:    make(map[string]map[int][]int),
+		Interfaces: make(map[string]map[int][]int),
+	}
+
+	if storageMatches, found := constraintsMap["verbose_storage"]; found {
+		for nodeKey, labelMatches := range storageMatches.(map[string]interface{}) {
+			nodeID, err := strconv.Atoi(nodeKey)
+			if err != nil {
+				return nil, NewDeserializationError("verbose storage machine ID %q not valid", nodeKey)
+			}
+			for label, ids := range convertConstraintMatches(labelMatches) {
+				if report.Storage[label] == nil {
+					report.Storage[label] = make(map[int][]int)
+				}
+				report.Storage[label][nodeID] = ids
+			}
+		}
+	}
+
+	if interfaceMatches, found := constraintsMap["verbose_interfaces"]; found {
+		for label, nodeMatches := range interfaceMatches.(map[string]interface{}) {
+			report.Interfaces[label] = make(map[int][]int)
+			for nodeKey, ids := range convertConstraintMatches(nodeMatches) {
+				nodeID, err := strconv.Atoi(nodeKey)
+				if err != nil {
+					return nil, NewDeserializationError("verbose interfaces machine ID %q not valid", nodeKey)
+				}
+				report.Interfaces[label][nodeID] = ids
+			}
+		}
+	}
+	return report, nil
+}
+
 func convertConstraintMatches(source interface{}) map[string][]int {
 	// These casts are all safe because of the schema check.
 	result := make(map[string][]int)
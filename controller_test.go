@@ -5,9 +5,13 @@ package gomaasapi
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
 
 	"github.com/juju/errors"
 	"github.com/juju/loggo"
@@ -84,6 +88,41 @@ func (s *controllerSuite) TestNewController(c *gc.C) {
 	c.Assert(expectedCapabilities.Difference(capabilities), gc.HasLen, 0)
 }
 
+func (s *controllerSuite) TestHasCapability(c *gc.C) {
+	controller := s.getController(c)
+	c.Assert(controller.HasCapability(CapDevicesManagement), jc.IsTrue)
+	c.Assert(controller.HasCapability("no-such-capability"), jc.IsFalse)
+}
+
+// createTestServerControllerWithoutCapabilities creates a controller backed
+// on to a test server that reports none of the capabilities.
+func createTestServerControllerWithoutCapabilities(c *gc.C, suite cleanup) (*SimpleTestServer, Controller) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, `{"version": "unknown", "subversion": "", "capabilities": []}`)
+	server.Start()
+	suite.AddCleanup(func(*gc.C) { server.Close() })
+
+	controller, err := NewController(ControllerArgs{
+		BaseURL: server.URL,
+		APIKey:  "fake:as:key",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	return server, controller
+}
+
+func (s *controllerSuite) TestDevicesWithoutCapability(c *gc.C) {
+	server, controller := createTestServerControllerWithoutCapabilities(c, s)
+	server.ResetRequests()
+	c.Assert(controller.HasCapability(CapDevicesManagement), jc.IsFalse)
+
+	_, err := controller.Devices(DevicesArgs{})
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+	_, err = controller.CreateDevice(CreateDeviceArgs{MACAddresses: []string{"a-mac-address"}})
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+	c.Assert(server.RequestCount(), gc.Equals, 0)
+}
+
 func (s *controllerSuite) TestNewControllerBadAPIKeyFormat(c *gc.C) {
 	server := NewSimpleServer()
 	server.Start()
@@ -119,7 +158,7 @@ func (s *controllerSuite) TestNewControllerBadCreds(c *gc.C) {
 	c.Assert(err, jc.Satisfies, IsPermissionError)
 }
 
-func (s *controllerSuite) TestNewControllerUnexpected(c *gc.C) {
+func (s *controllerSuite) TestNewControllerConflict(c *gc.C) {
 	server := NewSimpleServer()
 	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusConflict, "naughty")
 	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
@@ -129,7 +168,7 @@ func (s *controllerSuite) TestNewControllerUnexpected(c *gc.C) {
 		BaseURL: server.URL,
 		APIKey:  "fake:as:key",
 	})
-	c.Assert(err, jc.Satisfies, IsUnexpectedError)
+	c.Assert(err, jc.Satisfies, IsCannotCompleteError)
 }
 
 func (s *controllerSuite) TestNewControllerKnownVersion(c *gc.C) {
@@ -147,6 +186,141 @@ func (s *controllerSuite) TestNewControllerKnownVersion(c *gc.C) {
 	})
 }
 
+func (s *controllerSuite) TestNewControllerAPIVersion(c *gc.C) {
+	c.Assert(s.getController(c).APIVersion(), gc.Equals, "2.0")
+}
+
+// requestedVersions counts the requests made to each API version.
+func (s *controllerSuite) requestedVersions() map[string]int {
+	versions := make(map[string]int)
+	for _, request := range s.server.LastNRequests(s.server.RequestCount()) {
+		versions[strings.Split(request.URL.Path, "/")[2]]++
+	}
+	return versions
+}
+
+func (s *controllerSuite) TestNewControllerCachesNegotiatedVersion(c *gc.C) {
+	s.PatchValue(&supportedAPIVersions, []string{"2.1", "2.0"})
+	s.PatchValue(&negotiatedVersions.versions, make(map[string]string))
+	s.server.ResetRequests()
+
+	controller := s.getController(c)
+	c.Assert(controller.APIVersion(), gc.Equals, "2.0")
+	c.Assert(s.requestedVersions()["2.1"], gc.Equals, 1)
+
+	// The unsupported version isn't probed again. Each response is only
+	// served once, so the second controller needs its own.
+	s.server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	s.server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	s.server.ResetRequests()
+	controller = s.getController(c)
+	c.Assert(controller.APIVersion(), gc.Equals, "2.0")
+	c.Assert(s.requestedVersions(), jc.DeepEquals, map[string]int{"2.0": 2})
+}
+
+func (s *controllerSuite) TestNewControllerForgetsUnsupportedVersion(c *gc.C) {
+	s.PatchValue(&supportedAPIVersions, []string{"2.1", "2.0"})
+	s.PatchValue(&negotiatedVersions.versions, map[string]string{s.server.URL: "2.1"})
+	s.server.ResetRequests()
+
+	controller := s.getController(c)
+	c.Assert(controller.APIVersion(), gc.Equals, "2.0")
+	// The cached version is only tried once.
+	c.Assert(s.requestedVersions()["2.1"], gc.Equals, 1)
+	apiVersion, ok := cachedAPIVersion(s.server.URL)
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(apiVersion, gc.Equals, "2.0")
+}
+
+func (s *controllerSuite) TestNewControllerUsesServerVersion(c *gc.C) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponseFor("2.5.0"))
+	server.Start()
+	defer server.Close()
+
+	versionedController, err := NewController(ControllerArgs{
+		BaseURL: server.URL,
+		APIKey:  "fake:as:key",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	rawController, ok := versionedController.(*controller)
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(rawController.apiVersion, gc.Equals, version.Number{
+		Major: 2,
+		Minor: 0,
+	})
+	c.Assert(rawController.release, gc.Equals, version.Number{
+		Major: 2,
+		Minor: 5,
+	})
+	c.Assert(rawController.schemaVersion(), gc.Equals, rawController.release)
+	c.Assert(versionedController.APIVersion(), gc.Equals, "2.0")
+}
+
+func (*controllerSuite) TestParseServerRelease(c *gc.C) {
+	for _, test := range []struct {
+		value    string
+		expected version.Number
+	}{
+		{"2.4.2", version.Number{Major: 2, Minor: 4, Patch: 2}},
+		{"2.9.2~rc1", version.Number{Major: 2, Minor: 9, Patch: 2}},
+		{"2.3", version.Number{Major: 2, Minor: 3}},
+		{"unknown", version.Zero},
+	} {
+		c.Check(parseServerRelease(test.value), gc.Equals, test.expected)
+	}
+}
+
+func (s *controllerSuite) getControllerForRelease(c *gc.C, release string) (*SimpleTestServer, Controller) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponseFor(release))
+	server.Start()
+	s.AddCleanup(func(*gc.C) { server.Close() })
+
+	controller, err := NewController(ControllerArgs{
+		BaseURL: server.URL,
+		APIKey:  "fake:as:key",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	server.ResetRequests()
+	return server, controller
+}
+
+func (s *controllerSuite) TestServerVersion(c *gc.C) {
+	_, controller := s.getControllerForRelease(c, "2.9.2~rc1")
+	serverVersion := controller.ServerVersion()
+	c.Assert(serverVersion.Version, gc.Equals, version.Number{Major: 2, Minor: 9, Patch: 2})
+	c.Assert(serverVersion.Subversion, gc.Equals, "")
+	c.Assert(serverVersion.Capabilities, jc.DeepEquals, controller.Capabilities())
+	c.Assert(serverVersion.Capabilities.Contains(NetworksManagement), jc.IsTrue)
+}
+
+func (s *controllerSuite) TestServerVersionUnknown(c *gc.C) {
+	controller := s.getController(c)
+	c.Assert(controller.ServerVersion().Version, gc.Equals, version.Zero)
+}
+
+func (s *controllerSuite) TestPools(c *gc.C) {
+	server, controller := s.getControllerForRelease(c, "2.3.0")
+	server.AddGetResponse("/api/2.0/resourcepools/", http.StatusOK, poolsResponse)
+
+	pools, err := controller.Pools()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(pools, gc.HasLen, 2)
+	c.Assert(pools[1].Name(), gc.Equals, "swimming")
+}
+
+func (s *controllerSuite) TestPoolsUnsupported(c *gc.C) {
+	server, controller := s.getControllerForRelease(c, "2.2.0")
+
+	_, err := controller.Pools()
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+	c.Assert(err.Error(), gc.Equals, "resource pools before MAAS 2.3 not supported")
+	c.Assert(server.RequestCount(), gc.Equals, 0)
+}
+
 func (s *controllerSuite) TestNewControllerUnsupportedVersionSpecified(c *gc.C) {
 	// Ensure the server would actually respond to the version if it
 	// was asked.
@@ -178,7 +352,7 @@ func (s *controllerSuite) TestNewControllerNotHidingErrors(c *gc.C) {
 		APIKey:  "fake:as:key",
 	})
 	c.Assert(controller, gc.IsNil)
-	c.Assert(err, gc.ErrorMatches, `ServerError: 500 Internal Server Error \(kablooey\)`)
+	c.Assert(err, gc.ErrorMatches, `request [0-9a-f]+-[0-9a-f]+: ServerError: 500 Internal Server Error \(kablooey\)`)
 }
 
 func (s *controllerSuite) TestNewController410(c *gc.C) {
@@ -238,6 +412,164 @@ func (s *controllerSuite) TestNewControllerWith194Bug(c *gc.C) {
 	c.Assert(err, jc.Satisfies, IsUnsupportedVersionError)
 }
 
+type stubCredentialProvider struct {
+	keys    []string
+	err     error
+	calls   int
+	lastCtx context.Context
+}
+
+func (p *stubCredentialProvider) GetAPIKey(ctx context.Context) (string, error) {
+	p.calls++
+	p.lastCtx = ctx
+	if p.err != nil {
+		return "", p.err
+	}
+	key := p.keys[0]
+	if len(p.keys) > 1 {
+		p.keys = p.keys[1:]
+	}
+	return key, nil
+}
+
+// getControllerWithCredentials returns a controller using the provider,
+// backed by a new test server that only knows about versions and users.
+func (s *controllerSuite) TestNewControllerReadsUsername(c *gc.C) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `{"username": "thumper", "is_superuser": false}`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.Start()
+	defer server.Close()
+
+	versionedController, err := NewController(ControllerArgs{
+		BaseURL: server.URL,
+		APIKey:  "fake:as:key",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(versionedController.(*controller).username, gc.Equals, "thumper")
+}
+
+func (s *controllerSuite) getControllerWithCredentials(c *gc.C, provider CredentialProvider) (*SimpleTestServer, Controller) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.Start()
+	s.AddCleanup(func(*gc.C) { server.Close() })
+
+	controller, err := NewController(ControllerArgs{
+		BaseURL:            server.URL,
+		CredentialProvider: provider,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	server.ResetRequests()
+	return server, controller
+}
+
+func authorizationToken(request *http.Request) string {
+	header := strings.TrimPrefix(request.Header.Get("Authorization"), "OAuth ")
+	for _, part := range strings.Split(header, ", ") {
+		if strings.HasPrefix(part, "oauth_token=") {
+			return strings.Trim(strings.TrimPrefix(part, "oauth_token="), `"`)
+		}
+	}
+	return ""
+}
+
+func (s *controllerSuite) TestNewControllerCredentialProvider(c *gc.C) {
+	provider := &stubCredentialProvider{keys: []string{"fake:first:key"}}
+	server, controller := s.getControllerWithCredentials(c, provider)
+	c.Assert(provider.calls, gc.Equals, 1)
+	server.AddGetResponse("/api/2.0/zones/", http.StatusOK, zoneResponse)
+	_, err := controller.Zones()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(authorizationToken(server.LastRequest()), gc.Equals, "first")
+}
+
+func (s *controllerSuite) TestNewControllerCredentialProviderError(c *gc.C) {
+	provider := &stubCredentialProvider{err: errors.New("vault sealed")}
+	_, err := NewController(ControllerArgs{
+		BaseURL:            s.server.URL,
+		CredentialProvider: provider,
+	})
+	c.Assert(err, gc.ErrorMatches, "getting API key: vault sealed")
+}
+
+func (s *controllerSuite) TestCredentialsRefreshedOnUnauthorized(c *gc.C) {
+	provider := &stubCredentialProvider{keys: []string{"fake:first:key", "fake:second:key"}}
+	server, controller := s.getControllerWithCredentials(c, provider)
+	server.AddGetResponse("/api/2.0/zones/", http.StatusUnauthorized, "expired")
+	server.AddGetResponse("/api/2.0/zones/", http.StatusOK, zoneResponse)
+
+	zones, err := controller.Zones()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(zones, gc.HasLen, 2)
+	c.Assert(provider.calls, gc.Equals, 2)
+	requests := server.LastNRequests(2)
+	c.Assert(requests, gc.HasLen, 2)
+	c.Assert(authorizationToken(requests[0]), gc.Equals, "first")
+	c.Assert(authorizationToken(requests[1]), gc.Equals, "second")
+}
+
+type contextKey string
+
+func (s *controllerSuite) TestCredentialsRefreshedWithRequestContext(c *gc.C) {
+	provider := &stubCredentialProvider{keys: []string{"fake:first:key", "fake:second:key"}}
+	server, ctrl := s.getControllerWithCredentials(c, provider)
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusUnauthorized, "expired")
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+
+	ctx := context.WithValue(context.Background(), contextKey("caller"), "thumper")
+	_, err := ctrl.(*controller)._getContext(ctx, "users", "whoami", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(provider.calls, gc.Equals, 2)
+	c.Assert(provider.lastCtx.Value(contextKey("caller")), gc.Equals, "thumper")
+}
+
+func (s *controllerSuite) TestCredentialsRefreshedOnlyOnce(c *gc.C) {
+	provider := &stubCredentialProvider{keys: []string{"fake:first:key", "fake:second:key"}}
+	server, controller := s.getControllerWithCredentials(c, provider)
+	server.AddPostResponse("/api/2.0/machines/?op=release", http.StatusUnauthorized, "expired")
+	server.AddPostResponse("/api/2.0/machines/?op=release", http.StatusUnauthorized, "still expired")
+
+	err := controller.ReleaseMachines(ReleaseMachinesArgs{SystemIDs: []string{"this"}})
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+	c.Assert(provider.calls, gc.Equals, 2)
+	c.Assert(server.RequestCount(), gc.Equals, 2)
+}
+
+func (s *controllerSuite) TestCredentialsRefreshFailure(c *gc.C) {
+	provider := &stubCredentialProvider{keys: []string{"fake:first:key"}}
+	server, controller := s.getControllerWithCredentials(c, provider)
+	provider.err = errors.New("vault sealed")
+	server.AddGetResponse("/api/2.0/zones/", http.StatusUnauthorized, "expired")
+
+	_, err := controller.Zones()
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+	c.Assert(err, gc.ErrorMatches, "expired")
+	c.Assert(server.RequestCount(), gc.Equals, 1)
+}
+
+func (s *controllerSuite) TestNoCredentialProviderNoRetry(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/zones/", http.StatusUnauthorized, "expired")
+	server.ResetRequests()
+
+	_, err := controller.Zones()
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+	c.Assert(server.RequestCount(), gc.Equals, 1)
+}
+
+func (s *controllerSuite) TestNewControllerSignatureMethod(c *gc.C) {
+	_, err := NewController(ControllerArgs{
+		BaseURL:         s.server.URL,
+		APIKey:          "fake:as:key",
+		SignatureMethod: HMACSHA1SignatureMethod,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	header := s.server.LastRequest().Header.Get("Authorization")
+	c.Assert(header, jc.Contains, `oauth_signature_method="HMAC-SHA1"`)
+}
+
 func (s *controllerSuite) TestBootResources(c *gc.C) {
 	controller := s.getController(c)
 	resources, err := controller.BootResources()
@@ -252,6 +584,20 @@ func (s *controllerSuite) TestDevices(c *gc.C) {
 	c.Assert(devices, gc.HasLen, 1)
 }
 
+func (s *controllerSuite) TestDevicesOwner(c *gc.C) {
+	controller := s.getController(c)
+	s.server.AddGetResponse("/api/2.0/devices/", http.StatusOK, devicesResponse)
+	devices, err := controller.Devices(DevicesArgs{Owner: "thumper"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(devices, gc.HasLen, 1)
+
+	devices, err = controller.Devices(DevicesArgs{Owner: "someone"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(devices, gc.HasLen, 0)
+	// The owner isn't sent to MAAS.
+	c.Assert(s.server.LastRequest().URL.RawQuery, gc.Equals, "")
+}
+
 func (s *controllerSuite) TestDevicesArgs(c *gc.C) {
 	controller := s.getController(c)
 	// This will fail with a 404 due to the test server not having something  at
@@ -266,8 +612,38 @@ func (s *controllerSuite) TestDevicesArgs(c *gc.C) {
 		AgentName:    "agent 42",
 	})
 	request := s.server.LastRequest()
-	// There should be one entry in the form values for each of the args.
-	c.Assert(request.URL.Query(), gc.HasLen, 6)
+	// There should be one entry in the form values for each of the args,
+	// with the names MAAS expects.
+	c.Assert(request.URL.Query(), jc.DeepEquals, url.Values{
+		"hostname":    {"untasted-markita"},
+		"mac_address": {"something"},
+		"id":          {"something-else"},
+		"domain":      {"magic"},
+		"zone":        {"foo"},
+		"agent_name":  {"agent 42"},
+	})
+}
+
+func (s *controllerSuite) TestDevicesParent(c *gc.C) {
+	controller := s.getController(c)
+	s.server.AddGetResponse("/api/2.0/devices/", http.StatusOK, devicesResponse)
+	devices, err := controller.Devices(DevicesArgs{Parent: "4y3ha3"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(devices, gc.HasLen, 1)
+
+	devices, err = controller.Devices(DevicesArgs{Parent: "other"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(devices, gc.HasLen, 0)
+}
+
+func (s *controllerSuite) TestDevicesParamsOwnerAndParent(c *gc.C) {
+	args := DevicesArgs{Owner: "thumper", Parent: "4y3ha3"}
+	// MAAS only filters devices by owner and parent from 2.5.
+	c.Assert(devicesParams(args, twoDotFour), gc.HasLen, 0)
+	c.Assert(devicesParams(args, twoDotFive), jc.DeepEquals, url.Values{
+		"owner":  {"thumper"},
+		"parent": {"4y3ha3"},
+	})
 }
 
 func (s *controllerSuite) TestCreateDevice(c *gc.C) {
@@ -297,6 +673,19 @@ func (s *controllerSuite) TestCreateDeviceBadRequest(c *gc.C) {
 	c.Assert(err.Error(), gc.Equals, "some error")
 }
 
+func (s *controllerSuite) TestCreateDeviceBadRequestFieldErrors(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/devices/?op=", http.StatusBadRequest, `{"hostname": ["Invalid hostname"]}`)
+	controller := s.getController(c)
+	_, err := controller.CreateDevice(CreateDeviceArgs{
+		MACAddresses: []string{"a-mac-address"},
+	})
+	c.Assert(err, jc.Satisfies, IsBadRequestError)
+	badRequest := errors.Cause(err).(*BadRequestError)
+	c.Assert(badRequest.FieldErrors, jc.DeepEquals, map[string][]string{
+		"hostname": {"Invalid hostname"},
+	})
+}
+
 func (s *controllerSuite) TestCreateDeviceArgs(c *gc.C) {
 	s.server.AddPostResponse("/api/2.0/devices/?op=", http.StatusOK, deviceResponse)
 	controller := s.getController(c)
@@ -315,18 +704,307 @@ func (s *controllerSuite) TestCreateDeviceArgs(c *gc.C) {
 	c.Assert(request.PostForm, gc.HasLen, 4)
 }
 
-func (s *controllerSuite) TestFabrics(c *gc.C) {
+func (s *controllerSuite) TestCreateDeviceQualifiedHostname(c *gc.C) {
+	response := updateJSONMap(c, deviceResponse, map[string]interface{}{
+		"hostname":    "web",
+		"fqdn":        "web.example.com",
+		"description": "frontend",
+	})
+	s.server.AddPostResponse("/api/2.0/devices/?op=", http.StatusOK, response)
+	controller := s.getController(c)
+	device, err := controller.CreateDevice(CreateDeviceArgs{
+		Hostname:     "web.example.com",
+		MACAddresses: []string{"an-address"},
+		Zone:         "zone-a",
+		Description:  "frontend",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(device.Hostname(), gc.Equals, "web")
+	c.Check(device.FQDN(), gc.Equals, "web.example.com")
+	c.Check(device.Description(), gc.Equals, "frontend")
+
+	form := s.server.LastRequest().PostForm
+	c.Check(form.Get("hostname"), gc.Equals, "web")
+	c.Check(form.Get("domain"), gc.Equals, "example.com")
+	c.Check(form.Get("zone"), gc.Equals, "zone-a")
+	c.Check(form.Get("description"), gc.Equals, "frontend")
+}
+
+func (s *controllerSuite) TestCreateDeviceGeneratedHostname(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/devices/?op=", http.StatusOK, deviceResponse)
+	controller := s.getController(c)
+	device, err := controller.CreateDevice(CreateDeviceArgs{
+		MACAddresses: []string{"an-address"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	// The generated hostname comes from the creation response.
+	c.Check(device.Hostname(), gc.Equals, "furnacelike-brittney")
+	c.Check(device.FQDN(), gc.Equals, "furnacelike-brittney.maas")
+
+	_, ok := s.server.LastRequest().PostForm["hostname"]
+	c.Check(ok, jc.IsFalse)
+}
+
+func (s *controllerSuite) TestCreateDeviceNotValid(c *gc.C) {
+	controller := s.getController(c)
+	count := s.server.RequestCount()
+	_, err := controller.CreateDevice(CreateDeviceArgs{
+		Hostname:     "web.example.com",
+		Domain:       "maas",
+		MACAddresses: []string{"an-address"},
+	})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(err, gc.ErrorMatches, `Hostname "web.example.com" with Domain "maas" not valid`)
+	c.Assert(s.server.RequestCount(), gc.Equals, count)
+}
+
+func (*controllerSuite) TestCreateDeviceArgsValidate(c *gc.C) {
+	for i, test := range []struct {
+		args   CreateDeviceArgs
+		errMsg string
+	}{{
+		args: CreateDeviceArgs{},
+	}, {
+		args: CreateDeviceArgs{Hostname: "web-1"},
+	}, {
+		args: CreateDeviceArgs{Hostname: "web-1", Domain: "example.com"},
+	}, {
+		args: CreateDeviceArgs{Hostname: "web.example.com", Domain: "example.com"},
+	}, {
+		args:   CreateDeviceArgs{Hostname: "-web"},
+		errMsg: `Hostname "-web" not valid`,
+	}, {
+		args:   CreateDeviceArgs{Hostname: "web_1"},
+		errMsg: `Hostname "web_1" not valid`,
+	}, {
+		args:   CreateDeviceArgs{Hostname: "web."},
+		errMsg: `Hostname "web." not valid`,
+	}, {
+		args:   CreateDeviceArgs{Hostname: "web..com"},
+		errMsg: `Hostname "web..com" not valid`,
+	}, {
+		args:   CreateDeviceArgs{Hostname: strings.Repeat("a", 64)},
+		errMsg: `Hostname "a+" not valid`,
+	}} {
+		c.Logf("test %d", i)
+		err := test.args.Validate()
+		if test.errMsg == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, jc.Satisfies, errors.IsNotValid)
+			c.Check(err, gc.ErrorMatches, test.errMsg)
+		}
+	}
+}
+
+func (s *controllerSuite) TestFabrics(c *gc.C) {
+	controller := s.getController(c)
+	fabrics, err := controller.Fabrics()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(fabrics, gc.HasLen, 2)
+}
+
+func (s *controllerSuite) TestSpaces(c *gc.C) {
+	controller := s.getController(c)
+	spaces, err := controller.Spaces()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(spaces, gc.HasLen, 1)
+}
+
+func (s *controllerSuite) TestSubnetsInSpace(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, subnetResponse)
+	controller := s.getController(c)
+	subnets, err := controller.SubnetsInSpace("space-0")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(subnets, gc.HasLen, 2)
+
+	// The subnets are cached.
+	s.server.ResetRequests()
+	subnets, err = controller.SubnetsInSpace("space-0")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(subnets, gc.HasLen, 2)
+	c.Assert(s.server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *controllerSuite) TestSubnetsInSpaceCacheExpires(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, subnetResponse)
+	s.server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, subnetResponse)
+	ctrl := s.getController(c)
+	_, err := ctrl.SubnetsInSpace("space-0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	ctrl.(*controller).subnetsRead = time.Now().Add(-subnetCacheTTL)
+	s.server.ResetRequests()
+	subnets, err := ctrl.SubnetsInSpace("space-0")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(subnets, gc.HasLen, 2)
+	c.Assert(s.server.RequestCount(), gc.Equals, 1)
+}
+
+func (s *controllerSuite) TestCreateSubnetInvalidatesCache(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, subnetResponse)
+	s.server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, subnetResponse)
+	s.server.AddPostResponse("/api/2.0/subnets/?op=", http.StatusOK, ipv6SubnetResponse)
+	controller := s.getController(c)
+	_, err := controller.SubnetsInSpace("space-0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	_, err = controller.CreateSubnet(CreateSubnetArgs{CIDR: "2001:db8::/64"})
+	c.Assert(err, jc.ErrorIsNil)
+	s.server.ResetRequests()
+	_, err = controller.SubnetsInSpace("space-0")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.server.RequestCount(), gc.Equals, 1)
+}
+
+func (s *controllerSuite) TestSubnetsInSpaceNoMatch(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, subnetResponse)
+	s.server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, subnetResponse)
+	controller := s.getController(c)
+	s.server.ResetRequests()
+	subnets, err := controller.SubnetsInSpace("space-1")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(subnets, gc.HasLen, 0)
+	// The cache is refreshed once when nothing matches.
+	c.Assert(s.server.RequestCount(), gc.Equals, 2)
+}
+
+func (s *controllerSuite) TestSubnetByCIDR(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, subnetResponse)
+	controller := s.getController(c)
+	subnet, err := controller.SubnetByCIDR("192.168.122.0/24")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(subnet.ID(), gc.Equals, 34)
+}
+
+func (s *controllerSuite) TestSubnetByCIDRRefreshes(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, "[]")
+	s.server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, subnetResponse)
+	controller := s.getController(c)
+	subnet, err := controller.SubnetByCIDR("192.168.100.0/24")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(subnet.ID(), gc.Equals, 1)
+}
+
+func (s *controllerSuite) TestSubnetByCIDRMissing(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, subnetResponse)
+	s.server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, subnetResponse)
+	controller := s.getController(c)
+	_, err := controller.SubnetByCIDR("10.0.0.0/8")
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+	c.Assert(err.Error(), gc.Equals, `subnet "10.0.0.0/8" not found`)
+}
+
+func (s *controllerSuite) TestSubnetByCIDRRetryable(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/subnets/", http.StatusTooManyRequests, "slow down")
+	controller := s.getController(c)
+	_, err := controller.SubnetByCIDR("192.168.100.0/24")
+	c.Assert(err, jc.Satisfies, IsUnexpectedError)
+	c.Assert(err, jc.Satisfies, IsRetryable)
+}
+
+func (s *controllerSuite) TestCreateSubnet(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/subnets/?op=", http.StatusOK, ipv6SubnetResponse)
+	controller := s.getController(c)
+	mode := RDNSModeEnabled
+	subnet, err := controller.CreateSubnet(CreateSubnetArgs{
+		CIDR:       "2001:db8::/64",
+		Gateway:    "2001:db8::1",
+		DNSServers: []string{"2001:db8::53"},
+		RDNSMode:   &mode,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(subnet.ID(), gc.Equals, 7)
+	c.Check(subnet.IsIPv6(), jc.IsTrue)
+	c.Check(subnet.RDNSMode(), gc.Equals, RDNSModeEnabled)
+
+	form := s.server.LastRequest().PostForm
+	c.Check(form.Get("cidr"), gc.Equals, "2001:db8::/64")
+	c.Check(form.Get("gateway_ip"), gc.Equals, "2001:db8::1")
+	c.Check(form.Get("dns_servers"), gc.Equals, "2001:db8::53")
+	c.Check(form.Get("rdns_mode"), gc.Equals, "1")
+	c.Check(form["vlan"], gc.HasLen, 0)
+}
+
+func (s *controllerSuite) TestCreateSubnetRDNSModeDisabled(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/subnets/?op=", http.StatusOK, ipv6SubnetResponse)
+	controller := s.getController(c)
+	mode := RDNSModeDisabled
+	_, err := controller.CreateSubnet(CreateSubnetArgs{
+		CIDR:     "2001:db8::/64",
+		RDNSMode: &mode,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(s.server.LastRequest().PostForm.Get("rdns_mode"), gc.Equals, "0")
+}
+
+func (s *controllerSuite) TestCreateSubnetValidates(c *gc.C) {
+	controller := s.getController(c)
+	s.server.ResetRequests()
+	mode := RDNSMode(-1)
+	for _, args := range []CreateSubnetArgs{
+		{},
+		{CIDR: "2001:db8::"},
+		{CIDR: "2001:db8::/64", Gateway: "192.168.100.1"},
+		{CIDR: "2001:db8::/64", RDNSMode: &mode},
+	} {
+		_, err := controller.CreateSubnet(args)
+		c.Check(err, jc.Satisfies, errors.IsNotValid)
+	}
+	c.Check(s.server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *controllerSuite) TestCreateSubnetBadRequest(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/subnets/?op=", http.StatusBadRequest, "overlapping subnet")
+	controller := s.getController(c)
+	_, err := controller.CreateSubnet(CreateSubnetArgs{CIDR: "2001:db8::/64"})
+	c.Assert(err, jc.Satisfies, IsBadRequestError)
+	c.Assert(err.Error(), gc.Equals, "overlapping subnet")
+}
+
+func (s *controllerSuite) TestSubnetUpdate(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, subnetResponse)
+	response := updateJSONMap(c, ipv6SubnetResponse, map[string]interface{}{
+		"rdns_mode": 0,
+	})
+	s.server.AddPutResponse("/MAAS/api/2.0/subnets/1/", http.StatusOK, response)
+	controller := s.getController(c)
+	subnet, err := controller.SubnetByCIDR("192.168.100.0/24")
+	c.Assert(err, jc.ErrorIsNil)
+
+	mode := RDNSModeDisabled
+	err = subnet.Update(UpdateSubnetArgs{RDNSMode: &mode})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(subnet.RDNSMode(), gc.Equals, RDNSModeDisabled)
+	form := s.server.LastRequest().PostForm
+	c.Check(form.Get("rdns_mode"), gc.Equals, "0")
+	c.Check(form["name"], gc.HasLen, 0)
+	c.Check(form["dns_servers"], gc.HasLen, 0)
+}
+
+func (s *controllerSuite) TestSubnetUpdateForbidden(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, subnetResponse)
+	s.server.AddPutResponse("/MAAS/api/2.0/subnets/1/", http.StatusForbidden, "bad user")
 	controller := s.getController(c)
-	fabrics, err := controller.Fabrics()
+	subnet, err := controller.SubnetByCIDR("192.168.100.0/24")
 	c.Assert(err, jc.ErrorIsNil)
-	c.Assert(fabrics, gc.HasLen, 2)
+
+	err = subnet.Update(UpdateSubnetArgs{Name: "new"})
+	c.Check(err, jc.Satisfies, IsPermissionError)
 }
 
-func (s *controllerSuite) TestSpaces(c *gc.C) {
+func (s *controllerSuite) TestVLANSubnets(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, subnetResponse)
 	controller := s.getController(c)
 	spaces, err := controller.Spaces()
 	c.Assert(err, jc.ErrorIsNil)
-	c.Assert(spaces, gc.HasLen, 1)
+	vlan := spaces[0].Subnets()[0].VLAN()
+	c.Assert(vlan.ID(), gc.Equals, 5001)
+
+	subnets, err := vlan.Subnets()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(subnets, gc.HasLen, 1)
+	c.Assert(subnets[0].CIDR(), gc.Equals, "192.168.122.0/24")
 }
 
 func (s *controllerSuite) TestStaticRoutes(c *gc.C) {
@@ -412,8 +1090,112 @@ func (s *controllerSuite) TestMachinesArgs(c *gc.C) {
 		AgentName:    "agent 42",
 	})
 	request := s.server.LastRequest()
-	// There should be one entry in the form values for each of the args.
-	c.Assert(request.URL.Query(), gc.HasLen, 6)
+	// There should be one entry in the form values for each of the args,
+	// with the names MAAS expects.
+	c.Assert(request.URL.Query(), jc.DeepEquals, url.Values{
+		"hostname":    {"untasted-markita"},
+		"mac_address": {"something"},
+		"id":          {"something-else"},
+		"domain":      {"magic"},
+		"zone":        {"foo"},
+		"agent_name":  {"agent 42"},
+	})
+}
+
+func (s *controllerSuite) TestMachinesFilterWithOwner(c *gc.C) {
+	controller := s.getController(c)
+	machines, err := controller.Machines(MachinesArgs{Owner: "thumper"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines, gc.HasLen, 1)
+	c.Assert(machines[0].Hostname(), gc.Equals, "untasted-markita")
+	// The owner isn't sent to MAAS before 2.5.
+	c.Assert(s.server.LastRequest().URL.RawQuery, gc.Equals, "")
+}
+
+func (s *controllerSuite) TestMachinesParamsOwner(c *gc.C) {
+	args := MachinesArgs{Owner: "thumper"}
+	c.Assert(machinesParams(args, twoDotFour), gc.HasLen, 0)
+	c.Assert(machinesParams(args, twoDotFive), jc.DeepEquals, url.Values{
+		"owner": {"thumper"},
+	})
+}
+
+func (s *controllerSuite) TestEachMachine(c *gc.C) {
+	controller := s.getController(c)
+	var hostnames []string
+	err := controller.EachMachine(MachinesArgs{}, func(m Machine) error {
+		hostnames = append(hostnames, m.Hostname())
+		return nil
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(hostnames, jc.DeepEquals, []string{"untasted-markita", "lowlier-glady", "icier-nina"})
+}
+
+func (s *controllerSuite) TestEachMachineFilterWithOwnerData(c *gc.C) {
+	controller := s.getController(c)
+	var hostnames []string
+	err := controller.EachMachine(MachinesArgs{
+		OwnerData: map[string]string{
+			"braid": "jonathan blow",
+		},
+	}, func(m Machine) error {
+		hostnames = append(hostnames, m.Hostname())
+		return nil
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(hostnames, jc.DeepEquals, []string{"lowlier-glady", "icier-nina"})
+}
+
+func (s *controllerSuite) TestEachMachineStops(c *gc.C) {
+	controller := s.getController(c)
+	stop := errors.New("stop")
+	count := 0
+	err := controller.EachMachine(MachinesArgs{}, func(m Machine) error {
+		count++
+		return stop
+	})
+	c.Assert(errors.Cause(err), gc.Equals, stop)
+	c.Assert(count, gc.Equals, 1)
+}
+
+func (s *controllerSuite) TestEachMachineSummary(c *gc.C) {
+	controller := s.getController(c)
+	count := 0
+	err := controller.EachMachine(MachinesArgs{Projection: ProjectSummary}, func(m Machine) error {
+		count++
+		c.Check(m.InterfaceSet(), gc.HasLen, 0)
+		return nil
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(count, gc.Equals, 3)
+}
+
+func (s *controllerSuite) TestEachMachineSortBy(c *gc.C) {
+	controller := s.getController(c)
+	err := controller.EachMachine(MachinesArgs{SortBy: SortByHostname}, func(Machine) error {
+		c.Fatalf("unexpected machine")
+		return nil
+	})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *controllerSuite) TestEachMachineServerError(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/machines/?hostname=boom", http.StatusInternalServerError, "boom")
+	controller := s.getController(c)
+	err := controller.EachMachine(MachinesArgs{Hostnames: []string{"boom"}}, func(Machine) error {
+		c.Fatalf("unexpected machine")
+		return nil
+	})
+	c.Assert(err, jc.Satisfies, IsUnexpectedError)
+}
+
+func (s *controllerSuite) TestEachMachineBadJSON(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/machines/?hostname=bad", http.StatusOK, `{"not": "a list"}`)
+	controller := s.getController(c)
+	err := controller.EachMachine(MachinesArgs{Hostnames: []string{"bad"}}, func(Machine) error {
+		return nil
+	})
+	c.Assert(err, jc.Satisfies, IsUnexpectedError)
 }
 
 func (s *controllerSuite) TestStorageSpec(c *gc.C) {
@@ -465,10 +1247,40 @@ func (s *controllerSuite) TestInterfaceSpec(c *gc.C) {
 		err:  "missing Label not valid",
 	}, {
 		spec: InterfaceSpec{Label: "foo"},
-		err:  "empty Space constraint not valid",
+		err:  "empty constraints not valid",
 	}, {
 		spec: InterfaceSpec{Label: "foo", Space: "magic"},
 		repr: "foo:space=magic",
+	}, {
+		spec: InterfaceSpec{Label: "foo", Fabrics: []string{"fabric-0"}},
+		repr: "foo:fabric=fabric-0",
+	}, {
+		spec: InterfaceSpec{
+			Label:            "foo",
+			Space:            "magic",
+			Fabrics:          []string{"fabric-0", "fabric-1"},
+			NotFabrics:       []string{"fabric-2"},
+			FabricClasses:    []string{"10g"},
+			NotFabricClasses: []string{"1g"},
+			SubnetCIDRs:      []string{"10.0.0.0/24"},
+			NotSubnetCIDRs:   []string{"10.0.1.0/24"},
+			VIDs:             []int{0, 10},
+			NotVIDs:          []int{20},
+			Mode:             InterfaceSpecModeUnconfigured,
+		},
+		repr: "foo:space=magic,fabric=fabric-0,fabric=fabric-1,not_fabric=fabric-2," +
+			"fabric_class=10g,not_fabric_class=1g," +
+			"subnet_cidr=10.0.0.0/24,not_subnet_cidr=10.0.1.0/24," +
+			"vid=0,vid=10,not_vid=20,mode=unconfigured",
+	}, {
+		spec: InterfaceSpec{Label: "foo", SubnetCIDRs: []string{"10.0.0.0"}},
+		err:  `subnet CIDR "10.0.0.0" not valid`,
+	}, {
+		spec: InterfaceSpec{Label: "foo", NotVIDs: []int{4095}},
+		err:  "VID 4095 not valid",
+	}, {
+		spec: InterfaceSpec{Label: "foo", Space: "magic", Mode: "static"},
+		err:  `Mode "static" not valid`,
 	}} {
 		c.Logf("test %d", i)
 		err := test.spec.Validate()
@@ -545,6 +1357,16 @@ func (s *controllerSuite) TestAllocateMachineArgs(c *gc.C) {
 			NotSpace: []string{"foo", "bar"},
 		},
 		notSubnets: []string{"space:foo", "space:bar"},
+	}, {
+		args: AllocateMachineArgs{
+			Verbose: true,
+		},
+		err: "Verbose without DryRun not valid",
+	}, {
+		args: AllocateMachineArgs{
+			DryRun:  true,
+			Verbose: true,
+		},
 	}} {
 		c.Logf("test %d", i)
 		err := test.args.Validate()
@@ -584,6 +1406,52 @@ func (s *controllerSuite) TestAllocateMachine(c *gc.C) {
 	c.Assert(machine.SystemID(), gc.Equals, "4y3ha3")
 }
 
+func (s *controllerSuite) getAgentController(c *gc.C) Controller {
+	controller, err := NewController(ControllerArgs{
+		BaseURL:          s.server.URL,
+		APIKey:           "fake:as:key",
+		DefaultAgentName: "juju-1",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	return controller
+}
+
+func (s *controllerSuite) TestAllocateMachineDefaultAgentName(c *gc.C) {
+	s.addAllocateResponse(c, http.StatusOK, nil, nil)
+	s.addAllocateResponse(c, http.StatusOK, nil, nil)
+	controller := s.getAgentController(c)
+
+	_, _, err := controller.AllocateMachine(AllocateMachineArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(s.server.LastRequest().PostForm.Get("agent_name"), gc.Equals, "juju-1")
+
+	_, _, err = controller.AllocateMachine(AllocateMachineArgs{AgentName: "terraform"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(s.server.LastRequest().PostForm.Get("agent_name"), gc.Equals, "terraform")
+}
+
+func (s *controllerSuite) TestMachinesOwnedByAgent(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/machines/?agent_name=juju-1", http.StatusOK, machinesResponse)
+	s.server.AddGetResponse("/api/2.0/machines/?agent_name=terraform", http.StatusOK, "[]")
+	controller := s.getAgentController(c)
+
+	machines, err := controller.MachinesOwnedByAgent("")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machines, gc.HasLen, 3)
+
+	machines, err = controller.MachinesOwnedByAgent("terraform")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machines, gc.HasLen, 0)
+}
+
+func (s *controllerSuite) TestMachinesOwnedByAgentMissing(c *gc.C) {
+	controller := s.getController(c)
+	s.server.ResetRequests()
+	_, err := controller.MachinesOwnedByAgent("")
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(s.server.RequestCount(), gc.Equals, 0)
+}
+
 func (s *controllerSuite) TestAllocateMachineInterfacesMatch(c *gc.C) {
 	s.addAllocateResponse(c, http.StatusOK, constraintMatchInfo{
 		"database": []int{35, 99},
@@ -602,6 +1470,9 @@ func (s *controllerSuite) TestAllocateMachineInterfacesMatch(c *gc.C) {
 	c.Assert(ifaces, gc.HasLen, 2)
 	c.Assert(ifaces[0].ID(), gc.Equals, 35)
 	c.Assert(ifaces[1].ID(), gc.Equals, 99)
+	c.Assert(match.InterfaceSpecs, jc.DeepEquals, map[string]InterfaceSpec{
+		"database": {Label: "database", Space: "space-0"},
+	})
 }
 
 func (s *controllerSuite) TestAllocateMachineInterfacesMatchMissing(c *gc.C) {
@@ -689,9 +1560,12 @@ func (s *controllerSuite) TestAllocateMachineArgsForm(c *gc.C) {
 		NotSpace:     []string{"special"},
 		Zone:         "magic",
 		NotInZone:    []string{"not-magic"},
+		Pool:         "swimming",
+		NotPools:     []string{"paddling"},
 		AgentName:    "agent 42",
 		Comment:      "testing",
 		DryRun:       true,
+		Verbose:      true,
 	}
 	_, _, err := controller.AllocateMachine(args)
 	c.Assert(err, jc.ErrorIsNil)
@@ -699,13 +1573,71 @@ func (s *controllerSuite) TestAllocateMachineArgsForm(c *gc.C) {
 	request := s.server.LastRequest()
 	// There should be one entry in the form values for each of the args.
 	form := request.PostForm
-	c.Assert(form, gc.HasLen, 15)
+	c.Assert(form, gc.HasLen, 18)
+	c.Assert(form.Get("pool"), gc.Equals, "swimming")
+	c.Assert(form.Get("not_in_pool"), gc.Equals, "paddling")
+	c.Assert(form.Get("verbose"), gc.Equals, "true")
 	// Positive space check.
 	c.Assert(form.Get("interfaces"), gc.Equals, "default:space=magic")
 	// Negative space check.
 	c.Assert(form.Get("not_subnets"), gc.Equals, "space:special")
 }
 
+func (s *controllerSuite) TestAllocateMachineReport(c *gc.C) {
+	allocateJSON := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"constraints_by_type": map[string]interface{}{
+			"storage": constraintMatchInfo{"root": {34}},
+			"verbose_storage": map[string]interface{}{
+				"3": constraintMatchInfo{"root": {34}},
+				"7": constraintMatchInfo{"root": {51, 52}},
+			},
+			"verbose_interfaces": map[string]interface{}{
+				"default": map[string][]int{"3": {35}, "7": {60}},
+			},
+		},
+	})
+	s.server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusOK, allocateJSON)
+	controller := s.getController(c)
+	_, matches, err := controller.AllocateMachine(AllocateMachineArgs{
+		DryRun:  true,
+		Verbose: true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(matches.Report, jc.DeepEquals, &AllocationReport{
+		Storage: map[string]map[int][]int{
+			"root": {3: {34}, 7: {51, 52}},
+		},
+		Interfaces: map[string]map[int][]int{
+			"default": {3: {35}, 7: {60}},
+		},
+	})
+}
+
+func (s *controllerSuite) TestAllocateMachineReportOnlyForVerboseDryRun(c *gc.C) {
+	s.addAllocateResponse(c, http.StatusOK, nil, nil)
+	controller := s.getController(c)
+	_, matches, err := controller.AllocateMachine(AllocateMachineArgs{DryRun: true})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(matches.Report, gc.IsNil)
+}
+
+func (s *controllerSuite) TestAllocateMachineReportBadMachineID(c *gc.C) {
+	allocateJSON := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"constraints_by_type": map[string]interface{}{
+			"verbose_storage": map[string]interface{}{
+				"wat": constraintMatchInfo{"root": {34}},
+			},
+		},
+	})
+	s.server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusOK, allocateJSON)
+	controller := s.getController(c)
+	_, _, err := controller.AllocateMachine(AllocateMachineArgs{
+		DryRun:  true,
+		Verbose: true,
+	})
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+}
+
 func (s *controllerSuite) TestAllocateMachineNoMatch(c *gc.C) {
 	s.server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusConflict, "boo")
 	controller := s.getController(c)
@@ -713,11 +1645,12 @@ func (s *controllerSuite) TestAllocateMachineNoMatch(c *gc.C) {
 	c.Assert(err, jc.Satisfies, IsNoMatchError)
 }
 
-func (s *controllerSuite) TestAllocateMachineUnexpected(c *gc.C) {
+func (s *controllerSuite) TestAllocateMachineBadRequest(c *gc.C) {
 	s.server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusBadRequest, "boo")
 	controller := s.getController(c)
 	_, _, err := controller.AllocateMachine(AllocateMachineArgs{})
-	c.Assert(err, jc.Satisfies, IsUnexpectedError)
+	c.Assert(err, jc.Satisfies, IsBadRequestError)
+	c.Assert(err.Error(), gc.Equals, "boo")
 }
 
 func (s *controllerSuite) TestReleaseMachines(c *gc.C) {
@@ -772,7 +1705,54 @@ func (s *controllerSuite) TestReleaseMachinesUnexpected(c *gc.C) {
 		SystemIDs: []string{"this", "that"},
 	})
 	c.Assert(err, jc.Satisfies, IsUnexpectedError)
-	c.Assert(err.Error(), gc.Equals, "unexpected: ServerError: 502 Bad Gateway (wat)")
+	c.Assert(err, gc.ErrorMatches, `unexpected: request [0-9a-f]+-[0-9a-f]+: ServerError: 502 Bad Gateway \(wat\)`)
+}
+
+func (s *controllerSuite) TestReleaseMachinesErase(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/machines/this/?op=release", http.StatusOK, "{}")
+	s.server.AddPostResponse("/api/2.0/machines/that/?op=release", http.StatusOK, "{}")
+	controller := s.getController(c)
+	err := controller.ReleaseMachines(ReleaseMachinesArgs{
+		SystemIDs:  []string{"this", "that"},
+		Comment:    "decommissioned",
+		Erase:      true,
+		QuickErase: true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+
+	// The machines are released one by one, with the erase options.
+	for _, request := range s.server.LastNRequests(2) {
+		c.Check(request.PostForm, jc.DeepEquals, url.Values{
+			"comment":     {"decommissioned"},
+			"erase":       {"true"},
+			"quick_erase": {"true"},
+		})
+	}
+}
+
+func (s *controllerSuite) TestReleaseMachinesEraseFailure(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/machines/this/?op=release", http.StatusOK, "{}")
+	s.server.AddPostResponse("/api/2.0/machines/that/?op=release", http.StatusConflict, "machine busy")
+	controller := s.getController(c)
+	err := controller.ReleaseMachines(ReleaseMachinesArgs{
+		SystemIDs: []string{"this", "that"},
+		Erase:     true,
+	})
+	c.Assert(err, jc.Satisfies, IsMultiError)
+	multi := errors.Cause(err).(*MultiError)
+	c.Assert(multi.Errors, gc.HasLen, 1)
+	c.Assert(multi.Errors["that"], jc.Satisfies, IsCannotCompleteError)
+}
+
+func (s *controllerSuite) TestReleaseMachinesValidates(c *gc.C) {
+	controller := s.getController(c)
+	s.server.ResetRequests()
+	err := controller.ReleaseMachines(ReleaseMachinesArgs{
+		SystemIDs:   []string{"this"},
+		SecureErase: true,
+	})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(s.server.RequestCount(), gc.Equals, 0)
 }
 
 func (s *controllerSuite) TestFiles(c *gc.C) {
@@ -913,7 +1893,11 @@ func (s *controllerSuite) TestAddFileReader(c *gc.C) {
 	s.assertFile(c, request, "foo.txt", "test\n")
 }
 
-var versionResponse = `{"version": "unknown", "subversion": "", "capabilities": ["networks-management", "static-ipaddresses", "ipv6-deployment-ubuntu", "devices-management", "storage-deployment-ubuntu", "network-deployment-ubuntu"]}`
+var versionResponse = versionResponseFor("unknown")
+
+func versionResponseFor(serverVersion string) string {
+	return fmt.Sprintf(`{"version": %q, "subversion": "", "capabilities": ["networks-management", "static-ipaddresses", "ipv6-deployment-ubuntu", "devices-management", "storage-deployment-ubuntu", "network-deployment-ubuntu"]}`, serverVersion)
+}
 
 type cleanup interface {
 	AddCleanup(func(*gc.C))
@@ -936,3 +1920,205 @@ func createTestServerController(c *gc.C, suite cleanup) (*SimpleTestServer, Cont
 	c.Assert(err, jc.ErrorIsNil)
 	return server, controller
 }
+
+func (s *controllerSuite) TestRaw(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/things/?name=foo", http.StatusOK, `{"name": "foo"}`)
+	controller := s.getController(c)
+
+	result, status, err := controller.Raw("GET", "things", "", url.Values{"name": {"foo"}}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(status, gc.Equals, http.StatusOK)
+	c.Check(string(result), gc.Equals, `{"name": "foo"}`)
+}
+
+func (s *controllerSuite) TestRawPostsParams(c *gc.C) {
+	s.server.AddPostResponse("/api/2.0/things/?op=frob", http.StatusOK, "{}")
+	controller := s.getController(c)
+
+	_, _, err := controller.Raw("POST", "things", "frob", url.Values{"level": {"11"}}, nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	request := s.server.LastRequest()
+	c.Check(request.PostForm.Get("level"), gc.Equals, "11")
+}
+
+func (s *controllerSuite) TestRawNotFound(c *gc.C) {
+	controller := s.getController(c)
+
+	_, status, err := controller.Raw("POST", "things", "frob", nil, nil)
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+	c.Check(status, gc.Equals, http.StatusNotFound)
+}
+
+func (s *controllerSuite) TestRawConflict(c *gc.C) {
+	s.server.AddPutResponse("/api/2.0/things/", http.StatusConflict, "busy")
+	controller := s.getController(c)
+
+	_, status, err := controller.Raw("PUT", "things", "", nil, strings.NewReader("content"))
+	c.Assert(err, jc.Satisfies, IsCannotCompleteError)
+	c.Check(err.Error(), gc.Equals, "busy")
+	c.Check(status, gc.Equals, http.StatusConflict)
+}
+
+func (s *controllerSuite) getStrictController(c *gc.C, zones string) Controller {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.AddGetResponse("/api/2.0/zones/", http.StatusOK, zones)
+	server.Start()
+	s.AddCleanup(func(*gc.C) { server.Close() })
+
+	controller, err := NewController(ControllerArgs{
+		BaseURL:        server.URL,
+		APIKey:         "fake:as:key",
+		StrictDecoding: true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	return controller
+}
+
+func (s *controllerSuite) TestStrictDecoding(c *gc.C) {
+	controller := s.getStrictController(c, zoneResponse)
+	zones, err := controller.Zones()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(zones, gc.HasLen, 2)
+}
+
+func (s *controllerSuite) TestStrictDecodingUnknownFields(c *gc.C) {
+	controller := s.getStrictController(c, `[
+	    {"name": "default", "description": "", "resource_uri": "/zones/default/", "id": 1}
+	]`)
+	_, err := controller.Zones()
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err.Error(), gc.Equals, "zone strict decoding failed: unknown fields: id")
+}
+
+func (s *controllerSuite) TestNewControllerBadRateLimit(c *gc.C) {
+	_, err := NewController(ControllerArgs{
+		BaseURL:    s.server.URL,
+		APIKey:     "fake:as:key",
+		RateLimits: map[string]RateLimit{"machines": {Rate: 0}},
+	})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(err.Error(), gc.Equals, `rate limit for "machines": rate 0 not valid`)
+}
+
+func (s *controllerSuite) TestRateLimits(c *gc.C) {
+	controller, err := NewController(ControllerArgs{
+		BaseURL: s.server.URL,
+		APIKey:  "fake:as:key",
+		RateLimits: map[string]RateLimit{
+			"machines":     {Rate: 1000, Burst: 10},
+			OtherEndpoints: {Rate: 1000},
+		},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	machines, err := controller.Machines(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines, gc.HasLen, 3)
+}
+
+func (s *controllerSuite) TestLogger(c *gc.C) {
+	target := &recordingLogger{}
+	controller, err := NewController(ControllerArgs{
+		BaseURL: s.server.URL,
+		APIKey:  "fake:as:key",
+		Logger:  target,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	target.messages = nil
+
+	_, err = controller.Zones()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(target.messages, gc.HasLen, 2)
+	c.Check(target.messages[0], gc.Matches, `TRACE request [0-9a-f]+-[0-9a-f]+: GET http://.*/api/2.0/zones/`)
+	c.Check(target.messages[1], gc.Matches, `(?s)TRACE response [0-9a-f]+-[0-9a-f]+: .*"name": "special".*`)
+}
+
+func (s *controllerSuite) TestRequestIDHeader(c *gc.C) {
+	controller := s.getController(c)
+	_, err := controller.Zones()
+	c.Assert(err, jc.ErrorIsNil)
+	first := s.server.LastRequest().Header.Get("X-Request-Id")
+	c.Assert(first, gc.Matches, `[0-9a-f]+-[0-9a-f]+`)
+
+	s.server.AddGetResponse("/api/2.0/zones/", http.StatusOK, zoneResponse)
+	_, err = controller.Zones()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.server.LastRequest().Header.Get("X-Request-Id"), gc.Not(gc.Equals), first)
+}
+
+func (s *controllerSuite) TestCustomRequestIDHeader(c *gc.C) {
+	controller, err := NewController(ControllerArgs{
+		BaseURL:         s.server.URL,
+		APIKey:          "fake:as:key",
+		RequestIDHeader: "X-Correlation-Id",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = controller.Zones()
+	c.Assert(err, jc.ErrorIsNil)
+	request := s.server.LastRequest()
+	c.Assert(request.Header.Get("X-Correlation-Id"), gc.Matches, `[0-9a-f]+-[0-9a-f]+`)
+	c.Assert(request.Header.Get("X-Request-Id"), gc.Equals, "")
+}
+
+func (s *controllerSuite) TestUserAgent(c *gc.C) {
+	controller, err := NewController(ControllerArgs{
+		BaseURL:   s.server.URL,
+		APIKey:    "fake:as:key",
+		UserAgent: "my-tool/1.2",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = controller.Zones()
+	c.Assert(err, jc.ErrorIsNil)
+	for _, request := range s.server.LastNRequests(3) {
+		c.Check(request.Header.Get("User-Agent"), gc.Equals, "my-tool/1.2")
+	}
+}
+
+func (s *controllerSuite) TestDefaultUserAgent(c *gc.C) {
+	controller := s.getController(c)
+	_, err := controller.Zones()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.server.LastRequest().Header.Get("User-Agent"), gc.Matches, "Go-http-client/.*")
+}
+
+func (s *controllerSuite) TestCompressionRequested(c *gc.C) {
+	controller := s.getController(c)
+	_, err := controller.Zones()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.server.LastRequest().Header.Get("Accept-Encoding"), gc.Equals, "gzip, deflate")
+}
+
+func (s *controllerSuite) TestDisableCompression(c *gc.C) {
+	controller, err := NewController(ControllerArgs{
+		BaseURL:            s.server.URL,
+		APIKey:             "fake:as:key",
+		DisableCompression: true,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = controller.Zones()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.server.LastRequest().Header.Get("Accept-Encoding"), gc.Equals, "identity")
+}
+
+func (s *controllerSuite) TestRequestIDInErrors(c *gc.C) {
+	controller := s.getController(c)
+	_, err := controller.Zones()
+	c.Assert(err, jc.ErrorIsNil)
+
+	// The zones response has been used up, so the request fails.
+	_, err = controller.Zones()
+	c.Assert(err, jc.Satisfies, IsUnexpectedError)
+	requestID, ok := RequestID(err)
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(requestID, gc.Equals, s.server.LastRequest().Header.Get("X-Request-Id"))
+	c.Assert(err, gc.ErrorMatches, `unexpected: request `+requestID+`: .*`)
+}
+
+func (*controllerSuite) TestRequestIDNotFromRequest(c *gc.C) {
+	_, ok := RequestID(errors.New("boom"))
+	c.Assert(ok, jc.IsFalse)
+	_, ok = RequestID(nil)
+	c.Assert(ok, jc.IsFalse)
+}
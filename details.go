@@ -0,0 +1,288 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"strconv"
+
+	"github.com/juju/errors"
+)
+
+// HardwareDetails is the hardware of a machine, as found by lshw when the
+// machine was commissioned.
+type HardwareDetails struct {
+	// CPUs are the processors, one for each socket.
+	CPUs []CPUDetails
+	// NICs are the network interface cards.
+	NICs []NICDetails
+	// Disks are the disks, including optical drives.
+	Disks []DiskDetails
+
+	// LSHW is the XML output of lshw that the details are read from.
+	LSHW []byte
+	// LLDP is the XML output of lldpd, describing the machine's network
+	// neighbours.
+	LLDP []byte
+}
+
+// CPUDetails describes a processor.
+type CPUDetails struct {
+	Model  string
+	Vendor string
+	// Cores is the number of cores that are enabled.
+	Cores int
+}
+
+// NICDetails describes a network interface card.
+type NICDetails struct {
+	// Name is the name of the interface in the commissioning
+	// environment, such as "eth0".
+	Name       string
+	MACAddress string
+	Product    string
+	Vendor     string
+	Driver     string
+	Firmware   string
+}
+
+// DiskDetails describes a disk.
+type DiskDetails struct {
+	// Name is the device name in the commissioning environment, such as
+	// "/dev/sda".
+	Name    string
+	Serial  string
+	Product string
+	Vendor  string
+	// Size is in bytes, and is zero if it isn't known.
+	Size uint64
+}
+
+// lshwNode is an element of the lshw XML output. The nodes form a tree,
+// and are told apart by their class.
+type lshwNode struct {
+	Class        string        `xml:"class,attr"`
+	Product      string        `xml:"product"`
+	Vendor       string        `xml:"vendor"`
+	Serial       string        `xml:"serial"`
+	LogicalNames []string      `xml:"logicalname"`
+	Size         uint64        `xml:"size"`
+	Settings     []lshwSetting `xml:"configuration>setting"`
+	Children     []lshwNode    `xml:"node"`
+}
+
+type lshwSetting struct {
+	ID    string `xml:"id,attr"`
+	Value string `xml:"value,attr"`
+}
+
+func (n *lshwNode) logicalName() string {
+	if len(n.LogicalNames) == 0 {
+		return ""
+	}
+	return n.LogicalNames[0]
+}
+
+func (n *lshwNode) setting(id string) string {
+	for _, setting := range n.Settings {
+		if setting.ID == id {
+			return setting.Value
+		}
+	}
+	return ""
+}
+
+// parseLSHW reads the CPUs, NICs and disks from the lshw XML output, which
+// has either a single node or a list of nodes at its root.
+func parseLSHW(data []byte) (HardwareDetails, error) {
+	var root lshwNode
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return HardwareDetails{}, errors.Trace(err)
+	}
+	var details HardwareDetails
+	details.addNode(&root)
+	return details, nil
+}
+
+func (d *HardwareDetails) addNode(node *lshwNode) {
+	switch node.Class {
+	case "processor":
+		cores := 0
+		if value := node.setting("enabledcores"); value != "" {
+			cores, _ = strconv.Atoi(value)
+		}
+		d.CPUs = append(d.CPUs, CPUDetails{
+			Model:  node.Product,
+			Vendor: node.Vendor,
+			Cores:  cores,
+		})
+	case "network":
+		d.NICs = append(d.NICs, NICDetails{
+			Name:       node.logicalName(),
+			MACAddress: node.Serial,
+			Product:    node.Product,
+			Vendor:     node.Vendor,
+			Driver:     node.setting("driver"),
+			Firmware:   node.setting("firmware"),
+		})
+	case "disk":
+		d.Disks = append(d.Disks, DiskDetails{
+			Name:    node.logicalName(),
+			Serial:  node.Serial,
+			Product: node.Product,
+			Vendor:  node.Vendor,
+			Size:    node.Size,
+		})
+	}
+	for i := range node.Children {
+		d.addNode(&node.Children[i])
+	}
+}
+
+// The BSON types that the fields of the details document are sent as.
+const (
+	bsonString   = 0x02
+	bsonDocument = 0x03
+	bsonArray    = 0x04
+	bsonBinary   = 0x05
+	bsonNull     = 0x0A
+)
+
+// bsonSizes are the sizes of the values of the fixed size BSON types.
+var bsonSizes = map[byte]int{
+	0x01: 8,  // double
+	0x06: 0,  // undefined
+	0x07: 12, // object ID
+	0x08: 1,  // boolean
+	0x09: 8,  // UTC datetime
+	0x0A: 0,  // null
+	0x10: 4,  // 32-bit integer
+	0x11: 8,  // timestamp
+	0x12: 8,  // 64-bit integer
+	0x13: 16, // decimal128
+	0x7F: 0,  // max key
+	0xFF: 0,  // min key
+}
+
+// bsonElement is a field of a BSON document. The value of a string is
+// its bytes, and of binary data the data, while the values of the other
+// types are left encoded.
+type bsonElement struct {
+	kind  byte
+	value []byte
+}
+
+// readBSONDocument reads the top level fields of a BSON document. Only
+// the types that can be skipped by their size are supported, which are
+// all but regular expressions, DB pointers and JavaScript code.
+func readBSONDocument(data []byte) (map[string]bsonElement, error) {
+	if len(data) < 5 {
+		return nil, errors.New("BSON document too short")
+	}
+	size := binary.LittleEndian.Uint32(data)
+	if int64(size) != int64(len(data)) || data[len(data)-1] != 0 {
+		return nil, errors.Errorf("BSON document size %d doesn't match its %d bytes", size, len(data))
+	}
+	doc := make(map[string]bsonElement)
+	rest := data[4 : len(data)-1]
+	for len(rest) > 0 {
+		kind := rest[0]
+		end := bytes.IndexByte(rest[1:], 0)
+		if end < 0 {
+			return nil, errors.New("BSON field name not terminated")
+		}
+		name := string(rest[1 : 1+end])
+		rest = rest[end+2:]
+		var length int64
+		switch kind {
+		case bsonString, bsonDocument, bsonArray, bsonBinary:
+			if len(rest) < 4 {
+				return nil, errors.Errorf("BSON field %q truncated", name)
+			}
+			length = int64(int32(binary.LittleEndian.Uint32(rest)))
+			switch kind {
+			case bsonString:
+				// The length includes the terminating NUL.
+				if length < 1 {
+					return nil, errors.Errorf("BSON field %q has bad length %d", name, length)
+				}
+				length += 4
+			case bsonBinary:
+				// The length doesn't include the subtype.
+				length += 5
+			}
+		default:
+			fixed, ok := bsonSizes[kind]
+			if !ok {
+				return nil, errors.Errorf("BSON field %q has unsupported type 0x%02x", name, kind)
+			}
+			length = int64(fixed)
+		}
+		if length < 0 || length > int64(len(rest)) {
+			return nil, errors.Errorf("BSON field %q truncated", name)
+		}
+		value := rest[:length]
+		rest = rest[length:]
+		switch kind {
+		case bsonString:
+			value = value[4 : len(value)-1]
+		case bsonBinary:
+			value = value[5:]
+		}
+		doc[name] = bsonElement{kind: kind, value: value}
+	}
+	return doc, nil
+}
+
+// detailsBytes returns the value of a field of the details document, which
+// MAAS sends as binary data.
+func detailsBytes(doc map[string]bsonElement, name string) ([]byte, error) {
+	element, ok := doc[name]
+	if !ok {
+		return nil, nil
+	}
+	switch element.kind {
+	case bsonNull:
+		return nil, nil
+	case bsonBinary, bsonString:
+		return element.value, nil
+	}
+	return nil, NewDeserializationError("details %s: unexpected BSON type 0x%02x", name, element.kind)
+}
+
+// Details implements Machine.
+//
+// Returns
+//  - NoMatchError if the machine cannot be found
+//  - PermissionError if the user does not have permission to read the details
+//  - DeserializationError if the details cannot be decoded
+func (m *machine) Details() (HardwareDetails, error) {
+	content, err := m.controller._getRaw(m.resourceURI, "details", nil)
+	if err != nil {
+		return HardwareDetails{}, translateError(opEntity, err)
+	}
+	doc, err := readBSONDocument(content)
+	if err != nil {
+		return HardwareDetails{}, WrapWithDeserializationError(err, "details")
+	}
+	lshw, err := detailsBytes(doc, "lshw")
+	if err != nil {
+		return HardwareDetails{}, errors.Trace(err)
+	}
+	lldp, err := detailsBytes(doc, "lldp")
+	if err != nil {
+		return HardwareDetails{}, errors.Trace(err)
+	}
+	var details HardwareDetails
+	if len(lshw) > 0 {
+		if details, err = parseLSHW(lshw); err != nil {
+			return HardwareDetails{}, WrapWithDeserializationError(err, "lshw details")
+		}
+	}
+	details.LSHW = lshw
+	details.LLDP = lldp
+	return details, nil
+}
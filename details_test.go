@@ -0,0 +1,247 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"encoding/binary"
+	"net/http"
+	"strings"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type detailsSuite struct{}
+
+var _ = gc.Suite(&detailsSuite{})
+
+func (*detailsSuite) TestParseLSHW(c *gc.C) {
+	details, err := parseLSHW([]byte(lshwXML))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(details.CPUs, jc.DeepEquals, []CPUDetails{{
+		Model:  "Intel(R) Xeon(R) CPU E5-2620 v4 @ 2.10GHz",
+		Vendor: "Intel Corp.",
+		Cores:  8,
+	}})
+	c.Check(details.NICs, jc.DeepEquals, []NICDetails{{
+		Name:       "eth0",
+		MACAddress: "52:54:00:c9:6a:45",
+		Product:    "I350 Gigabit Network Connection",
+		Vendor:     "Intel Corporation",
+		Driver:     "igb",
+		Firmware:   "1.63, 0x800009fa",
+	}})
+	c.Check(details.Disks, jc.DeepEquals, []DiskDetails{{
+		Name:    "/dev/sda",
+		Serial:  "S3Z2NB0K123456",
+		Product: "Samsung SSD 860",
+		Vendor:  "ATA",
+		Size:    500107862016,
+	}, {
+		Name:    "/dev/cdrom",
+		Product: "DVD-ROM",
+	}})
+}
+
+func (*detailsSuite) TestParseLSHWList(c *gc.C) {
+	node := strings.SplitN(lshwXML, "\n", 2)[1]
+	details, err := parseLSHW([]byte("<list>" + node + "</list>"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(details.CPUs, gc.HasLen, 1)
+	c.Check(details.NICs, gc.HasLen, 1)
+	c.Check(details.Disks, gc.HasLen, 2)
+}
+
+func (*detailsSuite) TestParseLSHWBadXML(c *gc.C) {
+	_, err := parseLSHW([]byte("<node"))
+	c.Assert(err, gc.NotNil)
+}
+
+// bsonDoc encodes a BSON document with the fields.
+func bsonDoc(fields ...[]byte) []byte {
+	doc := make([]byte, 4)
+	for _, field := range fields {
+		doc = append(doc, field...)
+	}
+	doc = append(doc, 0)
+	binary.LittleEndian.PutUint32(doc, uint32(len(doc)))
+	return doc
+}
+
+// bsonField encodes a field of a BSON document, whose value is already
+// encoded.
+func bsonField(kind byte, name string, value []byte) []byte {
+	field := append([]byte{kind}, name...)
+	field = append(field, 0)
+	return append(field, value...)
+}
+
+func bsonBinaryField(name string, data []byte) []byte {
+	value := make([]byte, 5, 5+len(data))
+	binary.LittleEndian.PutUint32(value, uint32(len(data)))
+	return bsonField(bsonBinary, name, append(value, data...))
+}
+
+func bsonStringField(name, text string) []byte {
+	value := make([]byte, 4, 5+len(text))
+	binary.LittleEndian.PutUint32(value, uint32(len(text)+1))
+	value = append(value, text...)
+	return bsonField(bsonString, name, append(value, 0))
+}
+
+func (*detailsSuite) TestReadBSONDocument(c *gc.C) {
+	doc, err := readBSONDocument(bsonDoc(
+		bsonField(0x01, "double", make([]byte, 8)),
+		bsonField(bsonDocument, "nested", bsonDoc(bsonField(bsonNull, "inner", nil))),
+		bsonStringField("text", "hello"),
+		bsonBinaryField("data", []byte("bytes")),
+		bsonField(0x10, "int", []byte{42, 0, 0, 0}),
+	))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(doc, gc.HasLen, 5)
+	c.Check(string(doc["text"].value), gc.Equals, "hello")
+	c.Check(string(doc["data"].value), gc.Equals, "bytes")
+	c.Check(doc["int"], jc.DeepEquals, bsonElement{kind: 0x10, value: []byte{42, 0, 0, 0}})
+}
+
+func (*detailsSuite) TestReadBSONDocumentTruncated(c *gc.C) {
+	full := bsonDoc(bsonBinaryField("lshw", []byte(lshwXML)))
+	truncated := append([]byte(nil), full[:len(full)-10]...)
+	binary.LittleEndian.PutUint32(truncated, uint32(len(truncated)))
+	truncated[len(truncated)-1] = 0
+
+	_, err := readBSONDocument(truncated)
+	c.Assert(err, gc.ErrorMatches, `BSON field "lshw" truncated`)
+	_, err = readBSONDocument(full[:len(full)-1])
+	c.Assert(err, gc.ErrorMatches, `BSON document size .* doesn't match its .* bytes`)
+}
+
+func (*detailsSuite) TestReadBSONDocumentUnsupportedType(c *gc.C) {
+	_, err := readBSONDocument(bsonDoc(bsonField(0x0B, "regex", []byte("a\x00\x00"))))
+	c.Assert(err, gc.ErrorMatches, `BSON field "regex" has unsupported type 0x0b`)
+}
+
+func (s *machineSuite) addDetailsResponse(c *gc.C, server *SimpleTestServer, machine *machine, fields ...[]byte) {
+	server.AddGetResponse(machine.resourceURI+"?op=details", http.StatusOK, string(bsonDoc(fields...)))
+}
+
+func (s *machineSuite) TestDetails(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	s.addDetailsResponse(c, server, machine,
+		bsonBinaryField("lshw", []byte(lshwXML)),
+		bsonBinaryField("lldp", []byte(`<lldp label="LLDP neighbors"/>`)),
+	)
+
+	details, err := machine.Details()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(details.CPUs, gc.HasLen, 1)
+	c.Check(details.NICs, gc.HasLen, 1)
+	c.Check(details.NICs[0].Firmware, gc.Equals, "1.63, 0x800009fa")
+	c.Check(details.Disks, gc.HasLen, 2)
+	c.Check(details.Disks[0].Serial, gc.Equals, "S3Z2NB0K123456")
+	c.Check(string(details.LSHW), gc.Equals, lshwXML)
+	c.Check(string(details.LLDP), gc.Equals, `<lldp label="LLDP neighbors"/>`)
+}
+
+func (s *machineSuite) TestDetailsMissing(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	s.addDetailsResponse(c, server, machine, bsonField(bsonNull, "lshw", nil), bsonField(bsonNull, "lldp", nil))
+
+	details, err := machine.Details()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(details, jc.DeepEquals, HardwareDetails{})
+}
+
+func (s *machineSuite) TestDetailsBadLSHW(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	s.addDetailsResponse(c, server, machine, bsonBinaryField("lshw", []byte("<node")))
+
+	_, err := machine.Details()
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+}
+
+func (s *machineSuite) TestDetailsBadType(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	s.addDetailsResponse(c, server, machine, bsonField(0x10, "lshw", []byte{42, 0, 0, 0}))
+
+	_, err := machine.Details()
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err, gc.ErrorMatches, "details lshw: unexpected BSON type 0x10")
+}
+
+func (s *machineSuite) TestDetailsBadBSON(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse(machine.resourceURI+"?op=details", http.StatusOK, "not bson")
+
+	_, err := machine.Details()
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+}
+
+func (s *machineSuite) TestDetailsForbidden(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse(machine.resourceURI+"?op=details", http.StatusForbidden, "admins only")
+
+	_, err := machine.Details()
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+	c.Assert(err.Error(), gc.Equals, "admins only")
+}
+
+func (s *machineSuite) TestDetailsNotFound(c *gc.C) {
+	_, machine := s.getServerAndMachine(c)
+
+	_, err := machine.Details()
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+}
+
+const lshwXML = `<?xml version="1.0" standalone="yes" ?>
+<node id="node1" claimed="true" class="system" handle="DMI:0001">
+ <description>Rack Mount Chassis</description>
+ <product>PowerEdge R630</product>
+ <vendor>Dell Inc.</vendor>
+ <serial>ABC1234</serial>
+  <node id="core" claimed="true" class="bus" handle="DMI:0002">
+   <description>Motherboard</description>
+    <node id="cpu:0" claimed="true" class="processor" handle="DMI:0400">
+     <product>Intel(R) Xeon(R) CPU E5-2620 v4 @ 2.10GHz</product>
+     <vendor>Intel Corp.</vendor>
+     <size units="Hz">2100000000</size>
+     <configuration>
+      <setting id="cores" value="8" />
+      <setting id="enabledcores" value="8" />
+      <setting id="threads" value="16" />
+     </configuration>
+    </node>
+    <node id="pci" claimed="true" class="bridge" handle="PCIBUS:0000:00">
+     <node id="network" claimed="true" class="network" handle="PCI:0000:01:00.0">
+      <description>Ethernet interface</description>
+      <product>I350 Gigabit Network Connection</product>
+      <vendor>Intel Corporation</vendor>
+      <logicalname>eth0</logicalname>
+      <serial>52:54:00:c9:6a:45</serial>
+      <size units="bit/s">1000000000</size>
+      <configuration>
+       <setting id="driver" value="igb" />
+       <setting id="firmware" value="1.63, 0x800009fa" />
+       <setting id="link" value="yes" />
+      </configuration>
+     </node>
+     <node id="scsi" claimed="true" class="storage" handle="">
+      <node id="disk" claimed="true" class="disk" handle="SCSI:00:00:00:00">
+       <description>ATA Disk</description>
+       <product>Samsung SSD 860</product>
+       <vendor>ATA</vendor>
+       <logicalname>/dev/sda</logicalname>
+       <serial>S3Z2NB0K123456</serial>
+       <size units="bytes">500107862016</size>
+      </node>
+      <node id="cdrom" claimed="true" class="disk" handle="SCSI:01:00:00:00">
+       <product>DVD-ROM</product>
+       <logicalname>/dev/cdrom</logicalname>
+       <logicalname>/dev/sr0</logicalname>
+      </node>
+     </node>
+    </node>
+  </node>
+</node>
+`
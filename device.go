@@ -4,8 +4,9 @@
 package gomaasapi
 
 import (
+	"encoding/json"
 	"fmt"
-	"net/http"
+	"net/netip"
 	"strings"
 
 	"github.com/juju/errors"
@@ -13,6 +14,9 @@ import (
 	"github.com/juju/version"
 )
 
+// device implements Device.
+var _ Device = (*device)(nil)
+
 type device struct {
 	controller *controller
 
@@ -22,12 +26,29 @@ type device struct {
 	hostname string
 	fqdn     string
 
-	parent string
-	owner  string
+	parent      string
+	owner       string
+	domain      string
+	description string
+
+	nodeType     int
+	nodeTypeName string
+	addressTTL   int
 
 	ipAddresses  []string
+	ipAddrs      []netip.Addr
+	addrErr      error
 	interfaceSet []*interface_
 	zone         *zone
+	pool         *pool
+
+	// parentMachine caches the machine found by ParentMachine, until the
+	// parent changes.
+	parentMachine Machine
+
+	// source is the device as read from the MAAS controller, which is
+	// what the device is marshalled to.
+	source map[string]interface{}
 }
 
 // SystemID implements Device.
@@ -50,16 +71,82 @@ func (d *device) Parent() string {
 	return d.parent
 }
 
+// ParentMachine implements Device.
+//
+// Returns
+//  - NoMatchError if the device has no parent, or the parent isn't a machine
+func (d *device) ParentMachine() (Machine, error) {
+	if d.parent == "" {
+		return nil, NewNoMatchError(fmt.Sprintf("device %q has no parent", d.systemID))
+	}
+	if d.parentMachine != nil {
+		return d.parentMachine, nil
+	}
+	machines, err := d.controller.Machines(MachinesArgs{SystemIDs: []string{d.parent}})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for _, m := range machines {
+		if m.SystemID() == d.parent {
+			d.parentMachine = m
+			return m, nil
+		}
+	}
+	return nil, NewNoMatchError(fmt.Sprintf("parent %q of device %q is not a machine", d.parent, d.systemID))
+}
+
 // Owner implements Device.
 func (d *device) Owner() string {
 	return d.owner
 }
 
+// Domain implements Device.
+func (d *device) Domain() string {
+	return d.domain
+}
+
+// Description implements Device.
+func (d *device) Description() string {
+	return d.description
+}
+
+// NodeType implements Device.
+func (d *device) NodeType() int {
+	return d.nodeType
+}
+
+// NodeTypeName implements Device.
+func (d *device) NodeTypeName() string {
+	return d.nodeTypeName
+}
+
+// AddressTTL implements Device.
+func (d *device) AddressTTL() int {
+	return d.addressTTL
+}
+
 // IPAddresses implements Device.
 func (d *device) IPAddresses() []string {
 	return d.ipAddresses
 }
 
+// IPAddrs implements Device.
+func (d *device) IPAddrs() []netip.Addr {
+	return d.ipAddrs
+}
+
+func (d *device) addressErr() error {
+	if d.addrErr != nil {
+		return d.addrErr
+	}
+	for _, iface := range d.interfaceSet {
+		if err := iface.addressErr(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Zone implements Device.
 func (d *device) Zone() Zone {
 	if d.zone == nil {
@@ -68,16 +155,86 @@ func (d *device) Zone() Zone {
 	return d.zone
 }
 
+// Pool implements Device.
+func (d *device) Pool() Pool {
+	if d.pool == nil {
+		return nil
+	}
+	return d.pool
+}
+
+// MarshalJSON implements json.Marshaler. The device is marshalled to the
+// JSON that it was read from, so that it can be unmarshalled again with
+// UnmarshalDevice.
+func (d *device) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.source)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *device) UnmarshalJSON(data []byte) error {
+	source, err := decodeJSON(data)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	read, err := readDevice(twoDotOh, source)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	read.bind(d.controller)
+	*d = *read
+	return nil
+}
+
+// UnmarshalDevice reads a Device from the JSON returned by the MAAS
+// controller, or from a marshalled Device. The Device isn't associated with
+// a controller, so only the methods that don't make requests can be used.
+func UnmarshalDevice(data []byte) (Device, error) {
+	d := &device{}
+	if err := d.UnmarshalJSON(data); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return d, nil
+}
+
 // InterfaceSet implements Device.
 func (d *device) InterfaceSet() []Interface {
 	result := make([]Interface, len(d.interfaceSet))
 	for i, v := range d.interfaceSet {
-		v.controller = d.controller
 		result[i] = v
 	}
 	return result
 }
 
+// Interfaces implements Device.
+func (d *device) Interfaces() ([]Interface, error) {
+	source, err := d.controller.get(d.interfacesURI())
+	if err != nil {
+		return nil, translateError(opEntity, err)
+	}
+	interfaces, err := readInterfaces(d.controller.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err = d.controller.checkDecoded("interface", source, interfaces); err != nil {
+		return nil, errors.Trace(err)
+	}
+	for _, iface := range interfaces {
+		iface.bind(d.controller)
+	}
+	d.interfaceSet = interfaces
+	return d.InterfaceSet(), nil
+}
+
+// Interface implements Device.
+func (d *device) Interface(id int) Interface {
+	for _, iface := range d.interfaceSet {
+		if iface.ID() == id {
+			return iface
+		}
+	}
+	return nil
+}
+
 // CreateInterfaceArgs is an argument struct for passing parameters to
 // the Machine.CreateInterface method.
 type CreateInterfaceArgs struct {
@@ -95,6 +252,12 @@ type CreateInterfaceArgs struct {
 	AcceptRA bool
 	// Autoconf - Perform stateless autoconfiguration. (IPv6 only)
 	Autoconf bool
+	// Subnet is optional. If set, the new interface is linked to it with
+	// the LinkMode, which defaults to LinkModeAuto, so that the address
+	// MAAS assigns is in the links of the returned interface.
+	Subnet Subnet
+	// LinkMode is only valid when the Subnet is set.
+	LinkMode InterfaceLinkMode
 }
 
 // Validate checks the required fields are set for the arg structure.
@@ -108,7 +271,23 @@ func (a *CreateInterfaceArgs) Validate() error {
 	if a.VLAN == nil {
 		return errors.NotValidf("missing VLAN")
 	}
-	return nil
+	if a.Subnet == nil {
+		if a.LinkMode != "" {
+			return errors.NotValidf("setting LinkMode without Subnet")
+		}
+		return nil
+	}
+	linkArgs := a.linkSubnetArgs()
+	return errors.Trace(linkArgs.Validate())
+}
+
+// linkSubnetArgs returns the args to link the new interface to the subnet.
+func (a *CreateInterfaceArgs) linkSubnetArgs() LinkSubnetArgs {
+	mode := a.LinkMode
+	if mode == "" {
+		mode = LinkModeAuto
+	}
+	return LinkSubnetArgs{Mode: mode, Subnet: a.Subnet}
 }
 
 // interfacesURI used to add interfaces for this device. The operations
@@ -132,43 +311,108 @@ func (d *device) CreateInterface(args CreateInterfaceArgs) (Interface, error) {
 	params.MaybeAddBool("autoconf", args.Autoconf)
 	result, err := d.controller.post(d.interfacesURI(), "create_physical", params.Values)
 	if err != nil {
-		if svrErr, ok := errors.Cause(err).(ServerError); ok {
-			switch svrErr.StatusCode {
-			case http.StatusNotFound, http.StatusConflict:
-				return nil, errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
-			case http.StatusForbidden:
-				return nil, errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
-			case http.StatusServiceUnavailable:
-				return nil, errors.Wrap(err, NewCannotCompleteError(svrErr.BodyMessage))
-			}
-		}
-		return nil, NewUnexpectedError(err)
+		return nil, translateError(opReference, err)
 	}
 
-	iface, err := readInterface(d.controller.apiVersion, result)
+	iface, err := readInterface(d.controller.schemaVersion(), result)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
-	iface.controller = d.controller
+	if err = d.controller.checkDecoded("interface", result, iface); err != nil {
+		return nil, errors.Trace(err)
+	}
+	iface.bind(d.controller)
+
+	if args.Subnet != nil {
+		// The interface isn't wanted without the link, so remove it again.
+		if err := iface.LinkSubnet(args.linkSubnetArgs()); err != nil {
+			if deleteErr := iface.Delete(); deleteErr != nil {
+				d.controller.logger.Warnf("could not delete interface %q: %v", iface.Name(), deleteErr)
+			}
+			return nil, errors.Trace(err)
+		}
+	}
 
 	// TODO: add to the interfaces for the device when the interfaces are returned.
 	// lp:bug 1567213.
 	return iface, nil
 }
 
+// UpdateDeviceArgs is an argument struct for calling Device.Update. Only
+// the fields that are set are changed.
+type UpdateDeviceArgs struct {
+	Hostname string
+	// Parent is the SystemID of the new parent.
+	Parent string
+	// Zone is the name of the new zone.
+	Zone string
+}
+
+// Update implements Device.
+func (d *device) Update(args UpdateDeviceArgs) error {
+	var empty UpdateDeviceArgs
+	if args == empty {
+		return nil
+	}
+	params := NewURLParams()
+	params.MaybeAdd("hostname", args.Hostname)
+	params.MaybeAdd("parent", args.Parent)
+	params.MaybeAdd("zone", args.Zone)
+	source, err := d.controller.put(d.resourceURI, params.Values)
+	if err != nil {
+		return translateError(opEntity, err)
+	}
+
+	response, err := readDevice(d.controller.schemaVersion(), source)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err = d.controller.checkDecoded("device", source, response); err != nil {
+		return errors.Trace(err)
+	}
+	d.updateFrom(response)
+	return nil
+}
+
+func (d *device) updateFrom(other *device) {
+	if d.parent != other.parent {
+		d.parentMachine = nil
+	}
+	d.resourceURI = other.resourceURI
+	d.systemID = other.systemID
+	d.hostname = other.hostname
+	d.fqdn = other.fqdn
+	d.parent = other.parent
+	d.owner = other.owner
+	d.domain = other.domain
+	d.description = other.description
+	d.nodeType = other.nodeType
+	d.nodeTypeName = other.nodeTypeName
+	d.addressTTL = other.addressTTL
+	d.ipAddresses = other.ipAddresses
+	d.ipAddrs = other.ipAddrs
+	d.addrErr = other.addrErr
+	d.interfaceSet = other.interfaceSet
+	d.zone = other.zone
+	d.pool = other.pool
+	d.source = other.source
+	d.bind(d.controller)
+}
+
+// bind associates the device, and the interfaces nested in it, with the
+// controller.
+func (d *device) bind(c *controller) {
+	d.controller = c
+	for _, iface := range d.interfaceSet {
+		iface.bind(c)
+	}
+}
+
 // Delete implements Device.
 func (d *device) Delete() error {
 	err := d.controller.delete(d.resourceURI)
 	if err != nil {
-		if svrErr, ok := errors.Cause(err).(ServerError); ok {
-			switch svrErr.StatusCode {
-			case http.StatusNotFound:
-				return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
-			case http.StatusForbidden:
-				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
-			}
-		}
-		return NewUnexpectedError(err)
+		return translateError(opEntity, err)
 	}
 	return nil
 }
@@ -226,7 +470,7 @@ func readDeviceList(sourceList []interface{}, readFunc deviceDeserializationFunc
 		}
 		device, err := readFunc(source)
 		if err != nil {
-			return nil, errors.Annotatef(err, "device %d", i)
+			return nil, annotateListItem(err, "device", i, source)
 		}
 		result = append(result, device)
 	}
@@ -239,26 +483,40 @@ var deviceDeserializationFuncs = map[version.Number]deviceDeserializationFunc{
 	twoDotOh: device_2_0,
 }
 
-func device_2_0(source map[string]interface{}) (*device, error) {
-	fields := schema.Fields{
-		"resource_uri": schema.String(),
-
-		"system_id": schema.String(),
-		"hostname":  schema.String(),
-		"fqdn":      schema.String(),
-		"parent":    schema.OneOf(schema.Nil(""), schema.String()),
-		"owner":     schema.OneOf(schema.Nil(""), schema.String()),
+var device_2_0Checker = fieldMap("device", schema.Fields{
+	"resource_uri": schema.String(),
+
+	"system_id": schema.String(),
+	"hostname":  schema.String(),
+	"fqdn":      schema.String(),
+	"parent":    schema.OneOf(schema.Nil(""), schema.String()),
+	"owner":     schema.OneOf(schema.Nil(""), schema.String()),
+	"domain":    schema.OneOf(schema.Nil(""), schema.StringMap(schema.Any())),
+
+	"description": schema.OneOf(schema.Nil(""), schema.String()),
+
+	"node_type":      schema.ForceInt(),
+	"node_type_name": schema.String(),
+	"address_ttl":    schema.OneOf(schema.Nil(""), schema.ForceInt()),
+
+	"ip_addresses":  schema.OneOf(schema.Nil(""), schema.List(schema.String())),
+	"interface_set": schema.List(schema.StringMap(schema.Any())),
+	"zone":          schema.StringMap(schema.Any()),
+	"pool":          schema.OneOf(schema.Nil(""), schema.StringMap(schema.Any())),
+}, schema.Defaults{
+	"owner":  "",
+	"parent": "",
+	// The fields below aren't in the responses of all MAAS versions.
+	"domain":         schema.Omit,
+	"description":    schema.Omit,
+	"node_type":      schema.Omit,
+	"node_type_name": schema.Omit,
+	"address_ttl":    schema.Omit,
+	"pool":           schema.Omit,
+})
 
-		"ip_addresses":  schema.List(schema.String()),
-		"interface_set": schema.List(schema.StringMap(schema.Any())),
-		"zone":          schema.StringMap(schema.Any()),
-	}
-	defaults := schema.Defaults{
-		"owner":  "",
-		"parent": "",
-	}
-	checker := schema.FieldMap(fields, defaults)
-	coerced, err := checker.Coerce(source, nil)
+func device_2_0(source map[string]interface{}) (*device, error) {
+	coerced, err := device_2_0Checker.Coerce(source, nil)
 	if err != nil {
 		return nil, WrapWithDeserializationError(err, "device 2.0 schema check failed")
 	}
@@ -274,8 +532,28 @@ func device_2_0(source map[string]interface{}) (*device, error) {
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	var domainName string
+	if domainMap, ok := valid["domain"].(map[string]interface{}); ok {
+		domainName, err = deviceDomain_2_0(domainMap)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	var devicePool *pool
+	if poolMap, ok := valid["pool"].(map[string]interface{}); ok {
+		devicePool, err = pool_2_3(poolMap)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
 	owner, _ := valid["owner"].(string)
 	parent, _ := valid["parent"].(string)
+	description, _ := valid["description"].(string)
+	nodeType, _ := valid["node_type"].(int)
+	nodeTypeName, _ := valid["node_type_name"].(string)
+	addressTTL, _ := valid["address_ttl"].(int)
+	ipAddresses := convertToStringSlice(valid["ip_addresses"])
+	ipAddrs, addrErr := parseAddrs(ipAddresses)
 	result := &device{
 		resourceURI: valid["resource_uri"].(string),
 
@@ -284,10 +562,38 @@ func device_2_0(source map[string]interface{}) (*device, error) {
 		fqdn:     valid["fqdn"].(string),
 		parent:   parent,
 		owner:    owner,
+		domain:   domainName,
+
+		description: description,
+
+		nodeType:     nodeType,
+		nodeTypeName: nodeTypeName,
+		addressTTL:   addressTTL,
 
-		ipAddresses:  convertToStringSlice(valid["ip_addresses"]),
+		ipAddresses:  ipAddresses,
+		ipAddrs:      ipAddrs,
+		addrErr:      addrErr,
 		interfaceSet: interfaceSet,
 		zone:         zone,
+		pool:         devicePool,
+
+		source: source,
 	}
 	return result, nil
 }
+
+var deviceDomain_2_0Checker = fieldMap("domain", schema.Fields{
+	"name": schema.String(),
+}, nil) // no defaults
+
+// deviceDomain_2_0 returns the name of the domain nested in a device. Only
+// the name is read, as the other fields of the domain, which domain_2_0
+// requires, aren't in the device responses of all MAAS versions.
+func deviceDomain_2_0(source map[string]interface{}) (string, error) {
+	coerced, err := deviceDomain_2_0Checker.Coerce(source, nil)
+	if err != nil {
+		return "", WrapWithDeserializationError(err, "domain 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	return valid["name"].(string), nil
+}
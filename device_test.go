@@ -4,7 +4,9 @@
 package gomaasapi
 
 import (
+	"encoding/json"
 	"net/http"
+	"net/netip"
 
 	"github.com/juju/errors"
 	"github.com/juju/testing"
@@ -40,9 +42,89 @@ func (*deviceSuite) TestReadDevices(c *gc.C) {
 	c.Check(device.Hostname(), gc.Equals, "furnacelike-brittney")
 	c.Check(device.FQDN(), gc.Equals, "furnacelike-brittney.maas")
 	c.Check(device.IPAddresses(), jc.DeepEquals, []string{"192.168.100.11"})
+	c.Check(device.IPAddrs(), jc.DeepEquals, []netip.Addr{netip.MustParseAddr("192.168.100.11")})
 	zone := device.Zone()
 	c.Check(zone, gc.NotNil)
 	c.Check(zone.Name(), gc.Equals, "default")
+	c.Check(device.Owner(), gc.Equals, "thumper")
+	c.Check(device.Parent(), gc.Equals, "4y3ha3")
+	c.Check(device.Domain(), gc.Equals, "maas")
+	c.Check(device.NodeType(), gc.Equals, 1)
+	c.Check(device.NodeTypeName(), gc.Equals, "Device")
+	c.Check(device.AddressTTL(), gc.Equals, 0)
+	c.Check(device.Pool(), gc.IsNil)
+}
+
+func (*deviceSuite) TestReadDevicesOptionalFields(c *gc.C) {
+	json := parseJSON(c, devicesResponse)
+	deviceMap := json.([]interface{})[0].(map[string]interface{})
+	deviceMap["address_ttl"] = 300
+	deviceMap["pool"] = parseJSON(c, poolResponse)
+	delete(deviceMap, "domain")
+	delete(deviceMap, "node_type")
+	delete(deviceMap, "node_type_name")
+	devices, err := readDevices(twoDotOh, json)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(devices, gc.HasLen, 1)
+
+	device := devices[0]
+	c.Check(device.AddressTTL(), gc.Equals, 300)
+	c.Assert(device.Pool(), gc.NotNil)
+	c.Check(device.Pool().Name(), gc.Equals, "swimming")
+	c.Check(device.Domain(), gc.Equals, "")
+	c.Check(device.NodeType(), gc.Equals, 0)
+	c.Check(device.NodeTypeName(), gc.Equals, "")
+}
+
+func (*deviceSuite) TestReadDevicesDomainNameOnly(c *gc.C) {
+	json := parseJSON(c, devicesResponse)
+	deviceMap := json.([]interface{})[0].(map[string]interface{})
+	deviceMap["domain"] = map[string]interface{}{"name": "maas"}
+	devices, err := readDevices(twoDotOh, json)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(devices, gc.HasLen, 1)
+	c.Check(devices[0].Domain(), gc.Equals, "maas")
+}
+
+func (*deviceSuite) TestReadDevicesNullIPAddresses(c *gc.C) {
+	json := parseJSON(c, devicesResponse)
+	json.([]interface{})[0].(map[string]interface{})["ip_addresses"] = nil
+	devices, err := readDevices(twoDotOh, json)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(devices[0].IPAddresses(), gc.NotNil)
+	c.Check(devices[0].IPAddresses(), gc.HasLen, 0)
+	c.Check(devices[0].IPAddrs(), gc.NotNil)
+}
+
+func (*deviceSuite) TestJSONRoundTrip(c *gc.C) {
+	// The fixture is decoded as UnmarshalDevice decodes, with numbers as
+	// json.Number, so that the sources compare equal.
+	source, err := decodeJSON([]byte(devicesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	devices, err := readDevices(twoDotOh, source)
+	c.Assert(err, jc.ErrorIsNil)
+
+	data, err := json.Marshal(devices[0])
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(data), jc.JSONEquals, parseJSON(c, deviceResponse))
+
+	read, err := UnmarshalDevice(data)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(read, jc.DeepEquals, Device(devices[0]))
+
+	var unmarshalled struct {
+		Devices []*device
+	}
+	err = json.Unmarshal([]byte(`{"Devices": [`+string(data)+`]}`), &unmarshalled)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(unmarshalled.Devices, jc.DeepEquals, devices)
+}
+
+func (*deviceSuite) TestUnmarshalDeviceBadSchema(c *gc.C) {
+	_, err := UnmarshalDevice([]byte(`{"hostname": 42}`))
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+	_, err = UnmarshalDevice([]byte(`not json`))
+	c.Assert(err, gc.NotNil)
 }
 
 func (*deviceSuite) TestReadDevicesNils(c *gc.C) {
@@ -77,6 +159,46 @@ func (s *deviceSuite) TestInterfaceSet(c *gc.C) {
 	c.Assert(ifaces, gc.HasLen, 2)
 }
 
+func (s *deviceSuite) TestInterfaces(c *gc.C) {
+	server, device := s.getServerAndDevice(c)
+	server.AddGetResponse(device.interfacesURI(), http.StatusOK, interfacesResponse)
+	ifaces, err := device.Interfaces()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ifaces, gc.HasLen, 1)
+	c.Check(ifaces[0].ID(), gc.Equals, 40)
+
+	// The interface set is replaced with the current interfaces.
+	c.Assert(device.InterfaceSet(), gc.HasLen, 1)
+	c.Check(device.Interface(40), gc.NotNil)
+	c.Check(device.Interface(48), gc.IsNil)
+}
+
+func (s *deviceSuite) TestInterfacesNotFound(c *gc.C) {
+	server, device := s.getServerAndDevice(c)
+	server.AddGetResponse(device.interfacesURI(), http.StatusNotFound, "can't find device")
+	_, err := device.Interfaces()
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+	c.Assert(err.Error(), gc.Equals, "can't find device")
+	c.Assert(device.InterfaceSet(), gc.HasLen, 2)
+}
+
+func (s *deviceSuite) TestInterfacesForbidden(c *gc.C) {
+	server, device := s.getServerAndDevice(c)
+	server.AddGetResponse(device.interfacesURI(), http.StatusForbidden, "bad user")
+	_, err := device.Interfaces()
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+	c.Assert(err.Error(), gc.Equals, "bad user")
+}
+
+func (s *deviceSuite) TestInterface(c *gc.C) {
+	_, device := s.getServerAndDevice(c)
+	iface := device.Interface(49)
+	c.Assert(iface, gc.NotNil)
+	c.Check(iface.ID(), gc.Equals, 49)
+	c.Check(iface.(*interface_).controller, gc.Equals, device.controller)
+	c.Check(device.Interface(1), gc.IsNil)
+}
+
 type fakeVLAN struct {
 	VLAN
 	id int
@@ -100,6 +222,14 @@ func (s *controllerSuite) TestCreateInterfaceArgsValidate(c *gc.C) {
 		errText: `missing VLAN not valid`,
 	}, {
 		args: CreateInterfaceArgs{Name: "eth3", MACAddress: "a-mac-address", VLAN: &fakeVLAN{}},
+	}, {
+		args:    CreateInterfaceArgs{Name: "eth3", MACAddress: "a-mac-address", VLAN: &fakeVLAN{}, LinkMode: LinkModeDHCP},
+		errText: "setting LinkMode without Subnet not valid",
+	}, {
+		args:    CreateInterfaceArgs{Name: "eth3", MACAddress: "a-mac-address", VLAN: &fakeVLAN{}, Subnet: &fakeSubnet{}, LinkMode: "magic"},
+		errText: `unknown Mode value ("magic") not valid`,
+	}, {
+		args: CreateInterfaceArgs{Name: "eth3", MACAddress: "a-mac-address", VLAN: &fakeVLAN{}, Subnet: &fakeSubnet{}},
 	}} {
 		c.Logf("test %d", i)
 		err := test.args.Validate()
@@ -139,6 +269,37 @@ func (s *deviceSuite) TestCreateInterface(c *gc.C) {
 	c.Assert(form.Get("tags"), gc.Equals, "foo,bar")
 }
 
+func (s *deviceSuite) TestCreateInterfaceLinksSubnet(c *gc.C) {
+	server, device := s.getServerAndDevice(c)
+	server.AddPostResponse(device.interfacesURI()+"?op=create_physical", http.StatusOK, interfaceResponse)
+	server.AddPostResponse("/MAAS/api/2.0/nodes/4y3ha6/interfaces/40/?op=link_subnet", http.StatusOK, linkResponse(c, "auto", "192.168.100.5", 1))
+
+	args := minimalCreateInterfaceArgs()
+	args.Subnet = &fakeSubnet{id: 1}
+	iface, err := device.CreateInterface(args)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(iface.Links()[0].IPAddress(), gc.Equals, "192.168.100.5")
+
+	form := server.LastRequest().PostForm
+	c.Check(form.Get("mode"), gc.Equals, "AUTO")
+	c.Check(form.Get("subnet"), gc.Equals, "1")
+}
+
+func (s *deviceSuite) TestCreateInterfaceLinkFailure(c *gc.C) {
+	server, device := s.getServerAndDevice(c)
+	server.AddPostResponse(device.interfacesURI()+"?op=create_physical", http.StatusOK, interfaceResponse)
+	server.AddPostResponse("/MAAS/api/2.0/nodes/4y3ha6/interfaces/40/?op=link_subnet", http.StatusServiceUnavailable, "no addresses")
+	server.AddDeleteResponse("/MAAS/api/2.0/nodes/4y3ha6/interfaces/40/", http.StatusNoContent, "")
+
+	args := minimalCreateInterfaceArgs()
+	args.Subnet = &fakeSubnet{id: 1}
+	args.LinkMode = LinkModeStatic
+	_, err := device.CreateInterface(args)
+	c.Assert(err, jc.Satisfies, IsCannotCompleteError)
+	// The interface is deleted again.
+	c.Check(server.LastRequest().Method, gc.Equals, "DELETE")
+}
+
 func minimalCreateInterfaceArgs() CreateInterfaceArgs {
 	return CreateInterfaceArgs{
 		Name:       "eth43",
@@ -184,7 +345,7 @@ func (s *deviceSuite) TestCreateInterfaceUnknown(c *gc.C) {
 	server.AddPostResponse(device.interfacesURI()+"?op=create_physical", http.StatusMethodNotAllowed, "wat?")
 	_, err := device.CreateInterface(minimalCreateInterfaceArgs())
 	c.Assert(err, jc.Satisfies, IsUnexpectedError)
-	c.Assert(err.Error(), gc.Equals, "unexpected: ServerError: 405 Method Not Allowed (wat?)")
+	c.Assert(err, gc.ErrorMatches, `unexpected: request [0-9a-f]+-[0-9a-f]+: ServerError: 405 Method Not Allowed \(wat\?\)`)
 }
 
 func (s *deviceSuite) getServerAndDevice(c *gc.C) (*SimpleTestServer, *device) {
@@ -197,6 +358,25 @@ func (s *deviceSuite) getServerAndDevice(c *gc.C) (*SimpleTestServer, *device) {
 	return server, devices[0].(*device)
 }
 
+func (s *deviceSuite) TestDevicesBindsNestedResources(c *gc.C) {
+	_, device := s.getServerAndDevice(c)
+	c.Assert(device.controller, gc.NotNil)
+	c.Assert(device.interfaceSet, gc.Not(gc.HasLen), 0)
+	for _, iface := range device.interfaceSet {
+		checkInterfaceBound(c, iface, device.controller)
+	}
+}
+
+func (s *deviceSuite) TestInterfacesBindsNestedResources(c *gc.C) {
+	server, device := s.getServerAndDevice(c)
+	server.AddGetResponse(device.interfacesURI(), http.StatusOK, interfacesResponse)
+	_, err := device.Interfaces()
+	c.Assert(err, jc.ErrorIsNil)
+	for _, iface := range device.interfaceSet {
+		checkInterfaceBound(c, iface, device.controller)
+	}
+}
+
 func (s *deviceSuite) TestDelete(c *gc.C) {
 	server, device := s.getServerAndDevice(c)
 	// Successful delete is 204 - StatusNoContent
@@ -219,11 +399,120 @@ func (s *deviceSuite) TestDeleteForbidden(c *gc.C) {
 	c.Assert(err, jc.Satisfies, IsPermissionError)
 }
 
-func (s *deviceSuite) TestDeleteUnknown(c *gc.C) {
+func (s *deviceSuite) TestDeleteConflict(c *gc.C) {
 	server, device := s.getServerAndDevice(c)
 	server.AddDeleteResponse(device.resourceURI, http.StatusConflict, "")
 	err := device.Delete()
-	c.Assert(err, jc.Satisfies, IsUnexpectedError)
+	c.Assert(err, jc.Satisfies, IsCannotCompleteError)
+}
+
+func (s *deviceSuite) TestUpdateNoChangeNoRequest(c *gc.C) {
+	server, device := s.getServerAndDevice(c)
+	count := server.RequestCount()
+	err := device.Update(UpdateDeviceArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(server.RequestCount(), gc.Equals, count)
+}
+
+func (s *deviceSuite) TestUpdateMissing(c *gc.C) {
+	_, device := s.getServerAndDevice(c)
+	err := device.Update(UpdateDeviceArgs{Hostname: "renamed"})
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+}
+
+func (s *deviceSuite) TestUpdateBadRequest(c *gc.C) {
+	server, device := s.getServerAndDevice(c)
+	server.AddPutResponse(device.resourceURI, http.StatusBadRequest, "no such zone")
+	err := device.Update(UpdateDeviceArgs{Zone: "missing"})
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+	c.Check(err.Error(), gc.Equals, "no such zone")
+}
+
+func (s *deviceSuite) TestUpdateForbidden(c *gc.C) {
+	server, device := s.getServerAndDevice(c)
+	server.AddPutResponse(device.resourceURI, http.StatusForbidden, "bad user")
+	err := device.Update(UpdateDeviceArgs{Hostname: "renamed"})
+	c.Check(err, jc.Satisfies, IsPermissionError)
+	c.Check(err.Error(), gc.Equals, "bad user")
+}
+
+func (s *deviceSuite) TestUpdateUnknown(c *gc.C) {
+	server, device := s.getServerAndDevice(c)
+	server.AddPutResponse(device.resourceURI, http.StatusMethodNotAllowed, "wat?")
+	err := device.Update(UpdateDeviceArgs{Hostname: "renamed"})
+	c.Check(err, jc.Satisfies, IsUnexpectedError)
+	c.Assert(err, gc.ErrorMatches, `unexpected: request [0-9a-f]+-[0-9a-f]+: ServerError: 405 Method Not Allowed \(wat\?\)`)
+}
+
+func (s *deviceSuite) TestUpdateGood(c *gc.C) {
+	server, device := s.getServerAndDevice(c)
+	// The changed information is there just for the test to show that the response
+	// is parsed and the device updated
+	response := updateJSONMap(c, deviceResponse, map[string]interface{}{
+		"hostname": "renamed",
+		"parent":   "4y3ha4",
+	})
+	server.AddPutResponse(device.resourceURI, http.StatusOK, response)
+	args := UpdateDeviceArgs{
+		Hostname: "renamed",
+		Parent:   "4y3ha4",
+		Zone:     "special",
+	}
+	err := device.Update(args)
+	c.Check(err, jc.ErrorIsNil)
+	c.Check(device.Hostname(), gc.Equals, "renamed")
+	c.Check(device.Parent(), gc.Equals, "4y3ha4")
+
+	form := server.LastRequest().PostForm
+	c.Assert(form.Get("hostname"), gc.Equals, "renamed")
+	c.Assert(form.Get("parent"), gc.Equals, "4y3ha4")
+	c.Assert(form.Get("zone"), gc.Equals, "special")
+}
+
+func (s *deviceSuite) TestParentMachine(c *gc.C) {
+	server, device := s.getServerAndDevice(c)
+	server.AddGetResponse("/api/2.0/machines/?id=4y3ha3", http.StatusOK, "["+machineResponse+"]")
+	server.ResetRequests()
+
+	machine, err := device.ParentMachine()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.SystemID(), gc.Equals, "4y3ha3")
+
+	// The machine is remembered.
+	again, err := device.ParentMachine()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(again, gc.Equals, machine)
+	c.Check(server.RequestCount(), gc.Equals, 1)
+}
+
+func (s *deviceSuite) TestParentMachineForgottenWhenParentChanges(c *gc.C) {
+	server, device := s.getServerAndDevice(c)
+	server.AddGetResponse("/api/2.0/machines/?id=4y3ha3", http.StatusOK, "["+machineResponse+"]")
+	_, err := device.ParentMachine()
+	c.Assert(err, jc.ErrorIsNil)
+
+	response := updateJSONMap(c, deviceResponse, map[string]interface{}{
+		"parent": "4y3ha4",
+	})
+	server.AddPutResponse(device.resourceURI, http.StatusOK, response)
+	err = device.Update(UpdateDeviceArgs{Parent: "4y3ha4"})
+	c.Assert(err, jc.ErrorIsNil)
+
+	server.AddGetResponse("/api/2.0/machines/?id=4y3ha4", http.StatusOK, "[]")
+	_, err = device.ParentMachine()
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+	c.Check(err, gc.ErrorMatches, `parent "4y3ha4" of device "4y3haf" is not a machine`)
+}
+
+func (s *deviceSuite) TestParentMachineNoParent(c *gc.C) {
+	server, device := s.getServerAndDevice(c)
+	device.parent = ""
+	server.ResetRequests()
+
+	_, err := device.ParentMachine()
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+	c.Check(err, gc.ErrorMatches, `device "4y3haf" has no parent`)
+	c.Check(server.RequestCount(), gc.Equals, 0)
 }
 
 const (
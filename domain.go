@@ -0,0 +1,217 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+	"github.com/juju/version"
+)
+
+type domain struct {
+	// Add the controller in when we need to do things with the domain.
+	// controller Controller
+
+	resourceURI string
+
+	id                  int
+	name                string
+	ttl                 int
+	authoritative       bool
+	isDefault           bool
+	resourceRecordCount int
+}
+
+// ID implements Domain.
+func (d *domain) ID() int {
+	return d.id
+}
+
+// Name implements Domain.
+func (d *domain) Name() string {
+	return d.name
+}
+
+// TTL implements Domain.
+func (d *domain) TTL() int {
+	return d.ttl
+}
+
+// Authoritative implements Domain.
+func (d *domain) Authoritative() bool {
+	return d.authoritative
+}
+
+// IsDefault implements Domain.
+func (d *domain) IsDefault() bool {
+	return d.isDefault
+}
+
+// ResourceRecordCount implements Domain.
+func (d *domain) ResourceRecordCount() int {
+	return d.resourceRecordCount
+}
+
+// Domains implements Controller.
+func (c *controller) Domains() ([]Domain, error) {
+	domains, err := c.readDomains()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result []Domain
+	for _, d := range domains {
+		result = append(result, d)
+	}
+	return result, nil
+}
+
+func (c *controller) readDomains() ([]*domain, error) {
+	source, err := c.get("domains")
+	if err != nil {
+		return nil, translateError(opEntity, err)
+	}
+	domains, err := readDomains(c.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err = c.checkDecoded("domain", source, domains); err != nil {
+		return nil, errors.Trace(err)
+	}
+	sortDomains(domains)
+	return domains, nil
+}
+
+// DefaultDomain implements Controller.
+//
+// Returns
+//  - NoMatchError if none of the domains is the default
+func (c *controller) DefaultDomain() (Domain, error) {
+	domains, err := c.readDomains()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for _, d := range domains {
+		if d.isDefault {
+			return d, nil
+		}
+	}
+	return nil, NewNoMatchError("no default domain")
+}
+
+// SetDefaultDomain implements Controller.
+//
+// Returns
+//  - NoMatchError if there is no domain with the name
+//  - PermissionError if the user does not have permission to change the
+//    default domain
+func (c *controller) SetDefaultDomain(name string) error {
+	domains, err := c.readDomains()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, d := range domains {
+		if d.name != name {
+			continue
+		}
+		if d.isDefault {
+			return nil
+		}
+		_, err := c.post(fmt.Sprintf("domains/%d", d.id), "set_default", nil)
+		if err != nil {
+			return translateError(opEntity, err)
+		}
+		return nil
+	}
+	return NewNoMatchError(fmt.Sprintf("no domain %q", name))
+}
+
+func readDomains(controllerVersion version.Number, source interface{}) ([]*domain, error) {
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "domain base schema check failed")
+	}
+	valid := coerced.([]interface{})
+
+	var deserialisationVersion version.Number
+	for v := range domainDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, NewUnsupportedVersionError("no domain read func for version %s", controllerVersion)
+	}
+	readFunc := domainDeserializationFuncs[deserialisationVersion]
+	return readDomainList(valid, readFunc)
+}
+
+// readDomainList expects the values of the sourceList to be string maps.
+func readDomainList(sourceList []interface{}, readFunc domainDeserializationFunc) ([]*domain, error) {
+	result := make([]*domain, 0, len(sourceList))
+	for i, value := range sourceList {
+		source, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, NewDeserializationError("unexpected value for domain %d, %T", i, value)
+		}
+		domain, err := readFunc(source)
+		if err != nil {
+			return nil, annotateListItem(err, "domain", i, source)
+		}
+		result = append(result, domain)
+	}
+	return result, nil
+}
+
+type domainDeserializationFunc func(map[string]interface{}) (*domain, error)
+
+var domainDeserializationFuncs = map[version.Number]domainDeserializationFunc{
+	twoDotOh: domain_2_0,
+}
+
+var domain_2_0Checker = fieldMap("domain", schema.Fields{
+	"resource_uri":          schema.String(),
+	"id":                    schema.ForceInt(),
+	"name":                  schema.String(),
+	"ttl":                   schema.OneOf(schema.Nil(""), schema.ForceInt()),
+	"authoritative":         schema.Bool(),
+	"is_default":            schema.Bool(),
+	"resource_record_count": schema.ForceInt(),
+}, schema.Defaults{
+	// The fields below aren't in the responses of all MAAS versions.
+	"is_default":            schema.Omit,
+	"resource_record_count": schema.Omit,
+})
+
+func domain_2_0(source map[string]interface{}) (*domain, error) {
+	coerced, err := domain_2_0Checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "domain 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	id := valid["id"].(int)
+	isDefault, ok := valid["is_default"].(bool)
+	if !ok {
+		// Before is_default was added the default domain was always the
+		// one created with MAAS, which has ID 0.
+		isDefault = id == 0
+	}
+	ttl, _ := valid["ttl"].(int)
+	resourceRecordCount, _ := valid["resource_record_count"].(int)
+	result := &domain{
+		resourceURI:         valid["resource_uri"].(string),
+		id:                  id,
+		name:                valid["name"].(string),
+		ttl:                 ttl,
+		authoritative:       valid["authoritative"].(bool),
+		isDefault:           isDefault,
+		resourceRecordCount: resourceRecordCount,
+	}
+	return result, nil
+}
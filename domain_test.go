@@ -0,0 +1,148 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type domainSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&domainSuite{})
+
+const domainsPath = "/api/2.0/domains/"
+
+func (*domainSuite) TestReadDomainsBadSchema(c *gc.C) {
+	_, err := readDomains(twoDotOh, "wat?")
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err.Error(), gc.Equals, `domain base schema check failed: expected list, got string("wat?")`)
+}
+
+func (*domainSuite) TestReadDomains(c *gc.C) {
+	domains, err := readDomains(twoDotOh, parseJSON(c, domainsResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(domains, gc.HasLen, 2)
+
+	maas := domains[0]
+	c.Check(maas.ID(), gc.Equals, 0)
+	c.Check(maas.Name(), gc.Equals, "maas")
+	c.Check(maas.TTL(), gc.Equals, 0)
+	c.Check(maas.Authoritative(), jc.IsTrue)
+	c.Check(maas.IsDefault(), jc.IsFalse)
+	c.Check(maas.ResourceRecordCount(), gc.Equals, 3)
+
+	example := domains[1]
+	c.Check(example.ID(), gc.Equals, 1)
+	c.Check(example.Name(), gc.Equals, "example.com")
+	c.Check(example.TTL(), gc.Equals, 300)
+	c.Check(example.IsDefault(), jc.IsTrue)
+}
+
+func (*domainSuite) TestReadDomainsWithoutIsDefault(c *gc.C) {
+	// Older versions of MAAS don't say which domain is the default, which
+	// is then always the first one.
+	domains, err := readDomains(twoDotOh, parseJSON(c, `[
+	    {"id": 0, "name": "maas", "ttl": null, "authoritative": true, "resource_uri": "/MAAS/api/2.0/domains/0/"},
+	    {"id": 1, "name": "example.com", "ttl": null, "authoritative": true, "resource_uri": "/MAAS/api/2.0/domains/1/"}
+	]`))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(domains, gc.HasLen, 2)
+	c.Check(domains[0].IsDefault(), jc.IsTrue)
+	c.Check(domains[1].IsDefault(), jc.IsFalse)
+}
+
+func (s *domainSuite) TestDomains(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse(domainsPath, http.StatusOK, domainsResponse)
+
+	domains, err := controller.Domains()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(domains, gc.HasLen, 2)
+	c.Check(domains[0].Name(), gc.Equals, "example.com")
+	c.Check(domains[1].Name(), gc.Equals, "maas")
+}
+
+func (s *domainSuite) TestDefaultDomain(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse(domainsPath, http.StatusOK, domainsResponse)
+
+	domain, err := controller.DefaultDomain()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(domain.Name(), gc.Equals, "example.com")
+}
+
+func (s *domainSuite) TestDefaultDomainNone(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse(domainsPath, http.StatusOK, "[]")
+
+	_, err := controller.DefaultDomain()
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+}
+
+func (s *domainSuite) TestSetDefaultDomain(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse(domainsPath, http.StatusOK, domainsResponse)
+	server.AddPostResponse("/api/2.0/domains/0/?op=set_default", http.StatusOK, "{}")
+
+	err := controller.SetDefaultDomain("maas")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(server.LastRequest().URL.String(), gc.Equals, "/api/2.0/domains/0/?op=set_default")
+}
+
+func (s *domainSuite) TestSetDefaultDomainAlreadyDefault(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse(domainsPath, http.StatusOK, domainsResponse)
+	server.ResetRequests()
+
+	err := controller.SetDefaultDomain("example.com")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(server.RequestCount(), gc.Equals, 1)
+}
+
+func (s *domainSuite) TestSetDefaultDomainMissing(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse(domainsPath, http.StatusOK, domainsResponse)
+
+	err := controller.SetDefaultDomain("example.org")
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+	c.Assert(err, gc.ErrorMatches, `no domain "example.org"`)
+}
+
+func (s *domainSuite) TestSetDefaultDomainForbidden(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse(domainsPath, http.StatusOK, domainsResponse)
+	server.AddPostResponse("/api/2.0/domains/0/?op=set_default", http.StatusForbidden, "admins only")
+
+	err := controller.SetDefaultDomain("maas")
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+}
+
+var domainsResponse = `
+[
+    {
+        "authoritative": true,
+        "ttl": null,
+        "resource_record_count": 3,
+        "id": 0,
+        "name": "maas",
+        "is_default": false,
+        "resource_uri": "/MAAS/api/2.0/domains/0/"
+    },
+    {
+        "authoritative": true,
+        "ttl": 300,
+        "resource_record_count": 0,
+        "id": 1,
+        "name": "example.com",
+        "is_default": true,
+        "resource_uri": "/MAAS/api/2.0/domains/1/"
+    }
+]
+`
@@ -0,0 +1,176 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/utils/clock"
+)
+
+// endpointRetryInterval is how long an endpoint that couldn't be reached is
+// passed over before it is tried again.
+var endpointRetryInterval = 30 * time.Second
+
+// endpoints spreads the requests of a Client over the region controllers of
+// a MAAS. Requests are sent to the first endpoint that is up, and fail over
+// to the next when it can't be reached. GET requests are sent to the read
+// endpoints first, if there are any.
+type endpoints struct {
+	clock clock.Clock
+	write []*url.URL
+	read  []*url.URL
+
+	mu        sync.Mutex
+	downUntil map[string]time.Time
+}
+
+// newEndpoints returns the endpoints for the base URL, the failover URLs and
+// the read URLs of a controller, or nil if there are no other endpoints than
+// the base URL. The URLs may include the API version, and must have the same
+// path as the base URL once it is removed.
+func newEndpoints(clock clock.Clock, baseURL string, failoverURLs, readURLs []string) (*endpoints, error) {
+	if len(failoverURLs) == 0 && len(readURLs) == 0 {
+		return nil, nil
+	}
+	base, err := parseEndpoint(baseURL)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	parse := func(rawURLs []string) ([]*url.URL, error) {
+		var result []*url.URL
+		for _, rawURL := range rawURLs {
+			endpoint, err := parseEndpoint(rawURL)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			if endpoint.Path != base.Path {
+				return nil, errors.NotValidf("URL %q with a different path to %q", rawURL, baseURL)
+			}
+			result = append(result, endpoint)
+		}
+		return result, nil
+	}
+	write, err := parse(failoverURLs)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	read, err := parse(readURLs)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &endpoints{
+		clock:     clock,
+		write:     append([]*url.URL{base}, write...),
+		read:      read,
+		downUntil: make(map[string]time.Time),
+	}, nil
+}
+
+// parseEndpoint parses the URL, without the API version if it has one.
+func parseEndpoint(rawURL string) (*url.URL, error) {
+	base, _, _ := SplitVersionedURL(rawURL)
+	endpoint, err := url.Parse(base)
+	if err != nil {
+		return nil, errors.NotValidf("URL %q", rawURL)
+	}
+	if endpoint.Host == "" {
+		return nil, errors.NotValidf("URL %q without a host", rawURL)
+	}
+	endpoint.Path = strings.TrimSuffix(endpoint.Path, "/")
+	return endpoint, nil
+}
+
+// candidates returns the endpoints to try for a request with the method, in
+// order. The endpoints that are down go last, so that requests are still
+// attempted when they all are.
+func (e *endpoints) candidates(method string) []*url.URL {
+	all := e.write
+	if method == "GET" && len(e.read) > 0 {
+		all = append(append([]*url.URL{}, e.read...), e.write...)
+	}
+	now := e.clock.Now()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var up, down []*url.URL
+	for _, endpoint := range all {
+		if now.Before(e.downUntil[endpoint.Host]) {
+			down = append(down, endpoint)
+		} else {
+			up = append(up, endpoint)
+		}
+	}
+	return append(up, down...)
+}
+
+func (e *endpoints) setDown(endpoint *url.URL, down bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if down {
+		e.downUntil[endpoint.Host] = e.clock.Now().Add(endpointRetryInterval)
+	} else {
+		delete(e.downUntil, endpoint.Host)
+	}
+}
+
+// send sends the request with sendOne to each endpoint in turn, until one
+// of them can be reached.
+func (e *endpoints) send(request *http.Request, sendOne func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	var body []byte
+	if request.Body != nil {
+		var err error
+		body, err = readAndClose(request.Body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var lastErr error
+	for _, endpoint := range e.candidates(request.Method) {
+		attempt := request.Clone(request.Context())
+		attempt.URL.Scheme = endpoint.Scheme
+		attempt.URL.Host = endpoint.Host
+		attempt.Host = endpoint.Host
+		if body != nil {
+			attempt.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+		response, err := sendOne(attempt)
+		if err == nil {
+			e.setDown(endpoint, false)
+			return response, nil
+		}
+		lastErr = err
+		if request.Context().Err() != nil {
+			// The endpoint isn't at fault.
+			break
+		}
+		e.setDown(endpoint, true)
+		if !canFailOver(request.Method, err) {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+// canFailOver reports whether a request that failed with the error can be
+// sent to another endpoint. Requests that aren't idempotent are only sent
+// again if they can't have reached the endpoint.
+func canFailOver(method string, err error) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "PUT", "DELETE":
+		return true
+	}
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	opErr, ok := err.(*net.OpError)
+	return ok && opErr.Op == "dial"
+}
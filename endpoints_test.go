@@ -0,0 +1,143 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type endpointsSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&endpointsSuite{})
+
+func hosts(endpoints []*url.URL) []string {
+	var result []string
+	for _, endpoint := range endpoints {
+		result = append(result, endpoint.Host)
+	}
+	return result
+}
+
+func (*endpointsSuite) TestNewEndpointsNone(c *gc.C) {
+	endpoints, err := newEndpoints(&fakeClock{}, "http://region-1/MAAS/", nil, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(endpoints, gc.IsNil)
+}
+
+func (*endpointsSuite) TestNewEndpoints(c *gc.C) {
+	endpoints, err := newEndpoints(&fakeClock{},
+		"http://region-1/MAAS/",
+		[]string{"http://region-2/MAAS", "https://region-3/MAAS/api/2.0/"},
+		[]string{"http://replica-1/MAAS/"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(hosts(endpoints.write), jc.DeepEquals, []string{"region-1", "region-2", "region-3"})
+	c.Check(endpoints.write[2].Scheme, gc.Equals, "https")
+	c.Check(hosts(endpoints.read), jc.DeepEquals, []string{"replica-1"})
+}
+
+func (*endpointsSuite) TestNewEndpointsValidates(c *gc.C) {
+	_, err := newEndpoints(&fakeClock{}, "http://region-1/MAAS/", []string{"http://region-2/"}, nil)
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(err, gc.ErrorMatches, `URL "http://region-2/" with a different path to "http://region-1/MAAS/" not valid`)
+
+	_, err = newEndpoints(&fakeClock{}, "http://region-1/MAAS/", nil, []string{"/MAAS/"})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (*endpointsSuite) TestCandidates(c *gc.C) {
+	clock := &fakeClock{now: time.Now()}
+	endpoints, err := newEndpoints(clock,
+		"http://region-1/MAAS/",
+		[]string{"http://region-2/MAAS/"},
+		[]string{"http://replica-1/MAAS/"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(hosts(endpoints.candidates("GET")), jc.DeepEquals, []string{"replica-1", "region-1", "region-2"})
+	c.Check(hosts(endpoints.candidates("POST")), jc.DeepEquals, []string{"region-1", "region-2"})
+
+	endpoints.setDown(endpoints.write[0], true)
+	c.Check(hosts(endpoints.candidates("POST")), jc.DeepEquals, []string{"region-2", "region-1"})
+
+	clock.now = clock.now.Add(endpointRetryInterval)
+	c.Check(hosts(endpoints.candidates("POST")), jc.DeepEquals, []string{"region-1", "region-2"})
+}
+
+func (*endpointsSuite) TestCanFailOver(c *gc.C) {
+	refused := &url.Error{Op: "Post", URL: "http://region-1/", Err: &net.OpError{Op: "dial", Err: errors.New("refused")}}
+	reset := &url.Error{Op: "Post", URL: "http://region-1/", Err: &net.OpError{Op: "read", Err: errors.New("reset")}}
+	c.Check(canFailOver("GET", reset), jc.IsTrue)
+	c.Check(canFailOver("PUT", reset), jc.IsTrue)
+	c.Check(canFailOver("POST", refused), jc.IsTrue)
+	c.Check(canFailOver("POST", reset), jc.IsFalse)
+}
+
+// deadURL returns the URL of a server that has stopped.
+func deadURL() string {
+	server := NewSimpleServer()
+	server.Start()
+	server.Close()
+	return server.URL
+}
+
+func allocateResponse(c *gc.C) string {
+	return updateJSONMap(c, machineResponse, map[string]interface{}{
+		"constraints_by_type": map[string]interface{}{},
+	})
+}
+
+func (s *endpointsSuite) TestControllerFailsOver(c *gc.C) {
+	server := NewSimpleServer()
+	server.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	server.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusOK, allocateResponse(c))
+	server.Start()
+	s.AddCleanup(func(*gc.C) { server.Close() })
+
+	controller, err := NewController(ControllerArgs{
+		BaseURL:      deadURL(),
+		APIKey:       "fake:as:key",
+		FailoverURLs: []string{server.URL},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	_, _, err = controller.AllocateMachine(AllocateMachineArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(server.LastRequest().URL.Query().Get("op"), gc.Equals, "allocate")
+}
+
+func (s *endpointsSuite) TestControllerReadURLs(c *gc.C) {
+	region := NewSimpleServer()
+	region.AddPostResponse("/api/2.0/machines/?op=allocate", http.StatusOK, allocateResponse(c))
+	region.Start()
+	s.AddCleanup(func(*gc.C) { region.Close() })
+	replica := NewSimpleServer()
+	replica.AddGetResponse("/api/2.0/users/?op=whoami", http.StatusOK, `"captain awesome"`)
+	replica.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	replica.AddGetResponse("/api/2.0/machines/", http.StatusOK, machinesResponse)
+	replica.Start()
+	s.AddCleanup(func(*gc.C) { replica.Close() })
+
+	controller, err := NewController(ControllerArgs{
+		BaseURL:  region.URL,
+		APIKey:   "fake:as:key",
+		ReadURLs: []string{replica.URL},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	machines, err := controller.Machines(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machines, gc.HasLen, 3)
+	_, _, err = controller.AllocateMachine(AllocateMachineArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(region.RequestCount(), gc.Equals, 1)
+	c.Check(replica.RequestCount(), gc.Equals, 3)
+}
@@ -55,3 +55,23 @@ const (
 	// The node failed to erase its disks.
 	NodeStatusFailedDiskErasing = "15"
 )
+
+const (
+	// NodeType* values are the kinds of node, as returned by
+	// GenericNode.NodeType and Device.NodeType.
+
+	// The node is a machine that MAAS deploys.
+	NodeTypeMachine = 0
+
+	// The node is a device, which MAAS only manages the addresses of.
+	NodeTypeDevice = 1
+
+	// The node is a rack controller.
+	NodeTypeRackController = 2
+
+	// The node is a region controller.
+	NodeTypeRegionController = 3
+
+	// The node is both a region and a rack controller.
+	NodeTypeRegionAndRackController = 4
+)
@@ -4,7 +4,12 @@
 package gomaasapi
 
 import (
+	"encoding/json"
 	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
 
 	"github.com/juju/errors"
 )
@@ -77,6 +82,14 @@ func WrapWithUnsupportedVersionError(err error) error {
 // the controller doesn't match the code's expectations.
 type DeserializationError struct {
 	errors.Err
+
+	// Path locates the entity that couldn't be read within the response,
+	// outermost first, when it was read from a list. Each element names
+	// the kind of entity and its index in the list, along with its system
+	// ID, ID or name when it has one, such as `machine 2 (system_id
+	// "4y3ha3")` then `interface 0 (id 42)`. The message of the error
+	// names the offending field of the innermost entity.
+	Path []string
 }
 
 // NewDeserializationError constructs a new DeserializationError and sets the location.
@@ -111,19 +124,89 @@ func IsDeserializationError(err error) bool {
 	return ok
 }
 
+// annotateListItem annotates the error from reading the entity at the index
+// of a list with the kind of entity, the index and the identity of the
+// entity, which is also added to the Path of a DeserializationError.
+func annotateListItem(err error, entity string, index int, source map[string]interface{}) error {
+	item := fmt.Sprintf("%s %d", entity, index)
+	if identity := sourceIdentity(source); identity != "" {
+		item += " (" + identity + ")"
+	}
+	if derr, ok := errors.Cause(err).(*DeserializationError); ok {
+		derr.Path = append([]string{item}, derr.Path...)
+	}
+	annotated := errors.Annotate(err, item)
+	// We want the location of the annotation to be the caller of this
+	// function, not the line above.
+	if errType, ok := annotated.(*errors.Err); ok {
+		errType.SetLocation(1)
+	}
+	return annotated
+}
+
+// sourceIdentity describes the field of the source that identifies the
+// entity, if it has one of the usual ones.
+func sourceIdentity(source map[string]interface{}) string {
+	if systemID, ok := source["system_id"].(string); ok && systemID != "" {
+		return fmt.Sprintf("system_id %q", systemID)
+	}
+	switch id := source["id"].(type) {
+	case float64, json.Number:
+		return fmt.Sprintf("id %v", id)
+	case string:
+		return fmt.Sprintf("id %q", id)
+	}
+	if name, ok := source["name"].(string); ok && name != "" {
+		return fmt.Sprintf("name %q", name)
+	}
+	return ""
+}
+
 // BadRequestError is returned when the requested action cannot be performed
 // due to bad or incorrect parameters passed to the server.
 type BadRequestError struct {
 	errors.Err
+	// FieldErrors maps the names of the parameters that MAAS rejected to
+	// the reasons it gave, when the message is MAAS's JSON dictionary of
+	// field errors, such as {"hwe_kernel": ["Invalid kernel"]}. Errors
+	// that aren't about a single field are under "__all__". It is nil if
+	// the message isn't a dictionary of field errors.
+	FieldErrors map[string][]string
 }
 
 // NewBadRequestError constructs a new BadRequestError and sets the location.
 func NewBadRequestError(message string) error {
-	err := &BadRequestError{Err: errors.NewErr(message)}
+	err := &BadRequestError{
+		Err:         errors.NewErr(message),
+		FieldErrors: parseFieldErrors(message),
+	}
 	err.SetLocation(1)
 	return err
 }
 
+// parseFieldErrors returns the field errors in the message, or nil if it
+// isn't a JSON object whose values are strings or lists of strings.
+func parseFieldErrors(message string) map[string][]string {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(message), &fields); err != nil || len(fields) == 0 {
+		return nil
+	}
+	result := make(map[string][]string, len(fields))
+	for name, value := range fields {
+		var reasons []string
+		if err := json.Unmarshal(value, &reasons); err == nil {
+			result[name] = reasons
+			continue
+		}
+		var reason string
+		if err := json.Unmarshal(value, &reason); err != nil {
+			return nil
+		}
+		result[name] = []string{reason}
+	}
+	return result
+}
+
 // IsBadRequestError returns true if err is a NoMatchError.
 func IsBadRequestError(err error) bool {
 	_, ok := errors.Cause(err).(*BadRequestError)
@@ -167,3 +250,176 @@ func IsCannotCompleteError(err error) bool {
 	_, ok := errors.Cause(err).(*CannotCompleteError)
 	return ok
 }
+
+// AlreadyAllocatedError is returned when a specific machine can't be
+// allocated because it is allocated to a user already.
+type AlreadyAllocatedError struct {
+	errors.Err
+	SystemID string
+	Owner    string
+}
+
+// NewAlreadyAllocatedError constructs a new AlreadyAllocatedError and sets
+// the location.
+func NewAlreadyAllocatedError(systemID, owner string) error {
+	err := &AlreadyAllocatedError{
+		Err:      errors.NewErr("machine %q is allocated to %q", systemID, owner),
+		SystemID: systemID,
+		Owner:    owner,
+	}
+	err.SetLocation(1)
+	return err
+}
+
+// IsAlreadyAllocatedError returns true if err is an AlreadyAllocatedError.
+func IsAlreadyAllocatedError(err error) bool {
+	_, ok := errors.Cause(err).(*AlreadyAllocatedError)
+	return ok
+}
+
+// PlannedError is returned by the operations that would change the MAAS,
+// when the controller records them in an ActionPlan rather than making
+// them. It is usually wrapped in the error the operation returns, so check
+// for it with IsPlannedError.
+type PlannedError struct {
+	errors.Err
+}
+
+// NewPlannedError constructs a new PlannedError and sets the location.
+func NewPlannedError(message string) error {
+	err := &PlannedError{Err: errors.NewErr("%s", message)}
+	err.SetLocation(1)
+	return err
+}
+
+// IsPlannedError returns true if err is a PlannedError.
+func IsPlannedError(err error) bool {
+	_, ok := errors.Cause(err).(*PlannedError)
+	return ok
+}
+
+// MultiError is returned by BulkOperations when the operation failed for
+// one or more of the machines. Errors maps the system ID of each machine
+// that failed to the error for that machine.
+type MultiError struct {
+	errors.Err
+	Errors map[string]error
+}
+
+// NewMultiError constructs a new MultiError and sets the location.
+func NewMultiError(failures map[string]error) error {
+	ids := make([]string, 0, len(failures))
+	for id := range failures {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	messages := make([]string, len(ids))
+	for i, id := range ids {
+		messages[i] = fmt.Sprintf("%s: %v", id, failures[id])
+	}
+	noun := "machines"
+	if len(ids) == 1 {
+		noun = "machine"
+	}
+	err := &MultiError{
+		Err:    errors.NewErr("%d %s failed: %s", len(ids), noun, strings.Join(messages, "; ")),
+		Errors: failures,
+	}
+	err.SetLocation(1)
+	return err
+}
+
+// IsMultiError returns true if err is a MultiError.
+func IsMultiError(err error) bool {
+	_, ok := errors.Cause(err).(*MultiError)
+	return ok
+}
+
+// requestError records the ID of the request to the MAAS controller that
+// failed with the error. The cause of the error is unchanged.
+type requestError struct {
+	error
+	requestID string
+}
+
+func newRequestError(requestID string, err error) error {
+	return errors.Trace(&requestError{error: err, requestID: requestID})
+}
+
+// Error implements error.
+func (e *requestError) Error() string {
+	return fmt.Sprintf("request %s: %v", e.requestID, e.error)
+}
+
+// Cause returns the cause of the error, for errors.Cause.
+func (e *requestError) Cause() error {
+	return errors.Cause(e.error)
+}
+
+// Underlying returns the error, for errors.ErrorStack.
+func (e *requestError) Underlying() error {
+	return e.error
+}
+
+// Message returns the request ID, for errors.ErrorStack.
+func (e *requestError) Message() string {
+	return "request " + e.requestID
+}
+
+// RequestID returns the ID of the request to the MAAS controller that
+// failed with the error, if the error came from a request. It is the ID sent
+// in the ControllerArgs.RequestIDHeader header of the request.
+func RequestID(err error) (string, bool) {
+	for err != nil {
+		if reqErr, ok := err.(*requestError); ok {
+			return reqErr.requestID, true
+		}
+		wrapper, ok := err.(interface {
+			Underlying() error
+		})
+		if !ok {
+			break
+		}
+		err = wrapper.Underlying()
+	}
+	return "", false
+}
+
+// IsRetryable reports whether an operation that failed with the error may
+// succeed if it is made again later, unchanged. The errors of the MAAS
+// controller are retryable if it was busy or unavailable, which it reports
+// with the statuses 408, 429, 502, 503 and 504, as are timeouts and failures
+// to connect to it. Other errors, such as the 4xx statuses, come from the
+// arguments or the state of the MAAS, so they aren't.
+func IsRetryable(err error) bool {
+	for err != nil {
+		if svrErr, ok := err.(ServerError); ok {
+			switch svrErr.StatusCode {
+			case http.StatusRequestTimeout,
+				http.StatusTooManyRequests,
+				http.StatusBadGateway,
+				http.StatusServiceUnavailable,
+				http.StatusGatewayTimeout:
+				return true
+			}
+			return false
+		}
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			return true
+		}
+		if opErr, ok := err.(*net.OpError); ok && opErr.Op == "dial" {
+			return true
+		}
+		// The errors of this package are wrapped with Underlying, and
+		// those of the standard library, such as *url.Error, with Unwrap.
+		switch wrapper := err.(type) {
+		case interface{ Underlying() error }:
+			err = wrapper.Underlying()
+		case interface{ Unwrap() error }:
+			err = wrapper.Unwrap()
+		default:
+			return false
+		}
+	}
+	return false
+}
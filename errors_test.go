@@ -4,6 +4,9 @@
 package gomaasapi
 
 import (
+	"net"
+	"net/http"
+	"net/url"
 	"strings"
 
 	"github.com/juju/errors"
@@ -62,6 +65,28 @@ func (*errorTypesSuite) TestWrapWithDeserializationError(c *gc.C) {
 	c.Assert(strings.Split(stack, "\n"), gc.HasLen, 2)
 }
 
+func (*errorTypesSuite) TestAnnotateListItem(c *gc.C) {
+	err := NewDeserializationError("name: expected string, got nothing")
+	err = annotateListItem(err, "interface", 1, map[string]interface{}{"id": float64(42)})
+	err = annotateListItem(err, "machine", 2, map[string]interface{}{"system_id": "4y3ha3"})
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err.Error(), gc.Equals, `machine 2 (system_id "4y3ha3"): interface 1 (id 42): name: expected string, got nothing`)
+	derr := errors.Cause(err).(*DeserializationError)
+	c.Assert(derr.Path, jc.DeepEquals, []string{`machine 2 (system_id "4y3ha3")`, `interface 1 (id 42)`})
+}
+
+func (*errorTypesSuite) TestAnnotateListItemWithoutIdentity(c *gc.C) {
+	err := annotateListItem(NewDeserializationError("bad"), "zone", 0, map[string]interface{}{"name": ""})
+	c.Assert(err.Error(), gc.Equals, "zone 0: bad")
+	c.Assert(errors.Cause(err).(*DeserializationError).Path, jc.DeepEquals, []string{"zone 0"})
+}
+
+func (*errorTypesSuite) TestAnnotateListItemOtherError(c *gc.C) {
+	err := annotateListItem(errors.New("boom"), "space", 3, map[string]interface{}{"name": "dmz"})
+	c.Assert(err, gc.Not(jc.Satisfies), IsDeserializationError)
+	c.Assert(err.Error(), gc.Equals, `space 3 (name "dmz"): boom`)
+}
+
 func (*errorTypesSuite) TestBadRequestError(c *gc.C) {
 	err := NewBadRequestError("omg")
 	c.Assert(err, gc.NotNil)
@@ -69,6 +94,35 @@ func (*errorTypesSuite) TestBadRequestError(c *gc.C) {
 	c.Assert(err.Error(), gc.Equals, "omg")
 }
 
+func (*errorTypesSuite) TestBadRequestErrorFieldErrors(c *gc.C) {
+	for i, test := range []struct {
+		message string
+		fields  map[string][]string
+	}{{
+		message: `{"hwe_kernel": ["Invalid kernel"], "__all__": ["Bad", "Worse"]}`,
+		fields: map[string][]string{
+			"hwe_kernel": {"Invalid kernel"},
+			"__all__":    {"Bad", "Worse"},
+		},
+	}, {
+		message: `{"name": "Name is required."}`,
+		fields:  map[string][]string{"name": {"Name is required."}},
+	}, {
+		message: "omg",
+	}, {
+		message: `["not", "fields"]`,
+	}, {
+		message: `{"count": 3}`,
+	}, {
+		message: `{}`,
+	}} {
+		c.Logf("test %d: %s", i, test.message)
+		err := NewBadRequestError(test.message)
+		c.Check(err.Error(), gc.Equals, test.message)
+		c.Check(err.(*BadRequestError).FieldErrors, jc.DeepEquals, test.fields)
+	}
+}
+
 func (*errorTypesSuite) TestPermissionError(c *gc.C) {
 	err := NewPermissionError("naughty")
 	c.Assert(err, gc.NotNil)
@@ -82,3 +136,83 @@ func (*errorTypesSuite) TestCannotCompleteError(c *gc.C) {
 	c.Assert(err, jc.Satisfies, IsCannotCompleteError)
 	c.Assert(err.Error(), gc.Equals, "server says no")
 }
+
+func (*errorTypesSuite) TestPlannedError(c *gc.C) {
+	err := NewPlannedError("GET machines/?hostname=100%25 planned, not sent")
+	c.Assert(err, gc.NotNil)
+	c.Assert(err, jc.Satisfies, IsPlannedError)
+	c.Assert(err.Error(), gc.Equals, "GET machines/?hostname=100%25 planned, not sent")
+}
+
+func (*errorTypesSuite) TestAlreadyAllocatedError(c *gc.C) {
+	err := NewAlreadyAllocatedError("4y3ha3", "thumper")
+	c.Assert(err, gc.NotNil)
+	c.Assert(err, jc.Satisfies, IsAlreadyAllocatedError)
+	c.Assert(err.Error(), gc.Equals, `machine "4y3ha3" is allocated to "thumper"`)
+	c.Assert(errors.Cause(err).(*AlreadyAllocatedError).Owner, gc.Equals, "thumper")
+}
+
+func (*errorTypesSuite) TestMultiError(c *gc.C) {
+	err := NewMultiError(map[string]error{
+		"def": errors.New("bad"),
+		"abc": errors.New("worse"),
+	})
+	c.Assert(err, gc.NotNil)
+	c.Assert(err, jc.Satisfies, IsMultiError)
+	c.Assert(err.Error(), gc.Equals, "2 machines failed: abc: worse; def: bad")
+	c.Assert(errors.Cause(err).(*MultiError).Errors, gc.HasLen, 2)
+}
+
+func (*errorTypesSuite) TestMultiErrorSingle(c *gc.C) {
+	err := NewMultiError(map[string]error{"abc": errors.New("bad")})
+	c.Assert(err.Error(), gc.Equals, "1 machine failed: abc: bad")
+}
+
+func (*errorTypesSuite) TestIsRetryableStatus(c *gc.C) {
+	for status, retryable := range map[int]bool{
+		http.StatusBadRequest:          false,
+		http.StatusForbidden:           false,
+		http.StatusNotFound:            false,
+		http.StatusConflict:            false,
+		http.StatusRequestTimeout:      true,
+		http.StatusTooManyRequests:     true,
+		http.StatusInternalServerError: false,
+		http.StatusBadGateway:          true,
+		http.StatusServiceUnavailable:  true,
+		http.StatusGatewayTimeout:      true,
+	} {
+		c.Logf("status %d", status)
+		svrErr := errors.Trace(ServerError{error: errors.New("oops"), StatusCode: status})
+		err := NewUnexpectedError(newRequestError("7", svrErr))
+		c.Check(IsRetryable(err), gc.Equals, retryable)
+	}
+}
+
+func (*errorTypesSuite) TestIsRetryableWrappedStatus(c *gc.C) {
+	svrErr := errors.Trace(ServerError{error: errors.New("oops"), StatusCode: http.StatusServiceUnavailable})
+	err := errors.Trace(errors.Wrap(svrErr, NewCannotCompleteError("no addresses")))
+	c.Check(IsRetryable(err), jc.IsTrue)
+
+	svrErr = errors.Trace(ServerError{error: errors.New("oops"), StatusCode: http.StatusBadRequest})
+	err = errors.Trace(errors.Wrap(svrErr, NewBadRequestError("bad")))
+	c.Check(IsRetryable(err), jc.IsFalse)
+}
+
+func (*errorTypesSuite) TestIsRetryableNetwork(c *gc.C) {
+	timeout := &url.Error{Op: "Get", URL: "http://maas/", Err: &net.DNSError{IsTimeout: true}}
+	c.Check(IsRetryable(NewUnexpectedError(errors.Trace(timeout))), jc.IsTrue)
+
+	refused := &url.Error{Op: "Get", URL: "http://maas/", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}
+	c.Check(IsRetryable(NewUnexpectedError(errors.Trace(refused))), jc.IsTrue)
+
+	reset := &url.Error{Op: "Post", URL: "http://maas/", Err: &net.OpError{Op: "read", Err: errors.New("connection reset by peer")}}
+	c.Check(IsRetryable(NewUnexpectedError(errors.Trace(reset))), jc.IsFalse)
+}
+
+func (*errorTypesSuite) TestIsRetryableOther(c *gc.C) {
+	c.Check(IsRetryable(nil), jc.IsFalse)
+	c.Check(IsRetryable(errors.New("wat")), jc.IsFalse)
+	c.Check(IsRetryable(errors.NotValidf("args")), jc.IsFalse)
+	c.Check(IsRetryable(NewNoMatchError("none")), jc.IsFalse)
+	c.Check(IsRetryable(NewPlannedError("planned")), jc.IsFalse)
+}
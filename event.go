@@ -0,0 +1,226 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+)
+
+// eventPollInterval is how often Machine.TailEvents asks for new events.
+var eventPollInterval = 5 * time.Second
+
+// eventsPageSize is the number of events asked for in each request.
+const eventsPageSize = 100
+
+// eventTimeLayout is the format of the times of the events.
+const eventTimeLayout = "Mon, 02 Jan. 2006 15:04:05"
+
+// timeLayouts are the formats of the other times MAAS sends: those of the
+// events, and those of Django's JSON encoder, which are in UTC.
+var timeLayouts = []string{
+	eventTimeLayout,
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+}
+
+// parseTime parses a time in any of the timeLayouts.
+func parseTime(value string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.Errorf("unknown time format %q", value)
+}
+
+// Event is an entry in the event log of a machine, as sent by
+// Machine.TailEvents.
+type Event struct {
+	ID          int
+	SystemID    string
+	Hostname    string
+	Type        string
+	Description string
+	Level       string
+	Created     time.Time
+}
+
+// TailEvents implements Machine.
+func (m *machine) TailEvents(ctx context.Context, since time.Time) (<-chan Event, <-chan error) {
+	events := make(chan Event)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		defer close(events)
+		if err := m.tailEvents(ctx, since, events); err != nil {
+			errs <- err
+		}
+	}()
+	return events, errs
+}
+
+func (m *machine) tailEvents(ctx context.Context, since time.Time, out chan<- Event) error {
+	send := func(events []Event) bool {
+		for _, event := range events {
+			select {
+			case out <- event:
+			case <-ctx.Done():
+				return false
+			}
+		}
+		return true
+	}
+
+	backlog, lastID, err := m.eventsSince(since)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if !send(backlog) {
+		return nil
+	}
+	for {
+		timer := time.NewTimer(eventPollInterval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		}
+		// A full page means there are more events waiting, which are
+		// read without waiting again.
+		for {
+			page, err := m.queryEvents(url.Values{"after": {strconv.Itoa(lastID)}})
+			if err != nil {
+				return errors.Trace(err)
+			}
+			if len(page) == 0 {
+				break
+			}
+			sort.Slice(page, func(i, j int) bool { return page[i].ID < page[j].ID })
+			if !send(page) {
+				return nil
+			}
+			lastID = page[len(page)-1].ID
+			if len(page) < eventsPageSize {
+				break
+			}
+		}
+	}
+}
+
+// eventsSince returns the machine's events created since the time, oldest
+// first, and the ID of the latest event. Only the latest page of events is
+// returned if since is zero.
+func (m *machine) eventsSince(since time.Time) ([]Event, int, error) {
+	var (
+		newestFirst []Event
+		lastID      int
+		before      url.Values
+	)
+	for {
+		page, err := m.queryEvents(before)
+		if err != nil {
+			return nil, 0, errors.Trace(err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		sort.Slice(page, func(i, j int) bool { return page[i].ID > page[j].ID })
+		if lastID == 0 {
+			lastID = page[0].ID
+		}
+		older := false
+		for _, event := range page {
+			if event.Created.Before(since) {
+				older = true
+				break
+			}
+			newestFirst = append(newestFirst, event)
+		}
+		if older || since.IsZero() || len(page) < eventsPageSize {
+			break
+		}
+		before = url.Values{"before": {strconv.Itoa(page[len(page)-1].ID)}}
+	}
+	result := make([]Event, len(newestFirst))
+	for i, event := range newestFirst {
+		result[len(result)-1-i] = event
+	}
+	return result, lastID, nil
+}
+
+// queryEvents returns a page of the machine's events, selected by the
+// "after" or "before" event ID in the params.
+func (m *machine) queryEvents(params url.Values) ([]Event, error) {
+	query := NewURLParams()
+	for key, values := range params {
+		query.Values[key] = values
+	}
+	query.Values.Add("id", m.systemID)
+	query.Values.Add("limit", strconv.Itoa(eventsPageSize))
+	source, err := m.controller._get("events", "query", query.Values)
+	if err != nil {
+		return nil, translateError(opEntity, err)
+	}
+	return readEvents(source)
+}
+
+func readEvents(source interface{}) ([]Event, error) {
+	checker := schema.FieldMap(schema.Fields{
+		"events": schema.List(schema.StringMap(schema.Any())),
+	}, nil) // no defaults
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "event base schema check failed")
+	}
+	valid := coerced.(map[string]interface{})["events"].([]interface{})
+	result := make([]Event, 0, len(valid))
+	for i, value := range valid {
+		source := value.(map[string]interface{})
+		event, err := event_2_0(source)
+		if err != nil {
+			return nil, annotateListItem(err, "event", i, source)
+		}
+		result = append(result, event)
+	}
+	return result, nil
+}
+
+var event_2_0Checker = fieldMap("event", schema.Fields{
+	"id":          schema.ForceInt(),
+	"node":        schema.String(),
+	"hostname":    schema.String(),
+	"type":        schema.String(),
+	"description": schema.String(),
+	"level":       schema.String(),
+	"created":     schema.String(),
+}, nil) // no defaults
+
+func event_2_0(source map[string]interface{}) (Event, error) {
+	coerced, err := event_2_0Checker.Coerce(source, nil)
+	if err != nil {
+		return Event{}, WrapWithDeserializationError(err, "event 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	created, err := time.Parse(eventTimeLayout, valid["created"].(string))
+	if err != nil {
+		return Event{}, WrapWithDeserializationError(err, "event created time")
+	}
+	return Event{
+		ID:          valid["id"].(int),
+		SystemID:    valid["node"].(string),
+		Hostname:    valid["hostname"].(string),
+		Type:        valid["type"].(string),
+		Description: valid["description"].(string),
+		Level:       valid["level"].(string),
+		Created:     created,
+	}, nil
+}
@@ -0,0 +1,121 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+const eventsPath = "/api/2.0/events/?id=4y3ha3&limit=100&op=query"
+
+func eventsAfterPath(id int) string {
+	return fmt.Sprintf("/api/2.0/events/?after=%d&id=4y3ha3&limit=100&op=query", id)
+}
+
+func eventsResponse(ids ...int) string {
+	var events string
+	for i, id := range ids {
+		if i > 0 {
+			events += ","
+		}
+		events += fmt.Sprintf(`{
+            "id": %d,
+            "node": "4y3ha3",
+            "hostname": "untasted-markita",
+            "type": "Deploying",
+            "description": "event %d",
+            "level": "INFO",
+            "created": "Tue, 15 Oct. 2019 10:00:%02d"
+        }`, id, id, id)
+	}
+	return `{"count": ` + fmt.Sprint(len(ids)) + `, "events": [` + events + `], "next_uri": "", "prev_uri": ""}`
+}
+
+// collectEvents reads the events until the channels are closed.
+func collectEvents(c *gc.C, events <-chan Event, errs <-chan error) ([]int, error) {
+	var ids []int
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return ids, <-errs
+			}
+			ids = append(ids, event.ID)
+		case <-timeout:
+			c.Fatalf("timed out waiting for events")
+		}
+	}
+}
+
+func (*machineSuite) TestReadEvents(c *gc.C) {
+	events, err := readEvents(parseJSON(c, eventsResponse(10)))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(events, jc.DeepEquals, []Event{{
+		ID:          10,
+		SystemID:    "4y3ha3",
+		Hostname:    "untasted-markita",
+		Type:        "Deploying",
+		Description: "event 10",
+		Level:       "INFO",
+		Created:     time.Date(2019, time.October, 15, 10, 0, 10, 0, time.UTC),
+	}})
+}
+
+func (*machineSuite) TestReadEventsBadSchema(c *gc.C) {
+	_, err := readEvents("wat?")
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+
+	_, err = readEvents(parseJSON(c, `{"events": [{"id": 1}]}`))
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Check(err, gc.ErrorMatches, `event 0 \(id 1\): event 2.0 schema check failed: .*`)
+}
+
+func (s *machineSuite) TestTailEventsSince(c *gc.C) {
+	s.PatchValue(&eventPollInterval, time.Millisecond)
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse(eventsPath, http.StatusOK, eventsResponse(12, 11, 10))
+	server.AddGetResponse(eventsAfterPath(12), http.StatusForbidden, "bad user")
+
+	since := time.Date(2019, time.October, 15, 10, 0, 11, 0, time.UTC)
+	events, errs := machine.TailEvents(context.Background(), since)
+	ids, err := collectEvents(c, events, errs)
+	c.Assert(ids, jc.DeepEquals, []int{11, 12})
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+}
+
+func (s *machineSuite) TestTailEventsPolls(c *gc.C) {
+	s.PatchValue(&eventPollInterval, time.Millisecond)
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse(eventsPath, http.StatusOK, eventsResponse(10))
+	server.AddGetResponse(eventsAfterPath(10), http.StatusOK, eventsResponse(11))
+	server.AddGetResponse(eventsAfterPath(11), http.StatusOK, eventsResponse())
+	server.AddGetResponse(eventsAfterPath(11), http.StatusInternalServerError, "boom")
+
+	events, errs := machine.TailEvents(context.Background(), time.Time{})
+	ids, err := collectEvents(c, events, errs)
+	c.Assert(ids, jc.DeepEquals, []int{10, 11})
+	c.Assert(err, jc.Satisfies, IsUnexpectedError)
+}
+
+func (s *machineSuite) TestTailEventsContextDone(c *gc.C) {
+	s.PatchValue(&eventPollInterval, time.Hour)
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse(eventsPath, http.StatusOK, eventsResponse(11, 10))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, errs := machine.TailEvents(ctx, time.Time{})
+	c.Assert((<-events).ID, gc.Equals, 10)
+	c.Assert((<-events).ID, gc.Equals, 11)
+	cancel()
+	ids, err := collectEvents(c, events, errs)
+	c.Assert(ids, gc.HasLen, 0)
+	c.Assert(err, jc.ErrorIsNil)
+}
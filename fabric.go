@@ -46,6 +46,13 @@ func (f *fabric) VLANs() []VLAN {
 	return result
 }
 
+// bind associates the fabric's VLANs with the controller.
+func (f *fabric) bind(c *controller) {
+	for _, v := range f.vlans {
+		v.bind(c)
+	}
+}
+
 func readFabrics(controllerVersion version.Number, source interface{}) ([]*fabric, error) {
 	checker := schema.List(schema.StringMap(schema.Any()))
 	coerced, err := checker.Coerce(source, nil)
@@ -77,7 +84,7 @@ func readFabricList(sourceList []interface{}, readFunc fabricDeserializationFunc
 		}
 		fabric, err := readFunc(source)
 		if err != nil {
-			return nil, errors.Annotatef(err, "fabric %d", i)
+			return nil, annotateListItem(err, "fabric", i, source)
 		}
 		result = append(result, fabric)
 	}
@@ -90,16 +97,16 @@ var fabricDeserializationFuncs = map[version.Number]fabricDeserializationFunc{
 	twoDotOh: fabric_2_0,
 }
 
+var fabric_2_0Checker = fieldMap("fabric", schema.Fields{
+	"resource_uri": schema.String(),
+	"id":           schema.ForceInt(),
+	"name":         schema.String(),
+	"class_type":   schema.OneOf(schema.Nil(""), schema.String()),
+	"vlans":        schema.List(schema.StringMap(schema.Any())),
+}, nil) // no defaults
+
 func fabric_2_0(source map[string]interface{}) (*fabric, error) {
-	fields := schema.Fields{
-		"resource_uri": schema.String(),
-		"id":           schema.ForceInt(),
-		"name":         schema.String(),
-		"class_type":   schema.OneOf(schema.Nil(""), schema.String()),
-		"vlans":        schema.List(schema.StringMap(schema.Any())),
-	}
-	checker := schema.FieldMap(fields, nil) // no defaults
-	coerced, err := checker.Coerce(source, nil)
+	coerced, err := fabric_2_0Checker.Coerce(source, nil)
 	if err != nil {
 		return nil, errors.Annotatef(err, "fabric 2.0 schema check failed")
 	}
@@ -4,8 +4,9 @@
 package gomaasapi
 
 import (
+	"crypto/sha256"
 	"encoding/base64"
-	"net/http"
+	"encoding/hex"
 	"net/url"
 
 	"github.com/juju/errors"
@@ -20,6 +21,22 @@ type file struct {
 	filename     string
 	anonymousURI *url.URL
 	content      string
+
+	// size is -1 and sha256 empty unless MAAS reports them.
+	size   int64
+	sha256 string
+}
+
+// FileInfo is the metadata of a file stored in MAAS, as returned by
+// File.Stat and Controller.FileInfos.
+type FileInfo struct {
+	Filename     string
+	AnonymousURL string
+	// Size is the length of the content in bytes, and SHA256 the hex
+	// encoded SHA-256 hash of the content. Size is -1 and SHA256 is empty
+	// if they aren't known without downloading the content.
+	Size   int64
+	SHA256 string
 }
 
 // Filename implements File.
@@ -33,19 +50,32 @@ func (f *file) AnonymousURL() string {
 	return url.String()
 }
 
+// Stat implements File.
+func (f *file) Stat() (FileInfo, error) {
+	info := FileInfo{
+		Filename:     f.filename,
+		AnonymousURL: f.AnonymousURL(),
+		Size:         f.size,
+		SHA256:       f.sha256,
+	}
+	if f.content != "" {
+		// The file was read with its content, so work them out.
+		bytes, err := base64.StdEncoding.DecodeString(f.content)
+		if err != nil {
+			return FileInfo{}, translateError(opEntity, err)
+		}
+		sum := sha256.Sum256(bytes)
+		info.Size = int64(len(bytes))
+		info.SHA256 = hex.EncodeToString(sum[:])
+	}
+	return info, nil
+}
+
 // Delete implements File.
 func (f *file) Delete() error {
 	err := f.controller.delete(f.resourceURI)
 	if err != nil {
-		if svrErr, ok := errors.Cause(err).(ServerError); ok {
-			switch svrErr.StatusCode {
-			case http.StatusNotFound:
-				return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
-			case http.StatusForbidden:
-				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
-			}
-		}
-		return NewUnexpectedError(err)
+		return translateError(opEntity, err)
 	}
 	return nil
 }
@@ -57,7 +87,7 @@ func (f *file) ReadAll() ([]byte, error) {
 	}
 	bytes, err := base64.StdEncoding.DecodeString(f.content)
 	if err != nil {
-		return nil, NewUnexpectedError(err)
+		return nil, translateError(opEntity, err)
 	}
 	return bytes, nil
 }
@@ -68,15 +98,7 @@ func (f *file) readFromServer() ([]byte, error) {
 	args.Add("filename", f.filename)
 	bytes, err := f.controller._getRaw("files", "get", args)
 	if err != nil {
-		if svrErr, ok := errors.Cause(err).(ServerError); ok {
-			switch svrErr.StatusCode {
-			case http.StatusNotFound:
-				return nil, errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
-			case http.StatusForbidden:
-				return nil, errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
-			}
-		}
-		return nil, NewUnexpectedError(err)
+		return nil, translateError(opEntity, err)
 	}
 	return bytes, nil
 }
@@ -134,7 +156,7 @@ func readFileList(sourceList []interface{}, readFunc fileDeserializationFunc) ([
 		}
 		file, err := readFunc(source)
 		if err != nil {
-			return nil, errors.Annotatef(err, "file %d", i)
+			return nil, annotateListItem(err, "file", i, source)
 		}
 		result = append(result, file)
 	}
@@ -147,18 +169,22 @@ var fileDeserializationFuncs = map[version.Number]fileDeserializationFunc{
 	twoDotOh: file_2_0,
 }
 
+var file_2_0Checker = fieldMap("file", schema.Fields{
+	"resource_uri":      schema.String(),
+	"filename":          schema.String(),
+	"anon_resource_uri": schema.String(),
+	"content":           schema.String(),
+	// Not reported by current controllers, but used if they are.
+	"size":   schema.ForceInt(),
+	"sha256": schema.String(),
+}, schema.Defaults{
+	"content": "",
+	"size":    -1,
+	"sha256":  "",
+})
+
 func file_2_0(source map[string]interface{}) (*file, error) {
-	fields := schema.Fields{
-		"resource_uri":      schema.String(),
-		"filename":          schema.String(),
-		"anon_resource_uri": schema.String(),
-		"content":           schema.String(),
-	}
-	defaults := schema.Defaults{
-		"content": "",
-	}
-	checker := schema.FieldMap(fields, defaults)
-	coerced, err := checker.Coerce(source, nil)
+	coerced, err := file_2_0Checker.Coerce(source, nil)
 	if err != nil {
 		return nil, WrapWithDeserializationError(err, "file 2.0 schema check failed")
 	}
@@ -168,7 +194,7 @@ func file_2_0(source map[string]interface{}) (*file, error) {
 
 	anonURI, err := url.ParseRequestURI(valid["anon_resource_uri"].(string))
 	if err != nil {
-		return nil, NewUnexpectedError(err)
+		return nil, translateError(opEntity, err)
 	}
 
 	result := &file{
@@ -176,6 +202,8 @@ func file_2_0(source map[string]interface{}) (*file, error) {
 		filename:     valid["filename"].(string),
 		anonymousURI: anonURI,
 		content:      valid["content"].(string),
+		size:         int64(valid["size"].(int)),
+		sha256:       valid["sha256"].(string),
 	}
 	return result, nil
 }
@@ -69,6 +69,56 @@ func (s *fileSuite) TestReadAllFromFiles(c *gc.C) {
 	c.Assert(string(content), gc.Equals, "some content\n")
 }
 
+func (s *fileSuite) TestStatFromGetFile(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/files/testing/", http.StatusOK, fileResponse)
+	file, err := controller.GetFile("testing")
+	c.Assert(err, jc.ErrorIsNil)
+	server.ResetRequests()
+
+	info, err := file.Stat()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(info, jc.DeepEquals, FileInfo{
+		Filename:     "testing",
+		AnonymousURL: file.AnonymousURL(),
+		Size:         15,
+		SHA256:       "91751cee0a1ab8414400238a761411daa29643ab4b8243e9a91649e25be53ada",
+	})
+	c.Check(server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *fileSuite) TestFileInfos(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	listing := updateJSONMap(c, "{}", map[string]interface{}{
+		"resource_uri":      "/MAAS/api/2.0/files/testing/",
+		"anon_resource_uri": "/MAAS/api/2.0/files/?op=get_by_key&key=88e64b76-fb82-11e5-932f-52540051bf22",
+		"filename":          "testing",
+		"size":              4,
+		"sha256":            "0967115f2813a3541eaef77de9d9d5773f1c0c04314b0bbfe4ff3b3b1c55b5d5",
+	})
+	server.AddGetResponse("/api/2.0/files/?prefix=test", http.StatusOK, filesResponse)
+	server.AddGetResponse("/api/2.0/files/?prefix=testing", http.StatusOK, "["+listing+"]")
+
+	infos, err := controller.FileInfos("test")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(infos, gc.HasLen, 2)
+	c.Check(infos[0].Filename, gc.Equals, "test")
+	c.Check(infos[0].Size, gc.Equals, int64(-1))
+	c.Check(infos[0].SHA256, gc.Equals, "")
+	c.Check(infos[0].AnonymousURL, gc.Matches, ".*/MAAS/api/2.0/files/\\?op=get_by_key&key=3afba564-fb7d-11e5-932f-52540051bf22")
+
+	// Sizes and hashes are used if MAAS reports them.
+	infos, err = controller.FileInfos("testing")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(infos, gc.HasLen, 1)
+	c.Check(infos[0].Size, gc.Equals, int64(4))
+	c.Check(infos[0].SHA256, gc.Equals, "0967115f2813a3541eaef77de9d9d5773f1c0c04314b0bbfe4ff3b3b1c55b5d5")
+	// Only the listings were read.
+	for _, request := range server.LastNRequests(2) {
+		c.Check(request.URL.Query().Get("op"), gc.Equals, "")
+	}
+}
+
 func (s *fileSuite) TestDeleteMissing(c *gc.C) {
 	// If we get a file, but someone else deletes it first, we get a ...
 	server, controller := createTestServerController(c, s)
@@ -0,0 +1,142 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"sort"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// FileSync describes the changes made, or to be made in a dry run, by
+// SyncFiles. Each list holds MAAS filenames, sorted.
+type FileSync struct {
+	Added   []string
+	Changed []string
+	Deleted []string
+}
+
+// SyncFiles implements Controller.
+//
+// Returns
+//  - NotValid error if two local files have the same MAAS filename
+//  - the errors of Files, AddFile and File.Delete
+func (c *controller) SyncFiles(prefix string, local fs.FS, dryRun bool) (FileSync, error) {
+	var result FileSync
+	wanted, err := readSyncFiles(prefix, local)
+	if err != nil {
+		return result, errors.Trace(err)
+	}
+	files, err := c.Files(prefix)
+	if err != nil {
+		return result, errors.Trace(err)
+	}
+	existing := make(map[string]File)
+	for _, f := range files {
+		existing[f.Filename()] = f
+	}
+
+	names := make([]string, 0, len(wanted))
+	for name := range wanted {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		content := wanted[name]
+		if f, ok := existing[name]; !ok {
+			result.Added = append(result.Added, name)
+		} else {
+			same, err := sameContent(f, content)
+			if err != nil {
+				return result, errors.Annotatef(err, "reading %q", name)
+			}
+			if same {
+				continue
+			}
+			result.Changed = append(result.Changed, name)
+		}
+		if dryRun {
+			continue
+		}
+		// AddFile replaces the content of existing files.
+		if err := c.AddFile(AddFileArgs{Filename: name, Content: content}); err != nil {
+			return result, errors.Annotatef(err, "uploading %q", name)
+		}
+	}
+
+	names = names[:0]
+	for name := range existing {
+		if _, ok := wanted[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		result.Deleted = append(result.Deleted, name)
+		if dryRun {
+			continue
+		}
+		if err := existing[name].Delete(); err != nil {
+			return result, errors.Annotatef(err, "deleting %q", name)
+		}
+	}
+	return result, nil
+}
+
+// readSyncFiles returns the content of the regular files in the local tree,
+// keyed by MAAS filename. MAAS filenames can't hold paths, so the slashes of
+// the paths are replaced with dashes, after the prefix.
+func readSyncFiles(prefix string, local fs.FS) (map[string][]byte, error) {
+	result := make(map[string][]byte)
+	paths := make(map[string]string)
+	err := fs.WalkDir(local, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		name := prefix + strings.Replace(p, "/", "-", -1)
+		if other, ok := paths[name]; ok {
+			return errors.NotValidf("%q and %q both syncing to %q", other, p, name)
+		}
+		content, err := fs.ReadFile(local, p)
+		if err != nil {
+			return err
+		}
+		if content == nil {
+			// AddFile needs non-nil content, even when it is empty.
+			content = []byte{}
+		}
+		paths[name] = p
+		result[name] = content
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return result, nil
+}
+
+// sameContent reports whether the file in MAAS has the content. The content
+// is only downloaded if MAAS doesn't report the hash of the file.
+func sameContent(f File, content []byte) (bool, error) {
+	sum := sha256.Sum256(content)
+	info, err := f.Stat()
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	if info.SHA256 != "" {
+		return info.SHA256 == hex.EncodeToString(sum[:]), nil
+	}
+	current, err := f.ReadAll()
+	if err != nil {
+		return false, errors.Trace(err)
+	}
+	return sha256.Sum256(current) == sum, nil
+}
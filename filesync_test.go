@@ -0,0 +1,138 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing/fstest"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+func (s *fileSuite) getSyncServer(c *gc.C) (*SimpleTestServer, Controller) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/files/?prefix=snip-", http.StatusOK, syncFilesResponse)
+	server.AddGetResponse("/api/2.0/files/?filename=snip-a&op=get", http.StatusOK, "same")
+	server.AddGetResponse("/api/2.0/files/?filename=snip-b&op=get", http.StatusOK, "old")
+	return server, controller
+}
+
+var syncLocalFiles = fstest.MapFS{
+	"a":     {Data: []byte("same")},
+	"b":     {Data: []byte("new")},
+	"dir/d": {Data: []byte("added")},
+}
+
+func (s *fileSuite) TestSyncFiles(c *gc.C) {
+	server, controller := s.getSyncServer(c)
+	server.AddPostResponse("/api/2.0/files/?op=", http.StatusOK, "")
+	server.AddPostResponse("/api/2.0/files/?op=", http.StatusOK, "")
+	server.AddDeleteResponse("/MAAS/api/2.0/files/snip-c/", http.StatusNoContent, "")
+
+	result, err := controller.SyncFiles("snip-", syncLocalFiles, false)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(result, jc.DeepEquals, FileSync{
+		Added:   []string{"snip-dir-d"},
+		Changed: []string{"snip-b"},
+		Deleted: []string{"snip-c"},
+	})
+
+	requests := server.LastNRequests(3)
+	s.assertUpload(c, requests[0], "snip-b", "new")
+	s.assertUpload(c, requests[1], "snip-dir-d", "added")
+	c.Check(requests[2].Method, gc.Equals, "DELETE")
+	c.Check(requests[2].URL.Path, gc.Equals, "/MAAS/api/2.0/files/snip-c/")
+}
+
+func (s *fileSuite) TestSyncFilesDryRun(c *gc.C) {
+	server, controller := s.getSyncServer(c)
+	server.ResetRequests()
+
+	result, err := controller.SyncFiles("snip-", syncLocalFiles, true)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(result, jc.DeepEquals, FileSync{
+		Added:   []string{"snip-dir-d"},
+		Changed: []string{"snip-b"},
+		Deleted: []string{"snip-c"},
+	})
+	// Only the list and the content of the existing files are read.
+	c.Check(server.RequestCount(), gc.Equals, 3)
+	for _, request := range server.LastNRequests(3) {
+		c.Check(request.Method, gc.Equals, "GET")
+	}
+}
+
+func (s *fileSuite) TestSyncFilesReportedHash(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	listing := updateJSONMap(c, "{}", map[string]interface{}{
+		"resource_uri":      "/MAAS/api/2.0/files/snip-a/",
+		"anon_resource_uri": "/MAAS/api/2.0/files/?op=get_by_key&key=3afba564-fb7d-11e5-932f-52540051bf22",
+		"filename":          "snip-a",
+		"size":              4,
+		"sha256":            "0967115f2813a3541eaef77de9d9d5773f1c0c04314b0bbfe4ff3b3b1c55b5d5",
+	})
+	server.AddGetResponse("/api/2.0/files/?prefix=snip-", http.StatusOK, "["+listing+"]")
+	server.ResetRequests()
+
+	result, err := controller.SyncFiles("snip-", fstest.MapFS{"a": {Data: []byte("same")}}, true)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(result, jc.DeepEquals, FileSync{})
+	// The content isn't downloaded to compare it.
+	c.Check(server.RequestCount(), gc.Equals, 1)
+}
+
+func (s *fileSuite) TestSyncFilesNameClash(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.ResetRequests()
+
+	_, err := controller.SyncFiles("snip-", fstest.MapFS{
+		"dir-d": {Data: []byte("one")},
+		"dir/d": {Data: []byte("two")},
+	}, true)
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *fileSuite) TestSyncFilesUploadError(c *gc.C) {
+	server, controller := s.getSyncServer(c)
+	server.AddPostResponse("/api/2.0/files/?op=", http.StatusBadRequest, "no room")
+
+	result, err := controller.SyncFiles("snip-", syncLocalFiles, false)
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+	c.Check(err, gc.ErrorMatches, `uploading "snip-b": no room`)
+	c.Check(result.Changed, jc.DeepEquals, []string{"snip-b"})
+	c.Check(result.Deleted, gc.HasLen, 0)
+}
+
+func (s *fileSuite) assertUpload(c *gc.C, request *http.Request, filename, content string) {
+	c.Check(request.Form.Get("filename"), gc.Equals, filename)
+	f, err := request.MultipartForm.File["file"][0].Open()
+	c.Assert(err, jc.ErrorIsNil)
+	bytes, err := ioutil.ReadAll(f)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(bytes), gc.Equals, content)
+}
+
+const syncFilesResponse = `
+[
+    {
+        "resource_uri": "/MAAS/api/2.0/files/snip-a/",
+        "anon_resource_uri": "/MAAS/api/2.0/files/?op=get_by_key&key=3afba564-fb7d-11e5-932f-52540051bf22",
+        "filename": "snip-a"
+    },
+    {
+        "resource_uri": "/MAAS/api/2.0/files/snip-b/",
+        "anon_resource_uri": "/MAAS/api/2.0/files/?op=get_by_key&key=69913e62-fad2-11e5-932f-52540051bf22",
+        "filename": "snip-b"
+    },
+    {
+        "resource_uri": "/MAAS/api/2.0/files/snip-c/",
+        "anon_resource_uri": "/MAAS/api/2.0/files/?op=get_by_key&key=88e64b76-fb82-11e5-932f-52540051bf22",
+        "filename": "snip-c"
+    }
+]
+`
@@ -10,7 +10,8 @@ type filesystem struct {
 	mountPoint string
 	label      string
 	uuid       string
-	// no idea what the mount_options are as a value type, so ignoring for now.
+	// mountOptions are passed to mount, e.g. "noatime,nodev".
+	mountOptions string
 }
 
 // Type implements FileSystem.
@@ -33,24 +34,28 @@ func (f *filesystem) UUID() string {
 	return f.uuid
 }
 
+// MountOptions implements FileSystem.
+func (f *filesystem) MountOptions() string {
+	return f.mountOptions
+}
+
 // There is no need for controller based parsing of filesystems until we need it.
 // Currently the filesystem reading is only called by the Partition parsing.
 
+var filesystem2_0Checker = fieldMap("filesystem", schema.Fields{
+	"fstype":        schema.String(),
+	"mount_point":   schema.OneOf(schema.Nil(""), schema.String()),
+	"label":         schema.OneOf(schema.Nil(""), schema.String()),
+	"uuid":          schema.String(),
+	"mount_options": schema.OneOf(schema.Nil(""), schema.String()),
+}, schema.Defaults{
+	"mount_point":   "",
+	"label":         "",
+	"mount_options": "",
+})
+
 func filesystem2_0(source map[string]interface{}) (*filesystem, error) {
-	fields := schema.Fields{
-		"fstype":      schema.String(),
-		"mount_point": schema.OneOf(schema.Nil(""), schema.String()),
-		"label":       schema.OneOf(schema.Nil(""), schema.String()),
-		"uuid":        schema.String(),
-		// TODO: mount_options when we know the type (note it can be
-		// nil).
-	}
-	defaults := schema.Defaults{
-		"mount_point": "",
-		"label":       "",
-	}
-	checker := schema.FieldMap(fields, defaults)
-	coerced, err := checker.Coerce(source, nil)
+	coerced, err := filesystem2_0Checker.Coerce(source, nil)
 	if err != nil {
 		return nil, WrapWithDeserializationError(err, "filesystem 2.0 schema check failed")
 	}
@@ -59,11 +64,13 @@ func filesystem2_0(source map[string]interface{}) (*filesystem, error) {
 	// contains fields of the right type.
 	mount_point, _ := valid["mount_point"].(string)
 	label, _ := valid["label"].(string)
+	mountOptions, _ := valid["mount_options"].(string)
 	result := &filesystem{
-		fstype:     valid["fstype"].(string),
-		mountPoint: mount_point,
-		label:      label,
-		uuid:       valid["uuid"].(string),
+		fstype:       valid["fstype"].(string),
+		mountPoint:   mount_point,
+		label:        label,
+		uuid:         valid["uuid"].(string),
+		mountOptions: mountOptions,
 	}
 	return result, nil
 }
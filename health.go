@@ -0,0 +1,74 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/version"
+)
+
+// HealthStatus is the result of Controller.Ping.
+type HealthStatus struct {
+	// Reachable is whether the MAAS controller's version endpoint
+	// responded.
+	Reachable bool
+	// APIVersionSupported is whether the MAAS controller still serves the
+	// API version that the Controller uses.
+	APIVersionSupported bool
+	// Authenticated is whether the MAAS controller accepted the
+	// Controller's credentials.
+	Authenticated bool
+
+	// ServerVersion is the MAAS release reported by the version endpoint.
+	ServerVersion version.Number
+	// Latency is how long the checks took.
+	Latency time.Duration
+}
+
+// Healthy returns whether all the checks passed.
+func (s HealthStatus) Healthy() bool {
+	return s.Reachable && s.APIVersionSupported && s.Authenticated
+}
+
+// Ping implements Controller.
+func (c *controller) Ping(ctx context.Context) (HealthStatus, error) {
+	if err := ctx.Err(); err != nil {
+		return HealthStatus{}, errors.Trace(err)
+	}
+	start := time.Now()
+	status, err := c.ping(ctx)
+	status.Latency = time.Since(start)
+	if err != nil && ctx.Err() != nil {
+		// The requests were abandoned when the context was done.
+		return HealthStatus{Latency: status.Latency}, errors.Trace(ctx.Err())
+	}
+	return status, err
+}
+
+func (c *controller) ping(ctx context.Context) (HealthStatus, error) {
+	var status HealthStatus
+	serverVersion, err := c.readAPIVersionInfo(ctx)
+	if IsUnsupportedVersionError(err) || IsDeserializationError(err) {
+		status.Reachable = true
+		return status, errors.Trace(err)
+	}
+	if err != nil {
+		// The controller responded if there is a status code.
+		if _, ok := GetServerError(err); ok {
+			status.Reachable = true
+		}
+		return status, translateError(opEntity, err)
+	}
+	status.Reachable = true
+	status.APIVersionSupported = true
+	status.ServerVersion = serverVersion.Version
+	if _, err := c.whoami(ctx); err != nil {
+		return status, errors.Trace(err)
+	}
+	status.Authenticated = true
+	return status, nil
+}
@@ -0,0 +1,116 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/version"
+	gc "gopkg.in/check.v1"
+)
+
+type healthSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&healthSuite{})
+
+const versionPath = "/api/2.0/version/"
+
+func (s *healthSuite) TestPing(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse(versionPath, http.StatusOK, versionResponseFor("2.9.2"))
+	server.AddGetResponse(whoamiPath, http.StatusOK, `"captain awesome"`)
+
+	status, err := controller.Ping(context.Background())
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(status.Healthy(), jc.IsTrue)
+	c.Check(status.ServerVersion, gc.Equals, version.MustParse("2.9.2"))
+	c.Check(status.Latency > 0, jc.IsTrue)
+}
+
+func (s *healthSuite) TestPingUnauthorized(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse(versionPath, http.StatusOK, versionResponse)
+	server.AddGetResponse(whoamiPath, http.StatusUnauthorized, "bad key")
+
+	status, err := controller.Ping(context.Background())
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+	c.Check(status.Reachable, jc.IsTrue)
+	c.Check(status.APIVersionSupported, jc.IsTrue)
+	c.Check(status.Authenticated, jc.IsFalse)
+	c.Check(status.Healthy(), jc.IsFalse)
+}
+
+func (s *healthSuite) TestPingUnsupportedVersion(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse(versionPath, http.StatusGone, "gone")
+
+	status, err := controller.Ping(context.Background())
+	c.Assert(err, jc.Satisfies, IsUnsupportedVersionError)
+	c.Check(status.Reachable, jc.IsTrue)
+	c.Check(status.APIVersionSupported, jc.IsFalse)
+	c.Check(status.Healthy(), jc.IsFalse)
+}
+
+func (s *healthSuite) TestPingServerError(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse(versionPath, http.StatusInternalServerError, "oops")
+
+	status, err := controller.Ping(context.Background())
+	c.Assert(err, jc.Satisfies, IsUnexpectedError)
+	c.Check(status.Reachable, jc.IsTrue)
+	c.Check(status.Healthy(), jc.IsFalse)
+}
+
+func (s *healthSuite) TestPingUnreachable(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.Close()
+
+	status, err := controller.Ping(context.Background())
+	c.Assert(err, jc.Satisfies, IsUnexpectedError)
+	c.Check(status.Reachable, jc.IsFalse)
+}
+
+func (s *healthSuite) TestPingContextDone(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.ResetRequests()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	status, err := controller.Ping(ctx)
+	c.Assert(err, gc.ErrorMatches, "context canceled")
+	c.Check(status.Healthy(), jc.IsFalse)
+	c.Check(server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *healthSuite) TestPingAbandonsRequests(c *gc.C) {
+	_, ctrl := createTestServerController(c, s)
+	// The version request to this server doesn't get a response until it
+	// is abandoned.
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Minute):
+		}
+	}))
+	defer slow.Close()
+	apiURL, err := url.Parse(slow.URL + "/api/2.0/")
+	c.Assert(err, jc.ErrorIsNil)
+	ctrl.(*controller).client.APIURL = apiURL
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	status, err := ctrl.Ping(ctx)
+	c.Assert(errors.Cause(err), gc.Equals, context.DeadlineExceeded)
+	c.Check(status.Healthy(), jc.IsFalse)
+	c.Check(status.Latency < time.Minute, jc.IsTrue)
+}
@@ -5,7 +5,8 @@ package gomaasapi
 
 import (
 	"fmt"
-	"net/http"
+	"net/netip"
+	"strings"
 
 	"github.com/juju/errors"
 	"github.com/juju/schema"
@@ -30,8 +31,41 @@ type interface_ struct {
 	macAddress   string
 	effectiveMTU int
 
+	linkConnected  bool
+	interfaceSpeed int
+	linkSpeed      int
+
+	sriovMaxVF      int
+	vendor          string
+	product         string
+	firmwareVersion string
+
 	parents  []string
 	children []string
+
+	params InterfaceParams
+}
+
+// InterfaceParams holds the bond, bridge and MTU parameters of an
+// interface, as given when it was created or last updated. The fields
+// are zero if MAAS doesn't report them.
+type InterfaceParams struct {
+	MTU int
+
+	BondMode           string
+	BondMiimon         int
+	BondDownDelay      int
+	BondUpDelay        int
+	BondLACPRate       string
+	BondXmitHashPolicy string
+
+	BridgeType string
+	BridgeSTP  bool
+	BridgeFD   int
+
+	// Raw holds all the params as reported by MAAS, including those
+	// without a field above. It is nil if the interface has no params.
+	Raw map[string]interface{}
 }
 
 func (i *interface_) updateFrom(other *interface_) {
@@ -45,8 +79,31 @@ func (i *interface_) updateFrom(other *interface_) {
 	i.links = other.links
 	i.macAddress = other.macAddress
 	i.effectiveMTU = other.effectiveMTU
+	i.linkConnected = other.linkConnected
+	i.interfaceSpeed = other.interfaceSpeed
+	i.linkSpeed = other.linkSpeed
+	i.sriovMaxVF = other.sriovMaxVF
+	i.vendor = other.vendor
+	i.product = other.product
+	i.firmwareVersion = other.firmwareVersion
 	i.parents = other.parents
 	i.children = other.children
+	i.params = other.params
+	i.bind(i.controller)
+}
+
+// bind associates the interface, its VLAN and the VLANs of its linked
+// subnets with the controller.
+func (i *interface_) bind(c *controller) {
+	i.controller = c
+	if i.vlan != nil {
+		i.vlan.bind(c)
+	}
+	for _, link := range i.links {
+		if link.subnet != nil {
+			link.subnet.bind(c)
+		}
+	}
 }
 
 // ID implements Interface.
@@ -92,6 +149,15 @@ func (i *interface_) VLAN() VLAN {
 	return i.vlan
 }
 
+func (i *interface_) addressErr() error {
+	for _, link := range i.links {
+		if err := link.addressErr(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Links implements Interface.
 func (i *interface_) Links() []Link {
 	result := make([]Link, len(i.links))
@@ -111,11 +177,58 @@ func (i *interface_) EffectiveMTU() int {
 	return i.effectiveMTU
 }
 
+// Params implements Interface.
+func (i *interface_) Params() InterfaceParams {
+	return i.params
+}
+
+// LinkConnected implements Interface.
+func (i *interface_) LinkConnected() bool {
+	return i.linkConnected
+}
+
+// InterfaceSpeed implements Interface.
+func (i *interface_) InterfaceSpeed() int {
+	return i.interfaceSpeed
+}
+
+// LinkSpeed implements Interface.
+func (i *interface_) LinkSpeed() int {
+	return i.linkSpeed
+}
+
+// SRIOVMaxVF implements Interface.
+func (i *interface_) SRIOVMaxVF() int {
+	return i.sriovMaxVF
+}
+
+// Vendor implements Interface.
+func (i *interface_) Vendor() string {
+	return i.vendor
+}
+
+// Product implements Interface.
+func (i *interface_) Product() string {
+	return i.product
+}
+
+// FirmwareVersion implements Interface.
+func (i *interface_) FirmwareVersion() string {
+	return i.firmwareVersion
+}
+
 // UpdateInterfaceArgs is an argument struct for calling Interface.Update.
 type UpdateInterfaceArgs struct {
 	Name       string
 	MACAddress string
 	VLAN       VLAN
+	// Tags replaces the interface's tags if not nil. An empty, non-nil
+	// slice removes all the tags.
+	Tags []string
+	// AcceptRA and Autoconf change whether the interface accepts IPv6
+	// router advertisements, and configures itself with SLAAC, if not nil.
+	AcceptRA *bool
+	Autoconf *bool
 }
 
 func (a *UpdateInterfaceArgs) vlanID() int {
@@ -127,31 +240,35 @@ func (a *UpdateInterfaceArgs) vlanID() int {
 
 // Update implements Interface.
 func (i *interface_) Update(args UpdateInterfaceArgs) error {
-	var empty UpdateInterfaceArgs
-	if args == empty {
+	if args.Name == "" && args.MACAddress == "" && args.VLAN == nil && args.Tags == nil &&
+		args.AcceptRA == nil && args.Autoconf == nil {
 		return nil
 	}
 	params := NewURLParams()
 	params.MaybeAdd("name", args.Name)
 	params.MaybeAdd("mac_address", args.MACAddress)
 	params.MaybeAddInt("vlan", args.vlanID())
+	if args.Tags != nil {
+		params.Values.Add("tags", strings.Join(args.Tags, ","))
+	}
+	if args.AcceptRA != nil {
+		params.Values.Add("accept_ra", fmt.Sprint(*args.AcceptRA))
+	}
+	if args.Autoconf != nil {
+		params.Values.Add("autoconf", fmt.Sprint(*args.Autoconf))
+	}
 	source, err := i.controller.put(i.resourceURI, params.Values)
 	if err != nil {
-		if svrErr, ok := errors.Cause(err).(ServerError); ok {
-			switch svrErr.StatusCode {
-			case http.StatusNotFound:
-				return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
-			case http.StatusForbidden:
-				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
-			}
-		}
-		return NewUnexpectedError(err)
+		return translateError(opEntity, err)
 	}
 
-	response, err := readInterface(i.controller.apiVersion, source)
+	response, err := readInterface(i.controller.schemaVersion(), source)
 	if err != nil {
 		return errors.Trace(err)
 	}
+	if err = i.controller.checkDecoded("interface", source, response); err != nil {
+		return errors.Trace(err)
+	}
 	i.updateFrom(response)
 	return nil
 }
@@ -160,24 +277,20 @@ func (i *interface_) Update(args UpdateInterfaceArgs) error {
 func (i *interface_) Delete() error {
 	err := i.controller.delete(i.resourceURI)
 	if err != nil {
-		if svrErr, ok := errors.Cause(err).(ServerError); ok {
-			switch svrErr.StatusCode {
-			case http.StatusNotFound:
-				return errors.Wrap(err, NewNoMatchError(svrErr.BodyMessage))
-			case http.StatusForbidden:
-				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
-			}
-		}
-		return NewUnexpectedError(err)
+		return translateError(opEntity, err)
 	}
 	return nil
 }
 
 // InterfaceLinkMode is the type of the various link mode constants used for
-// LinkSubnetArgs.
+// LinkSubnetArgs, and returned by Link.LinkMode.
 type InterfaceLinkMode string
 
 const (
+	// LinkModeAuto - Assign a static IP address from the subnet to the
+	// interface when the machine is deployed.
+	LinkModeAuto InterfaceLinkMode = "AUTO"
+
 	// LinkModeDHCP - Bring the interface up with DHCP on the given subnet. Only
 	// one subnet can be set to DHCP. If the subnet is managed this interface
 	// will pull from the dynamic IP range.
@@ -193,6 +306,12 @@ const (
 	LinkModeLinkUp InterfaceLinkMode = "LINK_UP"
 )
 
+// normalizeLinkMode returns the link mode for the mode as MAAS reports it,
+// such as "link_up", which is in lower case.
+func normalizeLinkMode(mode string) InterfaceLinkMode {
+	return InterfaceLinkMode(strings.ToUpper(strings.TrimSpace(mode)))
+}
+
 // LinkSubnetArgs is an argument struct for passing parameters to
 // the Interface.LinkSubnet method.
 type LinkSubnetArgs struct {
@@ -203,11 +322,12 @@ type LinkSubnetArgs struct {
 	Subnet Subnet
 	// IPAddress is only valid when the Mode is set to LinkModeStatic. If
 	// not specified with a Mode of LinkModeStatic, an IP address from the
-	// subnet will be auto selected.
+	// subnet will be auto selected. It must be of the same address family
+	// as the CIDR of the Subnet.
 	IPAddress string
 	// DefaultGateway will set the gateway IP address for the Subnet as the
 	// default gateway for the machine or device the interface belongs to.
-	// Option can only be used with mode LinkModeStatic.
+	// Option can only be used with modes LinkModeAuto and LinkModeStatic.
 	DefaultGateway bool
 }
 
@@ -215,7 +335,7 @@ type LinkSubnetArgs struct {
 // are consistent with the Mode.
 func (a *LinkSubnetArgs) Validate() error {
 	switch a.Mode {
-	case LinkModeDHCP, LinkModeLinkUp, LinkModeStatic:
+	case LinkModeAuto, LinkModeDHCP, LinkModeLinkUp, LinkModeStatic:
 	case "":
 		return errors.NotValidf("missing Mode")
 	default:
@@ -227,12 +347,35 @@ func (a *LinkSubnetArgs) Validate() error {
 	if a.IPAddress != "" && a.Mode != LinkModeStatic {
 		return errors.NotValidf("setting IP Address when Mode is not LinkModeStatic")
 	}
-	if a.DefaultGateway && a.Mode != LinkModeStatic {
+	if a.IPAddress != "" {
+		if err := checkAddressFamily(a.IPAddress, a.Subnet); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if a.DefaultGateway && a.Mode != LinkModeStatic && a.Mode != LinkModeAuto {
 		return errors.NotValidf("specifying DefaultGateway for Mode %q", a.Mode)
 	}
 	return nil
 }
 
+// checkAddressFamily returns a NotValid error if the address isn't an IP
+// address, or is IPv4 for an IPv6 subnet or the other way around. Subnets
+// with a CIDR that can't be parsed aren't checked, and are left to MAAS.
+func checkAddressFamily(address string, subnet Subnet) error {
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return errors.NotValidf("IP Address %q", address)
+	}
+	prefix, err := netip.ParsePrefix(subnet.CIDR())
+	if err != nil {
+		return nil
+	}
+	if addr.Unmap().Is4() != prefix.Addr().Is4() {
+		return errors.NotValidf("IP Address %q for subnet %q", address, subnet.CIDR())
+	}
+	return nil
+}
+
 // LinkSubnet implements Interface.
 func (i *interface_) LinkSubnet(args LinkSubnetArgs) error {
 	if err := args.Validate(); err != nil {
@@ -245,23 +388,16 @@ func (i *interface_) LinkSubnet(args LinkSubnetArgs) error {
 	params.MaybeAddBool("default_gateway", args.DefaultGateway)
 	source, err := i.controller.post(i.resourceURI, "link_subnet", params.Values)
 	if err != nil {
-		if svrErr, ok := errors.Cause(err).(ServerError); ok {
-			switch svrErr.StatusCode {
-			case http.StatusNotFound, http.StatusBadRequest:
-				return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
-			case http.StatusForbidden:
-				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
-			case http.StatusServiceUnavailable:
-				return errors.Wrap(err, NewCannotCompleteError(svrErr.BodyMessage))
-			}
-		}
-		return NewUnexpectedError(err)
+		return translateError(opReference, err)
 	}
 
-	response, err := readInterface(i.controller.apiVersion, source)
+	response, err := readInterface(i.controller.schemaVersion(), source)
 	if err != nil {
 		return errors.Trace(err)
 	}
+	if err = i.controller.checkDecoded("interface", source, response); err != nil {
+		return errors.Trace(err)
+	}
 	i.updateFrom(response)
 	return nil
 }
@@ -284,30 +420,157 @@ func (i *interface_) UnlinkSubnet(subnet Subnet) error {
 	if link == nil {
 		return errors.NotValidf("unlinked Subnet")
 	}
+	return errors.Trace(i.unlink(link))
+}
+
+func (i *interface_) unlink(link *link) error {
 	params := NewURLParams()
 	params.Values.Add("id", fmt.Sprint(link.ID()))
 	source, err := i.controller.post(i.resourceURI, "unlink_subnet", params.Values)
 	if err != nil {
-		if svrErr, ok := errors.Cause(err).(ServerError); ok {
-			switch svrErr.StatusCode {
-			case http.StatusNotFound, http.StatusBadRequest:
-				return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
-			case http.StatusForbidden:
-				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
-			}
-		}
-		return NewUnexpectedError(err)
+		return translateError(opReference, err)
 	}
 
-	response, err := readInterface(i.controller.apiVersion, source)
+	response, err := readInterface(i.controller.schemaVersion(), source)
 	if err != nil {
 		return errors.Trace(err)
 	}
+	if err = i.controller.checkDecoded("interface", source, response); err != nil {
+		return errors.Trace(err)
+	}
 	i.updateFrom(response)
 
 	return nil
 }
 
+// UpdateLinkArgs is an argument struct for passing parameters to
+// the Interface.UpdateLink method.
+type UpdateLinkArgs struct {
+	// Mode is the new mode of the link. Required field.
+	Mode InterfaceLinkMode
+	// IPAddress is only valid when the Mode is set to LinkModeStatic. If
+	// not specified, the link keeps the address it has, so an auto link
+	// that has been given an address keeps it as a static one.
+	IPAddress string
+	// DefaultGateway is as for LinkSubnetArgs.
+	DefaultGateway bool
+}
+
+// UpdateLink implements Interface.
+//
+// MAAS has no operation to change a link, so the subnet is unlinked and
+// linked again with the new mode. If linking fails, the link is restored
+// with its old mode, and its old address if it was static.
+func (i *interface_) UpdateLink(linkID int, args UpdateLinkArgs) error {
+	var current *link
+	for _, l := range i.links {
+		if l.id == linkID {
+			current = l
+			break
+		}
+	}
+	if current == nil {
+		return errors.NotValidf("unknown link %d", linkID)
+	}
+	if current.subnet == nil {
+		return errors.NotValidf("link %d without subnet", linkID)
+	}
+	ipAddress := args.IPAddress
+	if ipAddress == "" && args.Mode == LinkModeStatic {
+		ipAddress = current.ipAddress
+	}
+	linkArgs := LinkSubnetArgs{
+		Mode:           args.Mode,
+		Subnet:         current.subnet,
+		IPAddress:      ipAddress,
+		DefaultGateway: args.DefaultGateway,
+	}
+	if err := linkArgs.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	if current.linkMode == args.Mode && current.ipAddress == ipAddress && !args.DefaultGateway {
+		return nil
+	}
+
+	if err := i.unlink(current); err != nil {
+		return errors.Trace(err)
+	}
+	if err := i.LinkSubnet(linkArgs); err != nil {
+		restore := LinkSubnetArgs{
+			Mode:   current.linkMode,
+			Subnet: current.subnet,
+		}
+		if restore.Mode == LinkModeStatic {
+			restore.IPAddress = current.ipAddress
+		}
+		if innerErr := i.LinkSubnet(restore); innerErr != nil {
+			i.controller.logger.Warnf("could not restore link %d of interface %q", linkID, i.name)
+		}
+		return errors.Trace(err)
+	}
+	return nil
+}
+
+// EnsureStaticIP implements Interface.
+//
+// Returns
+//  - NotValid error if the subnet is nil
+//  - BadRequestError if MAAS can't link the subnet
+//  - CannotCompleteError if MAAS doesn't assign an address
+func (i *interface_) EnsureStaticIP(subnet Subnet) (string, error) {
+	if subnet == nil {
+		return "", errors.NotValidf("missing Subnet")
+	}
+	if current := i.linkForSubnet(subnet); current == nil {
+		err := i.LinkSubnet(LinkSubnetArgs{Mode: LinkModeStatic, Subnet: subnet})
+		if err != nil {
+			return "", errors.Trace(err)
+		}
+	} else if !current.IsStatic() || current.ipAddress == "" {
+		// An auto link that has been given an address keeps it.
+		if err := i.UpdateLink(current.id, UpdateLinkArgs{Mode: LinkModeStatic}); err != nil {
+			return "", errors.Trace(err)
+		}
+	}
+	current := i.linkForSubnet(subnet)
+	if current == nil || current.ipAddress == "" {
+		return "", NewCannotCompleteError(fmt.Sprintf("no address assigned on subnet %q", subnet.CIDR()))
+	}
+	return current.ipAddress, nil
+}
+
+// AddTag implements Interface.
+func (i *interface_) AddTag(tag string) error {
+	return errors.Trace(i.tagOp("add_tag", tag))
+}
+
+// RemoveTag implements Interface.
+func (i *interface_) RemoveTag(tag string) error {
+	return errors.Trace(i.tagOp("remove_tag", tag))
+}
+
+func (i *interface_) tagOp(op, tag string) error {
+	if tag == "" {
+		return errors.NotValidf("missing tag")
+	}
+	params := NewURLParams()
+	params.Values.Add("tag", tag)
+	source, err := i.controller.post(i.resourceURI, op, params.Values)
+	if err != nil {
+		return translateError(opEntity, err)
+	}
+
+	response, err := readInterface(i.controller.schemaVersion(), source)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err = i.controller.checkDecoded("interface", source, response); err != nil {
+		return errors.Trace(err)
+	}
+	i.updateFrom(response)
+	return nil
+}
+
 func readInterface(controllerVersion version.Number, source interface{}) (*interface_, error) {
 	readFunc, err := getInterfaceDeserializationFunc(controllerVersion)
 	if err != nil {
@@ -360,7 +623,7 @@ func readInterfaceList(sourceList []interface{}, readFunc interfaceDeserializati
 		}
 		read, err := readFunc(source)
 		if err != nil {
-			return nil, errors.Annotatef(err, "interface %d", i)
+			return nil, annotateListItem(err, "interface", i, source)
 		}
 		result = append(result, read)
 	}
@@ -373,30 +636,54 @@ var interfaceDeserializationFuncs = map[version.Number]interfaceDeserializationF
 	twoDotOh: interface_2_0,
 }
 
-func interface_2_0(source map[string]interface{}) (*interface_, error) {
-	fields := schema.Fields{
-		"resource_uri": schema.String(),
+var interface_2_0Checker = fieldMap("interface", schema.Fields{
+	"resource_uri": schema.String(),
 
-		"id":      schema.ForceInt(),
-		"name":    schema.String(),
-		"type":    schema.String(),
-		"enabled": schema.Bool(),
-		"tags":    schema.OneOf(schema.Nil(""), schema.List(schema.String())),
+	"id":      schema.ForceInt(),
+	"name":    schema.String(),
+	"type":    schema.String(),
+	"enabled": schema.Bool(),
+	"tags":    schema.OneOf(schema.Nil(""), schema.List(schema.String())),
 
-		"vlan":  schema.OneOf(schema.Nil(""), schema.StringMap(schema.Any())),
-		"links": schema.List(schema.StringMap(schema.Any())),
+	"vlan":  schema.OneOf(schema.Nil(""), schema.StringMap(schema.Any())),
+	"links": schema.List(schema.StringMap(schema.Any())),
 
-		"mac_address":   schema.OneOf(schema.Nil(""), schema.String()),
-		"effective_mtu": schema.ForceInt(),
+	"mac_address":   schema.OneOf(schema.Nil(""), schema.String()),
+	"effective_mtu": schema.ForceInt(),
 
-		"parents":  schema.List(schema.String()),
-		"children": schema.List(schema.String()),
-	}
-	defaults := schema.Defaults{
-		"mac_address": "",
-	}
-	checker := schema.FieldMap(fields, defaults)
-	coerced, err := checker.Coerce(source, nil)
+	// Only reported by MAAS 2.5 and later.
+	"link_connected":  schema.Bool(),
+	"interface_speed": schema.ForceInt(),
+	"link_speed":      schema.ForceInt(),
+
+	// Only reported for physical interfaces, by MAAS 2.7 and later.
+	"sriov_max_vf":     schema.ForceInt(),
+	"vendor":           schema.OneOf(schema.Nil(""), schema.String()),
+	"product":          schema.OneOf(schema.Nil(""), schema.String()),
+	"firmware_version": schema.OneOf(schema.Nil(""), schema.String()),
+
+	"parents":  schema.OneOf(schema.Nil(""), schema.List(schema.String())),
+	"children": schema.OneOf(schema.Nil(""), schema.List(schema.String())),
+
+	// An empty string when the interface has no params.
+	"params": schema.OneOf(schema.Nil(""), schema.String(), schema.StringMap(schema.Any())),
+}, schema.Defaults{
+	"mac_address": "",
+	"params":      schema.Omit,
+
+	// Older controllers don't check the link, so assume it's up.
+	"link_connected":  true,
+	"interface_speed": 0,
+	"link_speed":      0,
+
+	"sriov_max_vf":     0,
+	"vendor":           "",
+	"product":          "",
+	"firmware_version": "",
+})
+
+func interface_2_0(source map[string]interface{}) (*interface_, error) {
+	coerced, err := interface_2_0Checker.Coerce(source, nil)
 	if err != nil {
 		return nil, WrapWithDeserializationError(err, "interface 2.0 schema check failed")
 	}
@@ -417,7 +704,19 @@ func interface_2_0(source map[string]interface{}) (*interface_, error) {
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	var params InterfaceParams
+	// A string is either empty or not one of the documented params, so
+	// only objects are read.
+	if paramsMap, ok := valid["params"].(map[string]interface{}); ok {
+		params, err = interfaceParams_2_0(paramsMap)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
 	macAddress, _ := valid["mac_address"].(string)
+	vendor, _ := valid["vendor"].(string)
+	product, _ := valid["product"].(string)
+	firmwareVersion, _ := valid["firmware_version"].(string)
 	result := &interface_{
 		resourceURI: valid["resource_uri"].(string),
 
@@ -433,8 +732,78 @@ func interface_2_0(source map[string]interface{}) (*interface_, error) {
 		macAddress:   macAddress,
 		effectiveMTU: valid["effective_mtu"].(int),
 
+		linkConnected:  valid["link_connected"].(bool),
+		interfaceSpeed: valid["interface_speed"].(int),
+		linkSpeed:      valid["link_speed"].(int),
+
+		sriovMaxVF:      valid["sriov_max_vf"].(int),
+		vendor:          vendor,
+		product:         product,
+		firmwareVersion: firmwareVersion,
+
 		parents:  convertToStringSlice(valid["parents"]),
 		children: convertToStringSlice(valid["children"]),
+
+		params: params,
+	}
+	return result, nil
+}
+
+func interfaceParams_2_0(source map[string]interface{}) (InterfaceParams, error) {
+	fields := schema.Fields{
+		"mtu": schema.ForceInt(),
+
+		"bond_mode":             schema.String(),
+		"bond_miimon":           schema.ForceInt(),
+		"bond_downdelay":        schema.ForceInt(),
+		"bond_updelay":          schema.ForceInt(),
+		"bond_lacp_rate":        schema.String(),
+		"bond_xmit_hash_policy": schema.String(),
+
+		"bridge_type": schema.String(),
+		"bridge_stp":  schema.Bool(),
+		"bridge_fd":   schema.ForceInt(),
+	}
+	defaults := schema.Defaults{}
+	for name := range fields {
+		defaults[name] = schema.Omit
+	}
+	// The params depend on the type of interface, and MAAS adds more over
+	// time, so they aren't decoded strictly. Raw keeps them all.
+	checker := schema.FieldMap(fields, defaults)
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return InterfaceParams{}, WrapWithDeserializationError(err, "interface params 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type, if they are there at all.
+
+	mtu, _ := valid["mtu"].(int)
+	bondMode, _ := valid["bond_mode"].(string)
+	bondMiimon, _ := valid["bond_miimon"].(int)
+	bondDownDelay, _ := valid["bond_downdelay"].(int)
+	bondUpDelay, _ := valid["bond_updelay"].(int)
+	bondLACPRate, _ := valid["bond_lacp_rate"].(string)
+	bondXmitHashPolicy, _ := valid["bond_xmit_hash_policy"].(string)
+	bridgeType, _ := valid["bridge_type"].(string)
+	bridgeSTP, _ := valid["bridge_stp"].(bool)
+	bridgeFD, _ := valid["bridge_fd"].(int)
+	result := InterfaceParams{
+		MTU: mtu,
+
+		BondMode:           bondMode,
+		BondMiimon:         bondMiimon,
+		BondDownDelay:      bondDownDelay,
+		BondUpDelay:        bondUpDelay,
+		BondLACPRate:       bondLACPRate,
+		BondXmitHashPolicy: bondXmitHashPolicy,
+
+		BridgeType: bridgeType,
+		BridgeSTP:  bridgeSTP,
+		BridgeFD:   bridgeFD,
+
+		Raw: source,
 	}
 	return result, nil
 }
@@ -4,7 +4,9 @@
 package gomaasapi
 
 import (
+	"encoding/json"
 	"net/http"
+	"net/url"
 
 	"github.com/juju/errors"
 	"github.com/juju/testing"
@@ -47,6 +49,26 @@ func (*interfaceSuite) TestReadInterfacesNulls(c *gc.C) {
 	c.Check(iface.VLAN(), gc.IsNil)
 }
 
+func (*interfaceSuite) TestReadInterfacesNullTags(c *gc.C) {
+	iface, err := readInterface(twoDotOh, parseJSON(c, interfaceNullsResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(iface.Tags(), gc.NotNil)
+	c.Check(iface.Tags(), gc.HasLen, 0)
+}
+
+func (*interfaceSuite) TestReadInterfacesNullParents(c *gc.C) {
+	json := parseJSON(c, interfaceNullsResponse)
+	data := json.(map[string]interface{})
+	data["parents"] = nil
+	data["children"] = nil
+	iface, err := readInterface(twoDotOh, json)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(iface.Parents(), gc.NotNil)
+	c.Check(iface.Parents(), gc.HasLen, 0)
+	c.Check(iface.Children(), gc.NotNil)
+	c.Check(iface.Children(), gc.HasLen, 0)
+}
+
 func (s *interfaceSuite) checkInterface(c *gc.C, iface *interface_) {
 	c.Check(iface.ID(), gc.Equals, 40)
 	c.Check(iface.Name(), gc.Equals, "eth0")
@@ -90,6 +112,96 @@ func (s *interfaceSuite) TestReadInterfaceNilMAC(c *gc.C) {
 	c.Assert(result.MACAddress(), gc.Equals, "")
 }
 
+func (s *interfaceSuite) TestReadInterfaceLinkState(c *gc.C) {
+	json := parseJSON(c, interfaceResponse)
+	source := json.(map[string]interface{})
+	c.Assert(readInterfaceOrFail(c, source).LinkConnected(), jc.IsTrue)
+
+	source["link_connected"] = false
+	source["interface_speed"] = 10000
+	source["link_speed"] = 1000
+	result := readInterfaceOrFail(c, source)
+	c.Check(result.LinkConnected(), jc.IsFalse)
+	c.Check(result.InterfaceSpeed(), gc.Equals, 10000)
+	c.Check(result.LinkSpeed(), gc.Equals, 1000)
+}
+
+func (s *interfaceSuite) TestReadInterfaceSRIOV(c *gc.C) {
+	json := parseJSON(c, interfaceResponse)
+	source := json.(map[string]interface{})
+	result := readInterfaceOrFail(c, source)
+	c.Check(result.SRIOVMaxVF(), gc.Equals, 0)
+	c.Check(result.Vendor(), gc.Equals, "")
+
+	source["sriov_max_vf"] = 63
+	source["vendor"] = "Intel Corporation"
+	source["product"] = "Ethernet Controller XL710 for 40GbE QSFP+"
+	source["firmware_version"] = "8.30 0x8000a4ad 1.2926.0"
+	result = readInterfaceOrFail(c, source)
+	c.Check(result.SRIOVMaxVF(), gc.Equals, 63)
+	c.Check(result.Vendor(), gc.Equals, "Intel Corporation")
+	c.Check(result.Product(), gc.Equals, "Ethernet Controller XL710 for 40GbE QSFP+")
+	c.Check(result.FirmwareVersion(), gc.Equals, "8.30 0x8000a4ad 1.2926.0")
+
+	source["vendor"] = nil
+	c.Check(readInterfaceOrFail(c, source).Vendor(), gc.Equals, "")
+}
+
+func (s *interfaceSuite) TestReadInterfaceParams(c *gc.C) {
+	json := parseJSON(c, interfaceResponse)
+	source := json.(map[string]interface{})
+	// String params, as most interfaces have, aren't decoded.
+	c.Assert(readInterfaceOrFail(c, source).Params(), jc.DeepEquals, InterfaceParams{})
+	delete(source, "params")
+	c.Assert(readInterfaceOrFail(c, source).Params(), jc.DeepEquals, InterfaceParams{})
+
+	raw := map[string]interface{}{
+		"mtu":                   9000,
+		"bond_mode":             "802.3ad",
+		"bond_miimon":           100,
+		"bond_downdelay":        0,
+		"bond_updelay":          "200",
+		"bond_lacp_rate":        "fast",
+		"bond_xmit_hash_policy": "layer3+4",
+		"accept-ra":             true,
+	}
+	source["params"] = raw
+	c.Check(readInterfaceOrFail(c, source).Params(), jc.DeepEquals, InterfaceParams{
+		MTU:                9000,
+		BondMode:           "802.3ad",
+		BondMiimon:         100,
+		BondUpDelay:        200,
+		BondLACPRate:       "fast",
+		BondXmitHashPolicy: "layer3+4",
+		Raw:                raw,
+	})
+
+	source["params"] = map[string]interface{}{
+		"bridge_type": "ovs",
+		"bridge_stp":  true,
+		"bridge_fd":   15,
+	}
+	params := readInterfaceOrFail(c, source).Params()
+	c.Check(params.BridgeType, gc.Equals, "ovs")
+	c.Check(params.BridgeSTP, jc.IsTrue)
+	c.Check(params.BridgeFD, gc.Equals, 15)
+	c.Check(params.MTU, gc.Equals, 0)
+}
+
+func (*interfaceSuite) TestReadInterfaceBadParams(c *gc.C) {
+	json := parseJSON(c, interfaceResponse)
+	json.(map[string]interface{})["params"] = map[string]interface{}{"bridge_stp": "maybe"}
+	_, err := readInterface(twoDotOh, json)
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Check(err, gc.ErrorMatches, `interface params 2.0 schema check failed: .*`)
+}
+
+func readInterfaceOrFail(c *gc.C, source interface{}) *interface_ {
+	result, err := readInterface(twoDotOh, source)
+	c.Assert(err, jc.ErrorIsNil)
+	return result
+}
+
 func (*interfaceSuite) TestLowVersion(c *gc.C) {
 	_, err := readInterfaces(version.MustParse("1.9.0"), parseJSON(c, interfacesResponse))
 	c.Assert(err, jc.Satisfies, IsUnsupportedVersionError)
@@ -143,11 +255,11 @@ func (s *interfaceSuite) TestDeleteForbidden(c *gc.C) {
 	c.Assert(err, jc.Satisfies, IsPermissionError)
 }
 
-func (s *interfaceSuite) TestDeleteUnknown(c *gc.C) {
+func (s *interfaceSuite) TestDeleteConflict(c *gc.C) {
 	server, iface := s.getServerAndNewInterface(c)
 	server.AddDeleteResponse(iface.resourceURI, http.StatusConflict, "")
 	err := iface.Delete()
-	c.Assert(err, jc.Satisfies, IsUnexpectedError)
+	c.Assert(err, jc.Satisfies, IsCannotCompleteError)
 }
 
 type fakeSubnet struct {
@@ -187,6 +299,11 @@ func (s *interfaceSuite) TestLinkSubnetArgs(c *gc.C) {
 		args: LinkSubnetArgs{Mode: LinkModeStatic, Subnet: &fakeSubnet{}},
 	}, {
 		args: LinkSubnetArgs{Mode: LinkModeLinkUp, Subnet: &fakeSubnet{}},
+	}, {
+		args: LinkSubnetArgs{Mode: LinkModeAuto, Subnet: &fakeSubnet{}},
+	}, {
+		args:    LinkSubnetArgs{Mode: LinkModeAuto, Subnet: &fakeSubnet{}, IPAddress: "10.10.10.10"},
+		errText: `setting IP Address when Mode is not LinkModeStatic not valid`,
 	}, {
 		args:    LinkSubnetArgs{Mode: LinkModeDHCP, Subnet: &fakeSubnet{}, IPAddress: "10.10.10.10"},
 		errText: `setting IP Address when Mode is not LinkModeStatic not valid`,
@@ -200,9 +317,22 @@ func (s *interfaceSuite) TestLinkSubnetArgs(c *gc.C) {
 		errText: `specifying DefaultGateway for Mode "DHCP" not valid`,
 	}, {
 		args: LinkSubnetArgs{Mode: LinkModeStatic, Subnet: &fakeSubnet{}, DefaultGateway: true},
+	}, {
+		args: LinkSubnetArgs{Mode: LinkModeAuto, Subnet: &fakeSubnet{}, DefaultGateway: true},
 	}, {
 		args:    LinkSubnetArgs{Mode: LinkModeLinkUp, Subnet: &fakeSubnet{}, DefaultGateway: true},
 		errText: `specifying DefaultGateway for Mode "LINK_UP" not valid`,
+	}, {
+		args:    LinkSubnetArgs{Mode: LinkModeStatic, Subnet: &fakeSubnet{}, IPAddress: "10.10.10"},
+		errText: `IP Address "10.10.10" not valid`,
+	}, {
+		args: LinkSubnetArgs{Mode: LinkModeStatic, Subnet: &fakeSubnet{cidr: "2001:db8::/64"}, IPAddress: "2001:db8::10"},
+	}, {
+		args:    LinkSubnetArgs{Mode: LinkModeStatic, Subnet: &fakeSubnet{cidr: "2001:db8::/64"}, IPAddress: "10.10.10.10"},
+		errText: `IP Address "10.10.10.10" for subnet "2001:db8::/64" not valid`,
+	}, {
+		args:    LinkSubnetArgs{Mode: LinkModeStatic, Subnet: &fakeSubnet{cidr: "10.10.10.0/24"}, IPAddress: "2001:db8::10"},
+		errText: `IP Address "2001:db8::10" for subnet "10.10.10.0/24" not valid`,
 	}} {
 		c.Logf("test %d", i)
 		err := test.args.Validate()
@@ -291,7 +421,7 @@ func (s *interfaceSuite) TestLinkSubnetUnknown(c *gc.C) {
 	}
 	err := iface.LinkSubnet(args)
 	c.Check(err, jc.Satisfies, IsUnexpectedError)
-	c.Assert(err.Error(), gc.Equals, "unexpected: ServerError: 405 Method Not Allowed (wat?)")
+	c.Assert(err, gc.ErrorMatches, `unexpected: request [0-9a-f]+-[0-9a-f]+: ServerError: 405 Method Not Allowed \(wat\?\)`)
 }
 
 func (s *interfaceSuite) TestUnlinkSubnetValidates(c *gc.C) {
@@ -345,7 +475,135 @@ func (s *interfaceSuite) TestUnlinkSubnetUnknown(c *gc.C) {
 	server.AddPostResponse(iface.resourceURI+"?op=unlink_subnet", http.StatusMethodNotAllowed, "wat?")
 	err := iface.UnlinkSubnet(&fakeSubnet{id: 1})
 	c.Check(err, jc.Satisfies, IsUnexpectedError)
-	c.Assert(err.Error(), gc.Equals, "unexpected: ServerError: 405 Method Not Allowed (wat?)")
+	c.Assert(err, gc.ErrorMatches, `unexpected: request [0-9a-f]+-[0-9a-f]+: ServerError: 405 Method Not Allowed \(wat\?\)`)
+}
+
+// staticLinkResponse is interfaceResponse with link 69 made static with
+// the address.
+func staticLinkResponse(c *gc.C, ipAddress string) string {
+	return linkResponse(c, "static", ipAddress, 1)
+}
+
+// linkResponse returns interfaceResponse with its link in the mode, with
+// the address, on the subnet with the ID.
+func linkResponse(c *gc.C, mode, ipAddress string, subnetID int) string {
+	source := parseJSON(c, interfaceResponse).(map[string]interface{})
+	link := source["links"].([]interface{})[0].(map[string]interface{})
+	link["mode"] = mode
+	link["ip_address"] = ipAddress
+	link["subnet"].(map[string]interface{})["id"] = subnetID
+	bytes, err := json.Marshal(source)
+	c.Assert(err, jc.ErrorIsNil)
+	return string(bytes)
+}
+
+func (s *interfaceSuite) TestUpdateLinkUnknown(c *gc.C) {
+	_, iface := s.getServerAndNewInterface(c)
+	err := iface.UpdateLink(42, UpdateLinkArgs{Mode: LinkModeStatic})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(err.Error(), gc.Equals, "unknown link 42 not valid")
+}
+
+func (s *interfaceSuite) TestUpdateLinkValidates(c *gc.C) {
+	_, iface := s.getServerAndNewInterface(c)
+	err := iface.UpdateLink(69, UpdateLinkArgs{Mode: LinkModeDHCP, IPAddress: "192.168.100.4"})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *interfaceSuite) TestUpdateLinkNoChangeNoRequest(c *gc.C) {
+	server, iface := s.getServerAndNewInterface(c)
+	count := server.RequestCount()
+	err := iface.UpdateLink(69, UpdateLinkArgs{Mode: LinkModeAuto})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(server.RequestCount(), gc.Equals, count)
+}
+
+func (s *interfaceSuite) TestUpdateLinkAutoToStatic(c *gc.C) {
+	server, iface := s.getServerAndNewInterface(c)
+	server.AddPostResponse(iface.resourceURI+"?op=unlink_subnet", http.StatusOK, interfaceResponse)
+	server.AddPostResponse(iface.resourceURI+"?op=link_subnet", http.StatusOK, staticLinkResponse(c, "192.168.100.4"))
+	err := iface.UpdateLink(69, UpdateLinkArgs{Mode: LinkModeStatic, IPAddress: "192.168.100.4"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(iface.Links()[0].Mode(), gc.Equals, "static")
+	c.Check(iface.Links()[0].IPAddress(), gc.Equals, "192.168.100.4")
+
+	requests := server.LastNRequests(2)
+	c.Check(requests[0].PostForm.Get("id"), gc.Equals, "69")
+	form := requests[1].PostForm
+	c.Check(form.Get("mode"), gc.Equals, "STATIC")
+	c.Check(form.Get("subnet"), gc.Equals, "1")
+	c.Check(form.Get("ip_address"), gc.Equals, "192.168.100.4")
+}
+
+func (s *interfaceSuite) TestUpdateLinkKeepsAddress(c *gc.C) {
+	server, iface := s.getServerAndNewInterface(c)
+	iface.links[0].ipAddress = "192.168.100.7"
+	server.AddPostResponse(iface.resourceURI+"?op=unlink_subnet", http.StatusOK, interfaceResponse)
+	server.AddPostResponse(iface.resourceURI+"?op=link_subnet", http.StatusOK, staticLinkResponse(c, "192.168.100.7"))
+	err := iface.UpdateLink(69, UpdateLinkArgs{Mode: LinkModeStatic})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(server.LastRequest().PostForm.Get("ip_address"), gc.Equals, "192.168.100.7")
+}
+
+func (s *interfaceSuite) TestEnsureStaticIP(c *gc.C) {
+	server, iface := s.getServerAndNewInterface(c)
+	server.AddPostResponse(iface.resourceURI+"?op=unlink_subnet", http.StatusOK, interfaceResponse)
+	server.AddPostResponse(iface.resourceURI+"?op=link_subnet", http.StatusOK, staticLinkResponse(c, "192.168.100.9"))
+
+	address, err := iface.EnsureStaticIP(&fakeSubnet{id: 1})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(address, gc.Equals, "192.168.100.9")
+	// MAAS chooses the address.
+	form := server.LastRequest().PostForm
+	c.Check(form.Get("mode"), gc.Equals, "STATIC")
+	_, found := form["ip_address"]
+	c.Check(found, jc.IsFalse)
+}
+
+func (s *interfaceSuite) TestEnsureStaticIPNewLink(c *gc.C) {
+	server, iface := s.getServerAndNewInterface(c)
+	server.AddPostResponse(iface.resourceURI+"?op=link_subnet", http.StatusOK, linkResponse(c, "static", "10.0.0.12", 42))
+
+	address, err := iface.EnsureStaticIP(&fakeSubnet{id: 42})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(address, gc.Equals, "10.0.0.12")
+	c.Check(server.LastRequest().PostForm.Get("subnet"), gc.Equals, "42")
+}
+
+func (s *interfaceSuite) TestEnsureStaticIPAlreadyStatic(c *gc.C) {
+	server, iface := s.getServerAndNewInterface(c)
+	iface.links[0].linkMode = LinkModeStatic
+	iface.links[0].ipAddress = "192.168.100.7"
+	count := server.RequestCount()
+
+	address, err := iface.EnsureStaticIP(&fakeSubnet{id: 1})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(address, gc.Equals, "192.168.100.7")
+	c.Check(server.RequestCount(), gc.Equals, count)
+}
+
+func (s *interfaceSuite) TestEnsureStaticIPNoAddress(c *gc.C) {
+	server, iface := s.getServerAndNewInterface(c)
+	server.AddPostResponse(iface.resourceURI+"?op=link_subnet", http.StatusOK, interfaceResponse)
+
+	_, err := iface.EnsureStaticIP(&fakeSubnet{id: 42, cidr: "10.0.0.0/24"})
+	c.Assert(err, jc.Satisfies, IsCannotCompleteError)
+	c.Assert(err.Error(), gc.Equals, `no address assigned on subnet "10.0.0.0/24"`)
+}
+
+func (s *interfaceSuite) TestUpdateLinkRestoresOnFailure(c *gc.C) {
+	server, iface := s.getServerAndNewInterface(c)
+	server.AddPostResponse(iface.resourceURI+"?op=unlink_subnet", http.StatusOK, interfaceResponse)
+	server.AddPostResponse(iface.resourceURI+"?op=link_subnet", http.StatusBadRequest, "address in use")
+	server.AddPostResponse(iface.resourceURI+"?op=link_subnet", http.StatusOK, interfaceResponse)
+	err := iface.UpdateLink(69, UpdateLinkArgs{Mode: LinkModeStatic, IPAddress: "192.168.100.4"})
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+	c.Check(err.Error(), gc.Equals, "address in use")
+
+	form := server.LastRequest().PostForm
+	c.Check(form.Get("mode"), gc.Equals, "AUTO")
+	c.Check(form.Get("subnet"), gc.Equals, "1")
+	c.Check(form.Get("ip_address"), gc.Equals, "")
 }
 
 func (s *interfaceSuite) TestUpdateNoChangeNoRequest(c *gc.C) {
@@ -375,7 +633,7 @@ func (s *interfaceSuite) TestUpdateUnknown(c *gc.C) {
 	server.AddPutResponse(iface.resourceURI, http.StatusMethodNotAllowed, "wat?")
 	err := iface.Update(UpdateInterfaceArgs{Name: "eth2"})
 	c.Check(err, jc.Satisfies, IsUnexpectedError)
-	c.Assert(err.Error(), gc.Equals, "unexpected: ServerError: 405 Method Not Allowed (wat?)")
+	c.Assert(err, gc.ErrorMatches, `unexpected: request [0-9a-f]+-[0-9a-f]+: ServerError: 405 Method Not Allowed \(wat\?\)`)
 }
 
 func (s *interfaceSuite) TestUpdateGood(c *gc.C) {
@@ -402,6 +660,107 @@ func (s *interfaceSuite) TestUpdateGood(c *gc.C) {
 	c.Assert(form.Get("vlan"), gc.Equals, "13")
 }
 
+func (s *interfaceSuite) TestUpdateIPv6Autoconfiguration(c *gc.C) {
+	server, iface := s.getServerAndNewInterface(c)
+	server.AddPutResponse(iface.resourceURI, http.StatusOK, interfaceResponse)
+	acceptRA, autoconf := true, false
+	err := iface.Update(UpdateInterfaceArgs{AcceptRA: &acceptRA, Autoconf: &autoconf})
+	c.Assert(err, jc.ErrorIsNil)
+
+	form := server.LastRequest().PostForm
+	c.Check(form, jc.DeepEquals, url.Values{
+		"accept_ra": {"true"},
+		"autoconf":  {"false"},
+	})
+}
+
+func (s *interfaceSuite) TestUpdateTags(c *gc.C) {
+	server, iface := s.getServerAndNewInterface(c)
+	response := updateJSONMap(c, interfaceResponse, map[string]interface{}{
+		"tags": []string{"sriov", "storage"},
+	})
+	server.AddPutResponse(iface.resourceURI, http.StatusOK, response)
+	err := iface.Update(UpdateInterfaceArgs{Tags: []string{"sriov", "storage"}})
+	c.Check(err, jc.ErrorIsNil)
+	c.Check(iface.Tags(), jc.DeepEquals, []string{"sriov", "storage"})
+
+	form := server.LastRequest().PostForm
+	c.Assert(form.Get("tags"), gc.Equals, "sriov,storage")
+	_, ok := form["name"]
+	c.Assert(ok, jc.IsFalse)
+}
+
+func (s *interfaceSuite) TestUpdateClearTags(c *gc.C) {
+	server, iface := s.getServerAndNewInterface(c)
+	server.AddPutResponse(iface.resourceURI, http.StatusOK, interfaceResponse)
+	err := iface.Update(UpdateInterfaceArgs{Tags: []string{}})
+	c.Check(err, jc.ErrorIsNil)
+
+	form := server.LastRequest().PostForm
+	tags, ok := form["tags"]
+	c.Assert(ok, jc.IsTrue)
+	c.Assert(tags, jc.DeepEquals, []string{""})
+}
+
+func (s *interfaceSuite) TestAddTagMissing(c *gc.C) {
+	_, iface := s.getServerAndNewInterface(c)
+	err := iface.AddTag("")
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(err, gc.ErrorMatches, "missing tag not valid")
+}
+
+func (s *interfaceSuite) TestAddTagNotFound(c *gc.C) {
+	_, iface := s.getServerAndNewInterface(c)
+	err := iface.AddTag("sriov")
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+}
+
+func (s *interfaceSuite) TestAddTagForbidden(c *gc.C) {
+	server, iface := s.getServerAndNewInterface(c)
+	server.AddPostResponse(iface.resourceURI+"?op=add_tag", http.StatusForbidden, "bad user")
+	err := iface.AddTag("sriov")
+	c.Check(err, jc.Satisfies, IsPermissionError)
+	c.Check(err.Error(), gc.Equals, "bad user")
+}
+
+func (s *interfaceSuite) TestAddTagBadRequest(c *gc.C) {
+	server, iface := s.getServerAndNewInterface(c)
+	server.AddPostResponse(iface.resourceURI+"?op=add_tag", http.StatusBadRequest, "bad tag")
+	err := iface.AddTag("sriov")
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+	c.Check(err.Error(), gc.Equals, "bad tag")
+}
+
+func (s *interfaceSuite) TestAddTag(c *gc.C) {
+	server, iface := s.getServerAndNewInterface(c)
+	response := updateJSONMap(c, interfaceResponse, map[string]interface{}{
+		"tags": []string{"sriov"},
+	})
+	server.AddPostResponse(iface.resourceURI+"?op=add_tag", http.StatusOK, response)
+	err := iface.AddTag("sriov")
+	c.Check(err, jc.ErrorIsNil)
+	c.Check(iface.Tags(), jc.DeepEquals, []string{"sriov"})
+
+	request := server.LastRequest()
+	c.Assert(request.URL.Query().Get("op"), gc.Equals, "add_tag")
+	c.Assert(request.PostForm.Get("tag"), gc.Equals, "sriov")
+}
+
+func (s *interfaceSuite) TestRemoveTag(c *gc.C) {
+	server, iface := s.getServerAndNewInterface(c)
+	response := updateJSONMap(c, interfaceResponse, map[string]interface{}{
+		"tags": []string{},
+	})
+	server.AddPostResponse(iface.resourceURI+"?op=remove_tag", http.StatusOK, response)
+	err := iface.RemoveTag("sriov")
+	c.Check(err, jc.ErrorIsNil)
+	c.Check(iface.Tags(), gc.HasLen, 0)
+
+	request := server.LastRequest()
+	c.Assert(request.URL.Query().Get("op"), gc.Equals, "remove_tag")
+	c.Assert(request.PostForm.Get("tag"), gc.Equals, "sriov")
+}
+
 const (
 	interfacesResponse = "[" + interfaceResponse + "]"
 	interfaceResponse  = `
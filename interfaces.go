@@ -3,10 +3,21 @@
 
 package gomaasapi
 
-import "github.com/juju/utils/set"
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"net/netip"
+	"net/url"
+	"time"
+
+	"github.com/juju/utils/set"
+)
 
 const (
-	// Capability constants.
+	// Capability constants, for the set returned by
+	// Controller.Capabilities.
 	NetworksManagement      = "networks-management"
 	StaticIPAddresses       = "static-ipaddresses"
 	IPv6DeploymentUbuntu    = "ipv6-deployment-ubuntu"
@@ -15,6 +26,19 @@ const (
 	NetworkDeploymentUbuntu = "network-deployment-ubuntu"
 )
 
+// Capability is a feature that the MAAS controller reports it has, for
+// Controller.HasCapability.
+type Capability string
+
+const (
+	CapNetworksManagement      Capability = NetworksManagement
+	CapStaticIPAddresses       Capability = StaticIPAddresses
+	CapIPv6DeploymentUbuntu    Capability = IPv6DeploymentUbuntu
+	CapDevicesManagement       Capability = DevicesManagement
+	CapStorageDeploymentUbuntu Capability = StorageDeploymentUbuntu
+	CapNetworkDeploymentUbuntu Capability = NetworkDeploymentUbuntu
+)
+
 // Controller represents an API connection to a MAAS Controller. Since the API
 // is restful, there is no long held connection to the API server, but instead
 // HTTP calls are made and JSON response structures parsed.
@@ -24,6 +48,27 @@ type Controller interface {
 	// constants.
 	Capabilities() set.Strings
 
+	// HasCapability reports whether the MAAS controller has the capability,
+	// which is one of the Cap constants.
+	HasCapability(capability Capability) bool
+
+	// ServerVersion returns the release of MAAS that the controller is
+	// running, along with its capabilities.
+	ServerVersion() ServerVersion
+
+	// APIVersion returns the version of the MAAS API in use, such as
+	// "2.0". It is the version in ControllerArgs.BaseURL if there is one,
+	// or the version negotiated with the MAAS controller. Negotiated
+	// versions are remembered for the BaseURL by the process, and tried
+	// first by later controllers.
+	APIVersion() string
+
+	// Ping checks that the MAAS controller can be reached, still serves
+	// the API version in use, and accepts the credentials. It is cheap
+	// enough to back a readiness probe. The error describes the first check
+	// that failed, and is the context's error if it is done first.
+	Ping(ctx context.Context) (HealthStatus, error)
+
 	BootResources() ([]BootResource, error)
 
 	// Fabrics returns the list of Fabrics defined in the MAAS controller.
@@ -32,32 +77,145 @@ type Controller interface {
 	// Spaces returns the list of Spaces defined in the MAAS controller.
 	Spaces() ([]Space, error)
 
+	// SpaceByName returns the space with the name. A NoMatchError is
+	// returned if there is no such space.
+	SpaceByName(name string) (Space, error)
+
+	// SubnetsInSpace returns the subnets in the named space.
+	SubnetsInSpace(space string) ([]Subnet, error)
+
+	// SubnetByCIDR returns the subnet with the given CIDR, for example
+	// "192.168.100.0/24".
+	SubnetByCIDR(cidr string) (Subnet, error)
+
+	// CreateSubnet creates and returns a new Subnet.
+	CreateSubnet(CreateSubnetArgs) (Subnet, error)
+
 	// StaticRoutes returns the list of StaticRoutes defined in the MAAS controller.
 	StaticRoutes() ([]StaticRoute, error)
 
-	// Zones lists all the zones known to the MAAS controller.
+	// Zones lists all the zones known to the MAAS controller, ordered by
+	// name.
 	Zones() ([]Zone, error)
 
-	// Machines returns a list of machines that match the params.
+	// Pools lists all the resource pools known to the MAAS controller.
+	// Resource pools were introduced in MAAS 2.3, and a NotSupported error
+	// is returned for older controllers. They are ordered by name.
+	Pools() ([]Pool, error)
+
+	// Domains lists all the DNS domains known to the MAAS controller,
+	// ordered by name.
+	Domains() ([]Domain, error)
+
+	// DefaultDomain returns the domain given to new machines.
+	DefaultDomain() (Domain, error)
+
+	// SetDefaultDomain makes the domain with the name the one given to new
+	// machines.
+	SetDefaultDomain(name string) error
+
+	// Machines returns a list of machines that match the params, in the
+	// order given by MachinesArgs.SortBy.
 	Machines(MachinesArgs) ([]Machine, error)
 
+	// EachMachine calls f with each machine that matches the params, in the
+	// order MAAS lists them. The listing is decoded one machine at a time,
+	// so memory use doesn't grow with the number of machines. If f returns
+	// an error, the listing is abandoned and that error is returned.
+	EachMachine(args MachinesArgs, f func(Machine) error) error
+
+	// MachinesIfChanged is like Machines, but when the listing is unchanged
+	// since the call that returned the previous token, it returns no
+	// machines and changed is false, without decoding the listing again.
+	// The validators MAAS returns are used, where provided, to avoid
+	// transferring the listing at all. The returned token is passed to the
+	// next call; an empty token always counts as changed.
+	MachinesIfChanged(args MachinesArgs, previousToken string) (machines []Machine, token string, changed bool, err error)
+
+	// DiffMachines compares two listings of the machines, such as those
+	// read by successive polls, and returns the machines added, removed
+	// and changed, with the names of the fields that changed.
+	DiffMachines(previous, current []Machine) MachineDiff
+
+	// QueryMachines returns the machines matching a search such as
+	// "status:Ready zone:az1 tag:gpu mem>=65536", see MachineQuery for the
+	// terms. A NotValid error is returned if the query can't be parsed.
+	QueryMachines(query string) ([]Machine, error)
+
+	// MachinesOwnedByAgent returns the machines allocated with the agent
+	// name, or with ControllerArgs.DefaultAgentName if the agent is empty.
+	MachinesOwnedByAgent(agent string) ([]Machine, error)
+
 	// AllocateMachine will attempt to allocate a machine to the user.
 	// If successful, the allocated machine is returned.
 	AllocateMachine(AllocateMachineArgs) (Machine, ConstraintMatches, error)
 
+	// AcquireSpecificMachine allocates the machine with the system ID,
+	// with the other args as for AllocateMachine, and checks that MAAS
+	// allocated that machine. It tries again, until the context is done,
+	// if the machine is free but MAAS refused it, and returns an
+	// AlreadyAllocatedError if another user has the machine.
+	AcquireSpecificMachine(ctx context.Context, systemID string, args AllocateMachineArgs) (Machine, ConstraintMatches, error)
+
+	// AllocateSpread allocates machines in turn from each of the zones, so
+	// that they are spread across the zones for high availability. Zones
+	// without matching machines are skipped, depending on the fallback. If
+	// not all the machines can be allocated, the machines that were are
+	// released before the error is returned.
+	AllocateSpread(AllocateSpreadArgs) ([]SpreadPlacement, error)
+
+	// AddChassis enlists the machines of a blade chassis or
+	// virtualization host, such as a virsh host, in bulk. MAAS enlists the
+	// machines in the background, and the message it returns is passed on.
+	AddChassis(AddChassisArgs) (string, error)
+
 	// ReleaseMachines will stop the specified machines, and release them
 	// from the user making them available to be allocated again.
 	ReleaseMachines(ReleaseMachinesArgs) error
 
-	// Devices returns a list of devices that match the params.
+	// WaitForRelease reads the machines until none of them is releasing
+	// or erasing its disks. A MultiError is returned with a
+	// CannotCompleteError for each machine that failed to release or
+	// erase, and a NoMatchError for each that can't be found. Once the
+	// context is done, the machines still being released are reported in
+	// the MultiError with the context's error.
+	WaitForRelease(ctx context.Context, systemIDs []string) error
+
+	// Devices returns a list of devices that match the params, in the
+	// order given by DevicesArgs.SortBy.
 	Devices(DevicesArgs) ([]Device, error)
 
-	// CreateDevice creates and returns a new Device.
+	// Nodes returns a list of all the kinds of node that match the params,
+	// machines, devices and controllers alike, in the order given by
+	// NodesArgs.SortBy. Use Machines or Devices for the details of each.
+	Nodes(NodesArgs) ([]GenericNode, error)
+
+	// RackControllers returns the rack controllers of the MAAS, which serve
+	// DHCP on the VLANs they are set as the racks of.
+	RackControllers() ([]RackController, error)
+
+	// BootImagesSyncStatus returns the boot images of each rack controller
+	// and whether they are synced with the region, so that callers can wait
+	// for an import to reach the racks before deploying machines.
+	BootImagesSyncStatus() (BootImagesSyncStatus, error)
+
+	// CreateDevice creates and returns a new Device. The Device is read
+	// from the creation response, so it includes the hostname and FQDN
+	// generated by MAAS when none was given.
 	CreateDevice(CreateDeviceArgs) (Device, error)
 
+	// ReserveStaticHost creates a Device for the MAC address, and links
+	// its interface to the subnet holding the IP address, with that
+	// address. If any step fails, the Device is deleted again.
+	ReserveStaticHost(ReserveStaticHostArgs) (StaticHost, error)
+
 	// Files returns all the files that match the specified prefix.
 	Files(prefix string) ([]File, error)
 
+	// FileInfos returns the metadata of the files that match the prefix,
+	// without their content, see File.Stat.
+	FileInfos(prefix string) ([]FileInfo, error)
+
 	// Return a single file by its filename.
 	GetFile(filename string) (File, error)
 
@@ -66,6 +224,106 @@ type Controller interface {
 	// file without sending the content of the file, we can return a File
 	// instance here too.
 	AddFile(AddFileArgs) error
+
+	// SyncFiles makes the files with the prefix match the regular files of
+	// the local tree, compared by content hash. New and changed files are
+	// uploaded, and files missing from the tree are deleted. The MAAS
+	// filename of a local file is the prefix followed by its path, with
+	// the slashes replaced by dashes. A dry run reports the changes
+	// without making them.
+	SyncFiles(prefix string, local fs.FS, dryRun bool) (FileSync, error)
+
+	// Raw makes a request to an API endpoint that has no other support in
+	// the Controller, using the same authentication and error handling as
+	// the other methods. The path is relative to the versioned API root,
+	// e.g. "resourcepools". It returns the body and status code of the
+	// response.
+	Raw(method, path, op string, params url.Values, body io.Reader) ([]byte, int, error)
+
+	// Bulk returns a BulkOperations that runs each operation on many
+	// machines concurrently.
+	Bulk(BulkArgs) BulkOperations
+
+	// InventorySnapshot reads the machines, devices, subnets, fabrics with
+	// their VLANs, and zones concurrently, using at most
+	// InventorySnapshotArgs.Workers requests at the same time. Once the
+	// context is done no more requests are started, and the context's
+	// error is returned.
+	InventorySnapshot(ctx context.Context, args InventorySnapshotArgs) (InventorySnapshot, error)
+
+	// IPUtilization reads the statistics of all the subnets concurrently,
+	// using at most IPUtilizationArgs.Workers requests at the same time,
+	// and returns the address usage of each subnet and their totals.
+	// Subnets deleted while the report is made are left out. Once the
+	// context is done no more requests are started, and the context's
+	// error is returned.
+	IPUtilization(ctx context.Context, args IPUtilizationArgs) (IPUtilization, error)
+
+	// CapacitySummary returns the total resources of the machines matching
+	// the args that are free (Ready) and allocated (Allocated, Deploying
+	// or Deployed), grouped by zone and resource pool. Machines with any
+	// other status aren't counted.
+	CapacitySummary(MachinesArgs) ([]CapacityGroup, error)
+
+	// ProxyConfig returns the MAAS settings for the HTTP proxy.
+	ProxyConfig() (ProxyConfig, error)
+
+	// SetProxyConfig validates and changes the MAAS settings for the HTTP
+	// proxy.
+	SetProxyConfig(ProxyConfig) error
+
+	// NTPConfig returns the MAAS settings for the NTP servers.
+	NTPConfig() (NTPConfig, error)
+
+	// SetNTPConfig validates and changes the MAAS settings for the NTP
+	// servers.
+	SetNTPConfig(NTPConfig) error
+
+	// ConfigureNetworkServices validates the proxy and NTP settings, and
+	// then changes them all. The settings aren't changed back if one
+	// fails to be set.
+	ConfigureNetworkServices(ConfigureNetworkServicesArgs) error
+
+	// DNSConfig returns the MAAS settings for the upstream DNS servers and
+	// DNSSEC validation.
+	DNSConfig() (DNSConfig, error)
+
+	// SetDNSConfig validates and changes the MAAS settings for the
+	// upstream DNS servers and DNSSEC validation.
+	SetDNSConfig(DNSConfig) error
+
+	// ConfigureDNS validates the DNS settings, changes them, and then
+	// makes ConfigureDNSArgs.DefaultDomain the default domain if it is
+	// set. The settings aren't changed back if one fails to be set.
+	ConfigureDNS(ConfigureDNSArgs) error
+
+	// SetDefaultStorageLayout sets the storage layout that MAAS applies to
+	// machines when they are commissioned. The layout must be one of
+	// StorageLayouts.
+	SetDefaultStorageLayout(layout string) error
+
+	// SetDefaultMinHWEKernel sets the minimum kernel that MAAS deploys to
+	// machines, such as "hwe-16.04". An empty kernel removes the minimum.
+	SetDefaultMinHWEKernel(kernel string) error
+}
+
+// BulkOperations performs the same operation on a number of machines,
+// making the requests for each machine concurrently. If the operation fails
+// for any of the machines, a MultiError is returned with the error for each
+// of them. Once the context is done no more requests are started, and the
+// machines that were skipped are reported in the MultiError with the
+// context's error.
+type BulkOperations interface {
+	// Start starts each of the machines, see Machine.Start. The args are
+	// validated once, before any machine is started.
+	Start(ctx context.Context, machines []Machine, args StartArgs) error
+
+	// Release releases each of the machines, see Controller.ReleaseMachines.
+	Release(ctx context.Context, machines []Machine, comment string) error
+
+	// SetOwnerData updates the owner data of each of the machines, see
+	// OwnerDataHolder.SetOwnerData.
+	SetOwnerData(ctx context.Context, machines []Machine, ownerData map[string]string) error
 }
 
 // File represents a file stored in the MAAS controller.
@@ -77,6 +335,11 @@ type File interface {
 	// file without credentials.
 	AnonymousURL() string
 
+	// Stat returns the metadata of the file without downloading its
+	// content. The size and hash are only known if the file was read with
+	// its content, by GetFile, or MAAS reports them.
+	Stat() (FileInfo, error)
+
 	// Delete removes the file from the MAAS controller.
 	Delete() error
 
@@ -131,6 +394,38 @@ type VLAN interface {
 
 	PrimaryRack() string
 	SecondaryRack() string
+
+	// RelayVLAN returns the VLAN that DHCP requests on this VLAN are
+	// relayed to, or nil if they aren't relayed.
+	RelayVLAN() VLAN
+
+	// Space is the name of the space the VLAN is in. MAAS reports
+	// "undefined" for VLANs that aren't in a space, and older versions
+	// don't report it, when it is empty.
+	Space() string
+
+	// ExternalDHCP is the address of a DHCP server on the VLAN that isn't
+	// managed by MAAS, or empty if there is none.
+	ExternalDHCP() string
+
+	// SetRelay relays the DHCP requests on this VLAN to the target VLAN,
+	// which must have MAAS managed DHCP. It is only supported for VLANs
+	// obtained from a Controller or an Interface.
+	SetRelay(target VLAN) error
+
+	// ClearRelay stops relaying the DHCP requests on this VLAN. It is only
+	// supported for VLANs obtained from a Controller or an Interface.
+	ClearRelay() error
+
+	// SetDHCPRacks turns on MAAS managed DHCP on this VLAN, served by the
+	// rack controllers with the system IDs. The secondary rack is
+	// optional, and makes DHCP highly available. It is only supported for
+	// VLANs obtained from a Controller or an Interface.
+	SetDHCPRacks(primary, secondary string) error
+
+	// Subnets returns the subnets on the VLAN. It is only supported for
+	// VLANs obtained from a Controller or an Interface.
+	Subnets() ([]Subnet, error)
 }
 
 // Zone represents a physical zone that a Machine is in. The meaning of a
@@ -142,6 +437,27 @@ type Zone interface {
 	Description() string
 }
 
+// Pool represents a resource pool. Resource pools group machines so that
+// they can be set aside for particular users or uses. Pools were introduced
+// in MAAS 2.3.
+type Pool interface {
+	ID() int
+	Name() string
+	Description() string
+}
+
+// Domain represents a DNS domain managed by MAAS.
+type Domain interface {
+	ID() int
+	Name() string
+	// TTL is zero if the domain uses the default TTL.
+	TTL() int
+	Authoritative() bool
+	// IsDefault is whether the domain is given to new machines.
+	IsDefault() bool
+	ResourceRecordCount() int
+}
+
 // BootResource is the bomb... find something to say here.
 type BootResource interface {
 	ID() int
@@ -154,52 +470,147 @@ type BootResource interface {
 
 // Device represents some form of device in MAAS.
 type Device interface {
-	// TODO: add domain
-	SystemID() string
-	Hostname() string
-	FQDN() string
+	// NodeType and NodeTypeName describe the kind of node, which is
+	// "Device" for devices.
+	GenericNode
+
+	// IPAddresses is empty, never nil, if the device has no addresses.
 	IPAddresses() []string
+	// IPAddrs are the parsed IPAddresses.
+	IPAddrs() []netip.Addr
 	Zone() Zone
 
 	// Parent returns the SystemID of the Parent. Most often this will be a
 	// Machine.
 	Parent() string
 
+	// ParentMachine returns the Machine that is the parent of the device.
+	// The Machine is read from the MAAS controller the first time, and
+	// remembered until the parent is changed.
+	ParentMachine() (Machine, error)
+
 	// Owner is the username of the user that created the device.
 	Owner() string
 
+	// Domain is the name of the DNS domain of the device.
+	Domain() string
+
+	// Description is empty if the device has none, or the MAAS controller
+	// doesn't report it.
+	Description() string
+
+	// Pool returns the resource pool of the device, or nil if it doesn't
+	// have one.
+	Pool() Pool
+
+	// AddressTTL is the TTL of the DNS records for the device's addresses.
+	// It is zero if the domain's TTL is used.
+	AddressTTL() int
+
+	// A Device is marshalled to the JSON it was read from, see
+	// UnmarshalDevice.
+	json.Marshaler
+
 	// InterfaceSet returns all the interfaces for the Device.
 	InterfaceSet() []Interface
 
+	// Interfaces gets the current interfaces of the Device from the MAAS
+	// controller, and updates the InterfaceSet with them.
+	Interfaces() ([]Interface, error)
+
+	// Interface returns the interface of the Device from the InterfaceSet
+	// that matches the id specified. If there is no match, nil is returned.
+	Interface(id int) Interface
+
 	// CreateInterface will create a physical interface for this machine.
 	CreateInterface(CreateInterfaceArgs) (Interface, error)
 
+	// Update changes the hostname, parent or zone of the Device.
+	Update(UpdateDeviceArgs) error
+
 	// Delete will remove this Device.
 	Delete() error
 }
 
+// GenericNode is any of the kinds of node MAAS knows about, which are told
+// apart by NodeType.
+type GenericNode interface {
+	SystemID() string
+	Hostname() string
+	FQDN() string
+	// NodeType is one of the NodeType constants, and NodeTypeName is its
+	// name, such as "Rack controller".
+	NodeType() int
+	NodeTypeName() string
+}
+
+// RackController represents a MAAS rack controller.
+type RackController interface {
+	GenericNode
+
+	// Interfaces returns the network interfaces of the rack controller,
+	// whose VLANs it can serve DHCP on.
+	Interfaces() []Interface
+}
+
 // Machine represents a physical machine.
 type Machine interface {
 	OwnerDataHolder
+	// NodeType and NodeTypeName describe the kind of node, which is
+	// "Machine" for machines.
+	GenericNode
 
-	SystemID() string
-	Hostname() string
-	FQDN() string
+	// Tags is empty, never nil, if the machine has no tags.
 	Tags() []string
 
+	// Description is empty if the machine has none, or the MAAS controller
+	// doesn't report it.
+	Description() string
+
+	// WorkloadAnnotations returns a copy of the key/value data describing
+	// the workload deployed on the machine. Workload annotations were
+	// introduced in MAAS 2.9, and are kept apart from the owner data.
+	WorkloadAnnotations() map[string]string
+
+	// SetWorkloadAnnotations updates the workload annotations with the
+	// values passed in. Existing keys that aren't specified are left in
+	// place; to clear a key set its value to "". The machine must be
+	// allocated or deployed, and MAAS must be 2.9 or later.
+	SetWorkloadAnnotations(map[string]string) error
+
 	OperatingSystem() string
 	DistroSeries() string
 	Architecture() string
 	Memory() int
 	CPUCount() int
-
+	// HardwareInfo is the hardware MAAS found when commissioning the
+	// Machine, keyed by names such as "cpu_model" and "system_vendor". It
+	// is empty if the Machine hasn't been commissioned.
+	HardwareInfo() map[string]string
+	// Resources returns the compute resources of the Machine.
+	Resources() MachineResources
+
+	// IPAddresses is empty, never nil, if the machine has no addresses.
 	IPAddresses() []string
+	// IPAddrs are the parsed IPAddresses.
+	IPAddrs() []netip.Addr
 	PowerState() string
 
 	// Devices returns a list of devices that match the params and have
 	// this Machine as the parent.
 	Devices(DevicesArgs) ([]Device, error)
 
+	// TailEvents sends the Machine's events created since the time, and
+	// then polls for new events and sends them as they happen, oldest
+	// first. Only the latest events are sent if since is zero. The
+	// channels are closed when the context is done, or after the error is
+	// sent if reading the events fails.
+	TailEvents(ctx context.Context, since time.Time) (<-chan Event, <-chan error)
+
+	// DeviceBySystemID returns the device with the system ID, if it has
+	// this Machine as the parent.
+	DeviceBySystemID(systemID string) (Device, error)
+
 	// Consider bundling the status values into a single struct.
 	// but need to check for consistent representation if exposed on other
 	// entities.
@@ -209,11 +620,28 @@ type Machine interface {
 
 	// BootInterface returns the interface that was used to boot the Machine.
 	BootInterface() Interface
+	// SetBootInterface makes the interface with the ID the one the Machine
+	// PXE boots from. It needs MAAS 2.5 or later.
+	SetBootInterface(ifaceID int) error
 	// InterfaceSet returns all the interfaces for the Machine.
 	InterfaceSet() []Interface
+	// PrimarySubnet returns the subnet of the first link of the boot
+	// interface, or of the first linked interface if the boot interface
+	// has no links. If no interfaces are linked to subnets nil is returned.
+	PrimarySubnet() Subnet
+	// GatewayFor returns the gateway of the first subnet in the space that
+	// an interface of the Machine is linked to. A NoMatchError is returned
+	// if there is no such subnet with a gateway.
+	GatewayFor(space string) (string, error)
+	// AddressesInSpace returns the IP addresses of the links to subnets in
+	// the space.
+	AddressesInSpace(space string) []string
 	// Interface returns the interface for the machine that matches the id
 	// specified. If there is no match, nil is returned.
 	Interface(id int) Interface
+	// SRIOVCapableInterfaces returns the physical interfaces of the
+	// Machine that support SR-IOV virtual functions, most first.
+	SRIOVCapableInterfaces() []Interface
 
 	// PhysicalBlockDevices returns all the physical block devices on the machine.
 	PhysicalBlockDevices() []BlockDevice
@@ -227,14 +655,128 @@ type Machine interface {
 	// id specified. If there is no match, nil is returned.
 	BlockDevice(id int) BlockDevice
 
+	// SpecialFilesystems returns the filesystems, such as tmpfs, that are
+	// mounted on the machine without a block device.
+	SpecialFilesystems() []FileSystem
+	// MountSpecialFilesystem declares a filesystem that isn't backed by
+	// a block device, to be mounted when the Machine is deployed.
+	MountSpecialFilesystem(MountSpecialArgs) error
+	// UnmountSpecial removes the special filesystem at the mount point.
+	UnmountSpecial(mountPoint string) error
+
 	Zone() Zone
 
+	// Owner is the username of the user the machine is allocated to. It
+	// is empty if the machine isn't allocated.
+	Owner() string
+
+	// Pool returns the resource pool the machine belongs to. Servers older
+	// than MAAS 2.3 don't have pools, so nil is returned.
+	Pool() Pool
+
+	// Locked returns true if the machine has been locked to prevent changes
+	// to it. Locking was introduced in MAAS 2.5.
+	Locked() bool
+
+	// AddressTTL is the TTL of the DNS records for the machine's
+	// addresses. It is zero if the domain's TTL is used.
+	AddressTTL() int
+
+	// SwapSize is the size, in bytes, of the swap file made when the
+	// machine is deployed. It is zero if MAAS chooses the size.
+	SwapSize() uint64
+	// SetSwapSize sets the size of the swap file, in bytes. Zero lets MAAS
+	// choose the size.
+	SetSwapSize(bytes uint64) error
+
+	// Netboot reports whether the machine boots from the network, which
+	// MAAS turns off once a machine is deployed.
+	Netboot() bool
+	// SetNetboot sets whether the machine boots from the network.
+	SetNetboot(netboot bool) error
+
+	// TestResultsSummary returns the status of the commissioning and
+	// testing of the machine, and of the tests of its hardware.
+	TestResultsSummary() TestResultsSummary
+
+	// LastImageSync is when the boot images were last synced to the
+	// machine, if it is a controller. It is the zero time otherwise.
+	LastImageSync() time.Time
+
 	// Start the machine and install the operating system specified in the args.
+	// The args are validated before any request is made.
 	Start(StartArgs) error
 
 	// CreateDevice creates a new Device with this Machine as the parent.
 	// The device will have one interface that is linked to the specified subnet.
 	CreateDevice(CreateMachineDeviceArgs) (Device, error)
+
+	// CloneTo copies the storage and/or network configuration of this
+	// Machine to each of the destination machines, identified by system
+	// ID. The clone operation was introduced in MAAS 2.9.
+	CloneTo(destinations []string, cloneStorage, cloneNetwork bool) error
+
+	// SetStorageLayout replaces the partitions and filesystems of the
+	// Machine's disks with the layout, which must be one of StorageLayouts.
+	// The options are passed to MAAS, e.g. "root_size" or "vg_name". The
+	// Machine must be Ready or Allocated.
+	SetStorageLayout(layout string, options map[string]string) error
+
+	// RestoreNetworkingConfiguration resets the Machine's interfaces to
+	// the configuration found when it was commissioned. The Machine must be
+	// Ready or Broken.
+	RestoreNetworkingConfiguration() error
+
+	// RestoreStorageConfiguration resets the Machine's disks to the
+	// configuration found when it was commissioned, with the default
+	// storage layout. The Machine must be Ready or Broken.
+	RestoreStorageConfiguration() error
+
+	// RestoreDefaultConfiguration restores both the networking and storage
+	// configuration of the Machine, undoing any customization. The Machine
+	// must be Ready or Broken.
+	RestoreDefaultConfiguration() error
+
+	// ScriptResults returns the results of the commissioning, testing and
+	// installation scripts that have been run on the Machine.
+	ScriptResults(ScriptResultsArgs) ([]ScriptResult, error)
+
+	// CommissioningResultID, TestingResultID and InstallationResultID are
+	// the IDs of the ScriptResults of the most recent commissioning,
+	// testing and installation of the machine. They are zero if the
+	// scripts haven't been run, or MAAS is older than 2.2.
+	CommissioningResultID() int
+	TestingResultID() int
+	InstallationResultID() int
+
+	// InstallationLog returns the curtin installation log from the most
+	// recent deployment of the machine, which explains deployment failures.
+	InstallationLog() ([]byte, error)
+
+	// DownloadInstallationOutput copies the output of the most recent
+	// installation, the curtin log, to the writer as it is received. A
+	// NoMatchError is returned if the machine hasn't been installed.
+	DownloadInstallationOutput(w io.Writer) error
+
+	// CurtinConfig returns the curtin configuration, in YAML, that MAAS
+	// uses to deploy the machine.
+	CurtinConfig() ([]byte, error)
+
+	// Details returns the hardware that MAAS found when the machine was
+	// commissioned, read from the lshw and lldp output.
+	Details() (HardwareDetails, error)
+
+	// CommissioningData returns the hardware that MAAS found when the
+	// machine was last commissioned, read from the machine-resources
+	// output, which MAAS produces from 2.9.
+	CommissioningData() (CommissioningData, error)
+
+	// NodeDevices returns the PCI and USB devices of the Machine that match
+	// the args, as found when it was commissioned by MAAS 2.9 or later.
+	NodeDevices(NodeDevicesArgs) ([]NodeDevice, error)
+
+	// Delete removes the Machine from the MAAS controller.
+	Delete() error
 }
 
 // Space is a name for a collection of Subnets.
@@ -242,6 +784,12 @@ type Space interface {
 	ID() int
 	Name() string
 	Subnets() []Subnet
+	// VLANs returns the VLANs in the space. It is empty for controllers
+	// that don't report them.
+	VLANs() []VLAN
+
+	// Rename changes the name of the space.
+	Rename(name string) error
 }
 
 // Subnet refers to an IP range on a VLAN.
@@ -255,9 +803,23 @@ type Subnet interface {
 	CIDR() string
 	// dns_mode
 
+	// GatewayAddr is the parsed Gateway, which is the zero netip.Addr if
+	// there is no gateway.
+	GatewayAddr() netip.Addr
+	// CIDRPrefix is the parsed CIDR.
+	CIDRPrefix() netip.Prefix
+
 	// DNSServers is a list of ip addresses of the DNS servers for the subnet.
 	// This list may be empty.
 	DNSServers() []string
+
+	// RDNSMode is how MAAS serves the reverse DNS of the subnet.
+	RDNSMode() RDNSMode
+	// IsIPv6 reports whether the CIDR is an IPv6 prefix.
+	IsIPv6() bool
+
+	// Update changes the name, gateway, DNS servers or reverse DNS mode.
+	Update(UpdateSubnetArgs) error
 }
 
 // StaticRoute defines an explicit route that users have requested to be added
@@ -291,6 +853,7 @@ type Interface interface {
 	Children() []string
 	Type() string
 	Enabled() bool
+	// Tags is empty, never nil, if the interface has no tags.
 	Tags() []string
 
 	VLAN() VLAN
@@ -299,12 +862,37 @@ type Interface interface {
 	MACAddress() string
 	EffectiveMTU() int
 
-	// Params is a JSON field, and defaults to an empty string, but is almost
-	// always a JSON object in practice. Gleefully ignoring it until we need it.
-
-	// Update the name, mac address or VLAN.
+	// LinkConnected reports whether MAAS saw a carrier on the interface the
+	// last time the machine was commissioned. It is always true for
+	// controllers older than MAAS 2.5, which don't check.
+	LinkConnected() bool
+	// InterfaceSpeed is the maximum speed of the interface in Mbit/s, and
+	// LinkSpeed the speed the link negotiated. Both are zero if unknown.
+	InterfaceSpeed() int
+	LinkSpeed() int
+
+	// SRIOVMaxVF is the number of SR-IOV virtual functions the interface
+	// supports, and zero if it doesn't support SR-IOV. Vendor, Product and
+	// FirmwareVersion describe the network card. All of these are only
+	// reported for physical interfaces, by MAAS 2.7 and later.
+	SRIOVMaxVF() int
+	Vendor() string
+	Product() string
+	FirmwareVersion() string
+
+	// Params returns the bond, bridge and MTU parameters of the interface.
+	// It is the zero value if the interface has none.
+	Params() InterfaceParams
+
+	// Update the name, mac address, VLAN or tags.
 	Update(UpdateInterfaceArgs) error
 
+	// AddTag adds the tag to the interface.
+	AddTag(tag string) error
+
+	// RemoveTag removes the tag from the interface.
+	RemoveTag(tag string) error
+
 	// Delete this interface.
 	Delete() error
 
@@ -315,16 +903,41 @@ type Interface interface {
 	// UnlinkSubnet will remove the Link to the subnet, and release the IP
 	// address associated if there is one.
 	UnlinkSubnet(Subnet) error
+
+	// UpdateLink changes the mode of the link with the ID, and its address
+	// if the new mode is static. A static link keeps the address the link
+	// has unless another is given, so an auto link can be made static
+	// without losing its address. If MAAS refuses the new mode, the link
+	// is restored as it was.
+	UpdateLink(linkID int, args UpdateLinkArgs) error
+
+	// EnsureStaticIP links the interface to the subnet with a static
+	// address that MAAS chooses, unless it already has one there, and
+	// returns the address. An existing link to the subnet is made static,
+	// keeping its address if it has one.
+	EnsureStaticIP(subnet Subnet) (string, error)
 }
 
 // Link represents a network link between an Interface and a Subnet.
 type Link interface {
 	ID() int
+	// Mode is the mode as MAAS reports it, in lower case, such as
+	// "link_up". LinkMode is the same mode as one of the LinkMode
+	// constants, to compare with those used to link subnets.
 	Mode() string
+	LinkMode() InterfaceLinkMode
+	// IsStatic reports whether the address of the link was chosen by
+	// hand, rather than with LinkModeAuto. IsDHCP reports whether the
+	// address is leased with DHCP.
+	IsStatic() bool
+	IsDHCP() bool
 	Subnet() Subnet
 	// IPAddress returns the address if one has been assigned.
 	// If unavailble, the address will be empty.
 	IPAddress() string
+	// IPAddr is the parsed IPAddress, which is the zero netip.Addr if no
+	// address has been assigned.
+	IPAddr() netip.Addr
 }
 
 // FileSystem represents a formatted filesystem mounted at a location.
@@ -335,6 +948,7 @@ type FileSystem interface {
 	MountPoint() string
 	Label() string
 	UUID() string
+	MountOptions() string
 }
 
 // Partition represents a partition of a block device. It may be mounted
@@ -349,6 +963,22 @@ type Partition interface {
 	UsedFor() string
 	// Size is the number of bytes in the partition.
 	Size() uint64
+	// Type is "partition".
+	Type() string
+	Bootable() bool
+
+	// Format creates a filesystem on the partition.
+	Format(FormatArgs) error
+	// Unformat removes the filesystem from the partition.
+	Unformat() error
+	// Mount mounts the filesystem of the partition.
+	Mount(MountArgs) error
+	// Unmount unmounts the filesystem of the partition.
+	Unmount() error
+	// Delete removes the partition from its block device. The partitions
+	// of the BlockDevice it was read from are not updated until they are
+	// refreshed.
+	Delete() error
 }
 
 // BlockDevice represents an entire block device on the machine.
@@ -359,18 +989,83 @@ type BlockDevice interface {
 	IDPath() string
 	Path() string
 	UsedFor() string
+	// Tags is empty, never nil, if the block device has no tags.
 	Tags() []string
 
 	BlockSize() uint64
 	UsedSize() uint64
 	Size() uint64
 
+	// FirmwareVersion is empty if unknown.
+	FirmwareVersion() string
+	// StoragePool is the pod storage pool a virtual block device is
+	// allocated from, and is empty for other block devices.
+	StoragePool() string
+	NUMANode() int
+
 	Partitions() []Partition
+	// RefreshPartitions reads the partitions of the block device from the
+	// controller, replacing those returned by Partitions.
+	RefreshPartitions() error
+	// CreatePartition adds a partition to the block device.
+	CreatePartition(CreatePartitionArgs) (Partition, error)
 
 	// There are some other attributes for block devices, but we can
 	// expose them on an as needed basis.
 }
 
+// ScriptResult is the result of running a set of commissioning, testing or
+// installation scripts on a Machine.
+type ScriptResult interface {
+	ID() int
+	// TypeName is "Commissioning", "Testing" or "Installation".
+	TypeName() string
+	StatusName() string
+	// Scripts returns the status of each of the scripts that were run.
+	Scripts() []ScriptStatus
+
+	// DownloadOutput copies the output of the scripts, which is one of the
+	// ScriptOutput constants, to the writer as it is received, as the
+	// output of stress tests can be large. It returns the content type of
+	// the output, detected from the output if the MAAS controller doesn't
+	// report it.
+	DownloadOutput(w io.Writer, output string) (string, error)
+}
+
+// StaticHost is a Device with a static IP address, made by
+// Controller.ReserveStaticHost.
+type StaticHost interface {
+	Device() Device
+	Subnet() Subnet
+	IPAddress() string
+
+	// Release deletes the Device, which releases the IP address.
+	Release() error
+}
+
+// NodeDevice represents a PCI or USB device of a Machine.
+type NodeDevice interface {
+	ID() int
+	// Bus is "PCIE" or "USB".
+	Bus() string
+	// HardwareType is "Node", "CPU", "Memory", "Storage", "Network" or
+	// "GPU".
+	HardwareType() string
+	VendorID() string
+	ProductID() string
+	VendorName() string
+	ProductName() string
+	// CommissioningDriver is the kernel driver used for the device while
+	// the machine was commissioned.
+	CommissioningDriver() string
+	BusNumber() int
+	DeviceNumber() int
+	// PCIAddress is empty for USB devices.
+	PCIAddress() string
+	// NUMANode is -1 for devices that aren't attached to a NUMA node.
+	NUMANode() int
+}
+
 // OwnerDataHolder represents any MAAS object that can store key/value
 // data.
 type OwnerDataHolder interface {
@@ -0,0 +1,312 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/juju/errors"
+)
+
+// InventorySnapshotArgs is an argument struct for passing parameters to
+// the Controller.InventorySnapshot method.
+type InventorySnapshotArgs struct {
+	// Workers is the maximum number of requests made to the controller at
+	// the same time. If zero, DefaultBulkWorkers is used.
+	Workers int
+}
+
+// InventorySnapshot is the inventory of a MAAS controller, as returned by
+// Controller.InventorySnapshot. The machines and devices are ordered by
+// system ID, the subnets, VLANs and fabrics by ID, and the zones by name.
+//
+// The snapshot is marshalled to JSON as a summary of each entity, with the
+// fields always in the same order, so that snapshots can be compared.
+type InventorySnapshot struct {
+	Machines []Machine
+	Devices  []Device
+	Subnets  []Subnet
+	VLANs    []VLAN
+	Fabrics  []Fabric
+	Zones    []Zone
+}
+
+// MachineStatusCounts returns the number of machines with each status
+// name, for example "Deployed".
+func (s InventorySnapshot) MachineStatusCounts() map[string]int {
+	counts := make(map[string]int)
+	for _, m := range s.Machines {
+		counts[m.StatusName()]++
+	}
+	return counts
+}
+
+// InventorySnapshot implements Controller.
+func (c *controller) InventorySnapshot(ctx context.Context, args InventorySnapshotArgs) (InventorySnapshot, error) {
+	workers := args.Workers
+	if workers <= 0 {
+		workers = DefaultBulkWorkers
+	}
+
+	// Each read sets a different field of the snapshot.
+	var snapshot InventorySnapshot
+	reads := []struct {
+		what string
+		read func() error
+	}{{
+		what: "machines",
+		read: func() (err error) {
+			snapshot.Machines, err = c.Machines(MachinesArgs{})
+			return err
+		},
+	}, {
+		what: "devices",
+		read: func() (err error) {
+			snapshot.Devices, err = c.Devices(DevicesArgs{})
+			return err
+		},
+	}, {
+		what: "subnets",
+		read: func() error {
+			subnets, err := c.cachedSubnets(true)
+			if err != nil {
+				return err
+			}
+			snapshot.Subnets = make([]Subnet, len(subnets))
+			for i, s := range subnets {
+				snapshot.Subnets[i] = s
+			}
+			sort.SliceStable(snapshot.Subnets, func(i, j int) bool {
+				return snapshot.Subnets[i].ID() < snapshot.Subnets[j].ID()
+			})
+			return nil
+		},
+	}, {
+		what: "fabrics",
+		read: func() error {
+			fabrics, err := c.Fabrics()
+			if err != nil {
+				return err
+			}
+			sort.SliceStable(fabrics, func(i, j int) bool {
+				return fabrics[i].ID() < fabrics[j].ID()
+			})
+			var vlans []VLAN
+			for _, f := range fabrics {
+				vlans = append(vlans, f.VLANs()...)
+			}
+			sort.SliceStable(vlans, func(i, j int) bool {
+				return vlans[i].ID() < vlans[j].ID()
+			})
+			snapshot.Fabrics = fabrics
+			snapshot.VLANs = vlans
+			return nil
+		},
+	}, {
+		what: "zones",
+		read: func() (err error) {
+			snapshot.Zones, err = c.Zones()
+			return err
+		},
+	}}
+
+	var wg sync.WaitGroup
+	failures := make([]error, len(reads))
+	slots := make(chan struct{}, workers)
+	for i, r := range reads {
+		if ctx.Err() == nil {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int, what string, read func() error) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			if err := read(); err != nil {
+				failures[i] = errors.Annotatef(err, "reading %s", what)
+			}
+		}(i, r.what, r.read)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return InventorySnapshot{}, errors.Trace(err)
+	}
+	// Report the first failure in the order of the reads, so the error
+	// doesn't depend on which request finished first.
+	for _, err := range failures {
+		if err != nil {
+			return InventorySnapshot{}, err
+		}
+	}
+	return snapshot, nil
+}
+
+type machineSummary struct {
+	SystemID     string   `json:"system_id"`
+	Hostname     string   `json:"hostname"`
+	FQDN         string   `json:"fqdn"`
+	Status       string   `json:"status"`
+	PowerState   string   `json:"power_state"`
+	Zone         string   `json:"zone"`
+	Pool         string   `json:"pool"`
+	Architecture string   `json:"architecture"`
+	CPUCount     int      `json:"cpu_count"`
+	Memory       int      `json:"memory"`
+	IPAddresses  []string `json:"ip_addresses"`
+	Tags         []string `json:"tags"`
+}
+
+type deviceSummary struct {
+	SystemID    string   `json:"system_id"`
+	Hostname    string   `json:"hostname"`
+	FQDN        string   `json:"fqdn"`
+	Parent      string   `json:"parent"`
+	Owner       string   `json:"owner"`
+	Zone        string   `json:"zone"`
+	IPAddresses []string `json:"ip_addresses"`
+}
+
+type subnetSummary struct {
+	ID         int      `json:"id"`
+	Name       string   `json:"name"`
+	CIDR       string   `json:"cidr"`
+	Space      string   `json:"space"`
+	Gateway    string   `json:"gateway"`
+	VLAN       int      `json:"vlan"`
+	DNSServers []string `json:"dns_servers"`
+}
+
+type vlanSummary struct {
+	ID     int    `json:"id"`
+	VID    int    `json:"vid"`
+	Name   string `json:"name"`
+	Fabric string `json:"fabric"`
+	MTU    int    `json:"mtu"`
+	DHCP   bool   `json:"dhcp"`
+}
+
+type fabricSummary struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	ClassType string `json:"class_type"`
+	VLANs     []int  `json:"vlans"`
+}
+
+type zoneSummary struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (s InventorySnapshot) MarshalJSON() ([]byte, error) {
+	summary := struct {
+		Machines            []machineSummary `json:"machines"`
+		Devices             []deviceSummary  `json:"devices"`
+		Subnets             []subnetSummary  `json:"subnets"`
+		VLANs               []vlanSummary    `json:"vlans"`
+		Fabrics             []fabricSummary  `json:"fabrics"`
+		Zones               []zoneSummary    `json:"zones"`
+		MachineStatusCounts map[string]int   `json:"machine_status_counts"`
+	}{
+		Machines:            make([]machineSummary, len(s.Machines)),
+		Devices:             make([]deviceSummary, len(s.Devices)),
+		Subnets:             make([]subnetSummary, len(s.Subnets)),
+		VLANs:               make([]vlanSummary, len(s.VLANs)),
+		Fabrics:             make([]fabricSummary, len(s.Fabrics)),
+		Zones:               make([]zoneSummary, len(s.Zones)),
+		MachineStatusCounts: s.MachineStatusCounts(),
+	}
+	for i, m := range s.Machines {
+		var pool string
+		if p := m.Pool(); p != nil {
+			pool = p.Name()
+		}
+		summary.Machines[i] = machineSummary{
+			SystemID:     m.SystemID(),
+			Hostname:     m.Hostname(),
+			FQDN:         m.FQDN(),
+			Status:       m.StatusName(),
+			PowerState:   m.PowerState(),
+			Zone:         zoneName(m.Zone()),
+			Pool:         pool,
+			Architecture: m.Architecture(),
+			CPUCount:     m.CPUCount(),
+			Memory:       m.Memory(),
+			IPAddresses:  m.IPAddresses(),
+			Tags:         m.Tags(),
+		}
+	}
+	for i, d := range s.Devices {
+		summary.Devices[i] = deviceSummary{
+			SystemID:    d.SystemID(),
+			Hostname:    d.Hostname(),
+			FQDN:        d.FQDN(),
+			Parent:      d.Parent(),
+			Owner:       d.Owner(),
+			Zone:        zoneName(d.Zone()),
+			IPAddresses: d.IPAddresses(),
+		}
+	}
+	for i, subnet := range s.Subnets {
+		var vlan int
+		if v := subnet.VLAN(); v != nil {
+			vlan = v.ID()
+		}
+		summary.Subnets[i] = subnetSummary{
+			ID:         subnet.ID(),
+			Name:       subnet.Name(),
+			CIDR:       subnet.CIDR(),
+			Space:      subnet.Space(),
+			Gateway:    subnet.Gateway(),
+			VLAN:       vlan,
+			DNSServers: subnet.DNSServers(),
+		}
+	}
+	for i, v := range s.VLANs {
+		summary.VLANs[i] = vlanSummary{
+			ID:     v.ID(),
+			VID:    v.VID(),
+			Name:   v.Name(),
+			Fabric: v.Fabric(),
+			MTU:    v.MTU(),
+			DHCP:   v.DHCP(),
+		}
+	}
+	for i, f := range s.Fabrics {
+		vlans := []int{}
+		for _, v := range f.VLANs() {
+			vlans = append(vlans, v.ID())
+		}
+		sort.Ints(vlans)
+		summary.Fabrics[i] = fabricSummary{
+			ID:        f.ID(),
+			Name:      f.Name(),
+			ClassType: f.ClassType(),
+			VLANs:     vlans,
+		}
+	}
+	for i, z := range s.Zones {
+		summary.Zones[i] = zoneSummary{
+			Name:        z.Name(),
+			Description: z.Description(),
+		}
+	}
+	return json.Marshal(summary)
+}
+
+func zoneName(z Zone) string {
+	if z == nil {
+		return ""
+	}
+	return z.Name()
+}
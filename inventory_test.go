@@ -0,0 +1,91 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+func (s *controllerSuite) TestInventorySnapshot(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, subnetResponse)
+	controller := s.getController(c)
+
+	snapshot, err := controller.InventorySnapshot(context.Background(), InventorySnapshotArgs{Workers: 2})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(snapshot.Machines, gc.HasLen, 3)
+	c.Check(snapshot.Devices, gc.HasLen, 1)
+	c.Check(snapshot.Subnets, gc.HasLen, 2)
+	c.Check(snapshot.Fabrics, gc.HasLen, 2)
+	c.Check(snapshot.VLANs, gc.HasLen, 2)
+	c.Check(snapshot.Zones, gc.HasLen, 2)
+	for i := 1; i < len(snapshot.VLANs); i++ {
+		c.Check(snapshot.VLANs[i-1].ID() < snapshot.VLANs[i].ID(), jc.IsTrue)
+	}
+	c.Check(snapshot.MachineStatusCounts(), jc.DeepEquals, map[string]int{
+		"Deployed": 1,
+		"Ready":    2,
+	})
+}
+
+func (s *controllerSuite) TestInventorySnapshotJSON(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, subnetResponse)
+	controller := s.getController(c)
+	snapshot, err := controller.InventorySnapshot(context.Background(), InventorySnapshotArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+
+	first, err := json.Marshal(snapshot)
+	c.Assert(err, jc.ErrorIsNil)
+	second, err := json.Marshal(snapshot)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(first), gc.Equals, string(second))
+
+	var decoded struct {
+		Machines []struct {
+			SystemID string `json:"system_id"`
+			Zone     string `json:"zone"`
+		} `json:"machines"`
+		Fabrics []struct {
+			VLANs []int `json:"vlans"`
+		} `json:"fabrics"`
+		MachineStatusCounts map[string]int `json:"machine_status_counts"`
+	}
+	err = json.Unmarshal(first, &decoded)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(decoded.Machines, gc.HasLen, 3)
+	c.Check(decoded.Machines[0].SystemID, gc.Equals, snapshot.Machines[0].SystemID())
+	c.Check(decoded.Machines[0].Zone, gc.Equals, snapshot.Machines[0].Zone().Name())
+	c.Assert(decoded.Fabrics, gc.HasLen, 2)
+	c.Check(decoded.Fabrics[0].VLANs, gc.NotNil)
+	c.Check(decoded.MachineStatusCounts, jc.DeepEquals, map[string]int{"Deployed": 1, "Ready": 2})
+}
+
+func (s *controllerSuite) TestInventorySnapshotEmptyJSON(c *gc.C) {
+	data, err := json.Marshal(InventorySnapshot{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(data), gc.Equals, `{"machines":[],"devices":[],"subnets":[],"vlans":[],"fabrics":[],"zones":[],"machine_status_counts":{}}`)
+}
+
+func (s *controllerSuite) TestInventorySnapshotError(c *gc.C) {
+	// There is no response for the subnets.
+	controller := s.getController(c)
+	_, err := controller.InventorySnapshot(context.Background(), InventorySnapshotArgs{})
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+	c.Assert(err, gc.ErrorMatches, `(?s)reading subnets: .*`)
+}
+
+func (s *controllerSuite) TestInventorySnapshotContextDone(c *gc.C) {
+	controller := s.getController(c)
+	s.server.ResetRequests()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := controller.InventorySnapshot(ctx, InventorySnapshotArgs{})
+	c.Assert(err, gc.ErrorMatches, "context canceled")
+	c.Assert(s.server.RequestCount(), gc.Equals, 0)
+}
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
 )
 
 // JSONObject is a wrapper around a JSON structure which provides
@@ -59,7 +60,7 @@ func maasify(client Client, value interface{}) JSONObject {
 		return JSONObject{isNull: true}
 	}
 	switch value.(type) {
-	case string, float64, bool:
+	case string, float64, json.Number, bool:
 		return JSONObject{value: value}
 	case map[string]interface{}:
 		original := value.(map[string]interface{})
@@ -86,8 +87,7 @@ func Parse(client Client, input []byte) (JSONObject, error) {
 	if input == nil {
 		panic(errors.New("Parse() called with nil input"))
 	}
-	var parsed interface{}
-	err := json.Unmarshal(input, &parsed)
+	parsed, err := decodeJSON(input)
 	if err == nil {
 		obj = maasify(client, parsed)
 		obj.bytes = input
@@ -162,11 +162,27 @@ func (obj JSONObject) GetString() (value string, err error) {
 // GetFloat64 retrieves the object's value as a float64.  If the value wasn't
 // a JSON number, that's an error.
 func (obj JSONObject) GetFloat64() (value float64, err error) {
-	value, ok := obj.value.(float64)
-	if !ok {
-		err = failConversion("float64", obj)
+	switch number := obj.value.(type) {
+	case float64:
+		return number, nil
+	case json.Number:
+		return number.Float64()
 	}
-	return
+	return 0, failConversion("float64", obj)
+}
+
+// GetNumber retrieves the object's value as a json.Number, which holds the
+// number as it was written, so that large integers can be read without
+// the loss of precision of GetFloat64.  If the value wasn't a JSON number,
+// that's an error.
+func (obj JSONObject) GetNumber() (value json.Number, err error) {
+	switch number := obj.value.(type) {
+	case json.Number:
+		return number, nil
+	case float64:
+		return json.Number(strconv.FormatFloat(number, 'g', -1, 64)), nil
+	}
+	return "", failConversion("number", obj)
 }
 
 // GetMap retrieves the object's value as a map.  If the value wasn't a JSON
@@ -158,6 +158,27 @@ func (suite *JSONObjectSuite) TestParseMaasifiesJSONBlob(c *C) {
 	c.Check(out, Equals, 12.0)
 }
 
+func (suite *JSONObjectSuite) TestParseKeepsLargeNumbers(c *C) {
+	blob := []byte("[18014398509481985]")
+	obj, err := Parse(Client{}, blob)
+	c.Assert(err, IsNil)
+
+	arr, err := obj.GetArray()
+	c.Assert(err, IsNil)
+	out, err := arr[0].GetNumber()
+	c.Assert(err, IsNil)
+	c.Check(out, Equals, json.Number("18014398509481985"))
+	value, err := out.Int64()
+	c.Assert(err, IsNil)
+	c.Check(value, Equals, int64(18014398509481985))
+}
+
+func (suite *JSONObjectSuite) TestGetNumberOfFloat64(c *C) {
+	out, err := maasify(Client{}, 3.5).GetNumber()
+	c.Assert(err, IsNil)
+	c.Check(out, Equals, json.Number("3.5"))
+}
+
 func (suite *JSONObjectSuite) TestParseKeepsBinaryOriginal(c *C) {
 	blob := []byte(`"Hi"`)
 
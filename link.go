@@ -4,6 +4,8 @@
 package gomaasapi
 
 import (
+	"net/netip"
+
 	"github.com/juju/errors"
 	"github.com/juju/schema"
 	"github.com/juju/version"
@@ -12,8 +14,11 @@ import (
 type link struct {
 	id        int
 	mode      string
+	linkMode  InterfaceLinkMode
 	subnet    *subnet
 	ipAddress string
+	ipAddr    netip.Addr
+	addrErr   error
 }
 
 // NOTE: not using lowercase L as the receiver as it is a horrible idea.
@@ -29,6 +34,21 @@ func (k *link) Mode() string {
 	return k.mode
 }
 
+// LinkMode implements Link.
+func (k *link) LinkMode() InterfaceLinkMode {
+	return k.linkMode
+}
+
+// IsStatic implements Link.
+func (k *link) IsStatic() bool {
+	return k.linkMode == LinkModeStatic
+}
+
+// IsDHCP implements Link.
+func (k *link) IsDHCP() bool {
+	return k.linkMode == LinkModeDHCP
+}
+
 // Subnet implements Link.
 func (k *link) Subnet() Subnet {
 	if k.subnet == nil {
@@ -42,6 +62,21 @@ func (k *link) IPAddress() string {
 	return k.ipAddress
 }
 
+// IPAddr implements Link.
+func (k *link) IPAddr() netip.Addr {
+	return k.ipAddr
+}
+
+func (k *link) addressErr() error {
+	if k.addrErr != nil {
+		return k.addrErr
+	}
+	if k.subnet != nil {
+		return k.subnet.addressErr()
+	}
+	return nil
+}
+
 func readLinks(controllerVersion version.Number, source interface{}) ([]*link, error) {
 	checker := schema.List(schema.StringMap(schema.Any()))
 	coerced, err := checker.Coerce(source, nil)
@@ -73,7 +108,7 @@ func readLinkList(sourceList []interface{}, readFunc linkDeserializationFunc) ([
 		}
 		link, err := readFunc(source)
 		if err != nil {
-			return nil, errors.Annotatef(err, "link %d", i)
+			return nil, annotateListItem(err, "link", i, source)
 		}
 		result = append(result, link)
 	}
@@ -86,19 +121,18 @@ var linkDeserializationFuncs = map[version.Number]linkDeserializationFunc{
 	twoDotOh: link_2_0,
 }
 
+var link_2_0Checker = fieldMap("link", schema.Fields{
+	"id":         schema.ForceInt(),
+	"mode":       schema.String(),
+	"subnet":     schema.StringMap(schema.Any()),
+	"ip_address": schema.String(),
+}, schema.Defaults{
+	"ip_address": "",
+	"subnet":     schema.Omit,
+})
+
 func link_2_0(source map[string]interface{}) (*link, error) {
-	fields := schema.Fields{
-		"id":         schema.ForceInt(),
-		"mode":       schema.String(),
-		"subnet":     schema.StringMap(schema.Any()),
-		"ip_address": schema.String(),
-	}
-	defaults := schema.Defaults{
-		"ip_address": "",
-		"subnet":     schema.Omit,
-	}
-	checker := schema.FieldMap(fields, defaults)
-	coerced, err := checker.Coerce(source, nil)
+	coerced, err := link_2_0Checker.Coerce(source, nil)
 	if err != nil {
 		return nil, WrapWithDeserializationError(err, "link 2.0 schema check failed")
 	}
@@ -114,11 +148,17 @@ func link_2_0(source map[string]interface{}) (*link, error) {
 		}
 	}
 
+	mode := valid["mode"].(string)
+	ipAddress := valid["ip_address"].(string)
+	ipAddr, addrErr := parseOptionalAddr(ipAddress)
 	result := &link{
 		id:        valid["id"].(int),
-		mode:      valid["mode"].(string),
+		mode:      mode,
+		linkMode:  normalizeLinkMode(mode),
 		subnet:    subnet,
-		ipAddress: valid["ip_address"].(string),
+		ipAddress: ipAddress,
+		ipAddr:    ipAddr,
+		addrErr:   addrErr,
 	}
 	return result, nil
 }
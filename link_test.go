@@ -4,6 +4,8 @@
 package gomaasapi
 
 import (
+	"net/netip"
+
 	jc "github.com/juju/testing/checkers"
 	"github.com/juju/version"
 	gc "gopkg.in/check.v1"
@@ -31,12 +33,41 @@ func (*linkSuite) TestReadLinks(c *gc.C) {
 	link := links[0]
 	c.Assert(link.ID(), gc.Equals, 69)
 	c.Assert(link.Mode(), gc.Equals, "auto")
+	c.Assert(link.LinkMode(), gc.Equals, LinkModeAuto)
+	c.Assert(link.IsStatic(), jc.IsFalse)
+	c.Assert(link.IsDHCP(), jc.IsFalse)
 	c.Assert(link.IPAddress(), gc.Equals, "192.168.100.5")
+	c.Assert(link.IPAddr(), gc.Equals, netip.MustParseAddr("192.168.100.5"))
 	subnet := link.Subnet()
 	c.Assert(subnet, gc.NotNil)
 	c.Assert(subnet.Name(), gc.Equals, "192.168.100.0/24")
 	// Second link has missing ip_address
 	c.Assert(links[1].IPAddress(), gc.Equals, "")
+	c.Assert(links[1].IPAddr().IsValid(), jc.IsFalse)
+}
+
+func (*linkSuite) TestLinkModes(c *gc.C) {
+	for i, test := range []struct {
+		mode     string
+		expected InterfaceLinkMode
+		static   bool
+		dhcp     bool
+	}{
+		{mode: "auto", expected: LinkModeAuto},
+		{mode: "static", expected: LinkModeStatic, static: true},
+		{mode: "dhcp", expected: LinkModeDHCP, dhcp: true},
+		{mode: "link_up", expected: LinkModeLinkUp},
+		{mode: "STATIC", expected: LinkModeStatic, static: true},
+	} {
+		c.Logf("test %d: %s", i, test.mode)
+		source := []interface{}{map[string]interface{}{"id": 1, "mode": test.mode}}
+		links, err := readLinks(twoDotOh, source)
+		c.Assert(err, jc.ErrorIsNil)
+		c.Check(links[0].Mode(), gc.Equals, test.mode)
+		c.Check(links[0].LinkMode(), gc.Equals, test.expected)
+		c.Check(links[0].IsStatic(), gc.Equals, test.static)
+		c.Check(links[0].IsDHCP(), gc.Equals, test.dhcp)
+	}
 }
 
 func (*linkSuite) TestLowVersion(c *gc.C) {
@@ -0,0 +1,188 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+
+	"github.com/juju/loggo"
+)
+
+// Logger is the interface of the loggers that a Controller writes to. Set
+// ControllerArgs.Logger to use a logger other than loggo, such as one from
+// NewSlogLogger or NewLogrLogger.
+type Logger interface {
+	Tracef(format string, args ...interface{})
+	Debugf(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+}
+
+// traceEnabler is implemented by the loggers that can tell whether trace
+// messages will be written, so that the controller can avoid building
+// messages that would be thrown away.
+type traceEnabler interface {
+	IsTraceEnabled() bool
+}
+
+// loggoLogger is the Logger used when ControllerArgs.Logger isn't set.
+type loggoLogger struct {
+	loggo.Logger
+}
+
+// Warnf implements Logger.
+func (l loggoLogger) Warnf(format string, args ...interface{}) {
+	l.Warningf(format, args...)
+}
+
+// slogLevelTrace is the slog level of trace messages, below slog.LevelDebug.
+const slogLevelTrace = slog.LevelDebug - 4
+
+// NewSlogLogger returns a Logger that writes to the slog.Logger. Trace
+// messages are written at slog.LevelDebug-4, as slog has no trace level.
+func NewSlogLogger(logger *slog.Logger) Logger {
+	return slogLogger{logger}
+}
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+func (l slogLogger) logf(level slog.Level, format string, args []interface{}) {
+	ctx := context.Background()
+	if l.logger.Enabled(ctx, level) {
+		l.logger.Log(ctx, level, fmt.Sprintf(format, args...))
+	}
+}
+
+// Tracef implements Logger.
+func (l slogLogger) Tracef(format string, args ...interface{}) {
+	l.logf(slogLevelTrace, format, args)
+}
+
+// Debugf implements Logger.
+func (l slogLogger) Debugf(format string, args ...interface{}) {
+	l.logf(slog.LevelDebug, format, args)
+}
+
+// Warnf implements Logger.
+func (l slogLogger) Warnf(format string, args ...interface{}) {
+	l.logf(slog.LevelWarn, format, args)
+}
+
+// IsTraceEnabled implements traceEnabler.
+func (l slogLogger) IsTraceEnabled() bool {
+	return l.logger.Enabled(context.Background(), slogLevelTrace)
+}
+
+// LogrSink is the part of logr.LogSink used by NewLogrLogger. It is
+// declared here so that this package doesn't depend on logr.
+type LogrSink interface {
+	Enabled(level int) bool
+	Info(level int, msg string, keysAndValues ...interface{})
+}
+
+// The logr verbosity levels that messages are written at. Warnings are
+// written at the default level, as logr has no warning level.
+const (
+	logrLevelWarn  = 0
+	logrLevelDebug = 1
+	logrLevelTrace = 2
+)
+
+// NewLogrLogger returns a Logger that writes to the sink, such as the one
+// returned by logr.Logger.GetSink. Warnings are written at V(0), debug
+// messages at V(1) and trace messages at V(2).
+func NewLogrLogger(sink LogrSink) Logger {
+	return logrLogger{sink}
+}
+
+type logrLogger struct {
+	sink LogrSink
+}
+
+func (l logrLogger) logf(level int, format string, args []interface{}) {
+	if l.sink.Enabled(level) {
+		l.sink.Info(level, fmt.Sprintf(format, args...))
+	}
+}
+
+// Tracef implements Logger.
+func (l logrLogger) Tracef(format string, args ...interface{}) {
+	l.logf(logrLevelTrace, format, args)
+}
+
+// Debugf implements Logger.
+func (l logrLogger) Debugf(format string, args ...interface{}) {
+	l.logf(logrLevelDebug, format, args)
+}
+
+// Warnf implements Logger.
+func (l logrLogger) Warnf(format string, args ...interface{}) {
+	l.logf(logrLevelWarn, format, args)
+}
+
+// IsTraceEnabled implements traceEnabler.
+func (l logrLogger) IsTraceEnabled() bool {
+	return l.sink.Enabled(logrLevelTrace)
+}
+
+var (
+	// oauthParamRE matches the OAuth parameters that hold credentials, in
+	// query strings and in Authorization headers, where the values are
+	// quoted.
+	oauthParamRE = regexp.MustCompile(`(oauth_(?:consumer_key|token|signature)=)("?)[^&",\s]*`)
+	// userinfoRE matches the password in the userinfo of a URL.
+	userinfoRE = regexp.MustCompile(`(://[^/@\s:]*:)[^/@\s]*@`)
+)
+
+// redact replaces the OAuth credentials and URL passwords in the message
+// with "REDACTED".
+func redact(message string) string {
+	message = oauthParamRE.ReplaceAllString(message, "${1}${2}REDACTED")
+	return userinfoRE.ReplaceAllString(message, "${1}REDACTED@")
+}
+
+// redactingLogger is the Logger that the controller writes to. It formats
+// the messages and redacts them before passing them on.
+type redactingLogger struct {
+	logger Logger
+}
+
+// newRedactingLogger returns a redactingLogger that writes to the target,
+// or to the package's loggo logger if the target is nil.
+func newRedactingLogger(target Logger) redactingLogger {
+	if target == nil {
+		target = loggoLogger{logger}
+	}
+	return redactingLogger{target}
+}
+
+// Tracef implements Logger.
+func (l redactingLogger) Tracef(format string, args ...interface{}) {
+	if l.IsTraceEnabled() {
+		l.logger.Tracef("%s", redact(fmt.Sprintf(format, args...)))
+	}
+}
+
+// Debugf implements Logger.
+func (l redactingLogger) Debugf(format string, args ...interface{}) {
+	l.logger.Debugf("%s", redact(fmt.Sprintf(format, args...)))
+}
+
+// Warnf implements Logger.
+func (l redactingLogger) Warnf(format string, args ...interface{}) {
+	l.logger.Warnf("%s", redact(fmt.Sprintf(format, args...)))
+}
+
+// IsTraceEnabled implements traceEnabler. Loggers that don't implement it
+// are assumed to write trace messages.
+func (l redactingLogger) IsTraceEnabled() bool {
+	if enabler, ok := l.logger.(traceEnabler); ok {
+		return enabler.IsTraceEnabled()
+	}
+	return true
+}
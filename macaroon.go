@@ -0,0 +1,146 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/juju/errors"
+)
+
+// MacaroonDischarger obtains the discharges for the third party caveats of
+// a macaroon issued by MAAS, such as the caveat that the user logs in to a
+// Candid identity server. It is declared here so that this package doesn't
+// depend on the macaroon bakery; a small adapter around the DischargeAll
+// method of an httpbakery.Client satisfies it.
+type MacaroonDischarger interface {
+	// DischargeAll returns the macaroon bound to its discharges, followed
+	// by the discharges. The macaroons are in their JSON form.
+	DischargeAll(ctx context.Context, macaroon json.RawMessage) ([]json.RawMessage, error)
+}
+
+const (
+	// bakeryProtocolHeader asks MAAS for the discharge required challenges
+	// that use the 401 status, rather than 407.
+	bakeryProtocolHeader  = "Bakery-Protocol-Version"
+	bakeryProtocolVersion = "1"
+
+	dischargeRequiredCode = "macaroon discharge required"
+	macaroonCookiePrefix  = "macaroon-"
+)
+
+// dischargeRequired is the body of the response to a request that needs a
+// discharged macaroon.
+type dischargeRequired struct {
+	Code    string
+	Message string
+	Info    struct {
+		Macaroon         json.RawMessage
+		MacaroonPath     string
+		CookieNameSuffix string
+	}
+}
+
+// challengeAnswerer is implemented by the signers that can meet a
+// challenge to their credentials. The client sends the request again when
+// the challenge is answered.
+type challengeAnswerer interface {
+	// answerChallenge returns false if the error isn't a challenge that
+	// the signer can meet.
+	answerChallenge(ctx context.Context, svrErr ServerError) (bool, error)
+}
+
+// macaroonSigner authenticates requests with the macaroons issued by MAAS.
+// The discharged macaroons are cached, and used for all requests until MAAS
+// challenges them, when they are discharged again.
+type macaroonSigner struct {
+	discharger MacaroonDischarger
+
+	mu     sync.Mutex
+	cookie *http.Cookie
+}
+
+var (
+	_ OAuthSigner       = (*macaroonSigner)(nil)
+	_ challengeAnswerer = (*macaroonSigner)(nil)
+)
+
+// OAuthSign implements OAuthSigner. It adds the discharged macaroons, if
+// there are any yet, to the request.
+func (s *macaroonSigner) OAuthSign(request *http.Request) error {
+	request.Header.Set(bakeryProtocolHeader, bakeryProtocolVersion)
+	s.mu.Lock()
+	cookie := s.cookie
+	s.mu.Unlock()
+	if cookie == nil {
+		return nil
+	}
+	// Replace the macaroons sent before a challenge was answered.
+	cookies := request.Cookies()
+	request.Header.Del("Cookie")
+	for _, c := range cookies {
+		if !strings.HasPrefix(c.Name, macaroonCookiePrefix) {
+			request.AddCookie(c)
+		}
+	}
+	request.AddCookie(cookie)
+	return nil
+}
+
+// answerChallenge implements challengeAnswerer.
+func (s *macaroonSigner) answerChallenge(ctx context.Context, svrErr ServerError) (bool, error) {
+	if svrErr.StatusCode != http.StatusUnauthorized && svrErr.StatusCode != http.StatusProxyAuthRequired {
+		return false, nil
+	}
+	var challenge dischargeRequired
+	if err := json.Unmarshal([]byte(svrErr.BodyMessage), &challenge); err != nil || challenge.Code != dischargeRequiredCode {
+		return false, nil
+	}
+	if len(challenge.Info.Macaroon) == 0 {
+		return true, errors.New("discharge required without macaroon")
+	}
+	macaroons, err := s.discharger.DischargeAll(ctx, challenge.Info.Macaroon)
+	if err != nil {
+		return true, errors.Annotate(err, "discharging macaroon")
+	}
+	data, err := json.Marshal(macaroons)
+	if err != nil {
+		return true, errors.Trace(err)
+	}
+	suffix := challenge.Info.CookieNameSuffix
+	if suffix == "" {
+		suffix = "authn"
+	}
+	s.mu.Lock()
+	s.cookie = &http.Cookie{
+		Name:  macaroonCookiePrefix + suffix,
+		Value: base64.StdEncoding.EncodeToString(data),
+	}
+	s.mu.Unlock()
+	return true, nil
+}
+
+// NewMacaroonClient creates a Client that authenticates with macaroons
+// issued by MAAS, using the discharger to discharge them. It is used with
+// MAAS controllers that delegate authentication to Candid, rather than
+// with API keys.
+// versionedURL should be the location of the versioned API root of
+// the MAAS server, e.g.:
+// http://my.maas.server.example.com/MAAS/api/2.0/
+func NewMacaroonClient(versionedURL string, discharger MacaroonDischarger) (*Client, error) {
+	if discharger == nil {
+		return nil, errors.NotValidf("missing discharger")
+	}
+	parsedURL, err := url.Parse(EnsureTrailingSlash(versionedURL))
+	if err != nil {
+		return nil, err
+	}
+	return &Client{Signer: &macaroonSigner{discharger: discharger}, APIURL: parsedURL}, nil
+}
@@ -0,0 +1,163 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type macaroonSuite struct {
+	testing.CleanupSuite
+	server     *SimpleTestServer
+	discharger *fakeDischarger
+}
+
+var _ = gc.Suite(&macaroonSuite{})
+
+// fakeDischarger is a MacaroonDischarger that "discharges" a macaroon by
+// returning it followed by a fixed discharge.
+type fakeDischarger struct {
+	macaroons []string
+	err       error
+}
+
+func (d *fakeDischarger) DischargeAll(ctx context.Context, macaroon json.RawMessage) ([]json.RawMessage, error) {
+	d.macaroons = append(d.macaroons, string(macaroon))
+	if d.err != nil {
+		return nil, d.err
+	}
+	return []json.RawMessage{macaroon, json.RawMessage(`{"i":"discharge"}`)}, nil
+}
+
+const (
+	whoamiPath = "/api/2.0/users/?op=whoami"
+
+	dischargeRequiredResponse = `{
+    "Code": "macaroon discharge required",
+    "Message": "authentication required",
+    "Info": {
+        "Macaroon": {"i": "root"},
+        "MacaroonPath": "/",
+        "CookieNameSuffix": "maas"
+    }
+}`
+)
+
+func (s *macaroonSuite) SetUpTest(c *gc.C) {
+	s.CleanupSuite.SetUpTest(c)
+	s.server = NewSimpleServer()
+	s.server.AddGetResponse("/api/2.0/version/", http.StatusOK, versionResponse)
+	s.server.Start()
+	s.AddCleanup(func(*gc.C) { s.server.Close() })
+	s.discharger = &fakeDischarger{}
+}
+
+func (s *macaroonSuite) newController(c *gc.C) Controller {
+	s.server.AddGetResponse(whoamiPath, http.StatusUnauthorized, dischargeRequiredResponse)
+	s.server.AddGetResponse(whoamiPath, http.StatusOK, `"captain awesome"`)
+	controller, err := NewController(ControllerArgs{
+		BaseURL:            s.server.URL,
+		MacaroonDischarger: s.discharger,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	return controller
+}
+
+// checkMacaroonCookie checks the request was sent with the discharged
+// macaroons.
+func checkMacaroonCookie(c *gc.C, request *http.Request) {
+	c.Check(request.Header.Get("Bakery-Protocol-Version"), gc.Equals, "1")
+	cookies := request.Cookies()
+	c.Assert(cookies, gc.HasLen, 1)
+	c.Check(cookies[0].Name, gc.Equals, "macaroon-maas")
+	data, err := base64.StdEncoding.DecodeString(cookies[0].Value)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(data), gc.Equals, `[{"i":"root"},{"i":"discharge"}]`)
+}
+
+func (s *macaroonSuite) TestNewController(c *gc.C) {
+	s.newController(c)
+	c.Assert(s.discharger.macaroons, jc.DeepEquals, []string{`{"i": "root"}`})
+	checkMacaroonCookie(c, s.server.LastRequest())
+}
+
+func (s *macaroonSuite) TestMacaroonsCached(c *gc.C) {
+	controller := s.newController(c)
+	s.server.AddGetResponse("/api/2.0/machines/", http.StatusOK, "[]")
+
+	_, err := controller.Machines(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.discharger.macaroons, gc.HasLen, 1)
+	checkMacaroonCookie(c, s.server.LastRequest())
+}
+
+func (s *macaroonSuite) TestMacaroonsDischargedAgain(c *gc.C) {
+	controller := s.newController(c)
+	s.server.AddPostResponse("/api/2.0/devices/?op=", http.StatusUnauthorized, dischargeRequiredResponse)
+	s.server.AddPostResponse("/api/2.0/devices/?op=", http.StatusOK, deviceResponse)
+
+	_, err := controller.CreateDevice(CreateDeviceArgs{MACAddresses: []string{"a-mac-address"}})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(s.discharger.macaroons, gc.HasLen, 2)
+	request := s.server.LastRequest()
+	checkMacaroonCookie(c, request)
+	// The body is sent again with the new macaroons.
+	c.Assert(request.PostForm.Get("mac_addresses"), gc.Equals, "a-mac-address")
+}
+
+func (s *macaroonSuite) TestDischargeFails(c *gc.C) {
+	s.discharger.err = errors.New("login failed")
+	s.server.AddGetResponse(whoamiPath, http.StatusUnauthorized, dischargeRequiredResponse)
+	_, err := NewController(ControllerArgs{
+		BaseURL:            s.server.URL,
+		MacaroonDischarger: s.discharger,
+	})
+	c.Assert(err, gc.ErrorMatches, `unexpected: request [0-9a-f]+-[0-9a-f]+: discharging macaroon: login failed`)
+}
+
+func (s *macaroonSuite) TestUnauthorizedWithoutChallenge(c *gc.C) {
+	s.server.AddGetResponse(whoamiPath, http.StatusUnauthorized, "go away")
+	_, err := NewController(ControllerArgs{
+		BaseURL:            s.server.URL,
+		MacaroonDischarger: s.discharger,
+	})
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+	c.Assert(s.discharger.macaroons, gc.HasLen, 0)
+}
+
+func (s *macaroonSuite) TestWithAPIKey(c *gc.C) {
+	_, err := NewController(ControllerArgs{
+		BaseURL:            s.server.URL,
+		APIKey:             "fake:as:key",
+		MacaroonDischarger: s.discharger,
+	})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(s.server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *macaroonSuite) TestNewMacaroonClient(c *gc.C) {
+	client, err := NewMacaroonClient(s.server.URL+"/api/2.0", s.discharger)
+	c.Assert(err, jc.ErrorIsNil)
+	s.server.AddGetResponse("/api/2.0/things/?op=list", http.StatusProxyAuthRequired, dischargeRequiredResponse)
+	s.server.AddGetResponse("/api/2.0/things/?op=list", http.StatusOK, "[]")
+
+	result, err := client.Get(&url.URL{Path: "things/"}, "list", nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(result), gc.Equals, "[]")
+	checkMacaroonCookie(c, s.server.LastRequest())
+}
+
+func (s *macaroonSuite) TestNewMacaroonClientMissingDischarger(c *gc.C) {
+	_, err := NewMacaroonClient(s.server.URL+"/api/2.0", nil)
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
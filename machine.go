@@ -4,15 +4,26 @@
 package gomaasapi
 
 import (
+	"bytes"
+	"encoding/base64"
 	"fmt"
-	"net/http"
+	"net/netip"
 	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/juju/errors"
 	"github.com/juju/schema"
+	"github.com/juju/utils/set"
 	"github.com/juju/version"
 )
 
+// machine implements Machine.
+var _ Machine = (*machine)(nil)
+
 type machine struct {
 	controller *controller
 
@@ -22,27 +33,47 @@ type machine struct {
 	hostname  string
 	fqdn      string
 	tags      []string
+	owner     string
 	ownerData map[string]string
 
+	description         string
+	workloadAnnotations map[string]string
+
 	operatingSystem string
 	distroSeries    string
 	architecture    string
 	memory          int
 	cpuCount        int
+	hardwareInfo    map[string]string
 
 	ipAddresses []string
+	ipAddrs     []netip.Addr
+	addrErr     error
 	powerState  string
+	addressTTL  int
+
+	swapSize uint64
+	netboot  bool
 
 	// NOTE: consider some form of status struct
 	statusName    string
 	statusMessage string
 
+	testResults           TestResultsSummary
+	commissioningResultID int
+	testingResultID       int
+	installationResultID  int
+	lastImageSync         time.Time
+
 	bootInterface *interface_
 	interfaceSet  []*interface_
 	zone          *zone
+	pool          *pool
+	locked        bool
 	// Don't really know the difference between these two lists:
 	physicalBlockDevices []*blockdevice
 	blockDevices         []*blockdevice
+	specialFilesystems   []*filesystem
 }
 
 func (m *machine) updateFrom(other *machine) {
@@ -55,13 +86,53 @@ func (m *machine) updateFrom(other *machine) {
 	m.architecture = other.architecture
 	m.memory = other.memory
 	m.cpuCount = other.cpuCount
+	m.hardwareInfo = other.hardwareInfo
 	m.ipAddresses = other.ipAddresses
+	m.ipAddrs = other.ipAddrs
+	m.addrErr = other.addrErr
 	m.powerState = other.powerState
+	m.addressTTL = other.addressTTL
+	m.swapSize = other.swapSize
+	m.netboot = other.netboot
 	m.statusName = other.statusName
 	m.statusMessage = other.statusMessage
+	m.testResults = other.testResults
+	m.commissioningResultID = other.commissioningResultID
+	m.testingResultID = other.testingResultID
+	m.installationResultID = other.installationResultID
+	m.lastImageSync = other.lastImageSync
 	m.zone = other.zone
+	m.pool = other.pool
+	m.locked = other.locked
 	m.tags = other.tags
+	m.owner = other.owner
 	m.ownerData = other.ownerData
+	m.description = other.description
+	m.workloadAnnotations = other.workloadAnnotations
+	m.specialFilesystems = other.specialFilesystems
+	m.bootInterface = other.bootInterface
+	if m.bootInterface != nil {
+		m.bootInterface.bind(m.controller)
+	}
+}
+
+// bind associates the machine, and the resources nested in it that make
+// requests, with the controller. It is called wherever a machine is read
+// from the controller, so that none of them are left without one.
+func (m *machine) bind(c *controller) {
+	m.controller = c
+	if m.bootInterface != nil {
+		m.bootInterface.bind(c)
+	}
+	for _, iface := range m.interfaceSet {
+		iface.bind(c)
+	}
+	for _, b := range m.physicalBlockDevices {
+		b.bind(c)
+	}
+	for _, b := range m.blockDevices {
+		b.bind(c)
+	}
 }
 
 // SystemID implements Machine.
@@ -79,6 +150,17 @@ func (m *machine) FQDN() string {
 	return m.fqdn
 }
 
+// NodeType implements GenericNode. The machines endpoint only lists
+// machines, so it is always NodeTypeMachine.
+func (m *machine) NodeType() int {
+	return NodeTypeMachine
+}
+
+// NodeTypeName implements GenericNode.
+func (m *machine) NodeTypeName() string {
+	return "Machine"
+}
+
 // Tags implements Machine.
 func (m *machine) Tags() []string {
 	return m.tags
@@ -89,6 +171,28 @@ func (m *machine) IPAddresses() []string {
 	return m.ipAddresses
 }
 
+// IPAddrs implements Machine.
+func (m *machine) IPAddrs() []netip.Addr {
+	return m.ipAddrs
+}
+
+func (m *machine) addressErr() error {
+	if m.addrErr != nil {
+		return m.addrErr
+	}
+	if m.bootInterface != nil {
+		if err := m.bootInterface.addressErr(); err != nil {
+			return err
+		}
+	}
+	for _, iface := range m.interfaceSet {
+		if err := iface.addressErr(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Memory implements Machine.
 func (m *machine) Memory() int {
 	return m.memory
@@ -99,6 +203,15 @@ func (m *machine) CPUCount() int {
 	return m.cpuCount
 }
 
+// HardwareInfo implements Machine.
+func (m *machine) HardwareInfo() map[string]string {
+	result := make(map[string]string, len(m.hardwareInfo))
+	for key, value := range m.hardwareInfo {
+		result[key] = value
+	}
+	return result
+}
+
 // PowerState implements Machine.
 func (m *machine) PowerState() string {
 	return m.powerState
@@ -112,20 +225,175 @@ func (m *machine) Zone() Zone {
 	return m.zone
 }
 
+// Pool implements Machine.
+func (m *machine) Pool() Pool {
+	if m.pool == nil {
+		return nil
+	}
+	return m.pool
+}
+
+// Owner implements Machine.
+func (m *machine) Owner() string {
+	return m.owner
+}
+
+// Locked implements Machine.
+func (m *machine) Locked() bool {
+	return m.locked
+}
+
+// AddressTTL implements Machine.
+func (m *machine) AddressTTL() int {
+	return m.addressTTL
+}
+
+// SwapSize implements Machine.
+func (m *machine) SwapSize() uint64 {
+	return m.swapSize
+}
+
+// SetSwapSize implements Machine.
+//
+// Returns
+//  - BadRequestError if the server rejects the size
+//  - CannotCompleteError if the machine isn't in a state that allows the change
+//  - PermissionError if the user does not have permission to change the machine
+//  - NoMatchError if the machine cannot be found
+func (m *machine) SetSwapSize(bytes uint64) error {
+	params := make(url.Values)
+	if bytes == 0 {
+		// An empty size lets MAAS choose it.
+		params.Add("swap_size", "")
+	} else {
+		params.Add("swap_size", strconv.FormatUint(bytes, 10))
+	}
+	return errors.Trace(m.update(params))
+}
+
+// TestResultsSummary is the status of the commissioning and testing of a
+// machine, and of the tests of each kind of hardware, as status names such
+// as "Passed" or "Failed". A status is empty if MAAS doesn't report it.
+type TestResultsSummary struct {
+	Commissioning string
+	Testing       string
+	CPU           string
+	Memory        string
+	Storage       string
+	Network       string
+	Interface     string
+	Other         string
+}
+
+// statuses returns the fields of the summary by the names of the machine
+// fields they are read from.
+func (s *TestResultsSummary) statuses() map[string]*string {
+	return map[string]*string{
+		"commissioning_status_name":  &s.Commissioning,
+		"testing_status_name":        &s.Testing,
+		"cpu_test_status_name":       &s.CPU,
+		"memory_test_status_name":    &s.Memory,
+		"storage_test_status_name":   &s.Storage,
+		"network_test_status_name":   &s.Network,
+		"interface_test_status_name": &s.Interface,
+		"other_test_status_name":     &s.Other,
+	}
+}
+
+// TestResultsSummary implements Machine.
+func (m *machine) TestResultsSummary() TestResultsSummary {
+	return m.testResults
+}
+
+// CommissioningResultID implements Machine.
+func (m *machine) CommissioningResultID() int {
+	return m.commissioningResultID
+}
+
+// TestingResultID implements Machine.
+func (m *machine) TestingResultID() int {
+	return m.testingResultID
+}
+
+// InstallationResultID implements Machine.
+func (m *machine) InstallationResultID() int {
+	return m.installationResultID
+}
+
+// LastImageSync implements Machine.
+func (m *machine) LastImageSync() time.Time {
+	return m.lastImageSync
+}
+
+// Netboot implements Machine.
+func (m *machine) Netboot() bool {
+	return m.netboot
+}
+
+// SetNetboot implements Machine.
+//
+// Returns
+//  - BadRequestError if the server rejects the change
+//  - CannotCompleteError if the machine isn't in a state that allows the change
+//  - PermissionError if the user does not have permission to change the machine
+//  - NoMatchError if the machine cannot be found
+func (m *machine) SetNetboot(netboot bool) error {
+	params := make(url.Values)
+	params.Add("netboot", fmt.Sprint(netboot))
+	return errors.Trace(m.update(params))
+}
+
 // BootInterface implements Machine.
 func (m *machine) BootInterface() Interface {
 	if m.bootInterface == nil {
 		return nil
 	}
-	m.bootInterface.controller = m.controller
 	return m.bootInterface
 }
 
+// SetBootInterface implements Machine.
+//
+// Returns
+//  - UnsupportedVersionError if the controller is older than MAAS 2.5
+//  - NotValid error if the interface isn't one of the machine's
+//  - BadRequestError if the server rejects the interface
+//  - CannotCompleteError if the machine isn't in a state that allows the change
+//  - PermissionError if the user does not have permission to change the machine
+//  - NoMatchError if the machine cannot be found
+func (m *machine) SetBootInterface(ifaceID int) error {
+	if m.controller.schemaVersion().Compare(twoDotFive) < 0 {
+		return NewUnsupportedVersionError("setting the boot interface needs MAAS %s, controller is %s", twoDotFive, m.controller.schemaVersion())
+	}
+	if m.Interface(ifaceID) == nil {
+		return errors.NotValidf("interface %d of machine %q", ifaceID, m.systemID)
+	}
+	params := make(url.Values)
+	params.Add("boot_interface", fmt.Sprint(ifaceID))
+	return errors.Trace(m.update(params))
+}
+
+// update changes the machine with a PUT of the params, and updates it from
+// the response.
+func (m *machine) update(params url.Values) error {
+	result, err := m.controller.put(m.resourceURI, params)
+	if err != nil {
+		return translateError(opEntity, err)
+	}
+	machine, err := readMachine(m.controller.schemaVersion(), result)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err = m.controller.checkDecoded("machine", result, machine); err != nil {
+		return errors.Trace(err)
+	}
+	m.updateFrom(machine)
+	return nil
+}
+
 // InterfaceSet implements Machine.
 func (m *machine) InterfaceSet() []Interface {
 	result := make([]Interface, len(m.interfaceSet))
 	for i, v := range m.interfaceSet {
-		v.controller = m.controller
 		result[i] = v
 	}
 	return result
@@ -135,13 +403,77 @@ func (m *machine) InterfaceSet() []Interface {
 func (m *machine) Interface(id int) Interface {
 	for _, iface := range m.interfaceSet {
 		if iface.ID() == id {
-			iface.controller = m.controller
 			return iface
 		}
 	}
 	return nil
 }
 
+// SRIOVCapableInterfaces implements Machine.
+func (m *machine) SRIOVCapableInterfaces() []Interface {
+	var result []Interface
+	for _, iface := range m.interfaceSet {
+		if iface.type_ == "physical" && iface.sriovMaxVF > 0 {
+			result = append(result, iface)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].SRIOVMaxVF() > result[j].SRIOVMaxVF()
+	})
+	return result
+}
+
+// PrimarySubnet implements Machine.
+func (m *machine) PrimarySubnet() Subnet {
+	interfaces := m.interfaceSet
+	if m.bootInterface != nil {
+		interfaces = append([]*interface_{m.bootInterface}, interfaces...)
+	}
+	for _, iface := range interfaces {
+		for _, link := range iface.links {
+			if link.subnet != nil {
+				return link.subnet
+			}
+		}
+	}
+	return nil
+}
+
+// linksInSpace returns the links of the machine's interfaces to subnets in
+// the space.
+func (m *machine) linksInSpace(space string) []*link {
+	var result []*link
+	for _, iface := range m.interfaceSet {
+		for _, link := range iface.links {
+			if link.subnet != nil && link.subnet.space == space {
+				result = append(result, link)
+			}
+		}
+	}
+	return result
+}
+
+// GatewayFor implements Machine.
+func (m *machine) GatewayFor(space string) (string, error) {
+	for _, link := range m.linksInSpace(space) {
+		if link.subnet.gateway != "" {
+			return link.subnet.gateway, nil
+		}
+	}
+	return "", NewNoMatchError(fmt.Sprintf("no gateway in space %q for machine %q", space, m.systemID))
+}
+
+// AddressesInSpace implements Machine.
+func (m *machine) AddressesInSpace(space string) []string {
+	var result []string
+	for _, link := range m.linksInSpace(space) {
+		if link.ipAddress != "" {
+			result = append(result, link.ipAddress)
+		}
+	}
+	return result
+}
+
 // OperatingSystem implements Machine.
 func (m *machine) OperatingSystem() string {
 	return m.operatingSystem
@@ -195,6 +527,88 @@ func (m *machine) BlockDevice(id int) BlockDevice {
 	return blockDeviceById(id, m.BlockDevices())
 }
 
+// SpecialFilesystems implements Machine.
+func (m *machine) SpecialFilesystems() []FileSystem {
+	result := make([]FileSystem, len(m.specialFilesystems))
+	for i, v := range m.specialFilesystems {
+		result[i] = v
+	}
+	return result
+}
+
+// MountSpecialArgs is an argument struct for calling
+// Machine.MountSpecialFilesystem.
+type MountSpecialArgs struct {
+	// FSType is required, and is a filesystem that isn't backed by a
+	// block device, e.g. "tmpfs" or "ramfs".
+	FSType string
+	// MountPoint is required, and is an absolute path.
+	MountPoint string
+	// MountOptions are optional, e.g. "size=1G".
+	MountOptions string
+}
+
+// Validate checks the args, returning a NotValid error if the FSType or
+// MountPoint is missing.
+func (a *MountSpecialArgs) Validate() error {
+	if a.FSType == "" {
+		return errors.NotValidf("missing FSType")
+	}
+	if a.MountPoint == "" {
+		return errors.NotValidf("missing MountPoint")
+	}
+	return nil
+}
+
+// MountSpecialFilesystem implements Machine.
+//
+// Returns
+//  - NotValid error if the args are not valid
+//  - BadRequestError if the server rejects the filesystem or mount point
+//  - CannotCompleteError if the machine isn't Ready or Allocated
+//  - PermissionError if the user does not have permission to change the machine
+//  - NoMatchError if the machine cannot be found
+func (m *machine) MountSpecialFilesystem(args MountSpecialArgs) error {
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	params := NewURLParams()
+	params.Values.Add("fstype", args.FSType)
+	params.Values.Add("mount_point", args.MountPoint)
+	params.MaybeAdd("mount_options", args.MountOptions)
+	return m.postStorageOp("mount_special", params.Values)
+}
+
+// UnmountSpecial implements Machine.
+//
+// Returns the same errors as MountSpecialFilesystem.
+func (m *machine) UnmountSpecial(mountPoint string) error {
+	if mountPoint == "" {
+		return errors.NotValidf("missing mount point")
+	}
+	params := make(url.Values)
+	params.Add("mount_point", mountPoint)
+	return m.postStorageOp("unmount_special", params)
+}
+
+// postStorageOp calls the op on the machine, and updates the machine from
+// the response.
+func (m *machine) postStorageOp(op string, params url.Values) error {
+	result, err := m.controller.post(m.resourceURI, op, params)
+	if err != nil {
+		return translateError(opEntity, err)
+	}
+	machine, err := readMachine(m.controller.schemaVersion(), result)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err = m.controller.checkDecoded("machine", result, machine); err != nil {
+		return errors.Trace(err)
+	}
+	m.updateFrom(machine)
+	return nil
+}
+
 func blockDeviceById(id int, blockDevices []BlockDevice) BlockDevice {
 	for _, blockDevice := range blockDevices {
 		if blockDevice.ID() == id {
@@ -206,21 +620,51 @@ func blockDeviceById(id int, blockDevices []BlockDevice) BlockDevice {
 
 // Devices implements Machine.
 func (m *machine) Devices(args DevicesArgs) ([]Device, error) {
-	// Perhaps in the future, MAAS will give us a way to query just for the
-	// devices for a particular parent.
+	args.Parent = m.SystemID()
 	devices, err := m.controller.Devices(args)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
-	var result []Device
+	return devices, nil
+}
+
+// DeviceBySystemID implements Machine.
+//
+// Returns
+//  - NoMatchError if the machine has no device with the system ID
+func (m *machine) DeviceBySystemID(systemID string) (Device, error) {
+	devices, err := m.controller.Devices(DevicesArgs{SystemIDs: []string{systemID}, Parent: m.SystemID()})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
 	for _, device := range devices {
-		if device.Parent() == m.SystemID() {
-			result = append(result, device)
+		if device.SystemID() == systemID && device.Parent() == m.SystemID() {
+			return device, nil
 		}
 	}
-	return result, nil
+	return nil, NewNoMatchError(fmt.Sprintf("machine %q has no device %q", m.systemID, systemID))
 }
 
+// Operating systems and Ubuntu series for StartArgs.DistroSeries, which is
+// either a series, taken to be an Ubuntu one, or an operating system and
+// series, such as "centos/centos70".
+const (
+	OSUbuntu  = "ubuntu"
+	OSCentOS  = "centos"
+	OSRHEL    = "rhel"
+	OSWindows = "windows"
+	OSCustom  = "custom"
+
+	SeriesBionic = "bionic"
+	SeriesFocal  = "focal"
+	SeriesJammy  = "jammy"
+	SeriesNoble  = "noble"
+)
+
+// distroSeriesRE matches a series, optionally qualified by the operating
+// system.
+var distroSeriesRE = regexp.MustCompile(`^([0-9A-Za-z][0-9A-Za-z_-]*/)?[0-9A-Za-z][0-9A-Za-z._-]*$`)
+
 // StartArgs is an argument struct for passing parameters to the Machine.Start
 // method.
 type StartArgs struct {
@@ -229,38 +673,109 @@ type StartArgs struct {
 	DistroSeries string
 	Kernel       string
 	Comment      string
+	// CheckBootImages asks Start to check that MAAS has boot images of the
+	// DistroSeries for the machine's architecture before deploying it,
+	// which costs a request.
+	CheckBootImages bool
+	// Ephemeral deploys the operating system in memory, leaving the disks
+	// of the machine untouched, as for diskless burn-in. It needs MAAS 3.2
+	// or later.
+	Ephemeral bool
+	// EnableHWSync keeps the hardware of the deployed machine in sync with
+	// MAAS, which polls it periodically. It needs MAAS 3.2 or later.
+	EnableHWSync bool
+}
+
+// Validate checks that the UserData is base64 encoded, and that the
+// DistroSeries and Kernel, if set, are names MAAS could accept.
+func (a *StartArgs) Validate() error {
+	if _, err := base64.StdEncoding.DecodeString(a.UserData); err != nil {
+		return errors.NewNotValid(err, "UserData not base64 encoded")
+	}
+	if a.DistroSeries != "" && !distroSeriesRE.MatchString(a.DistroSeries) {
+		return errors.NotValidf("DistroSeries %q", a.DistroSeries)
+	}
+	if a.Kernel != "" && !hweKernelRE.MatchString(a.Kernel) {
+		return errors.NotValidf("Kernel %q", a.Kernel)
+	}
+	if a.CheckBootImages && a.DistroSeries == "" {
+		return errors.NotValidf("CheckBootImages without DistroSeries")
+	}
+	return nil
 }
 
 // Start implements Machine.
+//
+// Returns
+//  - NotValid error if the args aren't valid, or MAAS has no boot images
+//    of the DistroSeries for the machine when CheckBootImages is set
+//  - NotSupported error if Ephemeral or EnableHWSync is set and MAAS is
+//    older than 3.2
+//  - BadRequestError if the machine cannot be found or isn't allocated
+//  - PermissionError if the user does not have permission to deploy it
+//  - CannotCompleteError if MAAS can't deploy the machine now
 func (m *machine) Start(args StartArgs) error {
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	if args.Ephemeral {
+		if err := m.controller.requireRelease(3, 2, "ephemeral deployment"); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if args.EnableHWSync {
+		if err := m.controller.requireRelease(3, 2, "hardware sync"); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if args.CheckBootImages {
+		if err := m.checkBootImages(args.DistroSeries); err != nil {
+			return errors.Trace(err)
+		}
+	}
 	params := NewURLParams()
 	params.MaybeAdd("user_data", args.UserData)
 	params.MaybeAdd("distro_series", args.DistroSeries)
 	params.MaybeAdd("hwe_kernel", args.Kernel)
 	params.MaybeAdd("comment", args.Comment)
+	params.MaybeAddBool("ephemeral_deploy", args.Ephemeral)
+	params.MaybeAddBool("enable_hw_sync", args.EnableHWSync)
 	result, err := m.controller.post(m.resourceURI, "deploy", params.Values)
 	if err != nil {
-		if svrErr, ok := errors.Cause(err).(ServerError); ok {
-			switch svrErr.StatusCode {
-			case http.StatusNotFound, http.StatusConflict:
-				return errors.Wrap(err, NewBadRequestError(svrErr.BodyMessage))
-			case http.StatusForbidden:
-				return errors.Wrap(err, NewPermissionError(svrErr.BodyMessage))
-			case http.StatusServiceUnavailable:
-				return errors.Wrap(err, NewCannotCompleteError(svrErr.BodyMessage))
-			}
-		}
-		return NewUnexpectedError(err)
+		return translateError(opReference, err)
 	}
 
-	machine, err := readMachine(m.controller.apiVersion, result)
+	machine, err := readMachine(m.controller.schemaVersion(), result)
 	if err != nil {
 		return errors.Trace(err)
 	}
+	if err = m.controller.checkDecoded("machine", result, machine); err != nil {
+		return errors.Trace(err)
+	}
 	m.updateFrom(machine)
 	return nil
 }
 
+// checkBootImages returns a NotValid error if MAAS has no boot resources
+// of the distro series for the architecture of the machine.
+func (m *machine) checkBootImages(distroSeries string) error {
+	name := distroSeries
+	if !strings.Contains(name, "/") {
+		name = OSUbuntu + "/" + name
+	}
+	arch := strings.SplitN(m.architecture, "/", 2)[0]
+	resources, err := m.controller.BootResources()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, r := range resources {
+		if r.Name() == name && strings.SplitN(r.Architecture(), "/", 2)[0] == arch {
+			return nil
+		}
+	}
+	return errors.NotValidf("DistroSeries %q without boot images for %s", distroSeries, arch)
+}
+
 // CreateMachineDeviceArgs is an argument structure for Machine.CreateDevice.
 // Only InterfaceName and MACAddress fields are required, the others are only
 // used if set. If Subnet and VLAN are both set, Subnet.VLAN() must match the
@@ -312,7 +827,7 @@ func (m *machine) CreateDevice(args CreateMachineDeviceArgs) (_ Device, err erro
 		// If there is an error return, at least try to delete the device we just created.
 		if *err != nil {
 			if innerErr := device.Delete(); innerErr != nil {
-				logger.Warningf("could not delete device %q", device.SystemID())
+				m.controller.logger.Warnf("could not delete device %q", device.SystemID())
 			}
 		}
 	}(&err)
@@ -328,7 +843,7 @@ func (m *machine) CreateDevice(args CreateMachineDeviceArgs) (_ Device, err erro
 	interfaces := device.InterfaceSet()
 	if count := len(interfaces); count != 1 {
 		err := errors.Errorf("unexpected interface count for device: %d", count)
-		return nil, NewUnexpectedError(err)
+		return nil, translateError(opEntity, err)
 	}
 	iface := interfaces[0]
 	nameToUse := args.InterfaceName
@@ -394,17 +909,249 @@ func (m *machine) SetOwnerData(ownerData map[string]string) error {
 		params.Add(key, value)
 	}
 	result, err := m.controller.post(m.resourceURI, "set_owner_data", params)
+	if err != nil {
+		return translateError(opEntity, err)
+	}
+	machine, err := readMachine(m.controller.schemaVersion(), result)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err = m.controller.checkDecoded("machine", result, machine); err != nil {
+		return errors.Trace(err)
+	}
+	m.updateFrom(machine)
+	return nil
+}
+
+// Description implements Machine.
+func (m *machine) Description() string {
+	return m.description
+}
+
+// WorkloadAnnotations implements Machine.
+func (m *machine) WorkloadAnnotations() map[string]string {
+	result := make(map[string]string)
+	for key, value := range m.workloadAnnotations {
+		result[key] = value
+	}
+	return result
+}
+
+// SetWorkloadAnnotations implements Machine.
+//
+// Returns
+//  - NotSupported error if MAAS is older than 2.9
+//  - BadRequestError if the server rejects the annotations
+//  - CannotCompleteError if the machine isn't allocated or deployed
+//  - PermissionError if the user does not have permission to change the machine
+//  - NoMatchError if the machine cannot be found
+func (m *machine) SetWorkloadAnnotations(annotations map[string]string) error {
+	if err := m.controller.requireRelease(2, 9, "workload annotations"); err != nil {
+		return errors.Trace(err)
+	}
+	params := make(url.Values)
+	for key, value := range annotations {
+		params.Add(key, value)
+	}
+	result, err := m.controller.post(m.resourceURI, "set_workload_annotations", params)
+	if err != nil {
+		return translateError(opEntity, err)
+	}
+	machine, err := readMachine(m.controller.schemaVersion(), result)
 	if err != nil {
 		return errors.Trace(err)
 	}
-	machine, err := readMachine(m.controller.apiVersion, result)
+	if err = m.controller.checkDecoded("machine", result, machine); err != nil {
+		return errors.Trace(err)
+	}
+	m.updateFrom(machine)
+	return nil
+}
+
+// CloneTo implements Machine.
+//
+// Returns
+//  - NotValid error if there are no destinations or nothing to clone
+//  - BadRequestError if the server rejects the destinations
+//  - PermissionError if the user does not have permission to clone the machine
+//  - NoMatchError if the machine cannot be found
+func (m *machine) CloneTo(destinations []string, cloneStorage, cloneNetwork bool) error {
+	if len(destinations) == 0 {
+		return errors.NotValidf("missing destinations")
+	}
+	if !cloneStorage && !cloneNetwork {
+		return errors.NotValidf("nothing to clone")
+	}
+	params := NewURLParams()
+	params.MaybeAdd("source", m.systemID)
+	params.MaybeAddMany("destinations", destinations)
+	params.MaybeAddBool("storage", cloneStorage)
+	params.MaybeAddBool("interfaces", cloneNetwork)
+	_, err := m.controller._postRaw("machines", "clone", params.Values, nil)
+	if err != nil {
+		return translateError(opEntity, err)
+	}
+	return nil
+}
+
+// SetStorageLayout implements Machine.
+//
+// Returns
+//  - NotValid error if the layout isn't one of StorageLayouts
+//  - BadRequestError if the server rejects the options
+//  - CannotCompleteError if the machine isn't Ready or Allocated
+//  - PermissionError if the user does not have permission to change the machine
+//  - NoMatchError if the machine cannot be found
+func (m *machine) SetStorageLayout(layout string, options map[string]string) error {
+	if err := validateStorageLayout(layout); err != nil {
+		return errors.Trace(err)
+	}
+	params := NewURLParams()
+	params.Values.Add("storage_layout", layout)
+	for key, value := range options {
+		if key == "storage_layout" {
+			return errors.NotValidf("option %q", key)
+		}
+		params.Values.Add(key, value)
+	}
+	result, err := m.controller.post(m.resourceURI, "set_storage_layout", params.Values)
+	if err != nil {
+		return translateError(opEntity, err)
+	}
+	machine, err := readMachine(m.controller.schemaVersion(), result)
 	if err != nil {
 		return errors.Trace(err)
 	}
+	if err = m.controller.checkDecoded("machine", result, machine); err != nil {
+		return errors.Trace(err)
+	}
 	m.updateFrom(machine)
 	return nil
 }
 
+// RestoreNetworkingConfiguration implements Machine.
+func (m *machine) RestoreNetworkingConfiguration() error {
+	return m.restoreConfiguration("restore_networking_configuration")
+}
+
+// RestoreStorageConfiguration implements Machine.
+func (m *machine) RestoreStorageConfiguration() error {
+	return m.restoreConfiguration("restore_storage_configuration")
+}
+
+// RestoreDefaultConfiguration implements Machine.
+func (m *machine) RestoreDefaultConfiguration() error {
+	return m.restoreConfiguration("restore_default_configuration")
+}
+
+// restoreConfiguration calls the restore op, and updates the machine from
+// the response.
+//
+// Returns
+//  - CannotCompleteError if the machine isn't Ready or Broken
+//  - PermissionError if the user does not have permission to change the machine
+//  - NoMatchError if the machine cannot be found
+func (m *machine) restoreConfiguration(op string) error {
+	result, err := m.controller.post(m.resourceURI, op, nil)
+	if err != nil {
+		return translateError(opEntity, err)
+	}
+	machine, err := readMachine(m.controller.schemaVersion(), result)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err = m.controller.checkDecoded("machine", result, machine); err != nil {
+		return errors.Trace(err)
+	}
+	m.updateFrom(machine)
+	return nil
+}
+
+// installationLogNames are the names that MAAS has used for the curtin
+// installation log in the installation results.
+var installationLogNames = set.NewStrings("install.log", "/tmp/install.log")
+
+// InstallationLog implements Machine.
+//
+// Returns
+//  - NoMatchError if there is no installation log for the machine
+//  - PermissionError if the user does not have permission to read the results
+func (m *machine) InstallationLog() ([]byte, error) {
+	if !m.controller.hasRelease(2, 2) {
+		return m.legacyInstallationLog()
+	}
+	// Since MAAS 2.2 the log is the output of the installation script
+	// result.
+	var buffer bytes.Buffer
+	if err := m.DownloadInstallationOutput(&buffer); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return buffer.Bytes(), nil
+}
+
+// legacyInstallationLog reads the log from the installation results, which
+// MAAS replaced with the node script results in 2.2.
+func (m *machine) legacyInstallationLog() ([]byte, error) {
+	params := NewURLParams()
+	params.MaybeAdd("system_id", m.systemID)
+	source, err := m.controller.getQuery("installation-results", params.Values)
+	if err != nil {
+		return nil, translateError(opEntity, err)
+	}
+
+	fields := schema.Fields{
+		"name": schema.String(),
+		"data": schema.String(),
+	}
+	checker := schema.List(schema.FieldMap(fields, nil))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "installation results schema check failed")
+	}
+	// If the machine has been deployed more than once, the last log is the
+	// most recent.
+	var data string
+	found := false
+	for _, result := range coerced.([]interface{}) {
+		valid := result.(map[string]interface{})
+		if installationLogNames.Contains(valid["name"].(string)) {
+			data = valid["data"].(string)
+			found = true
+		}
+	}
+	if !found {
+		return nil, NewNoMatchError(fmt.Sprintf("no installation log for machine %q", m.systemID))
+	}
+	content, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "installation log")
+	}
+	return content, nil
+}
+
+// CurtinConfig implements Machine.
+//
+// Returns
+//  - BadRequestError if the machine is not being or has not been deployed
+//  - PermissionError if the user does not have permission to read the config
+//  - NoMatchError if the machine cannot be found
+func (m *machine) CurtinConfig() ([]byte, error) {
+	content, err := m.controller._getRaw(m.resourceURI, "get_curtin_config", nil)
+	if err != nil {
+		return nil, translateError(opEntity, err)
+	}
+	return content, nil
+}
+
+// Delete implements Machine.
+func (m *machine) Delete() error {
+	err := m.controller.delete(m.resourceURI)
+	if err != nil {
+		return translateError(opEntity, err)
+	}
+	return nil
+}
+
 func readMachine(controllerVersion version.Number, source interface{}) (*machine, error) {
 	readFunc, err := getMachineDeserializationFunc(controllerVersion)
 	if err != nil {
@@ -457,7 +1204,7 @@ func readMachineList(sourceList []interface{}, readFunc machineDeserializationFu
 		}
 		machine, err := readFunc(source)
 		if err != nil {
-			return nil, errors.Annotatef(err, "machine %d", i)
+			return nil, annotateListItem(err, "machine", i, source)
 		}
 		result = append(result, machine)
 	}
@@ -467,26 +1214,33 @@ func readMachineList(sourceList []interface{}, readFunc machineDeserializationFu
 type machineDeserializationFunc func(map[string]interface{}) (*machine, error)
 
 var machineDeserializationFuncs = map[version.Number]machineDeserializationFunc{
-	twoDotOh: machine_2_0,
+	twoDotOh:    machine_2_0,
+	twoDotThree: machine_2_3,
+	twoDotFive:  machine_2_5,
+	twoDotNine:  machine_2_9,
 }
 
-func machine_2_0(source map[string]interface{}) (*machine, error) {
+var machine_2_0Checker = func() schema.Checker {
 	fields := schema.Fields{
 		"resource_uri": schema.String(),
 
-		"system_id":  schema.String(),
-		"hostname":   schema.String(),
-		"fqdn":       schema.String(),
-		"tag_names":  schema.List(schema.String()),
-		"owner_data": schema.StringMap(schema.String()),
+		"system_id":   schema.String(),
+		"hostname":    schema.String(),
+		"fqdn":        schema.String(),
+		"tag_names":   schema.OneOf(schema.Nil(""), schema.List(schema.String())),
+		"owner":       schema.OneOf(schema.Nil(""), schema.String()),
+		"owner_data":  schema.StringMap(schema.String()),
+		"description": schema.OneOf(schema.Nil(""), schema.String()),
 
 		"osystem":       schema.String(),
 		"distro_series": schema.String(),
 		"architecture":  schema.OneOf(schema.Nil(""), schema.String()),
 		"memory":        schema.ForceInt(),
 		"cpu_count":     schema.ForceInt(),
+		"hardware_info": schema.StringMap(schema.String()),
 
-		"ip_addresses":   schema.List(schema.String()),
+		"ip_addresses":   schema.OneOf(schema.Nil(""), schema.List(schema.String())),
+		"address_ttl":    schema.OneOf(schema.Nil(""), schema.ForceInt()),
 		"power_state":    schema.String(),
 		"status_name":    schema.String(),
 		"status_message": schema.OneOf(schema.Nil(""), schema.String()),
@@ -497,12 +1251,48 @@ func machine_2_0(source map[string]interface{}) (*machine, error) {
 
 		"physicalblockdevice_set": schema.List(schema.StringMap(schema.Any())),
 		"blockdevice_set":         schema.List(schema.StringMap(schema.Any())),
+		"special_filesystems":     schema.List(schema.StringMap(schema.Any())),
+
+		"swap_size": schema.OneOf(schema.Nil(""), schema.ForceUint()),
+		"netboot":   schema.Bool(),
+
+		"current_commissioning_result_id": schema.OneOf(schema.Nil(""), schema.ForceInt()),
+		"current_testing_result_id":       schema.OneOf(schema.Nil(""), schema.ForceInt()),
+		"current_installation_result_id":  schema.OneOf(schema.Nil(""), schema.ForceInt()),
+		"last_image_sync":                 schema.OneOf(schema.Nil(""), schema.String()),
 	}
 	defaults := schema.Defaults{
 		"architecture": "",
+		"owner":        "",
+		// The description isn't in the responses of all MAAS versions.
+		"description": schema.Omit,
+		// Nor is the hardware info, which is only there once the machine
+		// has been commissioned.
+		"hardware_info": schema.Omit,
+		// Special filesystems were added in MAAS 2.3.
+		"special_filesystems": schema.Omit,
+		// Not every MAAS version reports the address TTL, swap size and
+		// netboot of a machine.
+		"address_ttl": schema.Omit,
+		"swap_size":   schema.Omit,
+		"netboot":     schema.Omit,
+		// Nor the results of the scripts run on it, which were added in
+		// MAAS 2.2.
+		"current_commissioning_result_id": schema.Omit,
+		"current_testing_result_id":       schema.Omit,
+		"current_installation_result_id":  schema.Omit,
+		"last_image_sync":                 schema.Omit,
 	}
-	checker := schema.FieldMap(fields, defaults)
-	coerced, err := checker.Coerce(source, nil)
+	var testResults TestResultsSummary
+	for name := range testResults.statuses() {
+		fields[name] = schema.String()
+		defaults[name] = schema.Omit
+	}
+	return fieldMap("machine", fields, defaults)
+}()
+
+func machine_2_0(source map[string]interface{}) (*machine, error) {
+	coerced, err := machine_2_0Checker.Coerce(source, nil)
 	if err != nil {
 		return nil, WrapWithDeserializationError(err, "machine 2.0 schema check failed")
 	}
@@ -534,8 +1324,38 @@ func machine_2_0(source map[string]interface{}) (*machine, error) {
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
+	var specialFilesystems []*filesystem
+	if list, ok := valid["special_filesystems"].([]interface{}); ok {
+		for i, value := range list {
+			fs, err := filesystem2_0(value.(map[string]interface{}))
+			if err != nil {
+				return nil, annotateListItem(err, "special filesystem", i, value.(map[string]interface{}))
+			}
+			specialFilesystems = append(specialFilesystems, fs)
+		}
+	}
 	architecture, _ := valid["architecture"].(string)
+	owner, _ := valid["owner"].(string)
 	statusMessage, _ := valid["status_message"].(string)
+	description, _ := valid["description"].(string)
+	addressTTL, _ := valid["address_ttl"].(int)
+	swapSize, _ := valid["swap_size"].(uint64)
+	netboot, _ := valid["netboot"].(bool)
+	var testResults TestResultsSummary
+	for name, status := range testResults.statuses() {
+		*status, _ = valid[name].(string)
+	}
+	commissioningResultID, _ := valid["current_commissioning_result_id"].(int)
+	testingResultID, _ := valid["current_testing_result_id"].(int)
+	installationResultID, _ := valid["current_installation_result_id"].(int)
+	var lastImageSync time.Time
+	if value, _ := valid["last_image_sync"].(string); value != "" {
+		if lastImageSync, err = parseTime(value); err != nil {
+			return nil, WrapWithDeserializationError(err, "machine last_image_sync")
+		}
+	}
+	ipAddresses := convertToStringSlice(valid["ip_addresses"])
+	ipAddrs, addrErr := parseAddrs(ipAddresses)
 	result := &machine{
 		resourceURI: valid["resource_uri"].(string),
 
@@ -543,32 +1363,123 @@ func machine_2_0(source map[string]interface{}) (*machine, error) {
 		hostname:  valid["hostname"].(string),
 		fqdn:      valid["fqdn"].(string),
 		tags:      convertToStringSlice(valid["tag_names"]),
+		owner:     owner,
 		ownerData: convertToStringMap(valid["owner_data"]),
 
+		description: description,
+
 		operatingSystem: valid["osystem"].(string),
 		distroSeries:    valid["distro_series"].(string),
 		architecture:    architecture,
 		memory:          valid["memory"].(int),
 		cpuCount:        valid["cpu_count"].(int),
+		hardwareInfo:    convertToStringMap(valid["hardware_info"]),
 
-		ipAddresses:   convertToStringSlice(valid["ip_addresses"]),
+		ipAddresses:   ipAddresses,
+		ipAddrs:       ipAddrs,
+		addrErr:       addrErr,
 		powerState:    valid["power_state"].(string),
+		addressTTL:    addressTTL,
 		statusName:    valid["status_name"].(string),
 		statusMessage: statusMessage,
 
+		testResults:           testResults,
+		commissioningResultID: commissioningResultID,
+		testingResultID:       testingResultID,
+		installationResultID:  installationResultID,
+		lastImageSync:         lastImageSync,
+
+		swapSize: swapSize,
+		netboot:  netboot,
+
 		bootInterface:        bootInterface,
 		interfaceSet:         interfaceSet,
 		zone:                 zone,
 		physicalBlockDevices: physicalBlockDevices,
 		blockDevices:         blockDevices,
+		specialFilesystems:   specialFilesystems,
+	}
+
+	return result, nil
+}
+
+var machine_2_3Checker = fieldMap("machine", schema.Fields{
+	"pool": schema.OneOf(schema.Nil(""), schema.StringMap(schema.Any())),
+}, nil) // no defaults
+
+// machine_2_3 reads the machine fields added in MAAS 2.3 on top of those
+// read by machine_2_0.
+func machine_2_3(source map[string]interface{}) (*machine, error) {
+	result, err := machine_2_0(source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	coerced, err := machine_2_3Checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "machine 2.3 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	if poolMap, ok := valid["pool"].(map[string]interface{}); ok {
+		result.pool, err = pool_2_3(poolMap)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	return result, nil
+}
+
+var machine_2_5Checker = fieldMap("machine", schema.Fields{
+	"locked": schema.Bool(),
+}, nil) // no defaults
+
+// machine_2_5 reads the machine fields added in MAAS 2.5 on top of those
+// read by machine_2_3.
+func machine_2_5(source map[string]interface{}) (*machine, error) {
+	result, err := machine_2_3(source)
+	if err != nil {
+		return nil, errors.Trace(err)
 	}
+	coerced, err := machine_2_5Checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "machine 2.5 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
 
+	result.locked = valid["locked"].(bool)
 	return result, nil
 }
 
+var machine_2_9Checker = fieldMap("machine", schema.Fields{
+	"workload_annotations": schema.StringMap(schema.String()),
+}, nil) // no defaults
+
+// machine_2_9 reads the machine fields added in MAAS 2.9 on top of those
+// read by machine_2_5.
+func machine_2_9(source map[string]interface{}) (*machine, error) {
+	result, err := machine_2_5(source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	coerced, err := machine_2_9Checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "machine 2.9 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+
+	result.workloadAnnotations = convertToStringMap(valid["workload_annotations"])
+	return result, nil
+}
+
+// convertToStringSlice converts a list field that has passed a schema
+// check. A missing or null list is converted to an empty slice, never nil,
+// so that all the slices returned by the API types can be ranged over and
+// compared the same way.
 func convertToStringSlice(field interface{}) []string {
 	if field == nil {
-		return nil
+		return []string{}
 	}
 	fieldSlice := field.([]interface{})
 	result := make([]string, len(fieldSlice))
@@ -4,8 +4,11 @@
 package gomaasapi
 
 import (
+	"bytes"
 	"fmt"
 	"net/http"
+	"net/netip"
+	"time"
 
 	"github.com/juju/errors"
 	"github.com/juju/testing"
@@ -52,6 +55,8 @@ func (*machineSuite) TestReadMachines(c *gc.C) {
 	c.Check(machine.SystemID(), gc.Equals, "4y3ha3")
 	c.Check(machine.Hostname(), gc.Equals, "untasted-markita")
 	c.Check(machine.FQDN(), gc.Equals, "untasted-markita.maas")
+	c.Check(machine.NodeType(), gc.Equals, NodeTypeMachine)
+	c.Check(machine.NodeTypeName(), gc.Equals, "Machine")
 	c.Check(machine.Tags(), jc.DeepEquals, []string{"virtual", "magic"})
 	c.Check(machine.OwnerData(), jc.DeepEquals, map[string]string{
 		"fez":            "phil fish",
@@ -59,6 +64,7 @@ func (*machineSuite) TestReadMachines(c *gc.C) {
 	})
 
 	c.Check(machine.IPAddresses(), jc.DeepEquals, []string{"192.168.100.4"})
+	c.Check(machine.IPAddrs(), jc.DeepEquals, []netip.Addr{netip.MustParseAddr("192.168.100.4")})
 	c.Check(machine.Memory(), gc.Equals, 1024)
 	c.Check(machine.CPUCount(), gc.Equals, 1)
 	c.Check(machine.PowerState(), gc.Equals, "on")
@@ -110,6 +116,22 @@ func (*machineSuite) TestReadMachinesNilValues(c *gc.C) {
 	c.Check(machine.BootInterface(), gc.IsNil)
 }
 
+func (*machineSuite) TestReadMachinesNullLists(c *gc.C) {
+	json := parseJSON(c, machinesResponse)
+	data := json.([]interface{})[0].(map[string]interface{})
+	data["tag_names"] = nil
+	data["ip_addresses"] = nil
+	machines, err := readMachines(twoDotOh, json)
+	c.Assert(err, jc.ErrorIsNil)
+	machine := machines[0]
+	c.Check(machine.Tags(), gc.NotNil)
+	c.Check(machine.Tags(), gc.HasLen, 0)
+	c.Check(machine.IPAddresses(), gc.NotNil)
+	c.Check(machine.IPAddresses(), gc.HasLen, 0)
+	c.Check(machine.IPAddrs(), gc.NotNil)
+	c.Check(machine.IPAddrs(), gc.HasLen, 0)
+}
+
 func (*machineSuite) TestLowVersion(c *gc.C) {
 	_, err := readMachines(version.MustParse("1.9.0"), parseJSON(c, machinesResponse))
 	c.Assert(err, jc.Satisfies, IsUnsupportedVersionError)
@@ -122,6 +144,163 @@ func (*machineSuite) TestHighVersion(c *gc.C) {
 	c.Assert(machines, gc.HasLen, 3)
 }
 
+func (*machineSuite) TestReadMachinesOwner(c *gc.C) {
+	machines, err := readMachines(twoDotOh, parseJSON(c, machinesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines, gc.HasLen, 3)
+	c.Check(machines[0].Owner(), gc.Equals, "thumper")
+	c.Check(machines[1].Owner(), gc.Equals, "")
+}
+
+func (*machineSuite) TestReadMachinesPool(c *gc.C) {
+	source := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"pool": parseJSON(c, poolResponse),
+	})
+	machines, err := readMachines(twoDotFour, parseJSON(c, "["+source+"]"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines, gc.HasLen, 1)
+	pool := machines[0].Pool()
+	c.Assert(pool, gc.NotNil)
+	c.Check(pool.ID(), gc.Equals, 1)
+	c.Check(pool.Name(), gc.Equals, "swimming")
+	c.Check(pool.Description(), gc.Equals, "for the fish")
+	c.Check(machines[0].Locked(), jc.IsFalse)
+}
+
+func (*machineSuite) TestReadMachinesNilPool(c *gc.C) {
+	source := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"pool": nil,
+	})
+	machines, err := readMachines(twoDotFour, parseJSON(c, "["+source+"]"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines, gc.HasLen, 1)
+	c.Check(machines[0].Pool(), gc.IsNil)
+}
+
+func (*machineSuite) TestReadMachinesBadPool(c *gc.C) {
+	source := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"pool": "wat?",
+	})
+	_, err := readMachines(twoDotFour, parseJSON(c, "["+source+"]"))
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err, gc.ErrorMatches, `machine 0 \(system_id "4y3ha3"\): machine 2.3 schema check failed: .*`)
+}
+
+func (*machineSuite) TestReadMachinesOlderVersionIgnoresPool(c *gc.C) {
+	source := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"pool": parseJSON(c, poolResponse),
+	})
+	machines, err := readMachines(twoDotOh, parseJSON(c, "["+source+"]"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machines[0].Pool(), gc.IsNil)
+}
+
+func (*machineSuite) TestReadMachinesLocked(c *gc.C) {
+	source := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"pool":                 parseJSON(c, poolResponse),
+		"locked":               true,
+		"workload_annotations": map[string]interface{}{},
+	})
+	machines, err := readMachines(version.MustParse("2.9.2"), parseJSON(c, "["+source+"]"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines, gc.HasLen, 1)
+	c.Check(machines[0].Pool().Name(), gc.Equals, "swimming")
+	c.Check(machines[0].Locked(), jc.IsTrue)
+}
+
+func (*machineSuite) TestReadMachinesMissingLocked(c *gc.C) {
+	source := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"pool": parseJSON(c, poolResponse),
+	})
+	_, err := readMachines(twoDotFive, parseJSON(c, "["+source+"]"))
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err, gc.ErrorMatches, `machine 0 \(system_id "4y3ha3"\): machine 2.5 schema check failed: .*`)
+}
+
+func (*machineSuite) TestReadMachinesBadNestedSchema(c *gc.C) {
+	source := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"boot_interface": nil,
+		"interface_set":  []interface{}{map[string]interface{}{"id": 42, "wat": "?"}},
+	})
+	_, err := readMachines(twoDotOh, parseJSON(c, "["+source+"]"))
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err, gc.ErrorMatches, `machine 0 \(system_id "4y3ha3"\): interface 0 \(id 42\): interface 2.0 schema check failed: .*`)
+	derr := errors.Cause(err).(*DeserializationError)
+	c.Assert(derr.Path, jc.DeepEquals, []string{`machine 0 (system_id "4y3ha3")`, `interface 0 (id 42)`})
+}
+
+func (*machineSuite) TestReadMachinesDescription(c *gc.C) {
+	machines, err := readMachines(twoDotOh, parseJSON(c, machinesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machines[0].Description(), gc.Equals, "")
+
+	source := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"description": "database primary",
+	})
+	machines, err = readMachines(twoDotOh, parseJSON(c, "["+source+"]"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machines[0].Description(), gc.Equals, "database primary")
+}
+
+func (*machineSuite) TestReadMachinesWorkloadAnnotations(c *gc.C) {
+	source := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"pool":   parseJSON(c, poolResponse),
+		"locked": false,
+		"workload_annotations": map[string]interface{}{
+			"juju-model-uuid": "1234",
+		},
+	})
+	machines, err := readMachines(twoDotNine, parseJSON(c, "["+source+"]"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machines[0].WorkloadAnnotations(), jc.DeepEquals, map[string]string{"juju-model-uuid": "1234"})
+}
+
+func (*machineSuite) TestReadMachinesMissingWorkloadAnnotations(c *gc.C) {
+	source := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"pool":   parseJSON(c, poolResponse),
+		"locked": false,
+	})
+	_, err := readMachines(twoDotNine, parseJSON(c, "["+source+"]"))
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err, gc.ErrorMatches, `machine 0 \(system_id "4y3ha3"\): machine 2.9 schema check failed: .*`)
+}
+
+func (*machineSuite) TestPrimarySubnet(c *gc.C) {
+	machines, err := readMachines(twoDotOh, parseJSON(c, machinesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	subnet := machines[0].PrimarySubnet()
+	c.Assert(subnet, gc.NotNil)
+	c.Check(subnet.CIDR(), gc.Equals, "192.168.100.0/24")
+}
+
+func (*machineSuite) TestPrimarySubnetNoLinks(c *gc.C) {
+	m := &machine{bootInterface: &interface_{}, interfaceSet: []*interface_{{}}}
+	c.Check(m.PrimarySubnet(), gc.IsNil)
+}
+
+func (*machineSuite) TestGatewayFor(c *gc.C) {
+	machines, err := readMachines(twoDotOh, parseJSON(c, machinesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	gateway, err := machines[0].GatewayFor("space-0")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(gateway, gc.Equals, "192.168.100.1")
+}
+
+func (*machineSuite) TestGatewayForUnknownSpace(c *gc.C) {
+	machines, err := readMachines(twoDotOh, parseJSON(c, machinesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = machines[0].GatewayFor("space-1")
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+	c.Check(err, gc.ErrorMatches, `no gateway in space "space-1" for machine "4y3ha3"`)
+}
+
+func (*machineSuite) TestAddressesInSpace(c *gc.C) {
+	machines, err := readMachines(twoDotOh, parseJSON(c, machinesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machines[0].AddressesInSpace("space-0"), jc.DeepEquals, []string{"192.168.100.4", "192.168.100.5"})
+	c.Check(machines[0].AddressesInSpace("space-1"), gc.HasLen, 0)
+}
+
 func (s *machineSuite) getServerAndMachine(c *gc.C) (*SimpleTestServer, *machine) {
 	server, controller := createTestServerController(c, s)
 	// Just have machines return one machine
@@ -134,6 +313,48 @@ func (s *machineSuite) getServerAndMachine(c *gc.C) (*SimpleTestServer, *machine
 	return server, machine
 }
 
+// checkInterfaceBound checks the interface, its VLAN and the VLANs of its
+// linked subnets are all associated with the controller.
+func checkInterfaceBound(c *gc.C, iface *interface_, controller *controller) {
+	c.Check(iface.controller, gc.Equals, controller)
+	if iface.vlan != nil {
+		c.Check(iface.vlan.controller, gc.Equals, controller)
+	}
+	for _, link := range iface.links {
+		if link.subnet != nil && link.subnet.vlan != nil {
+			c.Check(link.subnet.vlan.controller, gc.Equals, controller)
+		}
+	}
+}
+
+func (s *machineSuite) TestMachinesBindsNestedResources(c *gc.C) {
+	_, machine := s.getServerAndMachine(c)
+	c.Assert(machine.controller, gc.NotNil)
+	c.Assert(machine.bootInterface, gc.NotNil)
+	checkInterfaceBound(c, machine.bootInterface, machine.controller)
+	c.Assert(machine.interfaceSet, gc.Not(gc.HasLen), 0)
+	for _, iface := range machine.interfaceSet {
+		checkInterfaceBound(c, iface, machine.controller)
+	}
+}
+
+func (*machineSuite) TestSRIOVCapableInterfaces(c *gc.C) {
+	machine := &machine{interfaceSet: []*interface_{
+		{id: 1, type_: "physical", sriovMaxVF: 8},
+		{id: 2, type_: "physical"},
+		{id: 3, type_: "vlan", sriovMaxVF: 8},
+		{id: 4, type_: "physical", sriovMaxVF: 64},
+	}}
+	var ids []int
+	for _, iface := range machine.SRIOVCapableInterfaces() {
+		ids = append(ids, iface.ID())
+	}
+	c.Assert(ids, jc.DeepEquals, []int{4, 1})
+
+	machine.interfaceSet = machine.interfaceSet[1:2]
+	c.Assert(machine.SRIOVCapableInterfaces(), gc.HasLen, 0)
+}
+
 func (s *machineSuite) TestStart(c *gc.C) {
 	server, machine := s.getServerAndMachine(c)
 	response := updateJSONMap(c, machineResponse, map[string]interface{}{
@@ -145,7 +366,7 @@ func (s *machineSuite) TestStart(c *gc.C) {
 	err := machine.Start(StartArgs{
 		UserData:     "userdata",
 		DistroSeries: "trusty",
-		Kernel:       "kernel",
+		Kernel:       "hwe-14.04",
 		Comment:      "a comment",
 	})
 	c.Assert(err, jc.ErrorIsNil)
@@ -158,10 +379,108 @@ func (s *machineSuite) TestStart(c *gc.C) {
 	c.Assert(form, gc.HasLen, 4)
 	c.Check(form.Get("user_data"), gc.Equals, "userdata")
 	c.Check(form.Get("distro_series"), gc.Equals, "trusty")
-	c.Check(form.Get("hwe_kernel"), gc.Equals, "kernel")
+	c.Check(form.Get("hwe_kernel"), gc.Equals, "hwe-14.04")
 	c.Check(form.Get("comment"), gc.Equals, "a comment")
 }
 
+func (s *machineSuite) TestStartEphemeral(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	machine.controller.release = version.MustParse("3.3.0")
+	response := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"pool":                 parseJSON(c, poolResponse),
+		"locked":               false,
+		"workload_annotations": map[string]interface{}{},
+	})
+	server.AddPostResponse(machine.resourceURI+"?op=deploy", http.StatusOK, response)
+
+	err := machine.Start(StartArgs{Ephemeral: true, EnableHWSync: true})
+	c.Assert(err, jc.ErrorIsNil)
+
+	form := server.LastRequest().PostForm
+	c.Assert(form, gc.HasLen, 2)
+	c.Check(form.Get("ephemeral_deploy"), gc.Equals, "true")
+	c.Check(form.Get("enable_hw_sync"), gc.Equals, "true")
+}
+
+func (s *machineSuite) TestStartEphemeralOldMAAS(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	machine.controller.release = version.MustParse("3.1.0")
+
+	err := machine.Start(StartArgs{Ephemeral: true})
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+	c.Assert(err.Error(), gc.Equals, "ephemeral deployment before MAAS 3.2 not supported")
+
+	err = machine.Start(StartArgs{EnableHWSync: true})
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+	c.Assert(err.Error(), gc.Equals, "hardware sync before MAAS 3.2 not supported")
+	c.Check(server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *machineSuite) TestStartArgsValidate(c *gc.C) {
+	for i, test := range []struct {
+		args    StartArgs
+		errText string
+	}{{
+		args: StartArgs{},
+	}, {
+		args: StartArgs{UserData: "I2Nsb3VkLWNvbmZpZwo=", DistroSeries: SeriesJammy, Kernel: "hwe-22.04-edge"},
+	}, {
+		args: StartArgs{DistroSeries: "centos/centos70", Kernel: "ga-18.04", CheckBootImages: true},
+	}, {
+		args:    StartArgs{UserData: "#cloud-config"},
+		errText: "UserData not base64 encoded: illegal base64 data at input byte 0",
+	}, {
+		args:    StartArgs{DistroSeries: "ubuntu jammy"},
+		errText: `DistroSeries "ubuntu jammy" not valid`,
+	}, {
+		args:    StartArgs{DistroSeries: "ubuntu/"},
+		errText: `DistroSeries "ubuntu/" not valid`,
+	}, {
+		args:    StartArgs{Kernel: "kernel"},
+		errText: `Kernel "kernel" not valid`,
+	}, {
+		args:    StartArgs{CheckBootImages: true},
+		errText: "CheckBootImages without DistroSeries not valid",
+	}} {
+		c.Logf("test %d", i)
+		err := test.args.Validate()
+		if test.errText == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, jc.Satisfies, errors.IsNotValid)
+			c.Check(err.Error(), gc.Equals, test.errText)
+		}
+	}
+}
+
+func (s *machineSuite) TestStartNotValid(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	count := server.RequestCount()
+	err := machine.Start(StartArgs{Kernel: "kernel"})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(server.RequestCount(), gc.Equals, count)
+}
+
+func (s *machineSuite) TestStartCheckBootImages(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse("/api/2.0/boot-resources/", http.StatusOK, bootResourcesResponse)
+	server.AddPostResponse(machine.resourceURI+"?op=deploy", http.StatusOK, machineResponse)
+
+	err := machine.Start(StartArgs{DistroSeries: "trusty", CheckBootImages: true})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(server.LastRequest().PostForm.Get("distro_series"), gc.Equals, "trusty")
+}
+
+func (s *machineSuite) TestStartCheckBootImagesMissing(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse("/api/2.0/boot-resources/", http.StatusOK, bootResourcesResponse)
+
+	err := machine.Start(StartArgs{DistroSeries: SeriesJammy, CheckBootImages: true})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(err.Error(), gc.Equals, `DistroSeries "jammy" without boot images for amd64 not valid`)
+	c.Check(server.LastRequest().Method, gc.Equals, "GET")
+}
+
 func (s *machineSuite) TestStartMachineNotFound(c *gc.C) {
 	server, machine := s.getServerAndMachine(c)
 	server.AddPostResponse(machine.resourceURI+"?op=deploy", http.StatusNotFound, "can't find machine")
@@ -199,7 +518,264 @@ func (s *machineSuite) TestStartMachineUnknown(c *gc.C) {
 	server.AddPostResponse(machine.resourceURI+"?op=deploy", http.StatusMethodNotAllowed, "wat?")
 	err := machine.Start(StartArgs{})
 	c.Assert(err, jc.Satisfies, IsUnexpectedError)
-	c.Assert(err.Error(), gc.Equals, "unexpected: ServerError: 405 Method Not Allowed (wat?)")
+	c.Assert(err, gc.ErrorMatches, `unexpected: request [0-9a-f]+-[0-9a-f]+: ServerError: 405 Method Not Allowed \(wat\?\)`)
+}
+
+func (s *machineSuite) TestSetBootInterface(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	machine.controller.release = twoDotFive
+	response := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"boot_interface": parseJSON(c, interfaceResponse),
+		"locked":         false,
+	})
+	server.AddPutResponse(machine.resourceURI, http.StatusOK, response)
+
+	err := machine.SetBootInterface(99)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.BootInterface().ID(), gc.Equals, 40)
+	request := server.LastRequest()
+	c.Check(request.PostForm.Get("boot_interface"), gc.Equals, "99")
+}
+
+func (s *machineSuite) TestSetBootInterfaceOldVersion(c *gc.C) {
+	_, machine := s.getServerAndMachine(c)
+	err := machine.SetBootInterface(99)
+	c.Assert(err, jc.Satisfies, IsUnsupportedVersionError)
+}
+
+func (s *machineSuite) TestSetBootInterfaceUnknownInterface(c *gc.C) {
+	_, machine := s.getServerAndMachine(c)
+	machine.controller.release = twoDotFive
+	err := machine.SetBootInterface(12)
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *machineSuite) TestSetBootInterfaceConflict(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	machine.controller.release = twoDotFive
+	server.AddPutResponse(machine.resourceURI, http.StatusConflict, "machine deployed")
+	err := machine.SetBootInterface(99)
+	c.Assert(err, jc.Satisfies, IsCannotCompleteError)
+}
+
+func (*machineSuite) TestReadMachineSwapNetbootAddressTTL(c *gc.C) {
+	machines, err := readMachines(twoDotOh, parseJSON(c, "["+machineResponse+"]"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machines[0].SwapSize(), gc.Equals, uint64(0))
+	c.Check(machines[0].Netboot(), jc.IsFalse)
+	c.Check(machines[0].AddressTTL(), gc.Equals, 0)
+
+	source := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"swap_size":   2147483648,
+		"netboot":     true,
+		"address_ttl": 300,
+	})
+	machines, err = readMachines(twoDotOh, parseJSON(c, "["+source+"]"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machines[0].SwapSize(), gc.Equals, uint64(2147483648))
+	c.Check(machines[0].Netboot(), jc.IsTrue)
+	c.Check(machines[0].AddressTTL(), gc.Equals, 300)
+}
+
+func (*machineSuite) TestReadMachineResults(c *gc.C) {
+	machines, err := readMachines(twoDotOh, parseJSON(c, "["+machineResponse+"]"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machines[0].TestResultsSummary(), jc.DeepEquals, TestResultsSummary{})
+	c.Check(machines[0].InstallationResultID(), gc.Equals, 0)
+	c.Check(machines[0].LastImageSync().IsZero(), jc.IsTrue)
+
+	source := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"commissioning_status_name":       "Passed",
+		"testing_status_name":             "Failed",
+		"cpu_test_status_name":            "Failed",
+		"memory_test_status_name":         "Passed",
+		"storage_test_status_name":        "Passed",
+		"network_test_status_name":        "Unknown",
+		"interface_test_status_name":      "Unknown",
+		"other_test_status_name":          "Unknown",
+		"current_commissioning_result_id": 3,
+		"current_testing_result_id":       4,
+		"current_installation_result_id":  5,
+		"last_image_sync":                 "Tue, 05 Jan. 2021 10:11:12",
+	})
+	machines, err = readMachines(twoDotOh, parseJSON(c, "["+source+"]"))
+	c.Assert(err, jc.ErrorIsNil)
+	machine := machines[0]
+	c.Check(machine.TestResultsSummary(), jc.DeepEquals, TestResultsSummary{
+		Commissioning: "Passed",
+		Testing:       "Failed",
+		CPU:           "Failed",
+		Memory:        "Passed",
+		Storage:       "Passed",
+		Network:       "Unknown",
+		Interface:     "Unknown",
+		Other:         "Unknown",
+	})
+	c.Check(machine.CommissioningResultID(), gc.Equals, 3)
+	c.Check(machine.TestingResultID(), gc.Equals, 4)
+	c.Check(machine.InstallationResultID(), gc.Equals, 5)
+	c.Check(machine.LastImageSync(), gc.Equals, time.Date(2021, 1, 5, 10, 11, 12, 0, time.UTC))
+}
+
+func (*machineSuite) TestReadMachineBadLastImageSync(c *gc.C) {
+	source := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"last_image_sync": "yesterday",
+	})
+	_, err := readMachines(twoDotOh, parseJSON(c, "["+source+"]"))
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+}
+
+func (s *machineSuite) TestDownloadInstallationOutput(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	machine.installationResultID = 5
+	server.AddGetResponse("/api/2.0/nodes/4y3ha3/results/5/?filetype=txt&op=download&output=combined", http.StatusOK, "curtin: Installation finished.")
+
+	var out bytes.Buffer
+	err := machine.DownloadInstallationOutput(&out)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(out.String(), gc.Equals, "curtin: Installation finished.")
+}
+
+func (s *machineSuite) TestDownloadInstallationOutputNotInstalled(c *gc.C) {
+	_, machine := s.getServerAndMachine(c)
+	var out bytes.Buffer
+	err := machine.DownloadInstallationOutput(&out)
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+	c.Assert(err.Error(), gc.Equals, `no installation result for machine "4y3ha3"`)
+}
+
+func (s *machineSuite) TestSetSwapSize(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	response := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"swap_size": 2147483648,
+	})
+	server.AddPutResponse(machine.resourceURI, http.StatusOK, response)
+
+	err := machine.SetSwapSize(2147483648)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.SwapSize(), gc.Equals, uint64(2147483648))
+	request := server.LastRequest()
+	c.Check(request.PostForm.Get("swap_size"), gc.Equals, "2147483648")
+}
+
+func (s *machineSuite) TestSetSwapSizeDefault(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPutResponse(machine.resourceURI, http.StatusOK, machineResponse)
+
+	err := machine.SetSwapSize(0)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.SwapSize(), gc.Equals, uint64(0))
+	request := server.LastRequest()
+	c.Check(request.PostForm["swap_size"], jc.DeepEquals, []string{""})
+}
+
+func (s *machineSuite) TestSetSwapSizeBadRequest(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPutResponse(machine.resourceURI, http.StatusBadRequest, "swap too big")
+	err := machine.SetSwapSize(1 << 50)
+	c.Assert(err, jc.Satisfies, IsBadRequestError)
+	c.Assert(err.Error(), gc.Equals, "swap too big")
+}
+
+func (s *machineSuite) TestSetNetboot(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	response := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"netboot": true,
+	})
+	server.AddPutResponse(machine.resourceURI, http.StatusOK, response)
+
+	err := machine.SetNetboot(true)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.Netboot(), jc.IsTrue)
+	request := server.LastRequest()
+	c.Check(request.PostForm.Get("netboot"), gc.Equals, "true")
+}
+
+func (s *machineSuite) TestSetNetbootForbidden(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPutResponse(machine.resourceURI, http.StatusForbidden, "no")
+	err := machine.SetNetboot(false)
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+}
+
+func (*machineSuite) TestReadMachineSpecialFilesystems(c *gc.C) {
+	source := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"special_filesystems": []interface{}{
+			map[string]interface{}{
+				"fstype":        "tmpfs",
+				"label":         nil,
+				"uuid":          "1e7d8d49-8c3a-4c18-9e1b-3d2c4c0bd3a4",
+				"mount_point":   "/srv/scratch",
+				"mount_options": "size=1G",
+			},
+		},
+	})
+	machines, err := readMachines(twoDotOh, parseJSON(c, "["+source+"]"))
+	c.Assert(err, jc.ErrorIsNil)
+	filesystems := machines[0].SpecialFilesystems()
+	c.Assert(filesystems, gc.HasLen, 1)
+	c.Check(filesystems[0].Type(), gc.Equals, "tmpfs")
+	c.Check(filesystems[0].MountPoint(), gc.Equals, "/srv/scratch")
+	c.Check(filesystems[0].MountOptions(), gc.Equals, "size=1G")
+}
+
+func (s *machineSuite) TestMountSpecialFilesystem(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	response := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"special_filesystems": []interface{}{
+			map[string]interface{}{
+				"fstype":        "tmpfs",
+				"uuid":          "1e7d8d49-8c3a-4c18-9e1b-3d2c4c0bd3a4",
+				"mount_point":   "/srv/scratch",
+				"mount_options": "size=1G",
+			},
+		},
+	})
+	server.AddPostResponse(machine.resourceURI+"?op=mount_special", http.StatusOK, response)
+
+	err := machine.MountSpecialFilesystem(MountSpecialArgs{
+		FSType:       "tmpfs",
+		MountPoint:   "/srv/scratch",
+		MountOptions: "size=1G",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.SpecialFilesystems(), gc.HasLen, 1)
+	form := server.LastRequest().PostForm
+	c.Check(form.Get("fstype"), gc.Equals, "tmpfs")
+	c.Check(form.Get("mount_point"), gc.Equals, "/srv/scratch")
+	c.Check(form.Get("mount_options"), gc.Equals, "size=1G")
+}
+
+func (s *machineSuite) TestMountSpecialFilesystemValidates(c *gc.C) {
+	_, machine := s.getServerAndMachine(c)
+	err := machine.MountSpecialFilesystem(MountSpecialArgs{FSType: "tmpfs"})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	err = machine.MountSpecialFilesystem(MountSpecialArgs{MountPoint: "/srv"})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *machineSuite) TestMountSpecialFilesystemConflict(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse(machine.resourceURI+"?op=mount_special", http.StatusConflict, "machine deployed")
+	err := machine.MountSpecialFilesystem(MountSpecialArgs{FSType: "tmpfs", MountPoint: "/srv"})
+	c.Assert(err, jc.Satisfies, IsCannotCompleteError)
+}
+
+func (s *machineSuite) TestUnmountSpecial(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse(machine.resourceURI+"?op=unmount_special", http.StatusOK, machineResponse)
+
+	err := machine.UnmountSpecial("/srv/scratch")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(machine.SpecialFilesystems(), gc.HasLen, 0)
+	c.Check(server.LastRequest().PostForm.Get("mount_point"), gc.Equals, "/srv/scratch")
+}
+
+func (s *machineSuite) TestUnmountSpecialNotFound(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse(machine.resourceURI+"?op=unmount_special", http.StatusNotFound, "no such machine")
+	err := machine.UnmountSpecial("/srv/scratch")
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
 }
 
 func (s *machineSuite) TestDevices(c *gc.C) {
@@ -413,6 +989,376 @@ func (s *machineSuite) TestSetOwnerData(c *gc.C) {
 	c.Check(form["empty"], gc.DeepEquals, []string{""})
 }
 
+func (s *machineSuite) TestWorkloadAnnotationsCopies(c *gc.C) {
+	machine := machine{workloadAnnotations: make(map[string]string)}
+	annotations := machine.WorkloadAnnotations()
+	annotations["sad"] = "children"
+	c.Assert(machine.WorkloadAnnotations(), gc.DeepEquals, map[string]string{})
+}
+
+func (s *machineSuite) TestSetWorkloadAnnotations(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	machine.controller.release = twoDotNine
+	response := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"pool":                 parseJSON(c, poolResponse),
+		"locked":               false,
+		"workload_annotations": map[string]interface{}{"returned": "data"},
+	})
+	server.AddPostResponse(machine.resourceURI+"?op=set_workload_annotations", http.StatusOK, response)
+	err := machine.SetWorkloadAnnotations(map[string]string{
+		"app":   "postgresql",
+		"empty": "",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machine.WorkloadAnnotations(), gc.DeepEquals, map[string]string{"returned": "data"})
+	form := server.LastRequest().PostForm
+	c.Check(form["app"], gc.DeepEquals, []string{"postgresql"})
+	c.Check(form["empty"], gc.DeepEquals, []string{""})
+}
+
+func (s *machineSuite) TestSetWorkloadAnnotationsOldMAAS(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	machine.controller.release = version.MustParse("2.8.2")
+
+	err := machine.SetWorkloadAnnotations(map[string]string{"app": "postgresql"})
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+	c.Assert(err.Error(), gc.Equals, "workload annotations before MAAS 2.9 not supported")
+	c.Check(server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *machineSuite) TestSetWorkloadAnnotationsConflict(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse(machine.resourceURI+"?op=set_workload_annotations", http.StatusConflict, "machine is ready")
+	err := machine.SetWorkloadAnnotations(map[string]string{"app": "postgresql"})
+	c.Check(err, jc.Satisfies, IsCannotCompleteError)
+	c.Check(err.Error(), gc.Equals, "machine is ready")
+}
+
+func (s *machineSuite) TestSetWorkloadAnnotationsForbidden(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse(machine.resourceURI+"?op=set_workload_annotations", http.StatusForbidden, "bad user")
+	err := machine.SetWorkloadAnnotations(map[string]string{"app": "postgresql"})
+	c.Check(err, jc.Satisfies, IsPermissionError)
+	c.Check(err.Error(), gc.Equals, "bad user")
+}
+
+func (s *machineSuite) TestCloneTo(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse("/api/2.0/machines/?op=clone", http.StatusOK, "")
+
+	err := machine.CloneTo([]string{"abc123", "def456"}, true, true)
+	c.Assert(err, jc.ErrorIsNil)
+
+	form := server.LastRequest().PostForm
+	c.Check(form.Get("source"), gc.Equals, machine.SystemID())
+	c.Check(form["destinations"], jc.DeepEquals, []string{"abc123", "def456"})
+	c.Check(form.Get("storage"), gc.Equals, "true")
+	c.Check(form.Get("interfaces"), gc.Equals, "true")
+}
+
+func (s *machineSuite) TestCloneToStorageOnly(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse("/api/2.0/machines/?op=clone", http.StatusOK, "")
+
+	err := machine.CloneTo([]string{"abc123"}, true, false)
+	c.Assert(err, jc.ErrorIsNil)
+
+	form := server.LastRequest().PostForm
+	c.Check(form.Get("storage"), gc.Equals, "true")
+	_, found := form["interfaces"]
+	c.Check(found, jc.IsFalse)
+}
+
+func (s *machineSuite) TestCloneToValidates(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+
+	err := machine.CloneTo(nil, true, true)
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(err.Error(), gc.Equals, "missing destinations not valid")
+
+	err = machine.CloneTo([]string{"abc123"}, false, false)
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(err.Error(), gc.Equals, "nothing to clone not valid")
+	c.Check(server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *machineSuite) TestCloneToBadRequest(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse("/api/2.0/machines/?op=clone", http.StatusBadRequest, "unknown destination")
+
+	err := machine.CloneTo([]string{"missing"}, true, false)
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+	c.Check(err.Error(), gc.Equals, "unknown destination")
+}
+
+func (s *machineSuite) TestCloneToForbidden(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse("/api/2.0/machines/?op=clone", http.StatusForbidden, "no")
+
+	err := machine.CloneTo([]string{"abc123"}, false, true)
+	c.Check(err, jc.Satisfies, IsPermissionError)
+}
+
+func (s *machineSuite) TestSetStorageLayout(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	response := updateJSONMap(c, machineResponse, map[string]interface{}{
+		"status_message": "storage layout set",
+	})
+	server.AddPostResponse(machine.resourceURI+"?op=set_storage_layout", http.StatusOK, response)
+
+	err := machine.SetStorageLayout(StorageLayoutLVM, map[string]string{
+		"vg_name":   "vgroot",
+		"root_size": "10G",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machine.StatusMessage(), gc.Equals, "storage layout set")
+
+	form := server.LastRequest().PostForm
+	c.Assert(form, gc.HasLen, 3)
+	c.Check(form.Get("storage_layout"), gc.Equals, "lvm")
+	c.Check(form.Get("vg_name"), gc.Equals, "vgroot")
+	c.Check(form.Get("root_size"), gc.Equals, "10G")
+}
+
+func (s *machineSuite) TestSetStorageLayoutValidates(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.ResetRequests()
+
+	err := machine.SetStorageLayout("zfs", nil)
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(err, gc.ErrorMatches, `storage layout "zfs" not valid`)
+
+	err = machine.SetStorageLayout(StorageLayoutFlat, map[string]string{"storage_layout": "lvm"})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *machineSuite) TestSetStorageLayoutBadRequest(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse(machine.resourceURI+"?op=set_storage_layout", http.StatusBadRequest, "root_size too big")
+	err := machine.SetStorageLayout(StorageLayoutFlat, map[string]string{"root_size": "10T"})
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+	c.Check(err.Error(), gc.Equals, "root_size too big")
+}
+
+func (s *machineSuite) TestSetStorageLayoutConflict(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse(machine.resourceURI+"?op=set_storage_layout", http.StatusConflict, "machine is deployed")
+	err := machine.SetStorageLayout(StorageLayoutFlat, nil)
+	c.Check(err, jc.Satisfies, IsCannotCompleteError)
+	c.Check(err.Error(), gc.Equals, "machine is deployed")
+}
+
+func (s *machineSuite) TestSetStorageLayoutForbidden(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse(machine.resourceURI+"?op=set_storage_layout", http.StatusForbidden, "bad user")
+	err := machine.SetStorageLayout(StorageLayoutFlat, nil)
+	c.Check(err, jc.Satisfies, IsPermissionError)
+	c.Check(err.Error(), gc.Equals, "bad user")
+}
+
+func (s *machineSuite) TestSetStorageLayoutNotFound(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse(machine.resourceURI+"?op=set_storage_layout", http.StatusNotFound, "can't find machine")
+	err := machine.SetStorageLayout(StorageLayoutFlat, nil)
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+	c.Check(err.Error(), gc.Equals, "can't find machine")
+}
+
+func (s *machineSuite) TestDeviceBySystemID(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse("/api/2.0/devices/?id=4y3haf", http.StatusOK, devicesResponse)
+
+	device, err := machine.DeviceBySystemID("4y3haf")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(device.SystemID(), gc.Equals, "4y3haf")
+	c.Check(device.Parent(), gc.Equals, machine.SystemID())
+}
+
+func (s *machineSuite) TestDeviceBySystemIDOtherParent(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	response := updateJSONMap(c, deviceResponse, map[string]interface{}{
+		"parent": "4y3ha4",
+	})
+	server.AddGetResponse("/api/2.0/devices/?id=4y3haf", http.StatusOK, "["+response+"]")
+
+	_, err := machine.DeviceBySystemID("4y3haf")
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+	c.Check(err, gc.ErrorMatches, `machine "4y3ha3" has no device "4y3haf"`)
+}
+
+func (s *machineSuite) TestDeviceBySystemIDMissing(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse("/api/2.0/devices/?id=missing", http.StatusOK, "[]")
+
+	_, err := machine.DeviceBySystemID("missing")
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+}
+
+func (s *machineSuite) TestRestoreConfiguration(c *gc.C) {
+	for i, test := range []struct {
+		op      string
+		restore func(Machine) error
+	}{{
+		op:      "restore_networking_configuration",
+		restore: Machine.RestoreNetworkingConfiguration,
+	}, {
+		op:      "restore_storage_configuration",
+		restore: Machine.RestoreStorageConfiguration,
+	}, {
+		op:      "restore_default_configuration",
+		restore: Machine.RestoreDefaultConfiguration,
+	}} {
+		c.Logf("test %d: %s", i, test.op)
+		server, machine := s.getServerAndMachine(c)
+		response := updateJSONMap(c, machineResponse, map[string]interface{}{
+			"status_message": "restored",
+		})
+		server.AddPostResponse(machine.resourceURI+"?op="+test.op, http.StatusOK, response)
+
+		err := test.restore(machine)
+		c.Check(err, jc.ErrorIsNil)
+		c.Check(machine.StatusMessage(), gc.Equals, "restored")
+	}
+}
+
+func (s *machineSuite) TestRestoreConfigurationConflict(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse(machine.resourceURI+"?op=restore_default_configuration", http.StatusConflict, "machine is deployed")
+	err := machine.RestoreDefaultConfiguration()
+	c.Check(err, jc.Satisfies, IsCannotCompleteError)
+	c.Check(err.Error(), gc.Equals, "machine is deployed")
+}
+
+func (s *machineSuite) TestRestoreConfigurationForbidden(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse(machine.resourceURI+"?op=restore_networking_configuration", http.StatusForbidden, "bad user")
+	err := machine.RestoreNetworkingConfiguration()
+	c.Check(err, jc.Satisfies, IsPermissionError)
+	c.Check(err.Error(), gc.Equals, "bad user")
+}
+
+func (s *machineSuite) TestRestoreConfigurationNotFound(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse(machine.resourceURI+"?op=restore_storage_configuration", http.StatusNotFound, "can't find machine")
+	err := machine.RestoreStorageConfiguration()
+	c.Check(err, jc.Satisfies, IsNoMatchError)
+	c.Check(err.Error(), gc.Equals, "can't find machine")
+}
+
+func (s *machineSuite) TestRestoreConfigurationUnexpected(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddPostResponse(machine.resourceURI+"?op=restore_default_configuration", http.StatusInternalServerError, "boom")
+	err := machine.RestoreDefaultConfiguration()
+	c.Check(err, jc.Satisfies, IsUnexpectedError)
+}
+
+func (s *machineSuite) TestInstallationLog(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	machine.controller.release = version.MustParse("2.1.0")
+	server.AddGetResponse("/api/2.0/installation-results/?system_id=4y3ha3", http.StatusOK, installationResultsResponse)
+
+	content, err := machine.InstallationLog()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(content), gc.Equals, "second install")
+}
+
+func (s *machineSuite) TestInstallationLogMissing(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	machine.controller.release = version.MustParse("2.1.0")
+	server.AddGetResponse("/api/2.0/installation-results/?system_id=4y3ha3", http.StatusOK, "[]")
+
+	_, err := machine.InstallationLog()
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+	c.Assert(err.Error(), gc.Equals, `no installation log for machine "4y3ha3"`)
+}
+
+func (s *machineSuite) TestInstallationLogBadData(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	machine.controller.release = version.MustParse("2.1.0")
+	server.AddGetResponse("/api/2.0/installation-results/?system_id=4y3ha3", http.StatusOK,
+		`[{"name": "install.log", "data": "not base64!"}]`)
+
+	_, err := machine.InstallationLog()
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+}
+
+func (s *machineSuite) TestInstallationLogForbidden(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	machine.controller.release = version.MustParse("2.1.0")
+	server.AddGetResponse("/api/2.0/installation-results/?system_id=4y3ha3", http.StatusForbidden, "no")
+
+	_, err := machine.InstallationLog()
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+}
+
+func (s *machineSuite) TestInstallationLogScriptResult(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	machine.controller.release = version.MustParse("2.2.0")
+	machine.installationResultID = 5
+	server.AddGetResponse("/api/2.0/nodes/4y3ha3/results/5/?filetype=txt&op=download&output=combined", http.StatusOK, "curtin: Installation failed.")
+
+	content, err := machine.InstallationLog()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(content), gc.Equals, "curtin: Installation failed.")
+}
+
+func (s *machineSuite) TestInstallationLogScriptResultMissing(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	machine.installationResultID = 5
+	server.AddGetResponse("/api/2.0/nodes/4y3ha3/results/5/?filetype=txt&op=download&output=combined", http.StatusNotFound, "gone")
+
+	_, err := machine.InstallationLog()
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+}
+
+func (s *machineSuite) TestCurtinConfig(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse(machine.resourceURI+"?op=get_curtin_config", http.StatusOK, "install:\n  log_file: /tmp/install.log\n")
+
+	content, err := machine.CurtinConfig()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(content), gc.Equals, "install:\n  log_file: /tmp/install.log\n")
+}
+
+func (s *machineSuite) TestCurtinConfigNotDeploying(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse(machine.resourceURI+"?op=get_curtin_config", http.StatusBadRequest, "not in a deployment state")
+
+	_, err := machine.CurtinConfig()
+	c.Assert(err, jc.Satisfies, IsBadRequestError)
+	c.Assert(err.Error(), gc.Equals, "not in a deployment state")
+}
+
+func (s *machineSuite) TestDelete(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	// Successful delete is 204 - StatusNoContent
+	server.AddDeleteResponse(machine.resourceURI, http.StatusNoContent, "")
+	err := machine.Delete()
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *machineSuite) TestDelete404(c *gc.C) {
+	_, machine := s.getServerAndMachine(c)
+	// No path, so 404
+	err := machine.Delete()
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+}
+
+func (s *machineSuite) TestDeleteForbidden(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddDeleteResponse(machine.resourceURI, http.StatusForbidden, "")
+	err := machine.Delete()
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+}
+
+func (s *machineSuite) TestDeleteConflict(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddDeleteResponse(machine.resourceURI, http.StatusConflict, "")
+	err := machine.Delete()
+	c.Assert(err, jc.Satisfies, IsCannotCompleteError)
+}
+
 func machineWithOwnerData(data string) string {
 	return fmt.Sprintf(machineOwnerDataTemplate, data)
 }
@@ -916,6 +1862,42 @@ const (
 )
 
 var (
+	// The data is "first install" and "second install", base64 encoded.
+	installationResultsResponse = `
+[
+    {
+        "name": "install.log",
+        "data": "Zmlyc3QgaW5zdGFsbA==",
+        "result_type": 1,
+        "script_result": 0,
+        "resource_uri": "/MAAS/api/2.0/installation-results/"
+    },
+    {
+        "name": "curtin-config.yaml",
+        "data": "",
+        "result_type": 1,
+        "script_result": 0,
+        "resource_uri": "/MAAS/api/2.0/installation-results/"
+    },
+    {
+        "name": "install.log",
+        "data": "c2Vjb25kIGluc3RhbGw=",
+        "result_type": 1,
+        "script_result": 0,
+        "resource_uri": "/MAAS/api/2.0/installation-results/"
+    }
+]
+`
+
+	poolResponse = `
+    {
+        "name": "swimming",
+        "description": "for the fish",
+        "id": 1,
+        "resource_uri": "/MAAS/api/2.0/resourcepool/1/"
+    }
+`
+
 	machineResponse = machineWithOwnerData(`{
             "fez": "phil fish",
             "frog-fractions": "jim crawford"
@@ -0,0 +1,151 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"reflect"
+)
+
+// MachineDiff describes how a listing of machines differs from an earlier
+// one, as returned by Controller.DiffMachines. The machines are in the
+// order of the listing they are taken from.
+type MachineDiff struct {
+	// Added are the machines only in the current listing, and Removed
+	// those only in the previous one.
+	Added   []Machine
+	Removed []Machine
+	// Changed are the machines in both listings whose fields differ.
+	Changed []MachineChange
+}
+
+// Empty reports whether the listings have the same machines, unchanged.
+func (d MachineDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// MachineChange describes a machine whose fields differ between listings.
+type MachineChange struct {
+	Previous Machine
+	Current  Machine
+	// Fields are the names of the Machine methods whose values differ, in
+	// the order of machineDiffFields.
+	Fields []string
+}
+
+// machineDiffField is a field compared by DiffMachines, with the method
+// that it is read with.
+type machineDiffField struct {
+	name  string
+	value func(Machine) interface{}
+}
+
+// machineDiffFields are the fields that DiffMachines compares. Interfaces
+// and block devices are compared by their IDs and addresses or sizes, so
+// that changes within them are reported as a change of the machine.
+var machineDiffFields = []machineDiffField{
+	{"Hostname", func(m Machine) interface{} { return m.Hostname() }},
+	{"FQDN", func(m Machine) interface{} { return m.FQDN() }},
+	{"Description", func(m Machine) interface{} { return m.Description() }},
+	{"Tags", func(m Machine) interface{} { return m.Tags() }},
+	{"OwnerData", func(m Machine) interface{} { return m.OwnerData() }},
+	{"WorkloadAnnotations", func(m Machine) interface{} { return m.WorkloadAnnotations() }},
+	{"Owner", func(m Machine) interface{} { return m.Owner() }},
+	{"Locked", func(m Machine) interface{} { return m.Locked() }},
+	{"StatusName", func(m Machine) interface{} { return m.StatusName() }},
+	{"StatusMessage", func(m Machine) interface{} { return m.StatusMessage() }},
+	{"PowerState", func(m Machine) interface{} { return m.PowerState() }},
+	{"OperatingSystem", func(m Machine) interface{} { return m.OperatingSystem() }},
+	{"DistroSeries", func(m Machine) interface{} { return m.DistroSeries() }},
+	{"Architecture", func(m Machine) interface{} { return m.Architecture() }},
+	{"Memory", func(m Machine) interface{} { return m.Memory() }},
+	{"CPUCount", func(m Machine) interface{} { return m.CPUCount() }},
+	{"HardwareInfo", func(m Machine) interface{} { return m.HardwareInfo() }},
+	{"IPAddresses", func(m Machine) interface{} { return m.IPAddresses() }},
+	{"Zone", func(m Machine) interface{} {
+		if zone := m.Zone(); zone != nil {
+			return zone.Name()
+		}
+		return ""
+	}},
+	{"Pool", func(m Machine) interface{} {
+		if pool := m.Pool(); pool != nil {
+			return pool.Name()
+		}
+		return ""
+	}},
+	{"Netboot", func(m Machine) interface{} { return m.Netboot() }},
+	{"SwapSize", func(m Machine) interface{} { return m.SwapSize() }},
+	{"AddressTTL", func(m Machine) interface{} { return m.AddressTTL() }},
+	{"TestResultsSummary", func(m Machine) interface{} { return m.TestResultsSummary() }},
+	{"LastImageSync", func(m Machine) interface{} { return m.LastImageSync().UTC() }},
+	{"BootInterface", func(m Machine) interface{} {
+		if iface := m.BootInterface(); iface != nil {
+			return iface.ID()
+		}
+		return 0
+	}},
+	{"InterfaceSet", func(m Machine) interface{} {
+		var result []string
+		for _, iface := range m.InterfaceSet() {
+			result = append(result, iface.Name()+" "+iface.MACAddress())
+			for _, link := range iface.Links() {
+				result = append(result, string(link.LinkMode())+" "+link.IPAddress())
+			}
+		}
+		return result
+	}},
+	{"BlockDevices", func(m Machine) interface{} {
+		var result []uint64
+		for _, device := range m.BlockDevices() {
+			result = append(result, uint64(device.ID()), device.Size())
+		}
+		return result
+	}},
+}
+
+// DiffMachines implements Controller.
+//
+// Machines are matched by system ID. Only the values the machines were
+// read with are compared, so no requests are made.
+func (c *controller) DiffMachines(previous, current []Machine) MachineDiff {
+	var result MachineDiff
+	before := make(map[string]Machine)
+	for _, m := range previous {
+		before[m.SystemID()] = m
+	}
+	after := make(map[string]bool)
+	for _, m := range current {
+		after[m.SystemID()] = true
+		old, found := before[m.SystemID()]
+		if !found {
+			result.Added = append(result.Added, m)
+			continue
+		}
+		if fields := changedMachineFields(old, m); len(fields) > 0 {
+			result.Changed = append(result.Changed, MachineChange{
+				Previous: old,
+				Current:  m,
+				Fields:   fields,
+			})
+		}
+	}
+	for _, m := range previous {
+		if !after[m.SystemID()] {
+			result.Removed = append(result.Removed, m)
+		}
+	}
+	return result
+}
+
+// changedMachineFields returns the names of the fields that differ between
+// the machines.
+func changedMachineFields(previous, current Machine) []string {
+	var result []string
+	for _, field := range machineDiffFields {
+		if !reflect.DeepEqual(field.value(previous), field.value(current)) {
+			result = append(result, field.name)
+		}
+	}
+	return result
+}
@@ -0,0 +1,52 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+func (s *machineSuite) readDiffMachine(c *gc.C, changes map[string]interface{}) Machine {
+	machine, err := readMachine(twoDotOh, parseJSON(c, updateJSONMap(c, machineResponse, changes)))
+	c.Assert(err, jc.ErrorIsNil)
+	return machine
+}
+
+func (s *machineSuite) TestDiffMachines(c *gc.C) {
+	_, machine := s.getServerAndMachine(c)
+	unchanged := s.readDiffMachine(c, map[string]interface{}{"system_id": "same"})
+	removed := s.readDiffMachine(c, map[string]interface{}{"system_id": "gone"})
+	before := s.readDiffMachine(c, nil)
+	after := s.readDiffMachine(c, map[string]interface{}{
+		"status_message": "Deploying",
+		"power_state":    "off",
+		"tag_names":      []string{"virtual", "gpu"},
+	})
+	added := s.readDiffMachine(c, map[string]interface{}{"system_id": "new"})
+
+	diff := machine.controller.DiffMachines(
+		[]Machine{unchanged, removed, before},
+		[]Machine{added, after, unchanged},
+	)
+	c.Check(diff.Empty(), jc.IsFalse)
+	c.Check(diff.Added, jc.DeepEquals, []Machine{added})
+	c.Check(diff.Removed, jc.DeepEquals, []Machine{removed})
+	c.Assert(diff.Changed, gc.HasLen, 1)
+	change := diff.Changed[0]
+	c.Check(change.Previous, gc.Equals, before)
+	c.Check(change.Current, gc.Equals, after)
+	c.Check(change.Fields, jc.DeepEquals, []string{"Tags", "StatusMessage", "PowerState"})
+}
+
+func (s *machineSuite) TestDiffMachinesUnchanged(c *gc.C) {
+	_, machine := s.getServerAndMachine(c)
+	// Machines read separately with the same fields are the same.
+	previous := []Machine{s.readDiffMachine(c, nil)}
+	current := []Machine{s.readDiffMachine(c, nil)}
+
+	diff := machine.controller.DiffMachines(previous, current)
+	c.Check(diff.Empty(), jc.IsTrue)
+	c.Check(machine.controller.DiffMachines(nil, nil).Empty(), jc.IsTrue)
+}
@@ -0,0 +1,422 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+// Package mocks provides stub implementations of the gomaasapi interfaces
+// for use in the tests of packages that consume gomaasapi.
+package mocks
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"net/url"
+
+	"github.com/juju/gomaasapi"
+	"github.com/juju/testing"
+	"github.com/juju/utils/set"
+)
+
+// Controller is a stub implementation of gomaasapi.Controller. Every method
+// call is recorded on the embedded Stub, and the values returned are taken
+// from the exported result fields. Errors are returned in sequence from the
+// Stub, see testing.Stub.SetErrors.
+type Controller struct {
+	*testing.Stub
+
+	CapabilitiesResult      set.Strings
+	ServerVersionResult     gomaasapi.ServerVersion
+	APIVersionResult        string
+	BootResourcesResult     []gomaasapi.BootResource
+	FabricsResult           []gomaasapi.Fabric
+	SpacesResult            []gomaasapi.Space
+	SpaceResult             gomaasapi.Space
+	SubnetsResult           []gomaasapi.Subnet
+	SubnetResult            gomaasapi.Subnet
+	StaticRoutesResult      []gomaasapi.StaticRoute
+	ZonesResult             []gomaasapi.Zone
+	PoolsResult             []gomaasapi.Pool
+	DomainsResult           []gomaasapi.Domain
+	DefaultDomainResult     gomaasapi.Domain
+	MachinesResult          []gomaasapi.Machine
+	MachinesToken           string
+	MachinesChanged         bool
+	DiffMachinesResult      gomaasapi.MachineDiff
+	QueryMachinesResult     []gomaasapi.Machine
+	AgentMachinesResult     []gomaasapi.Machine
+	AllocateMachineResult   gomaasapi.Machine
+	ConstraintMatches       gomaasapi.ConstraintMatches
+	AllocateSpreadResult    []gomaasapi.SpreadPlacement
+	DevicesResult           []gomaasapi.Device
+	CreateDeviceResult      gomaasapi.Device
+	ReserveStaticHostResult gomaasapi.StaticHost
+	NodesResult             []gomaasapi.GenericNode
+	RackControllersResult   []gomaasapi.RackController
+	BootImagesSyncResult    gomaasapi.BootImagesSyncStatus
+	AddChassisResult        string
+	FilesResult             []gomaasapi.File
+	FileInfosResult         []gomaasapi.FileInfo
+	GetFileResult           gomaasapi.File
+	SyncFilesResult         gomaasapi.FileSync
+	RawResult               []byte
+	RawStatus               int
+	BulkResult              gomaasapi.BulkOperations
+	ProxyConfigResult       gomaasapi.ProxyConfig
+	NTPConfigResult         gomaasapi.NTPConfig
+	DNSConfigResult         gomaasapi.DNSConfig
+	PingResult              gomaasapi.HealthStatus
+	InventorySnapshotResult gomaasapi.InventorySnapshot
+	IPUtilizationResult     gomaasapi.IPUtilization
+	CapacitySummaryResult   []gomaasapi.CapacityGroup
+}
+
+var _ gomaasapi.Controller = (*Controller)(nil)
+
+// NewController returns a Controller stub with a fresh testing.Stub.
+func NewController() *Controller {
+	return &Controller{Stub: &testing.Stub{}}
+}
+
+// Capabilities implements gomaasapi.Controller.
+func (c *Controller) Capabilities() set.Strings {
+	c.MethodCall(c, "Capabilities")
+	return c.CapabilitiesResult
+}
+
+// HasCapability implements gomaasapi.Controller.
+func (c *Controller) HasCapability(capability gomaasapi.Capability) bool {
+	c.MethodCall(c, "HasCapability", capability)
+	return c.CapabilitiesResult.Contains(string(capability))
+}
+
+// ServerVersion implements gomaasapi.Controller.
+func (c *Controller) ServerVersion() gomaasapi.ServerVersion {
+	c.MethodCall(c, "ServerVersion")
+	return c.ServerVersionResult
+}
+
+// APIVersion implements gomaasapi.Controller.
+func (c *Controller) APIVersion() string {
+	c.MethodCall(c, "APIVersion")
+	return c.APIVersionResult
+}
+
+// Ping implements gomaasapi.Controller.
+func (c *Controller) Ping(ctx context.Context) (gomaasapi.HealthStatus, error) {
+	c.MethodCall(c, "Ping", ctx)
+	return c.PingResult, c.NextErr()
+}
+
+// BootResources implements gomaasapi.Controller.
+func (c *Controller) BootResources() ([]gomaasapi.BootResource, error) {
+	c.MethodCall(c, "BootResources")
+	return c.BootResourcesResult, c.NextErr()
+}
+
+// Fabrics implements gomaasapi.Controller.
+func (c *Controller) Fabrics() ([]gomaasapi.Fabric, error) {
+	c.MethodCall(c, "Fabrics")
+	return c.FabricsResult, c.NextErr()
+}
+
+// Spaces implements gomaasapi.Controller.
+func (c *Controller) Spaces() ([]gomaasapi.Space, error) {
+	c.MethodCall(c, "Spaces")
+	return c.SpacesResult, c.NextErr()
+}
+
+// SpaceByName implements gomaasapi.Controller.
+func (c *Controller) SpaceByName(name string) (gomaasapi.Space, error) {
+	c.MethodCall(c, "SpaceByName", name)
+	return c.SpaceResult, c.NextErr()
+}
+
+// SubnetsInSpace implements gomaasapi.Controller.
+func (c *Controller) SubnetsInSpace(space string) ([]gomaasapi.Subnet, error) {
+	c.MethodCall(c, "SubnetsInSpace", space)
+	return c.SubnetsResult, c.NextErr()
+}
+
+// SubnetByCIDR implements gomaasapi.Controller.
+func (c *Controller) SubnetByCIDR(cidr string) (gomaasapi.Subnet, error) {
+	c.MethodCall(c, "SubnetByCIDR", cidr)
+	return c.SubnetResult, c.NextErr()
+}
+
+// CreateSubnet implements gomaasapi.Controller.
+func (c *Controller) CreateSubnet(args gomaasapi.CreateSubnetArgs) (gomaasapi.Subnet, error) {
+	c.MethodCall(c, "CreateSubnet", args)
+	return c.SubnetResult, c.NextErr()
+}
+
+// StaticRoutes implements gomaasapi.Controller.
+func (c *Controller) StaticRoutes() ([]gomaasapi.StaticRoute, error) {
+	c.MethodCall(c, "StaticRoutes")
+	return c.StaticRoutesResult, c.NextErr()
+}
+
+// Zones implements gomaasapi.Controller.
+func (c *Controller) Zones() ([]gomaasapi.Zone, error) {
+	c.MethodCall(c, "Zones")
+	return c.ZonesResult, c.NextErr()
+}
+
+// Pools implements gomaasapi.Controller.
+func (c *Controller) Pools() ([]gomaasapi.Pool, error) {
+	c.MethodCall(c, "Pools")
+	return c.PoolsResult, c.NextErr()
+}
+
+// Domains implements gomaasapi.Controller.
+func (c *Controller) Domains() ([]gomaasapi.Domain, error) {
+	c.MethodCall(c, "Domains")
+	return c.DomainsResult, c.NextErr()
+}
+
+// DefaultDomain implements gomaasapi.Controller.
+func (c *Controller) DefaultDomain() (gomaasapi.Domain, error) {
+	c.MethodCall(c, "DefaultDomain")
+	return c.DefaultDomainResult, c.NextErr()
+}
+
+// SetDefaultDomain implements gomaasapi.Controller.
+func (c *Controller) SetDefaultDomain(name string) error {
+	c.MethodCall(c, "SetDefaultDomain", name)
+	return c.NextErr()
+}
+
+// Machines implements gomaasapi.Controller.
+func (c *Controller) Machines(args gomaasapi.MachinesArgs) ([]gomaasapi.Machine, error) {
+	c.MethodCall(c, "Machines", args)
+	return c.MachinesResult, c.NextErr()
+}
+
+// EachMachine implements gomaasapi.Controller. It calls f with each of
+// MachinesResult.
+func (c *Controller) EachMachine(args gomaasapi.MachinesArgs, f func(gomaasapi.Machine) error) error {
+	c.MethodCall(c, "EachMachine", args)
+	if err := c.NextErr(); err != nil {
+		return err
+	}
+	for _, m := range c.MachinesResult {
+		if err := f(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MachinesIfChanged implements gomaasapi.Controller.
+func (c *Controller) MachinesIfChanged(args gomaasapi.MachinesArgs, previousToken string) ([]gomaasapi.Machine, string, bool, error) {
+	c.MethodCall(c, "MachinesIfChanged", args, previousToken)
+	return c.MachinesResult, c.MachinesToken, c.MachinesChanged, c.NextErr()
+}
+
+// DiffMachines implements gomaasapi.Controller.
+func (c *Controller) DiffMachines(previous, current []gomaasapi.Machine) gomaasapi.MachineDiff {
+	c.MethodCall(c, "DiffMachines", previous, current)
+	return c.DiffMachinesResult
+}
+
+// QueryMachines implements gomaasapi.Controller.
+func (c *Controller) QueryMachines(query string) ([]gomaasapi.Machine, error) {
+	c.MethodCall(c, "QueryMachines", query)
+	return c.QueryMachinesResult, c.NextErr()
+}
+
+// MachinesOwnedByAgent implements gomaasapi.Controller.
+func (c *Controller) MachinesOwnedByAgent(agent string) ([]gomaasapi.Machine, error) {
+	c.MethodCall(c, "MachinesOwnedByAgent", agent)
+	return c.AgentMachinesResult, c.NextErr()
+}
+
+// AllocateMachine implements gomaasapi.Controller.
+func (c *Controller) AllocateMachine(args gomaasapi.AllocateMachineArgs) (gomaasapi.Machine, gomaasapi.ConstraintMatches, error) {
+	c.MethodCall(c, "AllocateMachine", args)
+	return c.AllocateMachineResult, c.ConstraintMatches, c.NextErr()
+}
+
+// AcquireSpecificMachine implements gomaasapi.Controller.
+func (c *Controller) AcquireSpecificMachine(ctx context.Context, systemID string, args gomaasapi.AllocateMachineArgs) (gomaasapi.Machine, gomaasapi.ConstraintMatches, error) {
+	c.MethodCall(c, "AcquireSpecificMachine", ctx, systemID, args)
+	return c.AllocateMachineResult, c.ConstraintMatches, c.NextErr()
+}
+
+// AllocateSpread implements gomaasapi.Controller.
+func (c *Controller) AllocateSpread(args gomaasapi.AllocateSpreadArgs) ([]gomaasapi.SpreadPlacement, error) {
+	c.MethodCall(c, "AllocateSpread", args)
+	return c.AllocateSpreadResult, c.NextErr()
+}
+
+// AddChassis implements gomaasapi.Controller.
+func (c *Controller) AddChassis(args gomaasapi.AddChassisArgs) (string, error) {
+	c.MethodCall(c, "AddChassis", args)
+	return c.AddChassisResult, c.NextErr()
+}
+
+// ReleaseMachines implements gomaasapi.Controller.
+func (c *Controller) ReleaseMachines(args gomaasapi.ReleaseMachinesArgs) error {
+	c.MethodCall(c, "ReleaseMachines", args)
+	return c.NextErr()
+}
+
+// WaitForRelease implements gomaasapi.Controller.
+func (c *Controller) WaitForRelease(ctx context.Context, systemIDs []string) error {
+	c.MethodCall(c, "WaitForRelease", ctx, systemIDs)
+	return c.NextErr()
+}
+
+// Devices implements gomaasapi.Controller.
+func (c *Controller) Devices(args gomaasapi.DevicesArgs) ([]gomaasapi.Device, error) {
+	c.MethodCall(c, "Devices", args)
+	return c.DevicesResult, c.NextErr()
+}
+
+// Nodes implements gomaasapi.Controller.
+func (c *Controller) Nodes(args gomaasapi.NodesArgs) ([]gomaasapi.GenericNode, error) {
+	c.MethodCall(c, "Nodes", args)
+	return c.NodesResult, c.NextErr()
+}
+
+// RackControllers implements gomaasapi.Controller.
+func (c *Controller) RackControllers() ([]gomaasapi.RackController, error) {
+	c.MethodCall(c, "RackControllers")
+	return c.RackControllersResult, c.NextErr()
+}
+
+// BootImagesSyncStatus implements gomaasapi.Controller.
+func (c *Controller) BootImagesSyncStatus() (gomaasapi.BootImagesSyncStatus, error) {
+	c.MethodCall(c, "BootImagesSyncStatus")
+	return c.BootImagesSyncResult, c.NextErr()
+}
+
+// CreateDevice implements gomaasapi.Controller.
+func (c *Controller) CreateDevice(args gomaasapi.CreateDeviceArgs) (gomaasapi.Device, error) {
+	c.MethodCall(c, "CreateDevice", args)
+	return c.CreateDeviceResult, c.NextErr()
+}
+
+// ReserveStaticHost implements gomaasapi.Controller.
+func (c *Controller) ReserveStaticHost(args gomaasapi.ReserveStaticHostArgs) (gomaasapi.StaticHost, error) {
+	c.MethodCall(c, "ReserveStaticHost", args)
+	return c.ReserveStaticHostResult, c.NextErr()
+}
+
+// Files implements gomaasapi.Controller.
+func (c *Controller) Files(prefix string) ([]gomaasapi.File, error) {
+	c.MethodCall(c, "Files", prefix)
+	return c.FilesResult, c.NextErr()
+}
+
+// FileInfos implements gomaasapi.Controller.
+func (c *Controller) FileInfos(prefix string) ([]gomaasapi.FileInfo, error) {
+	c.MethodCall(c, "FileInfos", prefix)
+	return c.FileInfosResult, c.NextErr()
+}
+
+// GetFile implements gomaasapi.Controller.
+func (c *Controller) GetFile(filename string) (gomaasapi.File, error) {
+	c.MethodCall(c, "GetFile", filename)
+	return c.GetFileResult, c.NextErr()
+}
+
+// AddFile implements gomaasapi.Controller.
+func (c *Controller) AddFile(args gomaasapi.AddFileArgs) error {
+	c.MethodCall(c, "AddFile", args)
+	return c.NextErr()
+}
+
+// SyncFiles implements gomaasapi.Controller.
+func (c *Controller) SyncFiles(prefix string, local fs.FS, dryRun bool) (gomaasapi.FileSync, error) {
+	c.MethodCall(c, "SyncFiles", prefix, local, dryRun)
+	return c.SyncFilesResult, c.NextErr()
+}
+
+// Raw implements gomaasapi.Controller.
+func (c *Controller) Raw(method, path, op string, params url.Values, body io.Reader) ([]byte, int, error) {
+	c.MethodCall(c, "Raw", method, path, op, params, body)
+	return c.RawResult, c.RawStatus, c.NextErr()
+}
+
+// Bulk implements gomaasapi.Controller.
+func (c *Controller) Bulk(args gomaasapi.BulkArgs) gomaasapi.BulkOperations {
+	c.MethodCall(c, "Bulk", args)
+	return c.BulkResult
+}
+
+// InventorySnapshot implements gomaasapi.Controller.
+func (c *Controller) InventorySnapshot(ctx context.Context, args gomaasapi.InventorySnapshotArgs) (gomaasapi.InventorySnapshot, error) {
+	c.MethodCall(c, "InventorySnapshot", ctx, args)
+	return c.InventorySnapshotResult, c.NextErr()
+}
+
+// IPUtilization implements gomaasapi.Controller.
+func (c *Controller) IPUtilization(ctx context.Context, args gomaasapi.IPUtilizationArgs) (gomaasapi.IPUtilization, error) {
+	c.MethodCall(c, "IPUtilization", ctx, args)
+	return c.IPUtilizationResult, c.NextErr()
+}
+
+// CapacitySummary implements gomaasapi.Controller.
+func (c *Controller) CapacitySummary(args gomaasapi.MachinesArgs) ([]gomaasapi.CapacityGroup, error) {
+	c.MethodCall(c, "CapacitySummary", args)
+	return c.CapacitySummaryResult, c.NextErr()
+}
+
+// ProxyConfig implements gomaasapi.Controller.
+func (c *Controller) ProxyConfig() (gomaasapi.ProxyConfig, error) {
+	c.MethodCall(c, "ProxyConfig")
+	return c.ProxyConfigResult, c.NextErr()
+}
+
+// SetProxyConfig implements gomaasapi.Controller.
+func (c *Controller) SetProxyConfig(config gomaasapi.ProxyConfig) error {
+	c.MethodCall(c, "SetProxyConfig", config)
+	return c.NextErr()
+}
+
+// NTPConfig implements gomaasapi.Controller.
+func (c *Controller) NTPConfig() (gomaasapi.NTPConfig, error) {
+	c.MethodCall(c, "NTPConfig")
+	return c.NTPConfigResult, c.NextErr()
+}
+
+// SetNTPConfig implements gomaasapi.Controller.
+func (c *Controller) SetNTPConfig(config gomaasapi.NTPConfig) error {
+	c.MethodCall(c, "SetNTPConfig", config)
+	return c.NextErr()
+}
+
+// ConfigureNetworkServices implements gomaasapi.Controller.
+func (c *Controller) ConfigureNetworkServices(args gomaasapi.ConfigureNetworkServicesArgs) error {
+	c.MethodCall(c, "ConfigureNetworkServices", args)
+	return c.NextErr()
+}
+
+// DNSConfig implements gomaasapi.Controller.
+func (c *Controller) DNSConfig() (gomaasapi.DNSConfig, error) {
+	c.MethodCall(c, "DNSConfig")
+	return c.DNSConfigResult, c.NextErr()
+}
+
+// SetDNSConfig implements gomaasapi.Controller.
+func (c *Controller) SetDNSConfig(config gomaasapi.DNSConfig) error {
+	c.MethodCall(c, "SetDNSConfig", config)
+	return c.NextErr()
+}
+
+// ConfigureDNS implements gomaasapi.Controller.
+func (c *Controller) ConfigureDNS(args gomaasapi.ConfigureDNSArgs) error {
+	c.MethodCall(c, "ConfigureDNS", args)
+	return c.NextErr()
+}
+
+// SetDefaultStorageLayout implements gomaasapi.Controller.
+func (c *Controller) SetDefaultStorageLayout(layout string) error {
+	c.MethodCall(c, "SetDefaultStorageLayout", layout)
+	return c.NextErr()
+}
+
+// SetDefaultMinHWEKernel implements gomaasapi.Controller.
+func (c *Controller) SetDefaultMinHWEKernel(kernel string) error {
+	c.MethodCall(c, "SetDefaultMinHWEKernel", kernel)
+	return c.NextErr()
+}
@@ -0,0 +1,49 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package mocks_test
+
+import (
+	"github.com/juju/errors"
+	"github.com/juju/gomaasapi"
+	"github.com/juju/gomaasapi/mocks"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type controllerSuite struct{}
+
+var _ = gc.Suite(&controllerSuite{})
+
+func (*controllerSuite) TestRecordsCalls(c *gc.C) {
+	controller := mocks.NewController()
+	args := gomaasapi.MachinesArgs{Hostnames: []string{"foo"}}
+	_, err := controller.Machines(args)
+	c.Assert(err, jc.ErrorIsNil)
+	err = controller.ReleaseMachines(gomaasapi.ReleaseMachinesArgs{SystemIDs: []string{"bar"}})
+	c.Assert(err, jc.ErrorIsNil)
+
+	controller.CheckCallNames(c, "Machines", "ReleaseMachines")
+	controller.CheckCall(c, 0, "Machines", args)
+}
+
+func (*controllerSuite) TestReturnsResultsAndErrors(c *gc.C) {
+	controller := mocks.NewController()
+	controller.FilesResult = []gomaasapi.File{nil}
+	controller.SetErrors(nil, errors.New("boom"))
+
+	files, err := controller.Files("prefix")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(files, gc.HasLen, 1)
+
+	_, err = controller.Zones()
+	c.Assert(err, gc.ErrorMatches, "boom")
+}
+
+func (*controllerSuite) TestServerVersion(c *gc.C) {
+	controller := mocks.NewController()
+	controller.ServerVersionResult = gomaasapi.ServerVersion{Subversion: "bzr1234"}
+
+	c.Assert(controller.ServerVersion().Subversion, gc.Equals, "bzr1234")
+	controller.CheckCallNames(c, "ServerVersion")
+}
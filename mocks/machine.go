@@ -0,0 +1,512 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package mocks
+
+import (
+	"context"
+	"io"
+	"net/netip"
+	"time"
+
+	"github.com/juju/gomaasapi"
+	"github.com/juju/testing"
+)
+
+// Machine is a stub implementation of gomaasapi.Machine, in the same way
+// as Controller. The values of the Machine are taken from the exported
+// result fields, and the errors of the methods that make requests are
+// returned in sequence from the Stub.
+type Machine struct {
+	*testing.Stub
+
+	OwnerDataResult             map[string]string
+	SystemIDResult              string
+	HostnameResult              string
+	FQDNResult                  string
+	NodeTypeResult              int
+	NodeTypeNameResult          string
+	TagsResult                  []string
+	DescriptionResult           string
+	WorkloadAnnotationsResult   map[string]string
+	OperatingSystemResult       string
+	DistroSeriesResult          string
+	ArchitectureResult          string
+	MemoryResult                int
+	CPUCountResult              int
+	HardwareInfoResult          map[string]string
+	ResourcesResult             gomaasapi.MachineResources
+	IPAddressesResult           []string
+	IPAddrsResult               []netip.Addr
+	PowerStateResult            string
+	DevicesResult               []gomaasapi.Device
+	TailEventsResult            <-chan gomaasapi.Event
+	TailEventsErrors            <-chan error
+	DeviceResult                gomaasapi.Device
+	StatusNameResult            string
+	StatusMessageResult         string
+	BootInterfaceResult         gomaasapi.Interface
+	InterfaceSetResult          []gomaasapi.Interface
+	PrimarySubnetResult         gomaasapi.Subnet
+	GatewayResult               string
+	AddressesInSpaceResult      []string
+	InterfaceResult             gomaasapi.Interface
+	SRIOVInterfacesResult       []gomaasapi.Interface
+	PhysicalBlockDevicesResult  []gomaasapi.BlockDevice
+	PhysicalBlockDeviceResult   gomaasapi.BlockDevice
+	BlockDevicesResult          []gomaasapi.BlockDevice
+	BlockDeviceResult           gomaasapi.BlockDevice
+	SpecialFilesystemsResult    []gomaasapi.FileSystem
+	ZoneResult                  gomaasapi.Zone
+	OwnerResult                 string
+	PoolResult                  gomaasapi.Pool
+	LockedResult                bool
+	AddressTTLResult            int
+	SwapSizeResult              uint64
+	NetbootResult               bool
+	TestResultsSummaryResult    gomaasapi.TestResultsSummary
+	LastImageSyncResult         time.Time
+	CreateDeviceResult          gomaasapi.Device
+	ScriptResultsResult         []gomaasapi.ScriptResult
+	CommissioningResultIDResult int
+	TestingResultIDResult       int
+	InstallationResultIDResult  int
+	InstallationLogResult       []byte
+	CurtinConfigResult          []byte
+	DetailsResult               gomaasapi.HardwareDetails
+	CommissioningDataResult     gomaasapi.CommissioningData
+	NodeDevicesResult           []gomaasapi.NodeDevice
+}
+
+var _ gomaasapi.Machine = (*Machine)(nil)
+
+// NewMachine returns a Machine stub with a fresh testing.Stub.
+func NewMachine() *Machine {
+	return &Machine{Stub: &testing.Stub{}}
+}
+
+// OwnerData implements gomaasapi.Machine.
+func (m *Machine) OwnerData() map[string]string {
+	m.MethodCall(m, "OwnerData")
+	return m.OwnerDataResult
+}
+
+// SetOwnerData implements gomaasapi.Machine.
+func (m *Machine) SetOwnerData(data map[string]string) error {
+	m.MethodCall(m, "SetOwnerData", data)
+	return m.NextErr()
+}
+
+// SystemID implements gomaasapi.Machine.
+func (m *Machine) SystemID() string {
+	m.MethodCall(m, "SystemID")
+	return m.SystemIDResult
+}
+
+// Hostname implements gomaasapi.Machine.
+func (m *Machine) Hostname() string {
+	m.MethodCall(m, "Hostname")
+	return m.HostnameResult
+}
+
+// FQDN implements gomaasapi.Machine.
+func (m *Machine) FQDN() string {
+	m.MethodCall(m, "FQDN")
+	return m.FQDNResult
+}
+
+// NodeType implements gomaasapi.Machine.
+func (m *Machine) NodeType() int {
+	m.MethodCall(m, "NodeType")
+	return m.NodeTypeResult
+}
+
+// NodeTypeName implements gomaasapi.Machine.
+func (m *Machine) NodeTypeName() string {
+	m.MethodCall(m, "NodeTypeName")
+	return m.NodeTypeNameResult
+}
+
+// Tags implements gomaasapi.Machine.
+func (m *Machine) Tags() []string {
+	m.MethodCall(m, "Tags")
+	return m.TagsResult
+}
+
+// Description implements gomaasapi.Machine.
+func (m *Machine) Description() string {
+	m.MethodCall(m, "Description")
+	return m.DescriptionResult
+}
+
+// WorkloadAnnotations implements gomaasapi.Machine.
+func (m *Machine) WorkloadAnnotations() map[string]string {
+	m.MethodCall(m, "WorkloadAnnotations")
+	return m.WorkloadAnnotationsResult
+}
+
+// SetWorkloadAnnotations implements gomaasapi.Machine.
+func (m *Machine) SetWorkloadAnnotations(annotations map[string]string) error {
+	m.MethodCall(m, "SetWorkloadAnnotations", annotations)
+	return m.NextErr()
+}
+
+// OperatingSystem implements gomaasapi.Machine.
+func (m *Machine) OperatingSystem() string {
+	m.MethodCall(m, "OperatingSystem")
+	return m.OperatingSystemResult
+}
+
+// DistroSeries implements gomaasapi.Machine.
+func (m *Machine) DistroSeries() string {
+	m.MethodCall(m, "DistroSeries")
+	return m.DistroSeriesResult
+}
+
+// Architecture implements gomaasapi.Machine.
+func (m *Machine) Architecture() string {
+	m.MethodCall(m, "Architecture")
+	return m.ArchitectureResult
+}
+
+// Memory implements gomaasapi.Machine.
+func (m *Machine) Memory() int {
+	m.MethodCall(m, "Memory")
+	return m.MemoryResult
+}
+
+// CPUCount implements gomaasapi.Machine.
+func (m *Machine) CPUCount() int {
+	m.MethodCall(m, "CPUCount")
+	return m.CPUCountResult
+}
+
+// HardwareInfo implements gomaasapi.Machine.
+func (m *Machine) HardwareInfo() map[string]string {
+	m.MethodCall(m, "HardwareInfo")
+	return m.HardwareInfoResult
+}
+
+// Resources implements gomaasapi.Machine.
+func (m *Machine) Resources() gomaasapi.MachineResources {
+	m.MethodCall(m, "Resources")
+	return m.ResourcesResult
+}
+
+// IPAddresses implements gomaasapi.Machine.
+func (m *Machine) IPAddresses() []string {
+	m.MethodCall(m, "IPAddresses")
+	return m.IPAddressesResult
+}
+
+// IPAddrs implements gomaasapi.Machine.
+func (m *Machine) IPAddrs() []netip.Addr {
+	m.MethodCall(m, "IPAddrs")
+	return m.IPAddrsResult
+}
+
+// PowerState implements gomaasapi.Machine.
+func (m *Machine) PowerState() string {
+	m.MethodCall(m, "PowerState")
+	return m.PowerStateResult
+}
+
+// Devices implements gomaasapi.Machine.
+func (m *Machine) Devices(args gomaasapi.DevicesArgs) ([]gomaasapi.Device, error) {
+	m.MethodCall(m, "Devices", args)
+	return m.DevicesResult, m.NextErr()
+}
+
+// TailEvents implements gomaasapi.Machine. It returns TailEventsResult and
+// TailEventsErrors.
+func (m *Machine) TailEvents(ctx context.Context, since time.Time) (<-chan gomaasapi.Event, <-chan error) {
+	m.MethodCall(m, "TailEvents", ctx, since)
+	return m.TailEventsResult, m.TailEventsErrors
+}
+
+// DeviceBySystemID implements gomaasapi.Machine.
+func (m *Machine) DeviceBySystemID(systemID string) (gomaasapi.Device, error) {
+	m.MethodCall(m, "DeviceBySystemID", systemID)
+	return m.DeviceResult, m.NextErr()
+}
+
+// StatusName implements gomaasapi.Machine.
+func (m *Machine) StatusName() string {
+	m.MethodCall(m, "StatusName")
+	return m.StatusNameResult
+}
+
+// StatusMessage implements gomaasapi.Machine.
+func (m *Machine) StatusMessage() string {
+	m.MethodCall(m, "StatusMessage")
+	return m.StatusMessageResult
+}
+
+// BootInterface implements gomaasapi.Machine.
+func (m *Machine) BootInterface() gomaasapi.Interface {
+	m.MethodCall(m, "BootInterface")
+	return m.BootInterfaceResult
+}
+
+// SetBootInterface implements gomaasapi.Machine.
+func (m *Machine) SetBootInterface(ifaceID int) error {
+	m.MethodCall(m, "SetBootInterface", ifaceID)
+	return m.NextErr()
+}
+
+// InterfaceSet implements gomaasapi.Machine.
+func (m *Machine) InterfaceSet() []gomaasapi.Interface {
+	m.MethodCall(m, "InterfaceSet")
+	return m.InterfaceSetResult
+}
+
+// PrimarySubnet implements gomaasapi.Machine.
+func (m *Machine) PrimarySubnet() gomaasapi.Subnet {
+	m.MethodCall(m, "PrimarySubnet")
+	return m.PrimarySubnetResult
+}
+
+// GatewayFor implements gomaasapi.Machine.
+func (m *Machine) GatewayFor(space string) (string, error) {
+	m.MethodCall(m, "GatewayFor", space)
+	return m.GatewayResult, m.NextErr()
+}
+
+// AddressesInSpace implements gomaasapi.Machine.
+func (m *Machine) AddressesInSpace(space string) []string {
+	m.MethodCall(m, "AddressesInSpace", space)
+	return m.AddressesInSpaceResult
+}
+
+// Interface implements gomaasapi.Machine.
+func (m *Machine) Interface(id int) gomaasapi.Interface {
+	m.MethodCall(m, "Interface", id)
+	return m.InterfaceResult
+}
+
+// SRIOVCapableInterfaces implements gomaasapi.Machine.
+func (m *Machine) SRIOVCapableInterfaces() []gomaasapi.Interface {
+	m.MethodCall(m, "SRIOVCapableInterfaces")
+	return m.SRIOVInterfacesResult
+}
+
+// PhysicalBlockDevices implements gomaasapi.Machine.
+func (m *Machine) PhysicalBlockDevices() []gomaasapi.BlockDevice {
+	m.MethodCall(m, "PhysicalBlockDevices")
+	return m.PhysicalBlockDevicesResult
+}
+
+// PhysicalBlockDevice implements gomaasapi.Machine.
+func (m *Machine) PhysicalBlockDevice(id int) gomaasapi.BlockDevice {
+	m.MethodCall(m, "PhysicalBlockDevice", id)
+	return m.PhysicalBlockDeviceResult
+}
+
+// BlockDevices implements gomaasapi.Machine.
+func (m *Machine) BlockDevices() []gomaasapi.BlockDevice {
+	m.MethodCall(m, "BlockDevices")
+	return m.BlockDevicesResult
+}
+
+// BlockDevice implements gomaasapi.Machine.
+func (m *Machine) BlockDevice(id int) gomaasapi.BlockDevice {
+	m.MethodCall(m, "BlockDevice", id)
+	return m.BlockDeviceResult
+}
+
+// SpecialFilesystems implements gomaasapi.Machine.
+func (m *Machine) SpecialFilesystems() []gomaasapi.FileSystem {
+	m.MethodCall(m, "SpecialFilesystems")
+	return m.SpecialFilesystemsResult
+}
+
+// MountSpecialFilesystem implements gomaasapi.Machine.
+func (m *Machine) MountSpecialFilesystem(args gomaasapi.MountSpecialArgs) error {
+	m.MethodCall(m, "MountSpecialFilesystem", args)
+	return m.NextErr()
+}
+
+// UnmountSpecial implements gomaasapi.Machine.
+func (m *Machine) UnmountSpecial(mountPoint string) error {
+	m.MethodCall(m, "UnmountSpecial", mountPoint)
+	return m.NextErr()
+}
+
+// Zone implements gomaasapi.Machine.
+func (m *Machine) Zone() gomaasapi.Zone {
+	m.MethodCall(m, "Zone")
+	return m.ZoneResult
+}
+
+// Owner implements gomaasapi.Machine.
+func (m *Machine) Owner() string {
+	m.MethodCall(m, "Owner")
+	return m.OwnerResult
+}
+
+// Pool implements gomaasapi.Machine.
+func (m *Machine) Pool() gomaasapi.Pool {
+	m.MethodCall(m, "Pool")
+	return m.PoolResult
+}
+
+// Locked implements gomaasapi.Machine.
+func (m *Machine) Locked() bool {
+	m.MethodCall(m, "Locked")
+	return m.LockedResult
+}
+
+// AddressTTL implements gomaasapi.Machine.
+func (m *Machine) AddressTTL() int {
+	m.MethodCall(m, "AddressTTL")
+	return m.AddressTTLResult
+}
+
+// SwapSize implements gomaasapi.Machine.
+func (m *Machine) SwapSize() uint64 {
+	m.MethodCall(m, "SwapSize")
+	return m.SwapSizeResult
+}
+
+// SetSwapSize implements gomaasapi.Machine.
+func (m *Machine) SetSwapSize(bytes uint64) error {
+	m.MethodCall(m, "SetSwapSize", bytes)
+	return m.NextErr()
+}
+
+// Netboot implements gomaasapi.Machine.
+func (m *Machine) Netboot() bool {
+	m.MethodCall(m, "Netboot")
+	return m.NetbootResult
+}
+
+// SetNetboot implements gomaasapi.Machine.
+func (m *Machine) SetNetboot(netboot bool) error {
+	m.MethodCall(m, "SetNetboot", netboot)
+	return m.NextErr()
+}
+
+// TestResultsSummary implements gomaasapi.Machine.
+func (m *Machine) TestResultsSummary() gomaasapi.TestResultsSummary {
+	m.MethodCall(m, "TestResultsSummary")
+	return m.TestResultsSummaryResult
+}
+
+// LastImageSync implements gomaasapi.Machine.
+func (m *Machine) LastImageSync() time.Time {
+	m.MethodCall(m, "LastImageSync")
+	return m.LastImageSyncResult
+}
+
+// Start implements gomaasapi.Machine.
+func (m *Machine) Start(args gomaasapi.StartArgs) error {
+	m.MethodCall(m, "Start", args)
+	return m.NextErr()
+}
+
+// CreateDevice implements gomaasapi.Machine.
+func (m *Machine) CreateDevice(args gomaasapi.CreateMachineDeviceArgs) (gomaasapi.Device, error) {
+	m.MethodCall(m, "CreateDevice", args)
+	return m.CreateDeviceResult, m.NextErr()
+}
+
+// CloneTo implements gomaasapi.Machine.
+func (m *Machine) CloneTo(destinations []string, cloneStorage, cloneNetwork bool) error {
+	m.MethodCall(m, "CloneTo", destinations, cloneStorage, cloneNetwork)
+	return m.NextErr()
+}
+
+// SetStorageLayout implements gomaasapi.Machine.
+func (m *Machine) SetStorageLayout(layout string, options map[string]string) error {
+	m.MethodCall(m, "SetStorageLayout", layout, options)
+	return m.NextErr()
+}
+
+// RestoreNetworkingConfiguration implements gomaasapi.Machine.
+func (m *Machine) RestoreNetworkingConfiguration() error {
+	m.MethodCall(m, "RestoreNetworkingConfiguration")
+	return m.NextErr()
+}
+
+// RestoreStorageConfiguration implements gomaasapi.Machine.
+func (m *Machine) RestoreStorageConfiguration() error {
+	m.MethodCall(m, "RestoreStorageConfiguration")
+	return m.NextErr()
+}
+
+// RestoreDefaultConfiguration implements gomaasapi.Machine.
+func (m *Machine) RestoreDefaultConfiguration() error {
+	m.MethodCall(m, "RestoreDefaultConfiguration")
+	return m.NextErr()
+}
+
+// ScriptResults implements gomaasapi.Machine.
+func (m *Machine) ScriptResults(args gomaasapi.ScriptResultsArgs) ([]gomaasapi.ScriptResult, error) {
+	m.MethodCall(m, "ScriptResults", args)
+	return m.ScriptResultsResult, m.NextErr()
+}
+
+// CommissioningResultID implements gomaasapi.Machine.
+func (m *Machine) CommissioningResultID() int {
+	m.MethodCall(m, "CommissioningResultID")
+	return m.CommissioningResultIDResult
+}
+
+// TestingResultID implements gomaasapi.Machine.
+func (m *Machine) TestingResultID() int {
+	m.MethodCall(m, "TestingResultID")
+	return m.TestingResultIDResult
+}
+
+// InstallationResultID implements gomaasapi.Machine.
+func (m *Machine) InstallationResultID() int {
+	m.MethodCall(m, "InstallationResultID")
+	return m.InstallationResultIDResult
+}
+
+// InstallationLog implements gomaasapi.Machine.
+func (m *Machine) InstallationLog() ([]byte, error) {
+	m.MethodCall(m, "InstallationLog")
+	return m.InstallationLogResult, m.NextErr()
+}
+
+// DownloadInstallationOutput implements gomaasapi.Machine. It writes
+// InstallationLogResult to the writer.
+func (m *Machine) DownloadInstallationOutput(w io.Writer) error {
+	m.MethodCall(m, "DownloadInstallationOutput", w)
+	if err := m.NextErr(); err != nil {
+		return err
+	}
+	_, err := w.Write(m.InstallationLogResult)
+	return err
+}
+
+// CurtinConfig implements gomaasapi.Machine.
+func (m *Machine) CurtinConfig() ([]byte, error) {
+	m.MethodCall(m, "CurtinConfig")
+	return m.CurtinConfigResult, m.NextErr()
+}
+
+// Details implements gomaasapi.Machine.
+func (m *Machine) Details() (gomaasapi.HardwareDetails, error) {
+	m.MethodCall(m, "Details")
+	return m.DetailsResult, m.NextErr()
+}
+
+// CommissioningData implements gomaasapi.Machine.
+func (m *Machine) CommissioningData() (gomaasapi.CommissioningData, error) {
+	m.MethodCall(m, "CommissioningData")
+	return m.CommissioningDataResult, m.NextErr()
+}
+
+// NodeDevices implements gomaasapi.Machine.
+func (m *Machine) NodeDevices(args gomaasapi.NodeDevicesArgs) ([]gomaasapi.NodeDevice, error) {
+	m.MethodCall(m, "NodeDevices", args)
+	return m.NodeDevicesResult, m.NextErr()
+}
+
+// Delete implements gomaasapi.Machine.
+func (m *Machine) Delete() error {
+	m.MethodCall(m, "Delete")
+	return m.NextErr()
+}
@@ -0,0 +1,45 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package mocks_test
+
+import (
+	"bytes"
+
+	"github.com/juju/errors"
+	"github.com/juju/gomaasapi"
+	"github.com/juju/gomaasapi/mocks"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type machineSuite struct{}
+
+var _ = gc.Suite(&machineSuite{})
+
+func (*machineSuite) TestRecordsCalls(c *gc.C) {
+	machine := mocks.NewMachine()
+	machine.SystemIDResult = "4y3ha3"
+	args := gomaasapi.StartArgs{DistroSeries: gomaasapi.SeriesJammy}
+
+	c.Assert(machine.SystemID(), gc.Equals, "4y3ha3")
+	err := machine.Start(args)
+	c.Assert(err, jc.ErrorIsNil)
+
+	machine.CheckCallNames(c, "SystemID", "Start")
+	machine.CheckCall(c, 1, "Start", args)
+}
+
+func (*machineSuite) TestReturnsResultsAndErrors(c *gc.C) {
+	machine := mocks.NewMachine()
+	machine.InstallationLogResult = []byte("curtin: Installation finished.")
+	machine.SetErrors(nil, errors.New("boom"))
+
+	var out bytes.Buffer
+	err := machine.DownloadInstallationOutput(&out)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(out.String(), gc.Equals, "curtin: Installation finished.")
+
+	_, err = machine.Devices(gomaasapi.DevicesArgs{})
+	c.Assert(err, gc.ErrorMatches, "boom")
+}
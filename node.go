@@ -0,0 +1,172 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+	"github.com/juju/version"
+)
+
+// node holds the fields common to all the kinds of node listed by the
+// nodes endpoint. The fields specific to machines, devices and
+// controllers are read by Machines and Devices.
+type node struct {
+	resourceURI string
+
+	systemID     string
+	hostname     string
+	fqdn         string
+	nodeType     int
+	nodeTypeName string
+}
+
+// node implements GenericNode.
+var _ GenericNode = (*node)(nil)
+
+// SystemID implements GenericNode.
+func (n *node) SystemID() string {
+	return n.systemID
+}
+
+// Hostname implements GenericNode.
+func (n *node) Hostname() string {
+	return n.hostname
+}
+
+// FQDN implements GenericNode.
+func (n *node) FQDN() string {
+	return n.fqdn
+}
+
+// NodeType implements GenericNode.
+func (n *node) NodeType() int {
+	return n.nodeType
+}
+
+// NodeTypeName implements GenericNode.
+func (n *node) NodeTypeName() string {
+	return n.nodeTypeName
+}
+
+// NodesArgs is a argument struct for selecting Nodes.
+type NodesArgs struct {
+	SystemIDs []string
+	// NodeTypes is optional, and only the nodes of these types, the
+	// NodeType constants, are returned if it is set.
+	NodeTypes []int
+	// SortBy is optional, and defaults to SortBySystemID.
+	SortBy SortBy
+}
+
+// Nodes implements Controller.
+func (c *controller) Nodes(args NodesArgs) ([]GenericNode, error) {
+	if err := args.SortBy.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	params := NewURLParams()
+	params.MaybeAddMany("id", args.SystemIDs)
+	source, err := c.getQuery("nodes", params.Values)
+	if err != nil {
+		return nil, translateError(opEntity, err)
+	}
+	nodes, err := readNodes(c.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	sortNodes(nodes, args.SortBy)
+	var result []GenericNode
+	for _, n := range nodes {
+		if len(args.NodeTypes) > 0 && !containsInt(args.NodeTypes, n.nodeType) {
+			continue
+		}
+		result = append(result, n)
+	}
+	return result, nil
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func readNodes(controllerVersion version.Number, source interface{}) ([]*node, error) {
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "node base schema check failed")
+	}
+	valid := coerced.([]interface{})
+
+	var deserialisationVersion version.Number
+	for v := range nodeDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, NewUnsupportedVersionError("no node read func for version %s", controllerVersion)
+	}
+	readFunc := nodeDeserializationFuncs[deserialisationVersion]
+	return readNodeList(valid, readFunc)
+}
+
+// readNodeList expects the values of the sourceList to be string maps.
+func readNodeList(sourceList []interface{}, readFunc nodeDeserializationFunc) ([]*node, error) {
+	result := make([]*node, 0, len(sourceList))
+	for i, value := range sourceList {
+		source, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, NewDeserializationError("unexpected value for node %d, %T", i, value)
+		}
+		node, err := readFunc(source)
+		if err != nil {
+			return nil, annotateListItem(err, "node", i, source)
+		}
+		result = append(result, node)
+	}
+	return result, nil
+}
+
+type nodeDeserializationFunc func(map[string]interface{}) (*node, error)
+
+var nodeDeserializationFuncs = map[version.Number]nodeDeserializationFunc{
+	twoDotOh: node_2_0,
+}
+
+func node_2_0(source map[string]interface{}) (*node, error) {
+	fields := schema.Fields{
+		"resource_uri": schema.String(),
+
+		"system_id":      schema.String(),
+		"hostname":       schema.String(),
+		"fqdn":           schema.String(),
+		"node_type":      schema.ForceInt(),
+		"node_type_name": schema.String(),
+	}
+	// The other fields depend on the kind of node, so they aren't checked
+	// and the nodes aren't decoded strictly.
+	checker := schema.FieldMap(fields, nil) // no defaults
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "node 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	result := &node{
+		resourceURI:  valid["resource_uri"].(string),
+		systemID:     valid["system_id"].(string),
+		hostname:     valid["hostname"].(string),
+		fqdn:         valid["fqdn"].(string),
+		nodeType:     valid["node_type"].(int),
+		nodeTypeName: valid["node_type_name"].(string),
+	}
+	return result, nil
+}
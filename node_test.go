@@ -0,0 +1,110 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/version"
+	gc "gopkg.in/check.v1"
+)
+
+type nodeSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&nodeSuite{})
+
+func (*nodeSuite) TestReadNodesBadSchema(c *gc.C) {
+	_, err := readNodes(twoDotOh, "wat?")
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err.Error(), gc.Equals, `node base schema check failed: expected list, got string("wat?")`)
+}
+
+func (*nodeSuite) TestReadNodes(c *gc.C) {
+	nodes, err := readNodes(twoDotOh, parseJSON(c, nodesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(nodes, gc.HasLen, 3)
+
+	c.Check(nodes[0].SystemID(), gc.Equals, "4y3ha3")
+	c.Check(nodes[0].Hostname(), gc.Equals, "untasted-markita")
+	c.Check(nodes[0].FQDN(), gc.Equals, "untasted-markita.maas")
+	c.Check(nodes[0].NodeType(), gc.Equals, NodeTypeMachine)
+	c.Check(nodes[0].NodeTypeName(), gc.Equals, "Machine")
+	c.Check(nodes[1].NodeType(), gc.Equals, NodeTypeDevice)
+	c.Check(nodes[2].NodeType(), gc.Equals, NodeTypeRegionAndRackController)
+}
+
+func (*nodeSuite) TestLowVersion(c *gc.C) {
+	_, err := readNodes(version.MustParse("1.9.0"), parseJSON(c, nodesResponse))
+	c.Assert(err, jc.Satisfies, IsUnsupportedVersionError)
+}
+
+func (s *nodeSuite) TestNodes(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/nodes/", http.StatusOK, nodesResponse)
+
+	nodes, err := controller.Nodes(NodesArgs{SortBy: SortByHostname})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(nodes, gc.HasLen, 3)
+	c.Check(nodes[0].Hostname(), gc.Equals, "maas-region")
+	c.Check(nodes[1].Hostname(), gc.Equals, "printer")
+	c.Check(nodes[2].Hostname(), gc.Equals, "untasted-markita")
+}
+
+func (s *nodeSuite) TestNodesNodeTypes(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/nodes/?id=4y3ha3&id=4y3haf", http.StatusOK, nodesResponse)
+
+	nodes, err := controller.Nodes(NodesArgs{
+		SystemIDs: []string{"4y3ha3", "4y3haf"},
+		NodeTypes: []int{NodeTypeMachine, NodeTypeDevice},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(nodes, gc.HasLen, 2)
+	c.Check(nodes[0].SystemID(), gc.Equals, "4y3ha3")
+	c.Check(nodes[1].SystemID(), gc.Equals, "4y3haf")
+}
+
+func (s *nodeSuite) TestNodesUnexpected(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/nodes/", http.StatusInternalServerError, "boom")
+
+	_, err := controller.Nodes(NodesArgs{})
+	c.Assert(err, jc.Satisfies, IsUnexpectedError)
+}
+
+const nodesResponse = `
+[
+    {
+        "system_id": "4y3ha3",
+        "hostname": "untasted-markita",
+        "fqdn": "untasted-markita.maas",
+        "node_type": 0,
+        "node_type_name": "Machine",
+        "status_name": "Deployed",
+        "resource_uri": "/MAAS/api/2.0/machines/4y3ha3/"
+    },
+    {
+        "system_id": "4y3haf",
+        "hostname": "printer",
+        "fqdn": "printer.maas",
+        "node_type": 1,
+        "node_type_name": "Device",
+        "parent": null,
+        "resource_uri": "/MAAS/api/2.0/devices/4y3haf/"
+    },
+    {
+        "system_id": "xbt6kc",
+        "hostname": "maas-region",
+        "fqdn": "maas-region.maas",
+        "node_type": 4,
+        "node_type_name": "Region and rack controller",
+        "version": "2.9.2",
+        "resource_uri": "/MAAS/api/2.0/regioncontrollers/xbt6kc/"
+    }
+]
+`
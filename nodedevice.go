@@ -0,0 +1,214 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+)
+
+type nodeDevice struct {
+	resourceURI string
+
+	id                  int
+	bus                 string
+	hardwareType        string
+	vendorID            string
+	productID           string
+	vendorName          string
+	productName         string
+	commissioningDriver string
+	busNumber           int
+	deviceNumber        int
+	pciAddress          string
+	numaNode            int
+}
+
+// ID implements NodeDevice.
+func (d *nodeDevice) ID() int {
+	return d.id
+}
+
+// Bus implements NodeDevice.
+func (d *nodeDevice) Bus() string {
+	return d.bus
+}
+
+// HardwareType implements NodeDevice.
+func (d *nodeDevice) HardwareType() string {
+	return d.hardwareType
+}
+
+// VendorID implements NodeDevice.
+func (d *nodeDevice) VendorID() string {
+	return d.vendorID
+}
+
+// ProductID implements NodeDevice.
+func (d *nodeDevice) ProductID() string {
+	return d.productID
+}
+
+// VendorName implements NodeDevice.
+func (d *nodeDevice) VendorName() string {
+	return d.vendorName
+}
+
+// ProductName implements NodeDevice.
+func (d *nodeDevice) ProductName() string {
+	return d.productName
+}
+
+// CommissioningDriver implements NodeDevice.
+func (d *nodeDevice) CommissioningDriver() string {
+	return d.commissioningDriver
+}
+
+// BusNumber implements NodeDevice.
+func (d *nodeDevice) BusNumber() int {
+	return d.busNumber
+}
+
+// DeviceNumber implements NodeDevice.
+func (d *nodeDevice) DeviceNumber() int {
+	return d.deviceNumber
+}
+
+// PCIAddress implements NodeDevice.
+func (d *nodeDevice) PCIAddress() string {
+	return d.pciAddress
+}
+
+// NUMANode implements NodeDevice.
+func (d *nodeDevice) NUMANode() int {
+	return d.numaNode
+}
+
+// NodeDevicesArgs is an argument struct for selecting the devices returned
+// by Machine.NodeDevices. Devices must match all of the fields that are set.
+type NodeDevicesArgs struct {
+	// Bus is "PCIE" or "USB".
+	Bus string
+	// HardwareType is one of "NODE", "CPU", "MEMORY", "STORAGE", "NETWORK"
+	// or "GPU".
+	HardwareType        string
+	VendorIDs           []string
+	ProductIDs          []string
+	VendorName          string
+	ProductName         string
+	CommissioningDriver string
+}
+
+// NodeDevices implements Machine.
+//
+// Returns
+//  - NoMatchError if the machine cannot be found, or the MAAS is older
+//    than 2.9 and so doesn't list node devices
+//  - PermissionError if the user does not have permission to read the devices
+func (m *machine) NodeDevices(args NodeDevicesArgs) ([]NodeDevice, error) {
+	params := NewURLParams()
+	params.MaybeAdd("bus", args.Bus)
+	params.MaybeAdd("hardware_type", args.HardwareType)
+	params.MaybeAddMany("vendor_id", args.VendorIDs)
+	params.MaybeAddMany("product_id", args.ProductIDs)
+	params.MaybeAdd("vendor_name", args.VendorName)
+	params.MaybeAdd("product_name", args.ProductName)
+	params.MaybeAdd("commissioning_driver", args.CommissioningDriver)
+	source, err := m.controller.getQuery(fmt.Sprintf("nodes/%s/devices", m.systemID), params.Values)
+	if err != nil {
+		return nil, translateError(opEntity, err)
+	}
+	devices, err := readNodeDevices(source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err = m.controller.checkDecoded("node device", source, devices); err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result []NodeDevice
+	for _, d := range devices {
+		result = append(result, d)
+	}
+	return result, nil
+}
+
+func readNodeDevices(source interface{}) ([]*nodeDevice, error) {
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "node device base schema check failed")
+	}
+	valid := coerced.([]interface{})
+	result := make([]*nodeDevice, 0, len(valid))
+	for i, value := range valid {
+		source := value.(map[string]interface{})
+		read, err := nodeDevice_2_0(source)
+		if err != nil {
+			return nil, annotateListItem(err, "node device", i, source)
+		}
+		result = append(result, read)
+	}
+	return result, nil
+}
+
+var nodeDevice_2_0Checker = fieldMap("node device", schema.Fields{
+	"id":                   schema.ForceInt(),
+	"resource_uri":         schema.String(),
+	"system_id":            schema.String(),
+	"bus":                  schema.String(),
+	"hardware_type":        schema.String(),
+	"vendor_id":            schema.String(),
+	"product_id":           schema.String(),
+	"vendor_name":          schema.String(),
+	"product_name":         schema.String(),
+	"commissioning_driver": schema.String(),
+	"bus_number":           schema.ForceInt(),
+	"device_number":        schema.ForceInt(),
+	"pci_address":          schema.OneOf(schema.Nil(""), schema.String()),
+	"numa_node":            schema.OneOf(schema.Nil(""), schema.ForceInt()),
+	// The block device or interface of a device are read with the
+	// machine, so they are ignored here.
+	"physical_blockdevice": schema.Any(),
+	"physical_interface":   schema.Any(),
+}, schema.Defaults{
+	"pci_address":          schema.Omit,
+	"numa_node":            schema.Omit,
+	"physical_blockdevice": schema.Omit,
+	"physical_interface":   schema.Omit,
+})
+
+func nodeDevice_2_0(source map[string]interface{}) (*nodeDevice, error) {
+	coerced, err := nodeDevice_2_0Checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "node device 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	pciAddress, _ := valid["pci_address"].(string)
+	// USB devices have no NUMA node.
+	numaNode, ok := valid["numa_node"].(int)
+	if !ok {
+		numaNode = -1
+	}
+	result := &nodeDevice{
+		resourceURI:         valid["resource_uri"].(string),
+		id:                  valid["id"].(int),
+		bus:                 valid["bus"].(string),
+		hardwareType:        valid["hardware_type"].(string),
+		vendorID:            valid["vendor_id"].(string),
+		productID:           valid["product_id"].(string),
+		vendorName:          valid["vendor_name"].(string),
+		productName:         valid["product_name"].(string),
+		commissioningDriver: valid["commissioning_driver"].(string),
+		busNumber:           valid["bus_number"].(int),
+		deviceNumber:        valid["device_number"].(int),
+		pciAddress:          pciAddress,
+		numaNode:            numaNode,
+	}
+	return result, nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+const nodeDevicesPath = "/api/2.0/nodes/4y3ha3/devices/"
+
+func (*machineSuite) TestReadNodeDevicesBadSchema(c *gc.C) {
+	_, err := readNodeDevices("wat?")
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err.Error(), gc.Equals, `node device base schema check failed: expected list, got string("wat?")`)
+
+	_, err = readNodeDevices([]map[string]interface{}{
+		{
+			"wat": "?",
+		},
+	})
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err, gc.ErrorMatches, `node device 0: node device 2.0 schema check failed: .*`)
+}
+
+func (*machineSuite) TestReadNodeDevices(c *gc.C) {
+	devices, err := readNodeDevices(parseJSON(c, nodeDevicesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(devices, gc.HasLen, 2)
+
+	gpu := devices[0]
+	c.Check(gpu.ID(), gc.Equals, 7)
+	c.Check(gpu.Bus(), gc.Equals, "PCIE")
+	c.Check(gpu.HardwareType(), gc.Equals, "GPU")
+	c.Check(gpu.VendorID(), gc.Equals, "10de")
+	c.Check(gpu.ProductID(), gc.Equals, "1db4")
+	c.Check(gpu.VendorName(), gc.Equals, "NVIDIA Corporation")
+	c.Check(gpu.ProductName(), gc.Equals, "GV100GL [Tesla V100 PCIe 16GB]")
+	c.Check(gpu.CommissioningDriver(), gc.Equals, "nouveau")
+	c.Check(gpu.BusNumber(), gc.Equals, 59)
+	c.Check(gpu.DeviceNumber(), gc.Equals, 0)
+	c.Check(gpu.PCIAddress(), gc.Equals, "0000:3b:00.0")
+	c.Check(gpu.NUMANode(), gc.Equals, 0)
+
+	usb := devices[1]
+	c.Check(usb.Bus(), gc.Equals, "USB")
+	c.Check(usb.PCIAddress(), gc.Equals, "")
+	c.Check(usb.NUMANode(), gc.Equals, -1)
+}
+
+func (s *machineSuite) TestNodeDevices(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse(nodeDevicesPath+"?bus=PCIE&vendor_id=10de&vendor_id=1002", http.StatusOK, nodeDevicesResponse)
+
+	devices, err := machine.NodeDevices(NodeDevicesArgs{
+		Bus:       "PCIE",
+		VendorIDs: []string{"10de", "1002"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(devices, gc.HasLen, 2)
+	c.Check(devices[0].ID(), gc.Equals, 7)
+}
+
+func (s *machineSuite) TestNodeDevicesNotFound(c *gc.C) {
+	_, machine := s.getServerAndMachine(c)
+	_, err := machine.NodeDevices(NodeDevicesArgs{})
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+}
+
+func (s *machineSuite) TestNodeDevicesForbidden(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse(nodeDevicesPath, http.StatusForbidden, "no")
+
+	_, err := machine.NodeDevices(NodeDevicesArgs{})
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+}
+
+const nodeDevicesResponse = `
+[
+    {
+        "id": 7,
+        "bus": "PCIE",
+        "hardware_type": "GPU",
+        "system_id": "4y3ha3",
+        "vendor_id": "10de",
+        "product_id": "1db4",
+        "vendor_name": "NVIDIA Corporation",
+        "product_name": "GV100GL [Tesla V100 PCIe 16GB]",
+        "commissioning_driver": "nouveau",
+        "bus_number": 59,
+        "device_number": 0,
+        "pci_address": "0000:3b:00.0",
+        "numa_node": 0,
+        "physical_blockdevice": null,
+        "physical_interface": null,
+        "resource_uri": "/MAAS/api/2.0/nodes/4y3ha3/devices/7/"
+    },
+    {
+        "id": 9,
+        "bus": "USB",
+        "hardware_type": "Node",
+        "system_id": "4y3ha3",
+        "vendor_id": "0624",
+        "product_id": "0248",
+        "vendor_name": "Avocent Corp.",
+        "product_name": "Virtual Keyboard and Mouse",
+        "commissioning_driver": "usbhid",
+        "bus_number": 1,
+        "device_number": 2,
+        "pci_address": null,
+        "numa_node": null,
+        "physical_blockdevice": null,
+        "physical_interface": null,
+        "resource_uri": "/MAAS/api/2.0/nodes/4y3ha3/devices/9/"
+    }
+]
+`
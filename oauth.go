@@ -4,13 +4,22 @@
 package gomaasapi
 
 import (
+	"bytes"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/juju/errors"
 )
 
 // Not a true uuidgen, but at least creates same length random
@@ -31,6 +40,33 @@ type OAuthSigner interface {
 	OAuthSign(request *http.Request) error
 }
 
+// OAuthSignatureMethod is the type of the constants that select how requests
+// to the MAAS API are signed.
+type OAuthSignatureMethod string
+
+const (
+	// PlainTextSignatureMethod sends the token secret with each request. It
+	// is the default, and is what MAAS itself uses.
+	PlainTextSignatureMethod OAuthSignatureMethod = "PLAINTEXT"
+
+	// HMACSHA1SignatureMethod signs each request with the token secret
+	// without sending the secret. Use this when PLAINTEXT signatures are
+	// not allowed, as they may be by hardened deployments.
+	HMACSHA1SignatureMethod OAuthSignatureMethod = "HMAC-SHA1"
+)
+
+// NewOAuthSigner returns a signer for the token that uses the specified
+// signature method. An empty method means PlainTextSignatureMethod.
+func NewOAuthSigner(method OAuthSignatureMethod, token *OAuthToken, realm string) (OAuthSigner, error) {
+	switch method {
+	case "", PlainTextSignatureMethod:
+		return NewPlainTestOAuthSigner(token, realm)
+	case HMACSHA1SignatureMethod:
+		return NewHMACSHA1OAuthSigner(token, realm)
+	}
+	return nil, errors.NotValidf("signature method %q", method)
+}
+
 type OAuthToken struct {
 	ConsumerKey    string
 	ConsumerSecret string
@@ -75,6 +111,151 @@ func (signer plainTextOAuthSigner) OAuthSign(request *http.Request) error {
 		authHeader = append(authHeader, fmt.Sprintf(`%s="%s"`, key, url.QueryEscape(value)))
 	}
 	strHeader := "OAuth " + strings.Join(authHeader, ", ")
-	request.Header.Add("Authorization", strHeader)
+	request.Header.Set("Authorization", strHeader)
 	return nil
 }
+
+// Trick to ensure *hmacSHA1OAuthSigner implements the OAuthSigner interface.
+var _ OAuthSigner = (*hmacSHA1OAuthSigner)(nil)
+
+type hmacSHA1OAuthSigner struct {
+	token *OAuthToken
+	realm string
+}
+
+// NewHMACSHA1OAuthSigner returns a signer that signs requests using the
+// OAuth HMAC-SHA1 method: http://oauth.net/core/1.0a/#anchor15.
+func NewHMACSHA1OAuthSigner(token *OAuthToken, realm string) (OAuthSigner, error) {
+	return &hmacSHA1OAuthSigner{token, realm}, nil
+}
+
+// OAuthSign signs the provided request using the OAuth HMAC-SHA1 method.
+func (signer hmacSHA1OAuthSigner) OAuthSign(request *http.Request) error {
+	nonce, err := generateNonce()
+	if err != nil {
+		return err
+	}
+	authData := map[string]string{
+		"oauth_consumer_key":     signer.token.ConsumerKey,
+		"oauth_token":            signer.token.TokenKey,
+		"oauth_signature_method": string(HMACSHA1SignatureMethod),
+		"oauth_timestamp":        generateTimestamp(),
+		"oauth_nonce":            nonce,
+		"oauth_version":          "1.0",
+	}
+	params, err := requestSignatureParams(request)
+	if err != nil {
+		return err
+	}
+	for key, value := range authData {
+		params.Set(key, value)
+	}
+	authData["oauth_signature"] = hmacSHA1Signature(
+		request.Method, request.URL, params,
+		signer.token.ConsumerSecret, signer.token.TokenSecret)
+	authData["realm"] = signer.realm
+
+	// Build OAuth header.
+	var authHeader []string
+	for key, value := range authData {
+		authHeader = append(authHeader, fmt.Sprintf(`%s="%s"`, key, oauthEscape(value)))
+	}
+	strHeader := "OAuth " + strings.Join(authHeader, ", ")
+	request.Header.Set("Authorization", strHeader)
+	return nil
+}
+
+// requestSignatureParams returns the query parameters of the request, and
+// the parameters in its body if the body is form encoded, as these are
+// included in the signature.
+func requestSignatureParams(request *http.Request) (url.Values, error) {
+	params := make(url.Values)
+	for key, values := range request.URL.Query() {
+		params[key] = append(params[key], values...)
+	}
+	contentType := request.Header.Get("Content-Type")
+	if request.Body == nil || !strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		return params, nil
+	}
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		return nil, err
+	}
+	request.Body.Close()
+	// Put the body back for the request to send.
+	request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	bodyParams, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range bodyParams {
+		params[key] = append(params[key], values...)
+	}
+	return params, nil
+}
+
+// hmacSHA1Signature returns the base64 encoded HMAC-SHA1 of the signature
+// base string for the request, as described in
+// http://oauth.net/core/1.0a/#anchor13.
+func hmacSHA1Signature(method string, requestURL *url.URL, params url.Values, consumerSecret, tokenSecret string) string {
+	var pairs []string
+	for key, values := range params {
+		for _, value := range values {
+			pairs = append(pairs, oauthEscape(key)+"="+oauthEscape(value))
+		}
+	}
+	// Sorting the encoded pairs sorts by name, then value.
+	sort.Strings(pairs)
+	baseString := strings.Join([]string{
+		strings.ToUpper(method),
+		oauthEscape(signatureBaseURL(requestURL)),
+		oauthEscape(strings.Join(pairs, "&")),
+	}, "&")
+	key := oauthEscape(consumerSecret) + "&" + oauthEscape(tokenSecret)
+	mac := hmac.New(sha1.New, []byte(key))
+	mac.Write([]byte(baseString))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// signatureBaseURL returns the URL without the query, with the scheme and
+// host in lower case and without the default port.
+func signatureBaseURL(requestURL *url.URL) string {
+	scheme := strings.ToLower(requestURL.Scheme)
+	host := strings.ToLower(requestURL.Host)
+	if scheme == "http" && strings.HasSuffix(host, ":80") {
+		host = strings.TrimSuffix(host, ":80")
+	}
+	if scheme == "https" && strings.HasSuffix(host, ":443") {
+		host = strings.TrimSuffix(host, ":443")
+	}
+	return scheme + "://" + host + requestURL.EscapedPath()
+}
+
+// oauthEscape percent encodes the value as required by OAuth, which differs
+// from url.QueryEscape in encoding spaces as %20.
+func oauthEscape(value string) string {
+	return strings.Replace(url.QueryEscape(value), "+", "%20", -1)
+}
+
+// refreshableSigner is an OAuthSigner that delegates to another signer,
+// which can be replaced while requests are being signed.
+type refreshableSigner struct {
+	mu     sync.Mutex
+	signer OAuthSigner
+}
+
+var _ OAuthSigner = (*refreshableSigner)(nil)
+
+// OAuthSign implements OAuthSigner.
+func (r *refreshableSigner) OAuthSign(request *http.Request) error {
+	r.mu.Lock()
+	signer := r.signer
+	r.mu.Unlock()
+	return signer.OAuthSign(request)
+}
+
+func (r *refreshableSigner) setSigner(signer OAuthSigner) {
+	r.mu.Lock()
+	r.signer = signer
+	r.mu.Unlock()
+}
@@ -0,0 +1,122 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type oauthSuite struct{}
+
+var _ = gc.Suite(&oauthSuite{})
+
+func (*oauthSuite) TestHMACSHA1SignatureKnownGood(c *gc.C) {
+	// The example from Appendix A.5 of the OAuth Core 1.0 specification.
+	requestURL, err := url.Parse("http://photos.example.net/photos?file=vacation.jpg&size=original")
+	c.Assert(err, jc.ErrorIsNil)
+	params := url.Values{
+		"file":                   {"vacation.jpg"},
+		"size":                   {"original"},
+		"oauth_consumer_key":     {"dpf43f3p2l4k3l03"},
+		"oauth_token":            {"nnch734d00sl2jdk"},
+		"oauth_signature_method": {"HMAC-SHA1"},
+		"oauth_timestamp":        {"1191242096"},
+		"oauth_nonce":            {"kllo9940pd9333jh"},
+		"oauth_version":          {"1.0"},
+	}
+	signature := hmacSHA1Signature("GET", requestURL, params, "kd94hf93k423kf44", "pfkkdhi9sl3r4s00")
+	c.Assert(signature, gc.Equals, "tR3+Ty81lMeYAr/Fid0kMTYa/WM=")
+}
+
+func (*oauthSuite) TestSignatureBaseURL(c *gc.C) {
+	for _, test := range []struct {
+		source   string
+		expected string
+	}{
+		{"http://Example.COM:80/r%20v/X?id=123", "http://example.com/r%20v/X"},
+		{"https://www.example.net:8080/?q=1", "https://www.example.net:8080/"},
+		{"https://maas.example.com:443/MAAS/api/2.0/", "https://maas.example.com/MAAS/api/2.0/"},
+	} {
+		parsed, err := url.Parse(test.source)
+		c.Assert(err, jc.ErrorIsNil)
+		c.Check(signatureBaseURL(parsed), gc.Equals, test.expected)
+	}
+}
+
+func (*oauthSuite) TestOAuthEscape(c *gc.C) {
+	c.Assert(oauthEscape("a b+c~d/e=f"), gc.Equals, "a%20b%2Bc~d%2Fe%3Df")
+}
+
+func (*oauthSuite) TestHMACSHA1SignerSignsFormBody(c *gc.C) {
+	token := &OAuthToken{ConsumerKey: "consumer", TokenKey: "token", TokenSecret: "secret"}
+	signer, err := NewHMACSHA1OAuthSigner(token, "MAAS API")
+	c.Assert(err, jc.ErrorIsNil)
+	request, err := http.NewRequest("POST", "http://maas.example.com/MAAS/api/2.0/machines/?op=allocate", strings.NewReader("name=foo&zone=bar"))
+	c.Assert(err, jc.ErrorIsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	err = signer.OAuthSign(request)
+	c.Assert(err, jc.ErrorIsNil)
+
+	header := request.Header.Get("Authorization")
+	c.Check(header, jc.HasPrefix, "OAuth ")
+	c.Check(header, jc.Contains, `oauth_signature_method="HMAC-SHA1"`)
+	c.Check(header, jc.Contains, `oauth_token="token"`)
+	c.Check(header, jc.Contains, `oauth_signature="`)
+	c.Check(header, gc.Not(jc.Contains), "secret")
+
+	// The body is still available to be sent.
+	body, err := ioutil.ReadAll(request.Body)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(string(body), gc.Equals, "name=foo&zone=bar")
+}
+
+func (*oauthSuite) TestRequestSignatureParams(c *gc.C) {
+	request, err := http.NewRequest("POST", "http://example.com/request?b5=%3D%253D&a3=a&c%40=&a2=r%20b", strings.NewReader("c2&a3=2+q"))
+	c.Assert(err, jc.ErrorIsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	params, err := requestSignatureParams(request)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(params, jc.DeepEquals, url.Values{
+		"b5": {"=%3D"},
+		"a3": {"a", "2 q"},
+		"c@": {""},
+		"a2": {"r b"},
+		"c2": {""},
+	})
+}
+
+func (*oauthSuite) TestRequestSignatureParamsIgnoresMultipart(c *gc.C) {
+	request, err := http.NewRequest("POST", "http://example.com/files/?op=", strings.NewReader("binary"))
+	c.Assert(err, jc.ErrorIsNil)
+	request.Header.Set("Content-Type", "multipart/form-data; boundary=xxx")
+	params, err := requestSignatureParams(request)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(params, jc.DeepEquals, url.Values{"op": {""}})
+}
+
+func (*oauthSuite) TestNewOAuthSigner(c *gc.C) {
+	token := &OAuthToken{}
+	signer, err := NewOAuthSigner("", token, "realm")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(signer, gc.FitsTypeOf, &plainTextOAuthSigner{})
+
+	signer, err = NewOAuthSigner(PlainTextSignatureMethod, token, "realm")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(signer, gc.FitsTypeOf, &plainTextOAuthSigner{})
+
+	signer, err = NewOAuthSigner(HMACSHA1SignatureMethod, token, "realm")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(signer, gc.FitsTypeOf, &hmacSHA1OAuthSigner{})
+
+	_, err = NewOAuthSigner("RSA-SHA1", token, "realm")
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+}
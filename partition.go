@@ -4,24 +4,42 @@
 package gomaasapi
 
 import (
+	"net/url"
+
 	"github.com/juju/errors"
 	"github.com/juju/schema"
 	"github.com/juju/version"
 )
 
 type partition struct {
+	controller *controller
+
 	resourceURI string
 
 	id   int
 	path string
 	uuid string
 
-	usedFor string
-	size    uint64
+	usedFor  string
+	size     uint64
+	type_    string
+	bootable bool
 
 	filesystem *filesystem
 }
 
+func (p *partition) updateFrom(other *partition) {
+	p.resourceURI = other.resourceURI
+	p.id = other.id
+	p.path = other.path
+	p.uuid = other.uuid
+	p.usedFor = other.usedFor
+	p.size = other.size
+	p.type_ = other.type_
+	p.bootable = other.bootable
+	p.filesystem = other.filesystem
+}
+
 // ID implements Partition.
 func (p *partition) ID() int {
 	return p.id
@@ -55,14 +73,138 @@ func (p *partition) Size() uint64 {
 	return p.size
 }
 
-func readPartitions(controllerVersion version.Number, source interface{}) ([]*partition, error) {
-	checker := schema.List(schema.StringMap(schema.Any()))
-	coerced, err := checker.Coerce(source, nil)
+// Type implements Partition.
+func (p *partition) Type() string {
+	return p.type_
+}
+
+// Bootable implements Partition.
+func (p *partition) Bootable() bool {
+	return p.bootable
+}
+
+// FormatArgs is an argument struct for calling Partition.Format.
+type FormatArgs struct {
+	// FSType is required, e.g. "ext4".
+	FSType string
+	// UUID and Label are optional.
+	UUID  string
+	Label string
+}
+
+// Validate checks the args, returning a NotValid error if the FSType is
+// missing.
+func (a *FormatArgs) Validate() error {
+	if a.FSType == "" {
+		return errors.NotValidf("missing FSType")
+	}
+	return nil
+}
+
+// Format implements Partition.
+//
+// Returns
+//  - NotValid error if the args are not valid
+//  - BadRequestError if the server rejects the filesystem
+//  - CannotCompleteError if the machine isn't Ready or Allocated
+//  - PermissionError if the user does not have permission to change the machine
+//  - NoMatchError if the partition cannot be found
+func (p *partition) Format(args FormatArgs) error {
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	params := NewURLParams()
+	params.Values.Add("fstype", args.FSType)
+	params.MaybeAdd("uuid", args.UUID)
+	params.MaybeAdd("label", args.Label)
+	return p.post("format", params.Values)
+}
+
+// Unformat implements Partition.
+//
+// Returns the same errors as Format.
+func (p *partition) Unformat() error {
+	return p.post("unformat", nil)
+}
+
+// MountArgs is an argument struct for calling Partition.Mount.
+type MountArgs struct {
+	// MountPoint is required, and is an absolute path, or "none" for
+	// filesystems such as swap that aren't mounted.
+	MountPoint string
+	// MountOptions are optional, e.g. "noatime,nodev".
+	MountOptions string
+}
+
+// Validate checks the args, returning a NotValid error if the MountPoint is
+// missing.
+func (a *MountArgs) Validate() error {
+	if a.MountPoint == "" {
+		return errors.NotValidf("missing MountPoint")
+	}
+	return nil
+}
+
+// Mount implements Partition.
+//
+// Returns the same errors as Format.
+func (p *partition) Mount(args MountArgs) error {
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	params := NewURLParams()
+	params.Values.Add("mount_point", args.MountPoint)
+	params.MaybeAdd("mount_options", args.MountOptions)
+	return p.post("mount", params.Values)
+}
+
+// Unmount implements Partition.
+//
+// Returns the same errors as Format.
+func (p *partition) Unmount() error {
+	return p.post("unmount", nil)
+}
+
+// Delete implements Partition.
+//
+// Returns
+//  - NotSupported error if the partition wasn't read from a controller
+//  - CannotCompleteError if the machine isn't Ready or Allocated
+//  - PermissionError if the user does not have permission to change the machine
+//  - NoMatchError if the partition cannot be found
+func (p *partition) Delete() error {
+	if p.controller == nil {
+		return errors.NotSupportedf("deleting partition %d not read from a controller", p.id)
+	}
+	err := p.controller.delete(p.resourceURI)
 	if err != nil {
-		return nil, WrapWithDeserializationError(err, "partition base schema check failed")
+		return translateError(opEntity, err)
 	}
-	valid := coerced.([]interface{})
+	return nil
+}
 
+// post calls the op on the partition, and updates the partition from the
+// response.
+func (p *partition) post(op string, params url.Values) error {
+	if p.controller == nil {
+		return errors.NotSupportedf("%s on partition %d not read from a controller", op, p.id)
+	}
+	result, err := p.controller.post(p.resourceURI, op, params)
+	if err != nil {
+		return translateError(opEntity, err)
+	}
+	partition, err := readPartition(p.controller.schemaVersion(), result)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err = p.controller.checkDecoded("partition", result, partition); err != nil {
+		return errors.Trace(err)
+	}
+	p.updateFrom(partition)
+	return nil
+}
+
+func getPartitionDeserializationFunc(controllerVersion version.Number) (partitionDeserializationFunc, error) {
 	var deserialisationVersion version.Number
 	for v := range partitionDeserializationFuncs {
 		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
@@ -72,7 +214,36 @@ func readPartitions(controllerVersion version.Number, source interface{}) ([]*pa
 	if deserialisationVersion == version.Zero {
 		return nil, NewUnsupportedVersionError("no partition read func for version %s", controllerVersion)
 	}
-	readFunc := partitionDeserializationFuncs[deserialisationVersion]
+	return partitionDeserializationFuncs[deserialisationVersion], nil
+}
+
+func readPartition(controllerVersion version.Number, source interface{}) (*partition, error) {
+	readFunc, err := getPartitionDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	checker := schema.StringMap(schema.Any())
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "partition base schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	return readFunc(valid)
+}
+
+func readPartitions(controllerVersion version.Number, source interface{}) ([]*partition, error) {
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "partition base schema check failed")
+	}
+	valid := coerced.([]interface{})
+
+	readFunc, err := getPartitionDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
 	return readPartitionList(valid, readFunc)
 }
 
@@ -86,7 +257,7 @@ func readPartitionList(sourceList []interface{}, readFunc partitionDeserializati
 		}
 		partition, err := readFunc(source)
 		if err != nil {
-			return nil, errors.Annotatef(err, "partition %d", i)
+			return nil, annotateListItem(err, "partition", i, source)
 		}
 		result = append(result, partition)
 	}
@@ -99,24 +270,27 @@ var partitionDeserializationFuncs = map[version.Number]partitionDeserializationF
 	twoDotOh: partition_2_0,
 }
 
-func partition_2_0(source map[string]interface{}) (*partition, error) {
-	fields := schema.Fields{
-		"resource_uri": schema.String(),
+var partition_2_0Checker = fieldMap("partition", schema.Fields{
+	"resource_uri": schema.String(),
 
-		"id":   schema.ForceInt(),
-		"path": schema.String(),
-		"uuid": schema.OneOf(schema.Nil(""), schema.String()),
+	"id":   schema.ForceInt(),
+	"path": schema.String(),
+	"uuid": schema.OneOf(schema.Nil(""), schema.String()),
 
-		"used_for": schema.String(),
-		"size":     schema.ForceUint(),
+	"used_for": schema.String(),
+	"size":     schema.ForceUint(),
+	"type":     schema.String(),
+	"bootable": schema.Bool(),
 
-		"filesystem": schema.OneOf(schema.Nil(""), schema.StringMap(schema.Any())),
-	}
-	defaults := schema.Defaults{
-		"uuid": "",
-	}
-	checker := schema.FieldMap(fields, defaults)
-	coerced, err := checker.Coerce(source, nil)
+	"filesystem": schema.OneOf(schema.Nil(""), schema.StringMap(schema.Any())),
+}, schema.Defaults{
+	"uuid":     "",
+	"type":     "partition",
+	"bootable": false,
+})
+
+func partition_2_0(source map[string]interface{}) (*partition, error) {
+	coerced, err := partition_2_0Checker.Coerce(source, nil)
 	if err != nil {
 		return nil, WrapWithDeserializationError(err, "partition 2.0 schema check failed")
 	}
@@ -139,6 +313,8 @@ func partition_2_0(source map[string]interface{}) (*partition, error) {
 		uuid:        uuid,
 		usedFor:     valid["used_for"].(string),
 		size:        valid["size"].(uint64),
+		type_:       valid["type"].(string),
+		bootable:    valid["bootable"].(bool),
 		filesystem:  filesystem,
 	}
 	return result, nil
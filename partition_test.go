@@ -4,12 +4,18 @@
 package gomaasapi
 
 import (
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
 	jc "github.com/juju/testing/checkers"
 	"github.com/juju/version"
 	gc "gopkg.in/check.v1"
 )
 
-type partitionSuite struct{}
+type partitionSuite struct {
+	testing.CleanupSuite
+}
 
 var _ = gc.Suite(&partitionSuite{})
 
@@ -35,11 +41,14 @@ func (*partitionSuite) TestReadPartitions(c *gc.C) {
 	c.Check(partition.UUID(), gc.Equals, "6199b7c9-b66f-40f6-a238-a938a58a0adf")
 	c.Check(partition.UsedFor(), gc.Equals, "ext4 formatted filesystem mounted at /")
 	c.Check(partition.Size(), gc.Equals, uint64(8581545984))
+	c.Check(partition.Type(), gc.Equals, "partition")
+	c.Check(partition.Bootable(), jc.IsFalse)
 
 	fs := partition.FileSystem()
 	c.Assert(fs, gc.NotNil)
 	c.Assert(fs.Type(), gc.Equals, "ext4")
 	c.Assert(fs.MountPoint(), gc.Equals, "/")
+	c.Assert(fs.MountOptions(), gc.Equals, "")
 }
 
 func (*partitionSuite) TestReadPartitionsNilUUID(c *gc.C) {
@@ -52,6 +61,115 @@ func (*partitionSuite) TestReadPartitionsNilUUID(c *gc.C) {
 	c.Check(partition.UUID(), gc.Equals, "")
 }
 
+func (s *partitionSuite) getServerAndPartition(c *gc.C) (*SimpleTestServer, *partition) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, "["+machineResponse+"]")
+	machines, err := controller.Machines(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	partitions := machines[0].PhysicalBlockDevice(34).Partitions()
+	c.Assert(partitions, gc.HasLen, 1)
+	server.ResetRequests()
+	return server, partitions[0].(*partition)
+}
+
+func (s *partitionSuite) TestFormat(c *gc.C) {
+	server, partition := s.getServerAndPartition(c)
+	response := updateJSONMap(c, partitionResponse, map[string]interface{}{
+		"filesystem": map[string]interface{}{
+			"fstype":        "xfs",
+			"uuid":          "fcd7745e-f1b5-4f5d-9575-9b0bb796b752",
+			"label":         "data",
+			"mount_point":   nil,
+			"mount_options": nil,
+		},
+	})
+	server.AddPostResponse(partition.resourceURI+"/?op=format", http.StatusOK, response)
+
+	err := partition.Format(FormatArgs{FSType: "xfs", Label: "data"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(partition.FileSystem().Type(), gc.Equals, "xfs")
+	c.Check(partition.FileSystem().Label(), gc.Equals, "data")
+	form := server.LastRequest().PostForm
+	c.Check(form.Get("fstype"), gc.Equals, "xfs")
+	c.Check(form.Get("label"), gc.Equals, "data")
+	_, ok := form["uuid"]
+	c.Check(ok, jc.IsFalse)
+}
+
+func (s *partitionSuite) TestFormatValidates(c *gc.C) {
+	_, partition := s.getServerAndPartition(c)
+	err := partition.Format(FormatArgs{})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *partitionSuite) TestFormatConflict(c *gc.C) {
+	server, partition := s.getServerAndPartition(c)
+	server.AddPostResponse(partition.resourceURI+"/?op=format", http.StatusConflict, "machine deployed")
+	err := partition.Format(FormatArgs{FSType: "ext4"})
+	c.Assert(err, jc.Satisfies, IsCannotCompleteError)
+}
+
+func (s *partitionSuite) TestMount(c *gc.C) {
+	server, partition := s.getServerAndPartition(c)
+	response := updateJSONMap(c, partitionResponse, map[string]interface{}{
+		"filesystem": map[string]interface{}{
+			"fstype":        "ext4",
+			"uuid":          "fcd7745e-f1b5-4f5d-9575-9b0bb796b752",
+			"mount_point":   "/srv",
+			"mount_options": "noatime",
+		},
+	})
+	server.AddPostResponse(partition.resourceURI+"/?op=mount", http.StatusOK, response)
+
+	err := partition.Mount(MountArgs{MountPoint: "/srv", MountOptions: "noatime"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(partition.FileSystem().MountPoint(), gc.Equals, "/srv")
+	c.Check(partition.FileSystem().MountOptions(), gc.Equals, "noatime")
+	form := server.LastRequest().PostForm
+	c.Check(form.Get("mount_point"), gc.Equals, "/srv")
+	c.Check(form.Get("mount_options"), gc.Equals, "noatime")
+}
+
+func (s *partitionSuite) TestMountValidates(c *gc.C) {
+	_, partition := s.getServerAndPartition(c)
+	err := partition.Mount(MountArgs{})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *partitionSuite) TestUnmount(c *gc.C) {
+	server, partition := s.getServerAndPartition(c)
+	server.AddPostResponse(partition.resourceURI+"/?op=unmount", http.StatusOK, partitionResponse)
+	err := partition.Unmount()
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *partitionSuite) TestUnformatForbidden(c *gc.C) {
+	server, partition := s.getServerAndPartition(c)
+	server.AddPostResponse(partition.resourceURI+"/?op=unformat", http.StatusForbidden, "bad user")
+	err := partition.Unformat()
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+}
+
+func (s *partitionSuite) TestDelete(c *gc.C) {
+	server, partition := s.getServerAndPartition(c)
+	server.AddDeleteResponse(partition.resourceURI+"/", http.StatusNoContent, "")
+	err := partition.Delete()
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *partitionSuite) TestDeleteConflict(c *gc.C) {
+	server, partition := s.getServerAndPartition(c)
+	server.AddDeleteResponse(partition.resourceURI+"/", http.StatusConflict, "machine deployed")
+	err := partition.Delete()
+	c.Assert(err, jc.Satisfies, IsCannotCompleteError)
+}
+
+func (*partitionSuite) TestUnboundPartition(c *gc.C) {
+	var empty partition
+	err := empty.Unmount()
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+}
+
 func (*partitionSuite) TestLowVersion(c *gc.C) {
 	_, err := readPartitions(version.MustParse("1.9.0"), parseJSON(c, partitionsResponse))
 	c.Assert(err, jc.Satisfies, IsUnsupportedVersionError)
@@ -63,8 +181,9 @@ func (*partitionSuite) TestHighVersion(c *gc.C) {
 	c.Assert(partitions, gc.HasLen, 1)
 }
 
-var partitionsResponse = `
-[
+var partitionsResponse = "[" + partitionResponse + "]"
+
+var partitionResponse = `
     {
         "bootable": false,
         "id": 1,
@@ -82,5 +201,4 @@ var partitionsResponse = `
         "used_for": "ext4 formatted filesystem mounted at /",
         "size": 8581545984
     }
-]
 `
@@ -0,0 +1,74 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+)
+
+// ActionPlan records the requests that would change the MAAS, made by a
+// controller created with it as ControllerArgs.Plan. The requests aren't
+// sent, so a tool can show what it would do before doing it. The zero
+// value is an empty plan.
+type ActionPlan struct {
+	mu       sync.Mutex
+	requests []PlannedRequest
+}
+
+// PlannedRequest is a request that would change the MAAS, as recorded in
+// an ActionPlan.
+type PlannedRequest struct {
+	// Method is POST, PUT or DELETE, or the method given to Raw.
+	Method string
+	// Path is relative to the API URL, such as "machines/", or is the
+	// absolute resource URI of an entity, such as
+	// "/MAAS/api/2.0/machines/4y3ha3/".
+	Path   string
+	Op     string
+	Params url.Values
+	// Files holds the content of the files that would be uploaded, and Body
+	// the body given to Raw.
+	Files map[string][]byte
+	Body  []byte
+}
+
+// String returns the method, path and op of the request, with its params.
+func (r PlannedRequest) String() string {
+	result := r.Method + " " + r.Path
+	if r.Op != "" {
+		result += "?op=" + r.Op
+	}
+	if len(r.Params) > 0 {
+		result += " " + r.Params.Encode()
+	}
+	return result
+}
+
+// Requests returns the requests recorded so far, in the order they were
+// made.
+func (p *ActionPlan) Requests() []PlannedRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]PlannedRequest(nil), p.requests...)
+}
+
+func (p *ActionPlan) add(request PlannedRequest) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests = append(p.requests, request)
+}
+
+// plan records the request in the controller's ActionPlan and returns a
+// PlannedError, so that the request isn't sent. It returns nil if the
+// controller has no ActionPlan.
+func (c *controller) plan(request PlannedRequest) error {
+	if c.actionPlan == nil {
+		return nil
+	}
+	c.actionPlan.add(request)
+	c.logger.Tracef("planned %s", request)
+	return NewPlannedError(fmt.Sprintf("%s %s planned, not sent", request.Method, request.Path))
+}
@@ -0,0 +1,84 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+func (s *controllerSuite) getPlanningController(c *gc.C) (Controller, *ActionPlan) {
+	plan := &ActionPlan{}
+	controller, err := NewController(ControllerArgs{
+		BaseURL: s.server.URL,
+		APIKey:  "fake:as:key",
+		Plan:    plan,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	return controller, plan
+}
+
+func (s *controllerSuite) TestPlanRecordsChanges(c *gc.C) {
+	controller, plan := s.getPlanningController(c)
+	s.server.ResetRequests()
+
+	// Reads are made.
+	machines, err := controller.Machines(MachinesArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines, gc.HasLen, 3)
+	c.Assert(s.server.RequestCount(), gc.Equals, 1)
+
+	_, _, err = controller.AllocateMachine(AllocateMachineArgs{AgentName: "juju"})
+	c.Check(err, jc.Satisfies, IsPlannedError)
+	err = controller.AddFile(AddFileArgs{Filename: "foo.txt", Content: []byte("foo")})
+	c.Check(err, jc.Satisfies, IsPlannedError)
+	err = machines[0].SetOwnerData(map[string]string{"key": "value"})
+	c.Check(err, jc.Satisfies, IsPlannedError)
+	c.Assert(s.server.RequestCount(), gc.Equals, 1)
+
+	requests := plan.Requests()
+	c.Assert(requests, gc.HasLen, 3)
+	c.Check(requests[0], jc.DeepEquals, PlannedRequest{
+		Method: "POST",
+		Path:   "machines/",
+		Op:     "allocate",
+		Params: url.Values{"agent_name": {"juju"}},
+	})
+	c.Check(requests[0].String(), gc.Equals, "POST machines/?op=allocate agent_name=juju")
+	c.Check(requests[1].Files, jc.DeepEquals, map[string][]byte{"file": []byte("foo")})
+	c.Check(requests[2].Path, gc.Equals, "/MAAS/api/2.0/machines/4y3ha3/")
+	c.Check(requests[2].Op, gc.Equals, "set_owner_data")
+}
+
+func (s *controllerSuite) TestPlanRaw(c *gc.C) {
+	controller, plan := s.getPlanningController(c)
+	s.server.ResetRequests()
+
+	_, status, err := controller.Raw("GET", "machines", "", nil, nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(status, gc.Equals, http.StatusOK)
+
+	_, _, err = controller.Raw("PATCH", "machines/4y3ha3", "", nil, bytes.NewBufferString("body"))
+	c.Assert(err, jc.Satisfies, IsPlannedError)
+	c.Assert(s.server.RequestCount(), gc.Equals, 1)
+	c.Assert(plan.Requests(), jc.DeepEquals, []PlannedRequest{{
+		Method: "PATCH",
+		Path:   "machines/4y3ha3/",
+		Body:   []byte("body"),
+	}})
+}
+
+func (*controllerSuite) TestIsPlannedError(c *gc.C) {
+	err := NewPlannedError("POST machines/ planned, not sent")
+	c.Check(IsPlannedError(err), jc.IsTrue)
+	c.Check(IsPlannedError(errors.Trace(err)), jc.IsTrue)
+	c.Check(IsPlannedError(errors.Annotate(translateError(opEntity, err), "allocating")), jc.IsTrue)
+	c.Check(IsPlannedError(NewUnexpectedError(errors.New("boom"))), jc.IsFalse)
+	c.Check(IsPlannedError(nil), jc.IsFalse)
+}
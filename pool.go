@@ -0,0 +1,105 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"github.com/juju/schema"
+	"github.com/juju/version"
+)
+
+type pool struct {
+	// Add the controller in when we need to do things with the pool.
+	// controller Controller
+
+	resourceURI string
+
+	id          int
+	name        string
+	description string
+}
+
+// ID implements Pool.
+func (p *pool) ID() int {
+	return p.id
+}
+
+// Name implements Pool.
+func (p *pool) Name() string {
+	return p.name
+}
+
+// Description implements Pool.
+func (p *pool) Description() string {
+	return p.description
+}
+
+func readPools(controllerVersion version.Number, source interface{}) ([]*pool, error) {
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "pool base schema check failed")
+	}
+	valid := coerced.([]interface{})
+
+	var deserialisationVersion version.Number
+	for v := range poolDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, NewUnsupportedVersionError("no pool read func for version %s", controllerVersion)
+	}
+	readFunc := poolDeserializationFuncs[deserialisationVersion]
+	return readPoolList(valid, readFunc)
+}
+
+// readPoolList expects the values of the sourceList to be string maps.
+func readPoolList(sourceList []interface{}, readFunc poolDeserializationFunc) ([]*pool, error) {
+	result := make([]*pool, 0, len(sourceList))
+	for i, value := range sourceList {
+		source, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, NewDeserializationError("unexpected value for pool %d, %T", i, value)
+		}
+		pool, err := readFunc(source)
+		if err != nil {
+			return nil, annotateListItem(err, "pool", i, source)
+		}
+		result = append(result, pool)
+	}
+	return result, nil
+}
+
+type poolDeserializationFunc func(map[string]interface{}) (*pool, error)
+
+// Resource pools were added in MAAS 2.3.
+var poolDeserializationFuncs = map[version.Number]poolDeserializationFunc{
+	twoDotThree: pool_2_3,
+}
+
+var pool_2_3Checker = fieldMap("pool", schema.Fields{
+	"resource_uri": schema.String(),
+	"id":           schema.ForceInt(),
+	"name":         schema.String(),
+	"description":  schema.String(),
+}, nil) // no defaults
+
+func pool_2_3(source map[string]interface{}) (*pool, error) {
+	coerced, err := pool_2_3Checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "pool 2.3 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	result := &pool{
+		resourceURI: valid["resource_uri"].(string),
+		id:          valid["id"].(int),
+		name:        valid["name"].(string),
+		description: valid["description"].(string),
+	}
+	return result, nil
+}
@@ -0,0 +1,53 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type poolSuite struct{}
+
+var _ = gc.Suite(&poolSuite{})
+
+func (*poolSuite) TestReadPoolsBadSchema(c *gc.C) {
+	_, err := readPools(twoDotThree, "wat?")
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err.Error(), gc.Equals, `pool base schema check failed: expected list, got string("wat?")`)
+}
+
+func (*poolSuite) TestReadPools(c *gc.C) {
+	pools, err := readPools(twoDotThree, parseJSON(c, poolsResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(pools, gc.HasLen, 2)
+	c.Assert(pools[0].ID(), gc.Equals, 0)
+	c.Assert(pools[0].Name(), gc.Equals, "default")
+	c.Assert(pools[0].Description(), gc.Equals, "Default pool")
+	c.Assert(pools[1].ID(), gc.Equals, 1)
+	c.Assert(pools[1].Name(), gc.Equals, "swimming")
+	c.Assert(pools[1].Description(), gc.Equals, "")
+}
+
+func (*poolSuite) TestLowVersion(c *gc.C) {
+	_, err := readPools(twoDotOh, parseJSON(c, poolsResponse))
+	c.Assert(err, jc.Satisfies, IsUnsupportedVersionError)
+	c.Assert(err.Error(), gc.Equals, `no pool read func for version 2.0.0`)
+}
+
+var poolsResponse = `
+[
+    {
+        "name": "default",
+        "description": "Default pool",
+        "id": 0,
+        "resource_uri": "/MAAS/api/2.0/resourcepool/0/"
+    }, {
+        "name": "swimming",
+        "description": "",
+        "id": 1,
+        "resource_uri": "/MAAS/api/2.0/resourcepool/1/"
+    }
+]
+`
@@ -0,0 +1,119 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+const (
+	// URLEnvVar is the environment variable holding the URL of the MAAS
+	// controller, used by LoadControllerArgs.
+	URLEnvVar = "MAAS_URL"
+
+	// APIKeyEnvVar is the environment variable holding the API key for the
+	// MAAS controller, used by LoadControllerArgs.
+	APIKeyEnvVar = "MAAS_API_KEY"
+
+	// CACertEnvVar is the optional environment variable holding the path
+	// of a PEM file of the CA certificates to trust for the MAAS
+	// controller, used by NewControllerFromEnv.
+	CACertEnvVar = "MAAS_CA_CERT"
+)
+
+// CLIProfile is a profile that the MAAS command line client has logged in
+// with.
+type CLIProfile struct {
+	Name string
+	// URL includes the API version, as "http://host:5240/MAAS/api/2.0/".
+	URL string
+	// APIKey is empty for an anonymous profile.
+	APIKey string
+}
+
+// ControllerArgs returns the ControllerArgs to connect to the MAAS
+// controller of the profile.
+func (p CLIProfile) ControllerArgs() ControllerArgs {
+	return ControllerArgs{BaseURL: p.URL, APIKey: p.APIKey}
+}
+
+// listCLIProfiles returns the output of "maas list". The profiles are kept
+// by the CLI in a SQLite database (~/.maascli.db), so rather than reading
+// that directly the CLI is asked for them.
+var listCLIProfiles = func() ([]byte, error) {
+	return exec.Command("maas", "list").Output()
+}
+
+// ParseCLIProfiles reads the profiles from the output of "maas list", which
+// has one profile per line: the name, the URL, and the API key if the
+// profile isn't anonymous.
+func ParseCLIProfiles(r io.Reader) ([]CLIProfile, error) {
+	var profiles []CLIProfile
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		switch len(fields) {
+		case 0:
+			continue
+		case 2:
+			profiles = append(profiles, CLIProfile{Name: fields[0], URL: fields[1]})
+		case 3:
+			profiles = append(profiles, CLIProfile{Name: fields[0], URL: fields[1], APIKey: fields[2]})
+		default:
+			return nil, errors.NotValidf("profile line %q", scanner.Text())
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return profiles, nil
+}
+
+// LoadControllerArgs returns ControllerArgs that use the same credentials
+// as the MAAS command line client.
+//
+// If both MAAS_URL and MAAS_API_KEY are set in the environment they are
+// used. Otherwise the named profile is looked up with "maas list". If name
+// is empty the CLI must have exactly one profile, which is used. Returns
+//  - NotValid error if only one of MAAS_URL and MAAS_API_KEY is set
+//  - NotFound error if there is no matching profile
+func LoadControllerArgs(name string) (ControllerArgs, error) {
+	baseURL, apiKey := os.Getenv(URLEnvVar), os.Getenv(APIKeyEnvVar)
+	switch {
+	case baseURL != "" && apiKey != "":
+		return ControllerArgs{BaseURL: baseURL, APIKey: apiKey}, nil
+	case baseURL != "":
+		return ControllerArgs{}, errors.NotValidf("%s set without %s", URLEnvVar, APIKeyEnvVar)
+	case apiKey != "":
+		return ControllerArgs{}, errors.NotValidf("%s set without %s", APIKeyEnvVar, URLEnvVar)
+	}
+
+	output, err := listCLIProfiles()
+	if err != nil {
+		return ControllerArgs{}, errors.Annotate(err, "listing maas CLI profiles")
+	}
+	profiles, err := ParseCLIProfiles(bytes.NewReader(output))
+	if err != nil {
+		return ControllerArgs{}, errors.Trace(err)
+	}
+	if name == "" {
+		if len(profiles) != 1 {
+			return ControllerArgs{}, errors.NotFoundf("single maas CLI profile (found %d)", len(profiles))
+		}
+		return profiles[0].ControllerArgs(), nil
+	}
+	for _, profile := range profiles {
+		if profile.Name == name {
+			return profile.ControllerArgs(), nil
+		}
+	}
+	return ControllerArgs{}, errors.NotFoundf("maas CLI profile %q", name)
+}
@@ -0,0 +1,116 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type profileSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&profileSuite{})
+
+const cliProfilesOutput = `
+admin http://10.0.0.2:5240/MAAS/api/2.0/ consumer:token:secret
+anon http://10.0.0.3:5240/MAAS/api/2.0/
+`
+
+func (s *profileSuite) SetUpTest(c *gc.C) {
+	s.CleanupSuite.SetUpTest(c)
+	s.PatchEnvironment(URLEnvVar, "")
+	s.PatchEnvironment(APIKeyEnvVar, "")
+}
+
+func (s *profileSuite) patchProfiles(output string, err error) {
+	s.PatchValue(&listCLIProfiles, func() ([]byte, error) {
+		return []byte(output), err
+	})
+}
+
+func (s *profileSuite) TestParseCLIProfiles(c *gc.C) {
+	profiles, err := ParseCLIProfiles(strings.NewReader(cliProfilesOutput))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(profiles, jc.DeepEquals, []CLIProfile{{
+		Name:   "admin",
+		URL:    "http://10.0.0.2:5240/MAAS/api/2.0/",
+		APIKey: "consumer:token:secret",
+	}, {
+		Name: "anon",
+		URL:  "http://10.0.0.3:5240/MAAS/api/2.0/",
+	}})
+}
+
+func (s *profileSuite) TestParseCLIProfilesBadLine(c *gc.C) {
+	_, err := ParseCLIProfiles(strings.NewReader("admin\n"))
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *profileSuite) TestLoadControllerArgsFromEnvironment(c *gc.C) {
+	s.PatchEnvironment(URLEnvVar, "http://maas.example.com/MAAS")
+	s.PatchEnvironment(APIKeyEnvVar, "a:b:c")
+	s.patchProfiles("", errors.New("should not be called"))
+
+	args, err := LoadControllerArgs("admin")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(args, jc.DeepEquals, ControllerArgs{
+		BaseURL: "http://maas.example.com/MAAS",
+		APIKey:  "a:b:c",
+	})
+}
+
+func (s *profileSuite) TestLoadControllerArgsPartialEnvironment(c *gc.C) {
+	s.PatchEnvironment(URLEnvVar, "http://maas.example.com/MAAS")
+
+	_, err := LoadControllerArgs("")
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(err.Error(), gc.Equals, "MAAS_URL set without MAAS_API_KEY not valid")
+}
+
+func (s *profileSuite) TestLoadControllerArgsFromProfile(c *gc.C) {
+	s.patchProfiles(cliProfilesOutput, nil)
+
+	args, err := LoadControllerArgs("admin")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(args, jc.DeepEquals, ControllerArgs{
+		BaseURL: "http://10.0.0.2:5240/MAAS/api/2.0/",
+		APIKey:  "consumer:token:secret",
+	})
+}
+
+func (s *profileSuite) TestLoadControllerArgsMissingProfile(c *gc.C) {
+	s.patchProfiles(cliProfilesOutput, nil)
+
+	_, err := LoadControllerArgs("missing")
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+	c.Assert(err.Error(), gc.Equals, `maas CLI profile "missing" not found`)
+}
+
+func (s *profileSuite) TestLoadControllerArgsSingleProfile(c *gc.C) {
+	s.patchProfiles("admin http://10.0.0.2:5240/MAAS/api/2.0/ a:b:c\n", nil)
+
+	args, err := LoadControllerArgs("")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(args.APIKey, gc.Equals, "a:b:c")
+}
+
+func (s *profileSuite) TestLoadControllerArgsAmbiguousProfile(c *gc.C) {
+	s.patchProfiles(cliProfilesOutput, nil)
+
+	_, err := LoadControllerArgs("")
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+}
+
+func (s *profileSuite) TestLoadControllerArgsCLIError(c *gc.C) {
+	s.patchProfiles("", errors.New("exec: \"maas\": executable file not found in $PATH"))
+
+	_, err := LoadControllerArgs("admin")
+	c.Assert(err, gc.ErrorMatches, `listing maas CLI profiles: exec: "maas": .*`)
+}
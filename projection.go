@@ -0,0 +1,105 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"encoding/json"
+
+	"github.com/juju/errors"
+)
+
+// MachineProjection is the type of the constants that select how much of
+// each machine is decoded by Controller.Machines. MAAS always returns the
+// machines in full, so the projection only saves decoding time.
+type MachineProjection string
+
+const (
+	// ProjectFull decodes the machines in full. It is the default.
+	ProjectFull MachineProjection = "full"
+
+	// ProjectSummary skips the interfaces, block devices and special
+	// filesystems of the machines, which are most of the listing, for
+	// callers such as dashboards that only need counts and statuses. The
+	// Machine methods that return them, and those that use them such as
+	// PrimarySubnet and Resources, behave as if the machines have none.
+	ProjectSummary MachineProjection = "summary"
+)
+
+// Validate checks the projection is known. An empty projection means
+// ProjectFull.
+func (p MachineProjection) Validate() error {
+	switch p {
+	case "", ProjectFull, ProjectSummary:
+		return nil
+	}
+	return errors.NotValidf("machine projection %q", p)
+}
+
+// summarySkippedFields are the machine fields that ProjectSummary doesn't
+// decode, with the values they are replaced with. The special filesystems
+// are optional, so they are left out.
+var summarySkippedFields = map[string]func() interface{}{
+	"boot_interface":          func() interface{} { return nil },
+	"interface_set":           func() interface{} { return []interface{}{} },
+	"physicalblockdevice_set": func() interface{} { return []interface{}{} },
+	"blockdevice_set":         func() interface{} { return []interface{}{} },
+	"special_filesystems":     nil,
+}
+
+// decodeMachines unmarshals the machines listing, skipping the parts of
+// each machine that the projection leaves out.
+func decodeMachines(projection MachineProjection, body []byte) (interface{}, error) {
+	if projection != ProjectSummary {
+		source, err := decodeJSON(body)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return source, nil
+	}
+	var raw []json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, errors.Trace(err)
+	}
+	source := make([]interface{}, len(raw))
+	for i, rawMachine := range raw {
+		machine, err := decodeMachine(projection, rawMachine)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		source[i] = machine
+	}
+	return source, nil
+}
+
+// decodeMachine unmarshals one machine of the listing, skipping the parts
+// of it that the projection leaves out.
+func decodeMachine(projection MachineProjection, body []byte) (interface{}, error) {
+	if projection != ProjectSummary {
+		source, err := decodeJSON(body)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return source, nil
+	}
+	// The skipped fields are only scanned, not decoded.
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, errors.Trace(err)
+	}
+	machine := make(map[string]interface{}, len(raw))
+	for key, value := range raw {
+		if replacement, skipped := summarySkippedFields[key]; skipped {
+			if replacement != nil {
+				machine[key] = replacement()
+			}
+			continue
+		}
+		decoded, err := decodeJSON(value)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		machine[key] = decoded
+	}
+	return machine, nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type projectionSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&projectionSuite{})
+
+func (*projectionSuite) TestValidate(c *gc.C) {
+	c.Check(MachineProjection("").Validate(), jc.ErrorIsNil)
+	c.Check(ProjectFull.Validate(), jc.ErrorIsNil)
+	c.Check(ProjectSummary.Validate(), jc.ErrorIsNil)
+	c.Check(MachineProjection("brief").Validate(), jc.Satisfies, errors.IsNotValid)
+}
+
+func (*projectionSuite) TestDecodeMachinesSummary(c *gc.C) {
+	source, err := decodeMachines(ProjectSummary, []byte(machinesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	machines := source.([]interface{})
+	c.Assert(machines, gc.HasLen, 3)
+	machine := machines[0].(map[string]interface{})
+	c.Check(machine["system_id"], gc.Equals, "4y3ha3")
+	c.Check(machine["boot_interface"], gc.IsNil)
+	c.Check(machine["interface_set"], gc.HasLen, 0)
+	c.Check(machine["physicalblockdevice_set"], gc.HasLen, 0)
+	c.Check(machine["blockdevice_set"], gc.HasLen, 0)
+	_, ok := machine["special_filesystems"]
+	c.Check(ok, jc.IsFalse)
+}
+
+func (*projectionSuite) TestDecodeMachinesBadJSON(c *gc.C) {
+	_, err := decodeMachines(ProjectSummary, []byte(`{"not": "a list"}`))
+	c.Check(err, gc.NotNil)
+	_, err = decodeMachines(ProjectFull, []byte(`[`))
+	c.Check(err, gc.NotNil)
+}
+
+func (s *projectionSuite) TestMachinesSummary(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/", http.StatusOK, machinesResponse)
+
+	machines, err := controller.Machines(MachinesArgs{Projection: ProjectSummary})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines, gc.HasLen, 3)
+	machine := machines[0]
+	c.Check(machine.SystemID(), gc.Equals, "4y3ha3")
+	c.Check(machine.StatusName(), gc.Equals, "Deployed")
+	c.Check(machine.Zone().Name(), gc.Equals, "default")
+	c.Check(machine.BootInterface(), gc.IsNil)
+	c.Check(machine.InterfaceSet(), gc.HasLen, 0)
+	c.Check(machine.BlockDevices(), gc.HasLen, 0)
+}
+
+func (s *projectionSuite) TestMachinesBadProjection(c *gc.C) {
+	_, controller := createTestServerController(c, s)
+	_, err := controller.Machines(MachinesArgs{Projection: "brief"})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
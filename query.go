@@ -0,0 +1,173 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// MachineQuery is a parsed machine search, as used by
+// Controller.QueryMachines. Args holds the terms that MAAS filters the
+// machines by, and the other terms are checked by Matches.
+//
+// A query is a list of terms separated by spaces, all of which a machine
+// must match. The terms are:
+//   - hostname:NAME, id:SYSTEM-ID and mac:ADDRESS, which may be repeated to
+//     match any of the values
+//   - domain:NAME, zone:NAME and agent:NAME
+//   - status:NAME, tag:NAME, pool:NAME and arch:NAME, compared without
+//     regard to case; arch:amd64 matches all the amd64 subarchitectures
+//   - mem and cpu, followed by one of ":", "=", ">", ">=", "<" or "<=" and
+//     a number, compared with the memory in MB or the CPU count
+type MachineQuery struct {
+	Args       MachinesArgs
+	predicates []func(Machine) bool
+}
+
+// queryTermRE splits a query term into the key, the operator and the value.
+var queryTermRE = regexp.MustCompile(`^([a-z_]+)(:|>=|<=|>|<|=)(.+)$`)
+
+// ParseMachineQuery parses the query, returning a NotValid error for terms
+// that aren't understood.
+func ParseMachineQuery(query string) (MachineQuery, error) {
+	var result MachineQuery
+	for _, term := range strings.Fields(query) {
+		match := queryTermRE.FindStringSubmatch(term)
+		if match == nil {
+			return MachineQuery{}, errors.NotValidf("query term %q", term)
+		}
+		key, op, value := match[1], match[2], match[3]
+		if key == "mem" || key == "cpu" {
+			predicate, err := numericPredicate(key, op, value)
+			if err != nil {
+				return MachineQuery{}, errors.NotValidf("query term %q", term)
+			}
+			result.predicates = append(result.predicates, predicate)
+			continue
+		}
+		if op != ":" && op != "=" {
+			return MachineQuery{}, errors.NotValidf("query term %q", term)
+		}
+		if err := result.addTerm(key, value); err != nil {
+			return MachineQuery{}, errors.Annotatef(err, "query term %q", term)
+		}
+	}
+	return result, nil
+}
+
+func (q *MachineQuery) addTerm(key, value string) error {
+	setOnce := func(field *string) error {
+		if *field != "" {
+			return errors.NotValidf("repeated %s", key)
+		}
+		*field = value
+		return nil
+	}
+	switch key {
+	case "hostname":
+		q.Args.Hostnames = append(q.Args.Hostnames, value)
+	case "id":
+		q.Args.SystemIDs = append(q.Args.SystemIDs, value)
+	case "mac":
+		q.Args.MACAddresses = append(q.Args.MACAddresses, value)
+	case "domain":
+		return setOnce(&q.Args.Domain)
+	case "zone":
+		return setOnce(&q.Args.Zone)
+	case "agent":
+		return setOnce(&q.Args.AgentName)
+	case "status":
+		q.predicates = append(q.predicates, func(m Machine) bool {
+			return strings.EqualFold(m.StatusName(), value)
+		})
+	case "tag":
+		q.predicates = append(q.predicates, func(m Machine) bool {
+			for _, tag := range m.Tags() {
+				if strings.EqualFold(tag, value) {
+					return true
+				}
+			}
+			return false
+		})
+	case "pool":
+		q.predicates = append(q.predicates, func(m Machine) bool {
+			pool := m.Pool()
+			return pool != nil && strings.EqualFold(pool.Name(), value)
+		})
+	case "arch":
+		q.predicates = append(q.predicates, func(m Machine) bool {
+			arch := m.Architecture()
+			if !strings.Contains(value, "/") {
+				arch, _, _ = strings.Cut(arch, "/")
+			}
+			return strings.EqualFold(arch, value)
+		})
+	default:
+		return errors.NotValidf("key %q", key)
+	}
+	return nil
+}
+
+// numericPredicate returns a predicate comparing the machine's memory or
+// CPU count with the value.
+func numericPredicate(key, op, value string) (func(Machine) bool, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	get := Machine.Memory
+	if key == "cpu" {
+		get = Machine.CPUCount
+	}
+	var compare func(a, b int) bool
+	switch op {
+	case ":", "=":
+		compare = func(a, b int) bool { return a == b }
+	case ">":
+		compare = func(a, b int) bool { return a > b }
+	case ">=":
+		compare = func(a, b int) bool { return a >= b }
+	case "<":
+		compare = func(a, b int) bool { return a < b }
+	case "<=":
+		compare = func(a, b int) bool { return a <= b }
+	}
+	return func(m Machine) bool {
+		return compare(get(m), n)
+	}, nil
+}
+
+// Matches reports whether the machine matches the terms of the query that
+// MAAS can't filter by.
+func (q *MachineQuery) Matches(m Machine) bool {
+	for _, predicate := range q.predicates {
+		if !predicate(m) {
+			return false
+		}
+	}
+	return true
+}
+
+// QueryMachines implements Controller.
+func (c *controller) QueryMachines(query string) ([]Machine, error) {
+	parsed, err := ParseMachineQuery(query)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	machines, err := c.Machines(parsed.Args)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result []Machine
+	for _, m := range machines {
+		if parsed.Matches(m) {
+			result = append(result, m)
+		}
+	}
+	return result, nil
+}
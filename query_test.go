@@ -0,0 +1,120 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type querySuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&querySuite{})
+
+func (*querySuite) TestParseMachineQueryArgs(c *gc.C) {
+	query, err := ParseMachineQuery("hostname:a hostname:b id:4y3ha3 mac:52:54:00:55:b6:80 domain:maas zone=az1 agent:juju")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(query.Args, jc.DeepEquals, MachinesArgs{
+		Hostnames:    []string{"a", "b"},
+		SystemIDs:    []string{"4y3ha3"},
+		MACAddresses: []string{"52:54:00:55:b6:80"},
+		Domain:       "maas",
+		Zone:         "az1",
+		AgentName:    "juju",
+	})
+	c.Assert(query.predicates, gc.HasLen, 0)
+}
+
+func (*querySuite) TestParseMachineQueryInvalid(c *gc.C) {
+	for i, test := range []struct {
+		query  string
+		errMsg string
+	}{{
+		query:  "Ready",
+		errMsg: `query term "Ready" not valid`,
+	}, {
+		query:  "colour:blue",
+		errMsg: `query term "colour:blue": key "colour" not valid`,
+	}, {
+		query:  "zone:a zone:b",
+		errMsg: `query term "zone:b": repeated zone not valid`,
+	}, {
+		query:  "status>Ready",
+		errMsg: `query term "status>Ready" not valid`,
+	}, {
+		query:  "mem>=lots",
+		errMsg: `query term "mem>=lots" not valid`,
+	}} {
+		c.Logf("test %d: %s", i, test.query)
+		_, err := ParseMachineQuery(test.query)
+		c.Check(err, jc.Satisfies, errors.IsNotValid)
+		c.Check(err, gc.ErrorMatches, test.errMsg)
+	}
+}
+
+func (*querySuite) TestMatches(c *gc.C) {
+	gpu := &machine{
+		statusName:   "Ready",
+		tags:         []string{"virtual", "GPU"},
+		memory:       65536,
+		cpuCount:     16,
+		architecture: "amd64/generic",
+		pool:         &pool{name: "compute"},
+	}
+	small := &machine{
+		statusName:   "Deployed",
+		memory:       2048,
+		cpuCount:     2,
+		architecture: "arm64/generic",
+	}
+	for i, test := range []struct {
+		query   string
+		matches []bool
+	}{
+		{"", []bool{true, true}},
+		{"status:ready", []bool{true, false}},
+		{"tag:gpu", []bool{true, false}},
+		{"pool:compute", []bool{true, false}},
+		{"arch:arm64", []bool{false, true}},
+		{"arch:amd64/generic", []bool{true, false}},
+		{"mem>=65536", []bool{true, false}},
+		{"mem<65536", []bool{false, true}},
+		{"cpu:2", []bool{false, true}},
+		{"cpu>2 cpu<=16", []bool{true, false}},
+		{"status:Ready mem<1024", []bool{false, false}},
+	} {
+		c.Logf("test %d: %s", i, test.query)
+		query, err := ParseMachineQuery(test.query)
+		c.Assert(err, jc.ErrorIsNil)
+		c.Check(query.Matches(gpu), gc.Equals, test.matches[0])
+		c.Check(query.Matches(small), gc.Equals, test.matches[1])
+	}
+}
+
+func (s *querySuite) TestQueryMachines(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/?zone=default", http.StatusOK, machinesResponse)
+
+	machines, err := controller.QueryMachines("zone:default status:Ready")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(machines, gc.HasLen, 2)
+	for _, m := range machines {
+		c.Check(m.StatusName(), gc.Equals, "Ready")
+	}
+}
+
+func (s *querySuite) TestQueryMachinesInvalid(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.ResetRequests()
+
+	_, err := controller.QueryMachines("status")
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(server.RequestCount(), gc.Equals, 0)
+}
@@ -0,0 +1,274 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+	"github.com/juju/version"
+)
+
+// rackController implements RackController.
+var _ RackController = (*rackController)(nil)
+
+type rackController struct {
+	node
+
+	controller *controller
+
+	interfaceSet []*interface_
+}
+
+// Interfaces implements RackController.
+func (r *rackController) Interfaces() []Interface {
+	result := make([]Interface, len(r.interfaceSet))
+	for i, v := range r.interfaceSet {
+		result[i] = v
+	}
+	return result
+}
+
+func (r *rackController) bind(c *controller) {
+	r.controller = c
+	for _, iface := range r.interfaceSet {
+		iface.bind(c)
+	}
+}
+
+// RackControllers implements Controller.
+func (c *controller) RackControllers() ([]RackController, error) {
+	source, err := c.get("rackcontrollers")
+	if err != nil {
+		return nil, translateError(opEntity, err)
+	}
+	racks, err := readRackControllers(c.schemaVersion(), source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result []RackController
+	for _, r := range racks {
+		r.bind(c)
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+// The values of RackBootImages.Status.
+const (
+	BootImagesSynced    = "synced"
+	BootImagesSyncing   = "syncing"
+	BootImagesOutOfSync = "out-of-sync"
+	BootImagesUnknown   = "unknown"
+)
+
+// RackBootImage is a boot image held by a rack controller.
+type RackBootImage struct {
+	// Name is the OS and release, such as "ubuntu/jammy".
+	Name         string
+	Architecture string
+	Subarches    []string
+}
+
+// RackBootImages is the boot image import status of a rack controller.
+type RackBootImages struct {
+	SystemID string
+	Hostname string
+	// Status is one of the BootImages* constants.
+	Status string
+	// Connected is false if the region can't reach the rack controller, in
+	// which case its status is BootImagesUnknown.
+	Connected bool
+	Images    []RackBootImage
+}
+
+// BootImagesSyncStatus is the boot image import status of all the rack
+// controllers, as returned by Controller.BootImagesSyncStatus.
+type BootImagesSyncStatus struct {
+	Racks []RackBootImages
+}
+
+// Synced reports whether there are rack controllers, and all of them have
+// synced their boot images with the region.
+func (s BootImagesSyncStatus) Synced() bool {
+	if len(s.Racks) == 0 {
+		return false
+	}
+	for _, rack := range s.Racks {
+		if rack.Status != BootImagesSynced {
+			return false
+		}
+	}
+	return true
+}
+
+// Pending returns the rack controllers that haven't synced their boot
+// images.
+func (s BootImagesSyncStatus) Pending() []RackBootImages {
+	var result []RackBootImages
+	for _, rack := range s.Racks {
+		if rack.Status != BootImagesSynced {
+			result = append(result, rack)
+		}
+	}
+	return result
+}
+
+// BootImagesSyncStatus implements Controller.
+func (c *controller) BootImagesSyncStatus() (BootImagesSyncStatus, error) {
+	racks, err := c.RackControllers()
+	if err != nil {
+		return BootImagesSyncStatus{}, errors.Trace(err)
+	}
+	var status BootImagesSyncStatus
+	for _, r := range racks {
+		images, err := r.(*rackController).bootImages()
+		if err != nil {
+			return BootImagesSyncStatus{}, errors.Trace(err)
+		}
+		status.Racks = append(status.Racks, images)
+	}
+	return status, nil
+}
+
+// bootImages returns the boot images of the rack controller.
+func (r *rackController) bootImages() (RackBootImages, error) {
+	source, err := r.controller._get(r.resourceURI, "list_boot_images", nil)
+	if err != nil {
+		return RackBootImages{}, errors.Annotatef(translateError(opEntity, err), "rack controller %q", r.systemID)
+	}
+	images, err := readRackBootImages(source)
+	if err != nil {
+		return RackBootImages{}, errors.Annotatef(err, "rack controller %q", r.systemID)
+	}
+	if err = r.controller.checkDecoded("rack boot images", source, images); err != nil {
+		return RackBootImages{}, errors.Trace(err)
+	}
+	images.SystemID = r.systemID
+	images.Hostname = r.hostname
+	return images, nil
+}
+
+var rackBootImagesChecker = func() schema.Checker {
+	imageFields := schema.Fields{
+		"name":         schema.String(),
+		"architecture": schema.String(),
+		"subarches":    schema.List(schema.String()),
+	}
+	imageDefaults := schema.Defaults{
+		"subarches": schema.Omit,
+	}
+	fields := schema.Fields{
+		"status":    schema.String(),
+		"connected": schema.Bool(),
+		"images":    schema.List(schema.FieldMap(imageFields, imageDefaults)),
+	}
+	defaults := schema.Defaults{
+		"connected": true,
+	}
+	return fieldMap("rack boot images", fields, defaults)
+}()
+
+func readRackBootImages(source interface{}) (RackBootImages, error) {
+	coerced, err := rackBootImagesChecker.Coerce(source, nil)
+	if err != nil {
+		return RackBootImages{}, WrapWithDeserializationError(err, "rack boot images schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	result := RackBootImages{
+		Status:    valid["status"].(string),
+		Connected: valid["connected"].(bool),
+	}
+	for _, value := range valid["images"].([]interface{}) {
+		image := value.(map[string]interface{})
+		var subarches []string
+		if list, ok := image["subarches"].([]interface{}); ok {
+			for _, subarch := range list {
+				subarches = append(subarches, subarch.(string))
+			}
+		}
+		result.Images = append(result.Images, RackBootImage{
+			Name:         image["name"].(string),
+			Architecture: image["architecture"].(string),
+			Subarches:    subarches,
+		})
+	}
+	return result, nil
+}
+
+func readRackControllers(controllerVersion version.Number, source interface{}) ([]*rackController, error) {
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "rack controller base schema check failed")
+	}
+	valid := coerced.([]interface{})
+
+	var deserialisationVersion version.Number
+	for v := range rackControllerDeserializationFuncs {
+		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
+			deserialisationVersion = v
+		}
+	}
+	if deserialisationVersion == version.Zero {
+		return nil, NewUnsupportedVersionError("no rack controller read func for version %s", controllerVersion)
+	}
+	readFunc := rackControllerDeserializationFuncs[deserialisationVersion]
+	return readRackControllerList(valid, readFunc)
+}
+
+// readRackControllerList expects the values of the sourceList to be string maps.
+func readRackControllerList(sourceList []interface{}, readFunc rackControllerDeserializationFunc) ([]*rackController, error) {
+	result := make([]*rackController, 0, len(sourceList))
+	for i, value := range sourceList {
+		source, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, NewDeserializationError("unexpected value for rack controller %d, %T", i, value)
+		}
+		rack, err := readFunc(source)
+		if err != nil {
+			return nil, annotateListItem(err, "rack controller", i, source)
+		}
+		result = append(result, rack)
+	}
+	return result, nil
+}
+
+type rackControllerDeserializationFunc func(map[string]interface{}) (*rackController, error)
+
+var rackControllerDeserializationFuncs = map[version.Number]rackControllerDeserializationFunc{
+	twoDotOh: rackController_2_0,
+}
+
+func rackController_2_0(source map[string]interface{}) (*rackController, error) {
+	node, err := node_2_0(source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	fields := schema.Fields{
+		"interface_set": schema.List(schema.StringMap(schema.Any())),
+	}
+	// Like nodes, rack controllers have many fields that aren't needed
+	// here, so they aren't decoded strictly.
+	checker := schema.FieldMap(fields, nil) // no defaults
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "rack controller 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	interfaceSet, err := readInterfaceList(valid["interface_set"].([]interface{}), interface_2_0)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	result := &rackController{
+		node:         *node,
+		interfaceSet: interfaceSet,
+	}
+	return result, nil
+}
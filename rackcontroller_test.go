@@ -0,0 +1,171 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/version"
+	gc "gopkg.in/check.v1"
+)
+
+type rackControllerSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&rackControllerSuite{})
+
+// rackControllersResponse is a list of one rack controller, with the
+// interfaces of machineResponse.
+func rackControllersResponse(c *gc.C) string {
+	machine := parseJSON(c, machineResponse).(map[string]interface{})
+	rack := updateJSONMap(c, rackControllerResponse, map[string]interface{}{
+		"interface_set": machine["interface_set"],
+	})
+	return "[" + rack + "]"
+}
+
+func (*rackControllerSuite) TestReadRackControllersBadSchema(c *gc.C) {
+	_, err := readRackControllers(twoDotOh, "wat?")
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err.Error(), gc.Equals, `rack controller base schema check failed: expected list, got string("wat?")`)
+}
+
+func (*rackControllerSuite) TestReadRackControllers(c *gc.C) {
+	racks, err := readRackControllers(twoDotOh, parseJSON(c, rackControllersResponse(c)))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(racks, gc.HasLen, 1)
+
+	rack := racks[0]
+	c.Check(rack.SystemID(), gc.Equals, "4y3h7n")
+	c.Check(rack.Hostname(), gc.Equals, "maas-rack")
+	c.Check(rack.FQDN(), gc.Equals, "maas-rack.maas")
+	c.Check(rack.NodeType(), gc.Equals, NodeTypeRackController)
+	interfaces := rack.Interfaces()
+	c.Assert(interfaces, gc.HasLen, 2)
+	c.Check(interfaces[0].Name(), gc.Equals, "eth0")
+}
+
+func (*rackControllerSuite) TestLowVersion(c *gc.C) {
+	_, err := readRackControllers(version.MustParse("1.9.0"), parseJSON(c, rackControllersResponse(c)))
+	c.Assert(err, jc.Satisfies, IsUnsupportedVersionError)
+}
+
+func (s *rackControllerSuite) TestRackControllers(c *gc.C) {
+	server, ctrl := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/rackcontrollers/", http.StatusOK, rackControllersResponse(c))
+
+	racks, err := ctrl.RackControllers()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(racks, gc.HasLen, 1)
+	// The interfaces are bound to the controller, so their VLANs can
+	// be changed.
+	iface := racks[0].Interfaces()[0].(*interface_)
+	c.Check(iface.controller, gc.Equals, ctrl.(*controller))
+}
+
+func (s *rackControllerSuite) TestRackControllersUnexpected(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/rackcontrollers/", http.StatusInternalServerError, "boom")
+
+	_, err := controller.RackControllers()
+	c.Assert(err, jc.Satisfies, IsUnexpectedError)
+}
+
+const rackBootImagesPath = "/MAAS/api/2.0/rackcontrollers/4y3h7n/?op=list_boot_images"
+
+func (*rackControllerSuite) TestReadRackBootImages(c *gc.C) {
+	images, err := readRackBootImages(parseJSON(c, rackBootImagesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(images, jc.DeepEquals, RackBootImages{
+		Status:    BootImagesSynced,
+		Connected: true,
+		Images: []RackBootImage{{
+			Name:         "ubuntu/jammy",
+			Architecture: "amd64",
+			Subarches:    []string{"generic", "hwe-22.04"},
+		}},
+	})
+}
+
+func (*rackControllerSuite) TestReadRackBootImagesBadSchema(c *gc.C) {
+	_, err := readRackBootImages(map[string]interface{}{"status": 42})
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+}
+
+func (s *rackControllerSuite) TestBootImagesSyncStatus(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/rackcontrollers/", http.StatusOK, "["+rackControllerResponse+"]")
+	server.AddGetResponse(rackBootImagesPath, http.StatusOK, rackBootImagesResponse)
+
+	status, err := controller.BootImagesSyncStatus()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(status.Racks, gc.HasLen, 1)
+	rack := status.Racks[0]
+	c.Check(rack.SystemID, gc.Equals, "4y3h7n")
+	c.Check(rack.Hostname, gc.Equals, "maas-rack")
+	c.Check(rack.Images, gc.HasLen, 1)
+	c.Check(status.Synced(), jc.IsTrue)
+	c.Check(status.Pending(), gc.HasLen, 0)
+}
+
+func (s *rackControllerSuite) TestBootImagesSyncStatusSyncing(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/rackcontrollers/", http.StatusOK, "["+rackControllerResponse+"]")
+	server.AddGetResponse(rackBootImagesPath, http.StatusOK, `{"status": "syncing", "connected": true, "images": []}`)
+
+	status, err := controller.BootImagesSyncStatus()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(status.Synced(), jc.IsFalse)
+	c.Assert(status.Pending(), gc.HasLen, 1)
+	c.Check(status.Pending()[0].Status, gc.Equals, BootImagesSyncing)
+}
+
+func (s *rackControllerSuite) TestBootImagesSyncStatusNoRacks(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/rackcontrollers/", http.StatusOK, "[]")
+
+	status, err := controller.BootImagesSyncStatus()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(status.Synced(), jc.IsFalse)
+}
+
+func (s *rackControllerSuite) TestBootImagesSyncStatusUnexpected(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/rackcontrollers/", http.StatusOK, "["+rackControllerResponse+"]")
+	server.AddGetResponse(rackBootImagesPath, http.StatusInternalServerError, "boom")
+
+	_, err := controller.BootImagesSyncStatus()
+	c.Assert(err, jc.Satisfies, IsUnexpectedError)
+	c.Assert(err, gc.ErrorMatches, `rack controller "4y3h7n": .*`)
+}
+
+const rackBootImagesResponse = `
+{
+    "images": [
+        {
+            "name": "ubuntu/jammy",
+            "architecture": "amd64",
+            "subarches": ["generic", "hwe-22.04"]
+        }
+    ],
+    "connected": true,
+    "status": "synced"
+}
+`
+
+const rackControllerResponse = `
+{
+    "system_id": "4y3h7n",
+    "hostname": "maas-rack",
+    "fqdn": "maas-rack.maas",
+    "node_type": 2,
+    "node_type_name": "Rack controller",
+    "version": "2.9.2",
+    "interface_set": [],
+    "resource_uri": "/MAAS/api/2.0/rackcontrollers/4y3h7n/"
+}
+`
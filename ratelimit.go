@@ -0,0 +1,159 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/utils/clock"
+)
+
+// OtherEndpoints is the key in ControllerArgs.RateLimits for the limit on
+// the endpoint families that don't have a limit of their own.
+const OtherEndpoints = "*"
+
+// RateLimit limits the rate of requests with a token bucket. Up to Burst
+// requests are made straight away, after which requests are delayed so
+// that on average no more than Rate are made each second.
+type RateLimit struct {
+	// Rate is the number of requests allowed per second (required).
+	Rate float64
+	// Burst is the number of requests that can be made at once, and
+	// defaults to 1.
+	Burst int
+}
+
+// Validate checks the rate is positive and the burst isn't negative.
+func (r RateLimit) Validate() error {
+	if r.Rate <= 0 {
+		return errors.NotValidf("rate %v", r.Rate)
+	}
+	if r.Burst < 0 {
+		return errors.NotValidf("burst %d", r.Burst)
+	}
+	return nil
+}
+
+// tokenBucket is the limiter for a single endpoint family.
+type tokenBucket struct {
+	clock clock.Clock
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(clock clock.Clock, limit RateLimit) *tokenBucket {
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{
+		clock:  clock,
+		rate:   limit.Rate,
+		burst:  burst,
+		tokens: burst,
+		last:   clock.Now(),
+	}
+}
+
+// reserve takes a token from the bucket, and returns how long the caller
+// must wait before using it. Tokens are taken in the order reserve is
+// called, so waiting requests are made in order.
+func (b *tokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.clock.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// wait blocks until a request can be made, or the context is done, in
+// which case the token is given back and the context's error returned.
+func (b *tokenBucket) wait(ctx context.Context) error {
+	delay := b.reserve()
+	if delay <= 0 {
+		return nil
+	}
+	select {
+	case <-b.clock.After(delay):
+		return nil
+	case <-ctx.Done():
+		b.mu.Lock()
+		b.tokens++
+		b.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// rateLimiter holds a token bucket for each of the endpoint families with
+// a limit. A nil rateLimiter doesn't limit requests.
+type rateLimiter struct {
+	buckets map[string]*tokenBucket
+	other   *tokenBucket
+}
+
+func newRateLimiter(clock clock.Clock, limits map[string]RateLimit) *rateLimiter {
+	if len(limits) == 0 {
+		return nil
+	}
+	limiter := &rateLimiter{buckets: make(map[string]*tokenBucket)}
+	for family, limit := range limits {
+		bucket := newTokenBucket(clock, limit)
+		if family == OtherEndpoints {
+			limiter.other = bucket
+		} else {
+			limiter.buckets[family] = bucket
+		}
+	}
+	return limiter
+}
+
+// wait blocks until a request to the path can be made, or the context is
+// done, in which case the context's error is returned.
+func (l *rateLimiter) wait(ctx context.Context, path string) error {
+	if l == nil {
+		return nil
+	}
+	bucket, ok := l.buckets[endpointFamily(path)]
+	if !ok {
+		bucket = l.other
+	}
+	if bucket == nil {
+		return nil
+	}
+	return bucket.wait(ctx)
+}
+
+// endpointFamily returns the first element of the path after the API
+// version, such as "machines" for both "machines" and
+// "/MAAS/api/2.0/machines/4y3ha3/".
+func endpointFamily(path string) string {
+	if index := strings.Index(path, "/api/"); index >= 0 {
+		path = path[index+len("/api/"):]
+		// Skip the version.
+		if index := strings.Index(path, "/"); index >= 0 {
+			path = path[index+1:]
+		}
+	}
+	path = strings.TrimPrefix(path, "/")
+	if index := strings.Index(path, "/"); index >= 0 {
+		path = path[:index]
+	}
+	return path
+}
@@ -0,0 +1,144 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"time"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	"github.com/juju/utils/clock"
+	gc "gopkg.in/check.v1"
+)
+
+type rateLimitSuite struct{}
+
+var _ = gc.Suite(&rateLimitSuite{})
+
+// fakeClock is a clock.Clock whose time only moves when waited on.
+type fakeClock struct {
+	clock.Clock
+	now   time.Time
+	waits []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.waits = append(c.waits, d)
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+// blockingClock is a fakeClock whose waits never end.
+type blockingClock struct {
+	*fakeClock
+}
+
+func (c blockingClock) After(d time.Duration) <-chan time.Time {
+	c.waits = append(c.waits, d)
+	return make(chan time.Time)
+}
+
+func (*rateLimitSuite) TestValidate(c *gc.C) {
+	c.Check(RateLimit{Rate: 1}.Validate(), jc.ErrorIsNil)
+	c.Check(RateLimit{}.Validate(), jc.Satisfies, errors.IsNotValid)
+	c.Check(RateLimit{Rate: 1, Burst: -1}.Validate(), jc.Satisfies, errors.IsNotValid)
+}
+
+func (*rateLimitSuite) TestTokenBucketBurst(c *gc.C) {
+	clock := &fakeClock{now: time.Now()}
+	bucket := newTokenBucket(clock, RateLimit{Rate: 2, Burst: 3})
+
+	for i := 0; i < 5; i++ {
+		bucket.wait(context.Background())
+	}
+	c.Assert(clock.waits, jc.DeepEquals, []time.Duration{
+		500 * time.Millisecond,
+		500 * time.Millisecond,
+	})
+}
+
+func (*rateLimitSuite) TestTokenBucketWaitAbandoned(c *gc.C) {
+	clock := blockingClock{&fakeClock{now: time.Now()}}
+	bucket := newTokenBucket(clock, RateLimit{Rate: 1})
+	bucket.reserve()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := bucket.wait(ctx)
+	c.Assert(err, gc.Equals, context.Canceled)
+	c.Check(clock.waits, jc.DeepEquals, []time.Duration{time.Second})
+	// The token taken for the abandoned request was given back.
+	c.Check(bucket.reserve(), gc.Equals, time.Second)
+}
+
+func (*rateLimitSuite) TestTokenBucketReservesInOrder(c *gc.C) {
+	clock := &fakeClock{now: time.Now()}
+	bucket := newTokenBucket(clock, RateLimit{Rate: 1})
+
+	c.Check(bucket.reserve(), gc.Equals, time.Duration(0))
+	c.Check(bucket.reserve(), gc.Equals, time.Second)
+	c.Check(bucket.reserve(), gc.Equals, 2*time.Second)
+}
+
+func (*rateLimitSuite) TestTokenBucketRefills(c *gc.C) {
+	clock := &fakeClock{now: time.Now()}
+	bucket := newTokenBucket(clock, RateLimit{Rate: 1, Burst: 2})
+	bucket.reserve()
+	bucket.reserve()
+
+	// The bucket doesn't fill past the burst.
+	clock.now = clock.now.Add(time.Minute)
+	c.Check(bucket.reserve(), gc.Equals, time.Duration(0))
+	c.Check(bucket.reserve(), gc.Equals, time.Duration(0))
+	c.Check(bucket.reserve(), gc.Equals, time.Second)
+}
+
+func (*rateLimitSuite) TestRateLimiterFamilies(c *gc.C) {
+	clock := &fakeClock{now: time.Now()}
+	limiter := newRateLimiter(clock, map[string]RateLimit{
+		"machines":     {Rate: 1},
+		OtherEndpoints: {Rate: 0.5},
+	})
+
+	limiter.wait(context.Background(), "machines")
+	limiter.wait(context.Background(), "/MAAS/api/2.0/machines/4y3ha3/")
+	limiter.wait(context.Background(), "subnets")
+	limiter.wait(context.Background(), "zones")
+	c.Assert(clock.waits, jc.DeepEquals, []time.Duration{time.Second, 2 * time.Second})
+}
+
+func (*rateLimitSuite) TestRateLimiterWithoutOther(c *gc.C) {
+	clock := &fakeClock{now: time.Now()}
+	limiter := newRateLimiter(clock, map[string]RateLimit{"machines": {Rate: 1}})
+
+	limiter.wait(context.Background(), "subnets")
+	limiter.wait(context.Background(), "subnets")
+	c.Assert(clock.waits, gc.HasLen, 0)
+}
+
+func (*rateLimitSuite) TestNilRateLimiter(c *gc.C) {
+	limiter := newRateLimiter(&fakeClock{}, nil)
+	c.Assert(limiter, gc.IsNil)
+	limiter.wait(context.Background(), "machines")
+}
+
+func (*rateLimitSuite) TestEndpointFamily(c *gc.C) {
+	for path, family := range map[string]string{
+		"machines":                              "machines",
+		"machines/":                             "machines",
+		"/MAAS/api/2.0/machines/4y3ha3/":        "machines",
+		"/MAAS/api/2.0/nodes/4y3ha3/interfaces": "nodes",
+		"/api/2.0/subnets/":                     "subnets",
+		"":                                      "",
+	} {
+		c.Check(endpointFamily(path), gc.Equals, family, gc.Commentf("path %q", path))
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/utils/set"
+)
+
+// releasePollInterval is how often WaitForRelease reads the machines.
+var releasePollInterval = 10 * time.Second
+
+// releasingStatuses are the status names of machines that are still being
+// released, and failedReleaseStatuses those of machines that couldn't be.
+var (
+	releasingStatuses     = set.NewStrings("Releasing", "Disk erasing")
+	failedReleaseStatuses = set.NewStrings("Failed releasing", "Failed disk erasing")
+)
+
+// WaitForRelease implements Controller.
+func (c *controller) WaitForRelease(ctx context.Context, systemIDs []string) error {
+	if len(systemIDs) == 0 {
+		return nil
+	}
+	failures := make(map[string]error)
+	waiting := set.NewStrings(systemIDs...)
+	for {
+		machines, err := c.Machines(MachinesArgs{SystemIDs: waiting.SortedValues()})
+		if err != nil {
+			return errors.Trace(err)
+		}
+		found := set.NewStrings()
+		for _, m := range machines {
+			id := m.SystemID()
+			found.Add(id)
+			status := m.StatusName()
+			switch {
+			case releasingStatuses.Contains(status):
+				continue
+			case failedReleaseStatuses.Contains(status):
+				failures[id] = NewCannotCompleteError(releaseFailure(m))
+			}
+			waiting.Remove(id)
+		}
+		for _, id := range waiting.Difference(found).Values() {
+			failures[id] = NewNoMatchError(fmt.Sprintf("machine %q not found", id))
+			waiting.Remove(id)
+		}
+		if waiting.IsEmpty() {
+			break
+		}
+
+		timer := time.NewTimer(releasePollInterval)
+		select {
+		case <-timer.C:
+			continue
+		case <-ctx.Done():
+			timer.Stop()
+		}
+		for _, id := range waiting.Values() {
+			failures[id] = ctx.Err()
+		}
+		break
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+	return NewMultiError(failures)
+}
+
+// releaseFailure describes why the machine couldn't be released, using the
+// status message MAAS gives if there is one.
+func releaseFailure(m Machine) string {
+	if message := m.StatusMessage(); message != "" {
+		return fmt.Sprintf("%s: %s", m.StatusName(), message)
+	}
+	return m.StatusName()
+}
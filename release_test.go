@@ -0,0 +1,91 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type releaseSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&releaseSuite{})
+
+func (s *releaseSuite) SetUpTest(c *gc.C) {
+	s.CleanupSuite.SetUpTest(c)
+	s.PatchValue(&releasePollInterval, time.Millisecond)
+}
+
+const releasePath = "/api/2.0/machines/?id=4y3ha3"
+
+func machineWithStatus(c *gc.C, status, message string) string {
+	return "[" + updateJSONMap(c, machineResponse, map[string]interface{}{
+		"status_name":    status,
+		"status_message": message,
+	}) + "]"
+}
+
+func (s *releaseSuite) TestWaitForRelease(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse(releasePath, http.StatusOK, machineWithStatus(c, "Releasing", ""))
+	server.AddGetResponse(releasePath, http.StatusOK, machineWithStatus(c, "Disk erasing", ""))
+	server.AddGetResponse(releasePath, http.StatusOK, machineWithStatus(c, "Ready", ""))
+	server.ResetRequests()
+
+	err := controller.WaitForRelease(context.Background(), []string{"4y3ha3"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(server.RequestCount(), gc.Equals, 3)
+}
+
+func (s *releaseSuite) TestWaitForReleaseNoMachines(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.ResetRequests()
+
+	err := controller.WaitForRelease(context.Background(), nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *releaseSuite) TestWaitForReleaseFailed(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse(releasePath, http.StatusOK, machineWithStatus(c, "Failed disk erasing", "disk sda is locked"))
+
+	err := controller.WaitForRelease(context.Background(), []string{"4y3ha3"})
+	c.Assert(err, jc.Satisfies, IsMultiError)
+	multi := errors.Cause(err).(*MultiError)
+	c.Assert(multi.Errors, gc.HasLen, 1)
+	c.Assert(multi.Errors["4y3ha3"], jc.Satisfies, IsCannotCompleteError)
+	c.Assert(multi.Errors["4y3ha3"], gc.ErrorMatches, "Failed disk erasing: disk sda is locked")
+}
+
+func (s *releaseSuite) TestWaitForReleaseMissing(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/machines/?id=4y3ha3&id=gone", http.StatusOK, machineWithStatus(c, "Ready", ""))
+
+	err := controller.WaitForRelease(context.Background(), []string{"gone", "4y3ha3"})
+	c.Assert(err, jc.Satisfies, IsMultiError)
+	multi := errors.Cause(err).(*MultiError)
+	c.Assert(multi.Errors, gc.HasLen, 1)
+	c.Assert(multi.Errors["gone"], jc.Satisfies, IsNoMatchError)
+}
+
+func (s *releaseSuite) TestWaitForReleaseContextDone(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse(releasePath, http.StatusOK, machineWithStatus(c, "Disk erasing", ""))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := controller.WaitForRelease(ctx, []string{"4y3ha3"})
+	c.Assert(err, jc.Satisfies, IsMultiError)
+	multi := errors.Cause(err).(*MultiError)
+	c.Assert(multi.Errors["4y3ha3"], gc.Equals, context.Canceled)
+}
@@ -0,0 +1,242 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+	"github.com/juju/utils/set"
+)
+
+const (
+	// ScriptOutputCombined is the interleaved stdout and stderr of the
+	// scripts.
+	ScriptOutputCombined = "combined"
+	// ScriptOutputStdout is the stdout of the scripts.
+	ScriptOutputStdout = "stdout"
+	// ScriptOutputStderr is the stderr of the scripts.
+	ScriptOutputStderr = "stderr"
+	// ScriptOutputResult is the YAML result written by the scripts.
+	ScriptOutputResult = "result"
+)
+
+var scriptOutputs = set.NewStrings(
+	ScriptOutputCombined,
+	ScriptOutputStdout,
+	ScriptOutputStderr,
+	ScriptOutputResult,
+)
+
+// ScriptStatus is the status of one of the scripts in a ScriptResult.
+type ScriptStatus struct {
+	Name       string
+	StatusName string
+	// ExitStatus is -1 if the script hasn't exited.
+	ExitStatus int
+}
+
+type scriptResult struct {
+	controller *controller
+
+	resourceURI string
+
+	id         int
+	typeName   string
+	statusName string
+	scripts    []ScriptStatus
+}
+
+// ID implements ScriptResult.
+func (r *scriptResult) ID() int {
+	return r.id
+}
+
+// TypeName implements ScriptResult.
+func (r *scriptResult) TypeName() string {
+	return r.typeName
+}
+
+// StatusName implements ScriptResult.
+func (r *scriptResult) StatusName() string {
+	return r.statusName
+}
+
+// Scripts implements ScriptResult.
+func (r *scriptResult) Scripts() []ScriptStatus {
+	result := make([]ScriptStatus, len(r.scripts))
+	copy(result, r.scripts)
+	return result
+}
+
+// DownloadOutput implements ScriptResult.
+//
+// Returns
+//  - NotValid error if the output isn't one of the ScriptOutput constants
+//  - NoMatchError if the results cannot be found
+//  - PermissionError if the user does not have permission to read the results
+func (r *scriptResult) DownloadOutput(w io.Writer, output string) (string, error) {
+	if !scriptOutputs.Contains(output) {
+		return "", errors.NotValidf("script output %q", output)
+	}
+	params := NewURLParams()
+	params.Values.Add("output", output)
+	params.Values.Add("filetype", "txt")
+	sniffer := &sniffingWriter{Writer: w}
+	header, err := r.controller._getStream(context.Background(), r.resourceURI, "download", params.Values, sniffer)
+	if err != nil {
+		return "", translateError(opEntity, err)
+	}
+	contentType := header.Get("Content-Type")
+	if contentType == "" || strings.HasPrefix(contentType, "application/octet-stream") {
+		if len(sniffer.head) > 0 {
+			contentType = http.DetectContentType(sniffer.head)
+		}
+	}
+	return contentType, nil
+}
+
+// sniffingWriter passes everything written on to the Writer, keeping the
+// start of it so that the content type can be detected.
+type sniffingWriter struct {
+	io.Writer
+	head []byte
+}
+
+func (w *sniffingWriter) Write(p []byte) (int, error) {
+	// http.DetectContentType considers at most 512 bytes.
+	if missing := 512 - len(w.head); missing > 0 {
+		if missing > len(p) {
+			missing = len(p)
+		}
+		w.head = append(w.head, p[:missing]...)
+	}
+	return w.Writer.Write(p)
+}
+
+// DownloadInstallationOutput implements Machine.
+//
+// Returns
+//  - NoMatchError if the machine hasn't been installed
+//  - PermissionError if the user does not have permission to read the results
+func (m *machine) DownloadInstallationOutput(w io.Writer) error {
+	if m.installationResultID == 0 {
+		return NewNoMatchError(fmt.Sprintf("no installation result for machine %q", m.systemID))
+	}
+	result := &scriptResult{
+		controller:  m.controller,
+		resourceURI: fmt.Sprintf("nodes/%s/results/%d", m.systemID, m.installationResultID),
+		id:          m.installationResultID,
+	}
+	_, err := result.DownloadOutput(w, ScriptOutputCombined)
+	return errors.Trace(err)
+}
+
+// ScriptResultsArgs is an argument struct for selecting the results returned
+// by Machine.ScriptResults.
+type ScriptResultsArgs struct {
+	// Type is optional, and is one of "commissioning", "testing" or
+	// "installation". All the results are returned if it is empty.
+	Type string
+}
+
+// ScriptResults implements Machine.
+//
+// Returns
+//  - NoMatchError if the machine cannot be found
+//  - PermissionError if the user does not have permission to read the results
+func (m *machine) ScriptResults(args ScriptResultsArgs) ([]ScriptResult, error) {
+	params := NewURLParams()
+	params.MaybeAdd("type", args.Type)
+	source, err := m.controller.getQuery(fmt.Sprintf("nodes/%s/results", m.systemID), params.Values)
+	if err != nil {
+		return nil, translateError(opEntity, err)
+	}
+	results, err := readScriptResults(source)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err = m.controller.checkDecoded("script result", source, results); err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result []ScriptResult
+	for _, r := range results {
+		r.controller = m.controller
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+func readScriptResults(source interface{}) ([]*scriptResult, error) {
+	checker := schema.List(schema.StringMap(schema.Any()))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "script result base schema check failed")
+	}
+	valid := coerced.([]interface{})
+	result := make([]*scriptResult, 0, len(valid))
+	for i, value := range valid {
+		source := value.(map[string]interface{})
+		read, err := scriptResult_2_0(source)
+		if err != nil {
+			return nil, annotateListItem(err, "script result", i, source)
+		}
+		result = append(result, read)
+	}
+	return result, nil
+}
+
+var scriptResult_2_0Checker = fieldMap("script result", schema.Fields{
+	"id":           schema.ForceInt(),
+	"resource_uri": schema.String(),
+	"type_name":    schema.String(),
+	"status_name":  schema.String(),
+	"results":      schema.List(schema.StringMap(schema.Any())),
+}, nil) // no defaults
+
+func scriptResult_2_0(source map[string]interface{}) (*scriptResult, error) {
+	coerced, err := scriptResult_2_0Checker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "script result 2.0 schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+
+	scriptFields := schema.Fields{
+		"name":        schema.String(),
+		"status_name": schema.String(),
+		"exit_status": schema.OneOf(schema.Nil(""), schema.ForceInt()),
+	}
+	scriptChecker := schema.FieldMap(scriptFields, nil)
+	var scripts []ScriptStatus
+	for i, value := range valid["results"].([]interface{}) {
+		coerced, err := scriptChecker.Coerce(value, nil)
+		if err != nil {
+			return nil, WrapWithDeserializationError(err, "script %d schema check failed", i)
+		}
+		script := coerced.(map[string]interface{})
+		exitStatus, ok := script["exit_status"].(int)
+		if !ok {
+			exitStatus = -1
+		}
+		scripts = append(scripts, ScriptStatus{
+			Name:       script["name"].(string),
+			StatusName: script["status_name"].(string),
+			ExitStatus: exitStatus,
+		})
+	}
+
+	result := &scriptResult{
+		resourceURI: valid["resource_uri"].(string),
+		id:          valid["id"].(int),
+		typeName:    valid["type_name"].(string),
+		statusName:  valid["status_name"].(string),
+		scripts:     scripts,
+	}
+	return result, nil
+}
@@ -0,0 +1,161 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+const (
+	scriptResultsPath  = "/api/2.0/nodes/4y3ha3/results/"
+	scriptDownloadPath = "/MAAS/api/2.0/nodes/4y3ha3/results/2/?filetype=txt&op=download&output="
+)
+
+func (*machineSuite) TestReadScriptResultsBadSchema(c *gc.C) {
+	_, err := readScriptResults("wat?")
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err.Error(), gc.Equals, `script result base schema check failed: expected list, got string("wat?")`)
+
+	_, err = readScriptResults([]map[string]interface{}{
+		{
+			"wat": "?",
+		},
+	})
+	c.Check(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err, gc.ErrorMatches, `script result 0: script result 2.0 schema check failed: .*`)
+}
+
+func (*machineSuite) TestReadScriptResults(c *gc.C) {
+	results, err := readScriptResults(parseJSON(c, scriptResultsResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results, gc.HasLen, 1)
+
+	result := results[0]
+	c.Check(result.ID(), gc.Equals, 2)
+	c.Check(result.TypeName(), gc.Equals, "Testing")
+	c.Check(result.StatusName(), gc.Equals, "Failed")
+	c.Check(result.Scripts(), jc.DeepEquals, []ScriptStatus{
+		{Name: "smartctl-validate", StatusName: "Passed", ExitStatus: 0},
+		{Name: "stress-ng-cpu-long", StatusName: "Failed", ExitStatus: 1},
+		{Name: "memtester", StatusName: "Pending", ExitStatus: -1},
+	})
+}
+
+func (s *machineSuite) TestScriptResults(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse(scriptResultsPath+"?type=testing", http.StatusOK, scriptResultsResponse)
+
+	results, err := machine.ScriptResults(ScriptResultsArgs{Type: "testing"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results, gc.HasLen, 1)
+	c.Check(results[0].ID(), gc.Equals, 2)
+}
+
+func (s *machineSuite) TestScriptResultsNotFound(c *gc.C) {
+	_, machine := s.getServerAndMachine(c)
+	_, err := machine.ScriptResults(ScriptResultsArgs{})
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+}
+
+func (s *machineSuite) getServerAndScriptResult(c *gc.C) (*SimpleTestServer, ScriptResult) {
+	server, machine := s.getServerAndMachine(c)
+	server.AddGetResponse(scriptResultsPath, http.StatusOK, scriptResultsResponse)
+	results, err := machine.ScriptResults(ScriptResultsArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(results, gc.HasLen, 1)
+	server.ResetRequests()
+	return server, results[0]
+}
+
+func (s *machineSuite) TestDownloadOutput(c *gc.C) {
+	server, result := s.getServerAndScriptResult(c)
+	output := strings.Repeat("stress-ng: info: dispatching hogs\n", 1000)
+	server.AddGetResponseWithHeader(scriptDownloadPath+"stdout", http.StatusOK, output, http.Header{
+		"Content-Type": {"text/plain; charset=utf-8"},
+	})
+
+	var buf bytes.Buffer
+	contentType, err := result.DownloadOutput(&buf, ScriptOutputStdout)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(buf.String(), gc.Equals, output)
+	c.Check(contentType, gc.Equals, "text/plain; charset=utf-8")
+}
+
+func (s *machineSuite) TestDownloadOutputDetectsContentType(c *gc.C) {
+	server, result := s.getServerAndScriptResult(c)
+	server.AddGetResponseWithHeader(scriptDownloadPath+"result", http.StatusOK, "results:\n  status: failed\n", http.Header{
+		"Content-Type": {"application/octet-stream"},
+	})
+
+	var buf bytes.Buffer
+	contentType, err := result.DownloadOutput(&buf, ScriptOutputResult)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(contentType, gc.Equals, "text/plain; charset=utf-8")
+}
+
+func (s *machineSuite) TestDownloadOutputValidates(c *gc.C) {
+	_, result := s.getServerAndScriptResult(c)
+	_, err := result.DownloadOutput(&bytes.Buffer{}, "everything")
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *machineSuite) TestDownloadOutputNotFound(c *gc.C) {
+	server, result := s.getServerAndScriptResult(c)
+	server.AddGetResponse(scriptDownloadPath+"combined", http.StatusNotFound, "gone")
+
+	var buf bytes.Buffer
+	_, err := result.DownloadOutput(&buf, ScriptOutputCombined)
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+	c.Check(buf.Len(), gc.Equals, 0)
+}
+
+func (s *machineSuite) TestDownloadOutputForbidden(c *gc.C) {
+	server, result := s.getServerAndScriptResult(c)
+	server.AddGetResponse(scriptDownloadPath+"stderr", http.StatusForbidden, "bad user")
+
+	_, err := result.DownloadOutput(&bytes.Buffer{}, ScriptOutputStderr)
+	c.Assert(err, jc.Satisfies, IsPermissionError)
+}
+
+const scriptResultsResponse = `
+[
+    {
+        "id": 2,
+        "resource_uri": "/MAAS/api/2.0/nodes/4y3ha3/results/2/",
+        "type": 2,
+        "type_name": "Testing",
+        "status": 3,
+        "status_name": "Failed",
+        "results": [
+            {
+                "id": 5,
+                "name": "smartctl-validate",
+                "status": 2,
+                "status_name": "Passed",
+                "exit_status": 0
+            },
+            {
+                "id": 6,
+                "name": "stress-ng-cpu-long",
+                "status": 3,
+                "status_name": "Failed",
+                "exit_status": 1
+            },
+            {
+                "id": 7,
+                "name": "memtester",
+                "status": 0,
+                "status_name": "Pending",
+                "exit_status": null
+            }
+        ]
+    }
+]
+`
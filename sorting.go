@@ -0,0 +1,98 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"sort"
+
+	"github.com/juju/errors"
+)
+
+// SortBy is the type of the constants that select the order of the
+// machines and devices returned by the Controller. The MAAS responses have
+// no creation time, so there is no order by creation.
+type SortBy string
+
+const (
+	// SortBySystemID orders by system ID. It is the default.
+	SortBySystemID SortBy = "system-id"
+
+	// SortByHostname orders by hostname, and then by system ID.
+	SortByHostname SortBy = "hostname"
+)
+
+// Validate checks the order is known. An empty order means SortBySystemID.
+func (s SortBy) Validate() error {
+	switch s {
+	case "", SortBySystemID, SortByHostname:
+		return nil
+	}
+	return errors.NotValidf("sort order %q", s)
+}
+
+// nodeLess returns whether the node with the hostname and system ID comes
+// before the other node in the order.
+func (s SortBy) nodeLess(hostname, systemID, otherHostname, otherSystemID string) bool {
+	if s == SortByHostname && hostname != otherHostname {
+		return hostname < otherHostname
+	}
+	return systemID < otherSystemID
+}
+
+func sortMachines(machines []*machine, by SortBy) {
+	sort.SliceStable(machines, func(i, j int) bool {
+		a, b := machines[i], machines[j]
+		return by.nodeLess(a.hostname, a.systemID, b.hostname, b.systemID)
+	})
+}
+
+func sortDevices(devices []*device, by SortBy) {
+	sort.SliceStable(devices, func(i, j int) bool {
+		a, b := devices[i], devices[j]
+		return by.nodeLess(a.hostname, a.systemID, b.hostname, b.systemID)
+	})
+}
+
+func sortNodes(nodes []*node, by SortBy) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		a, b := nodes[i], nodes[j]
+		return by.nodeLess(a.hostname, a.systemID, b.hostname, b.systemID)
+	})
+}
+
+func sortZones(zones []*zone) {
+	sort.SliceStable(zones, func(i, j int) bool {
+		return zones[i].name < zones[j].name
+	})
+}
+
+func sortPools(pools []*pool) {
+	sort.SliceStable(pools, func(i, j int) bool {
+		return pools[i].name < pools[j].name
+	})
+}
+
+func sortDomains(domains []*domain) {
+	sort.SliceStable(domains, func(i, j int) bool {
+		return domains[i].name < domains[j].name
+	})
+}
+
+// MachinesByHostname returns the machines indexed by hostname.
+func MachinesByHostname(machines []Machine) map[string]Machine {
+	result := make(map[string]Machine, len(machines))
+	for _, m := range machines {
+		result[m.Hostname()] = m
+	}
+	return result
+}
+
+// MachinesBySystemID returns the machines indexed by system ID.
+func MachinesBySystemID(machines []Machine) map[string]Machine {
+	result := make(map[string]Machine, len(machines))
+	for _, m := range machines {
+		result[m.SystemID()] = m
+	}
+	return result
+}
@@ -0,0 +1,100 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type sortingSuite struct{}
+
+var _ = gc.Suite(&sortingSuite{})
+
+func (*sortingSuite) TestSortByValidate(c *gc.C) {
+	for _, by := range []SortBy{"", SortBySystemID, SortByHostname} {
+		c.Check(by.Validate(), jc.ErrorIsNil)
+	}
+	err := SortBy("created").Validate()
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	c.Assert(err, gc.ErrorMatches, `sort order "created" not valid`)
+}
+
+func (*sortingSuite) TestSortMachines(c *gc.C) {
+	machines := []*machine{
+		{systemID: "c", hostname: "alpha"},
+		{systemID: "a", hostname: "bravo"},
+		{systemID: "b", hostname: "alpha"},
+	}
+	sortMachines(machines, SortByHostname)
+	c.Check(machineSystemIDs(machines), jc.DeepEquals, []string{"b", "c", "a"})
+	sortMachines(machines, "")
+	c.Check(machineSystemIDs(machines), jc.DeepEquals, []string{"a", "b", "c"})
+}
+
+func machineSystemIDs(machines []*machine) []string {
+	var result []string
+	for _, m := range machines {
+		result = append(result, m.systemID)
+	}
+	return result
+}
+
+func (*sortingSuite) TestSortDevices(c *gc.C) {
+	devices := []*device{
+		{systemID: "b", hostname: "alpha"},
+		{systemID: "a", hostname: "bravo"},
+	}
+	sortDevices(devices, SortByHostname)
+	c.Check(devices[0].systemID, gc.Equals, "b")
+	sortDevices(devices, SortBySystemID)
+	c.Check(devices[0].systemID, gc.Equals, "a")
+}
+
+func (*sortingSuite) TestSortZonesAndPools(c *gc.C) {
+	zones := []*zone{{name: "b"}, {name: "a"}}
+	sortZones(zones)
+	c.Check(zones[0].name, gc.Equals, "a")
+	pools := []*pool{{name: "b"}, {name: "a"}}
+	sortPools(pools)
+	c.Check(pools[0].name, gc.Equals, "a")
+}
+
+func (*sortingSuite) TestMachinesIndexes(c *gc.C) {
+	first := &machine{systemID: "a", hostname: "alpha"}
+	second := &machine{systemID: "b", hostname: "bravo"}
+	machines := []Machine{first, second}
+	c.Assert(MachinesByHostname(machines), jc.DeepEquals, map[string]Machine{
+		"alpha": first,
+		"bravo": second,
+	})
+	c.Assert(MachinesBySystemID(machines), jc.DeepEquals, map[string]Machine{
+		"a": first,
+		"b": second,
+	})
+}
+
+func (s *controllerSuite) TestMachinesSortBy(c *gc.C) {
+	controller := s.getController(c)
+	machines, err := controller.Machines(MachinesArgs{SortBy: SortByHostname})
+	c.Assert(err, jc.ErrorIsNil)
+	var hostnames []string
+	for _, m := range machines {
+		hostnames = append(hostnames, m.Hostname())
+	}
+	c.Assert(hostnames, jc.DeepEquals, []string{"icier-nina", "lowlier-glady", "untasted-markita"})
+}
+
+func (s *controllerSuite) TestMachinesSortByNotValid(c *gc.C) {
+	controller := s.getController(c)
+	_, err := controller.Machines(MachinesArgs{SortBy: "created"})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (s *controllerSuite) TestDevicesSortByNotValid(c *gc.C) {
+	controller := s.getController(c)
+	_, err := controller.Devices(DevicesArgs{SortBy: "created"})
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+}
@@ -10,8 +10,7 @@ import (
 )
 
 type space struct {
-	// Add the controller in when we need to do things with the space.
-	// controller Controller
+	controller *controller
 
 	resourceURI string
 
@@ -19,6 +18,7 @@ type space struct {
 	name string
 
 	subnets []*subnet
+	vlans   []*vlan
 }
 
 // Id implements Space.
@@ -31,6 +31,15 @@ func (s *space) Name() string {
 	return s.name
 }
 
+func (s *space) addressErr() error {
+	for _, subnet := range s.subnets {
+		if err := subnet.addressErr(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Subnets implements Space.
 func (s *space) Subnets() []Subnet {
 	var result []Subnet
@@ -40,6 +49,72 @@ func (s *space) Subnets() []Subnet {
 	return result
 }
 
+// VLANs implements Space.
+func (s *space) VLANs() []VLAN {
+	var result []VLAN
+	for _, v := range s.vlans {
+		result = append(result, v)
+	}
+	return result
+}
+
+// Rename implements Space.
+//
+// Returns
+//  - NotValid error if the name is empty
+//  - BadRequestError if MAAS refuses the name, such as when another space
+//    has it
+//  - PermissionError if the user isn't an admin
+//  - NoMatchError if the space no longer exists
+func (s *space) Rename(name string) error {
+	if name == "" {
+		return errors.NotValidf("missing name")
+	}
+	if s.controller == nil {
+		return errors.NotSupportedf("renaming space %d without a controller", s.id)
+	}
+	params := NewURLParams()
+	params.Values.Add("name", name)
+	source, err := s.controller.put(s.resourceURI, params.Values)
+	if err != nil {
+		return translateError(opEntity, err)
+	}
+	sourceMap, ok := source.(map[string]interface{})
+	if !ok {
+		return NewDeserializationError("unexpected value for space, %T", source)
+	}
+	response, err := space_2_0(sourceMap)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err = s.controller.checkDecoded("space", source, response); err != nil {
+		return errors.Trace(err)
+	}
+	s.updateFrom(response)
+	return nil
+}
+
+func (s *space) updateFrom(other *space) {
+	s.resourceURI = other.resourceURI
+	s.id = other.id
+	s.name = other.name
+	s.subnets = other.subnets
+	s.vlans = other.vlans
+	s.bind(s.controller)
+}
+
+// bind associates the space, and the VLANs of the space and its subnets,
+// with the controller.
+func (s *space) bind(c *controller) {
+	s.controller = c
+	for _, subnet := range s.subnets {
+		subnet.bind(c)
+	}
+	for _, v := range s.vlans {
+		v.bind(c)
+	}
+}
+
 func readSpaces(controllerVersion version.Number, source interface{}) ([]*space, error) {
 	checker := schema.List(schema.StringMap(schema.Any()))
 	coerced, err := checker.Coerce(source, nil)
@@ -71,7 +146,7 @@ func readSpaceList(sourceList []interface{}, readFunc spaceDeserializationFunc)
 		}
 		space, err := readFunc(source)
 		if err != nil {
-			return nil, errors.Annotatef(err, "space %d", i)
+			return nil, annotateListItem(err, "space", i, source)
 		}
 		result = append(result, space)
 	}
@@ -84,15 +159,19 @@ var spaceDeserializationFuncs = map[version.Number]spaceDeserializationFunc{
 	twoDotOh: space_2_0,
 }
 
+var space_2_0Checker = fieldMap("space", schema.Fields{
+	"resource_uri": schema.String(),
+	"id":           schema.ForceInt(),
+	"name":         schema.String(),
+	"subnets":      schema.List(schema.StringMap(schema.Any())),
+	// Not reported by older controllers.
+	"vlans": schema.List(schema.StringMap(schema.Any())),
+}, schema.Defaults{
+	"vlans": schema.Omit,
+})
+
 func space_2_0(source map[string]interface{}) (*space, error) {
-	fields := schema.Fields{
-		"resource_uri": schema.String(),
-		"id":           schema.ForceInt(),
-		"name":         schema.String(),
-		"subnets":      schema.List(schema.StringMap(schema.Any())),
-	}
-	checker := schema.FieldMap(fields, nil) // no defaults
-	coerced, err := checker.Coerce(source, nil)
+	coerced, err := space_2_0Checker.Coerce(source, nil)
 	if err != nil {
 		return nil, errors.Annotatef(err, "space 2.0 schema check failed")
 	}
@@ -105,11 +184,20 @@ func space_2_0(source map[string]interface{}) (*space, error) {
 		return nil, errors.Trace(err)
 	}
 
+	var vlans []*vlan
+	if list, ok := valid["vlans"].([]interface{}); ok {
+		vlans, err = readVLANList(list, vlan_2_0)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+
 	result := &space{
 		resourceURI: valid["resource_uri"].(string),
 		id:          valid["id"].(int),
 		name:        valid["name"].(string),
 		subnets:     subnets,
+		vlans:       vlans,
 	}
 	return result, nil
 }
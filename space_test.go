@@ -4,6 +4,10 @@
 package gomaasapi
 
 import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/juju/errors"
 	jc "github.com/juju/testing/checkers"
 	"github.com/juju/version"
 	gc "gopkg.in/check.v1"
@@ -31,6 +35,73 @@ func (*spaceSuite) TestReadSpaces(c *gc.C) {
 	c.Assert(subnets[0].ID(), gc.Equals, 34)
 }
 
+func (*spaceSuite) TestReadSpacesVLANs(c *gc.C) {
+	spaces, err := readSpaces(twoDotOh, parseJSON(c, spacesResponse))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(spaces[0].VLANs(), gc.HasLen, 0)
+
+	spaces, err = readSpaces(twoDotOh, parseJSON(c, "["+spaceResponseWithVLANs(c, "space-0")+"]"))
+	c.Assert(err, jc.ErrorIsNil)
+	vlans := spaces[0].VLANs()
+	c.Assert(vlans, gc.HasLen, 2)
+	c.Assert(vlans[0].ID(), gc.Equals, 5001)
+	c.Assert(vlans[1].ID(), gc.Equals, 1)
+}
+
+// spaceResponseWithVLANs returns the space of spacesResponse with the
+// name, and with the VLANs of its subnets.
+func spaceResponseWithVLANs(c *gc.C, name string) string {
+	source := parseJSON(c, spacesResponse).([]interface{})[0].(map[string]interface{})
+	var vlans []interface{}
+	for _, subnet := range source["subnets"].([]interface{}) {
+		vlans = append(vlans, subnet.(map[string]interface{})["vlan"])
+	}
+	source["vlans"] = vlans
+	source["name"] = name
+	bytes, err := json.Marshal(source)
+	c.Assert(err, jc.ErrorIsNil)
+	return string(bytes)
+}
+
+func (s *controllerSuite) TestSpaceByName(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/spaces/", http.StatusOK, spacesResponse)
+	controller := s.getController(c)
+
+	space, err := controller.SpaceByName("space-0")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(space.ID(), gc.Equals, 0)
+
+	_, err = controller.SpaceByName("dmz")
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+	c.Assert(err.Error(), gc.Equals, `space "dmz" not found`)
+}
+
+func (s *controllerSuite) TestSpaceRename(c *gc.C) {
+	s.server.AddPutResponse("/MAAS/api/2.0/spaces/0/", http.StatusOK, spaceResponseWithVLANs(c, "dmz"))
+	controller := s.getController(c)
+	space, err := controller.SpaceByName("space-0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	err = space.Rename("dmz")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(space.Name(), gc.Equals, "dmz")
+	c.Check(space.VLANs(), gc.HasLen, 2)
+	c.Check(s.server.LastRequest().PostForm.Get("name"), gc.Equals, "dmz")
+}
+
+func (s *controllerSuite) TestSpaceRenameErrors(c *gc.C) {
+	s.server.AddPutResponse("/MAAS/api/2.0/spaces/0/", http.StatusBadRequest, "Space with this Name already exists.")
+	controller := s.getController(c)
+	space, err := controller.SpaceByName("space-0")
+	c.Assert(err, jc.ErrorIsNil)
+
+	err = space.Rename("")
+	c.Assert(err, jc.Satisfies, errors.IsNotValid)
+	err = space.Rename("dmz")
+	c.Assert(err, jc.Satisfies, IsBadRequestError)
+	c.Check(space.Name(), gc.Equals, "space-0")
+}
+
 func (*spaceSuite) TestLowVersion(c *gc.C) {
 	_, err := readSpaces(version.MustParse("1.9.0"), parseJSON(c, spacesResponse))
 	c.Assert(err.Error(), gc.Equals, `no space read func for version 1.9.0`)
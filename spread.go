@@ -0,0 +1,150 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+
+	"github.com/juju/errors"
+	"github.com/juju/utils/set"
+)
+
+// SpreadFallback is the type of the constants that select what
+// Controller.AllocateSpread does when a zone has no more matching machines.
+type SpreadFallback string
+
+const (
+	// SpreadFallbackOtherZones allocates the machine in the next zone, in
+	// the order given, that still has matching machines. It is the default.
+	SpreadFallbackOtherZones SpreadFallback = "other-zones"
+
+	// SpreadFallbackNone fails the allocation when the zone that is next
+	// in turn has no matching machines, so that the machines are never
+	// spread unevenly.
+	SpreadFallbackNone SpreadFallback = "none"
+)
+
+// AllocateSpreadArgs is an argument struct for passing parameters to the
+// Controller.AllocateSpread method.
+type AllocateSpreadArgs struct {
+	// Count is the number of machines to allocate (required).
+	Count int
+	// Zones are the names of the zones to spread the machines across, in
+	// the order they take turns (required).
+	Zones []string
+	// Fallback is optional, and defaults to SpreadFallbackOtherZones.
+	Fallback SpreadFallback
+	// Machine holds the constraints for each of the machines. Its Zone is
+	// set for each allocation, so it must not be set here.
+	Machine AllocateMachineArgs
+}
+
+// Validate checks the count is positive, the zones are given without
+// repeats, the fallback is known and the machine constraints are valid.
+func (a *AllocateSpreadArgs) Validate() error {
+	if a.Count <= 0 {
+		return errors.NotValidf("Count %d", a.Count)
+	}
+	if len(a.Zones) == 0 {
+		return errors.NotValidf("missing Zones")
+	}
+	seen := set.NewStrings()
+	for _, zone := range a.Zones {
+		if zone == "" || seen.Contains(zone) {
+			return errors.NotValidf("zone %q", zone)
+		}
+		seen.Add(zone)
+	}
+	switch a.Fallback {
+	case "", SpreadFallbackOtherZones, SpreadFallbackNone:
+	default:
+		return errors.NotValidf("fallback %q", a.Fallback)
+	}
+	if a.Machine.Zone != "" {
+		return errors.NotValidf("Machine.Zone with Zones")
+	}
+	if a.Machine.DryRun {
+		return errors.NotValidf("Machine.DryRun")
+	}
+	return errors.Annotate(a.Machine.Validate(), "Machine")
+}
+
+// SpreadPlacement describes where Controller.AllocateSpread allocated a
+// machine.
+type SpreadPlacement struct {
+	Machine Machine
+	Matches ConstraintMatches
+	// Zone is the zone the machine was allocated in.
+	Zone string
+	// PreferredZone is the zone whose turn it was. It differs from Zone
+	// when that zone had no matching machines.
+	PreferredZone string
+}
+
+// AllocateSpread implements Controller.
+//
+// Returns an error that satisfies IsNoMatchError if there aren't enough
+// matching machines.
+func (c *controller) AllocateSpread(args AllocateSpreadArgs) ([]SpreadPlacement, error) {
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	exhausted := set.NewStrings()
+	var placements []SpreadPlacement
+	for i := 0; i < args.Count; i++ {
+		first := i % len(args.Zones)
+		preferred := args.Zones[first]
+		candidates := []string{preferred}
+		if args.Fallback != SpreadFallbackNone {
+			for j := 1; j < len(args.Zones); j++ {
+				candidates = append(candidates, args.Zones[(first+j)%len(args.Zones)])
+			}
+		}
+		placement, err := c.allocateInZones(args.Machine, candidates, exhausted)
+		if err != nil {
+			c.releasePlacements(placements)
+			return nil, errors.Annotatef(err, "machine %d of %d", i+1, args.Count)
+		}
+		placement.PreferredZone = preferred
+		placements = append(placements, placement)
+	}
+	return placements, nil
+}
+
+// releasePlacements releases the machines allocated before AllocateSpread
+// failed, logging rather than returning an error if they can't be released.
+func (c *controller) releasePlacements(placements []SpreadPlacement) {
+	if len(placements) == 0 {
+		return
+	}
+	ids := make([]string, len(placements))
+	for i, placement := range placements {
+		ids[i] = placement.Machine.SystemID()
+	}
+	if err := c.ReleaseMachines(ReleaseMachinesArgs{SystemIDs: ids}); err != nil {
+		c.logger.Warnf("could not release machines %q: %v", ids, err)
+	}
+}
+
+// allocateInZones allocates a machine in the first of the zones that has a
+// matching machine, skipping and adding to exhausted the zones that don't.
+func (c *controller) allocateInZones(args AllocateMachineArgs, zones []string, exhausted set.Strings) (SpreadPlacement, error) {
+	for _, zone := range zones {
+		if exhausted.Contains(zone) {
+			continue
+		}
+		args.Zone = zone
+		machine, matches, err := c.AllocateMachine(args)
+		if IsNoMatchError(err) {
+			c.logger.Debugf("no matching machines in zone %q", zone)
+			exhausted.Add(zone)
+			continue
+		}
+		if err != nil {
+			return SpreadPlacement{}, errors.Trace(err)
+		}
+		return SpreadPlacement{Machine: machine, Matches: matches, Zone: zone}, nil
+	}
+	return SpreadPlacement{}, NewNoMatchError(fmt.Sprintf("no matching machines in zones %q", zones))
+}
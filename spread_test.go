@@ -0,0 +1,178 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type spreadSuite struct {
+	testing.CleanupSuite
+}
+
+var _ = gc.Suite(&spreadSuite{})
+
+const allocatePath = "/api/2.0/machines/?op=allocate"
+
+func (*spreadSuite) TestAllocateSpreadArgsValidate(c *gc.C) {
+	for i, test := range []struct {
+		args   AllocateSpreadArgs
+		errMsg string
+	}{{
+		args: AllocateSpreadArgs{Count: 3, Zones: []string{"a", "b"}},
+	}, {
+		args: AllocateSpreadArgs{Count: 1, Zones: []string{"a"}, Fallback: SpreadFallbackNone},
+	}, {
+		args:   AllocateSpreadArgs{Zones: []string{"a"}},
+		errMsg: "Count 0 not valid",
+	}, {
+		args:   AllocateSpreadArgs{Count: 1},
+		errMsg: "missing Zones not valid",
+	}, {
+		args:   AllocateSpreadArgs{Count: 1, Zones: []string{"a", "a"}},
+		errMsg: `zone "a" not valid`,
+	}, {
+		args:   AllocateSpreadArgs{Count: 1, Zones: []string{"a"}, Fallback: "random"},
+		errMsg: `fallback "random" not valid`,
+	}, {
+		args:   AllocateSpreadArgs{Count: 1, Zones: []string{"a"}, Machine: AllocateMachineArgs{Zone: "a"}},
+		errMsg: "Machine.Zone with Zones not valid",
+	}, {
+		args:   AllocateSpreadArgs{Count: 1, Zones: []string{"a"}, Machine: AllocateMachineArgs{DryRun: true}},
+		errMsg: "Machine.DryRun not valid",
+	}, {
+		args: AllocateSpreadArgs{Count: 1, Zones: []string{"a"}, Machine: AllocateMachineArgs{
+			Storage: []StorageSpec{{Label: "root", Size: 0}},
+		}},
+		errMsg: "Machine: Storage: Size value 0 not valid",
+	}} {
+		c.Logf("test %d", i)
+		err := test.args.Validate()
+		if test.errMsg == "" {
+			c.Check(err, jc.ErrorIsNil)
+		} else {
+			c.Check(err, jc.Satisfies, errors.IsNotValid)
+			c.Check(err, gc.ErrorMatches, test.errMsg)
+		}
+	}
+}
+
+// checkAllocatedZones checks the last allocate requests were for the zones.
+func checkAllocatedZones(c *gc.C, server *SimpleTestServer, zones ...string) {
+	var requested []string
+	for _, request := range server.LastNRequests(len(zones)) {
+		c.Assert(request.URL.String(), gc.Equals, allocatePath)
+		requested = append(requested, request.PostForm.Get("zone"))
+	}
+	c.Assert(requested, jc.DeepEquals, zones)
+}
+
+func (s *spreadSuite) TestAllocateSpread(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	for i := 0; i < 3; i++ {
+		server.AddPostResponse(allocatePath, http.StatusOK, machineResponse)
+	}
+
+	placements, err := controller.AllocateSpread(AllocateSpreadArgs{
+		Count:   3,
+		Zones:   []string{"a", "b"},
+		Machine: AllocateMachineArgs{Tags: []string{"ha"}},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(placements, gc.HasLen, 3)
+	for i, zone := range []string{"a", "b", "a"} {
+		c.Check(placements[i].Machine, gc.NotNil)
+		c.Check(placements[i].Zone, gc.Equals, zone)
+		c.Check(placements[i].PreferredZone, gc.Equals, zone)
+	}
+	checkAllocatedZones(c, server, "a", "b", "a")
+	c.Assert(server.LastRequest().PostForm.Get("tags"), gc.Equals, "ha")
+}
+
+func (s *spreadSuite) TestAllocateSpreadFallback(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse(allocatePath, http.StatusOK, machineResponse)
+	server.AddPostResponse(allocatePath, http.StatusConflict, "no machines")
+	server.AddPostResponse(allocatePath, http.StatusOK, machineResponse)
+	server.AddPostResponse(allocatePath, http.StatusOK, machineResponse)
+
+	placements, err := controller.AllocateSpread(AllocateSpreadArgs{
+		Count: 3,
+		Zones: []string{"a", "b"},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(placements, gc.HasLen, 3)
+	c.Check(placements[1].Zone, gc.Equals, "a")
+	c.Check(placements[1].PreferredZone, gc.Equals, "b")
+	// Zone b isn't tried again once it is exhausted.
+	checkAllocatedZones(c, server, "a", "b", "a", "a")
+}
+
+func (s *spreadSuite) TestAllocateSpreadFallbackNone(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse(allocatePath, http.StatusOK, machineResponse)
+	server.AddPostResponse(allocatePath, http.StatusConflict, "no machines")
+	server.AddPostResponse("/api/2.0/machines/?op=release", http.StatusOK, "[]")
+
+	placements, err := controller.AllocateSpread(AllocateSpreadArgs{
+		Count:    3,
+		Zones:    []string{"a", "b"},
+		Fallback: SpreadFallbackNone,
+	})
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+	c.Assert(err, gc.ErrorMatches, `machine 2 of 3: no matching machines in zones \["b"\]`)
+	c.Assert(placements, gc.HasLen, 0)
+
+	request := server.LastRequest()
+	c.Assert(request.URL.String(), gc.Equals, "/api/2.0/machines/?op=release")
+	c.Check(request.PostForm["machines"], jc.DeepEquals, []string{"4y3ha3"})
+}
+
+func (s *spreadSuite) TestAllocateSpreadReleaseFails(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse(allocatePath, http.StatusOK, machineResponse)
+	server.AddPostResponse(allocatePath, http.StatusBadRequest, "bad")
+	server.AddPostResponse("/api/2.0/machines/?op=release", http.StatusConflict, "busy")
+
+	placements, err := controller.AllocateSpread(AllocateSpreadArgs{
+		Count: 2,
+		Zones: []string{"a", "b"},
+	})
+	c.Assert(err, jc.Satisfies, IsBadRequestError)
+	c.Assert(err, gc.ErrorMatches, `machine 2 of 2: bad`)
+	c.Assert(placements, gc.HasLen, 0)
+	c.Check(server.LastRequest().URL.String(), gc.Equals, "/api/2.0/machines/?op=release")
+}
+
+func (s *spreadSuite) TestAllocateSpreadExhausted(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse(allocatePath, http.StatusConflict, "no machines")
+	server.AddPostResponse(allocatePath, http.StatusConflict, "no machines")
+
+	placements, err := controller.AllocateSpread(AllocateSpreadArgs{
+		Count: 1,
+		Zones: []string{"a", "b"},
+	})
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+	c.Assert(placements, gc.HasLen, 0)
+	checkAllocatedZones(c, server, "a", "b")
+}
+
+func (s *spreadSuite) TestAllocateSpreadError(c *gc.C) {
+	server, controller := createTestServerController(c, s)
+	server.AddPostResponse(allocatePath, http.StatusBadRequest, "bad")
+
+	_, err := controller.AllocateSpread(AllocateSpreadArgs{
+		Count: 2,
+		Zones: []string{"a", "b"},
+	})
+	c.Assert(err, jc.Satisfies, IsBadRequestError)
+	c.Assert(err, gc.ErrorMatches, `machine 1 of 2: bad`)
+	c.Assert(server.LastRequest().URL.String(), gc.Equals, allocatePath)
+}
@@ -0,0 +1,222 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+	"github.com/juju/utils/set"
+)
+
+// conflictingRangePurposes are the purposes of the reserved ranges of a
+// subnet that a static host can't be given an address in. Addresses in
+// ranges that are only "reserved" can be assigned by hand.
+var conflictingRangePurposes = set.NewStrings("dynamic", "assigned-ip", "gateway-ip", "dns-server")
+
+// ReserveStaticHostArgs is an argument struct for passing parameters to
+// the Controller.ReserveStaticHost method.
+type ReserveStaticHostArgs struct {
+	// MACAddress and IPAddress are required.
+	MACAddress string
+	IPAddress  string
+	// Hostname is optional, and MAAS generates one if it is empty. It may be
+	// domain-qualified, as for CreateDeviceArgs.
+	Hostname    string
+	Domain      string
+	Zone        string
+	Description string
+}
+
+// Validate checks that the MAC address and IP address are set and valid,
+// and checks the hostname as CreateDeviceArgs does.
+func (a *ReserveStaticHostArgs) Validate() error {
+	if a.MACAddress == "" {
+		return errors.NotValidf("missing MACAddress")
+	}
+	if _, err := net.ParseMAC(a.MACAddress); err != nil {
+		return errors.NotValidf("MACAddress %q", a.MACAddress)
+	}
+	if a.IPAddress == "" {
+		return errors.NotValidf("missing IPAddress")
+	}
+	if _, err := netip.ParseAddr(a.IPAddress); err != nil {
+		return errors.NotValidf("IPAddress %q", a.IPAddress)
+	}
+	args := a.deviceArgs()
+	return errors.Trace(args.Validate())
+}
+
+func (a *ReserveStaticHostArgs) deviceArgs() CreateDeviceArgs {
+	return CreateDeviceArgs{
+		Hostname:     a.Hostname,
+		MACAddresses: []string{a.MACAddress},
+		Domain:       a.Domain,
+		Zone:         a.Zone,
+		Description:  a.Description,
+	}
+}
+
+type staticHost struct {
+	device    Device
+	subnet    Subnet
+	ipAddress string
+}
+
+// Device implements StaticHost.
+func (h *staticHost) Device() Device {
+	return h.device
+}
+
+// Subnet implements StaticHost.
+func (h *staticHost) Subnet() Subnet {
+	return h.subnet
+}
+
+// IPAddress implements StaticHost.
+func (h *staticHost) IPAddress() string {
+	return h.ipAddress
+}
+
+// Release implements StaticHost.
+func (h *staticHost) Release() error {
+	return errors.Trace(h.device.Delete())
+}
+
+// ReserveStaticHost implements Controller.
+//
+// Returns
+//  - NotValid error if the args aren't valid
+//  - NoMatchError if no subnet holds the IP address
+//  - BadRequestError if the IP address is in use or in a dynamic range, or
+//    MAAS refuses the device or the link
+//  - CannotCompleteError if MAAS didn't give the device the IP address
+func (c *controller) ReserveStaticHost(args ReserveStaticHostArgs) (_ StaticHost, err error) {
+	if err := args.Validate(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	addr := netip.MustParseAddr(args.IPAddress)
+	subnet, err := c.subnetHolding(addr)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := c.checkAddressFree(subnet, addr); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	device, err := c.CreateDevice(args.deviceArgs())
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	defer func(err *error) {
+		// If there is an error return, at least try to delete the device we just created.
+		if *err != nil {
+			if innerErr := device.Delete(); innerErr != nil {
+				c.logger.Warnf("could not delete device %q", device.SystemID())
+			}
+		}
+	}(&err)
+
+	host := &staticHost{device: device, subnet: subnet, ipAddress: addr.String()}
+	if err := c.linkStaticHost(host); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return host, nil
+}
+
+// subnetHolding returns the most specific subnet that holds the address.
+func (c *controller) subnetHolding(addr netip.Addr) (Subnet, error) {
+	subnets, err := c.findSubnets(func(s *subnet) bool {
+		return s.CIDRPrefix().Contains(addr)
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var result Subnet
+	for _, s := range subnets {
+		if result == nil || s.CIDRPrefix().Bits() > result.CIDRPrefix().Bits() {
+			result = s
+		}
+	}
+	if result == nil {
+		return nil, NewNoMatchError(fmt.Sprintf("no subnet holds %s", addr))
+	}
+	return result, nil
+}
+
+// checkAddressFree returns a BadRequestError if the address is in one of the
+// reserved ranges of the subnet that it can't be assigned from.
+func (c *controller) checkAddressFree(subnet Subnet, addr netip.Addr) error {
+	source, err := c._get(fmt.Sprintf("subnets/%d", subnet.ID()), "reserved_ip_ranges", nil)
+	if err != nil {
+		return translateError(opEntity, err)
+	}
+	fields := schema.Fields{
+		"start":   schema.String(),
+		"end":     schema.String(),
+		"purpose": schema.List(schema.String()),
+	}
+	defaults := schema.Defaults{
+		"purpose": schema.Omit,
+	}
+	checker := schema.List(schema.FieldMap(fields, defaults))
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return WrapWithDeserializationError(err, "reserved IP ranges schema check failed")
+	}
+	for _, value := range coerced.([]interface{}) {
+		valid := value.(map[string]interface{})
+		start, err := netip.ParseAddr(valid["start"].(string))
+		if err != nil {
+			return WrapWithDeserializationError(err, "reserved IP range start")
+		}
+		end, err := netip.ParseAddr(valid["end"].(string))
+		if err != nil {
+			return WrapWithDeserializationError(err, "reserved IP range end")
+		}
+		if addr.Less(start) || end.Less(addr) {
+			continue
+		}
+		purposes, _ := valid["purpose"].([]interface{})
+		var conflicts []string
+		for _, purpose := range purposes {
+			if conflictingRangePurposes.Contains(purpose.(string)) {
+				conflicts = append(conflicts, purpose.(string))
+			}
+		}
+		if len(conflicts) > 0 {
+			return NewBadRequestError(fmt.Sprintf("%s is reserved in subnet %q (%s)", addr, subnet.CIDR(), strings.Join(conflicts, ", ")))
+		}
+	}
+	return nil
+}
+
+// linkStaticHost links the interface of the host's device to its subnet
+// with its address, and checks that MAAS assigned the address.
+func (c *controller) linkStaticHost(host *staticHost) error {
+	interfaces := host.device.InterfaceSet()
+	if len(interfaces) != 1 {
+		return NewCannotCompleteError(fmt.Sprintf("device %q has %d interfaces, expected 1", host.device.SystemID(), len(interfaces)))
+	}
+	iface := interfaces[0]
+	err := iface.LinkSubnet(LinkSubnetArgs{
+		Mode:      LinkModeStatic,
+		Subnet:    host.subnet,
+		IPAddress: host.ipAddress,
+	})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, link := range iface.Links() {
+		if s := link.Subnet(); s != nil && s.ID() == host.subnet.ID() && link.IPAddress() == host.ipAddress {
+			return nil
+		}
+	}
+	return NewCannotCompleteError(fmt.Sprintf("device %q not given %s", host.device.SystemID(), host.ipAddress))
+}
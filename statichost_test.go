@@ -0,0 +1,168 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+const (
+	reservedRangesPath   = "/api/2.0/subnets/1/?op=reserved_ip_ranges"
+	staticHostLinkPath   = "/MAAS/api/2.0/nodes/4y3haf/interfaces/48/?op=link_subnet"
+	staticHostDevicePath = "/MAAS/api/2.0/devices/4y3haf/"
+	reservedRanges       = `[
+    {"start": "192.168.100.1", "end": "192.168.100.1", "num_addresses": 1, "purpose": ["gateway-ip"]},
+    {"start": "192.168.100.20", "end": "192.168.100.29", "num_addresses": 10, "purpose": ["reserved"]},
+    {"start": "192.168.100.100", "end": "192.168.100.200", "num_addresses": 101, "purpose": ["dynamic"]}
+]`
+)
+
+// staticHostLinkResponse returns the interface of the created device, linked
+// to subnet 1 with the address.
+func staticHostLinkResponse(c *gc.C, ipAddress string) string {
+	source := parseJSON(c, interfaceResponse).(map[string]interface{})
+	source["resource_uri"] = "/MAAS/api/2.0/nodes/4y3haf/interfaces/48/"
+	link := source["links"].([]interface{})[0].(map[string]interface{})
+	link["mode"] = "static"
+	link["ip_address"] = ipAddress
+	bytes, err := json.Marshal(source)
+	c.Assert(err, jc.ErrorIsNil)
+	return string(bytes)
+}
+
+func (s *controllerSuite) addStaticHostResponses() {
+	s.server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, subnetResponse)
+	s.server.AddGetResponse(reservedRangesPath, http.StatusOK, reservedRanges)
+	s.server.AddPostResponse("/api/2.0/devices/?op=", http.StatusOK, createDeviceResponse)
+}
+
+func (s *controllerSuite) TestReserveStaticHostArgsValidate(c *gc.C) {
+	for i, test := range []struct {
+		args    ReserveStaticHostArgs
+		message string
+	}{{
+		args:    ReserveStaticHostArgs{IPAddress: "192.168.100.11"},
+		message: "missing MACAddress not valid",
+	}, {
+		args:    ReserveStaticHostArgs{MACAddress: "wat", IPAddress: "192.168.100.11"},
+		message: `MACAddress "wat" not valid`,
+	}, {
+		args:    ReserveStaticHostArgs{MACAddress: "78:f0:f1:16:a7:46"},
+		message: "missing IPAddress not valid",
+	}, {
+		args:    ReserveStaticHostArgs{MACAddress: "78:f0:f1:16:a7:46", IPAddress: "192.168.100"},
+		message: `IPAddress "192.168.100" not valid`,
+	}, {
+		args:    ReserveStaticHostArgs{MACAddress: "78:f0:f1:16:a7:46", IPAddress: "192.168.100.11", Hostname: "no_underscores"},
+		message: `Hostname "no_underscores" not valid`,
+	}} {
+		c.Logf("test %d", i)
+		err := test.args.Validate()
+		c.Check(err, jc.Satisfies, errors.IsNotValid)
+		c.Check(err, gc.ErrorMatches, test.message)
+	}
+}
+
+func (s *controllerSuite) TestReserveStaticHost(c *gc.C) {
+	s.addStaticHostResponses()
+	s.server.AddPostResponse(staticHostLinkPath, http.StatusOK, staticHostLinkResponse(c, "192.168.100.11"))
+	controller := s.getController(c)
+
+	host, err := controller.ReserveStaticHost(ReserveStaticHostArgs{
+		MACAddress: "78:f0:f1:16:a7:46",
+		IPAddress:  "192.168.100.11",
+		Hostname:   "printer",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(host.Device().SystemID(), gc.Equals, "4y3haf")
+	c.Check(host.Subnet().ID(), gc.Equals, 1)
+	c.Check(host.IPAddress(), gc.Equals, "192.168.100.11")
+
+	form := s.server.LastRequest().PostForm
+	c.Check(form.Get("mode"), gc.Equals, "STATIC")
+	c.Check(form.Get("subnet"), gc.Equals, "1")
+	c.Check(form.Get("ip_address"), gc.Equals, "192.168.100.11")
+
+	s.server.AddDeleteResponse(staticHostDevicePath, http.StatusNoContent, "")
+	err = host.Release()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(s.server.LastRequest().Method, gc.Equals, "DELETE")
+}
+
+func (s *controllerSuite) TestReserveStaticHostInReservedRange(c *gc.C) {
+	s.addStaticHostResponses()
+	s.server.AddPostResponse(staticHostLinkPath, http.StatusOK, staticHostLinkResponse(c, "192.168.100.25"))
+	controller := s.getController(c)
+
+	// Addresses in ranges that are only reserved can be assigned.
+	_, err := controller.ReserveStaticHost(ReserveStaticHostArgs{
+		MACAddress: "78:f0:f1:16:a7:46",
+		IPAddress:  "192.168.100.25",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+}
+
+func (s *controllerSuite) TestReserveStaticHostInDynamicRange(c *gc.C) {
+	s.addStaticHostResponses()
+	controller := s.getController(c)
+
+	_, err := controller.ReserveStaticHost(ReserveStaticHostArgs{
+		MACAddress: "78:f0:f1:16:a7:46",
+		IPAddress:  "192.168.100.150",
+	})
+	c.Assert(err, jc.Satisfies, IsBadRequestError)
+	c.Assert(err.Error(), gc.Equals, `192.168.100.150 is reserved in subnet "192.168.100.0/24" (dynamic)`)
+	// No device was created.
+	c.Check(s.server.LastRequest().Method, gc.Equals, "GET")
+}
+
+func (s *controllerSuite) TestReserveStaticHostNoSubnet(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, subnetResponse)
+	s.server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, subnetResponse)
+	controller := s.getController(c)
+
+	_, err := controller.ReserveStaticHost(ReserveStaticHostArgs{
+		MACAddress: "78:f0:f1:16:a7:46",
+		IPAddress:  "10.0.0.1",
+	})
+	c.Assert(err, jc.Satisfies, IsNoMatchError)
+	c.Assert(err.Error(), gc.Equals, "no subnet holds 10.0.0.1")
+}
+
+func (s *controllerSuite) TestReserveStaticHostLinkFailsDeletesDevice(c *gc.C) {
+	s.addStaticHostResponses()
+	s.server.AddPostResponse(staticHostLinkPath, http.StatusBadRequest, "IP address is already in use")
+	s.server.AddDeleteResponse(staticHostDevicePath, http.StatusNoContent, "")
+	controller := s.getController(c)
+
+	_, err := controller.ReserveStaticHost(ReserveStaticHostArgs{
+		MACAddress: "78:f0:f1:16:a7:46",
+		IPAddress:  "192.168.100.11",
+	})
+	c.Assert(err, jc.Satisfies, IsBadRequestError)
+	c.Assert(err.Error(), gc.Equals, "IP address is already in use")
+	request := s.server.LastRequest()
+	c.Check(request.Method, gc.Equals, "DELETE")
+	c.Check(request.URL.Path, gc.Equals, staticHostDevicePath)
+}
+
+func (s *controllerSuite) TestReserveStaticHostNotAssignedDeletesDevice(c *gc.C) {
+	s.addStaticHostResponses()
+	s.server.AddPostResponse(staticHostLinkPath, http.StatusOK, staticHostLinkResponse(c, "192.168.100.12"))
+	s.server.AddDeleteResponse(staticHostDevicePath, http.StatusNoContent, "")
+	controller := s.getController(c)
+
+	_, err := controller.ReserveStaticHost(ReserveStaticHostArgs{
+		MACAddress: "78:f0:f1:16:a7:46",
+		IPAddress:  "192.168.100.11",
+	})
+	c.Assert(err, jc.Satisfies, IsCannotCompleteError)
+	c.Assert(err.Error(), gc.Equals, `device "4y3haf" not given 192.168.100.11`)
+	c.Check(s.server.LastRequest().Method, gc.Equals, "DELETE")
+}
@@ -19,6 +19,13 @@ type staticRoute struct {
 	metric      int
 }
 
+func (s *staticRoute) addressErr() error {
+	if err := s.source.addressErr(); err != nil {
+		return err
+	}
+	return s.destination.addressErr()
+}
+
 // Id implements StaticRoute.
 func (s *staticRoute) ID() int {
 	return s.id
@@ -75,7 +82,7 @@ func readStaticRouteList(sourceList []interface{}, readFunc staticRouteDeseriali
 		}
 		staticRoute, err := readFunc(source)
 		if err != nil {
-			return nil, errors.Annotatef(err, "static-route %d", i)
+			return nil, annotateListItem(err, "static-route", i, source)
 		}
 		result = append(result, staticRoute)
 	}
@@ -88,17 +95,17 @@ var staticRouteDeserializationFuncs = map[version.Number]staticRouteDeserializat
 	twoDotOh: staticRoute_2_0,
 }
 
+var staticRoute_2_0Checker = fieldMap("static route", schema.Fields{
+	"resource_uri": schema.String(),
+	"id":           schema.ForceInt(),
+	"source":       schema.StringMap(schema.Any()),
+	"destination":  schema.StringMap(schema.Any()),
+	"gateway_ip":   schema.String(),
+	"metric":       schema.ForceInt(),
+}, nil) // no defaults
+
 func staticRoute_2_0(source map[string]interface{}) (*staticRoute, error) {
-	fields := schema.Fields{
-		"resource_uri": schema.String(),
-		"id":           schema.ForceInt(),
-		"source":       schema.StringMap(schema.Any()),
-		"destination":  schema.StringMap(schema.Any()),
-		"gateway_ip":   schema.String(),
-		"metric":       schema.ForceInt(),
-	}
-	checker := schema.FieldMap(fields, nil) // no defaults
-	coerced, err := checker.Coerce(source, nil)
+	coerced, err := staticRoute_2_0Checker.Coerce(source, nil)
 	if err != nil {
 		return nil, errors.Annotatef(err, "static-route 2.0 schema check failed")
 	}
@@ -0,0 +1,127 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"strings"
+
+	"github.com/juju/schema"
+	"github.com/juju/utils/set"
+)
+
+// entitySchema is the set of fields checked by the deserialization
+// functions for an entity, and those of them that have default values.
+type entitySchema struct {
+	fields   set.Strings
+	defaults set.Strings
+}
+
+// entitySchemas records the fields checked for each entity by the
+// deserialization functions. The different versions of an entity add to
+// the same set, as machine_2_3 does on top of machine_2_0. It is only
+// written while the package variables are initialised, by fieldMap, so it
+// is read without a lock.
+var entitySchemas = make(map[string]*entitySchema)
+
+// nestedEntities maps the fields of an entity that hold other entities to
+// the names of those entities, so that strict decoding can check them too.
+var nestedEntities = map[string]map[string]string{
+	"machine": {
+		"boot_interface":          "interface",
+		"interface_set":           "interface",
+		"zone":                    "zone",
+		"pool":                    "pool",
+		"physicalblockdevice_set": "blockdevice",
+		"blockdevice_set":         "blockdevice",
+	},
+	"device": {
+		"interface_set": "interface",
+		"zone":          "zone",
+		"domain":        "domain",
+		"pool":          "pool",
+	},
+	"interface":    {"vlan": "vlan", "links": "link"},
+	"link":         {"subnet": "subnet"},
+	"subnet":       {"vlan": "vlan"},
+	"space":        {"subnets": "subnet"},
+	"fabric":       {"vlans": "vlan"},
+	"blockdevice":  {"partitions": "partition"},
+	"partition":    {"filesystem": "filesystem"},
+	"static route": {"source": "subnet", "destination": "subnet"},
+}
+
+// fieldMap returns schema.FieldMap(fields, defaults), recording the fields
+// against the entity for strict decoding. It must only be used to
+// initialise package variables, so that the checkers are built once and
+// the fields of every entity are known before any response is read.
+func fieldMap(entity string, fields schema.Fields, defaults schema.Defaults) schema.Checker {
+	known, ok := entitySchemas[entity]
+	if !ok {
+		known = &entitySchema{fields: set.NewStrings(), defaults: set.NewStrings()}
+		entitySchemas[entity] = known
+	}
+	for name := range fields {
+		known.fields.Add(name)
+	}
+	for name, value := range defaults {
+		// Omitted fields are optional rather than defaulted.
+		if value != schema.Omit {
+			known.defaults.Add(name)
+		}
+	}
+	return schema.FieldMap(fields, defaults)
+}
+
+// checkStrict compares the source, a single entity or a list of them, with
+// the fields read by the deserialization functions. It returns a
+// DeserializationError listing the fields in the source that aren't read,
+// and the fields that are missing and so were given their default values.
+func checkStrict(entity string, source interface{}) error {
+	unknown, missing := set.NewStrings(), set.NewStrings()
+	collectStrictFields(entity, "", source, unknown, missing)
+	if unknown.IsEmpty() && missing.IsEmpty() {
+		return nil
+	}
+	var problems []string
+	if !unknown.IsEmpty() {
+		problems = append(problems, "unknown fields: "+strings.Join(unknown.SortedValues(), ", "))
+	}
+	if !missing.IsEmpty() {
+		problems = append(problems, "missing fields: "+strings.Join(missing.SortedValues(), ", "))
+	}
+	return NewDeserializationError("%s strict decoding failed: %s", entity, strings.Join(problems, "; "))
+}
+
+// collectStrictFields adds the unknown and missing fields of the source to
+// the sets, named by their path from the top level entity. Fields of lists
+// of entities are named without an index, so each is reported once.
+func collectStrictFields(entity, prefix string, source interface{}, unknown, missing set.Strings) {
+	switch value := source.(type) {
+	case []interface{}:
+		for _, item := range value {
+			collectStrictFields(entity, prefix, item, unknown, missing)
+		}
+	case map[string]interface{}:
+		known, ok := entitySchemas[entity]
+		if !ok {
+			// The entity has no schema, so there is nothing to
+			// compare against.
+			return
+		}
+		for name, field := range value {
+			if !known.fields.Contains(name) {
+				unknown.Add(prefix + name)
+				continue
+			}
+			if nested, ok := nestedEntities[entity][name]; ok {
+				collectStrictFields(nested, prefix+name+".", field, unknown, missing)
+			}
+		}
+		for _, name := range known.defaults.Values() {
+			if _, ok := value[name]; !ok {
+				missing.Add(prefix + name)
+			}
+		}
+	}
+}
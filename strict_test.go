@@ -0,0 +1,74 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+type strictSuite struct{}
+
+var _ = gc.Suite(&strictSuite{})
+
+func (*strictSuite) TestCheckStrictMatching(c *gc.C) {
+	source := parseJSON(c, zoneResponse)
+	_, err := readZones(twoDotOh, source)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(checkStrict("zone", source), jc.ErrorIsNil)
+}
+
+func (*strictSuite) TestCheckStrictUnknownFields(c *gc.C) {
+	source := parseJSON(c, `[
+	    {"name": "default", "description": "", "resource_uri": "/zones/default/", "colour": "red"},
+	    {"name": "special", "description": "", "resource_uri": "/zones/special/", "colour": "blue", "size": 3}
+	]`)
+	_, err := readZones(twoDotOh, source)
+	c.Assert(err, jc.ErrorIsNil)
+
+	err = checkStrict("zone", source)
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err.Error(), gc.Equals, "zone strict decoding failed: unknown fields: colour, size")
+}
+
+func (*strictSuite) TestCheckStrictNestedFields(c *gc.C) {
+	source := parseJSON(c, `[
+	    {"id": 1, "mode": "dhcp"},
+	    {"id": 2, "mode": "static", "ip_address": "10.0.0.2", "subnet": {
+	        "resource_uri": "/subnets/1/", "id": 1, "name": "a", "space": "b",
+	        "gateway_ip": null, "cidr": "10.0.0.0/24", "dns_servers": [], "rdns_mode": 2, "managed": true,
+	        "vlan": {"id": 1, "resource_uri": "/vlans/1/", "name": "untagged", "fabric": "fabric-0",
+	                 "vid": 0, "mtu": 1500, "dhcp_on": false, "primary_rack": null, "secondary_rack": null,
+	                 "external_dhcp": null, "colour": "red"}
+	    }}
+	]`)
+	_, err := readLinkList(source.([]interface{}), link_2_0)
+	c.Assert(err, jc.ErrorIsNil)
+
+	err = checkStrict("link", source)
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+	c.Assert(err.Error(), gc.Equals, "link strict decoding failed: "+
+		"unknown fields: subnet.managed, subnet.vlan.colour; missing fields: ip_address")
+}
+
+func (*strictSuite) TestSchemasRegisteredUpFront(c *gc.C) {
+	for entity, nested := range nestedEntities {
+		c.Check(entitySchemas[entity], gc.NotNil, gc.Commentf(entity))
+		for _, name := range nested {
+			c.Check(entitySchemas[name], gc.NotNil, gc.Commentf(name))
+		}
+	}
+	// The fields of later versions, and of entities that are only read
+	// nested in others, are known without reading any of them.
+	c.Check(entitySchemas["machine"].fields.Contains("workload_annotations"), jc.IsTrue)
+	c.Check(entitySchemas["domain"].fields.Contains("ttl"), jc.IsTrue)
+	c.Check(entitySchemas["link"].fields.Contains("ip_address"), jc.IsTrue)
+}
+
+func (*strictSuite) TestCheckStrictUnreadNestedEntity(c *gc.C) {
+	// The domain of a device is only read for its name, but the rest of
+	// the domain's fields are known from domain_2_0.
+	source := parseJSON(c, `{"name": "maas", "id": 0, "ttl": null, "authoritative": true, "resource_uri": "/domains/0/"}`)
+	c.Assert(checkStrict("domain", source), jc.ErrorIsNil)
+}
@@ -4,14 +4,17 @@
 package gomaasapi
 
 import (
+	"fmt"
+	"net/netip"
+	"strings"
+
 	"github.com/juju/errors"
 	"github.com/juju/schema"
 	"github.com/juju/version"
 )
 
 type subnet struct {
-	// Add the controller in when we need to do things with the subnet.
-	// controller Controller
+	controller *controller
 
 	resourceURI string
 
@@ -23,9 +26,31 @@ type subnet struct {
 	gateway string
 	cidr    string
 
+	gatewayAddr netip.Addr
+	cidrPrefix  netip.Prefix
+	addrErr     error
+
 	dnsServers []string
+	rdnsMode   RDNSMode
 }
 
+// RDNSMode is how MAAS serves the reverse DNS of a subnet, as returned by
+// Subnet.RDNSMode.
+type RDNSMode int
+
+const (
+	// RDNSModeDisabled - No reverse zone is created for the subnet.
+	RDNSModeDisabled RDNSMode = 0
+	// RDNSModeEnabled - A reverse zone is created for the subnet, on the
+	// octet or nibble boundary that contains it. For IPv6 subnets the
+	// zone is always on a nibble boundary.
+	RDNSModeEnabled RDNSMode = 1
+	// RDNSModeRFC2317 - As for RDNSModeEnabled, with glue for the parts
+	// of IPv4 subnets smaller than a /24. It is the same as
+	// RDNSModeEnabled for IPv6 subnets, and is the default.
+	RDNSModeRFC2317 RDNSMode = 2
+)
+
 // ID implements Subnet.
 func (s *subnet) ID() int {
 	return s.id
@@ -49,6 +74,14 @@ func (s *subnet) VLAN() VLAN {
 	return s.vlan
 }
 
+// bind associates the subnet, and its VLAN, with the controller.
+func (s *subnet) bind(c *controller) {
+	s.controller = c
+	if s.vlan != nil {
+		s.vlan.bind(c)
+	}
+}
+
 // Gateway implements Subnet.
 func (s *subnet) Gateway() string {
 	return s.gateway
@@ -59,11 +92,144 @@ func (s *subnet) CIDR() string {
 	return s.cidr
 }
 
+// GatewayAddr implements Subnet.
+func (s *subnet) GatewayAddr() netip.Addr {
+	return s.gatewayAddr
+}
+
+// CIDRPrefix implements Subnet.
+func (s *subnet) CIDRPrefix() netip.Prefix {
+	return s.cidrPrefix
+}
+
+func (s *subnet) addressErr() error {
+	return s.addrErr
+}
+
 // DNSServers implements Subnet.
 func (s *subnet) DNSServers() []string {
 	return s.dnsServers
 }
 
+// RDNSMode implements Subnet.
+func (s *subnet) RDNSMode() RDNSMode {
+	return s.rdnsMode
+}
+
+// IsIPv6 implements Subnet.
+func (s *subnet) IsIPv6() bool {
+	return s.cidrPrefix.Addr().Is6()
+}
+
+// UpdateSubnetArgs is an argument struct for passing information into
+// Subnet.Update. Empty fields are left unchanged.
+type UpdateSubnetArgs struct {
+	Name    string
+	Gateway string
+	// DNSServers replaces the subnet's DNS servers if not nil. An empty,
+	// non-nil slice removes all of them.
+	DNSServers []string
+	// RDNSMode changes how reverse DNS is served for the subnet, if not
+	// nil.
+	RDNSMode *RDNSMode
+}
+
+// Validate checks the gateway and the reverse DNS mode.
+func (a UpdateSubnetArgs) Validate() error {
+	if _, err := parseOptionalAddr(a.Gateway); err != nil {
+		return errors.NotValidf("Gateway %q", a.Gateway)
+	}
+	return validateRDNSMode(a.RDNSMode)
+}
+
+func validateRDNSMode(mode *RDNSMode) error {
+	if mode == nil {
+		return nil
+	}
+	switch *mode {
+	case RDNSModeDisabled, RDNSModeEnabled, RDNSModeRFC2317:
+		return nil
+	}
+	return errors.NotValidf("RDNSMode %d", *mode)
+}
+
+// addSubnetParams adds the params that are common to creating and
+// updating a subnet.
+func addSubnetParams(params *URLParams, name, gateway string, dnsServers []string, rdnsMode *RDNSMode) {
+	params.MaybeAdd("name", name)
+	params.MaybeAdd("gateway_ip", gateway)
+	if dnsServers != nil {
+		params.Values.Add("dns_servers", strings.Join(dnsServers, ","))
+	}
+	if rdnsMode != nil {
+		params.Values.Add("rdns_mode", fmt.Sprint(int(*rdnsMode)))
+	}
+}
+
+// Update implements Subnet.
+//
+// Returns
+//  - NotValid error if the gateway or the reverse DNS mode is not valid
+//  - NotSupported error if the subnet wasn't obtained from a Controller
+//  - BadRequestError if the server rejects the changes
+//  - PermissionError if the user does not have permission to change the subnet
+//  - NoMatchError if the subnet cannot be found
+func (s *subnet) Update(args UpdateSubnetArgs) error {
+	if err := args.Validate(); err != nil {
+		return errors.Trace(err)
+	}
+	if args.Name == "" && args.Gateway == "" && args.DNSServers == nil && args.RDNSMode == nil {
+		return nil
+	}
+	if s.controller == nil {
+		return errors.NotSupportedf("updating subnet %d without a controller", s.id)
+	}
+	params := NewURLParams()
+	addSubnetParams(params, args.Name, args.Gateway, args.DNSServers, args.RDNSMode)
+	source, err := s.controller.put(s.resourceURI, params.Values)
+	if err != nil {
+		return translateError(opEntity, err)
+	}
+	response, err := readSubnet(s.controller.schemaVersion(), source)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err = s.controller.checkDecoded("subnet", source, response); err != nil {
+		return errors.Trace(err)
+	}
+	s.updateFrom(response)
+	return nil
+}
+
+func (s *subnet) updateFrom(other *subnet) {
+	s.resourceURI = other.resourceURI
+	s.id = other.id
+	s.name = other.name
+	s.space = other.space
+	s.vlan = other.vlan
+	s.gateway = other.gateway
+	s.cidr = other.cidr
+	s.gatewayAddr = other.gatewayAddr
+	s.cidrPrefix = other.cidrPrefix
+	s.addrErr = other.addrErr
+	s.dnsServers = other.dnsServers
+	s.rdnsMode = other.rdnsMode
+	s.bind(s.controller)
+}
+
+func readSubnet(controllerVersion version.Number, source interface{}) (*subnet, error) {
+	readFunc, err := getSubnetDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	checker := schema.StringMap(schema.Any())
+	coerced, err := checker.Coerce(source, nil)
+	if err != nil {
+		return nil, errors.Annotatef(err, "subnet base schema check failed")
+	}
+	return readFunc(coerced.(map[string]interface{}))
+}
+
 func readSubnets(controllerVersion version.Number, source interface{}) ([]*subnet, error) {
 	checker := schema.List(schema.StringMap(schema.Any()))
 	coerced, err := checker.Coerce(source, nil)
@@ -72,6 +238,14 @@ func readSubnets(controllerVersion version.Number, source interface{}) ([]*subne
 	}
 	valid := coerced.([]interface{})
 
+	readFunc, err := getSubnetDeserializationFunc(controllerVersion)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return readSubnetList(valid, readFunc)
+}
+
+func getSubnetDeserializationFunc(controllerVersion version.Number) (subnetDeserializationFunc, error) {
 	var deserialisationVersion version.Number
 	for v := range subnetDeserializationFuncs {
 		if v.Compare(deserialisationVersion) > 0 && v.Compare(controllerVersion) <= 0 {
@@ -81,8 +255,7 @@ func readSubnets(controllerVersion version.Number, source interface{}) ([]*subne
 	if deserialisationVersion == version.Zero {
 		return nil, errors.Errorf("no subnet read func for version %s", controllerVersion)
 	}
-	readFunc := subnetDeserializationFuncs[deserialisationVersion]
-	return readSubnetList(valid, readFunc)
+	return subnetDeserializationFuncs[deserialisationVersion], nil
 }
 
 // readSubnetList expects the values of the sourceList to be string maps.
@@ -95,7 +268,7 @@ func readSubnetList(sourceList []interface{}, readFunc subnetDeserializationFunc
 		}
 		subnet, err := readFunc(source)
 		if err != nil {
-			return nil, errors.Annotatef(err, "subnet %d", i)
+			return nil, annotateListItem(err, "subnet", i, source)
 		}
 		result = append(result, subnet)
 	}
@@ -108,19 +281,22 @@ var subnetDeserializationFuncs = map[version.Number]subnetDeserializationFunc{
 	twoDotOh: subnet_2_0,
 }
 
+var subnet_2_0Checker = fieldMap("subnet", schema.Fields{
+	"resource_uri": schema.String(),
+	"id":           schema.ForceInt(),
+	"name":         schema.String(),
+	"space":        schema.String(),
+	"gateway_ip":   schema.OneOf(schema.Nil(""), schema.String()),
+	"cidr":         schema.String(),
+	"vlan":         schema.StringMap(schema.Any()),
+	"dns_servers":  schema.OneOf(schema.Nil(""), schema.List(schema.String())),
+	"rdns_mode":    schema.ForceInt(),
+}, schema.Defaults{
+	"rdns_mode": int(RDNSModeRFC2317),
+})
+
 func subnet_2_0(source map[string]interface{}) (*subnet, error) {
-	fields := schema.Fields{
-		"resource_uri": schema.String(),
-		"id":           schema.ForceInt(),
-		"name":         schema.String(),
-		"space":        schema.String(),
-		"gateway_ip":   schema.OneOf(schema.Nil(""), schema.String()),
-		"cidr":         schema.String(),
-		"vlan":         schema.StringMap(schema.Any()),
-		"dns_servers":  schema.OneOf(schema.Nil(""), schema.List(schema.String())),
-	}
-	checker := schema.FieldMap(fields, nil) // no defaults
-	coerced, err := checker.Coerce(source, nil)
+	coerced, err := subnet_2_0Checker.Coerce(source, nil)
 	if err != nil {
 		return nil, errors.Annotatef(err, "subnet 2.0 schema check failed")
 	}
@@ -137,6 +313,12 @@ func subnet_2_0(source map[string]interface{}) (*subnet, error) {
 	// the cast fails, then we get the default value we care about, which is the
 	// empty string.
 	gateway, _ := valid["gateway_ip"].(string)
+	gatewayAddr, addrErr := parseOptionalAddr(gateway)
+	cidr := valid["cidr"].(string)
+	cidrPrefix, err := parsePrefix(cidr)
+	if addrErr == nil {
+		addrErr = err
+	}
 
 	result := &subnet{
 		resourceURI: valid["resource_uri"].(string),
@@ -145,8 +327,12 @@ func subnet_2_0(source map[string]interface{}) (*subnet, error) {
 		space:       valid["space"].(string),
 		vlan:        vlan,
 		gateway:     gateway,
-		cidr:        valid["cidr"].(string),
+		cidr:        cidr,
+		gatewayAddr: gatewayAddr,
+		cidrPrefix:  cidrPrefix,
+		addrErr:     addrErr,
 		dnsServers:  convertToStringSlice(valid["dns_servers"]),
+		rdnsMode:    RDNSMode(valid["rdns_mode"].(int)),
 	}
 	return result, nil
 }
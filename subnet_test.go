@@ -4,6 +4,9 @@
 package gomaasapi
 
 import (
+	"net/netip"
+
+	"github.com/juju/errors"
 	jc "github.com/juju/testing/checkers"
 	"github.com/juju/version"
 	gc "gopkg.in/check.v1"
@@ -34,10 +37,58 @@ func (*subnetSuite) TestReadSubnets(c *gc.C) {
 	c.Assert(subnet.Space(), gc.Equals, "space-0")
 	c.Assert(subnet.Gateway(), gc.Equals, "192.168.100.1")
 	c.Assert(subnet.CIDR(), gc.Equals, "192.168.100.0/24")
+	c.Assert(subnet.GatewayAddr(), gc.Equals, netip.MustParseAddr("192.168.100.1"))
+	c.Assert(subnet.CIDRPrefix(), gc.Equals, netip.MustParsePrefix("192.168.100.0/24"))
 	vlan := subnet.VLAN()
 	c.Assert(vlan, gc.NotNil)
 	c.Assert(vlan.Name(), gc.Equals, "untagged")
 	c.Assert(subnet.DNSServers(), jc.DeepEquals, []string{"8.8.8.8", "8.8.4.4"})
+	c.Assert(subnet.RDNSMode(), gc.Equals, RDNSModeRFC2317)
+	c.Assert(subnet.IsIPv6(), jc.IsFalse)
+}
+
+func (*subnetSuite) TestReadSubnetIPv6(c *gc.C) {
+	subnets, err := readSubnets(twoDotOh, parseJSON(c, `[{
+        "gateway_ip": "2001:db8::1",
+        "name": "2001:db8::/64",
+        "vlan": {
+            "fabric": "fabric-0",
+            "resource_uri": "/MAAS/api/2.0/vlans/1/",
+            "name": "untagged",
+            "vid": 0,
+            "dhcp_on": true,
+            "id": 1,
+            "mtu": 1500
+        },
+        "space": "space-0",
+        "id": 7,
+        "resource_uri": "/MAAS/api/2.0/subnets/7/",
+        "dns_servers": [],
+        "cidr": "2001:db8::/64",
+        "rdns_mode": 1
+    }]`))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(subnets, gc.HasLen, 1)
+	subnet := subnets[0]
+	c.Check(subnet.IsIPv6(), jc.IsTrue)
+	c.Check(subnet.RDNSMode(), gc.Equals, RDNSModeEnabled)
+	c.Check(subnet.GatewayAddr(), gc.Equals, netip.MustParseAddr("2001:db8::1"))
+}
+
+func (*subnetSuite) TestUpdateValidates(c *gc.C) {
+	var empty subnet
+	err := empty.Update(UpdateSubnetArgs{Gateway: "wat"})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	mode := RDNSMode(3)
+	err = empty.Update(UpdateSubnetArgs{RDNSMode: &mode})
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+}
+
+func (*subnetSuite) TestUpdateWithoutController(c *gc.C) {
+	var empty subnet
+	mode := RDNSModeDisabled
+	err := empty.Update(UpdateSubnetArgs{RDNSMode: &mode})
+	c.Check(err, jc.Satisfies, errors.IsNotSupported)
 }
 
 func (*subnetSuite) TestLowVersion(c *gc.C) {
@@ -97,3 +148,27 @@ var subnetResponse = `
     }
 ]
 `
+
+var ipv6SubnetResponse = `
+{
+    "gateway_ip": "2001:db8::1",
+    "name": "2001:db8::/64",
+    "vlan": {
+        "fabric": "fabric-0",
+        "resource_uri": "/MAAS/api/2.0/vlans/1/",
+        "name": "untagged",
+        "secondary_rack": null,
+        "primary_rack": "4y3h7n",
+        "vid": 0,
+        "dhcp_on": true,
+        "id": 1,
+        "mtu": 1500
+    },
+    "space": "space-0",
+    "id": 7,
+    "resource_uri": "/MAAS/api/2.0/subnets/7/",
+    "dns_servers": ["2001:db8::53"],
+    "cidr": "2001:db8::/64",
+    "rdns_mode": 1
+}
+`
@@ -5,9 +5,12 @@ package gomaasapi
 
 import (
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type singleServingServer struct {
@@ -49,6 +52,7 @@ type flakyServer struct {
 	*httptest.Server
 	nbRequests *int
 	requests   *[][]byte
+	headers    *[]http.Header
 }
 
 // newFlakyServer creates a "flaky" test http server which will
@@ -56,6 +60,7 @@ type flakyServer struct {
 func newFlakyServer(uri string, code int, nbFlakyResponses int) *flakyServer {
 	nbRequests := 0
 	requests := make([][]byte, nbFlakyResponses+1)
+	headers := make([]http.Header, nbFlakyResponses+1)
 	handler := func(writer http.ResponseWriter, request *http.Request) {
 		nbRequests += 1
 		body, err := readAndClose(request.Body)
@@ -63,6 +68,7 @@ func newFlakyServer(uri string, code int, nbFlakyResponses int) *flakyServer {
 			panic(err)
 		}
 		requests[nbRequests-1] = body
+		headers[nbRequests-1] = request.Header
 		if request.URL.String() != uri {
 			errorMsg := fmt.Sprintf("Error 404: page not found (expected '%v', got '%v').", uri, request.URL.String())
 			http.Error(writer, errorMsg, http.StatusNotFound)
@@ -79,12 +85,34 @@ func newFlakyServer(uri string, code int, nbFlakyResponses int) *flakyServer {
 
 	}
 	server := httptest.NewServer(http.HandlerFunc(handler))
-	return &flakyServer{server, &nbRequests, &requests}
+	return &flakyServer{server, &nbRequests, &requests, &headers}
 }
 
 type simpleResponse struct {
 	status int
 	body   string
+	header http.Header
+}
+
+// ServerFault describes a failure that a SimpleTestServer injects into its
+// responses for a path, so that clients can be tested against a slow or
+// broken MAAS controller. The zero value injects no failure.
+type ServerFault struct {
+	// Latency delays the response. The request is recorded straight away,
+	// and the delay ends early if the client gives up on the request.
+	Latency time.Duration
+
+	// Reset resets the connection instead of finishing the response. The
+	// status and headers are sent, with the length of the whole body, and
+	// the first ResetAfter bytes of the body are written, although the
+	// reset may overtake them. If ResetAfter is zero, the
+	// connection is closed before anything is sent, and if it isn't less
+	// than the length of the body, after the whole body is sent.
+	Reset      bool
+	ResetAfter int
+
+	// MalformedJSON cuts the body short, so that it can't be decoded.
+	MalformedJSON bool
 }
 
 type SimpleTestServer struct {
@@ -99,6 +127,8 @@ type SimpleTestServer struct {
 	deleteResponses     map[string][]simpleResponse
 	deleteResponseIndex map[string]int
 
+	faults map[string]ServerFault
+
 	requests []*http.Request
 }
 
@@ -112,6 +142,7 @@ func NewSimpleServer() *SimpleTestServer {
 		postResponseIndex:   make(map[string]int),
 		deleteResponses:     make(map[string][]simpleResponse),
 		deleteResponseIndex: make(map[string]int),
+		faults:              make(map[string]ServerFault),
 	}
 	server.Server = httptest.NewUnstartedServer(http.HandlerFunc(server.handler))
 	return server
@@ -122,6 +153,13 @@ func (s *SimpleTestServer) AddGetResponse(path string, status int, body string)
 	s.getResponses[path] = append(s.getResponses[path], simpleResponse{status: status, body: body})
 }
 
+// AddGetResponseWithHeader is like AddGetResponse, but the response is sent
+// with the header as well.
+func (s *SimpleTestServer) AddGetResponseWithHeader(path string, status int, body string, header http.Header) {
+	logger.Debugf("add get response for: %s, %d", path, status)
+	s.getResponses[path] = append(s.getResponses[path], simpleResponse{status: status, body: body, header: header})
+}
+
 func (s *SimpleTestServer) AddPutResponse(path string, status int, body string) {
 	logger.Debugf("add put response for: %s, %d", path, status)
 	s.putResponses[path] = append(s.putResponses[path], simpleResponse{status: status, body: body})
@@ -137,6 +175,18 @@ func (s *SimpleTestServer) AddDeleteResponse(path string, status int, body strin
 	s.deleteResponses[path] = append(s.deleteResponses[path], simpleResponse{status: status, body: body})
 }
 
+// AddFault injects the fault into the responses to all requests for the
+// path, whatever their method, until the fault is replaced or cleared.
+func (s *SimpleTestServer) AddFault(path string, fault ServerFault) {
+	logger.Debugf("add fault for: %s, %+v", path, fault)
+	s.faults[path] = fault
+}
+
+// ClearFaults removes the faults added with AddFault.
+func (s *SimpleTestServer) ClearFaults() {
+	s.faults = make(map[string]ServerFault)
+}
+
 func (s *SimpleTestServer) LastRequest() *http.Request {
 	pos := len(s.requests) - 1
 	if pos < 0 {
@@ -207,6 +257,18 @@ func (s *SimpleTestServer) handler(writer http.ResponseWriter, request *http.Req
 	}
 	s.requests = append(s.requests, request)
 	uri := request.URL.String()
+	fault := s.faults[uri]
+	if fault.Latency > 0 {
+		select {
+		case <-time.After(fault.Latency):
+		case <-request.Context().Done():
+			return
+		}
+	}
+	if fault.Reset && fault.ResetAfter == 0 {
+		resetConnection(writer)
+		return
+	}
 	testResponses, found := responses[uri]
 	if !found {
 		errorMsg := fmt.Sprintf("Error 404: page not found ('%v').", uri)
@@ -216,7 +278,38 @@ func (s *SimpleTestServer) handler(writer http.ResponseWriter, request *http.Req
 		response := testResponses[index]
 		responseIndex[uri] = index + 1
 
+		body := response.body
+		if fault.MalformedJSON {
+			body = body[:len(body)/2]
+		}
+		for key, values := range response.header {
+			writer.Header()[key] = values
+		}
+		if fault.Reset {
+			// Claim the whole body, so the client sees it cut short.
+			writer.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			writer.WriteHeader(response.status)
+			if fault.ResetAfter < len(body) {
+				body = body[:fault.ResetAfter]
+			}
+			fmt.Fprint(writer, body)
+			resetConnection(writer)
+			return
+		}
 		writer.WriteHeader(response.status)
-		fmt.Fprint(writer, response.body)
+		fmt.Fprint(writer, body)
+	}
+}
+
+// resetConnection flushes what has been written of the response and closes
+// the connection abruptly, so the client sees it reset by the peer.
+func resetConnection(writer http.ResponseWriter) {
+	conn, _, err := writer.(http.Hijacker).Hijack()
+	if err != nil {
+		panic(err)
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
 	}
+	conn.Close()
 }
@@ -0,0 +1,56 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"time"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+func (s *controllerSuite) TestServerFaultLatency(c *gc.C) {
+	controller := s.getController(c)
+	s.server.AddFault("/api/2.0/zones/", ServerFault{Latency: 50 * time.Millisecond})
+
+	start := time.Now()
+	_, err := controller.Zones()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(time.Since(start) >= 50*time.Millisecond, jc.IsTrue)
+}
+
+func (s *controllerSuite) TestServerFaultResetMidBody(c *gc.C) {
+	controller := s.getController(c)
+	s.server.AddFault("/api/2.0/zones/", ServerFault{Reset: true, ResetAfter: 10})
+
+	_, err := controller.Zones()
+	c.Assert(err, jc.Satisfies, IsUnexpectedError)
+}
+
+func (s *controllerSuite) TestServerFaultResetBeforeResponse(c *gc.C) {
+	controller := s.getController(c)
+	s.server.AddFault("/api/2.0/zones/", ServerFault{Reset: true})
+
+	_, err := controller.Zones()
+	c.Assert(err, jc.Satisfies, IsUnexpectedError)
+}
+
+func (s *controllerSuite) TestServerFaultMalformedJSON(c *gc.C) {
+	controller := s.getController(c)
+	s.server.AddFault("/api/2.0/zones/", ServerFault{MalformedJSON: true})
+
+	_, err := controller.Zones()
+	c.Assert(err, jc.Satisfies, IsUnexpectedError)
+	c.Assert(err, gc.ErrorMatches, ".*unexpected end of JSON input")
+}
+
+func (s *controllerSuite) TestServerFaultClear(c *gc.C) {
+	controller := s.getController(c)
+	s.server.AddFault("/api/2.0/zones/", ServerFault{MalformedJSON: true})
+	s.server.ClearFaults()
+
+	zones, err := controller.Zones()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(zones, gc.HasLen, 2)
+}
@@ -109,6 +109,15 @@ type TestServer struct {
 	nextVLAN        int
 	staticRoutes    map[uint]*TestStaticRoute
 	nextStaticRoute uint
+
+	// lifecycle is nil unless SetNodeLifecycle has been called.
+	lifecycle *NodeLifecycle
+	// nodeTransitions maps the system_id of each node in an operation to
+	// the status it moves to when the operation finishes.
+	nodeTransitions map[string]nodeTransition
+	// nodeFailures holds "<system_id> <operation>" for each operation that
+	// is to fail, see FailNodeOperation.
+	nodeFailures map[string]bool
 }
 
 type TestDevice struct {
@@ -257,6 +266,9 @@ func (server *TestServer) Clear() {
 	server.nextVLAN = 1
 	server.staticRoutes = make(map[uint]*TestStaticRoute)
 	server.nextStaticRoute = 1
+	server.lifecycle = nil
+	server.nodeTransitions = make(map[string]nodeTransition)
+	server.nodeFailures = make(map[string]bool)
 }
 
 // SetVersionJSON sets the JSON response (capabilities) returned from the
@@ -921,6 +933,7 @@ func nodesHandler(server *TestServer, w http.ResponseWriter, r *http.Request) {
 	values, err := url.ParseQuery(r.URL.RawQuery)
 	checkError(err)
 	op := values.Get("op")
+	server.advanceNodes()
 	nodeURLRE := getNodeURLRE(server.version)
 	nodeURLMatch := nodeURLRE.FindStringSubmatch(r.URL.Path)
 	nodesURL := getNodesEndpoint(server.version)
@@ -964,11 +977,18 @@ func nodeHandler(server *TestServer, w http.ResponseWriter, r *http.Request, sys
 		}
 	}
 	if r.Method == "POST" {
-		// The only operations supported are "start", "stop" and "release".
-		if operation == "start" || operation == "stop" || operation == "release" {
+		// The only operations supported are "start", "stop", "release",
+		// "deploy" and "commission".
+		switch operation {
+		case "start", "stop", "release", "deploy", "commission":
 			// Record operation on node.
 			server.addNodeOperation(systemId, operation, r)
 
+			if server.lifecycle != nil && operation != "stop" {
+				if !nodeLifecycleHandler(server, w, systemId, operation) {
+					return
+				}
+			}
 			if operation == "release" {
 				delete(server.OwnedNodes(), systemId)
 			}
@@ -1048,7 +1068,9 @@ func nodeDeploymentStatusHandler(server *TestServer, w http.ResponseWriter, r *h
 func findFreeNode(server *TestServer, filter url.Values) *MAASObject {
 	for systemID, node := range server.Nodes() {
 		_, present := server.OwnedNodes()[systemID]
-		if !present {
+		// With a lifecycle, only Ready nodes can be allocated.
+		available := !present && (server.lifecycle == nil || server.nodeStatus(systemID) == NodeStatusReady)
+		if available {
 			var agentName, nodeName, zoneName, tagName, mem, cpuCores, arch string
 			for k := range filter {
 				switch k {
@@ -1141,6 +1163,9 @@ func nodesAcquireHandler(server *TestServer, w http.ResponseWriter, r *http.Requ
 		systemId, err := node.GetField("system_id")
 		checkError(err)
 		server.OwnedNodes()[systemId] = true
+		if server.lifecycle != nil {
+			server.setNodeStatus(systemId, NodeStatusAllocated)
+		}
 		res, err := json.MarshalIndent(node, "", "  ")
 		checkError(err)
 		// Record operation.
@@ -1166,10 +1191,23 @@ func nodesReleaseHandler(server *TestServer, w http.ResponseWriter, r *http.Requ
 		fmt.Fprintf(w, "Unknown node(s): %s.", strings.Join(unknown, ", "))
 		return
 	}
-	var releasedNodes = []map[string]JSONObject{}
+	var owned []string
 	for _, systemId := range systemIds {
-		if _, ok := server.OwnedNodes()[systemId]; !ok {
-			continue
+		if _, ok := server.OwnedNodes()[systemId]; ok {
+			owned = append(owned, systemId)
+		}
+	}
+	if server.lifecycle != nil {
+		if unreleasable := unreleasableNodes(server, owned); unreleasable != "" {
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprintf(w, "Node(s) cannot be released in their current state: %s.", unreleasable)
+			return
+		}
+	}
+	var releasedNodes = []map[string]JSONObject{}
+	for _, systemId := range owned {
+		if server.lifecycle != nil {
+			server.startNodeOperation(systemId, "release")
 		}
 		delete(server.OwnedNodes(), systemId)
 		node := server.Nodes()[systemId]
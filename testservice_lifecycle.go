@@ -0,0 +1,186 @@
+// Copyright 2012-2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/juju/utils/clock"
+)
+
+// NodeLifecycle configures how a TestServer moves nodes through their
+// statuses, see TestServer.SetNodeLifecycle. Nodes are acquired when Ready,
+// and become Allocated. Deploying an Allocated node, with op=start or
+// op=deploy, makes it Deploying for DeployDelay and then Deployed. Releasing
+// a node makes it Releasing for ReleaseDelay and then Ready, and
+// commissioning one makes it Commissioning for CommissionDelay and then
+// Ready.
+type NodeLifecycle struct {
+	DeployDelay     time.Duration
+	ReleaseDelay    time.Duration
+	CommissionDelay time.Duration
+
+	// Clock is optional, and defaults to the wall clock. The transitions
+	// that are due are made when the nodes are next requested.
+	Clock clock.Clock
+}
+
+// lifecycleOperation describes the statuses of a node operation: those it
+// can start from, the status during it, and the statuses it ends in when
+// it succeeds or fails.
+type lifecycleOperation struct {
+	from   []string
+	during string
+	done   string
+	failed string
+}
+
+var lifecycleOperations = map[string]lifecycleOperation{
+	"deploy": {
+		from:   []string{NodeStatusAllocated},
+		during: NodeStatusDeploying,
+		done:   NodeStatusDeployed,
+		failed: NodeStatusFailedDeployment,
+	},
+	"release": {
+		from: []string{
+			NodeStatusAllocated,
+			NodeStatusDeploying,
+			NodeStatusDeployed,
+			NodeStatusFailedDeployment,
+		},
+		during: NodeStatusReleasing,
+		done:   NodeStatusReady,
+		failed: NodeStatusFailedReleasing,
+	},
+	"commission": {
+		from: []string{
+			NodeStatusDeclared,
+			NodeStatusReady,
+			NodeStatusFailedTests,
+			NodeStatusBroken,
+		},
+		during: NodeStatusCommissioning,
+		done:   NodeStatusReady,
+		failed: NodeStatusFailedTests,
+	},
+}
+
+// nodeTransition is the status that a node moves to when the operation it
+// is in finishes.
+type nodeTransition struct {
+	status string
+	due    time.Time
+}
+
+// SetNodeLifecycle makes the server move nodes through their statuses as
+// MAAS does, and refuse the operations that aren't allowed in the status a
+// node is in with a 409 Conflict. Without it, any node that isn't owned can
+// be acquired, and the operations are only recorded. Clear removes the
+// lifecycle.
+func (server *TestServer) SetNodeLifecycle(lifecycle NodeLifecycle) {
+	if lifecycle.Clock == nil {
+		lifecycle.Clock = clock.WallClock
+	}
+	server.lifecycle = &lifecycle
+}
+
+// FailNodeOperation makes the next "deploy", "release" or "commission"
+// operation of the node fail once it has run for its delay, leaving the
+// node Failed deployment, Failed releasing or Failed tests.
+func (server *TestServer) FailNodeOperation(systemId, operation string) {
+	if _, ok := lifecycleOperations[operation]; !ok {
+		panic(fmt.Sprintf("unknown node operation %q", operation))
+	}
+	server.nodeFailures[systemId+" "+operation] = true
+}
+
+func (server *TestServer) nodeStatus(systemId string) string {
+	status, err := server.nodes[systemId].GetField("status")
+	checkError(err)
+	return status
+}
+
+func (server *TestServer) setNodeStatus(systemId, status string) {
+	server.nodes[systemId].GetMap()["status"] = maasify(server.client, status)
+}
+
+// canStartNodeOperation returns whether the operation is allowed in the
+// status of the node.
+func (server *TestServer) canStartNodeOperation(systemId, operation string) bool {
+	return contains(lifecycleOperations[operation].from, server.nodeStatus(systemId))
+}
+
+// startNodeOperation moves the node to the status it is in during the
+// operation, and schedules its move to the status the operation ends in.
+func (server *TestServer) startNodeOperation(systemId, operation string) {
+	op := lifecycleOperations[operation]
+	var delay time.Duration
+	switch operation {
+	case "deploy":
+		delay = server.lifecycle.DeployDelay
+	case "release":
+		delay = server.lifecycle.ReleaseDelay
+	case "commission":
+		delay = server.lifecycle.CommissionDelay
+	}
+	status := op.done
+	if failure := systemId + " " + operation; server.nodeFailures[failure] {
+		delete(server.nodeFailures, failure)
+		status = op.failed
+	}
+	server.setNodeStatus(systemId, op.during)
+	server.nodeTransitions[systemId] = nodeTransition{
+		status: status,
+		due:    server.lifecycle.Clock.Now().Add(delay),
+	}
+}
+
+// advanceNodes makes the node transitions that are due.
+func (server *TestServer) advanceNodes() {
+	if server.lifecycle == nil {
+		return
+	}
+	now := server.lifecycle.Clock.Now()
+	for systemId, transition := range server.nodeTransitions {
+		if transition.due.After(now) {
+			continue
+		}
+		delete(server.nodeTransitions, systemId)
+		if _, ok := server.nodes[systemId]; ok {
+			server.setNodeStatus(systemId, transition.status)
+		}
+	}
+}
+
+// nodeLifecycleHandler starts the operation on the node, or responds with
+// a 409 Conflict if the node's status doesn't allow it. It returns whether
+// the operation was started.
+func nodeLifecycleHandler(server *TestServer, w http.ResponseWriter, systemId, operation string) bool {
+	if operation == "start" {
+		operation = "deploy"
+	}
+	if !server.canStartNodeOperation(systemId, operation) {
+		w.WriteHeader(http.StatusConflict)
+		fmt.Fprintf(w, "Node %s cannot %s in status %s.", systemId, operation, server.nodeStatus(systemId))
+		return false
+	}
+	server.startNodeOperation(systemId, operation)
+	return true
+}
+
+// unreleasableNodes returns those of the nodes whose status doesn't allow
+// them to be released.
+func unreleasableNodes(server *TestServer, systemIds []string) string {
+	var result []string
+	for _, systemId := range systemIds {
+		if !server.canStartNodeOperation(systemId, "release") {
+			result = append(result, systemId)
+		}
+	}
+	return strings.Join(result, ", ")
+}
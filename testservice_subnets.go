@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math/big"
 	"net"
 	"net/http"
 	"net/url"
@@ -67,8 +68,8 @@ type TestSubnet struct {
 // that subnet stores.
 func (server *TestServer) AddFixedAddressRange(subnetID uint, ar AddressRange) {
 	subnet := server.subnets[subnetID]
-	ar.startUint = IPFromString(ar.Start).UInt64()
-	ar.endUint = IPFromString(ar.End).UInt64()
+	ar.start = IPFromString(ar.Start).BigInt()
+	ar.end = IPFromString(ar.End).BigInt()
 	subnet.FixedAddressRanges = append(subnet.FixedAddressRanges, ar)
 	server.subnets[subnetID] = subnet
 }
@@ -154,14 +155,26 @@ type addressList []IP
 
 func (a addressList) Len() int           { return len(a) }
 func (a addressList) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a addressList) Less(i, j int) bool { return a[i].UInt64() < a[j].UInt64() }
+func (a addressList) Less(i, j int) bool { return a[i].BigInt().Cmp(a[j].BigInt()) < 0 }
+
+var bigOne = big.NewInt(1)
+
+// bigToUint converts v to a uint, limiting it to the largest uint, as the
+// address counts of IPv6 subnets can be too large for one.
+func bigToUint(v *big.Int) uint {
+	max := new(big.Int).SetUint64(uint64(^uint(0)))
+	if v.Cmp(max) > 0 {
+		return ^uint(0)
+	}
+	return uint(v.Uint64())
+}
 
 // AddressRange is used to generate reserved IP address range lists
 type AddressRange struct {
 	Start        string `json:"start"`
-	startUint    uint64
+	start        *big.Int
 	End          string `json:"end"`
-	endUint      uint64
+	end          *big.Int
 	Purpose      []string `json:"purpose,omitempty"`
 	NumAddresses uint     `json:"num_addresses"`
 }
@@ -175,16 +188,18 @@ type AddressRangeList struct {
 func (ranges *AddressRangeList) Append(startIP, endIP IP) {
 	var i AddressRange
 	i.Start, i.End = startIP.String(), endIP.String()
-	i.startUint, i.endUint = startIP.UInt64(), endIP.UInt64()
-	i.NumAddresses = uint(1 + endIP.UInt64() - startIP.UInt64())
+	i.start, i.end = startIP.BigInt(), endIP.BigInt()
+	count := new(big.Int).Sub(i.end, i.start)
+	i.NumAddresses = bigToUint(count.Add(count, bigOne))
 	i.Purpose = startIP.Purpose
 	ranges.ar = append(ranges.ar, i)
 }
 
 func appendRangesToIPList(subnet TestSubnet, ipAddresses *[]IP) {
 	for _, r := range subnet.FixedAddressRanges {
-		for v := r.startUint; v <= r.endUint; v++ {
-			ip := IPFromInt64(v)
+		ipv6 := IPFromString(r.Start).IsIPv6()
+		for v := new(big.Int).Set(r.start); v.Cmp(r.end) <= 0; v.Add(v, bigOne) {
+			ip := IPFromBigInt(v, ipv6)
 			ip.Purpose = r.Purpose
 			*ipAddresses = append(*ipAddresses, ip)
 		}
@@ -200,42 +215,42 @@ func (server *TestServer) subnetUnreservedIPRanges(subnet TestSubnet) []AddressR
 
 	// We need the first and last address in the subnet
 	var ranges AddressRangeList
-	var startIP, endIP, lastUsableIP IP
 
 	_, ipNet, err := net.ParseCIDR(subnet.CIDR)
 	checkError(err)
-	startIP = IPFromNetIP(ipNet.IP)
+	networkIP := IPFromNetIP(ipNet.IP)
+	ipv6 := networkIP.IsIPv6()
 	// Start with the lowest usable address in the range, which is 1 above
 	// what net.ParseCIDR will give back.
-	startIP.SetUInt64(startIP.UInt64() + 1)
-
-	ones, bits := ipNet.Mask.Size()
-	set := ^((^uint64(0)) << uint(bits-ones))
+	start := new(big.Int).Add(networkIP.BigInt(), bigOne)
 
 	// The last usable address is one below the broadcast address, which is
-	// what you get by bitwise ORing 'set' with any IP address in the subnet.
-	lastUsableIP.SetUInt64((startIP.UInt64() | set) - 1)
+	// the last address in the subnet.
+	ones, bits := ipNet.Mask.Size()
+	size := new(big.Int).Lsh(bigOne, uint(bits-ones))
+	lastUsable := new(big.Int).Add(networkIP.BigInt(), size)
+	lastUsable.Sub(lastUsable, big.NewInt(2))
 
-	for _, endIP = range ipAddresses {
-		end := endIP.UInt64()
+	for _, endIP := range ipAddresses {
+		end := endIP.BigInt()
 
-		if endIP.UInt64() == startIP.UInt64() {
-			if endIP.UInt64() != lastUsableIP.UInt64() {
-				startIP.SetUInt64(end + 1)
+		if end.Cmp(start) == 0 {
+			if end.Cmp(lastUsable) != 0 {
+				start = new(big.Int).Add(end, bigOne)
 			}
 			continue
 		}
 
-		if end == lastUsableIP.UInt64() {
+		if end.Cmp(lastUsable) == 0 {
 			continue
 		}
 
-		ranges.Append(startIP, IPFromInt64(end-1))
-		startIP.SetUInt64(end + 1)
+		ranges.Append(IPFromBigInt(start, ipv6), IPFromBigInt(new(big.Int).Sub(end, bigOne), ipv6))
+		start = new(big.Int).Add(end, bigOne)
 	}
 
-	if startIP.UInt64() != lastUsableIP.UInt64() {
-		ranges.Append(startIP, lastUsableIP)
+	if start.Cmp(lastUsable) != 0 {
+		ranges.Append(IPFromBigInt(start, ipv6), IPFromBigInt(lastUsable, ipv6))
 	}
 
 	return ranges.ar
@@ -267,14 +282,15 @@ func (server *TestServer) subnetReservedIPRanges(subnet TestSubnet) []AddressRan
 				purposeMissmatch = true
 			}
 		}
-		if (thisIP.UInt64() != lastIP.UInt64() && thisIP.UInt64() != lastIP.UInt64()+1) || purposeMissmatch {
+		this, last := thisIP.BigInt(), lastIP.BigInt()
+		if (this.Cmp(last) != 0 && this.Cmp(new(big.Int).Add(last, bigOne)) != 0) || purposeMissmatch {
 			ranges.Append(startIP, lastIP)
 			startIP = thisIP
 		}
 		lastIP = thisIP
 	}
 
-	if len(ranges.ar) == 0 || ranges.ar[len(ranges.ar)-1].endUint != lastIP.UInt64() {
+	if len(ranges.ar) == 0 || ranges.ar[len(ranges.ar)-1].end.Cmp(lastIP.BigInt()) != 0 {
 		ranges.Append(startIP, lastIP)
 	}
 
@@ -298,7 +314,8 @@ func (server *TestServer) subnetStatistics(subnet TestSubnet, includeRanges bool
 	checkError(err)
 
 	ones, bits := ipNet.Mask.Size()
-	stats.TotalAddresses = (1 << uint(bits-ones)) - 2
+	total := new(big.Int).Lsh(bigOne, uint(bits-ones))
+	stats.TotalAddresses = bigToUint(total.Sub(total, big.NewInt(2)))
 	stats.NumUnavailable = uint(len(subnet.InUseIPAddresses))
 	stats.NumAvailable = stats.TotalAddresses - stats.NumUnavailable
 	stats.Usage = float32(stats.NumUnavailable) / float32(stats.TotalAddresses)
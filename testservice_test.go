@@ -9,6 +9,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math/big"
 	"math/rand"
 	"mime/multipart"
 	"net"
@@ -17,6 +18,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	jc "github.com/juju/testing/checkers"
 	. "gopkg.in/check.v1"
@@ -749,6 +751,17 @@ func extraSubnet() CreateSubnet {
 	return s
 }
 
+func ipv6Subnet(cidr string) CreateSubnet {
+	var s CreateSubnet
+	s.DNSServers = []string{"2001:db8::2"}
+	s.Name = "maas-eth0-v6"
+	s.Space = "space-0"
+	s.GatewayIP = "2001:db8::1"
+	s.CIDR = cidr
+	s.ID = 1
+	return s
+}
+
 func newSubnetOnSpace(space string, id uint) CreateSubnet {
 	var s CreateSubnet
 	s.DNSServers = []string{fmt.Sprintf("192.168.%v.2", id)}
@@ -1058,6 +1071,103 @@ func (suite *TestServerSuite) TestSubnetStats(c *C) {
 	c.Check(stats, DeepEquals, expected)
 }
 
+func (suite *TestServerSuite) getAddressRanges(c *C, op string) []AddressRange {
+	resp, err := http.Get(suite.subnetURL(1) + "?op=" + op)
+	c.Assert(err, IsNil)
+
+	var ranges []AddressRange
+	err = json.NewDecoder(resp.Body).Decode(&ranges)
+	c.Assert(err, IsNil)
+	return ranges
+}
+
+func (suite *TestServerSuite) reserveIPv6Addresses() {
+	suite.server.NewSubnet(subnetJSON(ipv6Subnet("2001:db8::/120")))
+	suite.server.NewIPAddress("2001:db8::10", "maas-eth0-v6")
+	suite.server.NewIPAddress("2001:db8::11", "maas-eth0-v6")
+	suite.server.NewIPAddress("2001:db8::20", "maas-eth0-v6")
+}
+
+func (suite *TestServerSuite) TestSubnetReservedIPRangesIPv6(c *C) {
+	suite.reserveIPv6Addresses()
+
+	ranges := suite.getAddressRanges(c, "reserved_ip_ranges")
+	c.Check(ranges, DeepEquals, []AddressRange{{
+		Start:        "2001:db8::10",
+		End:          "2001:db8::11",
+		Purpose:      []string{"assigned-ip"},
+		NumAddresses: 2,
+	}, {
+		Start:        "2001:db8::20",
+		End:          "2001:db8::20",
+		Purpose:      []string{"assigned-ip"},
+		NumAddresses: 1,
+	}})
+}
+
+func (suite *TestServerSuite) TestSubnetUnreservedIPRangesIPv6(c *C) {
+	suite.reserveIPv6Addresses()
+
+	ranges := suite.getAddressRanges(c, "unreserved_ip_ranges")
+	c.Check(ranges, DeepEquals, []AddressRange{{
+		Start:        "2001:db8::1",
+		End:          "2001:db8::f",
+		NumAddresses: 15,
+	}, {
+		Start:        "2001:db8::12",
+		End:          "2001:db8::1f",
+		NumAddresses: 14,
+	}, {
+		Start:        "2001:db8::21",
+		End:          "2001:db8::fe",
+		NumAddresses: 222,
+	}})
+}
+
+func (suite *TestServerSuite) TestSubnetReserveRangeIPv6(c *C) {
+	suite.server.NewSubnet(subnetJSON(ipv6Subnet("2001:db8::/64")))
+	var ar AddressRange
+	ar.Start = "2001:db8::ffff:ffff:ffff:fff0"
+	ar.End = "2001:db8::ffff:ffff:ffff:ffff"
+	ar.Purpose = []string{"dynamic"}
+	suite.server.AddFixedAddressRange(1, ar)
+
+	ranges := suite.getAddressRanges(c, "reserved_ip_ranges")
+	c.Check(ranges, DeepEquals, []AddressRange{{
+		Start:        "2001:db8::ffff:ffff:ffff:fff0",
+		End:          "2001:db8::ffff:ffff:ffff:ffff",
+		Purpose:      []string{"dynamic"},
+		NumAddresses: 16,
+	}})
+}
+
+func (suite *TestServerSuite) TestSubnetStatsIPv6(c *C) {
+	suite.reserveIPv6Addresses()
+
+	stats := suite.getSubnetStats(c, 1)
+	c.Check(stats, DeepEquals, SubnetStats{
+		NumAvailable:     251,
+		LargestAvailable: 222,
+		NumUnavailable:   3,
+		TotalAddresses:   254,
+		Usage:            float32(3) / float32(254),
+		UsageString:      "1.2%",
+	})
+}
+
+func (suite *TestServerSuite) TestSubnetStatsLargeIPv6(c *C) {
+	suite.server.NewSubnet(subnetJSON(ipv6Subnet("2001:db8::/64")))
+
+	stats := suite.getSubnetStats(c, 1)
+	c.Check(stats.TotalAddresses, Equals, uint(1<<64-2))
+	c.Check(stats.LargestAvailable, Equals, uint(1<<64-2))
+
+	ranges := suite.server.subnetUnreservedIPRanges(suite.server.subnets[1])
+	c.Assert(ranges, HasLen, 1)
+	c.Check(ranges[0].Start, Equals, "2001:db8::1")
+	c.Check(ranges[0].End, Equals, "2001:db8::ffff:ffff:ffff:fffe")
+}
+
 func (suite *TestServerSuite) TestSubnetsInNodes(c *C) {
 	// Create a subnet
 	subnet := suite.server.NewSubnet(subnetJSON(defaultSubnet()))
@@ -1155,6 +1265,20 @@ func (suite *IPSuite) TestIPSetUInt64(c *C) {
 	c.Check(ip.String(), Equals, "1.2.3.4")
 }
 
+func (suite *IPSuite) TestIPBigInt(c *C) {
+	ip := IPFromString("1.2.3.4")
+	c.Check(ip.BigInt().Int64(), Equals, int64(0x01020304))
+	c.Check(IPFromBigInt(ip.BigInt(), false).String(), Equals, "1.2.3.4")
+}
+
+func (suite *IPSuite) TestIPBigIntIPv6(c *C) {
+	ip := IPFromString("2001:db8::ffff:ffff:ffff:ffff")
+	c.Check(ip.IsIPv6(), Equals, true)
+	v := ip.BigInt()
+	v.Add(v, big.NewInt(1))
+	c.Check(IPFromBigInt(v, true).String(), Equals, "2001:db8:0:1::")
+}
+
 // TestMAASObjectSuite validates that the object created by
 // NewTestMAAS can be used by the gomaasapi library as if it were a real
 // MAAS server.
@@ -2062,3 +2186,110 @@ func (suite *TestMAASObjectSuite) TestDeploymentStatus(c *C) {
 		c.Assert(nodeStatus, Equals, status)
 	}
 }
+
+// checkNodeStatus checks the status of the node as it is read from the
+// server.
+func checkNodeStatus(c *C, node MAASObject, status string) {
+	current, err := node.Get()
+	c.Assert(err, IsNil)
+	field, err := current.GetField("status")
+	c.Assert(err, IsNil)
+	c.Check(field, Equals, status)
+}
+
+func (suite *TestMAASObjectSuite) TestNodeLifecycle(c *C) {
+	server := suite.TestMAASObject.TestServer
+	clock := &fakeClock{now: time.Now()}
+	server.SetNodeLifecycle(NodeLifecycle{DeployDelay: time.Minute, Clock: clock})
+	node := server.NewNode(fmt.Sprintf(`{"system_id": "n0", "status": %q}`, NodeStatusReady))
+	nodes := suite.TestMAASObject.GetSubObject("nodes/")
+
+	_, err := nodes.CallPost("acquire", nil)
+	c.Assert(err, IsNil)
+	checkNodeStatus(c, node, NodeStatusAllocated)
+
+	_, err = node.CallPost("start", nil)
+	c.Assert(err, IsNil)
+	checkNodeStatus(c, node, NodeStatusDeploying)
+	clock.now = clock.now.Add(time.Minute)
+	checkNodeStatus(c, node, NodeStatusDeployed)
+
+	_, err = node.CallPost("release", nil)
+	c.Assert(err, IsNil)
+	checkNodeStatus(c, node, NodeStatusReady)
+	c.Check(server.OwnedNodes()["n0"], Equals, false)
+
+	_, err = node.CallPost("commission", nil)
+	c.Assert(err, IsNil)
+	checkNodeStatus(c, node, NodeStatusReady)
+	c.Check(server.NodeOperations()["n0"], DeepEquals, []string{"acquire", "start", "release", "commission"})
+}
+
+func (suite *TestMAASObjectSuite) TestNodeLifecycleRefusesOperations(c *C) {
+	server := suite.TestMAASObject.TestServer
+	server.SetNodeLifecycle(NodeLifecycle{})
+	node := server.NewNode(fmt.Sprintf(`{"system_id": "n0", "status": %q}`, NodeStatusReady))
+	server.NewNode(`{"system_id": "n1"}`)
+	nodes := suite.TestMAASObject.GetSubObject("nodes/")
+
+	// Deployed nodes can't be acquired.
+	_, err := nodes.CallPost("acquire", url.Values{"name": []string{"n1"}})
+	svrError, ok := GetServerError(err)
+	c.Assert(ok, jc.IsTrue)
+	c.Check(svrError.StatusCode, Equals, http.StatusConflict)
+
+	// Nodes must be allocated to be deployed or released.
+	_, err = node.CallPost("deploy", nil)
+	c.Assert(err, ErrorMatches, `.* 409 Conflict \(Node n0 cannot deploy in status 4.\)`)
+	_, err = node.CallPost("release", nil)
+	c.Assert(err, ErrorMatches, `.* 409 Conflict \(Node n0 cannot release in status 4.\)`)
+	checkNodeStatus(c, node, NodeStatusReady)
+}
+
+func (suite *TestMAASObjectSuite) TestNodeLifecycleFailure(c *C) {
+	server := suite.TestMAASObject.TestServer
+	clock := &fakeClock{now: time.Now()}
+	server.SetNodeLifecycle(NodeLifecycle{ReleaseDelay: time.Second, CommissionDelay: time.Minute, Clock: clock})
+	node := server.NewNode(fmt.Sprintf(`{"system_id": "n0", "status": %q}`, NodeStatusAllocated))
+	server.OwnedNodes()["n0"] = true
+	server.FailNodeOperation("n0", "deploy")
+	server.FailNodeOperation("n0", "commission")
+
+	_, err := node.CallPost("deploy", nil)
+	c.Assert(err, IsNil)
+	checkNodeStatus(c, node, NodeStatusFailedDeployment)
+
+	// Failed deployments can be released, and the failure only happens
+	// once.
+	_, err = node.CallPost("release", nil)
+	c.Assert(err, IsNil)
+	checkNodeStatus(c, node, NodeStatusReleasing)
+	clock.now = clock.now.Add(time.Second)
+	checkNodeStatus(c, node, NodeStatusReady)
+
+	_, err = node.CallPost("commission", nil)
+	c.Assert(err, IsNil)
+	checkNodeStatus(c, node, NodeStatusCommissioning)
+	clock.now = clock.now.Add(time.Minute)
+	checkNodeStatus(c, node, NodeStatusFailedTests)
+}
+
+func (suite *TestMAASObjectSuite) TestNodeLifecycleNodesRelease(c *C) {
+	server := suite.TestMAASObject.TestServer
+	server.SetNodeLifecycle(NodeLifecycle{})
+	node := server.NewNode(fmt.Sprintf(`{"system_id": "n0", "status": %q}`, NodeStatusDeployed))
+	server.NewNode(fmt.Sprintf(`{"system_id": "n1", "status": %q}`, NodeStatusReleasing))
+	server.OwnedNodes()["n0"] = true
+	server.OwnedNodes()["n1"] = true
+	nodes := suite.TestMAASObject.GetSubObject("nodes/")
+
+	// If any nodes can't be released, none are.
+	_, err := nodes.CallPost("release", url.Values{"nodes": []string{"n0", "n1"}})
+	c.Assert(err, ErrorMatches, `.* 409 Conflict \(Node\(s\) cannot be released in their current state: n1.\)`)
+	c.Check(server.OwnedNodes()["n0"], Equals, true)
+
+	_, err = nodes.CallPost("release", url.Values{"nodes": []string{"n0"}})
+	c.Assert(err, IsNil)
+	c.Check(server.OwnedNodes()["n0"], Equals, false)
+	checkNodeStatus(c, node, NodeStatusReady)
+}
@@ -8,6 +8,7 @@ import (
 	"encoding/binary"
 	"encoding/json"
 	"errors"
+	"math/big"
 	"net"
 	"net/http"
 	"strconv"
@@ -57,6 +58,23 @@ func IPFromInt64(v uint64) IP {
 	return ip
 }
 
+// IPFromBigInt creates a new IP from a big.Int IP address representation,
+// as an IPv6 address if ipv6 is true and an IPv4 address otherwise.
+func IPFromBigInt(v *big.Int, ipv6 bool) IP {
+	size := net.IPv4len
+	if ipv6 {
+		size = net.IPv6len
+	}
+	netIP := make(net.IP, size)
+	value := v.Bytes()
+	if len(value) > size {
+		// Keep the low order bytes, as SetUInt64 does.
+		value = value[len(value)-size:]
+	}
+	copy(netIP[size-len(value):], value)
+	return IPFromNetIP(netIP)
+}
+
 // To4 converts the IPv4 address ip to a 4-byte representation. If ip is not
 // an IPv4 address, To4 returns nil.
 func (ip IP) To4() net.IP {
@@ -86,6 +104,25 @@ func (ip IP) UInt64() uint64 {
 	return binary.BigEndian.Uint64([]byte(ip.To16()))
 }
 
+// BigInt returns a big.Int holding the IP address. Unlike UInt64 it holds
+// all of an IPv6 address.
+func (ip IP) BigInt() *big.Int {
+	if len(ip.netIP) == 0 {
+		return new(big.Int)
+	}
+
+	if ip.To4() != nil {
+		return new(big.Int).SetBytes(ip.To4())
+	}
+
+	return new(big.Int).SetBytes(ip.To16())
+}
+
+// IsIPv6 returns true if the IP is an IPv6 address.
+func (ip IP) IsIPv6() bool {
+	return len(ip.netIP) != 0 && ip.To4() == nil
+}
+
 // SetUInt64 sets the IP value to v
 func (ip *IP) SetUInt64(v uint64) {
 	if len(ip.netIP) == 0 {
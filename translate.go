@@ -0,0 +1,95 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/juju/errors"
+)
+
+// errorOp is the kind of request that an error response is for, which
+// decides how translateError maps the status code.
+type errorOp int
+
+const (
+	// opEntity requests read or change the entity they are addressed to,
+	// so a 404 means that entity doesn't exist.
+	opEntity errorOp = iota
+	// opReference requests name other entities in their parameters, such
+	// as a VLAN or a distro series, or need the entity to be in a given
+	// state. A 404 or 409 means the parameters are wrong.
+	opReference
+	// opAllocate requests ask MAAS to pick an entity that matches the
+	// constraints, so a 409 means none does.
+	opAllocate
+)
+
+// statusErrors is the table that translateError uses:
+//
+//	status    opEntity        opReference     opAllocate
+//	400       BadRequest      BadRequest      BadRequest
+//	401, 403  Permission      Permission      Permission
+//	404       NoMatch         BadRequest      NoMatch
+//	409       CannotComplete  BadRequest      NoMatch
+//	503       CannotComplete  CannotComplete  CannotComplete
+//
+// Any other status is an UnexpectedError.
+var statusErrors = map[errorOp]map[int]func(message string) error{
+	opEntity: {
+		http.StatusBadRequest:         NewBadRequestError,
+		http.StatusUnauthorized:       NewPermissionError,
+		http.StatusForbidden:          NewPermissionError,
+		http.StatusNotFound:           NewNoMatchError,
+		http.StatusConflict:           NewCannotCompleteError,
+		http.StatusServiceUnavailable: NewCannotCompleteError,
+	},
+	opReference: {
+		http.StatusBadRequest:         NewBadRequestError,
+		http.StatusUnauthorized:       NewPermissionError,
+		http.StatusForbidden:          NewPermissionError,
+		http.StatusNotFound:           NewBadRequestError,
+		http.StatusConflict:           NewBadRequestError,
+		http.StatusServiceUnavailable: NewCannotCompleteError,
+	},
+	opAllocate: {
+		http.StatusBadRequest:         NewBadRequestError,
+		http.StatusUnauthorized:       NewPermissionError,
+		http.StatusForbidden:          NewPermissionError,
+		http.StatusNotFound:           NewNoMatchError,
+		http.StatusConflict:           NewNoMatchError,
+		http.StatusServiceUnavailable: NewCannotCompleteError,
+	},
+}
+
+// translateError returns the error for a failed request of the op kind.
+// ServerErrors are wrapped with the error from the statusErrors table, so
+// that the message is the body of the response. PlannedErrors, and the
+// errors of requests abandoned when their context was done, are returned
+// unchanged. Any other error is an UnexpectedError.
+func translateError(op errorOp, err error) error {
+	switch cause := errors.Cause(err).(type) {
+	case ServerError:
+		if newError, ok := statusErrors[op][cause.StatusCode]; ok {
+			return errors.Wrap(err, newError(cause.BodyMessage))
+		}
+	case *PlannedError:
+		return err
+	case *url.Error:
+		if isContextError(cause.Err) {
+			return err
+		}
+	default:
+		if isContextError(cause) {
+			return err
+		}
+	}
+	return NewUnexpectedError(err)
+}
+
+func isContextError(err error) bool {
+	return err == context.Canceled || err == context.DeadlineExceeded
+}
@@ -0,0 +1,191 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"reflect"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+var translatedStatuses = []int{
+	http.StatusBadRequest,
+	http.StatusUnauthorized,
+	http.StatusForbidden,
+	http.StatusNotFound,
+	http.StatusMethodNotAllowed,
+	http.StatusConflict,
+	http.StatusInternalServerError,
+	http.StatusServiceUnavailable,
+}
+
+func (*errorTypesSuite) TestTranslateError(c *gc.C) {
+	for i, test := range []struct {
+		op        errorOp
+		status    int
+		satisfies func(error) bool
+	}{
+		{opEntity, http.StatusBadRequest, IsBadRequestError},
+		{opEntity, http.StatusUnauthorized, IsPermissionError},
+		{opEntity, http.StatusForbidden, IsPermissionError},
+		{opEntity, http.StatusNotFound, IsNoMatchError},
+		{opEntity, http.StatusConflict, IsCannotCompleteError},
+		{opEntity, http.StatusServiceUnavailable, IsCannotCompleteError},
+		{opEntity, http.StatusInternalServerError, IsUnexpectedError},
+		{opReference, http.StatusNotFound, IsBadRequestError},
+		{opReference, http.StatusConflict, IsBadRequestError},
+		{opAllocate, http.StatusNotFound, IsNoMatchError},
+		{opAllocate, http.StatusConflict, IsNoMatchError},
+	} {
+		c.Logf("test %d: %d", i, test.status)
+		svrErr := ServerError{error: errors.New("no"), StatusCode: test.status, BodyMessage: "body"}
+		err := translateError(test.op, errors.Trace(svrErr))
+		c.Check(err, jc.Satisfies, test.satisfies)
+		if !IsUnexpectedError(err) {
+			c.Check(err.Error(), gc.Equals, "body")
+		}
+	}
+}
+
+func (*errorTypesSuite) TestTranslateErrorNotServerError(c *gc.C) {
+	err := translateError(opEntity, errors.New("connection refused"))
+	c.Assert(err, jc.Satisfies, IsUnexpectedError)
+	c.Assert(err.Error(), gc.Equals, "unexpected: connection refused")
+}
+
+func (*errorTypesSuite) TestTranslateErrorPlanned(c *gc.C) {
+	planned := errors.Trace(NewPlannedError("POST machines/ planned, not sent"))
+	err := translateError(opEntity, planned)
+	c.Assert(err, gc.Equals, planned)
+	c.Assert(err, jc.Satisfies, IsPlannedError)
+}
+
+func (*errorTypesSuite) TestTranslateErrorContextDone(c *gc.C) {
+	canceled := errors.Trace(context.Canceled)
+	c.Assert(translateError(opEntity, canceled), gc.Equals, canceled)
+	abandoned := newRequestError("1", &url.Error{Op: "Get", URL: "http://maas/", Err: context.DeadlineExceeded})
+	c.Assert(translateError(opEntity, abandoned), gc.Equals, abandoned)
+}
+
+// TestTranslatedConsistently checks that each method returns the error from
+// the statusErrors table for the kind of request it makes.
+func (s *machineSuite) TestTranslatedConsistently(c *gc.C) {
+	server, machine := s.getServerAndMachine(c)
+	controller := machine.controller
+	for _, test := range []struct {
+		about string
+		op    errorOp
+		add   func(status int)
+		call  func() error
+	}{{
+		about: "AllocateMachine",
+		op:    opAllocate,
+		add: func(status int) {
+			server.AddPostResponse("/api/2.0/machines/?op=allocate", status, "no")
+		},
+		call: func() error {
+			_, _, err := controller.AllocateMachine(AllocateMachineArgs{})
+			return err
+		},
+	}, {
+		about: "Machines",
+		op:    opEntity,
+		add: func(status int) {
+			server.AddGetResponse("/api/2.0/machines/", status, "no")
+		},
+		call: func() error {
+			_, err := controller.Machines(MachinesArgs{})
+			return err
+		},
+	}, {
+		about: "Zones",
+		op:    opEntity,
+		add: func(status int) {
+			server.AddGetResponse("/api/2.0/zones/", status, "no")
+		},
+		call: func() error {
+			_, err := controller.Zones()
+			return err
+		},
+	}, {
+		about: "Nodes",
+		op:    opEntity,
+		add: func(status int) {
+			server.AddGetResponse("/api/2.0/nodes/", status, "no")
+		},
+		call: func() error {
+			_, err := controller.Nodes(NodesArgs{})
+			return err
+		},
+	}, {
+		about: "ReleaseMachines",
+		op:    opEntity,
+		add: func(status int) {
+			server.AddPostResponse("/api/2.0/machines/?op=release", status, "no")
+		},
+		call: func() error {
+			return controller.ReleaseMachines(ReleaseMachinesArgs{SystemIDs: []string{"4y3ha3"}})
+		},
+	}, {
+		about: "GetFile",
+		op:    opEntity,
+		add: func(status int) {
+			server.AddGetResponse("/api/2.0/files/testing/", status, "no")
+		},
+		call: func() error {
+			_, err := controller.GetFile("testing")
+			return err
+		},
+	}, {
+		about: "Machine.Start",
+		op:    opReference,
+		add: func(status int) {
+			server.AddPostResponse(machine.resourceURI+"?op=deploy", status, "no")
+		},
+		call: func() error {
+			return machine.Start(StartArgs{})
+		},
+	}, {
+		about: "Machine.SetStorageLayout",
+		op:    opEntity,
+		add: func(status int) {
+			server.AddPostResponse(machine.resourceURI+"?op=set_storage_layout", status, "no")
+		},
+		call: func() error {
+			return machine.SetStorageLayout(StorageLayoutLVM, nil)
+		},
+	}, {
+		about: "Machine.CurtinConfig",
+		op:    opEntity,
+		add: func(status int) {
+			server.AddGetResponse(machine.resourceURI+"?op=get_curtin_config", status, "no")
+		},
+		call: func() error {
+			_, err := machine.CurtinConfig()
+			return err
+		},
+	}, {
+		about: "Machine.Delete",
+		op:    opEntity,
+		add: func(status int) {
+			server.AddDeleteResponse(machine.resourceURI, status, "no")
+		},
+		call: func() error {
+			return machine.Delete()
+		},
+	}} {
+		for _, status := range translatedStatuses {
+			c.Logf("%s: %d", test.about, status)
+			test.add(status)
+			err := test.call()
+			expected := translateError(test.op, ServerError{error: errors.New("no"), StatusCode: status})
+			c.Check(reflect.TypeOf(errors.Cause(err)), gc.Equals, reflect.TypeOf(errors.Cause(expected)))
+		}
+	}
+}
@@ -4,6 +4,8 @@
 package gomaasapi
 
 import (
+	"bytes"
+	"encoding/json"
 	"strings"
 )
 
@@ -25,3 +27,22 @@ func EnsureTrailingSlash(URL string) string {
 	}
 	return URL + "/"
 }
+
+// decodeJSON unmarshals JSON as json.Unmarshal does into an interface{},
+// except that numbers are decoded as json.Number rather than float64, so
+// that IDs and sizes above 2^53 keep their precision. The schema checkers
+// used to read entities, such as schema.ForceUint, accept json.Number.
+func decodeJSON(data []byte) (interface{}, error) {
+	// Check the data first, so that the errors are those of json.Unmarshal.
+	var raw json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var parsed interface{}
+	if err := decoder.Decode(&parsed); err != nil {
+		return nil, err
+	}
+	return parsed, nil
+}
@@ -34,6 +34,26 @@ func (suite *GomaasapiTestSuite) TestEnsureTrailingSlashReturnsSlashIfEmpty(c *g
 	c.Check(EnsureTrailingSlash(""), gc.Equals, "/")
 }
 
+func (suite *GomaasapiTestSuite) TestDecodeJSONKeepsNumbers(c *gc.C) {
+	parsed, err := decodeJSON([]byte(`{"size": 18014398509481985, "ratio": 0.5, "ids": [1, 2]}`))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(parsed, jc.DeepEquals, map[string]interface{}{
+		"size":  json.Number("18014398509481985"),
+		"ratio": json.Number("0.5"),
+		"ids":   []interface{}{json.Number("1"), json.Number("2")},
+	})
+}
+
+func (suite *GomaasapiTestSuite) TestDecodeJSONErrors(c *gc.C) {
+	for _, data := range []string{"", "{", `{"a": 1} {}`} {
+		var expected interface{}
+		expectedErr := json.Unmarshal([]byte(data), &expected)
+		c.Assert(expectedErr, gc.NotNil)
+		_, err := decodeJSON([]byte(data))
+		c.Check(err, jc.DeepEquals, expectedErr)
+	}
+}
+
 func parseJSON(c *gc.C, source string) interface{} {
 	var parsed interface{}
 	err := json.Unmarshal([]byte(source), &parsed)
@@ -0,0 +1,309 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"net/url"
+	"sort"
+	"sync"
+
+	"github.com/juju/errors"
+	"github.com/juju/schema"
+)
+
+// IPUtilizationArgs is an argument struct for passing parameters to the
+// Controller.IPUtilization method.
+type IPUtilizationArgs struct {
+	// Workers is the maximum number of requests made to the controller at
+	// the same time. If zero, DefaultBulkWorkers is used.
+	Workers int
+}
+
+// SubnetAddressRange is a range of addresses of a subnet, with the purposes
+// MAAS reports for them, such as "dynamic" or "assigned-ip".
+type SubnetAddressRange struct {
+	Start        string
+	End          string
+	NumAddresses uint64
+	Purposes     []string
+}
+
+// SubnetUtilization is the address usage of a subnet, as reported by MAAS
+// in the statistics of the subnet. The counts of the largest IPv6 subnets
+// don't fit, and are given as math.MaxUint64.
+type SubnetUtilization struct {
+	Subnet Subnet
+
+	TotalAddresses       uint64
+	AvailableAddresses   uint64
+	UnavailableAddresses uint64
+	// LargestFreeBlock is the number of addresses in the largest range of
+	// available addresses.
+	LargestFreeBlock uint64
+
+	// Conflicts are the ranges where a dynamic range holds addresses that
+	// are assigned by hand, or are the gateway or a DNS server.
+	Conflicts []SubnetAddressRange
+}
+
+// UsagePercent returns the percentage of the addresses of the subnet that
+// are unavailable.
+func (u SubnetUtilization) UsagePercent() float64 {
+	return usagePercent(u.UnavailableAddresses, u.TotalAddresses)
+}
+
+// IPUtilization is the address usage of the subnets of a MAAS controller,
+// as returned by Controller.IPUtilization. The subnets are ordered by ID,
+// and the totals are the sums over the subnets.
+type IPUtilization struct {
+	Subnets []SubnetUtilization
+
+	TotalAddresses       uint64
+	AvailableAddresses   uint64
+	UnavailableAddresses uint64
+}
+
+// UsagePercent returns the percentage of the addresses of all the subnets
+// that are unavailable.
+func (u IPUtilization) UsagePercent() float64 {
+	return usagePercent(u.UnavailableAddresses, u.TotalAddresses)
+}
+
+// SubnetsAbove returns the subnets with more than the percentage of their
+// addresses unavailable, or with conflicts.
+func (u IPUtilization) SubnetsAbove(percent float64) []SubnetUtilization {
+	var result []SubnetUtilization
+	for _, s := range u.Subnets {
+		if s.UsagePercent() > percent || len(s.Conflicts) > 0 {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+func usagePercent(unavailable, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(unavailable) / float64(total) * 100
+}
+
+// addCount adds the counts, saturating at math.MaxUint64.
+func addCount(a, b uint64) uint64 {
+	if a > math.MaxUint64-b {
+		return math.MaxUint64
+	}
+	return a + b
+}
+
+// IPUtilization implements Controller.
+func (c *controller) IPUtilization(ctx context.Context, args IPUtilizationArgs) (IPUtilization, error) {
+	workers := args.Workers
+	if workers <= 0 {
+		workers = DefaultBulkWorkers
+	}
+	subnets, err := c.cachedSubnets(true)
+	if err != nil {
+		return IPUtilization{}, errors.Trace(err)
+	}
+	sorted := make([]*subnet, len(subnets))
+	copy(sorted, subnets)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].ID() < sorted[j].ID()
+	})
+
+	var wg sync.WaitGroup
+	results := make([]*SubnetUtilization, len(sorted))
+	failures := make([]error, len(sorted))
+	slots := make(chan struct{}, workers)
+	for i, s := range sorted {
+		if ctx.Err() == nil {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int, s *subnet) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			results[i], failures[i] = c.subnetUtilization(s)
+		}(i, s)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return IPUtilization{}, errors.Trace(err)
+	}
+	// Report the first failure in the order of the subnets, so the error
+	// doesn't depend on which request finished first.
+	for _, err := range failures {
+		if err != nil {
+			return IPUtilization{}, err
+		}
+	}
+	var report IPUtilization
+	for _, u := range results {
+		if u == nil {
+			continue
+		}
+		report.Subnets = append(report.Subnets, *u)
+		report.TotalAddresses = addCount(report.TotalAddresses, u.TotalAddresses)
+		report.AvailableAddresses = addCount(report.AvailableAddresses, u.AvailableAddresses)
+		report.UnavailableAddresses = addCount(report.UnavailableAddresses, u.UnavailableAddresses)
+	}
+	return report, nil
+}
+
+// subnetUtilization reads the statistics of the subnet. It returns nil if
+// the subnet was deleted since the subnets were listed.
+func (c *controller) subnetUtilization(s *subnet) (*SubnetUtilization, error) {
+	params := url.Values{"include_ranges": {"true"}}
+	source, err := c._get(fmt.Sprintf("subnets/%d", s.ID()), "statistics", params)
+	if err != nil {
+		err = translateError(opEntity, err)
+		if IsNoMatchError(err) {
+			return nil, nil
+		}
+		return nil, errors.Annotatef(err, "reading statistics of subnet %d", s.ID())
+	}
+	result, err := readSubnetUtilization(source)
+	if err != nil {
+		return nil, errors.Annotatef(err, "reading statistics of subnet %d", s.ID())
+	}
+	if err = c.checkDecoded("subnet statistics", source, result); err != nil {
+		return nil, errors.Trace(err)
+	}
+	result.Subnet = s
+	return result, nil
+}
+
+var subnetUtilizationChecker = func() schema.Checker {
+	rangeFields := schema.Fields{
+		"start":         schema.String(),
+		"end":           schema.String(),
+		"num_addresses": schema.Any(),
+		"purpose":       schema.List(schema.String()),
+	}
+	rangeDefaults := schema.Defaults{
+		"purpose": schema.Omit,
+	}
+	fields := schema.Fields{
+		"num_available":     schema.Any(),
+		"largest_available": schema.Any(),
+		"num_unavailable":   schema.Any(),
+		"total_addresses":   schema.Any(),
+		// The usage is worked out from the counts.
+		"usage":            schema.Any(),
+		"usage_string":     schema.Any(),
+		"available_string": schema.Any(),
+		"first_address":    schema.Any(),
+		"last_address":     schema.Any(),
+		"ip_version":       schema.Any(),
+		"ranges":           schema.List(schema.FieldMap(rangeFields, rangeDefaults)),
+	}
+	defaults := schema.Defaults{
+		"usage":            schema.Omit,
+		"usage_string":     schema.Omit,
+		"available_string": schema.Omit,
+		"first_address":    schema.Omit,
+		"last_address":     schema.Omit,
+		"ip_version":       schema.Omit,
+		"ranges":           schema.Omit,
+	}
+	return fieldMap("subnet statistics", fields, defaults)
+}()
+
+func readSubnetUtilization(source interface{}) (*SubnetUtilization, error) {
+	coerced, err := subnetUtilizationChecker.Coerce(source, nil)
+	if err != nil {
+		return nil, WrapWithDeserializationError(err, "subnet statistics schema check failed")
+	}
+	valid := coerced.(map[string]interface{})
+	// From here we know that the map returned from the schema coercion
+	// contains fields of the right type.
+
+	var result SubnetUtilization
+	for _, count := range []struct {
+		name  string
+		value *uint64
+	}{
+		{"total_addresses", &result.TotalAddresses},
+		{"num_available", &result.AvailableAddresses},
+		{"num_unavailable", &result.UnavailableAddresses},
+		{"largest_available", &result.LargestFreeBlock},
+	} {
+		if *count.value, err = addressCount(valid[count.name]); err != nil {
+			return nil, WrapWithDeserializationError(err, "subnet statistics %s", count.name)
+		}
+	}
+	ranges, _ := valid["ranges"].([]interface{})
+	for _, value := range ranges {
+		r := value.(map[string]interface{})
+		var purposes []string
+		if list, ok := r["purpose"].([]interface{}); ok {
+			for _, purpose := range list {
+				purposes = append(purposes, purpose.(string))
+			}
+		}
+		if !conflictingPurposes(purposes) {
+			continue
+		}
+		size, err := addressCount(r["num_addresses"])
+		if err != nil {
+			return nil, WrapWithDeserializationError(err, "subnet statistics range")
+		}
+		result.Conflicts = append(result.Conflicts, SubnetAddressRange{
+			Start:        r["start"].(string),
+			End:          r["end"].(string),
+			NumAddresses: size,
+			Purposes:     purposes,
+		})
+	}
+	return &result, nil
+}
+
+// conflictingPurposes reports whether the purposes of a range are a dynamic
+// range and another use that mustn't be handed out by DHCP.
+func conflictingPurposes(purposes []string) bool {
+	dynamic, other := false, false
+	for _, purpose := range purposes {
+		switch {
+		case purpose == "dynamic":
+			dynamic = true
+		case conflictingRangePurposes.Contains(purpose):
+			other = true
+		}
+	}
+	return dynamic && other
+}
+
+// addressCount returns a count of addresses read from MAAS, saturating at
+// math.MaxUint64 for the counts of large IPv6 subnets.
+func addressCount(value interface{}) (uint64, error) {
+	var count *big.Int
+	switch v := value.(type) {
+	case json.Number:
+		count, _ = new(big.Int).SetString(string(v), 10)
+	case float64:
+		count, _ = big.NewFloat(v).Int(nil)
+	case int:
+		count = big.NewInt(int64(v))
+	}
+	if count == nil || count.Sign() < 0 {
+		return 0, errors.Errorf("expected address count, got %T(%v)", value, value)
+	}
+	if !count.IsUint64() {
+		return math.MaxUint64, nil
+	}
+	return count.Uint64(), nil
+}
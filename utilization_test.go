@@ -0,0 +1,127 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package gomaasapi
+
+import (
+	"context"
+	"math"
+	"net/http"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+)
+
+const (
+	subnet1StatisticsPath  = "/api/2.0/subnets/1/?include_ranges=true&op=statistics"
+	subnet34StatisticsPath = "/api/2.0/subnets/34/?include_ranges=true&op=statistics"
+	subnet1Statistics      = `{
+    "num_available": 150,
+    "largest_available": 99,
+    "num_unavailable": 104,
+    "total_addresses": 254,
+    "usage": 0.41,
+    "usage_string": "41%",
+    "available_string": "59%",
+    "first_address": "192.168.100.1",
+    "last_address": "192.168.100.254",
+    "ip_version": 4,
+    "ranges": [
+        {"start": "192.168.100.1", "end": "192.168.100.1", "num_addresses": 1, "purpose": ["gateway-ip"]},
+        {"start": "192.168.100.2", "end": "192.168.100.100", "num_addresses": 99, "purpose": ["unused"]},
+        {"start": "192.168.100.101", "end": "192.168.100.149", "num_addresses": 49, "purpose": ["dynamic"]},
+        {"start": "192.168.100.150", "end": "192.168.100.151", "num_addresses": 2, "purpose": ["assigned-ip", "dynamic"]},
+        {"start": "192.168.100.152", "end": "192.168.100.200", "num_addresses": 49, "purpose": ["dynamic"]}
+    ]
+}`
+	subnet34Statistics = `{
+    "num_available": 18446744073709551615,
+    "largest_available": 1208925819614629174706174,
+    "num_unavailable": 2,
+    "total_addresses": 1208925819614629174706176,
+    "ranges": []
+}`
+)
+
+func (s *controllerSuite) TestIPUtilization(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, subnetResponse)
+	s.server.AddGetResponse(subnet1StatisticsPath, http.StatusOK, subnet1Statistics)
+	s.server.AddGetResponse(subnet34StatisticsPath, http.StatusOK, subnet34Statistics)
+	controller := s.getController(c)
+
+	report, err := controller.IPUtilization(context.Background(), IPUtilizationArgs{Workers: 2})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(report.Subnets, gc.HasLen, 2)
+
+	first := report.Subnets[0]
+	c.Check(first.Subnet.ID(), gc.Equals, 1)
+	c.Check(first.TotalAddresses, gc.Equals, uint64(254))
+	c.Check(first.AvailableAddresses, gc.Equals, uint64(150))
+	c.Check(first.UnavailableAddresses, gc.Equals, uint64(104))
+	c.Check(first.LargestFreeBlock, gc.Equals, uint64(99))
+	c.Check(first.UsagePercent(), gc.Equals, 104.0/254*100)
+	c.Check(first.Conflicts, jc.DeepEquals, []SubnetAddressRange{{
+		Start:        "192.168.100.150",
+		End:          "192.168.100.151",
+		NumAddresses: 2,
+		Purposes:     []string{"assigned-ip", "dynamic"},
+	}})
+
+	// The counts of large IPv6 subnets saturate.
+	second := report.Subnets[1]
+	c.Check(second.Subnet.ID(), gc.Equals, 34)
+	c.Check(second.TotalAddresses, gc.Equals, uint64(math.MaxUint64))
+	c.Check(second.AvailableAddresses, gc.Equals, uint64(math.MaxUint64))
+	c.Check(second.LargestFreeBlock, gc.Equals, uint64(math.MaxUint64))
+	c.Check(second.Conflicts, gc.HasLen, 0)
+
+	c.Check(report.TotalAddresses, gc.Equals, uint64(math.MaxUint64))
+	c.Check(report.UnavailableAddresses, gc.Equals, uint64(106))
+	above := report.SubnetsAbove(90)
+	c.Assert(above, gc.HasLen, 1)
+	c.Check(above[0].Subnet.ID(), gc.Equals, 1)
+}
+
+func (s *controllerSuite) TestIPUtilizationSkipsDeletedSubnets(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, subnetResponse)
+	s.server.AddGetResponse(subnet1StatisticsPath, http.StatusOK, subnet1Statistics)
+	controller := s.getController(c)
+
+	report, err := controller.IPUtilization(context.Background(), IPUtilizationArgs{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(report.Subnets, gc.HasLen, 1)
+	c.Check(report.Subnets[0].Subnet.ID(), gc.Equals, 1)
+	c.Check(report.TotalAddresses, gc.Equals, uint64(254))
+}
+
+func (s *controllerSuite) TestIPUtilizationServerError(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, subnetResponse)
+	s.server.AddGetResponse(subnet1StatisticsPath, http.StatusOK, subnet1Statistics)
+	s.server.AddGetResponse(subnet34StatisticsPath, http.StatusInternalServerError, "boom")
+	controller := s.getController(c)
+
+	_, err := controller.IPUtilization(context.Background(), IPUtilizationArgs{})
+	c.Assert(err, jc.Satisfies, IsUnexpectedError)
+	c.Assert(err, gc.ErrorMatches, "reading statistics of subnet 34: .*")
+}
+
+func (s *controllerSuite) TestIPUtilizationCancelled(c *gc.C) {
+	s.server.AddGetResponse("/api/2.0/subnets/", http.StatusOK, subnetResponse)
+	controller := s.getController(c)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := controller.IPUtilization(ctx, IPUtilizationArgs{})
+	c.Assert(errors.Cause(err), gc.Equals, context.Canceled)
+}
+
+func (*controllerSuite) TestReadSubnetUtilizationBadCount(c *gc.C) {
+	_, err := readSubnetUtilization(map[string]interface{}{
+		"num_available":     "lots",
+		"largest_available": 1.0,
+		"num_unavailable":   1.0,
+		"total_addresses":   2.0,
+	})
+	c.Assert(err, jc.Satisfies, IsDeserializationError)
+}
@@ -4,14 +4,18 @@
 package gomaasapi
 
 import (
+	"net/url"
+	"strconv"
+
 	"github.com/juju/errors"
 	"github.com/juju/schema"
 	"github.com/juju/version"
 )
 
 type vlan struct {
-	// Add the controller in when we need to do things with the vlan.
-	// controller Controller
+	// controller is only set for VLANs read through the controller, and is
+	// used to look up the VLAN's subnets.
+	controller *controller
 
 	resourceURI string
 
@@ -25,6 +29,35 @@ type vlan struct {
 
 	primaryRack   string
 	secondaryRack string
+
+	relayVLAN    *vlan
+	space        string
+	externalDHCP string
+}
+
+// bind associates the VLAN, and the VLAN it relays DHCP to, with the
+// controller.
+func (v *vlan) bind(c *controller) {
+	v.controller = c
+	if v.relayVLAN != nil {
+		v.relayVLAN.bind(c)
+	}
+}
+
+func (v *vlan) updateFrom(other *vlan) {
+	v.resourceURI = other.resourceURI
+	v.id = other.id
+	v.name = other.name
+	v.fabric = other.fabric
+	v.vid = other.vid
+	v.mtu = other.mtu
+	v.dhcp = other.dhcp
+	v.primaryRack = other.primaryRack
+	v.secondaryRack = other.secondaryRack
+	v.relayVLAN = other.relayVLAN
+	v.space = other.space
+	v.externalDHCP = other.externalDHCP
+	v.bind(v.controller)
 }
 
 // ID implements VLAN.
@@ -57,6 +90,20 @@ func (v *vlan) DHCP() bool {
 	return v.dhcp
 }
 
+// Subnets implements VLAN.
+func (v *vlan) Subnets() ([]Subnet, error) {
+	if v.controller == nil {
+		return nil, errors.NotSupportedf("subnets of VLAN %d without a controller", v.id)
+	}
+	result, err := v.controller.findSubnets(func(s *subnet) bool {
+		return s.vlan.id == v.id
+	})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return result, nil
+}
+
 // PrimaryRack implements VLAN.
 func (v *vlan) PrimaryRack() string {
 	return v.primaryRack
@@ -67,6 +114,111 @@ func (v *vlan) SecondaryRack() string {
 	return v.secondaryRack
 }
 
+// RelayVLAN implements VLAN.
+func (v *vlan) RelayVLAN() VLAN {
+	if v.relayVLAN == nil {
+		return nil
+	}
+	return v.relayVLAN
+}
+
+// Space implements VLAN.
+func (v *vlan) Space() string {
+	return v.space
+}
+
+// ExternalDHCP implements VLAN.
+func (v *vlan) ExternalDHCP() string {
+	return v.externalDHCP
+}
+
+// SetRelay implements VLAN.
+//
+// Returns
+//  - NotValid error if the target is nil, or is the VLAN itself
+//  - NotSupported error if the VLAN wasn't obtained from a Controller
+//  - BadRequestError if the server rejects the relay
+//  - PermissionError if the user does not have permission to change the VLAN
+//  - NoMatchError if the VLAN cannot be found
+func (v *vlan) SetRelay(target VLAN) error {
+	if target == nil {
+		return errors.NotValidf("missing relay VLAN")
+	}
+	if target.ID() == v.id {
+		return errors.NotValidf("relaying VLAN %d to itself", v.id)
+	}
+	return v.updateRelay(strconv.Itoa(target.ID()))
+}
+
+// ClearRelay implements VLAN.
+//
+// Returns
+//  - NotSupported error if the VLAN wasn't obtained from a Controller
+//  - PermissionError if the user does not have permission to change the VLAN
+//  - NoMatchError if the VLAN cannot be found
+func (v *vlan) ClearRelay() error {
+	return v.updateRelay("")
+}
+
+func (v *vlan) updateRelay(relayVLAN string) error {
+	if v.controller == nil {
+		return errors.NotSupportedf("changing relay of VLAN %d without a controller", v.id)
+	}
+	params := NewURLParams()
+	// An empty value clears the relay, so it is always sent.
+	params.Values.Add("relay_vlan", relayVLAN)
+	return v.update(params.Values)
+}
+
+// SetDHCPRacks implements VLAN.
+//
+// Returns
+//  - NotValid error if the primary rack is empty, or the secondary rack
+//    is the primary rack
+//  - NotSupported error if the VLAN wasn't obtained from a Controller
+//  - BadRequestError if the server rejects the racks
+//  - PermissionError if the user does not have permission to change the VLAN
+//  - NoMatchError if the VLAN cannot be found
+func (v *vlan) SetDHCPRacks(primary, secondary string) error {
+	if primary == "" {
+		return errors.NotValidf("missing primary rack")
+	}
+	if secondary == primary {
+		return errors.NotValidf("secondary rack %q the same as the primary rack", secondary)
+	}
+	if v.controller == nil {
+		return errors.NotSupportedf("changing DHCP racks of VLAN %d without a controller", v.id)
+	}
+	params := NewURLParams()
+	params.Values.Add("dhcp_on", "true")
+	params.Values.Add("primary_rack", primary)
+	// An empty value clears the secondary rack, so it is always sent.
+	params.Values.Add("secondary_rack", secondary)
+	return v.update(params.Values)
+}
+
+// update changes the VLAN with the params, and updates it from the
+// response.
+func (v *vlan) update(params url.Values) error {
+	source, err := v.controller.put(v.resourceURI, params)
+	if err != nil {
+		return translateError(opEntity, err)
+	}
+	sourceMap, ok := source.(map[string]interface{})
+	if !ok {
+		return NewDeserializationError("unexpected value for vlan, %T", source)
+	}
+	response, err := vlan_2_0(sourceMap)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err = v.controller.checkDecoded("vlan", source, response); err != nil {
+		return errors.Trace(err)
+	}
+	v.updateFrom(response)
+	return nil
+}
+
 func readVLANs(controllerVersion version.Number, source interface{}) ([]*vlan, error) {
 	checker := schema.List(schema.StringMap(schema.Any()))
 	coerced, err := checker.Coerce(source, nil)
@@ -97,7 +249,7 @@ func readVLANList(sourceList []interface{}, readFunc vlanDeserializationFunc) ([
 		}
 		vlan, err := readFunc(source)
 		if err != nil {
-			return nil, errors.Annotatef(err, "vlan %d", i)
+			return nil, annotateListItem(err, "vlan", i, source)
 		}
 		result = append(result, vlan)
 	}
@@ -110,21 +262,30 @@ var vlanDeserializationFuncs = map[version.Number]vlanDeserializationFunc{
 	twoDotOh: vlan_2_0,
 }
 
+var vlan_2_0Checker = fieldMap("vlan", schema.Fields{
+	"id":           schema.ForceInt(),
+	"resource_uri": schema.String(),
+	"name":         schema.OneOf(schema.Nil(""), schema.String()),
+	"fabric":       schema.String(),
+	"vid":          schema.ForceInt(),
+	"mtu":          schema.ForceInt(),
+	"dhcp_on":      schema.Bool(),
+	// racks are not always set.
+	"primary_rack":   schema.OneOf(schema.Nil(""), schema.String()),
+	"secondary_rack": schema.OneOf(schema.Nil(""), schema.String()),
+
+	"relay_vlan":    schema.OneOf(schema.Nil(""), schema.StringMap(schema.Any())),
+	"space":         schema.OneOf(schema.Nil(""), schema.String()),
+	"external_dhcp": schema.OneOf(schema.Nil(""), schema.String()),
+}, schema.Defaults{
+	// The fields below aren't in the responses of all MAAS versions.
+	"relay_vlan":    schema.Omit,
+	"space":         schema.Omit,
+	"external_dhcp": schema.Omit,
+})
+
 func vlan_2_0(source map[string]interface{}) (*vlan, error) {
-	fields := schema.Fields{
-		"id":           schema.ForceInt(),
-		"resource_uri": schema.String(),
-		"name":         schema.OneOf(schema.Nil(""), schema.String()),
-		"fabric":       schema.String(),
-		"vid":          schema.ForceInt(),
-		"mtu":          schema.ForceInt(),
-		"dhcp_on":      schema.Bool(),
-		// racks are not always set.
-		"primary_rack":   schema.OneOf(schema.Nil(""), schema.String()),
-		"secondary_rack": schema.OneOf(schema.Nil(""), schema.String()),
-	}
-	checker := schema.FieldMap(fields, nil)
-	coerced, err := checker.Coerce(source, nil)
+	coerced, err := vlan_2_0Checker.Coerce(source, nil)
 	if err != nil {
 		return nil, errors.Annotatef(err, "vlan 2.0 schema check failed")
 	}
@@ -138,6 +299,16 @@ func vlan_2_0(source map[string]interface{}) (*vlan, error) {
 	primary_rack, _ := valid["primary_rack"].(string)
 	secondary_rack, _ := valid["secondary_rack"].(string)
 	name, _ := valid["name"].(string)
+	space, _ := valid["space"].(string)
+	externalDHCP, _ := valid["external_dhcp"].(string)
+
+	var relayVLAN *vlan
+	if relayMap, ok := valid["relay_vlan"].(map[string]interface{}); ok {
+		relayVLAN, err = vlan_2_0(relayMap)
+		if err != nil {
+			return nil, errors.Annotate(err, "relay VLAN")
+		}
+	}
 
 	result := &vlan{
 		resourceURI:   valid["resource_uri"].(string),
@@ -149,6 +320,9 @@ func vlan_2_0(source map[string]interface{}) (*vlan, error) {
 		dhcp:          valid["dhcp_on"].(bool),
 		primaryRack:   primary_rack,
 		secondaryRack: secondary_rack,
+		relayVLAN:     relayVLAN,
+		space:         space,
+		externalDHCP:  externalDHCP,
 	}
 	return result, nil
 }
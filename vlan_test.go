@@ -4,12 +4,18 @@
 package gomaasapi
 
 import (
+	"net/http"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
 	jc "github.com/juju/testing/checkers"
 	"github.com/juju/version"
 	gc "gopkg.in/check.v1"
 )
 
-type vlanSuite struct{}
+type vlanSuite struct {
+	testing.CleanupSuite
+}
 
 var _ = gc.Suite(&vlanSuite{})
 
@@ -18,6 +24,11 @@ func (*vlanSuite) TestReadVLANsBadSchema(c *gc.C) {
 	c.Assert(err.Error(), gc.Equals, `vlan base schema check failed: expected list, got string("wat?")`)
 }
 
+func (*vlanSuite) TestSubnetsWithoutController(c *gc.C) {
+	_, err := (&vlan{id: 1}).Subnets()
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+}
+
 func (s *vlanSuite) TestReadVLANsWithName(c *gc.C) {
 	vlans, err := readVLANs(twoDotOh, parseJSON(c, vlanResponseWithName))
 	c.Assert(err, jc.ErrorIsNil)
@@ -63,6 +74,138 @@ func (s *vlanSuite) TestReadVLANsWithoutName(c *gc.C) {
 	})
 }
 
+func (s *vlanSuite) TestReadVLANsRelay(c *gc.C) {
+	vlans, err := readVLANs(twoDotOh, parseJSON(c, vlanResponseWithRelay))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(vlans, gc.HasLen, 1)
+	readVLAN := vlans[0]
+	c.Check(readVLAN.Space(), gc.Equals, "dmz")
+	c.Check(readVLAN.ExternalDHCP(), gc.Equals, "10.0.0.2")
+	relay := readVLAN.RelayVLAN()
+	c.Assert(relay, gc.NotNil)
+	c.Check(relay.ID(), gc.Equals, 5006)
+	c.Check(relay.RelayVLAN(), gc.IsNil)
+}
+
+func (s *vlanSuite) TestReadVLANsWithoutRelay(c *gc.C) {
+	vlans, err := readVLANs(twoDotOh, parseJSON(c, vlanResponseWithName))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(vlans[0].RelayVLAN(), gc.IsNil)
+	c.Check(vlans[0].Space(), gc.Equals, "")
+	c.Check(vlans[0].ExternalDHCP(), gc.Equals, "")
+}
+
+func (s *vlanSuite) getServerAndVLANs(c *gc.C) (*SimpleTestServer, []VLAN) {
+	server, controller := createTestServerController(c, s)
+	server.AddGetResponse("/api/2.0/fabrics/", http.StatusOK, fabricResponse)
+	fabrics, err := controller.Fabrics()
+	c.Assert(err, jc.ErrorIsNil)
+	return server, []VLAN{fabrics[0].VLANs()[0], fabrics[1].VLANs()[0]}
+}
+
+func (s *vlanSuite) TestSetRelay(c *gc.C) {
+	server, vlans := s.getServerAndVLANs(c)
+	response := updateJSONMap(c, fabricVLANResponse, map[string]interface{}{
+		"relay_vlan": parseJSON(c, fabricRelayVLANResponse),
+	})
+	server.AddPutResponse("/MAAS/api/2.0/vlans/5001/", http.StatusOK, response)
+
+	err := vlans[1].SetRelay(vlans[0])
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(vlans[1].RelayVLAN().ID(), gc.Equals, 1)
+	c.Check(server.LastRequest().PostForm.Get("relay_vlan"), gc.Equals, "1")
+}
+
+func (s *vlanSuite) TestSetRelayValidates(c *gc.C) {
+	server, vlans := s.getServerAndVLANs(c)
+	server.ResetRequests()
+
+	err := vlans[0].SetRelay(nil)
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	err = vlans[0].SetRelay(vlans[0])
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *vlanSuite) TestSetRelayBadRequest(c *gc.C) {
+	server, vlans := s.getServerAndVLANs(c)
+	server.AddPutResponse("/MAAS/api/2.0/vlans/5001/", http.StatusBadRequest, "relay VLAN has DHCP off")
+	err := vlans[1].SetRelay(vlans[0])
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+	c.Check(err.Error(), gc.Equals, "relay VLAN has DHCP off")
+}
+
+func (s *vlanSuite) TestClearRelay(c *gc.C) {
+	server, vlans := s.getServerAndVLANs(c)
+	server.AddPutResponse("/MAAS/api/2.0/vlans/5001/", http.StatusOK, fabricVLANResponse)
+
+	err := vlans[1].ClearRelay()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(vlans[1].RelayVLAN(), gc.IsNil)
+	form := server.LastRequest().PostForm
+	c.Check(form["relay_vlan"], jc.DeepEquals, []string{""})
+}
+
+func (s *vlanSuite) TestClearRelayForbidden(c *gc.C) {
+	server, vlans := s.getServerAndVLANs(c)
+	server.AddPutResponse("/MAAS/api/2.0/vlans/5001/", http.StatusForbidden, "bad user")
+	err := vlans[1].ClearRelay()
+	c.Check(err, jc.Satisfies, IsPermissionError)
+}
+
+func (s *vlanSuite) TestSetDHCPRacks(c *gc.C) {
+	server, vlans := s.getServerAndVLANs(c)
+	response := updateJSONMap(c, fabricVLANResponse, map[string]interface{}{
+		"dhcp_on":        true,
+		"primary_rack":   "4y3h7n",
+		"secondary_rack": "7k3h6e",
+	})
+	server.AddPutResponse("/MAAS/api/2.0/vlans/5001/", http.StatusOK, response)
+
+	err := vlans[1].SetDHCPRacks("4y3h7n", "7k3h6e")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Check(vlans[1].DHCP(), jc.IsTrue)
+	c.Check(vlans[1].PrimaryRack(), gc.Equals, "4y3h7n")
+	c.Check(vlans[1].SecondaryRack(), gc.Equals, "7k3h6e")
+	form := server.LastRequest().PostForm
+	c.Check(form.Get("dhcp_on"), gc.Equals, "true")
+	c.Check(form.Get("primary_rack"), gc.Equals, "4y3h7n")
+	c.Check(form.Get("secondary_rack"), gc.Equals, "7k3h6e")
+}
+
+func (s *vlanSuite) TestSetDHCPRacksClearsSecondary(c *gc.C) {
+	server, vlans := s.getServerAndVLANs(c)
+	server.AddPutResponse("/MAAS/api/2.0/vlans/5001/", http.StatusOK, fabricVLANResponse)
+
+	err := vlans[1].SetDHCPRacks("4y3h7n", "")
+	c.Assert(err, jc.ErrorIsNil)
+	form := server.LastRequest().PostForm
+	c.Check(form["secondary_rack"], jc.DeepEquals, []string{""})
+}
+
+func (s *vlanSuite) TestSetDHCPRacksValidates(c *gc.C) {
+	server, vlans := s.getServerAndVLANs(c)
+	server.ResetRequests()
+
+	err := vlans[0].SetDHCPRacks("", "7k3h6e")
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	err = vlans[0].SetDHCPRacks("4y3h7n", "4y3h7n")
+	c.Check(err, jc.Satisfies, errors.IsNotValid)
+	c.Check(server.RequestCount(), gc.Equals, 0)
+}
+
+func (s *vlanSuite) TestSetDHCPRacksBadRequest(c *gc.C) {
+	server, vlans := s.getServerAndVLANs(c)
+	server.AddPutResponse("/MAAS/api/2.0/vlans/5001/", http.StatusBadRequest, "rack not on VLAN")
+	err := vlans[1].SetDHCPRacks("4y3h7n", "")
+	c.Check(err, jc.Satisfies, IsBadRequestError)
+}
+
+func (*vlanSuite) TestRelayWithoutController(c *gc.C) {
+	err := (&vlan{id: 1}).ClearRelay()
+	c.Assert(err, jc.Satisfies, errors.IsNotSupported)
+}
+
 func (*vlanSuite) TestLowVersion(c *gc.C) {
 	_, err := readVLANs(version.MustParse("1.9.0"), parseJSON(c, vlanResponseWithName))
 	c.Assert(err.Error(), gc.Equals, `no vlan read func for version 1.9.0`)
@@ -105,5 +248,63 @@ const (
         "secondary_rack": null
     }
 ]
+`
+	vlanResponseWithRelay = `
+[
+    {
+        "dhcp_on": false,
+        "id": 5007,
+        "mtu": 1500,
+        "fabric": "maas-management",
+        "vid": 40,
+        "primary_rack": null,
+        "name": "dmz",
+        "space": "dmz",
+        "external_dhcp": "10.0.0.2",
+        "relay_vlan": {
+            "dhcp_on": true,
+            "id": 5006,
+            "mtu": 1500,
+            "fabric": "maas-management",
+            "vid": 30,
+            "primary_rack": "4y3h7n",
+            "name": null,
+            "external_dhcp": null,
+            "relay_vlan": null,
+            "space": "undefined",
+            "resource_uri": "/MAAS/api/2.0/vlans/5006/",
+            "secondary_rack": null
+        },
+        "resource_uri": "/MAAS/api/2.0/vlans/5007/",
+        "secondary_rack": null
+    }
+]
+`
+	fabricVLANResponse = `
+{
+    "name": "untagged",
+    "vid": 0,
+    "primary_rack": null,
+    "resource_uri": "/MAAS/api/2.0/vlans/5001/",
+    "id": 5001,
+    "secondary_rack": null,
+    "fabric": "fabric-1",
+    "mtu": 1500,
+    "dhcp_on": false,
+    "relay_vlan": null
+}
+`
+	fabricRelayVLANResponse = `
+{
+    "name": "untagged",
+    "vid": 0,
+    "primary_rack": "4y3h7n",
+    "resource_uri": "/MAAS/api/2.0/vlans/1/",
+    "id": 1,
+    "secondary_rack": null,
+    "fabric": "fabric-0",
+    "mtu": 1500,
+    "dhcp_on": true
+}
 `
 )
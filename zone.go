@@ -60,7 +60,7 @@ func readZoneList(sourceList []interface{}, readFunc zoneDeserializationFunc) ([
 		}
 		zone, err := readFunc(source)
 		if err != nil {
-			return nil, errors.Annotatef(err, "zone %d", i)
+			return nil, annotateListItem(err, "zone", i, source)
 		}
 		result = append(result, zone)
 	}
@@ -73,14 +73,14 @@ var zoneDeserializationFuncs = map[version.Number]zoneDeserializationFunc{
 	twoDotOh: zone_2_0,
 }
 
+var zone_2_0Checker = fieldMap("zone", schema.Fields{
+	"name":         schema.String(),
+	"description":  schema.String(),
+	"resource_uri": schema.String(),
+}, nil) // no defaults
+
 func zone_2_0(source map[string]interface{}) (*zone, error) {
-	fields := schema.Fields{
-		"name":         schema.String(),
-		"description":  schema.String(),
-		"resource_uri": schema.String(),
-	}
-	checker := schema.FieldMap(fields, nil) // no defaults
-	coerced, err := checker.Coerce(source, nil)
+	coerced, err := zone_2_0Checker.Coerce(source, nil)
 	if err != nil {
 		return nil, errors.Annotatef(err, "zone 2.0 schema check failed")
 	}